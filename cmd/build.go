@@ -2,33 +2,114 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/yarlson/ftl/pkg/build"
 	"github.com/yarlson/ftl/pkg/config"
 	"github.com/yarlson/ftl/pkg/console"
+	"github.com/yarlson/ftl/pkg/deployment"
 	"github.com/yarlson/ftl/pkg/runner/local"
 )
 
 var buildCmd = &cobra.Command{
-	Use:   "build",
+	Use:   "build [service...]",
 	Short: "Build your application Docker images",
 	Long: `Build your application Docker images as defined in ftl.yaml.
 This command handles the entire build process, including
-building and pushing the Docker images to the registry.`,
-	Run: runBuild,
+building and pushing the Docker images to the registry.
+
+Name services as arguments to build only those services.
+
+With scan set in ftl.yaml, every image is checked for known vulnerabilities
+with Trivy before it is pushed; --skip-scan leaves the scan out.
+
+Images are built with the local Docker daemon unless the builder section of
+ftl.yaml, or --builder-context, --builder-host and --buildx-builder, select
+another one, such as a remote build machine or Depot. With builder.sync or
+--builder-sync, build contexts are kept on an ssh:// builder host and only
+the files that changed are uploaded.`,
+	ValidArgsFunction: completeServices,
+	Run:               runBuild,
 }
 
 func init() {
 	rootCmd.AddCommand(buildCmd)
 	buildCmd.Flags().Bool("skip-push", false, "Skip pushing images to registry after building")
+	buildCmd.Flags().BoolP("verbose", "v", false, "Stream the full build output")
+	buildCmd.Flags().Bool("skip-scan", false, "Skip the vulnerability scan configured in ftl.yaml")
+	addBuilderFlags(buildCmd)
+	addConfigFlag(buildCmd)
+}
+
+// addBuilderFlags registers the flags selecting where images are built,
+// which override the builder section of the configuration.
+func addBuilderFlags(cmd *cobra.Command) {
+	cmd.Flags().String("builder-context", "", "Build with the Docker daemon of this docker context (default from builder.context)")
+	cmd.Flags().String("builder-host", "", "Build with the Docker daemon at this address, e.g. ssh://user@host (default from builder.host)")
+	cmd.Flags().String("buildx-builder", "", "Build on this buildx builder (default from builder.buildx)")
+	cmd.Flags().Bool("builder-sync", false, "Sync build contexts to the ssh:// builder host and upload only changed files (default from builder.sync)")
+}
+
+// newBuilder returns the builder of the images of cfg, building where the
+// flags of addBuilderFlags or the builder section of cfg select.
+func newBuilder(cmd *cobra.Command, cfg *config.Config) (*build.Build, error) {
+	var target build.Target
+	if cfg.Builder != nil {
+		target = build.Target{Context: cfg.Builder.Context, Host: cfg.Builder.Host, Builder: cfg.Builder.Buildx, Sync: cfg.Builder.Sync}
+	}
+
+	builderContext, err := cmd.Flags().GetString("builder-context")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get builder-context flag: %w", err)
+	}
+	builderHost, err := cmd.Flags().GetString("builder-host")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get builder-host flag: %w", err)
+	}
+	buildxBuilder, err := cmd.Flags().GetString("buildx-builder")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get buildx-builder flag: %w", err)
+	}
+	builderSync, err := cmd.Flags().GetBool("builder-sync")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get builder-sync flag: %w", err)
+	}
+
+	// A daemon selected on the command line replaces the configured one.
+	switch {
+	case builderContext != "" && builderHost != "":
+		return nil, errors.New("--builder-context and --builder-host cannot be used together")
+	case builderContext != "":
+		// Contexts are only synced to SSH hosts.
+		target.Context, target.Host, target.Sync = builderContext, "", false
+	case builderHost != "":
+		target.Context, target.Host = "", builderHost
+	}
+	if buildxBuilder != "" {
+		target.Builder = buildxBuilder
+	}
+	if builderSync {
+		target.Sync = true
+	}
+	if target.Sync && !strings.HasPrefix(target.Host, "ssh://") {
+		return nil, errors.New("syncing the build context requires an ssh:// builder host")
+	}
+
+	builder := build.NewBuild(local.NewRunner())
+	builder.SetTarget(target)
+	return builder, nil
 }
 
 func runBuild(cmd *cobra.Command, args []string) {
-	cfg, err := parseConfig("ftl.yaml")
+	cfg, err := parseConfig(configFile)
 	if err != nil {
 		console.Error("Failed to parse config file:", err)
 		return
@@ -40,21 +121,164 @@ func runBuild(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	runner := local.NewRunner()
-	builder := build.NewBuild(runner)
+	verbose, err := cmd.Flags().GetBool("verbose")
+	if err != nil {
+		console.Error("Failed to get verbose flag:", err)
+		return
+	}
+
+	skipScan, err := cmd.Flags().GetBool("skip-scan")
+	if err != nil {
+		console.Error("Failed to get skip-scan flag:", err)
+		return
+	}
+	scan := cfg.Scan
+	if skipScan {
+		scan = nil
+	}
+
+	services := cfg.Services
+	if len(args) > 0 {
+		selected, err := deployment.SelectServices(cfg, args, nil)
+		if err != nil {
+			console.Error(err)
+			return
+		}
+		services = nil
+		for _, svc := range cfg.Services {
+			if slices.Contains(selected, svc.Name) {
+				services = append(services, svc)
+			}
+		}
+	}
+
+	builder, err := newBuilder(cmd, cfg)
+	if err != nil {
+		console.Error(err)
+		return
+	}
 
 	ctx := context.Background()
 
-	if err := buildAndPushServices(ctx, cfg.Project.Name, cfg.Services, builder, skipPush); err != nil {
+	var output buildOutput
+	var finish func(err error)
+	if verbose {
+		output = func(service, line string) {
+			console.Print(fmt.Sprintf("[%s] %s", service, line))
+		}
+		finish = func(err error) {
+			if err == nil {
+				console.Success("Build complete")
+			}
+		}
+	} else {
+		var end func(err error)
+		output, end = startBuildProgress()
+		finish = func(err error) {
+			end(err)
+			if err == nil {
+				console.Success("Build complete")
+			}
+		}
+	}
+
+	digests, timings, err := buildAndPushServices(ctx, cfg.Project.Name, services, builder, skipPush, scan, output)
+	finish(err)
+	if err != nil {
 		console.Error("Build process failed:", err)
 		return
 	}
+
+	if len(digests) > 0 {
+		if err := build.SaveDigests(digestsPath(), digests); err != nil {
+			console.Warning(fmt.Sprintf("Failed to record image digests: %v", err))
+		}
+	}
+	if err := build.SaveTimings(buildPath(build.TimingsFile), timings); err != nil {
+		console.Warning(fmt.Sprintf("Failed to record build timings: %v", err))
+	}
+}
+
+// digestsPath returns where the digests of pushed images are recorded, next
+// to the configuration file.
+func digestsPath() string {
+	return buildPath(build.DigestsFile)
+}
+
+// buildPath returns the path of the build record name relative to the
+// directory of the configuration file.
+func buildPath(name string) string {
+	dir := "."
+	if configFile != "-" {
+		dir = filepath.Dir(configFile)
+	}
+	return filepath.Join(dir, name)
+}
+
+// buildOutput receives each line of build output together with the name of
+// the service being built.
+type buildOutput func(service, line string)
+
+// startBuildProgress shows the progress of every service being built on a
+// line of its own, with anything else printed meanwhile scrolling above them.
+// It returns the output to build with and the function that ends the lines
+// once the build is over.
+func startBuildProgress() (buildOutput, func(err error)) {
+	renderer := console.StartRenderer()
+	restore, err := renderer.Capture()
+	if err != nil {
+		restore = func() {}
+	}
+
+	var mu sync.Mutex
+	regions := map[string]*console.Region{}
+	progress := map[string]*build.Progress{}
+	output := func(service, line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if progress[service] == nil {
+			progress[service] = &build.Progress{}
+			regions[service] = renderer.Region(service, "starting")
+		}
+		if status, ok := progress[service].Update(line); ok {
+			regions[service].Update(status)
+		}
+	}
+
+	end := func(err error) {
+		restore()
+		mu.Lock()
+		defer mu.Unlock()
+		// A failed build stops every service, so the lines keep their last
+		// status and the error says which one failed.
+		if err == nil {
+			for _, region := range regions {
+				region.Done("built")
+			}
+		}
+		renderer.Stop()
+	}
+
+	return output, end
 }
 
-// buildAndPushServices builds and pushes all services concurrently.
-func buildAndPushServices(ctx context.Context, project string, services []config.Service, builder *build.Build, skipPush bool) error {
+// buildAndPushServices builds and pushes all services concurrently, scanning
+// every image first when scan is set. It returns the digest of every pushed
+// image, keyed by image, and the seconds spent building and pushing, summed
+// over the services.
+func buildAndPushServices(ctx context.Context, project string, services []config.Service, builder *build.Build, skipPush bool, scan *config.Scan, output buildOutput) (map[string]string, map[string]float64, error) {
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(services))
+	var mu sync.Mutex
+	digests := map[string]string{}
+	timings := map[string]float64{}
+	timed := func(phase string, started time.Time) {
+		mu.Lock()
+		timings[phase] += time.Since(started).Seconds()
+		mu.Unlock()
+	}
+	// Without a cache directory pre-build commands run on every build.
+	preCacheDir, _ := build.DefaultPreCacheDir()
 
 	for _, svc := range services {
 		wg.Add(1)
@@ -66,22 +290,87 @@ func buildAndPushServices(ctx context.Context, project string, services []config
 				image = fmt.Sprintf("%s-%s", project, serviceName)
 			}
 
+			cached, err := builder.Pre(ctx, project, &svc, preCacheDir, func(line string) { output(serviceName, line) })
+			if err != nil {
+				errChan <- fmt.Errorf("failed to run pre-build commands of service %s: %w", serviceName, err)
+				return
+			}
+			if cached {
+				output(serviceName, fmt.Sprintf("Restored %s from the build cache", svc.Build.Output))
+			}
+
+			opts := build.ServiceOptions(&svc)
+			if len(opts.Platforms) > 1 {
+				// Multi-platform images only exist in the registry, so they
+				// are pushed while they are built.
+				if skipPush {
+					errChan <- fmt.Errorf("service %s builds for several platforms, which cannot be done without pushing", serviceName)
+					return
+				}
+				started := time.Now()
+				digest, err := builder.BuildMultiPlatform(ctx, svc.Image, svc.Path, opts, func(line string) { output(serviceName, line) })
+				timed(deployment.PhaseBuild, started)
+				if err != nil {
+					errChan <- fmt.Errorf("failed to build service %s: %w", serviceName, err)
+					return
+				}
+				// The digest is not recorded for a failed scan, so ftl
+				// deploy does not pin the image.
+				if err := scanImage(ctx, builder, scan, &svc, svc.Image+"@"+digest); err != nil {
+					errChan <- err
+					return
+				}
+				mu.Lock()
+				digests[svc.Image] = digest
+				mu.Unlock()
+				return
+			}
+
 			// Build service
-			if err := builder.Build(ctx, image, svc.Path); err != nil {
+			started := time.Now()
+			err = builder.Build(ctx, image, svc.Path, opts, func(line string) { output(serviceName, line) })
+			timed(deployment.PhaseBuild, started)
+			if err != nil {
 				errChan <- fmt.Errorf("failed to build service %s: %w", serviceName, err)
 				return
 			}
 
+			// Images built on a remote daemon are only there; the local
+			// daemon needs those that are scanned or not pushed.
+			if svc.Image == "" || skipPush || scan != nil {
+				if err := builder.Fetch(ctx, image); err != nil {
+					errChan <- fmt.Errorf("failed to copy image of service %s from the builder: %w", serviceName, err)
+					return
+				}
+			}
+
+			if err := scanImage(ctx, builder, scan, &svc, image); err != nil {
+				errChan <- err
+				return
+			}
+
 			// Skip push if requested or if using local image
 			if skipPush || svc.Image == "" {
 				return
 			}
 
 			// Push service
-			if err := builder.Push(ctx, svc.Image); err != nil {
+			started = time.Now()
+			err = builder.Push(ctx, svc.Image)
+			timed(deployment.PhasePush, started)
+			if err != nil {
 				errChan <- fmt.Errorf("failed to push service %s: %w", serviceName, err)
 				return
 			}
+
+			digest, err := builder.Digest(ctx, svc.Image)
+			if err != nil {
+				errChan <- fmt.Errorf("failed to get digest of service %s: %w", serviceName, err)
+				return
+			}
+			mu.Lock()
+			digests[svc.Image] = digest
+			mu.Unlock()
 		}(svc)
 	}
 
@@ -94,8 +383,31 @@ func buildAndPushServices(ctx context.Context, project string, services []config
 	}
 
 	if len(errs) > 0 {
-		return fmt.Errorf("errors occurred during build/push: %v", errs)
+		return nil, nil, fmt.Errorf("errors occurred during build/push: %v", errs)
+	}
+
+	return digests, timings, nil
+}
+
+// scanImage scans image, built for service, when scan is set. Vulnerabilities
+// fail the build unless scan only warns about them.
+func scanImage(ctx context.Context, builder *build.Build, scan *config.Scan, service *config.Service, image string) error {
+	if scan == nil {
+		return nil
 	}
 
+	result, err := builder.Scan(ctx, image, build.ServiceScanOptions(scan, service))
+	if err != nil {
+		return fmt.Errorf("failed to scan service %s: %w", service.Name, err)
+	}
+	if len(result.Vulnerabilities) == 0 {
+		return nil
+	}
+
+	message := fmt.Sprintf("image of service %s has vulnerabilities of severity %s or above (%s), see %s", service.Name, scan.Threshold(), result.Summary(), result.Report)
+	if scan.Fails() {
+		return errors.New(message)
+	}
+	console.Warning(message)
 	return nil
 }
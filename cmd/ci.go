@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/yarlson/ftl/pkg/ci"
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/console"
+	"github.com/yarlson/ftl/pkg/ssh"
+)
+
+var ciCmd = &cobra.Command{
+	Use:   "ci",
+	Short: "Set up continuous deployment",
+}
+
+var ciInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Generate a CI pipeline that builds and deploys the project",
+	Long: `Init writes a GitHub Actions workflow (--github) or a GitLab CI pipeline
+(--gitlab) that runs ftl build and ftl deploy on every push to the branch.
+
+The pipeline logs in to the registries of the service images, with OIDC for
+ECR and Artifact Registry, and connects to the server with the private key
+in the FTL_SSH_KEY secret, so no credentials need to be in ftl.yaml. The
+environment variables ftl.yaml references without a default are passed from
+secrets of the same name. The settings the pipeline expects are listed at the
+top of the generated file.`,
+	Run: runCIInit,
+}
+
+func init() {
+	rootCmd.AddCommand(ciCmd)
+	ciCmd.AddCommand(ciInitCmd)
+
+	ciInitCmd.Flags().Bool("github", false, "Generate a GitHub Actions workflow")
+	ciInitCmd.Flags().Bool("gitlab", false, "Generate a GitLab CI pipeline")
+	ciInitCmd.Flags().String("branch", "main", "Deploy pushes to this branch")
+	ciInitCmd.Flags().Bool("force", false, "Overwrite an existing pipeline")
+	addConfigFlag(ciInitCmd)
+	addEnvFlag(ciInitCmd)
+	ciInitCmd.MarkFlagsOneRequired("github", "gitlab")
+	ciInitCmd.MarkFlagsMutuallyExclusive("github", "gitlab")
+}
+
+func runCIInit(cmd *cobra.Command, args []string) {
+	gitlab, err := cmd.Flags().GetBool("gitlab")
+	if err != nil {
+		console.Error("Failed to get gitlab flag:", err)
+		return
+	}
+
+	branch, err := cmd.Flags().GetString("branch")
+	if err != nil {
+		console.Error("Failed to get branch flag:", err)
+		return
+	}
+
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		console.Error("Failed to get force flag:", err)
+		return
+	}
+
+	if configFile == "-" {
+		console.Error("ci init cannot read the configuration from stdin")
+		return
+	}
+
+	provider := ci.GitHub
+	if gitlab {
+		provider = ci.GitLab
+	}
+	path := provider.Path()
+	if _, err := os.Stat(path); err == nil && !force {
+		console.Error(path + " already exists, use --force to overwrite it")
+		return
+	}
+
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		console.Error("Failed to read config file:", err)
+		return
+	}
+
+	cfg, err := parseConfig(configFile)
+	if err != nil {
+		console.Error("Failed to parse config file:", err)
+		return
+	}
+
+	for _, pattern := range cfg.Include {
+		matches, _ := filepath.Glob(filepath.Join(filepath.Dir(configFile), pattern))
+		for _, match := range matches {
+			if included, err := os.ReadFile(match); err == nil {
+				data = append(append(data, '\n'), included...)
+			}
+		}
+	}
+
+	variables := slices.DeleteFunc(config.RequiredVariables(data), func(name string) bool {
+		return name == ssh.KeyEnv
+	})
+	pipeline, err := ci.Generate(provider, cfg, ci.Options{
+		Branch:      branch,
+		Environment: environment,
+		Variables:   variables,
+	})
+	if err != nil {
+		console.Error(err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		console.Error(fmt.Sprintf("Failed to create %s: %v", filepath.Dir(path), err))
+		return
+	}
+	if err := os.WriteFile(path, pipeline, 0644); err != nil {
+		console.Error("Failed to write "+path+":", err)
+		return
+	}
+
+	console.Success("Created " + path)
+	console.Info("Add the secrets listed at the top of " + path + " to the CI settings of the repository")
+	if _, err := os.Stat(knownHostsPath()); err != nil {
+		console.Warning(`Run "ftl server trust" and commit ` + knownHostsPath() + " so the pipeline can verify the server")
+	}
+}
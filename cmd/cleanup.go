@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yarlson/pin"
+
+	"github.com/yarlson/ftl/pkg/console"
+	"github.com/yarlson/ftl/pkg/deployment"
+)
+
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Remove unused images, containers and volumes from the server",
+	Long: `Cleanup applies the retention policy from the cleanup section of ftl.yaml
+on the server. It removes stopped containers left behind on the project
+network, all but the newest keep_releases tags of the images the project
+uses, untagged images older than prune_older_than and anonymous volumes
+that no container uses.
+
+Images and volumes still used by a container and named volumes are never
+removed. The same policy runs after every deploy when cleanup is configured.`,
+	Run: runCleanup,
+}
+
+func init() {
+	rootCmd.AddCommand(cleanupCmd)
+	addConfigFlag(cleanupCmd)
+	addEnvFlag(cleanupCmd)
+}
+
+func runCleanup(cmd *cobra.Command, args []string) {
+	pCleanup := pin.New("Cleaning up server", pin.WithSpinnerColor(pin.ColorCyan))
+	cancelCleanup := pCleanup.Start(context.Background())
+	defer cancelCleanup()
+
+	cfg, err := parseConfig(configFile)
+	if err != nil {
+		pCleanup.Fail(fmt.Sprintf("Failed to parse config file: %v", err))
+		return
+	}
+
+	runner, err := connectToServer(cfg.Server)
+	if err != nil {
+		pCleanup.Fail(fmt.Sprintf("Failed to connect to server %s: %v", cfg.Server.Host, err))
+		return
+	}
+	defer runner.Close()
+
+	report, err := deployment.NewDeployment(runner, nil).Cleanup(context.Background(), cfg.Project.Name, cfg, pCleanup)
+	if err != nil {
+		pCleanup.Fail(fmt.Sprintf("Cleanup failed: %v", err))
+		return
+	}
+
+	if report.Empty() && report.Reclaimed == "" {
+		pCleanup.Stop("Nothing to clean up")
+		return
+	}
+
+	pCleanup.Stop("Cleanup complete")
+	printCleanupReport(report)
+}
+
+func printCleanupReport(report *deployment.CleanupReport) {
+	if len(report.Containers) > 0 {
+		console.Info(fmt.Sprintf("Removed containers: %s", strings.Join(report.Containers, ", ")))
+	}
+	if len(report.Images) > 0 {
+		console.Info(fmt.Sprintf("Removed images: %s", strings.Join(report.Images, ", ")))
+	}
+	if len(report.Volumes) > 0 {
+		console.Info(fmt.Sprintf("Removed volumes: %s", strings.Join(report.Volumes, ", ")))
+	}
+	if report.Reclaimed != "" {
+		console.Info(fmt.Sprintf("Reclaimed space: %s", report.Reclaimed))
+	}
+}
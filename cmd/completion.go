@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/yarlson/ftl/pkg/config"
+)
+
+// completeServices completes the names of the services in the configuration
+// that are not arguments yet.
+func completeServices(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeNames(args, func(cfg *config.Config) []string {
+		var names []string
+		for _, service := range cfg.Services {
+			names = append(names, service.Name)
+		}
+		return names
+	})
+}
+
+// completeDependencies completes the names of the dependencies in the
+// configuration that are not arguments yet.
+func completeDependencies(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeNames(args, dependencyNames)
+}
+
+// completeContainer completes the single service or dependency argument of
+// logs.
+func completeContainer(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	services, directive := completeServices(cmd, args, toComplete)
+	dependencies, _ := completeDependencies(cmd, args, toComplete)
+	return append(services, dependencies...), directive
+}
+
+// completeNames returns the names listed by the configuration selected with
+// --file, leaving out args. Completion stays quiet when it cannot be read.
+func completeNames(args []string, list func(*config.Config) []string) ([]string, cobra.ShellCompDirective) {
+	cfg, err := config.ParseConfigFile(configFile)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, name := range list(cfg) {
+		if !slices.Contains(args, name) {
+			names = append(names, name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func dependencyNames(cfg *config.Config) []string {
+	var names []string
+	for _, dependency := range cfg.Dependencies {
+		names = append(names, dependency.Name)
+	}
+	return names
+}
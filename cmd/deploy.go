@@ -4,110 +4,487 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path/filepath"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/yarlson/pin"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 
+	"github.com/yarlson/ftl/pkg/build"
 	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/console"
 	"github.com/yarlson/ftl/pkg/deployment"
+	"github.com/yarlson/ftl/pkg/ftl"
 	"github.com/yarlson/ftl/pkg/imagesync"
 	"github.com/yarlson/ftl/pkg/runner/remote"
 	"github.com/yarlson/ftl/pkg/ssh"
 )
 
 var deployCmd = &cobra.Command{
-	Use:   "deploy",
+	Use:   "deploy [service...]",
 	Short: "Deploy your application to configured server",
 	Long: `Deploy your application to the server defined in ftl.yaml.
 This command handles the entire deployment process, ensuring
-zero-downtime updates of your services.`,
-	Run: runDeploy,
+zero-downtime updates of your services.
+
+Name services as arguments or with --only to deploy just those services,
+or use --skip to leave services out. Dependencies are always deployed and
+the proxy keeps routing to services that are already running.
+
+With --env the deployment targets an environment of ftl.yaml. --all-envs
+deploys every environment in the order they are listed, asking before those
+marked confirm, and stops at the first one that fails.
+
+With --dry-run nothing is changed: deploy prints every command it would run
+on the server and every file it would write there, such as the proxy
+configuration, assuming nothing is deployed yet. Add --json for a JSON array
+of the steps. The deploy lock, the history and local hooks are left out.`,
+	ValidArgsFunction: completeServices,
+	Run:               runDeploy,
 }
 
 func init() {
 	rootCmd.AddCommand(deployCmd)
+	deployCmd.Flags().Bool("force-unlock", false, "Take over the deploy lock left behind by an interrupted deployment")
+	deployCmd.Flags().Bool("notify", false, "Show a desktop notification when the deployment finishes")
+	deployCmd.Flags().StringSlice("only", nil, "Deploy only these services")
+	deployCmd.Flags().StringSlice("skip", nil, "Deploy all services except these")
+	deployCmd.Flags().Bool("pin-digests", true, "Deploy pushed images by the digest recorded by ftl build")
+	deployCmd.Flags().Bool("all-envs", false, "Deploy every environment of ftl.yaml in order")
+	deployCmd.Flags().Bool("yes", false, "Deploy environments that require confirmation without asking")
+	deployCmd.Flags().Bool("dry-run", false, "Print the commands and files the deployment would send to the server without connecting to it")
+	deployCmd.Flags().Bool("json", false, "Print the steps of a dry run as JSON")
+	deployCmd.Flags().Int("parallel-uploads", 0, "Upload at most this many image layers at once (default from server.transfer, or 1)")
+	deployCmd.Flags().String("bandwidth", "", "Cap the upload bandwidth, e.g. 2m or 20mbit (default from server.transfer)")
+	addConfigFlag(deployCmd)
+	addEnvFlag(deployCmd)
+	addProfileFlag(deployCmd)
 }
 
 func runDeploy(cmd *cobra.Command, args []string) {
-	pDeploy := pin.New("Deploying", pin.WithSpinnerColor(pin.ColorCyan))
+	asJSON, _ := cmd.Flags().GetBool("json")
+	spinnerOptions := []pin.Option{pin.WithSpinnerColor(pin.ColorCyan)}
+	if asJSON {
+		// Keep stdout for the JSON document.
+		spinnerOptions = append(spinnerOptions, pin.WithWriter(os.Stderr))
+	}
+	pDeploy := pin.New("Deploying", spinnerOptions...)
 	cancelDeploy := pDeploy.Start(context.Background())
 	defer cancelDeploy()
 
-	cfg, err := parseConfig("ftl.yaml")
+	forceUnlock, err := cmd.Flags().GetBool("force-unlock")
+	if err != nil {
+		pDeploy.Fail(fmt.Sprintf("Failed to get force-unlock flag: %v", err))
+		return
+	}
+
+	notify, err := cmd.Flags().GetBool("notify")
+	if err != nil {
+		pDeploy.Fail(fmt.Sprintf("Failed to get notify flag: %v", err))
+		return
+	}
+
+	only, err := cmd.Flags().GetStringSlice("only")
+	if err != nil {
+		pDeploy.Fail(fmt.Sprintf("Failed to get only flag: %v", err))
+		return
+	}
+
+	skip, err := cmd.Flags().GetStringSlice("skip")
+	if err != nil {
+		pDeploy.Fail(fmt.Sprintf("Failed to get skip flag: %v", err))
+		return
+	}
+
+	pinImages, err := cmd.Flags().GetBool("pin-digests")
+	if err != nil {
+		pDeploy.Fail(fmt.Sprintf("Failed to get pin-digests flag: %v", err))
+		return
+	}
+
+	allEnvs, err := cmd.Flags().GetBool("all-envs")
+	if err != nil {
+		pDeploy.Fail(fmt.Sprintf("Failed to get all-envs flag: %v", err))
+		return
+	}
+
+	yes, err := cmd.Flags().GetBool("yes")
+	if err != nil {
+		pDeploy.Fail(fmt.Sprintf("Failed to get yes flag: %v", err))
+		return
+	}
+
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		pDeploy.Fail(fmt.Sprintf("Failed to get dry-run flag: %v", err))
+		return
+	}
+	if asJSON && !dryRun {
+		pDeploy.Fail("--json requires --dry-run")
+		return
+	}
+	parallelUploads, err := cmd.Flags().GetInt("parallel-uploads")
+	if err != nil {
+		pDeploy.Fail(fmt.Sprintf("Failed to get parallel-uploads flag: %v", err))
+		return
+	}
+	if parallelUploads < 0 {
+		pDeploy.Fail("--parallel-uploads must be positive")
+		return
+	}
+	bandwidth, err := cmd.Flags().GetString("bandwidth")
+	if err != nil {
+		pDeploy.Fail(fmt.Sprintf("Failed to get bandwidth flag: %v", err))
+		return
+	}
+	if bandwidth != "" {
+		if _, err := config.BandwidthBytes(bandwidth); err != nil {
+			pDeploy.Fail(fmt.Sprintf("Invalid --bandwidth: %v", err))
+			return
+		}
+	}
+
+	opts := deployOptions{
+		revision:    currentRevision(),
+		selection:   append(args, only...),
+		skip:        skip,
+		forceUnlock: forceUnlock,
+		notify:      notify,
+		pinImages:   pinImages,
+		dryRun:      dryRun,
+		json:        asJSON,
+		transfer:    config.Transfer{Parallel: parallelUploads, Bandwidth: bandwidth},
+	}
+
+	if allEnvs {
+		deployPipeline(pDeploy, opts, yes)
+		return
+	}
+
+	cfg, err := parseConfig(configFile)
 	if err != nil {
 		pDeploy.Fail(fmt.Sprintf("Failed to parse config file: %v", err))
 		return
 	}
 
-	if err := deployToServer(cfg.Project.Name, cfg, pDeploy); err != nil {
+	deployConfig(pDeploy, cfg, opts)
+}
+
+// deployOptions are the deploy settings shared by every environment of a
+// pipeline.
+type deployOptions struct {
+	revision    config.Revision
+	selection   []string
+	skip        []string
+	forceUnlock bool
+	notify      bool
+	pinImages   bool
+	dryRun      bool
+	json        bool
+	// transfer overrides the transfer settings of the server where set.
+	transfer config.Transfer
+}
+
+// deployConfig deploys cfg and reports the result on the spinner and to the
+// notification channels. It reports whether the deployment succeeded.
+func deployConfig(pDeploy *pin.Pin, cfg *config.Config, opts deployOptions) bool {
+	overrideTransfer(cfg.Server, opts.transfer)
+
+	services, err := deployment.SelectServices(cfg, opts.selection, opts.skip)
+	if err != nil {
+		pDeploy.Fail(err.Error())
+		return false
+	}
+
+	if opts.dryRun {
+		cfg.SetRevision(opts.revision)
+		if opts.pinImages {
+			if err := ftl.PinDigests(cfg, digestsPath()); err != nil {
+				pDeploy.Fail(err.Error())
+				return false
+			}
+		}
+
+		steps, err := dryRunDeploy(cfg, services, pDeploy)
+		if err != nil {
+			pDeploy.Fail(fmt.Sprintf("Dry run failed: %v", err))
+			return false
+		}
+		pDeploy.Stop(fmt.Sprintf("Dry run of %s on %s completed, nothing was changed", cfg.Project.Name, cfg.Server.Host))
+		if err := printPlan(steps, opts.json); err != nil {
+			console.Error(err)
+			return false
+		}
+		return true
+	}
+
+	console.PushTitle(fmt.Sprintf("ftl: deploying %s", cfg.Project.Name))
+	defer console.PopTitle()
+	console.SetProgress(console.ProgressIndeterminate, 0)
+
+	clientOptions := []ftl.Option{
+		ftl.WithServices(services),
+		ftl.WithRevision(opts.revision),
+		ftl.WithBuildTimings(buildPath(build.TimingsFile)),
+		ftl.WithLockOwner(lockOwner()),
+		ftl.WithEventSink(func(event deployment.Event) {
+			switch event.Type {
+			case deployment.EventStage, deployment.EventProgress:
+				pDeploy.UpdateMessage(event.Message)
+			case deployment.EventWarning:
+				console.Warning(event.Message)
+			}
+		}),
+	}
+	if opts.pinImages {
+		clientOptions = append(clientOptions, ftl.WithDigests(digestsPath()))
+	}
+	if opts.forceUnlock {
+		clientOptions = append(clientOptions, ftl.WithForceUnlock())
+	}
+	client := ftl.New(cfg, clientOptions...)
+
+	started := time.Now()
+	if err := client.Deploy(context.Background()); err != nil {
+		console.SetProgress(console.ProgressError, 100)
 		pDeploy.Fail(fmt.Sprintf("Deployment failed: %v", err))
-		return
+		printPhases(client.Phases(), time.Since(started))
+		notifyDeployResult(opts.notify, fmt.Sprintf("Deployment of %s failed", cfg.Project.Name))
+		console.SetProgress(console.ProgressClear, 0)
+		return false
 	}
 
+	console.SetProgress(console.ProgressClear, 0)
 	pDeploy.Stop("Deployment completed successfully")
+	printPhases(client.Phases(), time.Since(started))
+	notifyDeployResult(opts.notify, fmt.Sprintf("Deployment of %s completed successfully", cfg.Project.Name))
+	return true
 }
 
-func parseConfig(filename string) (*config.Config, error) {
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+// printPhases prints how long the deploy spent in each phase it went through,
+// followed by its total duration.
+func printPhases(phases map[string]float64, total time.Duration) {
+	if len(phases) == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "PHASE\tDURATION")
+	for _, phase := range deployment.Phases {
+		if seconds, ok := phases[phase]; ok {
+			_, _ = fmt.Fprintf(w, "%s\t%s\n", phaseName(phase), phaseDuration(seconds))
+		}
 	}
+	_, _ = fmt.Fprintf(w, "total\t%s\n", phaseDuration(total.Seconds()))
+	_ = w.Flush()
+}
+
+// overrideTransfer replaces the transfer settings of server with those set in
+// override.
+func overrideTransfer(server *config.Server, override config.Transfer) {
+	if server == nil || (override.Parallel == 0 && override.Bandwidth == "") {
+		return
+	}
+	transfer := config.Transfer{}
+	if server.Transfer != nil {
+		transfer = *server.Transfer
+	}
+	if override.Parallel > 0 {
+		transfer.Parallel = override.Parallel
+	}
+	if override.Bandwidth != "" {
+		transfer.Bandwidth = override.Bandwidth
+	}
+	server.Transfer = &transfer
+}
 
-	cfg, err := config.ParseConfig(data)
+// deployPipeline deploys every environment of ftl.yaml in order, asking
+// before the environments that require confirmation unless yes is set. It
+// stops at the first environment that fails.
+func deployPipeline(pDeploy *pin.Pin, opts deployOptions, yes bool) {
+	if environment != "" {
+		pDeploy.Fail("--env and --all-envs cannot be combined")
+		return
+	}
+	if opts.dryRun {
+		pDeploy.Fail("--dry-run and --all-envs cannot be combined, dry-run each environment with --env")
+		return
+	}
+	if configFile == "-" {
+		pDeploy.Fail("--all-envs cannot read the configuration from stdin")
+		return
+	}
+
+	cfg, err := parseConfig(configFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+		pDeploy.Fail(fmt.Sprintf("Failed to parse config file: %v", err))
+		return
+	}
+	names := cfg.EnvironmentNames()
+	if len(names) == 0 {
+		pDeploy.Fail("No environments are defined in " + configFile)
+		return
 	}
+	pDeploy.Stop("Deploying " + strings.Join(names, " → "))
 
-	return cfg, nil
+	for _, name := range names {
+		stage, err := loadConfig(configFile, name)
+		if err != nil {
+			console.Error(fmt.Sprintf("Failed to parse config file for %s: %v", name, err))
+			return
+		}
+
+		target, err := stage.Environment(name)
+		if err != nil {
+			console.Error(err)
+			return
+		}
+		if target.Confirm && !yes {
+			ok, err := confirmEnvironment(name, stage.Server.Host)
+			if err != nil {
+				console.Error(err)
+				return
+			}
+			if !ok {
+				console.Warning("Pipeline stopped before " + name)
+				return
+			}
+		}
+
+		pStage := pin.New("Deploying to "+name, pin.WithSpinnerColor(pin.ColorCyan))
+		cancelStage := pStage.Start(context.Background())
+		ok := deployConfig(pStage, stage, opts)
+		cancelStage()
+		if !ok {
+			return
+		}
+	}
 }
 
-func deployToServer(project string, cfg *config.Config, spinner *pin.Pin) error {
-	server := cfg.Server
-	hostname := server.Host
+// confirmEnvironment asks whether to go on deploying to the environment name.
+// Without a terminal to ask on, the deployment is not confirmed.
+func confirmEnvironment(name, host string) (bool, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, fmt.Errorf("deploying to %s needs confirmation and there is no terminal to ask on, rerun with --yes", name)
+	}
 
-	spinner.UpdateMessage("Connecting to server " + hostname + "...")
-	// Connect to server
-	runner, err := connectToServer(server)
+	console.Input(fmt.Sprintf("Deploy to %s (%s)? [y/N]: ", name, host))
+	answer, err := console.ReadLine()
 	if err != nil {
-		return fmt.Errorf("failed to connect to server %s: %w", hostname, err)
+		return false, err
 	}
-	defer runner.Close()
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes", nil
+}
 
-	spinner.UpdateMessage("Connected to server " + hostname + ". Creating temporary directory for docker sync...")
-	// Create temp directory for docker sync
-	localStore, err := os.MkdirTemp("", "dockersync-local")
+// gitCommit returns the short SHA of the checked out commit, or an empty
+// string outside a git repository.
+func gitCommit() string {
+	output, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output()
 	if err != nil {
-		return fmt.Errorf("failed to create local store: %w", err)
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// imageVars returns the variables images are rendered with, describing the
+// checked out commit. Outside a git repository only the project and the
+// environment are set.
+func imageVars(project, env string) config.ImageVars {
+	vars := config.ImageVars{Project: project, Env: env}
+	revision := currentRevision()
+	if revision.Commit == "" {
+		return vars
 	}
+	vars.GitSHA = revision.Commit
+	vars.Revision = revision.String()
 
-	spinner.UpdateMessage("Temporary directory created. Initializing image syncer and deployment...")
-	// Initialize image syncer and deployment
-	syncer := imagesync.NewImageSync(imagesync.Config{
-		LocalStore:  localStore,
-		MaxParallel: 1,
-	}, runner)
-	deploy := deployment.NewDeployment(runner, syncer)
+	if branch, err := exec.Command("git", "symbolic-ref", "--short", "-q", "HEAD").Output(); err == nil {
+		vars.GitBranch = config.TagValue(strings.TrimSpace(string(branch)))
+	}
+	if tag, err := exec.Command("git", "describe", "--tags", "--exact-match", "HEAD").Output(); err == nil {
+		vars.GitTag = config.TagValue(strings.TrimSpace(string(tag)))
+	}
+	if committed, err := exec.Command("git", "log", "-1", "--format=%ct", "HEAD").Output(); err == nil {
+		if seconds, err := strconv.ParseInt(strings.TrimSpace(string(committed)), 10, 64); err == nil {
+			vars.Timestamp = time.Unix(seconds, 0).UTC().Format("20060102150405")
+		}
+	}
+	return vars
+}
 
-	// Start deployment
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// currentRevision returns the checked out commit and whether the working tree
+// has uncommitted changes.
+func currentRevision() config.Revision {
+	commit := gitCommit()
+	if commit == "" {
+		return config.Revision{}
+	}
+	return config.Revision{Commit: commit, Dirty: gitDirty()}
+}
 
-	spinner.UpdateMessage("Starting deployment process...")
-	if err := deploy.Deploy(ctx, project, cfg, spinner); err != nil {
-		return err
+// notifyDeployResult shows a desktop notification if requested.
+func notifyDeployResult(notify bool, message string) {
+	if !notify {
+		return
+	}
+	if err := console.Notify("FTL", message); err != nil {
+		console.Warning(err)
 	}
+}
 
-	return nil
+func parseConfig(filename string) (*config.Config, error) {
+	return loadConfig(filename, environment)
 }
 
-func connectToServer(server *config.Server) (*remote.Runner, error) {
-	sshKeyPath := filepath.Join(os.Getenv("HOME"), ".ssh", filepath.Base(server.SSHKey))
-	sshClient, _, err := ssh.FindKeyAndConnectWithUser(server.Host, server.Port, server.User, sshKeyPath)
+// loadConfig parses filename with the active profiles, targeting the
+// environment env unless it is empty.
+func loadConfig(filename, env string) (*config.Config, error) {
+	cfg, err := config.ParseConfigFile(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	return remote.NewRunner(sshClient), nil
+	if err := cfg.ApplyProfiles(profiles); err != nil {
+		return nil, err
+	}
+
+	if env != "" {
+		if err := cfg.ApplyEnvironment(env); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.UsesImageVars() {
+		if err := cfg.RenderImages(imageVars(cfg.Project.Name, env)); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Server != nil {
+		ssh.SetIdentityAgent(cfg.Server.IdentityAgent)
+	}
+	ssh.SetConnectPolicy(cfg.ServerTimeouts().Connect.Duration(), cfg.RetryPolicy())
+
+	return cfg, nil
+}
+
+// newImageSyncer creates an image syncer that stages images in a temporary
+// local directory.
+func newImageSyncer(runner *remote.Runner, server *config.Server) (*imagesync.ImageSync, error) {
+	return ftl.NewImageSyncer(runner, server)
+}
+
+// lockOwner describes the current user and machine for the deploy lock.
+func lockOwner() string {
+	return fmt.Sprintf("%s since %s", ftl.CurrentUser(), time.Now().Format(time.RFC3339))
+}
+
+func connectToServer(server *config.Server) (*remote.Runner, error) {
+	return ftl.Connect(server)
 }
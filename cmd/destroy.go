@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yarlson/pin"
+	"golang.org/x/term"
+
+	"github.com/yarlson/ftl/pkg/console"
+	"github.com/yarlson/ftl/pkg/deployment"
+)
+
+var destroyCmd = &cobra.Command{
+	Use:   "destroy",
+	Short: "Remove the project from the server",
+	Long: `Destroy tears the project down on the server: it stops and removes every
+container on the project networks, including those of services no longer in
+ftl.yaml, removes its domains from the shared proxy, its networks, its named
+volumes, its certificates and its folder with the proxy configuration,
+uploads and deploy history.
+
+Destroy lists exactly what it removes and asks to confirm by typing the
+project name. The deploy lock is held from the listing on, so no deploy
+changes the project in between. --keep-volumes keeps the data of the named
+volumes and --keep-certs the certificates, so a later deploy does not
+request them again. Images are left for cleanup.`,
+	Run: runDestroy,
+}
+
+func init() {
+	rootCmd.AddCommand(destroyCmd)
+	destroyCmd.Flags().Bool("keep-volumes", false, "Keep the named volumes of the project")
+	destroyCmd.Flags().Bool("keep-certs", false, "Keep the certificates of the project")
+	destroyCmd.Flags().Bool("yes", false, "Destroy without asking for confirmation")
+	addConfigFlag(destroyCmd)
+	addEnvFlag(destroyCmd)
+}
+
+func runDestroy(cmd *cobra.Command, args []string) {
+	keepVolumes, err := cmd.Flags().GetBool("keep-volumes")
+	if err != nil {
+		console.Error("Failed to get keep-volumes flag:", err)
+		return
+	}
+
+	keepCerts, err := cmd.Flags().GetBool("keep-certs")
+	if err != nil {
+		console.Error("Failed to get keep-certs flag:", err)
+		return
+	}
+
+	yes, err := cmd.Flags().GetBool("yes")
+	if err != nil {
+		console.Error("Failed to get yes flag:", err)
+		return
+	}
+
+	cfg, err := parseConfig(configFile)
+	if err != nil {
+		console.Error("Failed to parse config file:", err)
+		return
+	}
+	project := cfg.Project.Name
+
+	pPlan := pin.New("Looking up "+project+" on "+cfg.Server.Host, pin.WithSpinnerColor(pin.ColorCyan))
+	cancelPlan := pPlan.Start(context.Background())
+	defer cancelPlan()
+
+	runner, err := connectToServer(cfg.Server)
+	if err != nil {
+		pPlan.Fail(fmt.Sprintf("Failed to connect to server %s: %v", cfg.Server.Host, err))
+		return
+	}
+	defer runner.Close()
+
+	deploy := deployment.NewDeployment(runner, nil)
+	ctx := context.Background()
+
+	// The lock is taken before planning, so no deploy adds to what the plan
+	// lists before it is removed.
+	if err := deploy.Lock(ctx, project, lockOwner(), false); err != nil {
+		pPlan.Fail(err.Error())
+		return
+	}
+	release := func() {
+		if err := deploy.ReleaseTeardown(context.Background(), project); err != nil {
+			console.Warning(fmt.Sprintf("Failed to release the deploy lock: %v", err))
+		}
+	}
+
+	teardown, err := deploy.PlanTeardown(ctx, project, cfg, deployment.TeardownOptions{KeepVolumes: keepVolumes, KeepCertificates: keepCerts})
+	if err != nil {
+		release()
+		pPlan.Fail(err.Error())
+		return
+	}
+	if teardown.Empty() {
+		release()
+		pPlan.Stop(fmt.Sprintf("Nothing of %s is on %s", project, cfg.Server.Host))
+		return
+	}
+	pPlan.Stop(fmt.Sprintf("Destroying %s removes from %s:", project, cfg.Server.Host))
+	printTeardown(teardown)
+
+	if !yes {
+		ok, err := confirmDestroy(project)
+		if err != nil {
+			release()
+			console.Error(err)
+			return
+		}
+		if !ok {
+			release()
+			console.Warning("Nothing was removed")
+			return
+		}
+	}
+
+	pDestroy := pin.New("Destroying "+project, pin.WithSpinnerColor(pin.ColorCyan))
+	cancelDestroy := pDestroy.Start(context.Background())
+	defer cancelDestroy()
+
+	// The deploy lock lives in the project folder and goes with it, so it is
+	// only released when destroying fails or the folder is kept.
+	if err := deploy.Destroy(ctx, project, teardown, pDestroy); err != nil {
+		_ = deploy.Unlock(context.Background(), project)
+		pDestroy.Fail(fmt.Sprintf("Destroy failed: %v", err))
+		return
+	}
+	if teardown.Folder == "" {
+		release()
+	}
+
+	pDestroy.Stop(fmt.Sprintf("%s was removed from %s", project, cfg.Server.Host))
+}
+
+// printTeardown lists what destroying removes, and what it keeps.
+func printTeardown(teardown *deployment.Teardown) {
+	if len(teardown.Containers) > 0 {
+		console.Info("Containers: " + strings.Join(teardown.Containers, ", "))
+	}
+	if teardown.Shared {
+		console.Info("Shared proxy: the domains of the project")
+	}
+	if len(teardown.Networks) > 0 {
+		console.Info("Networks: " + strings.Join(teardown.Networks, ", "))
+	}
+	if len(teardown.Volumes) > 0 {
+		console.Info("Volumes and their data: " + strings.Join(teardown.Volumes, ", "))
+	}
+	if teardown.Certificates != "" {
+		console.Info("Certificates: " + teardown.Certificates)
+	}
+	if teardown.Folder != "" {
+		console.Info("Project folder: " + teardown.Folder)
+	}
+	if teardown.Options.KeepVolumes {
+		console.Warning("Named volumes are kept (--keep-volumes)")
+	}
+	if teardown.Options.KeepCertificates {
+		console.Warning("Certificates are kept (--keep-certs)")
+	}
+}
+
+// confirmDestroy asks to type the project name to go on. Without a terminal
+// to ask on, destroying is not confirmed.
+func confirmDestroy(project string) (bool, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, fmt.Errorf("destroying %s needs confirmation and there is no terminal to ask on, rerun with --yes", project)
+	}
+
+	console.Input(fmt.Sprintf("Type %s to confirm: ", project))
+	answer, err := console.ReadLine()
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(answer) == project, nil
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/yarlson/pin"
+
+	"github.com/yarlson/ftl/pkg/build"
+	"github.com/yarlson/ftl/pkg/console"
+	"github.com/yarlson/ftl/pkg/dev"
+)
+
+var devCmd = &cobra.Command{
+	Use:   "dev",
+	Short: "Run your project locally with the production topology",
+	Long: `Dev builds your services and runs them locally in Docker together with
+their dependencies, volumes and the proxy routes from ftl.yaml, served over
+plain HTTP on localhost. Service sources are watched, and a service is
+rebuilt and its container restarted whenever a file changes.
+Press Ctrl+C to stop; containers are removed and volumes are kept.`,
+	Run: runDev,
+}
+
+func init() {
+	rootCmd.AddCommand(devCmd)
+	devCmd.Flags().Int("port", 8080, "Local port the proxy listens on")
+	devCmd.Flags().BoolP("verbose", "v", false, "Stream the full build output")
+	addConfigFlag(devCmd)
+	addProfileFlag(devCmd)
+}
+
+func runDev(cmd *cobra.Command, args []string) {
+	port, err := cmd.Flags().GetInt("port")
+	if err != nil {
+		console.Error("Failed to get port flag:", err)
+		return
+	}
+
+	verbose, err := cmd.Flags().GetBool("verbose")
+	if err != nil {
+		console.Error("Failed to get verbose flag:", err)
+		return
+	}
+
+	cfg, err := parseConfig(configFile)
+	if err != nil {
+		console.Error("Failed to parse config file:", err)
+		return
+	}
+
+	env, err := dev.New(cfg, port)
+	if err != nil {
+		console.Error("Failed to prepare development environment:", err)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	pDev := pin.New("Starting development environment", pin.WithSpinnerColor(pin.ColorCyan))
+	cancelDev := pDev.Start(ctx)
+	defer cancelDev()
+
+	env.Progress = pDev.UpdateMessage
+	progress := map[string]*build.Progress{}
+	env.Output = func(service, line string) {
+		if verbose {
+			console.Print(fmt.Sprintf("[%s] %s", service, line))
+			return
+		}
+		if progress[service] == nil {
+			progress[service] = &build.Progress{}
+		}
+		if status, ok := progress[service].Update(line); ok {
+			pDev.UpdateMessage(fmt.Sprintf("Building %s: %s", service, status))
+		}
+	}
+
+	defer func() {
+		if err := env.Down(context.Background()); err != nil {
+			console.Error("Failed to stop development environment:", err)
+			return
+		}
+		console.Success("Development environment stopped")
+	}()
+
+	if err := env.Up(ctx); err != nil {
+		pDev.Fail(fmt.Sprintf("Failed to start development environment: %v", err))
+		return
+	}
+	pDev.Stop(fmt.Sprintf("Development environment running at %s", env.URL()))
+
+	env.Progress = func(string) {}
+	_ = env.Watch(ctx, func(service string, err error) {
+		if err != nil {
+			console.Error(fmt.Sprintf("Failed to restart %s: %v", service, err))
+			return
+		}
+		console.Success(fmt.Sprintf("Restarted %s", service))
+	})
+}
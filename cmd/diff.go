@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/yarlson/pin"
+
+	"github.com/yarlson/ftl/pkg/console"
+	"github.com/yarlson/ftl/pkg/deployment"
+	"github.com/yarlson/ftl/pkg/ftl"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show differences between the server and ftl.yaml",
+	Long: `Diff inspects the containers, images, environment, volumes and proxy
+configuration running on the server and compares them with ftl.yaml.
+It reports pending changes as well as changes made on the server by hand.`,
+	Run: runDiff,
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	addConfigFlag(diffCmd)
+	addEnvFlag(diffCmd)
+	addProfileFlag(diffCmd)
+}
+
+func runDiff(cmd *cobra.Command, args []string) {
+	pDiff := pin.New("Comparing server state", pin.WithSpinnerColor(pin.ColorCyan))
+	cancelDiff := pDiff.Start(context.Background())
+	defer cancelDiff()
+
+	cfg, err := parseConfig(configFile)
+	if err != nil {
+		pDiff.Fail(fmt.Sprintf("Failed to parse config file: %v", err))
+		return
+	}
+	// Compare against the images ftl deploy runs, pinned to recorded digests.
+	if err := ftl.PinDigests(cfg, digestsPath()); err != nil {
+		pDiff.Fail(err.Error())
+		return
+	}
+
+	runner, err := connectToServer(cfg.Server)
+	if err != nil {
+		pDiff.Fail(fmt.Sprintf("Failed to connect to server %s: %v", cfg.Server.Host, err))
+		return
+	}
+	defer runner.Close()
+
+	drifts, err := deployment.NewDeployment(runner, nil).Diff(context.Background(), cfg.Project.Name, cfg)
+	if err != nil {
+		pDiff.Fail(fmt.Sprintf("Failed to compare server state: %v", err))
+		return
+	}
+
+	if len(drifts) == 0 {
+		pDiff.Stop("Server matches the configuration")
+		return
+	}
+
+	pDiff.Stop(fmt.Sprintf("Found %d difference(s)", len(drifts)))
+	for _, drift := range drifts {
+		console.Warning(drift.String())
+	}
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/yarlson/pin"
+
+	"github.com/yarlson/ftl/pkg/console"
+	"github.com/yarlson/ftl/pkg/doctor"
+	"github.com/yarlson/ftl/pkg/runner/local"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose problems with the local machine, the server and DNS",
+	Long: `Doctor checks everything a deployment depends on and explains how to fix
+what is wrong:
+- Docker is running locally
+- the server is reachable over SSH
+- Docker on the server is usable and recent enough
+- the server has free disk space
+- ports 80 and 443 of the server are reachable
+- the project's domains resolve to the server
+- the domains present valid certificates
+
+It exits with a non-zero status if a check fails.`,
+	Run: runDoctor,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	addConfigFlag(doctorCmd)
+	addEnvFlag(doctorCmd)
+	addProfileFlag(doctorCmd)
+}
+
+func runDoctor(cmd *cobra.Command, args []string) {
+	cfg, err := parseConfig(configFile)
+	if err != nil {
+		console.Error("Failed to parse config file:", err)
+		os.Exit(1)
+	}
+
+	pDoctor := pin.New("Running checks", pin.WithSpinnerColor(pin.ColorCyan))
+	cancelDoctor := pDoctor.Start(context.Background())
+	results := (&doctor.Doctor{
+		Config: cfg,
+		Local:  local.NewRunner(),
+		Connect: func() (doctor.ServerRunner, error) {
+			return connectToServer(cfg.Server)
+		},
+	}).Run(context.Background())
+	pDoctor.Stop("Checks completed")
+	cancelDoctor()
+
+	failed := 0
+	for _, result := range results {
+		line := fmt.Sprintf("%s: %s", result.Check, result.Message)
+		switch result.Status {
+		case doctor.Pass:
+			console.Success(line)
+		case doctor.Warn:
+			console.Warning(line)
+		case doctor.Fail:
+			failed++
+			console.Error(line)
+		case doctor.Skip:
+			console.Info(line + " (skipped)")
+		}
+		if result.Remedy != "" {
+			console.Info("→ " + result.Remedy)
+		}
+	}
+
+	if failed > 0 {
+		console.Error(fmt.Sprintf("%d of %d checks failed", failed, len(results)))
+		os.Exit(1)
+	}
+}
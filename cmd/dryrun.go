@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/yarlson/pin"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/deployment"
+	"github.com/yarlson/ftl/pkg/runner/dryrun"
+)
+
+// dryRunDeploy runs the deployment of services against a runner that records
+// the steps instead of connecting to the server. The server is assumed to have
+// nothing deployed. The deploy lock, the history and local hooks, which need
+// tunnels to the server, are left out.
+func dryRunDeploy(cfg *config.Config, services []string, spinner *pin.Pin) ([]dryrun.Step, error) {
+	for i := range cfg.Services {
+		cfg.Services[i].Hooks = remoteHooks(cfg.Services[i].Hooks)
+	}
+
+	runner := dryrun.NewRunner(cfg.Server.Host)
+	if cfg.Server.DockerHost != "" {
+		runner.SetEnv("DOCKER_HOST", cfg.Server.DockerHost)
+	}
+	// The home directory of the deploy user is assumed to be the usual one,
+	// and new containers report healthy right away.
+	runner.On("sh -c echo $HOME", homeDir(cfg.Server.User))
+	runner.On("docker inspect --format={{.State.Health.Status}}", "healthy")
+	runner.On("docker inspect --format={{.State.Status}}", "running")
+
+	deploy := deployment.NewDeployment(runner, runner)
+	if err := deploy.Deploy(context.Background(), cfg.Project.Name, cfg, spinner, services); err != nil {
+		return runner.Steps(), err
+	}
+
+	return runner.Steps(), nil
+}
+
+// homeDir returns the usual home directory of user on a Linux server.
+func homeDir(user string) string {
+	if user == "root" {
+		return "/root"
+	}
+	return "/home/" + user
+}
+
+// printPlan writes the steps of a dry run to stdout, as a JSON array when
+// asJSON is set.
+func printPlan(steps []dryrun.Step, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if steps == nil {
+			steps = []dryrun.Step{}
+		}
+		return encoder.Encode(steps)
+	}
+
+	for _, step := range steps {
+		fmt.Println(step)
+	}
+	return nil
+}
+
+// remoteHooks returns hooks without their local commands.
+func remoteHooks(hooks *config.Hooks) *config.Hooks {
+	if hooks == nil {
+		return nil
+	}
+	remote := &config.Hooks{}
+	if hooks.Pre != nil && hooks.Pre.Remote != "" {
+		remote.Pre = &config.HookItem{Remote: hooks.Pre.Remote}
+	}
+	if hooks.Post != nil && hooks.Post.Remote != "" {
+		remote.Post = &config.HookItem{Remote: hooks.Post.Remote}
+	}
+	return remote
+}
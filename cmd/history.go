@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yarlson/pin"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/console"
+	"github.com/yarlson/ftl/pkg/deployment"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the deploy history of the project",
+	Long: `History prints the audit log that every deploy appends to on the server:
+when it ran, who ran it, the git commit, the services it covered and its
+result. Use --verbose to also show image digests, the configuration hash
+and errors, or --timings to compare the time each deploy spent building,
+pushing, pulling, health checking and cutting over.`,
+	Run: runHistory,
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().IntP("limit", "n", 20, "Number of deploys to show, 0 for all")
+	historyCmd.Flags().BoolP("verbose", "v", false, "Show images, configuration hash and errors")
+	historyCmd.Flags().Bool("timings", false, "Show the duration of each deploy phase")
+	addConfigFlag(historyCmd)
+	addEnvFlag(historyCmd)
+}
+
+func runHistory(cmd *cobra.Command, args []string) {
+	limit, err := cmd.Flags().GetInt("limit")
+	if err != nil {
+		console.Error("Failed to get limit flag:", err)
+		return
+	}
+
+	verbose, err := cmd.Flags().GetBool("verbose")
+	if err != nil {
+		console.Error("Failed to get verbose flag:", err)
+		return
+	}
+
+	timings, err := cmd.Flags().GetBool("timings")
+	if err != nil {
+		console.Error("Failed to get timings flag:", err)
+		return
+	}
+
+	cfg, err := parseConfig(configFile)
+	if err != nil {
+		console.Error("Failed to parse config file:", err)
+		return
+	}
+
+	pHistory := pin.New("Fetching deploy history", pin.WithSpinnerColor(pin.ColorCyan))
+	cancelHistory := pHistory.Start(context.Background())
+	defer cancelHistory()
+
+	runner, err := connectToServer(cfg.Server)
+	if err != nil {
+		pHistory.Fail(fmt.Sprintf("Failed to connect to server %s: %v", cfg.Server.Host, err))
+		return
+	}
+	defer runner.Close()
+
+	entries, err := deployment.NewDeployment(runner, nil).History(context.Background(), cfg.Project.Name, limit)
+	if err != nil {
+		pHistory.Fail(err.Error())
+		return
+	}
+	pHistory.Stop(fmt.Sprintf("%d deploy(s) of %s", len(entries), cfg.Project.Name))
+
+	if len(entries) == 0 {
+		return
+	}
+
+	if timings {
+		printHistoryTimings(entries)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TIME\tUSER\tCOMMIT\tRESULT\tDURATION\tSERVICES")
+	for _, entry := range entries {
+		commit := entry.Commit
+		if commit == "" {
+			commit = "-"
+		} else if entry.Dirty {
+			commit += "-dirty"
+		}
+		duration := time.Duration(entry.Duration * float64(time.Second)).Round(time.Second)
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			entry.Time.Local().Format("2006-01-02 15:04"), entry.User, commit, historyResult(entry.Result), duration, strings.Join(entry.Services, ", "))
+
+		if verbose {
+			_, _ = fmt.Fprintf(w, "\tconfig %s\n", shortHash(entry.ConfigHash))
+			for _, service := range entry.Services {
+				if image, ok := entry.Images[service]; ok {
+					_, _ = fmt.Fprintf(w, "\t%s %s\n", service, image)
+				}
+			}
+			if entry.Error != "" {
+				_, _ = fmt.Fprintf(w, "\terror: %s\n", strings.ReplaceAll(entry.Error, "\n", " "))
+			}
+		}
+	}
+	_ = w.Flush()
+}
+
+// printHistoryTimings prints the duration of every phase of entries, one
+// deploy per row, so slow phases stand out across deploys.
+func printHistoryTimings(entries []deployment.HistoryEntry) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := []string{"TIME", "COMMIT", "RESULT"}
+	for _, phase := range deployment.Phases {
+		header = append(header, strings.ToUpper(phaseName(phase)))
+	}
+	_, _ = fmt.Fprintln(w, strings.Join(append(header, "TOTAL"), "\t"))
+
+	for _, entry := range entries {
+		commit := entry.Commit
+		if commit == "" {
+			commit = "-"
+		}
+		row := []string{entry.Time.Local().Format("2006-01-02 15:04"), commit, historyResult(entry.Result)}
+		for _, phase := range deployment.Phases {
+			seconds, ok := entry.Phases[phase]
+			if !ok {
+				row = append(row, "-")
+				continue
+			}
+			row = append(row, phaseDuration(seconds))
+		}
+		_, _ = fmt.Fprintln(w, strings.Join(append(row, phaseDuration(entry.Duration)), "\t"))
+	}
+	_ = w.Flush()
+}
+
+// phaseName returns how a deploy phase is shown.
+func phaseName(phase string) string {
+	return strings.ReplaceAll(phase, "_", " ")
+}
+
+// phaseDuration formats seconds spent in a deploy phase, to a tenth of a
+// second below a minute.
+func phaseDuration(seconds float64) string {
+	duration := time.Duration(seconds * float64(time.Second))
+	if duration < time.Minute {
+		return duration.Round(100 * time.Millisecond).String()
+	}
+	return duration.Round(time.Second).String()
+}
+
+func historyResult(result string) string {
+	switch result {
+	case config.EventDeploySucceeded:
+		return "ok"
+	case config.EventDeployRolledBack:
+		return "rolled back"
+	}
+	return result
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/console"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create an ftl.yaml for your project",
+	Long: `Init writes a starter ftl.yaml to the current directory.
+
+In a terminal it asks for the project name, domain, server, services and
+dependencies and writes a configuration for them; --sample writes the sample
+configuration without asking.
+
+With --from-compose, the services of a Docker Compose file are converted
+instead: images and build contexts, ports, named volumes, environment,
+health checks, restart policies and resource limits are carried over.
+Databases, caches and queues become dependencies. Review the generated
+file, in particular the domain and server, before deploying.`,
+	Run: runInit,
+}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+	initCmd.Flags().String("from-compose", "", "Convert a Docker Compose file into ftl.yaml")
+	initCmd.Flags().Bool("force", false, "Overwrite an existing ftl.yaml")
+	initCmd.Flags().Bool("sample", false, "Write the sample configuration without asking")
+	addConfigFlag(initCmd)
+}
+
+func runInit(cmd *cobra.Command, args []string) {
+	composePath, err := cmd.Flags().GetString("from-compose")
+	if err != nil {
+		console.Error("Failed to get from-compose flag:", err)
+		return
+	}
+
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		console.Error("Failed to get force flag:", err)
+		return
+	}
+
+	sample, err := cmd.Flags().GetBool("sample")
+	if err != nil {
+		console.Error("Failed to get sample flag:", err)
+		return
+	}
+
+	if _, err := os.Stat(configFile); err == nil && !force && configFile != "-" {
+		console.Error(configFile + " already exists, use --force to overwrite it")
+		return
+	}
+
+	output := config.Sample
+	switch {
+	case composePath != "":
+		output, err = convertCompose(composePath)
+		if err != nil {
+			console.Error(err)
+			return
+		}
+	case !sample && configFile != "-" && term.IsTerminal(int(os.Stdin.Fd())):
+		output, err = runWizard()
+		if err != nil {
+			console.Error(err)
+			return
+		}
+	}
+
+	if configFile == "-" {
+		_, _ = os.Stdout.Write(output)
+		return
+	}
+
+	if err := os.WriteFile(configFile, output, 0644); err != nil {
+		console.Error("Failed to write "+configFile+":", err)
+		return
+	}
+
+	console.Success("Created " + configFile)
+}
+
+func convertCompose(composePath string) ([]byte, error) {
+	data, err := os.ReadFile(composePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+	project := strings.ToLower(filepath.Base(cwd))
+
+	cfg, warnings, err := config.FromCompose(data, project)
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range warnings {
+		console.Warning(warning)
+	}
+
+	output, err := config.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render ftl.yaml: %w", err)
+	}
+
+	return output, nil
+}
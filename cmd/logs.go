@@ -23,14 +23,18 @@ var logsCmd = &cobra.Command{
 	Long: `Fetch logs from the specified service running on remote server.
 If no service is specified, logs from all services will be fetched.
 Use the -f flag to stream logs in real-time.`,
-	Args: cobra.MaximumNArgs(1),
-	Run:  runLogs,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeContainer,
+	Run:               runLogs,
 }
 
 func init() {
 	rootCmd.AddCommand(logsCmd)
 	logsCmd.Flags().BoolVarP(&follow, "follow", "f", false, "Stream logs in real-time")
 	logsCmd.Flags().IntVarP(&tail, "tail", "n", -1, "Number of lines to show from the end of the logs")
+	addConfigFlag(logsCmd)
+	addEnvFlag(logsCmd)
+	addProfileFlag(logsCmd)
 }
 
 func runLogs(cmd *cobra.Command, args []string) {
@@ -43,7 +47,7 @@ func runLogs(cmd *cobra.Command, args []string) {
 		tail = 100
 	}
 
-	cfg, err := parseConfig("ftl.yaml")
+	cfg, err := parseConfig(configFile)
 	if err != nil {
 		console.Error("Failed to parse config file:", err)
 		return
@@ -62,7 +66,9 @@ func getLogs(cfg *config.Config, serviceName string, follow bool, tail int) erro
 		services = append(services, serviceName)
 	} else {
 		for _, service := range cfg.Services {
-			services = append(services, service.Name)
+			for i := 0; i < service.ReplicaCount(); i++ {
+				services = append(services, service.ReplicaName(i))
+			}
 		}
 	}
 
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/yarlson/pin"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/console"
+	"github.com/yarlson/ftl/pkg/deployment"
+)
+
+var maintenanceCmd = &cobra.Command{
+	Use:   "maintenance",
+	Short: "Serve a maintenance page instead of the services",
+	Long: `Maintenance switches the proxy between the services and a static maintenance
+page, served with status 503 to every client except those in
+maintenance.allow_ips. Containers keep running, so planned work such as a
+database migration can be done behind the page.
+
+The page is maintenance.page of ftl.yaml, or a built-in page.`,
+}
+
+var maintenanceOnCmd = &cobra.Command{
+	Use:   "on",
+	Short: "Turn maintenance mode on",
+	Run:   runMaintenance(true),
+}
+
+var maintenanceOffCmd = &cobra.Command{
+	Use:   "off",
+	Short: "Turn maintenance mode off",
+	Run:   runMaintenance(false),
+}
+
+var maintenanceStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether maintenance mode is on",
+	Run:   runMaintenanceStatus,
+}
+
+func init() {
+	rootCmd.AddCommand(maintenanceCmd)
+	for _, cmd := range []*cobra.Command{maintenanceOnCmd, maintenanceOffCmd, maintenanceStatusCmd} {
+		maintenanceCmd.AddCommand(cmd)
+		addConfigFlag(cmd)
+		addEnvFlag(cmd)
+	}
+}
+
+func runMaintenance(on bool) func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		message := "Turning maintenance mode off"
+		if on {
+			message = "Turning maintenance mode on"
+		}
+		pMaintenance := pin.New(message, pin.WithSpinnerColor(pin.ColorCyan))
+		cancelMaintenance := pMaintenance.Start(context.Background())
+		defer cancelMaintenance()
+
+		cfg, err := parseConfig(configFile)
+		if err != nil {
+			pMaintenance.Fail(fmt.Sprintf("Failed to parse config file: %v", err))
+			return
+		}
+
+		runner, err := connectToServer(cfg.Server)
+		if err != nil {
+			pMaintenance.Fail(fmt.Sprintf("Failed to connect to server %s: %v", cfg.Server.Host, err))
+			return
+		}
+		defer runner.Close()
+
+		deploy := deployment.NewDeployment(runner, nil)
+		if !on {
+			if err := deploy.MaintenanceOff(context.Background(), cfg.Project.Name); err != nil {
+				pMaintenance.Fail(err.Error())
+				return
+			}
+			pMaintenance.Stop("Maintenance mode is off")
+			return
+		}
+
+		if err := deploy.MaintenanceOn(context.Background(), cfg.Project.Name, maintenancePage(cfg)); err != nil {
+			pMaintenance.Fail(err.Error())
+			return
+		}
+		pMaintenance.Stop("Maintenance mode is on")
+	}
+}
+
+func runMaintenanceStatus(cmd *cobra.Command, args []string) {
+	cfg, err := parseConfig(configFile)
+	if err != nil {
+		console.Error("Failed to parse config file:", err)
+		return
+	}
+
+	runner, err := connectToServer(cfg.Server)
+	if err != nil {
+		console.Error(fmt.Sprintf("Failed to connect to server %s:", cfg.Server.Host), err)
+		return
+	}
+	defer runner.Close()
+
+	enabled, err := deployment.NewDeployment(runner, nil).MaintenanceEnabled(context.Background(), cfg.Project.Name)
+	if err != nil {
+		console.Error("Failed to check maintenance mode:", err)
+		return
+	}
+	if enabled {
+		console.Warning("Maintenance mode is on")
+		return
+	}
+	console.Success("Maintenance mode is off")
+}
+
+func maintenancePage(cfg *config.Config) string {
+	if cfg.Maintenance == nil {
+		return ""
+	}
+	return cfg.Maintenance.Page
+}
@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yarlson/pin"
+
+	"github.com/yarlson/ftl/pkg/build"
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/console"
+	"github.com/yarlson/ftl/pkg/runner/local"
+)
+
+var releaseCmd = &cobra.Command{
+	Use:   "release",
+	Short: "Build images once and promote them between environments",
+	Long: `Release builds the images of the checked out commit once, and promotes
+them to environments without rebuilding, so every environment runs exactly
+the same images.`,
+}
+
+var releaseCreateCmd = &cobra.Command{
+	Use:   "create [service...]",
+	Short: "Build and push the images of the current commit",
+	Long: `Create builds the services with a build path, tags their images with the
+short SHA of the checked out commit, pushes them and records their digests in
+.ftl/releases next to ftl.yaml.
+
+The working tree must be clean, so the release matches its commit, unless
+--allow-dirty is set.`,
+	ValidArgsFunction: completeServices,
+	Run:               runReleaseCreate,
+}
+
+var releasePromoteCmd = &cobra.Command{
+	Use:   "promote <sha>",
+	Short: "Deploy the images of a release",
+	Long: `Promote deploys the services of the release of a commit with the images
+recorded by ftl release create, pinned to their digests. Without a local
+record of the release, the digests of the images tagged with the commit are
+looked up in their registries.
+
+Nothing is built. Use --env to pick the environment to promote to.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runReleasePromote,
+}
+
+func init() {
+	rootCmd.AddCommand(releaseCmd)
+	releaseCmd.AddCommand(releaseCreateCmd)
+	releaseCmd.AddCommand(releasePromoteCmd)
+
+	releaseCreateCmd.Flags().Bool("allow-dirty", false, "Release a working tree with uncommitted changes")
+	releaseCreateCmd.Flags().Bool("skip-scan", false, "Skip the vulnerability scan configured in ftl.yaml")
+	addBuilderFlags(releaseCreateCmd)
+	addConfigFlag(releaseCreateCmd)
+
+	releasePromoteCmd.Flags().Bool("force-unlock", false, "Take over the deploy lock left behind by an interrupted deployment")
+	addConfigFlag(releasePromoteCmd)
+	addEnvFlag(releasePromoteCmd)
+	addProfileFlag(releasePromoteCmd)
+}
+
+func runReleaseCreate(cmd *cobra.Command, args []string) {
+	allowDirty, err := cmd.Flags().GetBool("allow-dirty")
+	if err != nil {
+		console.Error("Failed to get allow-dirty flag:", err)
+		return
+	}
+
+	skipScan, err := cmd.Flags().GetBool("skip-scan")
+	if err != nil {
+		console.Error("Failed to get skip-scan flag:", err)
+		return
+	}
+
+	cfg, err := parseConfig(configFile)
+	if err != nil {
+		console.Error("Failed to parse config file:", err)
+		return
+	}
+	if skipScan {
+		cfg.Scan = nil
+	}
+
+	commit := gitCommit()
+	if commit == "" {
+		console.Error("Releases are tagged with the current commit, which needs a git repository")
+		return
+	}
+	if !allowDirty && gitDirty() {
+		console.Error("The working tree has uncommitted changes, commit them or use --allow-dirty")
+		return
+	}
+
+	services, err := releaseServices(cfg, args)
+	if err != nil {
+		console.Error(err)
+		return
+	}
+
+	builder, err := newBuilder(cmd, cfg)
+	if err != nil {
+		console.Error(err)
+		return
+	}
+
+	console.Info(fmt.Sprintf("Building release %s", commit))
+	output, end := startBuildProgress()
+	digests, _, err := buildAndPushServices(context.Background(), cfg.Project.Name, services, builder, false, cfg.Scan, output)
+	end(err)
+	if err != nil {
+		console.Error("Build process failed:", err)
+		return
+	}
+
+	release := &build.Release{Commit: commit, Images: map[string]string{}}
+	for _, service := range services {
+		release.Images[service.Name] = digests[service.Image]
+	}
+	if err := build.SaveRelease(releasesPath(), release); err != nil {
+		console.Error(err)
+		return
+	}
+
+	console.Success(fmt.Sprintf("Release %s created", commit))
+	for _, service := range services {
+		console.Info(fmt.Sprintf("%s: %s", service.Name, release.Images[service.Name]))
+	}
+}
+
+// releaseServices returns the services a release builds, the named ones or
+// every service with a build path, with their images tagged for the release.
+func releaseServices(cfg *config.Config, names []string) ([]config.Service, error) {
+	commit := gitCommit()
+
+	var services []config.Service
+	for _, service := range cfg.Services {
+		if service.Path == "" || (len(names) > 0 && !slices.Contains(names, service.Name)) {
+			continue
+		}
+		if service.Image == "" {
+			return nil, fmt.Errorf("service %s has no image to push the release to", service.Name)
+		}
+		service.Image = build.ReleaseTag(service.Image, commit)
+		services = append(services, service)
+	}
+
+	for _, name := range names {
+		if !slices.ContainsFunc(services, func(s config.Service) bool { return s.Name == name }) {
+			return nil, fmt.Errorf("service %q is not built by ftl", name)
+		}
+	}
+	if len(services) == 0 {
+		return nil, errors.New("no service has a build path to release")
+	}
+
+	return services, nil
+}
+
+func runReleasePromote(cmd *cobra.Command, args []string) {
+	pDeploy := pin.New("Promoting release "+args[0], pin.WithSpinnerColor(pin.ColorCyan))
+	cancelDeploy := pDeploy.Start(context.Background())
+	defer cancelDeploy()
+
+	forceUnlock, err := cmd.Flags().GetBool("force-unlock")
+	if err != nil {
+		pDeploy.Fail(fmt.Sprintf("Failed to get force-unlock flag: %v", err))
+		return
+	}
+
+	cfg, err := parseConfig(configFile)
+	if err != nil {
+		pDeploy.Fail(fmt.Sprintf("Failed to parse config file: %v", err))
+		return
+	}
+
+	release, err := build.LoadRelease(releasesPath(), args[0])
+	if errors.Is(err, os.ErrNotExist) {
+		pDeploy.UpdateMessage("Looking up the images of release " + args[0] + "...")
+		release, err = lookUpRelease(cfg, args[0])
+	}
+	if err != nil {
+		pDeploy.Fail(err.Error())
+		return
+	}
+
+	var services []string
+	for i := range cfg.Services {
+		service := &cfg.Services[i]
+		if image, ok := release.Images[service.Name]; ok {
+			service.PinImage(image)
+			services = append(services, service.Name)
+		}
+	}
+	if len(services) == 0 {
+		pDeploy.Fail(fmt.Sprintf("Release %s has none of the services in %s", args[0], configFile))
+		return
+	}
+
+	deployConfig(pDeploy, cfg, deployOptions{revision: config.Revision{Commit: release.Commit}, selection: services, forceUnlock: forceUnlock})
+}
+
+// lookUpRelease finds the digests of the images tagged with commit in their
+// registries, for a release created elsewhere.
+func lookUpRelease(cfg *config.Config, commit string) (*build.Release, error) {
+	builder := build.NewBuild(local.NewRunner())
+	release := &build.Release{Commit: commit, Images: map[string]string{}}
+	for _, service := range cfg.Services {
+		if service.Path == "" || service.Image == "" {
+			continue
+		}
+		digest, err := builder.RemoteDigest(context.Background(), build.ReleaseTag(service.Image, commit))
+		if err != nil {
+			return nil, fmt.Errorf("release %s of service %s not found: %w", commit, service.Name, err)
+		}
+		release.Images[service.Name] = digest
+	}
+	return release, nil
+}
+
+// releasesPath returns where releases are recorded, next to the configuration
+// file.
+func releasesPath() string {
+	dir := "."
+	if configFile != "-" {
+		dir = filepath.Dir(configFile)
+	}
+	return filepath.Join(dir, build.ReleasesDir)
+}
+
+// gitDirty reports whether the working tree has uncommitted changes, leaving
+// out the files ftl records itself.
+func gitDirty() bool {
+	output, err := exec.Command("git", "status", "--porcelain").Output()
+	if err != nil {
+		return true
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if len(line) < 4 {
+			continue
+		}
+		path := line[3:]
+		if !strings.HasPrefix(path, ".ftl/") && !strings.Contains(path, "/.ftl/") {
+			return true
+		}
+	}
+	return false
+}
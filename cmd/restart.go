@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/yarlson/pin"
+
+	"github.com/yarlson/ftl/pkg/deployment"
+)
+
+var restartCmd = &cobra.Command{
+	Use:   "restart [service...]",
+	Short: "Replace the containers of services without rebuilding",
+	Long: `Restart replaces the containers of the given services, or of all services,
+with new containers started from the images that are already deployed. The
+new containers are health-checked and traffic is switched over one replica
+at a time, as during a deploy. Nothing is built or pushed and hooks and
+migrations do not run.
+
+Use it to apply a changed external secret or environment variable, or to
+clear a memory leak.`,
+	ValidArgsFunction: completeServices,
+	Run:               runRestart,
+}
+
+func init() {
+	rootCmd.AddCommand(restartCmd)
+	addConfigFlag(restartCmd)
+	addEnvFlag(restartCmd)
+	addProfileFlag(restartCmd)
+
+	restartCmd.Flags().Bool("force-unlock", false, "Take over the deploy lock left behind by an interrupted deployment")
+}
+
+func runRestart(cmd *cobra.Command, args []string) {
+	pRestart := pin.New("Restarting", pin.WithSpinnerColor(pin.ColorCyan))
+	cancelRestart := pRestart.Start(context.Background())
+	defer cancelRestart()
+
+	forceUnlock, err := cmd.Flags().GetBool("force-unlock")
+	if err != nil {
+		pRestart.Fail(fmt.Sprintf("Failed to get force-unlock flag: %v", err))
+		return
+	}
+
+	cfg, err := parseConfig(configFile)
+	if err != nil {
+		pRestart.Fail(fmt.Sprintf("Failed to parse config file: %v", err))
+		return
+	}
+
+	services, err := deployment.SelectServices(cfg, args, nil)
+	if err != nil {
+		pRestart.Fail(err.Error())
+		return
+	}
+
+	pRestart.UpdateMessage("Connecting to server " + cfg.Server.Host + "...")
+	runner, err := connectToServer(cfg.Server)
+	if err != nil {
+		pRestart.Fail(fmt.Sprintf("Failed to connect to server %s: %v", cfg.Server.Host, err))
+		return
+	}
+	defer runner.Close()
+
+	deploy := deployment.NewDeployment(runner, nil)
+	ctx := context.Background()
+
+	pRestart.UpdateMessage("Acquiring deploy lock...")
+	if err := deploy.Lock(ctx, cfg.Project.Name, lockOwner(), forceUnlock); err != nil {
+		pRestart.Fail(err.Error())
+		return
+	}
+	defer func() {
+		_ = deploy.Unlock(context.Background(), cfg.Project.Name)
+	}()
+
+	if err := deploy.Restart(ctx, cfg.Project.Name, cfg, pRestart, services); err != nil {
+		pRestart.Fail(fmt.Sprintf("Restart failed: %v", err))
+		return
+	}
+
+	pRestart.Stop("Restart completed successfully")
+}
@@ -1,7 +1,20 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/console"
+	"github.com/yarlson/ftl/pkg/ssh"
+	"github.com/yarlson/ftl/pkg/telemetry"
 )
 
 var rootCmd = &cobra.Command{
@@ -13,9 +26,121 @@ to make deployment easy and reliable, even for developers who aren't experts
 in server management or advanced deployment techniques.
 
 Use 'ftl [command] --help' for more information about a command.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		ssh.SetHostKeys(ssh.NewHostKeys(knownHostsPath(), confirmHostKey))
+		ssh.SetPassphrasePrompt(askPassphrase)
+		config.SetStrictEnv(strictEnv)
+		config.SetStrictFields(!noStrict)
+	},
 }
 
+// strictEnv is set with --strict-env.
+var strictEnv bool
+
+// noStrict is set with --no-strict.
+var noStrict bool
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&strictEnv, "strict-env", false, "Fail when ftl.yaml references an unset environment variable without a default")
+	rootCmd.PersistentFlags().BoolVar(&noStrict, "no-strict", false, "Ignore keys ftl.yaml does not define instead of failing")
+}
+
+// configFile is the configuration selected with --file; "-" reads it from stdin.
+var configFile string
+
+// addConfigFlag registers --file on cmd, with the -f shorthand unless the
+// command already uses it.
+func addConfigFlag(cmd *cobra.Command) {
+	shorthand := "f"
+	if cmd.Flags().ShorthandLookup("f") != nil {
+		shorthand = ""
+	}
+	cmd.Flags().StringVarP(&configFile, "file", shorthand, "ftl.yaml", `Configuration file, or "-" to read it from stdin`)
+}
+
+// profiles are the profiles activated with --profile.
+var profiles []string
+
+// addProfileFlag registers --profile on cmd. The configuration returned by
+// parseConfig then only contains the services and dependencies of the active
+// profiles, and those without profiles.
+func addProfileFlag(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&profiles, "profile", nil, "Activate services and dependencies of this profile")
+}
+
+// environment is the environment selected with --env.
+var environment string
+
+// addEnvFlag registers --env on cmd. The configuration returned by
+// parseConfig then targets that environment of ftl.yaml.
+func addEnvFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&environment, "env", "", "Target this environment of ftl.yaml")
+}
+
+// knownHostsPath returns where the host keys of the project's servers are
+// pinned, next to the configuration file.
+func knownHostsPath() string {
+	dir := "."
+	if configFile != "-" && configFile != "" {
+		dir = filepath.Dir(configFile)
+	}
+	return filepath.Join(dir, ssh.KnownHostsFile)
+}
+
+// confirmHostKey asks whether to trust a server ftl connects to for the first
+// time. Without a terminal to ask on, the key is not trusted.
+func confirmHostKey(host, fingerprint string) (bool, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, fmt.Errorf("%s is not known and there is no terminal to confirm its key %s, run \"ftl server trust\" first", host, fingerprint)
+	}
+
+	fmt.Println()
+	console.Warning(fmt.Sprintf("The authenticity of %s can't be established.", host))
+	console.Info("Host key fingerprint is " + fingerprint)
+	console.Input("Trust this host and pin its key in " + knownHostsPath() + "? [y/N]: ")
+	answer, err := console.ReadLine()
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes", nil
+}
+
+// askPassphrase asks for the passphrase of an encrypted SSH key that is not
+// in the ssh-agent.
+func askPassphrase(path string) ([]byte, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("%s is encrypted and there is no terminal to enter its passphrase, add it to ssh-agent with ssh-add %s", path, path)
+	}
+
+	fmt.Println()
+	console.Input("Enter passphrase for " + path + ": ")
+	passphrase, err := console.ReadPassword()
+	fmt.Println()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(passphrase), nil
+}
+
+// traceFlushTimeout bounds how long exiting waits for spans to be exported.
+const traceFlushTimeout = 5 * time.Second
+
 // Execute adds all child commands to the root command and sets flags appropriately.
+// Traces are exported over OTLP when an endpoint is configured.
 func Execute() error {
+	shutdown, err := telemetry.Setup(context.Background(), version)
+	if err != nil {
+		console.Warning(fmt.Sprintf("Tracing is disabled: %v", err))
+	} else {
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), traceFlushTimeout)
+			defer cancel()
+			if err := shutdown(ctx); err != nil {
+				console.Warning(fmt.Sprintf("Failed to export traces: %v", err))
+			}
+		}()
+	}
+
 	return rootCmd.Execute()
 }
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yarlson/pin"
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/yarlson/ftl/pkg/cloud"
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/console"
+	"github.com/yarlson/ftl/pkg/ssh"
+)
+
+var serverCmd = &cobra.Command{
+	Use:   "server",
+	Short: "Manage the deploy server",
+}
+
+var serverCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a server with a cloud provider and set it up",
+	Long: `Create starts a new server with a cloud provider, allows the SSH key of
+ftl.yaml to log in as root, runs the same setup as ftl setup and writes the
+address of the server to server.host in ftl.yaml.
+
+Providers and their credentials:
+  hetzner        HCLOUD_TOKEN
+  digitalocean   DIGITALOCEAN_TOKEN
+  aws            the configuration of the aws command`,
+	Run: runServerCreate,
+}
+
+var serverTrustCmd = &cobra.Command{
+	Use:   "trust",
+	Short: "Pin the current host key of the server",
+	Long: `Trust connects to the server of ftl.yaml, shows the fingerprint of its host
+key and pins it in .ftl/known_hosts next to ftl.yaml, replacing the key pinned
+before. Run it after rebuilding or reinstalling the server on purpose, or to
+pin the key of a server before deploying to it from CI.`,
+	Run: runServerTrust,
+}
+
+func init() {
+	rootCmd.AddCommand(serverCmd)
+	serverCmd.AddCommand(serverCreateCmd)
+	serverCmd.AddCommand(serverTrustCmd)
+	addConfigFlag(serverTrustCmd)
+	addEnvFlag(serverTrustCmd)
+	addConfigFlag(serverCreateCmd)
+	addEnvFlag(serverCreateCmd)
+
+	serverCreateCmd.Flags().String("provider", "", "Cloud provider: "+strings.Join(cloud.Providers(), ", "))
+	serverCreateCmd.Flags().String("name", "", "Server name (default: project name)")
+	serverCreateCmd.Flags().String("region", "", "Provider region or location (default: the provider's default)")
+	serverCreateCmd.Flags().String("size", "", "Provider server type or size (default: the provider's smallest suitable size)")
+	serverCreateCmd.Flags().String("image", "", "Provider image (default: Ubuntu 24.04)")
+	_ = serverCreateCmd.MarkFlagRequired("provider")
+}
+
+func runServerCreate(cmd *cobra.Command, args []string) {
+	providerName, _ := cmd.Flags().GetString("provider")
+	name, _ := cmd.Flags().GetString("name")
+	region, _ := cmd.Flags().GetString("region")
+	size, _ := cmd.Flags().GetString("size")
+	image, _ := cmd.Flags().GetString("image")
+
+	cfg, err := parseConfig(configFile)
+	if err != nil {
+		console.Error("Failed to parse config file:", err)
+		return
+	}
+	if name == "" {
+		name = cfg.Project.Name
+	}
+
+	provider, err := cloud.New(providerName)
+	if err != nil {
+		console.Error("Failed to configure provider:", err)
+		return
+	}
+
+	sshKey, err := ssh.AuthorizedKey(cfg.Server.SSHKey)
+	if err != nil {
+		console.Error("Failed to read SSH key:", err)
+		return
+	}
+
+	pCreate := pin.New(fmt.Sprintf("Creating server %s with %s", name, providerName), pin.WithSpinnerColor(pin.ColorCyan))
+	cancelCreate := pCreate.Start(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	created, err := provider.Create(ctx, cloud.Spec{
+		Name:   name,
+		Region: region,
+		Size:   size,
+		Image:  image,
+		SSHKey: sshKey,
+	})
+	if err != nil {
+		pCreate.Fail(fmt.Sprintf("Failed to create server: %v", err))
+		cancelCreate()
+		return
+	}
+
+	pCreate.UpdateMessage(fmt.Sprintf("Waiting for SSH on %s", created.IP))
+	if err := cloud.WaitForSSH(ctx, created.IP, cfg.Server.Port); err != nil {
+		pCreate.Fail(fmt.Sprintf("Server %s was created but is not reachable: %v", created.ID, err))
+		cancelCreate()
+		return
+	}
+	pCreate.Stop(fmt.Sprintf("Server %s created at %s", created.ID, created.IP))
+	cancelCreate()
+
+	if err := trustHostKey(created.IP, cfg.Server.Port, nil); err != nil {
+		console.Error("Failed to pin host key:", err)
+		return
+	}
+
+	cfg.Server.Host = created.IP
+	if configFile == "-" {
+		console.Warning("Configuration was read from stdin; set server.host to " + created.IP + " in your configuration")
+	} else if err := config.SetServerHost(configFile, created.IP); err != nil {
+		console.Error("Failed to write server host:", err)
+		return
+	} else {
+		console.Info("Wrote server.host " + created.IP + " to " + configFile)
+	}
+
+	if err := setupServer(cfg); err != nil {
+		console.Info("Run ftl setup to retry the setup of the server")
+		return
+	}
+
+	console.Success("Server created and set up successfully.")
+}
+
+func runServerTrust(cmd *cobra.Command, args []string) {
+	cfg, err := parseConfig(configFile)
+	if err != nil {
+		console.Error("Failed to parse config file:", err)
+		return
+	}
+
+	if err := trustHostKey(cfg.Server.Host, cfg.Server.Port, (*ssh.Jump)(cfg.Server.ProxyJump)); err != nil {
+		console.Error("Failed to pin host key:", err)
+		return
+	}
+}
+
+// trustHostKey pins the key the server at host presents, whatever was pinned
+// for it before.
+func trustHostKey(host string, port int, jump *ssh.Jump) error {
+	key, err := ssh.FetchHostKey(host, port, jump)
+	if err != nil {
+		return err
+	}
+
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	if err := ssh.NewHostKeys(knownHostsPath(), nil).Trust(address, key); err != nil {
+		return err
+	}
+	console.Success(fmt.Sprintf("Pinned host key %s of %s in %s", gossh.FingerprintSHA256(key), host, knownHostsPath()))
+	return nil
+}
@@ -18,19 +18,33 @@ var setupCmd = &cobra.Command{
 	Use:   "setup",
 	Short: "Prepare server for deployment",
 	Long: `Setup configures server defined in ftl.yaml for deployment.
-Run this once for each new server before deploying your application.`,
+Run this once for each new server before deploying your application.
+
+For a fresh server that only accepts the root password, use --password: ftl
+logs in as root with the password once to authorize the SSH key, sets the
+server up, turns password logins off once the deploy user can log in with the
+key, and writes server.user and server.ssh_key to ftl.yaml.`,
 	Run: runSetup,
 }
 
 func init() {
 	rootCmd.AddCommand(setupCmd)
+	setupCmd.Flags().Bool("password", false, "Log in as root with a password once to authorize the SSH key, then turn password logins off")
+	addConfigFlag(setupCmd)
+	addEnvFlag(setupCmd)
 }
 
 func runSetup(cmd *cobra.Command, args []string) {
+	password, err := cmd.Flags().GetBool("password")
+	if err != nil {
+		console.Error("Failed to get password flag:", err)
+		return
+	}
+
 	pConfig := pin.New("Parsing configuration", pin.WithSpinnerColor(pin.ColorCyan))
 	pConfig.Start(context.Background())
 	cancelConfig := pConfig.Start(context.Background())
-	cfg, err := parseConfig("ftl.yaml")
+	cfg, err := parseConfig(configFile)
 	if err != nil {
 		pConfig.Fail(fmt.Sprintf("Failed to parse config file: %v", err))
 		cancelConfig()
@@ -39,6 +53,85 @@ func runSetup(cmd *cobra.Command, args []string) {
 	pConfig.Stop("Configuration parsed")
 	cancelConfig()
 
+	if password {
+		if err := bootstrapServer(cfg.Server); err != nil {
+			return
+		}
+	}
+
+	if err := setupServer(cfg); err != nil {
+		return
+	}
+
+	if password {
+		if err := lockDownServer(cfg.Server); err != nil {
+			return
+		}
+	}
+
+	console.Success("Server setup completed successfully.")
+}
+
+// bootstrapServer asks for the root password and authorizes the SSH key for
+// root with it, so the setup can log in with the key.
+func bootstrapServer(target *config.Server) error {
+	console.Input("Enter root password for " + target.Host + ":")
+	rootPassword, err := console.ReadPassword()
+	fmt.Println()
+	if err != nil {
+		console.Error("Failed to read root password:", err)
+		return err
+	}
+
+	pBootstrap := pin.New("Authorizing SSH key for root", pin.WithSpinnerColor(pin.ColorCyan))
+	cancelBootstrap := pBootstrap.Start(context.Background())
+	defer cancelBootstrap()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := server.Bootstrap(ctx, target, rootPassword); err != nil {
+		pBootstrap.Fail(err.Error())
+		return err
+	}
+	pBootstrap.Stop("SSH key authorized for root")
+	return nil
+}
+
+// lockDownServer turns password logins off and records the user and key the
+// server now accepts in ftl.yaml.
+func lockDownServer(target *config.Server) error {
+	pLock := pin.New("Disabling password login", pin.WithSpinnerColor(pin.ColorCyan))
+	cancelLock := pLock.Start(context.Background())
+	defer cancelLock()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := server.DisablePasswordLogin(ctx, target); err != nil {
+		pLock.Fail(err.Error())
+		return err
+	}
+	pLock.Stop("Password login disabled")
+
+	values := map[string]string{"user": target.User}
+	settings := "server.user"
+	if target.SSHKey != "" {
+		values["ssh_key"] = target.SSHKey
+		settings += " and server.ssh_key"
+	}
+	if configFile == "-" || environment != "" {
+		console.Info(fmt.Sprintf("Set %s to %s and the SSH key in the configuration", settings, target.User))
+		return nil
+	}
+	if err := config.SetServerValues(configFile, values); err != nil {
+		console.Warning("Failed to update "+configFile+":", err)
+		return nil
+	}
+	console.Info("Wrote " + settings + " to " + configFile)
+	return nil
+}
+
+// setupServer asks for the Docker Hub credentials and the password of the
+// deploy user, and prepares the server of cfg. Failures are reported as they
+// happen; the returned error only tells the caller to stop.
+func setupServer(cfg *config.Config) error {
 	pDocker := pin.New("Checking Docker credentials", pin.WithSpinnerColor(pin.ColorCyan))
 	pDocker.Start(context.Background())
 	cancelDocker := pDocker.Start(context.Background())
@@ -46,7 +139,7 @@ func runSetup(cmd *cobra.Command, args []string) {
 	if err != nil {
 		pDocker.Fail(fmt.Sprintf("Failed to get Docker credentials: %v", err))
 		cancelDocker()
-		return
+		return err
 	}
 	pDocker.Stop("Docker credentials obtained")
 	cancelDocker()
@@ -54,13 +147,14 @@ func runSetup(cmd *cobra.Command, args []string) {
 	newUserPassword, err := getUserPassword()
 	if err != nil {
 		console.Error("Failed to read password:", err)
-		return
+		return err
 	}
 	console.Success("Password set successfully")
 
 	pSetup := pin.New("Setting up server", pin.WithSpinnerColor(pin.ColorCyan))
 	pSetup.Start(context.Background())
 	cancelSetup := pSetup.Start(context.Background())
+	defer cancelSetup()
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
 	if err := server.Setup(ctx, cfg, server.DockerCredentials{
@@ -68,13 +162,11 @@ func runSetup(cmd *cobra.Command, args []string) {
 		Password: dockerCreds.Password,
 	}, newUserPassword, pSetup); err != nil {
 		pSetup.Fail(fmt.Sprintf("Setup failed: %v", err))
-		cancelSetup()
-		return
+		return err
 	}
 	pSetup.Stop("Server setup completed successfully")
-	cancelSetup()
 
-	console.Success("Server setup completed successfully.")
+	return nil
 }
 
 func getDockerCredentials(services []config.Service) (server.DockerCredentials, error) {
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yarlson/pin"
+
+	"github.com/yarlson/ftl/pkg/console"
+	"github.com/yarlson/ftl/pkg/deployment"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the deployed commit of every service, the proxy and the certificate expiry of every domain",
+	Long: `Status shows the git commit the running container of every service was
+deployed from, marked -dirty when the working tree had uncommitted changes.
+
+It checks that the proxy is running and accepts its configuration, and
+starts it again when it is down. A proxy that stays unhealthy is reported
+with the end of its log.
+
+It then reads the certificate the proxy serves for every domain of the
+project and shows the number of days until it expires. Certificates that
+expire sooner than the tls.expiry_alert threshold (14 days by default) are
+reported as warnings: they are renewed 30 days before expiry, so a
+certificate that gets this close means its renewal is failing.`,
+	Run: runStatus,
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	addConfigFlag(statusCmd)
+	addEnvFlag(statusCmd)
+	addProfileFlag(statusCmd)
+}
+
+func runStatus(cmd *cobra.Command, args []string) {
+	cfg, err := parseConfig(configFile)
+	if err != nil {
+		console.Error("Failed to parse config file:", err)
+		return
+	}
+
+	pStatus := pin.New("Connecting to server "+cfg.Server.Host+"...", pin.WithSpinnerColor(pin.ColorCyan))
+	cancelStatus := pStatus.Start(context.Background())
+	defer cancelStatus()
+
+	runner, err := connectToServer(cfg.Server)
+	if err != nil {
+		pStatus.Fail(fmt.Sprintf("Failed to connect to server %s: %v", cfg.Server.Host, err))
+		return
+	}
+	defer runner.Close()
+
+	deploy := deployment.NewDeployment(runner, nil)
+
+	pStatus.UpdateMessage("Reading deployed commits...")
+	revisions := deploy.Revisions(context.Background(), cfg.Project.Name, cfg)
+
+	pStatus.UpdateMessage("Checking proxy...")
+	restarted, proxyErr := deploy.HealProxy(context.Background(), cfg.Project.Name)
+
+	pStatus.UpdateMessage("Reading certificates...")
+	certificates := deploy.Certificates(context.Background(), cfg.Project.Name, cfg.Domains())
+	pStatus.Stop("Status of " + cfg.Project.Name)
+
+	for _, service := range cfg.Services {
+		if service.Static != nil {
+			continue
+		}
+		revision, ok := revisions[service.Name]
+		if !ok {
+			revision = "unknown commit"
+		}
+		console.Info(fmt.Sprintf("%s: %s", service.Name, revision))
+	}
+
+	switch {
+	case proxyErr != nil:
+		console.Error("proxy:", proxyErr)
+	case restarted:
+		console.Warning("proxy: was not running, started it again")
+	default:
+		console.Success("proxy: running")
+	}
+
+	threshold := cfg.TLS.AlertDays()
+	now := time.Now()
+	for _, certificate := range certificates {
+		if certificate.Err != nil {
+			console.Error(certificate.Domain+":", certificate.Err)
+			continue
+		}
+
+		days := certificate.DaysLeft(now)
+		message := fmt.Sprintf("%s: expires in %d days (%s)", certificate.Domain, days, certificate.NotAfter.Format(time.DateOnly))
+		switch {
+		case days < 0:
+			console.Error(fmt.Sprintf("%s: expired on %s", certificate.Domain, certificate.NotAfter.Format(time.DateOnly)))
+		case days < threshold:
+			console.Warning(message)
+		default:
+			console.Success(message)
+		}
+	}
+}
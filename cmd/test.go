@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yarlson/pin"
+
+	"github.com/yarlson/ftl/pkg/console"
+	"github.com/yarlson/ftl/pkg/deployment"
+)
+
+var testCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Run integration tests against a sandboxed copy of your project",
+	Long: `Test deploys your dependencies and services to an isolated sandbox
+on the server, named after the project with a "-test" suffix, and runs
+the test containers defined under "tests" in ftl.yaml against it.
+Services are reachable from the tests by name. The sandbox is removed
+when the tests finish, and the command exits non-zero if any test fails.`,
+	Run: runTest,
+}
+
+func init() {
+	rootCmd.AddCommand(testCmd)
+	testCmd.Flags().Bool("keep", false, "Keep the sandbox running after the tests for debugging")
+	addConfigFlag(testCmd)
+}
+
+func runTest(cmd *cobra.Command, args []string) {
+	pTest := pin.New("Testing", pin.WithSpinnerColor(pin.ColorCyan))
+	cancelTest := pTest.Start(context.Background())
+	defer cancelTest()
+
+	keep, err := cmd.Flags().GetBool("keep")
+	if err != nil {
+		pTest.Fail(fmt.Sprintf("Failed to get keep flag: %v", err))
+		return
+	}
+
+	cfg, err := parseConfig(configFile)
+	if err != nil {
+		pTest.Fail(fmt.Sprintf("Failed to parse config file: %v", err))
+		return
+	}
+
+	if len(cfg.Tests) == 0 {
+		pTest.Fail("No tests defined in ftl.yaml")
+		return
+	}
+
+	runner, err := connectToServer(cfg.Server)
+	if err != nil {
+		pTest.Fail(fmt.Sprintf("Failed to connect to server %s: %v", cfg.Server.Host, err))
+		return
+	}
+	defer runner.Close()
+
+	syncer, err := newImageSyncer(runner, cfg.Server)
+	if err != nil {
+		pTest.Fail(err.Error())
+		return
+	}
+
+	results, err := deployment.NewDeployment(runner, syncer).Test(context.Background(), cfg.Project.Name, cfg, pTest, keep)
+	if err != nil && len(results) == 0 {
+		pTest.Fail(fmt.Sprintf("Test run failed: %v", err))
+		os.Exit(1)
+	}
+
+	failed := 0
+	for _, result := range results {
+		if !result.Passed {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		pTest.Fail(fmt.Sprintf("%d of %d tests failed", failed, len(results)))
+	} else {
+		pTest.Stop(fmt.Sprintf("All %d tests passed", len(results)))
+	}
+
+	for _, result := range results {
+		if result.Passed {
+			console.Success(fmt.Sprintf("%s (%s)", result.Name, result.Duration.Round(time.Millisecond)))
+			continue
+		}
+		console.Error(fmt.Sprintf("%s (%s)", result.Name, result.Duration.Round(time.Millisecond)))
+		console.Print("\x1b[90m" + result.Output + "\x1b[0m")
+	}
+
+	if keep {
+		console.Info(fmt.Sprintf("Sandbox %s is still running", deployment.SandboxName(cfg.Project.Name)))
+	}
+
+	if err != nil {
+		console.Error(fmt.Sprintf("Test run failed: %v", err))
+		os.Exit(1)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/console"
+	"github.com/yarlson/ftl/pkg/deployment"
+)
+
+var topCmd = &cobra.Command{
+	Use:     "top",
+	Aliases: []string{"ps"},
+	Short:   "Show the CPU, memory, network and disk I/O of the project containers",
+	Long: `Top shows the resource usage docker stats reports for every container on
+the networks of the project: CPU in percent of one core, memory against its
+limit, and the network and block I/O since the container started.
+
+In a terminal the table is refreshed every --interval until interrupted.
+Otherwise, or with --once, it is printed once.`,
+	Run: runTop,
+}
+
+func init() {
+	rootCmd.AddCommand(topCmd)
+	topCmd.Flags().Duration("interval", 2*time.Second, "Time between refreshes")
+	topCmd.Flags().Bool("once", false, "Print the table once instead of refreshing it")
+	topCmd.Flags().String("sort", "cpu", "Sort containers by cpu, memory or name")
+	addConfigFlag(topCmd)
+	addEnvFlag(topCmd)
+}
+
+func runTop(cmd *cobra.Command, args []string) {
+	interval, err := cmd.Flags().GetDuration("interval")
+	if err != nil {
+		console.Error("Failed to get interval flag:", err)
+		return
+	}
+	once, err := cmd.Flags().GetBool("once")
+	if err != nil {
+		console.Error("Failed to get once flag:", err)
+		return
+	}
+	by, err := cmd.Flags().GetString("sort")
+	if err != nil {
+		console.Error("Failed to get sort flag:", err)
+		return
+	}
+	if by != "cpu" && by != "memory" && by != "name" {
+		console.Error(fmt.Sprintf("Invalid --sort %q: use cpu, memory or name", by))
+		return
+	}
+
+	cfg, err := parseConfig(configFile)
+	if err != nil {
+		console.Error("Failed to parse config file:", err)
+		return
+	}
+
+	runner, err := connectToServer(cfg.Server)
+	if err != nil {
+		console.Error(fmt.Sprintf("Failed to connect to server %s:", cfg.Server.Host), err)
+		return
+	}
+	defer runner.Close()
+
+	deploy := deployment.NewDeployment(runner, nil)
+	live := !once && term.IsTerminal(int(os.Stdout.Fd()))
+	if !live {
+		if err := printTop(context.Background(), deploy, cfg, by, os.Stdout); err != nil {
+			console.Error("Failed to read container stats:", err)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// Clear the screen and move the cursor home before every refresh.
+		fmt.Print("\033[H\033[2J")
+		fmt.Printf("%s on %s, every %s (Ctrl-C to quit)\n\n", cfg.Project.Name, cfg.Server.Host, interval)
+		if err := printTop(ctx, deploy, cfg, by, os.Stdout); err != nil && ctx.Err() == nil {
+			console.Error("Failed to read container stats:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// printTop writes the resource usage of the project containers to out as a
+// table, sorted by by.
+func printTop(ctx context.Context, deploy *deployment.Deployment, cfg *config.Config, by string, out io.Writer) error {
+	stats, err := deploy.Stats(ctx, cfg.Project.Name, cfg)
+	if err != nil {
+		return err
+	}
+	sortStats(stats, by)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "CONTAINER\tCPU\tMEMORY\tMEM %\tNET I/O\tBLOCK I/O\tPIDS")
+	for _, s := range stats {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, s.CPU, s.Memory, s.MemoryPerc, s.NetIO, s.BlockIO, s.PIDs)
+	}
+	return w.Flush()
+}
+
+// sortStats orders stats by name, or by descending CPU or memory usage.
+func sortStats(stats []deployment.ContainerStats, by string) {
+	sort.SliceStable(stats, func(i, j int) bool {
+		switch by {
+		case "cpu":
+			return stats[i].CPUPercent() > stats[j].CPUPercent()
+		case "memory":
+			return stats[i].MemoryPercent() > stats[j].MemoryPercent()
+		}
+		return stats[i].Name < stats[j].Name
+	})
+}
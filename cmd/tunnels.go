@@ -2,9 +2,11 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/yarlson/pin"
 
 	"github.com/yarlson/ftl/pkg/console"
+	"github.com/yarlson/ftl/pkg/ssh"
 	"github.com/yarlson/ftl/pkg/tunnel"
 )
 
@@ -19,27 +22,104 @@ var tunnelsCmd = &cobra.Command{
 	Use:   "tunnels",
 	Short: "Create SSH tunnels for dependencies",
 	Long: `Create SSH tunnels for all dependencies defined in ftl.yaml,
-forwarding local ports to remote ports.`,
+forwarding local ports to remote ports.
+
+Use --reverse to let the server reach a port on this machine instead, for
+example a local webhook receiver. The format follows ssh -R:
+[bind_address:]remote_port:[local_host:]local_port. The server listens on
+localhost by default; other bind addresses, such as the Docker bridge gateway
+that containers can reach, require GatewayPorts clientspecified in sshd_config.
+
+Use --socks to open a SOCKS5 proxy on this machine whose connections are made
+from the server, like ssh -D, for example to browse internal dashboards.
+
+With --auto-ports the dependency tunnels listen on free local ports instead of
+the dependency ports, so they never clash with local services. --json prints
+the ports that were chosen as a JSON object once the tunnels are up.`,
+	Example: `  ftl tunnels
+  ftl tunnels --reverse 9000:3000
+  ftl tunnels -R 172.17.0.1:5432:localhost:5432
+  ftl tunnels --socks
+  ftl tunnels --auto-ports --json`,
 	Run: runTunnels,
 }
 
+// tunnelsOutput is what --json prints once the tunnels are established.
+type tunnelsOutput struct {
+	Tunnels []tunnelOutput        `json:"tunnels"`
+	Reverse []reverseTunnelOutput `json:"reverse,omitempty"`
+	SOCKS   string                `json:"socks,omitempty"`
+}
+
+type tunnelOutput struct {
+	LocalPort int    `json:"local_port"`
+	Remote    string `json:"remote"`
+}
+
+type reverseTunnelOutput struct {
+	Remote string `json:"remote"`
+	Local  string `json:"local"`
+}
+
 func init() {
 	rootCmd.AddCommand(tunnelsCmd)
+	addConfigFlag(tunnelsCmd)
+	addEnvFlag(tunnelsCmd)
+
+	tunnelsCmd.Flags().StringSliceP("reverse", "R", nil, "Forward a server port to this machine ([bind_address:]remote_port:[local_host:]local_port)")
+	tunnelsCmd.Flags().String("socks", "", "Open a SOCKS5 proxy through the server on this local port, 1080 when given without a value, 0 for a free port")
+	tunnelsCmd.Flags().Lookup("socks").NoOptDefVal = "1080"
+	tunnelsCmd.Flags().Bool("auto-ports", false, "Listen on free local ports instead of the dependency ports")
+	tunnelsCmd.Flags().Bool("json", false, "Print the established tunnels and their local ports as JSON")
 }
 
 func runTunnels(cmd *cobra.Command, args []string) {
-	pTunnel := pin.New("Establishing SSH tunnels", pin.WithSpinnerColor(pin.ColorCyan), pin.WithTextColor(pin.ColorYellow))
+	asJSON, _ := cmd.Flags().GetBool("json")
+	autoPorts, _ := cmd.Flags().GetBool("auto-ports")
+	socksPort, _ := cmd.Flags().GetString("socks")
+
+	// With --json, stdout only carries the JSON object.
+	spinnerOptions := []pin.Option{pin.WithSpinnerColor(pin.ColorCyan), pin.WithTextColor(pin.ColorYellow)}
+	if asJSON {
+		spinnerOptions = append(spinnerOptions, pin.WithWriter(os.Stderr))
+	}
+	pTunnel := pin.New("Establishing SSH tunnels", spinnerOptions...)
 	cancelTunnel := pTunnel.Start(context.Background())
 
-	cfg, err := parseConfig("ftl.yaml")
+	cfg, err := parseConfig(configFile)
 	if err != nil {
 		pTunnel.Fail(fmt.Sprintf("Failed to parse config file: %v", err))
 		cancelTunnel()
 		return
 	}
 
+	reverseSpecs, _ := cmd.Flags().GetStringSlice("reverse")
+	var reverse []tunnel.ReverseConfig
+	for _, spec := range reverseSpecs {
+		rt, err := tunnel.ParseReverse(spec)
+		if err != nil {
+			pTunnel.Fail(err.Error())
+			cancelTunnel()
+			return
+		}
+		reverse = append(reverse, rt)
+	}
+
+	if socksPort != "" {
+		if n, err := strconv.Atoi(socksPort); err != nil || n < 0 || n > 65535 {
+			pTunnel.Fail(fmt.Sprintf("Invalid --socks port %q", socksPort))
+			cancelTunnel()
+			return
+		}
+	}
+
 	tunnels := tunnel.CollectDependencyTunnels(cfg)
-	if len(tunnels) == 0 {
+	if autoPorts {
+		for i := range tunnels {
+			tunnels[i].LocalPort = "0"
+		}
+	}
+	if len(tunnels) == 0 && len(reverse) == 0 && socksPort == "" {
 		pTunnel.Fail("No dependencies with ports found in the configuration.")
 		cancelTunnel()
 		return
@@ -49,29 +129,91 @@ func runTunnels(cmd *cobra.Command, args []string) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	err = tunnel.StartTunnels(
-		ctx,
-		cfg.Server.Host, cfg.Server.Port,
-		cfg.Server.User, cfg.Server.SSHKey,
-		tunnels,
-	)
-	if err != nil {
-		pTunnel.Fail(fmt.Sprintf("Failed to establish tunnels: %v", err))
-		cancelTunnel()
-		return
+	if len(tunnels) > 0 {
+		tunnels, err = tunnel.StartTunnels(
+			ctx,
+			cfg.Server.Host, cfg.Server.Port,
+			cfg.Server.User, cfg.Server.SSHKey,
+			(*ssh.Jump)(cfg.Server.ProxyJump),
+			tunnels,
+		)
+		if err != nil {
+			pTunnel.Fail(fmt.Sprintf("Failed to establish tunnels: %v", err))
+			cancelTunnel()
+			return
+		}
+	}
+
+	if len(reverse) > 0 {
+		err = tunnel.StartReverseTunnels(
+			ctx,
+			cfg.Server.Host, cfg.Server.Port,
+			cfg.Server.User, cfg.Server.SSHKey,
+			(*ssh.Jump)(cfg.Server.ProxyJump),
+			reverse,
+		)
+		if err != nil {
+			pTunnel.Fail(fmt.Sprintf("Failed to establish reverse tunnels: %v", err))
+			cancelTunnel()
+			return
+		}
+	}
+
+	if socksPort != "" {
+		socksPort, err = tunnel.StartSOCKS(
+			ctx,
+			cfg.Server.Host, cfg.Server.Port,
+			cfg.Server.User, cfg.Server.SSHKey,
+			(*ssh.Jump)(cfg.Server.ProxyJump),
+			socksPort,
+		)
+		if err != nil {
+			pTunnel.Fail(fmt.Sprintf("Failed to start SOCKS proxy: %v", err))
+			cancelTunnel()
+			return
+		}
 	}
 
 	pTunnel.Stop("SSH tunnels established")
 	cancelTunnel()
 
-	console.Success("SSH tunnels established. Press Ctrl+C to exit.")
+	if asJSON {
+		output := tunnelsOutput{Tunnels: []tunnelOutput{}}
+		for _, t := range tunnels {
+			port, _ := strconv.Atoi(t.LocalPort)
+			output.Tunnels = append(output.Tunnels, tunnelOutput{LocalPort: port, Remote: t.RemoteAddr})
+		}
+		for _, t := range reverse {
+			output.Reverse = append(output.Reverse, reverseTunnelOutput{Remote: t.RemoteAddr, Local: t.LocalAddr})
+		}
+		if socksPort != "" {
+			output.SOCKS = "localhost:" + socksPort
+		}
+		if err := json.NewEncoder(os.Stdout).Encode(output); err != nil {
+			console.Error("Failed to print tunnels:", err)
+			return
+		}
+	} else {
+		for _, t := range tunnels {
+			console.Print(fmt.Sprintf("  localhost:%s -> %s (server)", t.LocalPort, t.RemoteAddr))
+		}
+		for _, t := range reverse {
+			console.Print(fmt.Sprintf("  %s (server) -> %s", t.RemoteAddr, t.LocalAddr))
+		}
+		if socksPort != "" {
+			console.Print(fmt.Sprintf("  SOCKS5 proxy on localhost:%s -> connections from the server", socksPort))
+		}
+		console.Success("SSH tunnels established. Press Ctrl+C to exit.")
+	}
 
 	// Same old signal handling
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 	<-sigs
 
-	console.Info("Shutting down tunnels...")
+	if !asJSON {
+		console.Info("Shutting down tunnels...")
+	}
 	cancel()
 	time.Sleep(1 * time.Second)
 }
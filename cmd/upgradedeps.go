@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yarlson/pin"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/console"
+	"github.com/yarlson/ftl/pkg/deployment"
+)
+
+var upgradeDepsCmd = &cobra.Command{
+	Use:   "upgrade-deps [dependency...]",
+	Short: "Upgrade dependencies to the images in ftl.yaml",
+	Long: `Upgrade-deps compares the image of each deployed dependency, or of the
+given dependencies, with ftl.yaml and replaces the containers whose image
+changed.
+
+Dependencies with named volumes are stopped and their volumes archived into
+the snapshots folder of the project on the server before the upgrade. A major
+version change of such a dependency, as from postgres:16 to postgres:17,
+usually needs a data migration and is refused unless --allow-major is set.`,
+	ValidArgsFunction: completeDependencies,
+	Run:               runUpgradeDeps,
+}
+
+func init() {
+	rootCmd.AddCommand(upgradeDepsCmd)
+	addConfigFlag(upgradeDepsCmd)
+	addEnvFlag(upgradeDepsCmd)
+	addProfileFlag(upgradeDepsCmd)
+
+	upgradeDepsCmd.Flags().Bool("allow-major", false, "Allow major version upgrades of dependencies with volumes")
+	upgradeDepsCmd.Flags().Bool("force-unlock", false, "Take over the deploy lock left behind by an interrupted deployment")
+}
+
+func runUpgradeDeps(cmd *cobra.Command, args []string) {
+	pUpgrade := pin.New("Checking dependencies", pin.WithSpinnerColor(pin.ColorCyan))
+	cancelUpgrade := pUpgrade.Start(context.Background())
+	defer cancelUpgrade()
+
+	allowMajor, err := cmd.Flags().GetBool("allow-major")
+	if err != nil {
+		pUpgrade.Fail(fmt.Sprintf("Failed to get allow-major flag: %v", err))
+		return
+	}
+	forceUnlock, err := cmd.Flags().GetBool("force-unlock")
+	if err != nil {
+		pUpgrade.Fail(fmt.Sprintf("Failed to get force-unlock flag: %v", err))
+		return
+	}
+
+	cfg, err := parseConfig(configFile)
+	if err != nil {
+		pUpgrade.Fail(fmt.Sprintf("Failed to parse config file: %v", err))
+		return
+	}
+
+	var names []string
+	if len(args) > 0 {
+		if err := checkDependencyNames(cfg, args); err != nil {
+			pUpgrade.Fail(err.Error())
+			return
+		}
+		names = args
+	}
+
+	runner, err := connectToServer(cfg.Server)
+	if err != nil {
+		pUpgrade.Fail(fmt.Sprintf("Failed to connect to server %s: %v", cfg.Server.Host, err))
+		return
+	}
+	defer runner.Close()
+
+	deploy := deployment.NewDeployment(runner, nil)
+	ctx := context.Background()
+
+	upgrades, err := deploy.DependencyUpgrades(ctx, cfg.Project.Name, cfg, names)
+	if err != nil {
+		pUpgrade.Fail(fmt.Sprintf("Failed to compare dependency images: %v", err))
+		return
+	}
+	if len(upgrades) == 0 {
+		pUpgrade.Stop("Dependencies are up to date")
+		return
+	}
+
+	pUpgrade.Stop(fmt.Sprintf("Found %d dependency upgrade(s)", len(upgrades)))
+
+	var blocked []string
+	for _, upgrade := range upgrades {
+		switch {
+		case upgrade.Major && upgrade.Stateful():
+			console.Warning(upgrade.String() + " is a major version upgrade of a dependency with volumes")
+			blocked = append(blocked, upgrade.Dependency.Name)
+		case upgrade.Major:
+			console.Warning(upgrade.String() + " is a major version upgrade")
+		default:
+			console.Info(upgrade.String())
+		}
+	}
+	if len(blocked) > 0 && !allowMajor {
+		console.Error(fmt.Sprintf("Refusing to upgrade %s: check the upgrade notes of the image for data migrations and rerun with --allow-major", strings.Join(blocked, ", ")))
+		return
+	}
+
+	pUpgrade = pin.New("Upgrading dependencies", pin.WithSpinnerColor(pin.ColorCyan))
+	cancelUpgrade = pUpgrade.Start(context.Background())
+	defer cancelUpgrade()
+
+	pUpgrade.UpdateMessage("Acquiring deploy lock...")
+	if err := deploy.Lock(ctx, cfg.Project.Name, lockOwner(), forceUnlock); err != nil {
+		pUpgrade.Fail(err.Error())
+		return
+	}
+	defer func() {
+		_ = deploy.Unlock(context.Background(), cfg.Project.Name)
+	}()
+
+	var snapshots []string
+	for _, upgrade := range upgrades {
+		taken, err := deploy.UpgradeDependency(ctx, cfg.Project.Name, pUpgrade, upgrade)
+		snapshots = append(snapshots, taken...)
+		if err != nil {
+			pUpgrade.Fail(err.Error())
+			printSnapshots(snapshots)
+			return
+		}
+	}
+
+	pUpgrade.Stop("Dependencies upgraded successfully")
+	printSnapshots(snapshots)
+}
+
+// checkDependencyNames returns an error naming the first of names that is not
+// a dependency in cfg.
+func checkDependencyNames(cfg *config.Config, names []string) error {
+	for _, name := range names {
+		found := false
+		for _, dependency := range cfg.Dependencies {
+			if dependency.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown dependency %q", name)
+		}
+	}
+	return nil
+}
+
+func printSnapshots(snapshots []string) {
+	for _, snapshot := range snapshots {
+		console.Info("Volume snapshot saved to " + snapshot)
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/yarlson/pin"
 
+	"github.com/yarlson/ftl/pkg/config"
 	"github.com/yarlson/ftl/pkg/console"
+	"github.com/yarlson/ftl/pkg/deployment"
 )
 
 var validateCmd = &cobra.Command{
@@ -20,12 +22,20 @@ This command performs validation checks including:
 - File path existence
 - Environment variable resolution
 - Service name uniqueness
-- Volume reference validity`,
+- Volume reference validity
+
+With --remote, it also connects to the server and checks that it can satisfy
+the configuration: Docker version, CPUs and memory against declared resource
+limits, and kernel support for pids limits and ulimits.`,
 	Run: runValidate,
 }
 
 func init() {
 	rootCmd.AddCommand(validateCmd)
+
+	validateCmd.Flags().Bool("remote", false, "Also check the configuration against the server's capabilities")
+	addConfigFlag(validateCmd)
+	addEnvFlag(validateCmd)
 }
 
 func runValidate(cmd *cobra.Command, args []string) {
@@ -33,14 +43,45 @@ func runValidate(cmd *cobra.Command, args []string) {
 	cancelValidate := pValidate.Start(context.Background())
 	defer cancelValidate()
 
-	_, err := parseConfig("ftl.yaml")
+	cfg, err := parseConfig(configFile)
 	if err != nil {
 		pValidate.Fail(fmt.Sprintf("Configuration validation failed: %v", err))
 		return
 	}
 
-	// Additional validation checks could be added here
+	remote, _ := cmd.Flags().GetBool("remote")
+	if remote {
+		pValidate.UpdateMessage("Checking server capabilities")
+
+		problems, err := validateRemote(cfg)
+		if err != nil {
+			pValidate.Fail(fmt.Sprintf("Server capability check failed: %v", err))
+			return
+		}
+		if len(problems) > 0 {
+			pValidate.Fail("Server cannot satisfy the configuration")
+			for _, problem := range problems {
+				console.Error(problem)
+			}
+			return
+		}
+	}
 
 	pValidate.Stop("Configuration is valid")
 	console.Success("All validation checks passed successfully")
 }
+
+func validateRemote(cfg *config.Config) ([]string, error) {
+	runner, err := connectToServer(cfg.Server)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to server %s: %w", cfg.Server.Host, err)
+	}
+	defer runner.Close()
+
+	caps, err := deployment.NewDeployment(runner, nil).ServerCapabilities(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return deployment.CheckCapabilities(cfg, caps), nil
+}
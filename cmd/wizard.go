@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/console"
+)
+
+// runWizard asks for the settings of a new project and renders them as
+// ftl.yaml.
+func runWizard() ([]byte, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	cfg := &config.Config{}
+	if cfg.Project.Name, err = ask("Project name", strings.ToLower(filepath.Base(cwd))); err != nil {
+		return nil, err
+	}
+	if cfg.Project.Domain, err = ask("Domain", cfg.Project.Name+".example.com"); err != nil {
+		return nil, err
+	}
+	if cfg.Project.Email, err = ask("Email for Let's Encrypt", "admin@"+cfg.Project.Domain); err != nil {
+		return nil, err
+	}
+
+	cfg.Server = &config.Server{Port: 22}
+	if cfg.Server.Host, err = ask("Server host", cfg.Project.Domain); err != nil {
+		return nil, err
+	}
+	if cfg.Server.User, err = ask("Server user", cfg.Project.Name); err != nil {
+		return nil, err
+	}
+
+	for {
+		defaultName := ""
+		if len(cfg.Services) == 0 {
+			defaultName = cfg.Project.Name
+		}
+		prompt := "Service name"
+		if len(cfg.Services) > 0 {
+			prompt = "Another service (leave empty to finish)"
+		}
+		name, err := ask(prompt, defaultName)
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			break
+		}
+
+		service, err := askService(name, len(cfg.Services) == 0)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Services = append(cfg.Services, *service)
+	}
+
+	console.Info("Dependencies with defaults: " + strings.Join(config.DefaultDependencyNames(), ", "))
+	answer, err := ask("Dependencies, comma separated, as postgres:16", "")
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range strings.Split(answer, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		dependency, ok := config.DefaultDependency(value)
+		if !ok {
+			image := value
+			value, _, _ = strings.Cut(value, ":")
+			dependency = &config.Dependency{Name: value, Image: image}
+			console.Warning(fmt.Sprintf("No defaults for %s, add its ports, volumes and environment to ftl.yaml", value))
+		}
+		cfg.Dependencies = append(cfg.Dependencies, *dependency)
+		for _, volume := range dependency.Volumes {
+			if name, _, _ := strings.Cut(volume, ":"); !slices.Contains(cfg.Volumes, name) {
+				cfg.Volumes = append(cfg.Volumes, name)
+			}
+		}
+	}
+
+	output, err := config.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render ftl.yaml: %w", err)
+	}
+	if _, err := config.ParseConfig(output); err != nil {
+		console.Warning(fmt.Sprintf("Fix ftl.yaml before deploying: %v", err))
+	}
+
+	return output, nil
+}
+
+// askService asks how the service name is built and routed. The first service
+// is routed at the root of the domain, the others under their name.
+func askService(name string, first bool) (*config.Service, error) {
+	service := &config.Service{Name: name}
+
+	source, err := ask(name+": build path or image", "./")
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(source, ".") || strings.HasPrefix(source, "/") {
+		service.Path = source
+	} else {
+		service.Image = source
+	}
+
+	for {
+		port, err := ask(name+": port it listens on", "80")
+		if err != nil {
+			return nil, err
+		}
+		if service.Port, err = strconv.Atoi(port); err == nil && service.Port > 0 && service.Port <= 65535 {
+			break
+		}
+		console.Warning("Enter a port between 1 and 65535")
+	}
+
+	path := "/" + name
+	if first {
+		path = "/"
+	}
+	path, err = ask(name+": route path", path)
+	if err != nil {
+		return nil, err
+	}
+	service.Routes = []config.Route{{PathPrefix: path}}
+
+	return service, nil
+}
+
+// ask prompts for a value, returning def when the answer is empty.
+func ask(prompt, def string) (string, error) {
+	if def != "" {
+		prompt += " [" + def + "]"
+	}
+	console.Input(prompt + ": ")
+	answer, err := console.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	if answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
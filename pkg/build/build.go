@@ -1,46 +1,71 @@
 package build
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/yarlson/ftl/pkg/config"
 )
 
 type Runner interface {
 	RunCommand(ctx context.Context, command string, args ...string) (io.ReadCloser, error)
+	RunCommandWithOutput(ctx context.Context, w io.Writer, command string, args ...string) error
 	RunCommands(ctx context.Context, commands []string) error
 }
 
+// buildErrorLines is how many trailing lines of build output are included
+// in the error when a build fails.
+const buildErrorLines = 20
+
 type Build struct {
 	runner Runner
+	target Target
+}
+
+// Options are passed to docker build. Secrets, SSH and the caches use the
+// notation of the --secret, --ssh, --cache-from and --cache-to flags, e.g.
+// "id=npm,env=NPM_TOKEN", "default" and "type=registry,ref=app:cache".
+type Options struct {
+	Platforms []string
+	Secrets   []string
+	SSH       []string
+	CacheFrom []string
+	CacheTo   []string
 }
 
 func NewBuild(runner Runner) *Build {
 	return &Build{runner: runner}
 }
 
-func (b *Build) Build(ctx context.Context, image, path string) error {
+// Build builds image from the Dockerfile in path. Every line of build output
+// is passed to output as it is produced, when output is not nil.
+func (b *Build) Build(ctx context.Context, image, path string, opts Options, output func(line string)) error {
 	labelKey := "org.opencontainers.image.vendor"
 	labelValue := "ftl"
 
-	_, err := b.runner.RunCommand(ctx,
-		"docker", "build",
-		"-t", image,
-		"--platform", "linux/amd64",
-		"--label", fmt.Sprintf("%s=%s", labelKey, labelValue),
-		path,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to build image: %w", err)
+	buildArgsFor := func(path string) []string {
+		return buildArgs(image, path, b.target.Builder, opts, labelKey+"="+labelValue)
+	}
+	if b.target.Sync {
+		if err := b.buildSynced(ctx, path, opts, buildArgsFor, output); err != nil {
+			return err
+		}
+	} else if err := b.stream(ctx, buildArgsFor(path), output); err != nil {
+		return err
 	}
 
-	outputReader, err := b.runner.RunCommand(ctx,
-		"docker", "images",
+	outputReader, err := b.runner.RunCommand(ctx, "docker", b.dockerArgs(
+		"images",
 		"--filter", "dangling=true",
 		"--filter", fmt.Sprintf("label=%s=%s", labelKey, labelValue),
 		"--format", "{{.ID}}",
-	)
+	)...)
 	if err != nil {
 		return fmt.Errorf("failed to list images for cleanup: %w", err)
 	}
@@ -57,7 +82,7 @@ func (b *Build) Build(ctx context.Context, image, path string) error {
 	}
 
 	args := append([]string{"rmi", "--force"}, imageIDs...)
-	_, err = b.runner.RunCommand(ctx, "docker", args...)
+	_, err = b.runner.RunCommand(ctx, "docker", b.dockerArgs(args...)...)
 	if err != nil {
 		return fmt.Errorf("failed to remove images: %w", err)
 	}
@@ -65,11 +90,151 @@ func (b *Build) Build(ctx context.Context, image, path string) error {
 	return nil
 }
 
-func (b *Build) Push(ctx context.Context, image string) error {
-	_, err := b.runner.RunCommand(ctx, "docker", "push", image)
-	if err != nil {
-		return fmt.Errorf("failed to push image: %w", err)
+// stream runs docker with args on the daemon of the target, passing every line of output to output when
+// it is not nil. The last lines of output are included in the error when the
+// build fails.
+func (b *Build) stream(ctx context.Context, args []string, output func(line string)) error {
+	if err := b.run(ctx, output, "docker", b.dockerArgs(args...)...); err != nil {
+		return fmt.Errorf("failed to build image: %w", err)
 	}
+	return nil
+}
+
+// run runs command with args like stream, for commands other than docker.
+func (b *Build) run(ctx context.Context, output func(line string), command string, args ...string) error {
+	reader, writer := io.Pipe()
+	done := make(chan struct{})
+	var tail []string
+
+	go func() {
+		defer close(done)
 
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			tail = append(tail, line)
+			if len(tail) > buildErrorLines {
+				tail = tail[1:]
+			}
+			if output != nil {
+				output(line)
+			}
+		}
+		_, _ = io.Copy(io.Discard, reader)
+	}()
+
+	err := b.runner.RunCommandWithOutput(ctx, writer, command, args...)
+	_ = writer.Close()
+	<-done
+
+	if err != nil {
+		return fmt.Errorf("%w\n\x1b[93mBuild output:\x1b[0m\n\x1b[90m%s\x1b[0m", err, strings.Join(tail, "\n"))
+	}
 	return nil
 }
+
+// buildArgs returns the docker arguments for a build. Builds that use a cache
+// or run on the buildx builder named builder go through buildx and load the
+// result into the image store of the daemon, which plain docker build does
+// on its own.
+func buildArgs(image, path, builder string, opts Options, label string) []string {
+	args := []string{"build"}
+	if builder != "" {
+		args = []string{"buildx", "build", "--builder", builder, "--load"}
+	} else if len(opts.CacheFrom) > 0 || len(opts.CacheTo) > 0 {
+		args = []string{"buildx", "build", "--load"}
+	}
+	args = append(args,
+		"--progress", "plain",
+		"-t", image,
+		"--platform", platform(opts),
+		"--label", label,
+	)
+	return append(optionArgs(args, opts), path)
+}
+
+// defaultPlatform is the platform images are built for unless configured.
+const defaultPlatform = "linux/amd64"
+
+func platform(opts Options) string {
+	if len(opts.Platforms) > 0 {
+		return opts.Platforms[0]
+	}
+	return defaultPlatform
+}
+
+// optionArgs appends the secret, SSH and cache flags of opts to args.
+func optionArgs(args []string, opts Options) []string {
+	for _, secret := range opts.Secrets {
+		args = append(args, "--secret", secret)
+	}
+	for _, ssh := range opts.SSH {
+		args = append(args, "--ssh", ssh)
+	}
+	for _, cache := range opts.CacheFrom {
+		args = append(args, "--cache-from", cache)
+	}
+	for _, cache := range opts.CacheTo {
+		args = append(args, "--cache-to", cache)
+	}
+	return args
+}
+
+// pushAttempts is how many times a push is attempted. The registry keeps the
+// layers that were uploaded before a failure, so each retry only sends the
+// layers that are still missing.
+const pushAttempts = 3
+
+func (b *Build) Push(ctx context.Context, image string) error {
+	var err error
+	for attempt := 1; attempt <= pushAttempts; attempt++ {
+		if _, err = b.runner.RunCommand(ctx, "docker", b.dockerArgs("push", image)...); err == nil {
+			return nil
+		}
+		if attempt == pushAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to push image: %w", ctx.Err())
+		case <-time.After(time.Duration(attempt) * time.Second):
+		}
+	}
+
+	return fmt.Errorf("failed to push image after %d attempts: %w", pushAttempts, err)
+}
+
+// ServiceOptions returns the build options configured for service. A leading
+// "~/" in SSH key paths is expanded to the home directory.
+func ServiceOptions(service *config.Service) Options {
+	var opts Options
+	if service.Build == nil {
+		return opts
+	}
+
+	for _, secret := range service.Build.Secrets {
+		opts.Secrets = append(opts.Secrets, secret.Spec())
+	}
+	opts.Platforms = service.Build.Platforms
+	opts.CacheFrom = service.Build.CacheFrom
+	opts.CacheTo = service.Build.CacheTo
+
+	home, _ := os.UserHomeDir()
+	for _, ssh := range service.Build.SSH {
+		id, paths, ok := strings.Cut(ssh, "=")
+		if ok && home != "" {
+			expanded := strings.Split(paths, ",")
+			for i, path := range expanded {
+				if rest, found := strings.CutPrefix(path, "~/"); found {
+					expanded[i] = filepath.Join(home, rest)
+				}
+			}
+			ssh = id + "=" + strings.Join(expanded, ",")
+		}
+		opts.SSH = append(opts.SSH, ssh)
+	}
+
+	return opts
+}
@@ -0,0 +1,63 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+)
+
+func TestBuildArgs(t *testing.T) {
+	args := buildArgs("app:latest", "./web", "", Options{
+		Secrets: []string{"id=npm,env=NPM_TOKEN"},
+		SSH:     []string{"default"},
+	}, "org.opencontainers.image.vendor=ftl")
+
+	assert.Equal(t, []string{
+		"build",
+		"--progress", "plain",
+		"-t", "app:latest",
+		"--platform", "linux/amd64",
+		"--label", "org.opencontainers.image.vendor=ftl",
+		"--secret", "id=npm,env=NPM_TOKEN",
+		"--ssh", "default",
+		"./web",
+	}, args)
+}
+
+func TestServiceOptions(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	opts := ServiceOptions(&config.Service{
+		Build: &config.Build{
+			Secrets: []config.BuildSecret{
+				{ID: "npm", Env: "NPM_TOKEN"},
+				{ID: "netrc", Src: "./.netrc"},
+			},
+			SSH: []string{"default", "github=~/.ssh/id_ed25519"},
+		},
+	})
+
+	assert.Equal(t, []string{"id=npm,env=NPM_TOKEN", "id=netrc,src=./.netrc"}, opts.Secrets)
+	assert.Equal(t, []string{"default", "github=" + filepath.Join(home, ".ssh", "id_ed25519")}, opts.SSH)
+	assert.Equal(t, Options{}, ServiceOptions(&config.Service{}))
+}
+
+func TestBuildArgs_Cache(t *testing.T) {
+	args := buildArgs("app:latest", "./web", "", Options{
+		CacheFrom: []string{"type=registry,ref=ghcr.io/acme/web:cache"},
+		CacheTo:   []string{"type=registry,ref=ghcr.io/acme/web:cache,mode=max"},
+	}, "org.opencontainers.image.vendor=ftl")
+
+	assert.Equal(t, []string{"buildx", "build", "--load"}, args[:3])
+	assert.Equal(t, []string{
+		"--cache-from", "type=registry,ref=ghcr.io/acme/web:cache",
+		"--cache-to", "type=registry,ref=ghcr.io/acme/web:cache,mode=max",
+		"./web",
+	}, args[len(args)-5:])
+}
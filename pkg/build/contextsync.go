@@ -0,0 +1,449 @@
+package build
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/moby/patternmatcher"
+	"github.com/moby/patternmatcher/ignorefile"
+
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+// syncRoot is the directory, relative to the home of the SSH user on the
+// build host, build contexts are synced to.
+const syncRoot = ".cache/ftl/contexts"
+
+// syncStaging is the directory of the archive the pure-Go sync uploads that
+// holds the list of removed files and the new manifest.
+const syncStaging = ".ftl-sync"
+
+// ignoreFiles are read from the root of a build context. .ftlignore keeps
+// files out of the upload in addition to .dockerignore, and uses its syntax.
+var ignoreFiles = []string{".dockerignore", ".ftlignore"}
+
+// lookPath finds the rsync binary. It is a variable so tests can pretend it
+// is missing or installed.
+var lookPath = exec.LookPath
+
+// sshHost is a build host reached over SSH, as in ssh://user@host:port.
+type sshHost struct {
+	user string
+	host string
+	port string
+}
+
+func parseSSHHost(address string) (sshHost, error) {
+	u, err := url.Parse(address)
+	if err != nil || u.Scheme != "ssh" || u.Hostname() == "" {
+		return sshHost{}, fmt.Errorf("syncing the build context needs an ssh:// builder host, not %q", address)
+	}
+	return sshHost{user: u.User.Username(), host: u.Hostname(), port: u.Port()}, nil
+}
+
+func (h sshHost) destination() string {
+	return h.userAt(h.host)
+}
+
+// rsyncDestination returns the destination of rsync, which takes the path
+// after a colon and so needs IPv6 addresses in brackets, unlike ssh.
+func (h sshHost) rsyncDestination() string {
+	if strings.Contains(h.host, ":") {
+		return h.userAt("[" + h.host + "]")
+	}
+	return h.destination()
+}
+
+func (h sshHost) userAt(host string) string {
+	if h.user == "" {
+		return host
+	}
+	return h.user + "@" + host
+}
+
+// options returns the ssh options, without the destination.
+func (h sshHost) options() []string {
+	options := []string{"-o", "BatchMode=yes"}
+	if h.port != "" {
+		options = append(options, "-p", h.port)
+	}
+	return options
+}
+
+// args returns the ssh arguments that run command on the host.
+func (h sshHost) args(command string) []string {
+	return append(h.options(), h.destination(), "--", command)
+}
+
+// buildSynced syncs the build context in path to the build host of the target
+// and runs the docker build there, with the arguments args returns for the
+// synced directory, so only the files that changed since the last build are
+// transferred.
+func (b *Build) buildSynced(ctx context.Context, path string, opts Options, args func(path string) []string, output func(line string)) error {
+	// Secrets and SSH agents are read by the docker CLI, which runs on the
+	// build host here.
+	if len(opts.Secrets) > 0 || len(opts.SSH) > 0 {
+		return errors.New("build secrets and ssh cannot be used when the build context is synced to the builder host")
+	}
+
+	host, err := parseSSHHost(b.target.Host)
+	if err != nil {
+		return err
+	}
+	dir, err := b.syncContext(ctx, host, path)
+	if err != nil {
+		return err
+	}
+
+	if err := b.run(ctx, output, "ssh", host.args(shell.Join("docker", args(dir)...))...); err != nil {
+		return fmt.Errorf("failed to build image: %w", err)
+	}
+	return nil
+}
+
+// syncContext copies the build context in path to the build host, with rsync
+// when it is installed and by comparing file hashes otherwise, and returns
+// the directory it is synced to. Files ignored by .dockerignore or
+// .ftlignore are left out, and files removed locally are removed from the
+// host.
+func (b *Build) syncContext(ctx context.Context, host sshHost, path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve build context %s: %w", path, err)
+	}
+	dir := syncDir(abs)
+
+	files, excluded, err := contextFiles(abs)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := lookPath("rsync"); err == nil {
+		err = b.rsync(ctx, host, abs, dir, excluded)
+	} else {
+		err = b.syncArchive(ctx, host, abs, dir, files)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to sync build context to %s: %w", host.host, err)
+	}
+	return dir, nil
+}
+
+// syncDir returns the directory on the build host the context at the
+// absolute path is synced to, so every context keeps its own copy.
+func syncDir(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return syncRoot + "/" + hex.EncodeToString(sum[:])[:12]
+}
+
+// rsync syncs the context with the rsync binary, excluding the ignored paths.
+func (b *Build) rsync(ctx context.Context, host sshHost, path, dir string, excluded []string) error {
+	excludeFile, err := os.CreateTemp("", "ftl-rsync-exclude-*")
+	if err != nil {
+		return fmt.Errorf("failed to create exclude file: %w", err)
+	}
+	defer os.Remove(excludeFile.Name())
+
+	_, err = excludeFile.WriteString(rsyncExcludes(excluded))
+	_ = excludeFile.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write exclude file: %w", err)
+	}
+
+	if err := b.run(ctx, nil, "ssh", host.args(shell.Join("mkdir -p", dir))...); err != nil {
+		return err
+	}
+	return b.run(ctx, nil, "rsync", rsyncArgs(host, path, dir, excludeFile.Name())...)
+}
+
+// rsyncArgs returns the rsync arguments that sync path to dir on host. Owners
+// are not preserved, since the SSH user may not be allowed to set them.
+func rsyncArgs(host sshHost, path, dir, excludeFile string) []string {
+	return []string{
+		"-rlptz", "--delete", "--delete-excluded",
+		"--exclude-from=" + excludeFile,
+		"-e", strings.Join(append([]string{"ssh"}, host.options()...), " "),
+		path + "/",
+		host.rsyncDestination() + ":" + dir + "/",
+	}
+}
+
+// rsyncExcludes returns the rsync exclude rules of the ignored paths: one per
+// path, anchored at the root of the context, with wildcards escaped.
+func rsyncExcludes(excluded []string) string {
+	var rules strings.Builder
+	for _, path := range excluded {
+		for _, r := range "/" + path {
+			if strings.ContainsRune(`\*?[`, r) {
+				rules.WriteRune('\\')
+			}
+			rules.WriteRune(r)
+		}
+		rules.WriteByte('\n')
+	}
+	return rules.String()
+}
+
+// syncArchive syncs the context without rsync. The manifest of the last sync,
+// kept next to dir on the host, is compared to that of the local files, and
+// an archive of the changed files is extracted into dir, with the files that
+// were removed deleted.
+func (b *Build) syncArchive(ctx context.Context, host sshHost, path, dir string, files []string) error {
+	local, err := contextManifest(path, files)
+	if err != nil {
+		return err
+	}
+
+	manifestPath := dir + ".manifest"
+	previous, err := b.runner.RunCommand(ctx, "ssh", host.args("cat "+shell.Quote(manifestPath)+" 2>/dev/null || true")...)
+	if err != nil {
+		return fmt.Errorf("failed to read manifest of the last sync: %w", err)
+	}
+	data, err := io.ReadAll(previous)
+	_ = previous.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read manifest of the last sync: %w", err)
+	}
+
+	changed, removed := diffManifests(local, parseManifest(string(data)))
+
+	script := fmt.Sprintf(
+		`mkdir -p %[1]s && tar -xzf - -C %[1]s && (cd %[1]s && while IFS= read -r f; do rm -f -- "$f"; done < %[2]s/removed) && mv %[1]s/%[2]s/manifest %[3]s && rm -rf %[1]s/%[2]s`,
+		shell.Quote(dir), syncStaging, shell.Quote(manifestPath),
+	)
+	upload := exec.CommandContext(ctx, "ssh", host.args(script)...)
+	reader, writer := io.Pipe()
+	upload.Stdin = reader
+	var stderr strings.Builder
+	upload.Stderr = &stderr
+
+	go func() {
+		writer.CloseWithError(writeSyncArchive(writer, path, changed, removed, local))
+	}()
+
+	if err := upload.Run(); err != nil {
+		_ = reader.CloseWithError(err)
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// contextFiles returns the files of the build context in path that are sent
+// to the builder, and the paths that .dockerignore and .ftlignore leave out,
+// relative to path with forward slashes. A directory whose whole content is
+// ignored is listed instead of its files. The Dockerfile and .dockerignore
+// are always sent, as docker build does.
+func contextFiles(path string) (files, excluded []string, err error) {
+	var patterns []string
+	for _, name := range ignoreFiles {
+		file, err := os.Open(filepath.Join(path, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		filePatterns, err := ignorefile.ReadAll(file)
+		_ = file.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		patterns = append(patterns, filePatterns...)
+	}
+
+	matcher, err := patternmatcher.New(patterns)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid ignore pattern: %w", err)
+	}
+
+	err = filepath.WalkDir(path, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(path, name)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		ignored, err := matcher.MatchesOrParentMatches(rel)
+		if err != nil {
+			return err
+		}
+		if ignored && rel != "Dockerfile" && rel != ".dockerignore" {
+			// With exclusions, such as !keep.txt, an ignored directory may
+			// still hold files that are sent.
+			if !entry.IsDir() || !matcher.Exclusions() {
+				excluded = append(excluded, rel)
+			}
+			if entry.IsDir() && !matcher.Exclusions() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if !entry.IsDir() {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list build context %s: %w", path, err)
+	}
+	return files, excluded, nil
+}
+
+// contextManifest returns the hash of the content and mode of every file,
+// keyed by its path. Symbolic links are hashed by their target.
+func contextManifest(path string, files []string) (map[string]string, error) {
+	manifest := make(map[string]string, len(files))
+	for _, file := range files {
+		name := filepath.Join(path, filepath.FromSlash(file))
+		info, err := os.Lstat(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%s\x00", info.Mode())
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read link %s: %w", file, err)
+			}
+			hash.Write([]byte(target))
+		} else if err := hashFile(hash, name); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		manifest[file] = hex.EncodeToString(hash.Sum(nil))
+	}
+	return manifest, nil
+}
+
+func hashFile(w io.Writer, name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// formatManifest writes manifest as one "hash path" line per file, sorted by
+// path.
+func formatManifest(manifest map[string]string) string {
+	paths := make([]string, 0, len(manifest))
+	for path := range manifest {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	for _, path := range paths {
+		fmt.Fprintf(&b, "%s %s\n", manifest[path], path)
+	}
+	return b.String()
+}
+
+func parseManifest(data string) map[string]string {
+	manifest := map[string]string{}
+	for _, line := range strings.Split(data, "\n") {
+		hash, path, ok := strings.Cut(line, " ")
+		if ok && path != "" {
+			manifest[path] = hash
+		}
+	}
+	return manifest
+}
+
+// diffManifests returns the files of local that are new or changed since
+// previous, and those of previous that local no longer has, sorted.
+func diffManifests(local, previous map[string]string) (changed, removed []string) {
+	for path, hash := range local {
+		if previous[path] != hash {
+			changed = append(changed, path)
+		}
+	}
+	for path := range previous {
+		if _, ok := local[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// writeSyncArchive writes a gzipped tar of the changed files of the context
+// in path to w, followed by the list of removed files and the new manifest
+// in the staging directory.
+func writeSyncArchive(w io.Writer, path string, changed, removed []string, manifest map[string]string) error {
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+
+	for _, file := range changed {
+		if err := addToArchive(archive, path, file); err != nil {
+			return err
+		}
+	}
+
+	var list strings.Builder
+	for _, file := range removed {
+		list.WriteString(file + "\n")
+	}
+	for name, content := range map[string]string{"removed": list.String(), "manifest": formatManifest(manifest)} {
+		header := &tar.Header{Name: syncStaging + "/" + name, Mode: 0o644, Size: int64(len(content))}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := archive.Write([]byte(content)); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addToArchive(archive *tar.Writer, path, file string) error {
+	name := filepath.Join(path, filepath.FromSlash(file))
+	info, err := os.Lstat(name)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	var link string
+	if info.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(name); err != nil {
+			return fmt.Errorf("failed to read link %s: %w", file, err)
+		}
+	}
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", file, err)
+	}
+	header.Name = file
+	if err := archive.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", file, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	return hashFile(archive, name)
+}
@@ -0,0 +1,175 @@
+package build
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func writeContext(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	return dir
+}
+
+func TestContextFiles(t *testing.T) {
+	dir := writeContext(t, map[string]string{
+		"Dockerfile":              "FROM alpine",
+		".dockerignore":           "node_modules\n*.log\nDockerfile\n",
+		".ftlignore":              "tmp/\n",
+		"main.go":                 "package main",
+		"debug.log":               "",
+		"node_modules/a/index.js": "",
+		"tmp/cache":               "",
+		"src/app.go":              "package src",
+	})
+
+	files, excluded, err := contextFiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{".dockerignore", ".ftlignore", "Dockerfile", "main.go", "src/app.go"}, files)
+	assert.Equal(t, []string{"debug.log", "node_modules", "tmp"}, excluded)
+
+	// Files excluded from an ignored directory are still sent.
+	dir = writeContext(t, map[string]string{
+		".dockerignore":   "vendor\n!vendor/keep.txt\n",
+		"vendor/drop.txt": "",
+		"vendor/keep.txt": "",
+	})
+	files, excluded, err = contextFiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{".dockerignore", "vendor/keep.txt"}, files)
+	assert.Equal(t, []string{"vendor/drop.txt"}, excluded)
+}
+
+func TestDiffManifests(t *testing.T) {
+	previous := parseManifest(formatManifest(map[string]string{"a.go": "1", "b.go": "2", "c.go": "3"}))
+	changed, removed := diffManifests(map[string]string{"a.go": "1", "b.go": "9", "d.go": "4"}, previous)
+	assert.Equal(t, []string{"b.go", "d.go"}, changed)
+	assert.Equal(t, []string{"c.go"}, removed)
+
+	changed, removed = diffManifests(previous, previous)
+	assert.Empty(t, changed)
+	assert.Empty(t, removed)
+}
+
+func TestContextManifest(t *testing.T) {
+	dir := writeContext(t, map[string]string{"a.go": "package a"})
+	first, err := contextManifest(dir, []string{"a.go"})
+	require.NoError(t, err)
+
+	require.NoError(t, os.Chmod(filepath.Join(dir, "a.go"), 0o755))
+	second, err := contextManifest(dir, []string{"a.go"})
+	require.NoError(t, err)
+	assert.NotEqual(t, first["a.go"], second["a.go"])
+}
+
+func TestWriteSyncArchive(t *testing.T) {
+	dir := writeContext(t, map[string]string{"a.go": "package a", "b.go": "package b"})
+	manifest := map[string]string{"a.go": "1", "b.go": "2"}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeSyncArchive(&buf, dir, []string{"b.go"}, []string{"old.go"}, manifest))
+
+	gz, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	archive := tar.NewReader(gz)
+	contents := map[string]string{}
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(archive)
+		require.NoError(t, err)
+		contents[header.Name] = string(data)
+	}
+
+	assert.Equal(t, map[string]string{
+		"b.go":               "package b",
+		".ftl-sync/removed":  "old.go\n",
+		".ftl-sync/manifest": "1 a.go\n2 b.go\n",
+	}, contents)
+}
+
+func TestParseSSHHost(t *testing.T) {
+	host, err := parseSSHHost("ssh://builder@build.example.com:2222")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-o", "BatchMode=yes", "-p", "2222", "builder@build.example.com", "--", "docker ps"}, host.args("docker ps"))
+
+	_, err = parseSSHHost("tcp://build.example.com:2376")
+	assert.Error(t, err)
+
+	host, err = parseSSHHost("ssh://builder@[2001:db8::1]:2222")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-o", "BatchMode=yes", "-p", "2222", "builder@2001:db8::1", "--", "docker ps"}, host.args("docker ps"))
+}
+
+func TestRsyncArgs(t *testing.T) {
+	host, err := parseSSHHost("ssh://builder@build.example.com")
+	require.NoError(t, err)
+	args := rsyncArgs(host, "/src/app", "/tmp/ftl-build", "/tmp/excludes")
+	assert.Equal(t, "builder@build.example.com:/tmp/ftl-build/", args[len(args)-1])
+
+	host, err = parseSSHHost("ssh://builder@[2001:db8::1]")
+	require.NoError(t, err)
+	args = rsyncArgs(host, "/src/app", "/tmp/ftl-build", "/tmp/excludes")
+	assert.Equal(t, "builder@[2001:db8::1]:/tmp/ftl-build/", args[len(args)-1])
+
+	host, err = parseSSHHost("ssh://[2001:db8::1]:2222")
+	require.NoError(t, err)
+	args = rsyncArgs(host, "/src/app", "/tmp/ftl-build", "/tmp/excludes")
+	assert.Equal(t, []string{"-e", "ssh -o BatchMode=yes -p 2222", "/src/app/", "[2001:db8::1]:/tmp/ftl-build/"}, args[4:])
+}
+
+func TestBuildSynced(t *testing.T) {
+	defer func(original func(string) (string, error)) { lookPath = original }(lookPath)
+	lookPath = func(string) (string, error) { return "/usr/bin/rsync", nil }
+
+	dir := writeContext(t, map[string]string{"Dockerfile": "FROM alpine", ".dockerignore": "*.log\n", "debug.log": ""})
+
+	runner := fake.NewRunner()
+	builder := NewBuild(runner)
+	builder.SetTarget(Target{Host: "ssh://builder@build.example.com", Sync: true})
+	require.NoError(t, builder.Build(context.Background(), "app:latest", dir, Options{}, nil))
+
+	calls := runner.Calls()
+	require.Len(t, calls, 4)
+	remote := syncDir(dir)
+
+	assert.Equal(t, "ssh -o BatchMode=yes builder@build.example.com -- mkdir -p '"+remote+"'", calls[0].String())
+
+	assert.Equal(t, "rsync", calls[1].Command)
+	assert.Equal(t, []string{"-rlptz", "--delete", "--delete-excluded"}, calls[1].Args[:3])
+	assert.Equal(t, []string{"-e", "ssh -o BatchMode=yes", dir + "/", "builder@build.example.com:" + remote + "/"}, calls[1].Args[4:])
+
+	assert.Equal(t, []string{"-o", "BatchMode=yes", "builder@build.example.com", "--",
+		"docker 'build' '--progress' 'plain' '-t' 'app:latest' '--platform' 'linux/amd64' '--label' 'org.opencontainers.image.vendor=ftl' '" + remote + "'",
+	}, calls[2].Args)
+
+	// The dangling images are still cleaned up through the daemon address.
+	assert.Equal(t, []string{"--host", "ssh://builder@build.example.com", "images"}, calls[3].Args[:3])
+
+	err := builder.Build(context.Background(), "app:latest", dir, Options{SSH: []string{"default"}}, nil)
+	assert.ErrorContains(t, err, "cannot be used when the build context is synced")
+}
+
+func TestRsyncExcludes(t *testing.T) {
+	assert.Equal(t, "/node_modules\n/logs/\\*.log\n", rsyncExcludes([]string{"node_modules", "logs/*.log"}))
+}
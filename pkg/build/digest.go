@@ -0,0 +1,91 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/docker"
+)
+
+// DigestsFile is where ftl build records the digest of every image it pushed,
+// relative to the directory of the configuration. Deploys use the recorded
+// digests so the server runs exactly the images that were built.
+const DigestsFile = ".ftl/digests.json"
+
+// Digest returns the reference by digest of image, "repository@sha256:...",
+// once it was pushed to its registry.
+func (b *Build) Digest(ctx context.Context, image string) (string, error) {
+	output, err := b.runner.RunCommand(ctx, "docker", b.dockerArgs("image", "inspect", "--format", "{{json .RepoDigests}}", image)...)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", image, err)
+	}
+	defer output.Close()
+
+	data, err := io.ReadAll(output)
+	if err != nil {
+		return "", fmt.Errorf("failed to read output of docker image inspect: %w", err)
+	}
+
+	var digests []string
+	if err := json.Unmarshal([]byte(strings.TrimSpace(string(data))), &digests); err != nil {
+		return "", fmt.Errorf("failed to parse digests of image %s: %w", image, err)
+	}
+
+	repository := docker.Repository(image)
+	for _, digest := range digests {
+		if docker.Repository(digest) == repository {
+			return digest, nil
+		}
+	}
+
+	return "", fmt.Errorf("image %s has no digest in %s", image, repository)
+}
+
+// LoadDigests reads the digests recorded at path, keyed by image. A missing
+// file has no digests.
+func LoadDigests(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image digests: %w", err)
+	}
+
+	digests := map[string]string{}
+	if err := json.Unmarshal(data, &digests); err != nil {
+		return nil, fmt.Errorf("failed to parse image digests %s: %w", path, err)
+	}
+	return digests, nil
+}
+
+// SaveDigests records digests at path, keeping the digests of other images
+// that were recorded before.
+func SaveDigests(path string, digests map[string]string) error {
+	recorded, err := LoadDigests(path)
+	if err != nil {
+		return err
+	}
+	for image, digest := range digests {
+		recorded[image] = digest
+	}
+
+	data, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode image digests: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for image digests: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write image digests: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package build
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestDigest(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker image inspect", fake.Response{Output: `["docker.io/library/web@sha256:1111","ghcr.io/acme/web@sha256:2222"]` + "\n"})
+
+	digest, err := NewBuild(runner).Digest(context.Background(), "ghcr.io/acme/web:latest")
+	require.NoError(t, err)
+	assert.Equal(t, "ghcr.io/acme/web@sha256:2222", digest)
+
+	_, err = NewBuild(runner).Digest(context.Background(), "registry.local:5000/web:latest")
+	assert.ErrorContains(t, err, "has no digest")
+}
+
+func TestSaveDigests(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DigestsFile)
+
+	digests, err := LoadDigests(path)
+	require.NoError(t, err)
+	assert.Empty(t, digests)
+
+	require.NoError(t, SaveDigests(path, map[string]string{"ghcr.io/acme/web:latest": "ghcr.io/acme/web@sha256:1111"}))
+	require.NoError(t, SaveDigests(path, map[string]string{"ghcr.io/acme/api:latest": "ghcr.io/acme/api@sha256:2222"}))
+
+	digests, err = LoadDigests(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"ghcr.io/acme/web:latest": "ghcr.io/acme/web@sha256:1111",
+		"ghcr.io/acme/api:latest": "ghcr.io/acme/api@sha256:2222",
+	}, digests)
+}
+
+func TestRelease(t *testing.T) {
+	assert.Equal(t, "registry.local:5000/web:1a2b3c4", ReleaseTag("registry.local:5000/web:latest", "1a2b3c4"))
+
+	dir := filepath.Join(t.TempDir(), ReleasesDir)
+	_, err := LoadRelease(dir, "1a2b3c4")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	release := &Release{Commit: "1a2b3c4", Images: map[string]string{"web": "ghcr.io/acme/web@sha256:1111"}}
+	require.NoError(t, SaveRelease(dir, release))
+
+	loaded, err := LoadRelease(dir, "1a2b3c4")
+	require.NoError(t, err)
+	assert.Equal(t, release, loaded)
+}
+
+func TestRemoteDigest(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker buildx imagetools inspect", fake.Response{Output: "sha256:2222\n"})
+
+	digest, err := NewBuild(runner).RemoteDigest(context.Background(), "ghcr.io/acme/web:1a2b3c4")
+	require.NoError(t, err)
+	assert.Equal(t, "ghcr.io/acme/web@sha256:2222", digest)
+	assert.Equal(t, "docker buildx imagetools inspect --format {{.Manifest.Digest}} ghcr.io/acme/web:1a2b3c4", runner.Calls()[0].String())
+}
+
+func TestSaveTimings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), TimingsFile)
+
+	timings, err := LoadTimings(path)
+	require.NoError(t, err)
+	assert.Empty(t, timings)
+
+	require.NoError(t, SaveTimings(path, map[string]float64{"build": 30, "push": 5}))
+	require.NoError(t, SaveTimings(path, map[string]float64{"build": 10}))
+
+	timings, err = LoadTimings(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"build": 40, "push": 5}, timings)
+
+	require.NoError(t, ClearTimings(path))
+	require.NoError(t, ClearTimings(path))
+	timings, err = LoadTimings(path)
+	require.NoError(t, err)
+	assert.Empty(t, timings)
+}
@@ -0,0 +1,194 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/yarlson/ftl/pkg/docker"
+)
+
+// Builder is the buildx builder multi-platform images are built with, unless
+// the target names another one. The
+// default docker driver cannot push images by digest, so a docker-container
+// builder is created on first use. All platforms are built on it, so they
+// share its BuildKit cache: stages that do not depend on the target platform
+// are built once.
+const Builder = "ftl"
+
+// BuildMultiPlatform builds image for every platform of opts concurrently,
+// pushes each platform image by digest and assembles them into one
+// multi-platform image pushed as image. It returns the reference by digest of
+// the multi-platform image. Every line of build output is passed to output,
+// when it is not nil; buildx prefixes build steps with their platform.
+func (b *Build) BuildMultiPlatform(ctx context.Context, image, path string, opts Options, output func(line string)) (string, error) {
+	builder := b.multiPlatformBuilder()
+	if b.target.Builder == "" {
+		if err := b.ensureBuilder(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	workDir, err := os.MkdirTemp("", "ftl-build-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		errs    []error
+		digests = make([]string, len(opts.Platforms))
+	)
+	for i, platform := range opts.Platforms {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			metadata := filepath.Join(workDir, fmt.Sprintf("%d.json", i))
+			err := b.stream(ctx, platformBuildArgs(image, path, builder, platform, metadata, opts), func(line string) {
+				if output != nil {
+					mu.Lock()
+					output(line)
+					mu.Unlock()
+				}
+			})
+			if err == nil {
+				digests[i], err = readImageDigest(metadata)
+			}
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", platform, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return "", errors.Join(errs...)
+	}
+
+	repository := docker.Repository(image)
+	args := []string{"buildx", "imagetools", "create", "--builder", builder, "-t", image}
+	for _, digest := range digests {
+		args = append(args, repository+"@"+digest)
+	}
+	if _, err := b.runner.RunCommand(ctx, "docker", b.dockerArgs(args...)...); err != nil {
+		return "", fmt.Errorf("failed to create multi-platform image %s: %w", image, err)
+	}
+
+	return b.manifestDigest(ctx, builder, image)
+}
+
+// ensureBuilder creates Builder unless it exists.
+func (b *Build) ensureBuilder(ctx context.Context) error {
+	if _, err := b.runner.RunCommand(ctx, "docker", b.dockerArgs("buildx", "inspect", Builder)...); err == nil {
+		return nil
+	}
+	if _, err := b.runner.RunCommand(ctx, "docker", b.dockerArgs("buildx", "create", "--name", Builder, "--driver", "docker-container")...); err != nil {
+		return fmt.Errorf("failed to create buildx builder %s: %w", Builder, err)
+	}
+	return nil
+}
+
+// platformBuildArgs returns the docker arguments that build image for one
+// platform on builder and push it by digest, recording the digest in
+// metadata.
+func platformBuildArgs(image, path, builder, platform, metadata string, opts Options) []string {
+	args := []string{
+		"buildx", "build",
+		"--builder", builder,
+		"--progress", "plain",
+		"--platform", platform,
+		"--label", "org.opencontainers.image.vendor=ftl",
+		"--output", fmt.Sprintf("type=image,name=%s,push-by-digest=true,name-canonical=true,push=true", docker.Repository(image)),
+		"--metadata-file", metadata,
+	}
+	platformOpts := opts
+	platformOpts.CacheFrom = nil
+	platformOpts.CacheTo = nil
+	for _, cache := range opts.CacheFrom {
+		platformOpts.CacheFrom = append(platformOpts.CacheFrom, platformCache(cache, platform))
+	}
+	for _, cache := range opts.CacheTo {
+		platformOpts.CacheTo = append(platformOpts.CacheTo, platformCache(cache, platform))
+	}
+	return append(optionArgs(args, platformOpts), path)
+}
+
+// platformCache gives each platform its own cache, so the concurrent builds do
+// not overwrite each other's: the tag of a registry ref, or the ref itself
+// when it has no tag, is suffixed with the platform, as in
+// "ghcr.io/acme/web:cache-linux-arm64", and so are local cache directories
+// and GitHub Actions cache scopes.
+func platformCache(spec, platform string) string {
+	suffix := strings.ReplaceAll(platform, "/", "-")
+	tagged := func(ref string) string {
+		if docker.Repository(ref) != ref {
+			return ref + "-" + suffix
+		}
+		return ref + ":" + suffix
+	}
+
+	if !strings.Contains(spec, "=") {
+		return tagged(spec)
+	}
+	options := strings.Split(spec, ",")
+	for i, option := range options {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "ref":
+			options[i] = "ref=" + tagged(value)
+		case "src", "dest", "scope":
+			options[i] = key + "=" + value + "-" + suffix
+		}
+	}
+	return strings.Join(options, ",")
+}
+
+// readImageDigest returns the digest buildx recorded in a metadata file.
+func readImageDigest(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read build metadata: %w", err)
+	}
+	var metadata struct {
+		Digest string `json:"containerimage.digest"`
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return "", fmt.Errorf("failed to parse build metadata: %w", err)
+	}
+	if metadata.Digest == "" {
+		return "", fmt.Errorf("build metadata has no image digest")
+	}
+	return metadata.Digest, nil
+}
+
+// manifestDigest returns the reference by digest of the multi-platform image.
+func (b *Build) manifestDigest(ctx context.Context, builder, image string) (string, error) {
+	output, err := b.runner.RunCommand(ctx, "docker", b.dockerArgs("buildx", "imagetools", "inspect", "--builder", builder, "--format", "{{json .Manifest}}", image)...)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", image, err)
+	}
+	defer output.Close()
+
+	data, err := io.ReadAll(output)
+	if err != nil {
+		return "", fmt.Errorf("failed to read output of docker buildx imagetools inspect: %w", err)
+	}
+
+	var manifest struct {
+		Digest string `json:"digest"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(string(data))), &manifest); err != nil || manifest.Digest == "" {
+		return "", fmt.Errorf("failed to parse digest of image %s: %s", image, strings.TrimSpace(string(data)))
+	}
+	return docker.Repository(image) + "@" + manifest.Digest, nil
+}
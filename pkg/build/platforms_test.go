@@ -0,0 +1,66 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlatformBuildArgs(t *testing.T) {
+	args := platformBuildArgs("ghcr.io/acme/web:v1", "./web", "ftl", "linux/arm64", "/tmp/1.json", Options{
+		Platforms: []string{"linux/amd64", "linux/arm64"},
+		Secrets:   []string{"id=npm,env=NPM_TOKEN"},
+		CacheFrom: []string{"type=registry,ref=ghcr.io/acme/web:cache"},
+		CacheTo:   []string{"type=registry,ref=ghcr.io/acme/web:cache,mode=max"},
+	})
+
+	assert.Equal(t, []string{
+		"buildx", "build",
+		"--builder", "ftl",
+		"--progress", "plain",
+		"--platform", "linux/arm64",
+		"--label", "org.opencontainers.image.vendor=ftl",
+		"--output", "type=image,name=ghcr.io/acme/web,push-by-digest=true,name-canonical=true,push=true",
+		"--metadata-file", "/tmp/1.json",
+		"--secret", "id=npm,env=NPM_TOKEN",
+		"--cache-from", "type=registry,ref=ghcr.io/acme/web:cache-linux-arm64",
+		"--cache-to", "type=registry,ref=ghcr.io/acme/web:cache-linux-arm64,mode=max",
+		"./web",
+	}, args)
+}
+
+func TestPlatformCache(t *testing.T) {
+	tests := map[string]string{
+		"ghcr.io/acme/web:cache":                 "ghcr.io/acme/web:cache-linux-arm64",
+		"localhost:5000/web":                     "localhost:5000/web:linux-arm64",
+		"type=local,dest=/tmp/cache,mode=max":    "type=local,dest=/tmp/cache-linux-arm64,mode=max",
+		"type=gha,scope=web":                     "type=gha,scope=web-linux-arm64",
+		"type=inline":                            "type=inline",
+		"type=registry,ref=registry:5000/web:c1": "type=registry,ref=registry:5000/web:c1-linux-arm64",
+	}
+	for spec, want := range tests {
+		assert.Equal(t, want, platformCache(spec, "linux/arm64"), spec)
+	}
+}
+
+func TestReadImageDigest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"buildx.build.ref": "ftl/ftl0/abc", "containerimage.digest": "sha256:0123"}`), 0644))
+
+	digest, err := readImageDigest(path)
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:0123", digest)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{}`), 0644))
+	_, err = readImageDigest(path)
+	assert.EqualError(t, err, "build metadata has no image digest")
+}
+
+func TestBuildArgs_Platform(t *testing.T) {
+	args := buildArgs("app:latest", "./web", "", Options{Platforms: []string{"linux/arm64"}}, "org.opencontainers.image.vendor=ftl")
+	assert.Contains(t, args, "linux/arm64")
+	assert.NotContains(t, args, "linux/amd64")
+}
@@ -0,0 +1,303 @@
+package build
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+// preCacheEntries is how many outputs of the pre-build commands of a service
+// the cache keeps, so switching between branches keeps hitting it.
+const preCacheEntries = 5
+
+// preSkippedDirs are left out of the default inputs of pre-build commands:
+// they change without the sources changing.
+var preSkippedDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+}
+
+// DefaultPreCacheDir returns the directory pre-build outputs are cached in,
+// in the user cache directory.
+func DefaultPreCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find cache directory: %w", err)
+	}
+	return filepath.Join(dir, "ftl", "build"), nil
+}
+
+// Pre runs the pre-build commands of service in its path, passing every line
+// they print to output. When the service has a build output, the output is
+// restored from cacheDir instead if the commands already ran on the same
+// inputs, and cached after they run otherwise. Without a cacheDir the
+// commands always run. It reports whether the cache was used.
+func (b *Build) Pre(ctx context.Context, project string, service *config.Service, cacheDir string, output func(line string)) (bool, error) {
+	if service.Build == nil || len(service.Build.Pre) == 0 {
+		return false, nil
+	}
+	if service.Build.Output == "" || cacheDir == "" {
+		return false, b.runPre(ctx, service, output)
+	}
+
+	key, err := preKey(service)
+	if err != nil {
+		return false, fmt.Errorf("failed to hash build inputs: %w", err)
+	}
+	dir := filepath.Join(cacheDir, project, service.Name)
+	archive := filepath.Join(dir, key+".tar.gz")
+	outputDir := filepath.Join(service.Path, service.Build.Output)
+
+	if _, err := os.Stat(archive); err == nil {
+		if err := restoreOutput(archive, outputDir); err != nil {
+			return false, fmt.Errorf("failed to restore cached build output: %w", err)
+		}
+		now := time.Now()
+		_ = os.Chtimes(archive, now, now)
+		return true, nil
+	}
+
+	if err := b.runPre(ctx, service, output); err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, fmt.Errorf("failed to create build cache: %w", err)
+	}
+	if err := archiveOutput(outputDir, archive); err != nil {
+		return false, fmt.Errorf("failed to cache build output %s: %w", service.Build.Output, err)
+	}
+	pruneCache(dir, preCacheEntries)
+	return false, nil
+}
+
+// runPre runs the pre-build commands of service one after the other.
+func (b *Build) runPre(ctx context.Context, service *config.Service, output func(line string)) error {
+	for _, command := range service.Build.Pre {
+		if output != nil {
+			output("$ " + command)
+		}
+		if err := b.run(ctx, output, "sh", "-c", "cd "+shell.Quote(service.Path)+" && "+command); err != nil {
+			return fmt.Errorf("pre-build command %q failed: %w", command, err)
+		}
+	}
+	return nil
+}
+
+// preKey returns a hash of the pre-build commands of service, its output
+// directory and the names and content of every file of its inputs.
+func preKey(service *config.Service) (string, error) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "output=%s\n", filepath.Clean(service.Build.Output))
+	for _, command := range service.Build.Pre {
+		fmt.Fprintf(hash, "pre=%s\n", command)
+	}
+
+	inputs := service.Build.Inputs
+	defaults := len(inputs) == 0
+	if defaults {
+		inputs = []string{"."}
+	}
+	output := filepath.Join(service.Path, service.Build.Output)
+
+	for _, input := range inputs {
+		root := filepath.Join(service.Path, input)
+		err := filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				if name == output || (defaults && preSkippedDirs[entry.Name()]) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(service.Path, name)
+			if err != nil {
+				return err
+			}
+
+			file, err := os.Open(name)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			fmt.Fprintf(hash, "%s\n", filepath.ToSlash(rel))
+			_, err = io.Copy(hash, file)
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// archiveOutput writes the files of dir to a gzipped tar archive, written
+// next to it first so a failed run never leaves a truncated cache entry.
+func archiveOutput(dir, archive string) error {
+	file, err := os.CreateTemp(filepath.Dir(archive), ".ftl-cache-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	err = filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || name == dir {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		content, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+		_, err = io.Copy(tw, content)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), archive)
+}
+
+// restoreOutput replaces dir with the files of archive, keeping their
+// modification times.
+func restoreOutput(archive, dir string) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !filepath.IsLocal(header.Name) {
+			return fmt.Errorf("invalid path %q in archive", header.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(header.Name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, header.FileInfo().Mode().Perm()|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, header.FileInfo().Mode().Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			if closeErr := out.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+			if err := os.Chtimes(target, header.ModTime, header.ModTime); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// pruneCache removes all but the keep most recently used archives of dir.
+func pruneCache(dir string, keep int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	type archive struct {
+		path string
+		info fs.FileInfo
+	}
+	var archives []archive
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".tar.gz") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		archives = append(archives, archive{filepath.Join(dir, entry.Name()), info})
+	}
+	sort.Slice(archives, func(i, j int) bool { return archives[i].info.ModTime().After(archives[j].info.ModTime()) })
+	for i := keep; i < len(archives); i++ {
+		_ = os.Remove(archives[i].path)
+	}
+}
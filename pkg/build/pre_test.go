@@ -0,0 +1,109 @@
+package build
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/local"
+)
+
+func TestPre(t *testing.T) {
+	path := t.TempDir()
+	cacheDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(path, "index.src"), []byte("hello"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(path, "node_modules"), 0755))
+
+	service := &config.Service{Name: "web", Path: path, Build: &config.Build{
+		Pre:    []string{"echo run >> runs.log", "mkdir -p dist/css && cp index.src dist/index.html && echo body > dist/css/site.css"},
+		Output: "dist",
+		// runs.log changes on every run, so it is not an input.
+		Inputs: []string{"index.src"},
+	}}
+	builder := NewBuild(local.NewRunner())
+	runs := func() string {
+		log, err := os.ReadFile(filepath.Join(path, "runs.log"))
+		require.NoError(t, err)
+		return string(log)
+	}
+
+	var lines []string
+	cached, err := builder.Pre(context.Background(), "project", service, cacheDir, func(line string) { lines = append(lines, line) })
+	require.NoError(t, err)
+	assert.False(t, cached)
+	assert.Contains(t, lines, "$ echo run >> runs.log")
+
+	require.NoError(t, os.RemoveAll(filepath.Join(path, "dist")))
+	require.NoError(t, os.WriteFile(filepath.Join(path, "node_modules", "dep.js"), []byte("changed"), 0644))
+	cached, err = builder.Pre(context.Background(), "project", service, cacheDir, nil)
+	require.NoError(t, err)
+	assert.True(t, cached, "unchanged inputs restore the output")
+	assert.Equal(t, "run\n", runs(), "the commands are skipped")
+	content, err := os.ReadFile(filepath.Join(path, "dist", "css", "site.css"))
+	require.NoError(t, err)
+	assert.Equal(t, "body\n", string(content))
+
+	require.NoError(t, os.WriteFile(filepath.Join(path, "index.src"), []byte("changed"), 0644))
+	cached, err = builder.Pre(context.Background(), "project", service, cacheDir, nil)
+	require.NoError(t, err)
+	assert.False(t, cached, "changed inputs run the commands again")
+	content, err = os.ReadFile(filepath.Join(path, "dist", "index.html"))
+	require.NoError(t, err)
+	assert.Equal(t, "changed", string(content))
+}
+
+func TestPre_Failure(t *testing.T) {
+	service := &config.Service{Name: "web", Path: t.TempDir(), Build: &config.Build{Pre: []string{"echo broken; exit 3"}, Output: "dist"}}
+
+	_, err := NewBuild(local.NewRunner()).Pre(context.Background(), "project", service, t.TempDir(), nil)
+	assert.ErrorContains(t, err, `pre-build command "echo broken; exit 3" failed`)
+	assert.ErrorContains(t, err, "broken")
+}
+
+func TestPreKey_DefaultInputs(t *testing.T) {
+	path := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(path, "app.js"), []byte("v1"), 0644))
+	service := &config.Service{Path: path, Build: &config.Build{Pre: []string{"npm run build"}, Output: "dist"}}
+
+	key, err := preKey(service)
+	require.NoError(t, err)
+
+	for _, dir := range []string{"dist", "node_modules", ".git"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(path, dir), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(path, dir, "file"), []byte(dir), 0644))
+	}
+	unchanged, err := preKey(service)
+	require.NoError(t, err)
+	assert.Equal(t, key, unchanged, "the output, node_modules and .git are not inputs")
+
+	service.Build.Pre = []string{"npm run build:prod"}
+	changed, err := preKey(service)
+	require.NoError(t, err)
+	assert.NotEqual(t, key, changed, "the commands are part of the key")
+}
+
+func TestPruneCache(t *testing.T) {
+	dir := t.TempDir()
+	for i, name := range []string{"a.tar.gz", "b.tar.gz", "c.tar.gz"} {
+		file := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(file, nil, 0644))
+		mtime := time.Now().Add(time.Duration(i) * time.Minute)
+		require.NoError(t, os.Chtimes(file, mtime, mtime))
+	}
+
+	pruneCache(dir, 2)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"b.tar.gz", "c.tar.gz"}, names)
+}
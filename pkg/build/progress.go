@@ -0,0 +1,46 @@
+package build
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var (
+	stepRegex   = regexp.MustCompile(`^#\d+ \[(?:\S+ )?(\d+/\d+)\] (.+)$`)
+	cachedRegex = regexp.MustCompile(`^#\d+ CACHED$`)
+	layerRegex  = regexp.MustCompile(`^#\d+ sha256:([0-9a-f]{12})[0-9a-f]* ([0-9.]+[kMG]?B / [0-9.]+[kMG]?B)`)
+)
+
+// Progress condenses plain BuildKit output into a one-line status with the
+// current step, layer download progress and the number of cache hits.
+type Progress struct {
+	step   string
+	cached int
+}
+
+// Update consumes a line of build output and returns the new status. It
+// returns false when the line does not change the status.
+func (p *Progress) Update(line string) (string, bool) {
+	switch {
+	case stepRegex.MatchString(line):
+		match := stepRegex.FindStringSubmatch(line)
+		p.step = fmt.Sprintf("step %s %s", match[1], match[2])
+	case cachedRegex.MatchString(line):
+		p.cached++
+	case layerRegex.MatchString(line):
+		match := layerRegex.FindStringSubmatch(line)
+		return fmt.Sprintf("downloading layer %s %s", match[1], match[2]), true
+	default:
+		return "", false
+	}
+
+	return p.String(), true
+}
+
+// String returns the current status.
+func (p *Progress) String() string {
+	if p.cached == 0 {
+		return p.step
+	}
+	return fmt.Sprintf("%s (%d cached)", p.step, p.cached)
+}
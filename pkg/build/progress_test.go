@@ -0,0 +1,30 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressUpdate(t *testing.T) {
+	var p Progress
+
+	status, ok := p.Update("#5 [builder 2/4] RUN go mod download")
+	assert.True(t, ok)
+	assert.Equal(t, "step 2/4 RUN go mod download", status)
+
+	status, ok = p.Update("#5 CACHED")
+	assert.True(t, ok)
+	assert.Equal(t, "step 2/4 RUN go mod download (1 cached)", status)
+
+	status, ok = p.Update("#3 sha256:4abcf20661432fb2d719aaf90656f55c287f8ca915dc1c92ec14ff61e67fbaf8 12.58MB / 27.14MB 0.6s")
+	assert.True(t, ok)
+	assert.Equal(t, "downloading layer 4abcf2066143 12.58MB / 27.14MB", status)
+
+	status, ok = p.Update("#6 [3/4] COPY . .")
+	assert.True(t, ok)
+	assert.Equal(t, "step 3/4 COPY . . (1 cached)", status)
+
+	_, ok = p.Update("#6 DONE 0.1s")
+	assert.False(t, ok)
+}
@@ -0,0 +1,86 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/docker"
+)
+
+// ReleasesDir is where ftl release create records releases, relative to the
+// directory of the configuration.
+const ReleasesDir = ".ftl/releases"
+
+// Release is a set of images built from one commit, recorded by digest so a
+// promotion deploys exactly what was built.
+type Release struct {
+	Commit string `json:"commit"`
+	// Images maps each service to its image by digest.
+	Images map[string]string `json:"images"`
+}
+
+// ReleaseTag returns image tagged with commit, as "ghcr.io/acme/web:1a2b3c4".
+func ReleaseTag(image, commit string) string {
+	return docker.Repository(image) + ":" + commit
+}
+
+// SaveRelease records release in dir.
+func SaveRelease(dir string, release *Release) error {
+	data, err := json.MarshalIndent(release, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode release: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create releases directory: %w", err)
+	}
+	if err := os.WriteFile(releasePath(dir, release.Commit), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write release: %w", err)
+	}
+	return nil
+}
+
+// LoadRelease reads the release of commit recorded in dir. The error wraps
+// os.ErrNotExist when there is none.
+func LoadRelease(dir, commit string) (*Release, error) {
+	data, err := os.ReadFile(releasePath(dir, commit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read release %s: %w", commit, err)
+	}
+
+	var release Release
+	if err := json.Unmarshal(data, &release); err != nil {
+		return nil, fmt.Errorf("failed to parse release %s: %w", commit, err)
+	}
+	return &release, nil
+}
+
+func releasePath(dir, commit string) string {
+	return filepath.Join(dir, commit+".json")
+}
+
+// RemoteDigest returns the reference by digest of image as its registry
+// serves it, without pulling it.
+func (b *Build) RemoteDigest(ctx context.Context, image string) (string, error) {
+	output, err := b.runner.RunCommand(ctx, "docker", "buildx", "imagetools", "inspect", "--format", "{{.Manifest.Digest}}", image)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s in its registry: %w", image, err)
+	}
+	defer output.Close()
+
+	data, err := io.ReadAll(output)
+	if err != nil {
+		return "", fmt.Errorf("failed to read output of docker buildx imagetools inspect: %w", err)
+	}
+
+	digest := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(digest, "sha256:") {
+		return "", fmt.Errorf("unexpected digest %q of image %s", digest, image)
+	}
+	return docker.Repository(image) + "@" + digest, nil
+}
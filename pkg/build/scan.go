@@ -0,0 +1,127 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/config"
+)
+
+// ScanOptions are passed to trivy image.
+type ScanOptions struct {
+	// Severities are the severities of the vulnerabilities to report.
+	Severities    []string
+	IgnoreUnfixed bool
+	// Report is the path the JSON report is written to.
+	Report string
+}
+
+// Vulnerability is a vulnerability Trivy found in an image.
+type Vulnerability struct {
+	ID       string `json:"VulnerabilityID"`
+	Package  string `json:"PkgName"`
+	Severity string `json:"Severity"`
+}
+
+// ScanResult lists the vulnerabilities of the reported severities found in an
+// image.
+type ScanResult struct {
+	Vulnerabilities []Vulnerability
+	Report          string
+}
+
+// Summary counts the vulnerabilities by severity, from the highest, e.g.
+// "2 CRITICAL, 5 HIGH".
+func (r *ScanResult) Summary() string {
+	counts := map[string]int{}
+	for _, vulnerability := range r.Vulnerabilities {
+		counts[vulnerability.Severity]++
+	}
+	var parts []string
+	for i := len(config.Severities) - 1; i >= 0; i-- {
+		if n := counts[config.Severities[i]]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, config.Severities[i]))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Scan checks image, which must be in the local Docker daemon or a registry,
+// for known vulnerabilities with the trivy command line and writes its JSON
+// report to opts.Report.
+func (b *Build) Scan(ctx context.Context, image string, opts ScanOptions) (*ScanResult, error) {
+	if err := os.MkdirAll(filepath.Dir(opts.Report), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	var output bytes.Buffer
+	if err := b.runner.RunCommandWithOutput(ctx, &output, "trivy", scanArgs(image, opts)...); err != nil {
+		return nil, fmt.Errorf("failed to scan %s with trivy (https://trivy.dev): %w\n%s", image, err, strings.TrimSpace(output.String()))
+	}
+
+	data, err := os.ReadFile(opts.Report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scan report: %w", err)
+	}
+	vulnerabilities, err := parseScanReport(data, opts.Severities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse scan report %s: %w", opts.Report, err)
+	}
+	return &ScanResult{Vulnerabilities: vulnerabilities, Report: opts.Report}, nil
+}
+
+func scanArgs(image string, opts ScanOptions) []string {
+	args := []string{
+		"image",
+		"--quiet",
+		"--format", "json",
+		"--output", opts.Report,
+		"--severity", strings.Join(opts.Severities, ","),
+		"--exit-code", "0",
+	}
+	if opts.IgnoreUnfixed {
+		args = append(args, "--ignore-unfixed")
+	}
+	return append(args, image)
+}
+
+// parseScanReport returns the vulnerabilities of severities in a Trivy JSON
+// report, each once even when several results list it.
+func parseScanReport(data []byte, severities []string) ([]Vulnerability, error) {
+	var report struct {
+		Results []struct {
+			Vulnerabilities []Vulnerability `json:"Vulnerabilities"`
+		} `json:"Results"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+
+	seen := map[Vulnerability]bool{}
+	var vulnerabilities []Vulnerability
+	for _, result := range report.Results {
+		for _, vulnerability := range result.Vulnerabilities {
+			if seen[vulnerability] || !slices.Contains(severities, vulnerability.Severity) {
+				continue
+			}
+			seen[vulnerability] = true
+			vulnerabilities = append(vulnerabilities, vulnerability)
+		}
+	}
+	return vulnerabilities, nil
+}
+
+// ServiceScanOptions returns the options service is scanned with under scan.
+func ServiceScanOptions(scan *config.Scan, service *config.Service) ScanOptions {
+	return ScanOptions{
+		Severities:    scan.CountedSeverities(),
+		IgnoreUnfixed: scan.IgnoreUnfixed,
+		Report:        filepath.Join(scan.ReportDir(), service.Name+".json"),
+	}
+}
@@ -0,0 +1,52 @@
+package build
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+const scanReport = `{
+  "Results": [
+    {"Target": "debian", "Vulnerabilities": [
+      {"VulnerabilityID": "CVE-2024-0001", "PkgName": "openssl", "Severity": "CRITICAL"},
+      {"VulnerabilityID": "CVE-2024-0002", "PkgName": "zlib", "Severity": "HIGH"},
+      {"VulnerabilityID": "CVE-2024-0003", "PkgName": "curl", "Severity": "LOW"}
+    ]},
+    {"Target": "app", "Vulnerabilities": [
+      {"VulnerabilityID": "CVE-2024-0002", "PkgName": "zlib", "Severity": "HIGH"},
+      {"VulnerabilityID": "CVE-2024-0004", "PkgName": "lodash", "Severity": "HIGH"}
+    ]},
+    {"Target": "clean"}
+  ]
+}`
+
+func TestScan(t *testing.T) {
+	scan := &config.Scan{Reports: t.TempDir(), IgnoreUnfixed: true}
+	opts := ServiceScanOptions(scan, &config.Service{Name: "web"})
+	assert.Equal(t, []string{"HIGH", "CRITICAL"}, opts.Severities)
+	require.NoError(t, os.WriteFile(opts.Report, []byte(scanReport), 0644))
+
+	runner := fake.NewRunner()
+	result, err := NewBuild(runner).Scan(context.Background(), "app-web", opts)
+	require.NoError(t, err)
+
+	assert.Equal(t, "trivy image --quiet --format json --output "+filepath.Join(scan.Reports, "web.json")+
+		" --severity HIGH,CRITICAL --exit-code 0 --ignore-unfixed app-web", runner.Calls()[0].String())
+	assert.Len(t, result.Vulnerabilities, 3, "vulnerabilities are counted once and below the threshold not at all")
+	assert.Equal(t, "1 CRITICAL, 2 HIGH", result.Summary())
+}
+
+func TestScan_Error(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("trivy", fake.Response{Output: "unable to find the image", Err: os.ErrNotExist})
+	_, err := NewBuild(runner).Scan(context.Background(), "app-web", ScanOptions{Report: filepath.Join(t.TempDir(), "web.json")})
+	assert.ErrorContains(t, err, "unable to find the image")
+}
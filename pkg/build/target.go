@@ -0,0 +1,91 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Target selects where images are built: the Docker daemon of a docker
+// context or at a host address, as in DOCKER_HOST, and the buildx builder.
+// The zero Target builds with the local daemon. With Sync, build contexts
+// are synced to an ssh:// Host and built there, instead of being streamed
+// to the daemon in full on every build.
+type Target struct {
+	Context string
+	Host    string
+	Builder string
+	Sync    bool
+}
+
+// Remote reports whether images are built on a Docker daemon other than the
+// local one, so they have to be copied back to be used locally.
+func (t Target) Remote() bool {
+	return t.Context != "" || t.Host != ""
+}
+
+// SetTarget makes the builds run on target.
+func (b *Build) SetTarget(target Target) {
+	b.target = target
+}
+
+// Target returns where images are built.
+func (b *Build) Target() Target {
+	return b.target
+}
+
+// dockerArgs prefixes the arguments of a docker command with the flags
+// selecting the daemon of the target.
+func (b *Build) dockerArgs(args ...string) []string {
+	switch {
+	case b.target.Context != "":
+		return append([]string{"--context", b.target.Context}, args...)
+	case b.target.Host != "":
+		return append([]string{"--host", b.target.Host}, args...)
+	}
+	return args
+}
+
+// multiPlatformBuilder returns the buildx builder multi-platform images are
+// built with: the builder of the target, or Builder.
+func (b *Build) multiPlatformBuilder() string {
+	if b.target.Builder != "" {
+		return b.target.Builder
+	}
+	return Builder
+}
+
+// Fetch copies image from the daemon of a remote target to the local one.
+// It does nothing when the target is local.
+func (b *Build) Fetch(ctx context.Context, image string) error {
+	if !b.target.Remote() {
+		return nil
+	}
+
+	save := exec.CommandContext(ctx, "docker", b.dockerArgs("save", image)...)
+	load := exec.CommandContext(ctx, "docker", "load")
+
+	var saveErr, loadErr strings.Builder
+	save.Stderr = &saveErr
+	load.Stderr = &loadErr
+	load.Stdout = io.Discard
+	pipe, err := save.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to copy image %s: %w", image, err)
+	}
+	load.Stdin = pipe
+
+	if err := load.Start(); err != nil {
+		return fmt.Errorf("failed to start docker load: %w", err)
+	}
+	if err := save.Run(); err != nil {
+		_ = load.Wait()
+		return fmt.Errorf("failed to save image %s on the builder: %w: %s", image, err, strings.TrimSpace(saveErr.String()))
+	}
+	if err := load.Wait(); err != nil {
+		return fmt.Errorf("failed to load image %s: %w: %s", image, err, strings.TrimSpace(loadErr.String()))
+	}
+	return nil
+}
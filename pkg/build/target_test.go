@@ -0,0 +1,42 @@
+package build
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestTarget(t *testing.T) {
+	runner := fake.NewRunner()
+	builder := NewBuild(runner)
+	builder.SetTarget(Target{Context: "build-box"})
+	assert.True(t, builder.Target().Remote())
+
+	require.NoError(t, builder.Build(context.Background(), "app:latest", "./web", Options{}, nil))
+	require.NoError(t, builder.Push(context.Background(), "app:latest"))
+
+	calls := runner.Calls()
+	require.Len(t, calls, 3)
+	for _, call := range calls {
+		assert.Equal(t, "docker", call.Command)
+		assert.Equal(t, []string{"--context", "build-box"}, call.Args[:2])
+	}
+	assert.Equal(t, []string{"--context", "build-box", "push", "app:latest"}, calls[2].Args)
+
+	builder.SetTarget(Target{Host: "ssh://builder@build.example.com"})
+	assert.Equal(t, []string{"--host", "ssh://builder@build.example.com", "push", "app:latest"}, builder.dockerArgs("push", "app:latest"))
+
+	builder.SetTarget(Target{Builder: "depot"})
+	assert.False(t, builder.Target().Remote())
+	assert.Equal(t, []string{"push", "app:latest"}, builder.dockerArgs("push", "app:latest"))
+	assert.Equal(t, "depot", builder.multiPlatformBuilder())
+}
+
+func TestBuildArgs_Builder(t *testing.T) {
+	args := buildArgs("app:latest", "./web", "depot", Options{}, "org.opencontainers.image.vendor=ftl")
+	assert.Equal(t, []string{"buildx", "build", "--builder", "depot", "--load"}, args[:5])
+}
@@ -0,0 +1,67 @@
+package build
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// TimingsFile is where ftl build records the seconds it spent building and
+// pushing images, keyed by phase, relative to the directory of the
+// configuration. The next deploy adds them to its duration breakdown and
+// removes the file.
+const TimingsFile = ".ftl/timings.json"
+
+// LoadTimings reads the timings recorded at path. A missing file has no
+// timings.
+func LoadTimings(path string) (map[string]float64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]float64{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read build timings: %w", err)
+	}
+
+	timings := map[string]float64{}
+	if err := json.Unmarshal(data, &timings); err != nil {
+		return nil, fmt.Errorf("failed to parse build timings %s: %w", path, err)
+	}
+	return timings, nil
+}
+
+// SaveTimings records timings at path, adding them to the timings of builds
+// that were not deployed yet.
+func SaveTimings(path string, timings map[string]float64) error {
+	recorded, err := LoadTimings(path)
+	if err != nil {
+		return err
+	}
+	for phase, seconds := range timings {
+		recorded[phase] += seconds
+	}
+
+	data, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode build timings: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for build timings: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write build timings: %w", err)
+	}
+	return nil
+}
+
+// ClearTimings removes the timings recorded at path once a deploy has
+// accounted for them.
+func ClearTimings(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove build timings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,373 @@
+// Package ci generates the pipelines that build and deploy a project with ftl
+// from GitHub Actions or GitLab CI.
+//
+// The pipelines run ftl without a terminal: server credentials come from the
+// FTL_SSH_KEY secret, the host key of the server from the committed
+// .ftl/known_hosts, and registries are logged in to with the short-lived
+// credentials of the CI system where the registry supports them.
+package ci
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/ssh"
+)
+
+// Provider is a CI system.
+type Provider string
+
+const (
+	GitHub Provider = "github"
+	GitLab Provider = "gitlab"
+)
+
+// Path returns where the pipeline of the provider lives, relative to the root
+// of the repository.
+func (p Provider) Path() string {
+	if p == GitLab {
+		return ".gitlab-ci.yml"
+	}
+	return ".github/workflows/deploy.yml"
+}
+
+// Options adjust the generated pipeline.
+type Options struct {
+	// Branch is the branch whose pushes are deployed, "main" unless set.
+	Branch string
+	// Environment is passed to ftl deploy with --env when set.
+	Environment string
+	// Variables are the environment variables ftl.yaml needs, passed to ftl
+	// from secrets of the same name.
+	Variables []string
+}
+
+// RegistryKind is the kind of a container registry, which decides how the
+// pipeline logs in to it.
+type RegistryKind string
+
+const (
+	RegistryGHCR      RegistryKind = "ghcr"
+	RegistryECR       RegistryKind = "ecr"
+	RegistryGAR       RegistryKind = "gar"
+	RegistryGitLab    RegistryKind = "gitlab"
+	RegistryDockerHub RegistryKind = "dockerhub"
+	RegistryOther     RegistryKind = "other"
+)
+
+// Registry is a registry ftl build pushes images to.
+type Registry struct {
+	Host string
+	Kind RegistryKind
+	// Region is the AWS region of an ECR registry.
+	Region string
+}
+
+// Registries returns the registries of the images of the services that are
+// built, in the order the services are listed.
+func Registries(cfg *config.Config) []Registry {
+	var registries []Registry
+	for _, service := range cfg.Services {
+		if service.Path == "" || service.Image == "" {
+			continue
+		}
+		registry := registryOf(service.Image)
+		if !slices.Contains(registries, registry) {
+			registries = append(registries, registry)
+		}
+	}
+	return registries
+}
+
+// registryOf returns the registry of an image reference. The first component
+// is a registry host when it looks like one, as Docker reads references.
+func registryOf(image string) Registry {
+	host, _, found := strings.Cut(image, "/")
+	if !found || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+		return Registry{Host: "docker.io", Kind: RegistryDockerHub}
+	}
+
+	switch {
+	case host == "ghcr.io":
+		return Registry{Host: host, Kind: RegistryGHCR}
+	case host == "registry.gitlab.com":
+		return Registry{Host: host, Kind: RegistryGitLab}
+	case host == "docker.io" || host == "index.docker.io":
+		return Registry{Host: "docker.io", Kind: RegistryDockerHub}
+	case strings.HasSuffix(host, ".amazonaws.com") && strings.Contains(host, ".dkr.ecr."):
+		// <account>.dkr.ecr.<region>.amazonaws.com
+		parts := strings.Split(host, ".")
+		return Registry{Host: host, Kind: RegistryECR, Region: parts[3]}
+	case strings.HasSuffix(host, "-docker.pkg.dev") || host == "gcr.io" || strings.HasSuffix(host, ".gcr.io"):
+		return Registry{Host: host, Kind: RegistryGAR}
+	default:
+		return Registry{Host: host, Kind: RegistryOther}
+	}
+}
+
+// setting is a secret or variable the pipeline expects to be configured.
+type setting struct {
+	name        string
+	description string
+}
+
+// Generate returns the pipeline that builds and deploys cfg with provider.
+func Generate(provider Provider, cfg *config.Config, opts Options) ([]byte, error) {
+	if opts.Branch == "" {
+		opts.Branch = "main"
+	}
+
+	registries := Registries(cfg)
+	switch provider {
+	case GitHub:
+		return []byte(github(cfg.Project.Name, registries, opts)), nil
+	case GitLab:
+		return []byte(gitlab(cfg.Project.Name, registries, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown CI provider %q, use %s or %s", provider, GitHub, GitLab)
+	}
+}
+
+// deployArgs returns the arguments of ftl deploy in the pipeline.
+func deployArgs(opts Options) string {
+	args := "deploy --yes"
+	if opts.Environment != "" {
+		args += " --env " + opts.Environment
+	}
+	return args
+}
+
+// writeSettings writes the settings as a comment block under title.
+func writeSettings(b *strings.Builder, title string, settings []setting) {
+	if len(settings) == 0 {
+		return
+	}
+	width := 0
+	for _, s := range settings {
+		width = max(width, len(s.name))
+	}
+	fmt.Fprintf(b, "#\n# %s:\n", title)
+	for _, s := range settings {
+		fmt.Fprintf(b, "#   %-*s  %s\n", width, s.name, s.description)
+	}
+}
+
+// appendSetting adds s to settings unless one of the same name is there.
+func appendSetting(settings []setting, s setting) []setting {
+	for _, existing := range settings {
+		if existing.name == s.name {
+			return settings
+		}
+	}
+	return append(settings, s)
+}
+
+const installScript = "curl -fsSL https://github.com/yarlson/ftl/releases/latest/download/ftl_$(uname -s)_$(uname -m).tar.gz | %star xz -C /usr/local/bin ftl"
+
+func github(project string, registries []Registry, opts Options) string {
+	secrets := []setting{{ssh.KeyEnv, "Private SSH key of the deploy user on the server"}}
+	var vars []setting
+	var logins strings.Builder
+	gcpAuth := false
+
+	for _, registry := range registries {
+		switch registry.Kind {
+		case RegistryGHCR:
+			logins.WriteString(`
+      - uses: docker/login-action@v3
+        with:
+          registry: ghcr.io
+          username: ${{ github.actor }}
+          password: ${{ secrets.GITHUB_TOKEN }}
+`)
+		case RegistryECR:
+			vars = appendSetting(vars, setting{"AWS_ROLE_ARN", "IAM role trusted for GitHub OIDC, allowed to push to ECR"})
+			fmt.Fprintf(&logins, `
+      - uses: aws-actions/configure-aws-credentials@v4
+        with:
+          role-to-assume: ${{ vars.AWS_ROLE_ARN }}
+          aws-region: %s
+
+      - uses: aws-actions/amazon-ecr-login@v2
+`, registry.Region)
+		case RegistryGAR:
+			vars = appendSetting(vars, setting{"GCP_WORKLOAD_IDENTITY_PROVIDER", "Workload identity provider trusted for GitHub OIDC"})
+			vars = appendSetting(vars, setting{"GCP_SERVICE_ACCOUNT", "Service account allowed to push to Artifact Registry"})
+			if !gcpAuth {
+				gcpAuth = true
+				logins.WriteString(`
+      - id: gcp
+        uses: google-github-actions/auth@v2
+        with:
+          workload_identity_provider: ${{ vars.GCP_WORKLOAD_IDENTITY_PROVIDER }}
+          service_account: ${{ vars.GCP_SERVICE_ACCOUNT }}
+          token_format: access_token
+`)
+			}
+			fmt.Fprintf(&logins, `
+      - uses: docker/login-action@v3
+        with:
+          registry: %s
+          username: oauth2accesstoken
+          password: ${{ steps.gcp.outputs.access_token }}
+`, registry.Host)
+		case RegistryDockerHub:
+			vars = appendSetting(vars, setting{"DOCKERHUB_USERNAME", "Docker Hub user"})
+			secrets = appendSetting(secrets, setting{"DOCKERHUB_TOKEN", "Docker Hub access token"})
+			logins.WriteString(`
+      - uses: docker/login-action@v3
+        with:
+          username: ${{ vars.DOCKERHUB_USERNAME }}
+          password: ${{ secrets.DOCKERHUB_TOKEN }}
+`)
+		default:
+			secrets = appendSetting(secrets, setting{"REGISTRY_USERNAME", "User of " + registry.Host})
+			secrets = appendSetting(secrets, setting{"REGISTRY_PASSWORD", "Password or token of " + registry.Host})
+			fmt.Fprintf(&logins, `
+      - uses: docker/login-action@v3
+        with:
+          registry: %s
+          username: ${{ secrets.REGISTRY_USERNAME }}
+          password: ${{ secrets.REGISTRY_PASSWORD }}
+`, registry.Host)
+		}
+	}
+	for _, name := range opts.Variables {
+		secrets = appendSetting(secrets, setting{name, "Referenced by ftl.yaml"})
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Deploys %s with ftl on every push to %s. Generated by ftl ci init.\n", project, opts.Branch)
+	writeSettings(&b, "Repository secrets", secrets)
+	writeSettings(&b, "Repository variables", vars)
+	b.WriteString(`#
+# Commit .ftl/known_hosts, written by ftl server trust, so the key of the
+# server is checked without a terminal to confirm it on.
+name: Deploy
+
+on:
+  push:
+`)
+	fmt.Fprintf(&b, "    branches: [%s]\n", opts.Branch)
+	b.WriteString(`  workflow_dispatch:
+
+permissions:
+  contents: read
+  id-token: write # OIDC login to cloud registries
+  packages: write # Push to ghcr.io
+
+concurrency:
+`)
+	fmt.Fprintf(&b, "  group: deploy-%s\n", project)
+	b.WriteString(`  cancel-in-progress: false
+
+jobs:
+  deploy:
+    runs-on: ubuntu-latest
+    env:
+`)
+	for _, name := range append([]string{ssh.KeyEnv}, opts.Variables...) {
+		fmt.Fprintf(&b, "      %s: ${{ secrets.%s }}\n", name, name)
+	}
+	b.WriteString(`    steps:
+      - uses: actions/checkout@v4
+
+      - uses: docker/setup-buildx-action@v3
+
+      # Lets buildx use the GitHub Actions cache, as with cache_from:
+      # [type=gha] and cache_to: ["type=gha,mode=max"] in ftl.yaml.
+      - uses: crazy-max/ghaction-github-runtime@v3
+`)
+	b.WriteString(logins.String())
+	fmt.Fprintf(&b, `
+      - name: Install ftl
+        run: %s
+
+      - run: ftl build
+
+      - run: ftl %s
+`, fmt.Sprintf(installScript, "sudo "), deployArgs(opts))
+	return b.String()
+}
+
+func gitlab(project string, registries []Registry, opts Options) string {
+	variables := []setting{{ssh.KeyEnv, "Private SSH key of the deploy user, base64 encoded so it can be masked"}}
+	packages := []string{"curl", "git"}
+	var logins []string
+	awsToken := false
+
+	for _, registry := range registries {
+		switch registry.Kind {
+		case RegistryGitLab:
+			logins = append(logins, `echo "$CI_REGISTRY_PASSWORD" | docker login -u "$CI_REGISTRY_USER" --password-stdin "$CI_REGISTRY"`)
+		case RegistryECR:
+			variables = appendSetting(variables, setting{"AWS_ROLE_ARN", "IAM role trusted for GitLab OIDC, allowed to push to ECR"})
+			if !awsToken {
+				awsToken = true
+				packages = append(packages, "aws-cli")
+				logins = append(logins,
+					`echo "$AWS_ID_TOKEN" > /tmp/aws-web-identity-token`,
+					`export AWS_WEB_IDENTITY_TOKEN_FILE=/tmp/aws-web-identity-token`,
+				)
+			}
+			logins = append(logins, fmt.Sprintf(`aws ecr get-login-password --region %s | docker login -u AWS --password-stdin %s`, registry.Region, registry.Host))
+		case RegistryGHCR:
+			variables = appendSetting(variables, setting{"GHCR_USERNAME", "GitHub user"})
+			variables = appendSetting(variables, setting{"GHCR_TOKEN", "GitHub token allowed to write packages"})
+			logins = append(logins, `echo "$GHCR_TOKEN" | docker login -u "$GHCR_USERNAME" --password-stdin ghcr.io`)
+		case RegistryDockerHub:
+			variables = appendSetting(variables, setting{"DOCKERHUB_USERNAME", "Docker Hub user"})
+			variables = appendSetting(variables, setting{"DOCKERHUB_TOKEN", "Docker Hub access token"})
+			logins = append(logins, `echo "$DOCKERHUB_TOKEN" | docker login -u "$DOCKERHUB_USERNAME" --password-stdin`)
+		default:
+			variables = appendSetting(variables, setting{"REGISTRY_USERNAME", "User of " + registry.Host})
+			variables = appendSetting(variables, setting{"REGISTRY_PASSWORD", "Password or token of " + registry.Host})
+			logins = append(logins, fmt.Sprintf(`echo "$REGISTRY_PASSWORD" | docker login -u "$REGISTRY_USERNAME" --password-stdin %s`, registry.Host))
+		}
+	}
+	for _, name := range opts.Variables {
+		variables = appendSetting(variables, setting{name, "Referenced by ftl.yaml"})
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Deploys %s with ftl on every push to %s. Generated by ftl ci init.\n", project, opts.Branch)
+	writeSettings(&b, "CI/CD variables", variables)
+	b.WriteString(`#
+# Commit .ftl/known_hosts, written by ftl server trust, so the key of the
+# server is checked without a terminal to confirm it on. Registry caches, as
+# cache_from: [type=registry,ref=...] in ftl.yaml, work with the buildx
+# builder created below.
+deploy:
+  stage: deploy
+  image: docker:27
+  services:
+    - docker:27-dind
+  variables:
+    DOCKER_TLS_CERTDIR: /certs
+`)
+	fmt.Fprintf(&b, "  resource_group: deploy-%s\n", project)
+	fmt.Fprintf(&b, "  rules:\n    - if: $CI_COMMIT_BRANCH == %q\n", opts.Branch)
+	if awsToken {
+		b.WriteString("  id_tokens:\n    AWS_ID_TOKEN:\n      aud: sts.amazonaws.com\n")
+	}
+	b.WriteString("  before_script:\n")
+	fmt.Fprintf(&b, "    - apk add --no-cache %s\n", strings.Join(packages, " "))
+	fmt.Fprintf(&b, "    - %s\n", fmt.Sprintf(installScript, ""))
+	b.WriteString("    - docker buildx create --use --driver docker-container\n")
+	for _, login := range logins {
+		fmt.Fprintf(&b, "    - %s\n", yamlScalar(login))
+	}
+	b.WriteString("  script:\n    - ftl build\n")
+	fmt.Fprintf(&b, "    - ftl %s\n", deployArgs(opts))
+	return b.String()
+}
+
+// yamlScalar quotes a script line that YAML would not read as a plain string.
+func yamlScalar(line string) string {
+	if strings.ContainsAny(line[:1], `"'&*!|>%@`+"`") || strings.Contains(line, ": ") || strings.Contains(line, " #") {
+		return "'" + strings.ReplaceAll(line, "'", "''") + "'"
+	}
+	return line
+}
@@ -0,0 +1,130 @@
+package ci
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/yarlson/ftl/pkg/config"
+)
+
+func TestRegistryOf(t *testing.T) {
+	tests := []struct {
+		image string
+		want  Registry
+	}{
+		{"acme/web", Registry{Host: "docker.io", Kind: RegistryDockerHub}},
+		{"web:1.0", Registry{Host: "docker.io", Kind: RegistryDockerHub}},
+		{"docker.io/acme/web", Registry{Host: "docker.io", Kind: RegistryDockerHub}},
+		{"ghcr.io/acme/web:latest", Registry{Host: "ghcr.io", Kind: RegistryGHCR}},
+		{"registry.gitlab.com/acme/web", Registry{Host: "registry.gitlab.com", Kind: RegistryGitLab}},
+		{"123456789012.dkr.ecr.eu-west-1.amazonaws.com/web", Registry{Host: "123456789012.dkr.ecr.eu-west-1.amazonaws.com", Kind: RegistryECR, Region: "eu-west-1"}},
+		{"europe-west1-docker.pkg.dev/acme/images/web", Registry{Host: "europe-west1-docker.pkg.dev", Kind: RegistryGAR}},
+		{"registry.example.com:5000/web", Registry{Host: "registry.example.com:5000", Kind: RegistryOther}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			assert.Equal(t, tt.want, registryOf(tt.image))
+		})
+	}
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Project: config.Project{Name: "app"},
+		Services: []config.Service{
+			{Name: "web", Path: "./web", Image: "ghcr.io/acme/web"},
+			{Name: "api", Path: "./api", Image: "123456789012.dkr.ecr.eu-west-1.amazonaws.com/api"},
+			{Name: "worker", Path: "./api", Image: "ghcr.io/acme/worker"},
+			{Name: "local", Path: "./local"},
+			{Name: "cache", Image: "registry.example.com/cache"},
+		},
+	}
+}
+
+func TestRegistries(t *testing.T) {
+	assert.Equal(t, []Registry{
+		{Host: "ghcr.io", Kind: RegistryGHCR},
+		{Host: "123456789012.dkr.ecr.eu-west-1.amazonaws.com", Kind: RegistryECR, Region: "eu-west-1"},
+	}, Registries(testConfig()))
+}
+
+func TestGenerate_GitHub(t *testing.T) {
+	data, err := Generate(GitHub, testConfig(), Options{Environment: "production", Variables: []string{"API_TOKEN"}})
+	require.NoError(t, err)
+
+	var workflow struct {
+		On struct {
+			Push struct {
+				Branches []string `yaml:"branches"`
+			} `yaml:"push"`
+		} `yaml:"on"`
+		Permissions map[string]string `yaml:"permissions"`
+		Jobs        map[string]struct {
+			Env   map[string]string `yaml:"env"`
+			Steps []struct {
+				Uses string            `yaml:"uses"`
+				Run  string            `yaml:"run"`
+				With map[string]string `yaml:"with"`
+			} `yaml:"steps"`
+		} `yaml:"jobs"`
+	}
+	require.NoError(t, yaml.Unmarshal(data, &workflow))
+
+	assert.Equal(t, []string{"main"}, workflow.On.Push.Branches)
+	assert.Equal(t, "write", workflow.Permissions["id-token"])
+
+	job := workflow.Jobs["deploy"]
+	assert.Equal(t, map[string]string{
+		"FTL_SSH_KEY": "${{ secrets.FTL_SSH_KEY }}",
+		"API_TOKEN":   "${{ secrets.API_TOKEN }}",
+	}, job.Env)
+
+	var uses, runs []string
+	for _, step := range job.Steps {
+		if step.Uses != "" {
+			uses = append(uses, step.Uses)
+		}
+		if step.Run != "" {
+			runs = append(runs, step.Run)
+		}
+	}
+	assert.Equal(t, []string{
+		"actions/checkout@v4",
+		"docker/setup-buildx-action@v3",
+		"crazy-max/ghaction-github-runtime@v3",
+		"docker/login-action@v3",
+		"aws-actions/configure-aws-credentials@v4",
+		"aws-actions/amazon-ecr-login@v2",
+	}, uses)
+	assert.Equal(t, "ftl deploy --yes --env production", runs[len(runs)-1])
+	assert.Contains(t, string(data), "#   AWS_ROLE_ARN  IAM role trusted for GitHub OIDC")
+}
+
+func TestGenerate_GitLab(t *testing.T) {
+	data, err := Generate(GitLab, testConfig(), Options{Branch: "release"})
+	require.NoError(t, err)
+
+	var pipeline map[string]struct {
+		Rules        []map[string]string `yaml:"rules"`
+		IDTokens     map[string]any      `yaml:"id_tokens"`
+		BeforeScript []string            `yaml:"before_script"`
+		Script       []string            `yaml:"script"`
+	}
+	require.NoError(t, yaml.Unmarshal(data, &pipeline))
+
+	job := pipeline["deploy"]
+	assert.Equal(t, `$CI_COMMIT_BRANCH == "release"`, job.Rules[0]["if"])
+	assert.Contains(t, job.IDTokens, "AWS_ID_TOKEN")
+	assert.Contains(t, job.BeforeScript, `echo "$GHCR_TOKEN" | docker login -u "$GHCR_USERNAME" --password-stdin ghcr.io`)
+	assert.Contains(t, job.BeforeScript, "aws ecr get-login-password --region eu-west-1 | docker login -u AWS --password-stdin 123456789012.dkr.ecr.eu-west-1.amazonaws.com")
+	assert.Equal(t, []string{"ftl build", "ftl deploy --yes"}, job.Script)
+}
+
+func TestGenerate_UnknownProvider(t *testing.T) {
+	_, err := Generate("jenkins", testConfig(), Options{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,166 @@
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Defaults of AWS EC2 instances. The region defaults to the one configured
+// for the AWS CLI.
+const (
+	awsDefaultSize = "t3.small"
+	// awsDefaultImage is the SSM parameter holding the current Ubuntu 24.04 AMI.
+	awsDefaultImage = "resolve:ssm:/aws/service/canonical/ubuntu/server/24.04/stable/current/amd64/hvm/ebs-gp3/ami-id"
+)
+
+// awsUserData lets root log in with the instance key, which Ubuntu AMIs refuse
+// by default. ftl setup connects as root.
+const awsUserData = `#cloud-config
+disable_root: false
+`
+
+// Runner runs commands on the local machine.
+type Runner interface {
+	RunCommand(ctx context.Context, command string, args ...string) (io.ReadCloser, error)
+}
+
+// AWS creates EC2 instances with the AWS CLI, so credentials, profiles and
+// SSO work the way they do for the aws command.
+type AWS struct {
+	runner Runner
+}
+
+// NewAWS returns an AWS provider that runs the aws command with runner.
+func NewAWS(runner Runner) *AWS {
+	return &AWS{runner: runner}
+}
+
+func newAWSFromEnv() (Provider, error) {
+	return NewAWS(awsCLI{}), nil
+}
+
+// Create imports the SSH key, creates a security group that allows SSH, HTTP
+// and HTTPS, and starts the instance with both. When a step fails, what the
+// earlier steps created is deleted again.
+func (a *AWS) Create(ctx context.Context, spec Spec) (server *Server, err error) {
+	var region []string
+	if spec.Region != "" {
+		region = []string{"--region", spec.Region}
+	}
+
+	// rollback lists the commands that delete what was created, in the order
+	// they run.
+	var rollback [][]string
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(rollback) - 1; i >= 0; i-- {
+			if _, undoErr := a.aws(context.Background(), region, rollback[i]...); undoErr != nil {
+				err = fmt.Errorf("%w (failed to clean up with aws %s: %v)", err, strings.Join(rollback[i][:2], " "), undoErr)
+			}
+		}
+	}()
+
+	if _, err := a.aws(ctx, region, "ec2", "import-key-pair",
+		"--key-name", spec.Name,
+		"--public-key-material", base64.StdEncoding.EncodeToString([]byte(spec.SSHKey)),
+	); err != nil {
+		return nil, fmt.Errorf("failed to import ssh key: %w", err)
+	}
+	rollback = append(rollback, []string{"ec2", "delete-key-pair", "--key-name", spec.Name})
+
+	groupID, err := a.aws(ctx, region, "ec2", "create-security-group",
+		"--group-name", spec.Name,
+		"--description", "ftl server "+spec.Name,
+		"--query", "GroupId", "--output", "text",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create security group: %w", err)
+	}
+	rollback = append(rollback, []string{"ec2", "delete-security-group", "--group-id", groupID})
+
+	for _, port := range []string{"22", "80", "443"} {
+		if _, err := a.aws(ctx, region, "ec2", "authorize-security-group-ingress",
+			"--group-id", groupID, "--protocol", "tcp", "--port", port, "--cidr", "0.0.0.0/0",
+		); err != nil {
+			return nil, fmt.Errorf("failed to open port %s: %w", port, err)
+		}
+	}
+
+	instanceID, err := a.aws(ctx, region, "ec2", "run-instances",
+		"--image-id", valueOr(spec.Image, awsDefaultImage),
+		"--instance-type", valueOr(spec.Size, awsDefaultSize),
+		"--key-name", spec.Name,
+		"--security-group-ids", groupID,
+		"--user-data", awsUserData,
+		"--tag-specifications", fmt.Sprintf("ResourceType=instance,Tags=[{Key=Name,Value=%s}]", spec.Name),
+		"--query", "Instances[0].InstanceId", "--output", "text",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run instance: %w", err)
+	}
+	// The security group can only be deleted once the instance is gone.
+	rollback = append(rollback,
+		[]string{"ec2", "wait", "instance-terminated", "--instance-ids", instanceID},
+		[]string{"ec2", "terminate-instances", "--instance-ids", instanceID},
+	)
+
+	if _, err := a.aws(ctx, region, "ec2", "wait", "instance-running", "--instance-ids", instanceID); err != nil {
+		return nil, fmt.Errorf("instance %s did not start: %w", instanceID, err)
+	}
+
+	output, err := a.aws(ctx, region, "ec2", "describe-instances",
+		"--instance-ids", instanceID,
+		"--query", "Reservations[0].Instances[0].PublicIpAddress", "--output", "json",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe instance %s: %w", instanceID, err)
+	}
+	var ip string
+	if err := json.Unmarshal([]byte(output), &ip); err != nil || ip == "" {
+		return nil, fmt.Errorf("instance %s has no public IP address", instanceID)
+	}
+
+	return &Server{ID: instanceID, IP: ip}, nil
+}
+
+// aws runs the aws command and returns its trimmed output.
+func (a *AWS) aws(ctx context.Context, region []string, args ...string) (string, error) {
+	output, err := a.runner.RunCommand(ctx, "aws", append(args, region...)...)
+	if err != nil {
+		return "", err
+	}
+	defer output.Close()
+
+	data, err := io.ReadAll(output)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// awsCLI runs the aws command on the local machine. Only its standard output
+// is parsed: the CLI writes warnings, such as about an outdated Python, to
+// standard error, which is added to the error of a failed command instead.
+type awsCLI struct{}
+
+func (awsCLI) RunCommand(ctx context.Context, command string, args ...string) (io.ReadCloser, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%w: %s", err, message)
+		}
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(output)), nil
+}
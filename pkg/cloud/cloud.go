@@ -0,0 +1,145 @@
+// Package cloud creates servers with cloud providers, ready for ftl setup.
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Spec describes the server to create. Empty fields use the provider's
+// defaults.
+type Spec struct {
+	Name   string
+	Region string
+	Size   string
+	Image  string
+	// SSHKey is the public key, in authorized_keys format, that is allowed to
+	// log in as root.
+	SSHKey string
+}
+
+// Server is a created server.
+type Server struct {
+	ID string
+	IP string
+}
+
+// Provider creates servers. Create returns once the server is running and
+// has a public address.
+type Provider interface {
+	Create(ctx context.Context, spec Spec) (*Server, error)
+}
+
+// pollInterval is how often the state of a server is checked while it starts.
+var pollInterval = 5 * time.Second
+
+// providers creates the supported providers, configured from the environment.
+var providers = map[string]func() (Provider, error){
+	"hetzner":      newHetznerFromEnv,
+	"digitalocean": newDigitalOceanFromEnv,
+	"aws":          newAWSFromEnv,
+}
+
+// Providers returns the names of the supported providers.
+func Providers() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns the provider with the given name. Credentials are read from the
+// environment: HCLOUD_TOKEN for Hetzner, DIGITALOCEAN_TOKEN for DigitalOcean
+// and the AWS CLI configuration for AWS.
+func New(name string) (Provider, error) {
+	newProvider, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q, available providers: %s", name, strings.Join(Providers(), ", "))
+	}
+	return newProvider()
+}
+
+// tokenFromEnv returns the API token in the environment variable name.
+func tokenFromEnv(name string) (string, error) {
+	token := os.Getenv(name)
+	if token == "" {
+		return "", fmt.Errorf("%s is not set", name)
+	}
+	return token, nil
+}
+
+// WaitForSSH waits until the SSH port of host accepts connections.
+func WaitForSSH(ctx context.Context, host string, port int) error {
+	address := net.JoinHostPort(host, fmt.Sprint(port))
+	for {
+		conn, err := (&net.Dialer{Timeout: 5 * time.Second}).DialContext(ctx, "tcp", address)
+		if err == nil {
+			return conn.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("ssh on %s did not become reachable: %w", address, ctx.Err())
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// apiClient calls the JSON API of a provider with a bearer token.
+type apiClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// do sends body as JSON and decodes the response into out, when it is not nil.
+func (c *apiClient) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response of %s %s: %w", method, path, err)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s failed with status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response of %s %s: %w", method, path, err)
+	}
+	return nil
+}
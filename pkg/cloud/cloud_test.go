@@ -0,0 +1,172 @@
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func init() {
+	pollInterval = time.Millisecond
+}
+
+var testSpec = Spec{Name: "my-project", SSHKey: "ssh-ed25519 AAAA test"}
+
+func TestNew(t *testing.T) {
+	t.Setenv("HCLOUD_TOKEN", "")
+	_, err := New("hetzner")
+	assert.EqualError(t, err, "HCLOUD_TOKEN is not set")
+
+	_, err = New("linode")
+	assert.EqualError(t, err, `unknown provider "linode", available providers: aws, digitalocean, hetzner`)
+}
+
+func TestHetznerCreate(t *testing.T) {
+	var polls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		var body map[string]any
+		if r.Method == http.MethodPost {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		}
+
+		switch r.Method + " " + r.URL.Path {
+		case "POST /ssh_keys":
+			assert.Equal(t, testSpec.SSHKey, body["public_key"])
+			w.Write([]byte(`{"ssh_key": {"id": 7}}`))
+		case "POST /servers":
+			assert.Equal(t, "cx22", body["server_type"])
+			assert.Equal(t, "fsn1", body["location"])
+			assert.Equal(t, []any{float64(7)}, body["ssh_keys"])
+			w.Write([]byte(`{"server": {"id": 42, "status": "initializing"}}`))
+		case "GET /servers/42":
+			polls++
+			w.Write([]byte(`{"server": {"id": 42, "status": "running", "public_net": {"ipv4": {"ip": "203.0.113.10"}}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewHetzner("token")
+	provider.api.baseURL = server.URL
+
+	created, err := provider.Create(context.Background(), testSpec)
+	require.NoError(t, err)
+	assert.Equal(t, &Server{ID: "42", IP: "203.0.113.10"}, created)
+	assert.Equal(t, 1, polls)
+}
+
+func TestDigitalOceanCreate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /account/keys":
+			w.Write([]byte(`{"ssh_key": {"id": 7}}`))
+		case "POST /droplets":
+			w.Write([]byte(`{"droplet": {"id": 42, "status": "new"}}`))
+		case "GET /droplets/42":
+			w.Write([]byte(`{"droplet": {"id": 42, "status": "active", "networks": {"v4": [
+				{"ip_address": "10.0.0.2", "type": "private"},
+				{"ip_address": "203.0.113.10", "type": "public"}
+			]}}}`))
+		default:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"message": "invalid"}`))
+		}
+	}))
+	defer server.Close()
+
+	provider := NewDigitalOcean("token")
+	provider.api.baseURL = server.URL
+
+	created, err := provider.Create(context.Background(), testSpec)
+	require.NoError(t, err)
+	assert.Equal(t, &Server{ID: "42", IP: "203.0.113.10"}, created)
+
+	provider.api.baseURL = server.URL + "/missing"
+	_, err = provider.Create(context.Background(), testSpec)
+	assert.ErrorContains(t, err, `failed to upload ssh key: POST /account/keys failed with status 422: {"message": "invalid"}`)
+}
+
+func TestAWSCreate(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("aws ec2 create-security-group", fake.Response{Output: "sg-123\n"})
+	runner.On("aws ec2 run-instances", fake.Response{Output: "i-456\n"})
+	runner.On("aws ec2 describe-instances", fake.Response{Output: `"203.0.113.10"`})
+
+	spec := testSpec
+	spec.Region = "eu-central-1"
+	created, err := NewAWS(runner).Create(context.Background(), spec)
+	require.NoError(t, err)
+	assert.Equal(t, &Server{ID: "i-456", IP: "203.0.113.10"}, created)
+
+	var commands []string
+	for _, call := range runner.Calls() {
+		commands = append(commands, strings.Join(call.Args[:2], " "))
+		assert.Equal(t, []string{"--region", "eu-central-1"}, call.Args[len(call.Args)-2:])
+	}
+	assert.Equal(t, []string{
+		"ec2 import-key-pair",
+		"ec2 create-security-group",
+		"ec2 authorize-security-group-ingress",
+		"ec2 authorize-security-group-ingress",
+		"ec2 authorize-security-group-ingress",
+		"ec2 run-instances",
+		"ec2 wait",
+		"ec2 describe-instances",
+	}, commands)
+	assert.Contains(t, runner.Calls()[5].String(), "--security-group-ids sg-123")
+
+	runner.On("aws ec2 describe-instances", fake.Response{Output: "null"})
+	_, err = NewAWS(runner).Create(context.Background(), spec)
+	assert.EqualError(t, err, "instance i-456 has no public IP address")
+}
+
+func TestAWSCreate_Rollback(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("aws ec2 create-security-group", fake.Response{Output: "sg-123\n"})
+	runner.On("aws ec2 run-instances", fake.Response{Err: errors.New("InstanceLimitExceeded")})
+
+	_, err := NewAWS(runner).Create(context.Background(), testSpec)
+	assert.EqualError(t, err, "failed to run instance: InstanceLimitExceeded")
+
+	calls := runner.Calls()
+	require.Len(t, calls, 8)
+	assert.Equal(t, "aws ec2 delete-security-group --group-id sg-123", calls[6].String())
+	assert.Equal(t, "aws ec2 delete-key-pair --key-name "+testSpec.Name, calls[7].String(), "what was created is deleted in reverse order")
+
+	// A started instance is terminated before its security group is deleted.
+	runner.Reset()
+	runner.On("aws ec2 create-security-group", fake.Response{Output: "sg-123\n"})
+	runner.On("aws ec2 run-instances", fake.Response{Output: "i-456\n"})
+	runner.On("aws ec2 wait instance-running", fake.Response{Err: errors.New("Waiter InstanceRunning failed")})
+	_, err = NewAWS(runner).Create(context.Background(), testSpec)
+	assert.ErrorContains(t, err, "instance i-456 did not start")
+	calls = runner.Calls()
+	require.Len(t, calls, 11)
+	assert.Equal(t, "aws ec2 terminate-instances --instance-ids i-456", calls[7].String())
+	assert.Equal(t, "aws ec2 wait instance-terminated --instance-ids i-456", calls[8].String())
+	assert.Equal(t, "aws ec2 delete-security-group --group-id sg-123", calls[9].String())
+}
+
+func TestAWSCLI(t *testing.T) {
+	output, err := awsCLI{}.RunCommand(context.Background(), "sh", "-c", "echo sg-123; echo 'Python 3.8 is deprecated' >&2")
+	require.NoError(t, err)
+	data, err := io.ReadAll(output)
+	require.NoError(t, err)
+	assert.Equal(t, "sg-123\n", string(data), "warnings on standard error are not parsed")
+
+	_, err = awsCLI{}.RunCommand(context.Background(), "sh", "-c", "echo 'An error occurred (UnauthorizedOperation)' >&2; exit 254")
+	assert.EqualError(t, err, "exit status 254: An error occurred (UnauthorizedOperation)")
+}
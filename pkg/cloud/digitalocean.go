@@ -0,0 +1,105 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Defaults of DigitalOcean droplets.
+const (
+	digitalOceanDefaultRegion = "fra1"
+	digitalOceanDefaultSize   = "s-1vcpu-1gb"
+	digitalOceanDefaultImage  = "ubuntu-24-04-x64"
+)
+
+// DigitalOcean creates droplets with the DigitalOcean API.
+type DigitalOcean struct {
+	api apiClient
+}
+
+// NewDigitalOcean returns a DigitalOcean provider that authenticates with token.
+func NewDigitalOcean(token string) *DigitalOcean {
+	return &DigitalOcean{api: apiClient{
+		baseURL: "https://api.digitalocean.com/v2",
+		token:   token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}}
+}
+
+func newDigitalOceanFromEnv() (Provider, error) {
+	token, err := tokenFromEnv("DIGITALOCEAN_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	return NewDigitalOcean(token), nil
+}
+
+type droplet struct {
+	ID       int64  `json:"id"`
+	Status   string `json:"status"`
+	Networks struct {
+		V4 []struct {
+			IPAddress string `json:"ip_address"`
+			Type      string `json:"type"`
+		} `json:"v4"`
+	} `json:"networks"`
+}
+
+func (d droplet) publicIP() string {
+	for _, network := range d.Networks.V4 {
+		if network.Type == "public" {
+			return network.IPAddress
+		}
+	}
+	return ""
+}
+
+// Create uploads the SSH key and creates the droplet with it.
+func (d *DigitalOcean) Create(ctx context.Context, spec Spec) (*Server, error) {
+	var key struct {
+		SSHKey struct {
+			ID int64 `json:"id"`
+		} `json:"ssh_key"`
+	}
+	if err := d.api.do(ctx, http.MethodPost, "/account/keys", map[string]any{
+		"name":       spec.Name,
+		"public_key": spec.SSHKey,
+	}, &key); err != nil {
+		return nil, fmt.Errorf("failed to upload ssh key: %w", err)
+	}
+
+	var created struct {
+		Droplet droplet `json:"droplet"`
+	}
+	if err := d.api.do(ctx, http.MethodPost, "/droplets", map[string]any{
+		"name":     spec.Name,
+		"region":   valueOr(spec.Region, digitalOceanDefaultRegion),
+		"size":     valueOr(spec.Size, digitalOceanDefaultSize),
+		"image":    valueOr(spec.Image, digitalOceanDefaultImage),
+		"ssh_keys": []int64{key.SSHKey.ID},
+	}, &created); err != nil {
+		return nil, fmt.Errorf("failed to create droplet: %w", err)
+	}
+
+	current := created.Droplet
+	for current.Status != "active" || current.publicIP() == "" {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("droplet %d did not start: %w", current.ID, ctx.Err())
+		case <-time.After(pollInterval):
+		}
+
+		var polled struct {
+			Droplet droplet `json:"droplet"`
+		}
+		if err := d.api.do(ctx, http.MethodGet, fmt.Sprintf("/droplets/%d", created.Droplet.ID), nil, &polled); err != nil {
+			return nil, fmt.Errorf("failed to get droplet status: %w", err)
+		}
+		current = polled.Droplet
+	}
+
+	return &Server{ID: strconv.FormatInt(current.ID, 10), IP: current.publicIP()}, nil
+}
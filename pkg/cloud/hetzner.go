@@ -0,0 +1,102 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Defaults of Hetzner Cloud servers.
+const (
+	hetznerDefaultRegion = "fsn1"
+	hetznerDefaultSize   = "cx22"
+	hetznerDefaultImage  = "ubuntu-24.04"
+)
+
+// Hetzner creates servers with the Hetzner Cloud API.
+type Hetzner struct {
+	api apiClient
+}
+
+// NewHetzner returns a Hetzner provider that authenticates with token.
+func NewHetzner(token string) *Hetzner {
+	return &Hetzner{api: apiClient{
+		baseURL: "https://api.hetzner.cloud/v1",
+		token:   token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}}
+}
+
+func newHetznerFromEnv() (Provider, error) {
+	token, err := tokenFromEnv("HCLOUD_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	return NewHetzner(token), nil
+}
+
+type hetznerServer struct {
+	ID        int64  `json:"id"`
+	Status    string `json:"status"`
+	PublicNet struct {
+		IPv4 struct {
+			IP string `json:"ip"`
+		} `json:"ipv4"`
+	} `json:"public_net"`
+}
+
+// Create uploads the SSH key and creates the server with it.
+func (h *Hetzner) Create(ctx context.Context, spec Spec) (*Server, error) {
+	var key struct {
+		SSHKey struct {
+			ID int64 `json:"id"`
+		} `json:"ssh_key"`
+	}
+	if err := h.api.do(ctx, http.MethodPost, "/ssh_keys", map[string]any{
+		"name":       spec.Name,
+		"public_key": spec.SSHKey,
+	}, &key); err != nil {
+		return nil, fmt.Errorf("failed to upload ssh key: %w", err)
+	}
+
+	var created struct {
+		Server hetznerServer `json:"server"`
+	}
+	if err := h.api.do(ctx, http.MethodPost, "/servers", map[string]any{
+		"name":        spec.Name,
+		"location":    valueOr(spec.Region, hetznerDefaultRegion),
+		"server_type": valueOr(spec.Size, hetznerDefaultSize),
+		"image":       valueOr(spec.Image, hetznerDefaultImage),
+		"ssh_keys":    []int64{key.SSHKey.ID},
+	}, &created); err != nil {
+		return nil, fmt.Errorf("failed to create server: %w", err)
+	}
+
+	server := created.Server
+	for server.Status != "running" {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("server %d did not start: %w", server.ID, ctx.Err())
+		case <-time.After(pollInterval):
+		}
+
+		var current struct {
+			Server hetznerServer `json:"server"`
+		}
+		if err := h.api.do(ctx, http.MethodGet, fmt.Sprintf("/servers/%d", server.ID), nil, &current); err != nil {
+			return nil, fmt.Errorf("failed to get server status: %w", err)
+		}
+		server = current.Server
+	}
+
+	return &Server{ID: strconv.FormatInt(server.ID, 10), IP: server.PublicNet.IPv4.IP}, nil
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
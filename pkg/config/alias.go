@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Aliases give a service or dependency stable names on its networks, apart
+// from the names of its containers:
+//
+//	dependencies:
+//	  - name: postgres
+//	    image: postgres:16
+//	    aliases: [db, db.internal]
+//
+// Every service and dependency then gets the first alias in an environment
+// variable named after the one it belongs to, FTL_POSTGRES_HOST=db here, so
+// code can find it without hardcoding the name.
+const aliasEnvPrefix = "FTL_"
+
+// invalidEnvChars matches the characters of a name an environment variable
+// name cannot hold.
+var invalidEnvChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// AliasEnvName returns the environment variable holding the first alias of
+// the service or dependency called name, such as FTL_POSTGRES_HOST.
+func AliasEnvName(name string) string {
+	return aliasEnvPrefix + invalidEnvChars.ReplaceAllString(strings.ToUpper(name), "_") + "_HOST"
+}
+
+// validateAliases checks that no alias is taken by another service,
+// dependency or alias.
+func (c *Config) validateAliases() error {
+	owners := map[string]string{}
+	for _, service := range c.Services {
+		owners[service.Name] = "service " + service.Name
+	}
+	for _, dependency := range c.Dependencies {
+		owners[dependency.Name] = "dependency " + dependency.Name
+	}
+
+	claim := func(owner string, aliases []string) error {
+		for _, alias := range aliases {
+			if other, ok := owners[alias]; ok {
+				return fmt.Errorf("%s: alias %q is already taken by %s", owner, alias, other)
+			}
+			owners[alias] = owner
+		}
+		return nil
+	}
+	for _, service := range c.Services {
+		if err := claim("service "+service.Name, service.Aliases); err != nil {
+			return err
+		}
+	}
+	for _, dependency := range c.Dependencies {
+		if err := claim("dependency "+dependency.Name, dependency.Aliases); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aliasEnv returns the variables holding the first alias of every service and
+// dependency that has one.
+func (c *Config) aliasEnv() []string {
+	var env []string
+	for _, service := range c.Services {
+		if len(service.Aliases) > 0 {
+			env = append(env, AliasEnvName(service.Name)+"="+service.Aliases[0])
+		}
+	}
+	for _, dependency := range c.Dependencies {
+		if len(dependency.Aliases) > 0 {
+			env = append(env, AliasEnvName(dependency.Name)+"="+dependency.Aliases[0])
+		}
+	}
+	return env
+}
+
+// injectAliasEnv adds the alias variables to every service and dependency.
+// Variables they set themselves are kept.
+func (c *Config) injectAliasEnv() {
+	env := c.aliasEnv()
+	if len(env) == 0 {
+		return
+	}
+	for i := range c.Services {
+		c.Services[i].Env = mergeEnv(env, c.Services[i].Env)
+	}
+	for i := range c.Dependencies {
+		c.Dependencies[i].Env = mergeEnv(env, c.Dependencies[i].Env)
+	}
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"errors"
+	"strings"
+)
+
+// Builder selects where ftl build and ftl release create build images,
+// apart from the server they are deployed to:
+//
+//	builder:
+//	  context: build-box
+//
+// Context is a docker context and Host a Docker daemon address, as in
+// DOCKER_HOST, such as "ssh://builder@build.example.com"; only one of them
+// may be set. Buildx is a buildx builder, such as one created by Depot or for
+// a remote BuildKit, that builds run on instead of the daemon. Images built
+// on a remote daemon that are not pushed to a registry are copied back to
+// the local daemon, so ftl deploy can transfer them to the server.
+//
+// Sync keeps a copy of every build context on an ssh:// Host and builds from
+// it there, so only the files that changed since the last build are uploaded
+// instead of the whole context.
+type Builder struct {
+	Context string `yaml:"context" validate:"omitempty,excluded_with=Host"`
+	Host    string `yaml:"host" validate:"omitempty,excluded_with=Context,url"`
+	Buildx  string `yaml:"buildx"`
+	Sync    bool   `yaml:"sync"`
+}
+
+// validateBuilder checks that a synced build context has an SSH host to be
+// synced to.
+func (c *Config) validateBuilder() error {
+	if c.Builder == nil || !c.Builder.Sync {
+		return nil
+	}
+	if !strings.HasPrefix(c.Builder.Host, "ssh://") {
+		return errors.New("builder: sync requires an ssh:// host")
+	}
+	return nil
+}
@@ -0,0 +1,402 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// composeFile is the subset of the Compose specification ftl can convert.
+type composeFile struct {
+	Name     string                    `yaml:"name"`
+	Services map[string]composeService `yaml:"services"`
+}
+
+type composeService struct {
+	Image       string              `yaml:"image"`
+	Build       composeBuild        `yaml:"build"`
+	Ports       []composePort       `yaml:"ports"`
+	Expose      []composePort       `yaml:"expose"`
+	Volumes     []composeVolume     `yaml:"volumes"`
+	Environment composeEnvironment  `yaml:"environment"`
+	Command     composeCommand      `yaml:"command"`
+	Entrypoint  composeCommand      `yaml:"entrypoint"`
+	Restart     string              `yaml:"restart"`
+	HealthCheck *composeHealthCheck `yaml:"healthcheck"`
+	User        string              `yaml:"user"`
+	CapAdd      []string            `yaml:"cap_add"`
+	CapDrop     []string            `yaml:"cap_drop"`
+	ReadOnly    bool                `yaml:"read_only"`
+	SecurityOpt []string            `yaml:"security_opt"`
+	Tmpfs       composeCommand      `yaml:"tmpfs"`
+	Deploy      struct {
+		Resources struct {
+			Limits struct {
+				CPUs   string `yaml:"cpus"`
+				Memory string `yaml:"memory"`
+				Pids   int    `yaml:"pids"`
+			} `yaml:"limits"`
+			Reservations struct {
+				Memory string `yaml:"memory"`
+			} `yaml:"reservations"`
+		} `yaml:"resources"`
+	} `yaml:"deploy"`
+}
+
+// composeBuild accepts both `build: ./dir` and `build: {context: ./dir}`.
+type composeBuild struct {
+	Context string `yaml:"context"`
+}
+
+func (b *composeBuild) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		b.Context = node.Value
+		return nil
+	}
+	type plain composeBuild
+	return node.Decode((*plain)(b))
+}
+
+// composePort is the container side of a port mapping such as
+// "127.0.0.1:8080:80/tcp" or {target: 80}.
+type composePort int
+
+func (p *composePort) UnmarshalYAML(node *yaml.Node) error {
+	value := node.Value
+	if node.Kind == yaml.MappingNode {
+		var long struct {
+			Target int `yaml:"target"`
+		}
+		if err := node.Decode(&long); err != nil {
+			return err
+		}
+		*p = composePort(long.Target)
+		return nil
+	}
+
+	value, _, _ = strings.Cut(value, "/")
+	if i := strings.LastIndex(value, ":"); i >= 0 {
+		value = value[i+1:]
+	}
+	value, _, _ = strings.Cut(value, "-")
+
+	port, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid port %q", node.Line, node.Value)
+	}
+	*p = composePort(port)
+	return nil
+}
+
+// composeVolume accepts "source:target[:mode]" and the long syntax.
+type composeVolume struct {
+	Source string
+	Target string
+}
+
+func (v *composeVolume) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		var long struct {
+			Source string `yaml:"source"`
+			Target string `yaml:"target"`
+		}
+		if err := node.Decode(&long); err != nil {
+			return err
+		}
+		v.Source, v.Target = long.Source, long.Target
+		return nil
+	}
+
+	parts := strings.Split(node.Value, ":")
+	if len(parts) == 1 {
+		v.Target = parts[0]
+		return nil
+	}
+	v.Source, v.Target = parts[0], parts[1]
+	return nil
+}
+
+// composeEnvironment accepts both the map and the list form.
+type composeEnvironment []string
+
+func (e *composeEnvironment) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		var list []string
+		if err := node.Decode(&list); err != nil {
+			return err
+		}
+		*e = list
+		return nil
+	}
+
+	var env map[string]*string
+	if err := node.Decode(&env); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := ""
+		if env[key] != nil {
+			value = *env[key]
+		}
+		*e = append(*e, key+"="+value)
+	}
+	return nil
+}
+
+// composeCommand accepts a command string or an exec-form list.
+type composeCommand []string
+
+func (c *composeCommand) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*c = []string{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*c = list
+	return nil
+}
+
+type composeHealthCheck struct {
+	Test        composeCommand `yaml:"test"`
+	Interval    string         `yaml:"interval"`
+	Timeout     string         `yaml:"timeout"`
+	Retries     int            `yaml:"retries"`
+	StartPeriod string         `yaml:"start_period"`
+	Disable     bool           `yaml:"disable"`
+}
+
+// FromCompose converts a Docker Compose file into an ftl configuration for
+// project. Services with a build context, or whose image is not a known
+// dependency, become ftl services; the rest become dependencies. Settings
+// that have no ftl equivalent are reported as warnings.
+func FromCompose(data []byte, project string) (*Config, []string, error) {
+	var compose composeFile
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		return nil, nil, fmt.Errorf("error parsing compose file: %w", err)
+	}
+	if len(compose.Services) == 0 {
+		return nil, nil, fmt.Errorf("compose file defines no services")
+	}
+	if compose.Name != "" {
+		project = compose.Name
+	}
+
+	cfg := &Config{
+		Project: Project{
+			Name:   project,
+			Domain: project + ".example.com",
+			Email:  "admin@example.com",
+		},
+		Server: &Server{
+			Host: project + ".example.com",
+			Port: 22,
+		},
+	}
+
+	var warnings []string
+	warn := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	names := make([]string, 0, len(compose.Services))
+	for name := range compose.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	volumes := map[string]struct{}{}
+	for _, name := range names {
+		svc := compose.Services[name]
+
+		var binds []string
+		for _, volume := range svc.Volumes {
+			switch {
+			case volume.Source == "":
+				warn("%s: anonymous volume %s is not supported, use a named volume", name, volume.Target)
+			case strings.HasPrefix(volume.Source, "/"):
+				binds = append(binds, volume.Source+":"+volume.Target)
+			case strings.HasPrefix(volume.Source, ".") || strings.HasPrefix(volume.Source, "~"):
+				warn("%s: bind mount %s is relative to your machine and was left out", name, volume.Source)
+			default:
+				volumes[volume.Source] = struct{}{}
+				binds = append(binds, volume.Source+":"+volume.Target)
+			}
+		}
+
+		healthCheck, err := composeContainerHealthCheck(svc.HealthCheck)
+		if err != nil {
+			return nil, nil, fmt.Errorf("service %s: %w", name, err)
+		}
+		container := composeContainer(svc, healthCheck)
+
+		resources := composeResources(svc)
+		restart := svc.Restart
+		if restart != "" && !restartPolicyRegex.MatchString(restart) {
+			warn("%s: restart policy %q is not supported", name, restart)
+			restart = ""
+		}
+
+		ports := append(append([]composePort{}, svc.Ports...), svc.Expose...)
+
+		if svc.Build.Context == "" && isKnownDependency(svc.Image) {
+			dependency := Dependency{
+				Name:      name,
+				Image:     svc.Image,
+				Volumes:   binds,
+				Env:       svc.Environment,
+				Container: container,
+				Resources: resources,
+				Restart:   restart,
+			}
+			for _, port := range ports {
+				dependency.Ports = append(dependency.Ports, int(port))
+			}
+			cfg.Dependencies = append(cfg.Dependencies, dependency)
+			continue
+		}
+
+		service := Service{
+			Name:       name,
+			Image:      svc.Image,
+			Path:       svc.Build.Context,
+			Volumes:    binds,
+			Env:        svc.Environment,
+			Entrypoint: svc.Entrypoint,
+			Container:  container,
+			Resources:  resources,
+			Restart:    restart,
+		}
+		if len(svc.Command) > 0 {
+			service.Command = strings.Join(svc.Command, " ")
+		}
+		if len(ports) > 0 {
+			service.Port = int(ports[0])
+			if len(ports) > 1 {
+				warn("%s: only port %d is routed, other ports were left out", name, service.Port)
+			}
+		} else {
+			warn("%s: no port found, set the port the service listens on", name)
+		}
+
+		route := Route{PathPrefix: "/"}
+		if len(cfg.Services) > 0 {
+			route = Route{PathPrefix: "/" + name + "/", StripPrefix: true}
+			warn("%s: routed under %s, adjust the route or give it its own domain", name, route.PathPrefix)
+		}
+		service.Routes = []Route{route}
+
+		cfg.Services = append(cfg.Services, service)
+	}
+
+	for volume := range volumes {
+		cfg.Volumes = append(cfg.Volumes, volume)
+	}
+	sort.Strings(cfg.Volumes)
+
+	return cfg, warnings, nil
+}
+
+// isKnownDependency reports whether image is one of the databases, caches or
+// queues ftl has default dependency settings for.
+func isKnownDependency(image string) bool {
+	if image == "" {
+		return false
+	}
+	name, _, _ := strings.Cut(path.Base(image), ":")
+	_, ok := defaultConfigs[name]
+	return ok
+}
+
+// composeContainer returns the container settings of a compose service, nil
+// when it has none.
+func composeContainer(svc composeService, healthCheck *ContainerHealthCheck) *Container {
+	if healthCheck == nil && svc.User == "" && len(svc.CapAdd) == 0 && len(svc.CapDrop) == 0 &&
+		!svc.ReadOnly && len(svc.SecurityOpt) == 0 && len(svc.Tmpfs) == 0 {
+		return nil
+	}
+	return &Container{
+		HealthCheck: healthCheck,
+		User:        svc.User,
+		CapAdd:      svc.CapAdd,
+		CapDrop:     svc.CapDrop,
+		ReadOnly:    svc.ReadOnly,
+		SecurityOpt: svc.SecurityOpt,
+		Tmpfs:       svc.Tmpfs,
+	}
+}
+
+func composeContainerHealthCheck(hc *composeHealthCheck) (*ContainerHealthCheck, error) {
+	if hc == nil || hc.Disable || len(hc.Test) == 0 || hc.Test[0] == "NONE" {
+		return nil, nil
+	}
+
+	var cmd string
+	switch hc.Test[0] {
+	case "CMD-SHELL":
+		cmd = strings.Join(hc.Test[1:], " ")
+	case "CMD":
+		cmd = strings.Join(hc.Test[1:], " ")
+	default:
+		cmd = strings.Join(hc.Test, " ")
+	}
+
+	healthCheck := &ContainerHealthCheck{Cmd: cmd, Retries: hc.Retries}
+	for _, field := range []struct {
+		value  string
+		target *Duration
+	}{
+		{hc.Interval, &healthCheck.Interval},
+		{hc.Timeout, &healthCheck.Timeout},
+		{hc.StartPeriod, &healthCheck.StartPeriod},
+	} {
+		if field.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid healthcheck duration %q: %w", field.value, err)
+		}
+		*field.target = Duration(d)
+	}
+
+	return healthCheck, nil
+}
+
+func composeResources(svc composeService) *Resources {
+	limits := svc.Deploy.Resources.Limits
+	resources := &Resources{
+		Memory:            composeMemory(limits.Memory),
+		MemoryReservation: composeMemory(svc.Deploy.Resources.Reservations.Memory),
+		PidsLimit:         limits.Pids,
+	}
+	if cpus, err := strconv.ParseFloat(limits.CPUs, 64); err == nil {
+		resources.CPUs = cpus
+	}
+
+	if *resources == (Resources{}) {
+		return nil
+	}
+	return resources
+}
+
+// composeMemory converts Compose sizes such as "512M" or "1gb" to ftl's format.
+func composeMemory(size string) string {
+	size = strings.TrimSuffix(strings.ToLower(size), "b")
+	if size == "" || memorySizeRegex.MatchString(size) {
+		return size
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const composeSample = `
+services:
+  web:
+    build: ./web
+    ports:
+      - "8080:3000"
+    environment:
+      DATABASE_URL: postgres://app:secret@db:5432/app
+      NODE_ENV: production
+    volumes:
+      - uploads:/app/uploads
+      - ./src:/app/src
+    restart: unless-stopped
+    healthcheck:
+      test: ["CMD-SHELL", "curl -f http://localhost:3000/health || exit 1"]
+      interval: 10s
+      timeout: 5s
+      retries: 3
+    deploy:
+      resources:
+        limits:
+          cpus: "0.5"
+          memory: 512M
+  worker:
+    image: ghcr.io/acme/worker:1.2
+    command: ["bundle", "exec", "sidekiq"]
+    expose:
+      - "9000"
+    user: "1000"
+    read_only: true
+    cap_drop: [ALL]
+    security_opt: [no-new-privileges]
+    tmpfs: /tmp
+  db:
+    image: postgres:16-alpine
+    environment:
+      - POSTGRES_PASSWORD=secret
+    volumes:
+      - db-data:/var/lib/postgresql/data
+    ports:
+      - "5432:5432"
+volumes:
+  uploads:
+  db-data:
+`
+
+func TestFromCompose(t *testing.T) {
+	cfg, warnings, err := FromCompose([]byte(composeSample), "shop")
+	require.NoError(t, err)
+
+	assert.Equal(t, "shop", cfg.Project.Name)
+	assert.Equal(t, []string{"db-data", "uploads"}, cfg.Volumes)
+
+	require.Len(t, cfg.Dependencies, 1)
+	db := cfg.Dependencies[0]
+	assert.Equal(t, "db", db.Name)
+	assert.Equal(t, "postgres:16-alpine", db.Image)
+	assert.Equal(t, []int{5432}, db.Ports)
+	assert.Equal(t, []string{"POSTGRES_PASSWORD=secret"}, db.Env)
+	assert.Equal(t, []string{"db-data:/var/lib/postgresql/data"}, db.Volumes)
+
+	require.Len(t, cfg.Services, 2)
+	web := cfg.Services[0]
+	assert.Equal(t, "web", web.Name)
+	assert.Equal(t, "./web", web.Path)
+	assert.Equal(t, 3000, web.Port)
+	assert.Equal(t, []string{"DATABASE_URL=postgres://app:secret@db:5432/app", "NODE_ENV=production"}, web.Env)
+	assert.Equal(t, []string{"uploads:/app/uploads"}, web.Volumes)
+	assert.Equal(t, "unless-stopped", web.Restart)
+	assert.Equal(t, "curl -f http://localhost:3000/health || exit 1", web.Container.HealthCheck.Cmd)
+	assert.Equal(t, 10*time.Second, web.Container.HealthCheck.Interval.Duration())
+	assert.Equal(t, &Resources{CPUs: 0.5, Memory: "512m"}, web.Resources)
+	assert.Equal(t, []Route{{PathPrefix: "/"}}, web.Routes)
+
+	worker := cfg.Services[1]
+	assert.Equal(t, "bundle exec sidekiq", worker.Command)
+	assert.Equal(t, 9000, worker.Port)
+	assert.Equal(t, []Route{{PathPrefix: "/worker/", StripPrefix: true}}, worker.Routes)
+	assert.Equal(t, &Container{
+		User:        "1000",
+		CapDrop:     []string{"ALL"},
+		ReadOnly:    true,
+		SecurityOpt: []string{"no-new-privileges"},
+		Tmpfs:       []string{"/tmp"},
+	}, worker.Container)
+
+	assert.Contains(t, warnings, "web: bind mount ./src is relative to your machine and was left out")
+}
+
+func TestFromCompose_RoundTrip(t *testing.T) {
+	cfg, _, err := FromCompose([]byte(composeSample), "shop")
+	require.NoError(t, err)
+
+	data, err := Marshal(cfg)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "interval: 10s")
+	assert.NotContains(t, string(data), "null")
+
+	parsed, err := ParseConfig(data)
+	require.NoError(t, err)
+	assert.Equal(t, cfg.Services[0].Container, parsed.Services[0].Container)
+	assert.Equal(t, cfg.Services[1].Container, parsed.Services[1].Container)
+	assert.Equal(t, cfg.Dependencies, parsed.Dependencies)
+}
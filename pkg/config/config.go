@@ -5,25 +5,95 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"os/user"
 	"path/filepath"
 	"reflect"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/joho/godotenv"
 	"gopkg.in/yaml.v3"
+
+	"github.com/yarlson/ftl/pkg/ssh"
 )
 
 type Config struct {
-	Project      Project      `yaml:"project" validate:"required"`
-	Server       *Server      `yaml:"server" validate:"omitempty"`
-	Services     []Service    `yaml:"services" validate:"required,dive"`
-	Dependencies []Dependency `yaml:"dependencies" validate:"dive"`
-	Volumes      []string     `yaml:"volumes" validate:"dive"`
+	Project       Project           `yaml:"project" validate:"required"`
+	Server        *Server           `yaml:"server" validate:"omitempty"`
+	Env           []string          `yaml:"env"`
+	Services      []Service         `yaml:"services" validate:"required,dive"`
+	Dependencies  []Dependency      `yaml:"dependencies" validate:"dive"`
+	Volumes       []string          `yaml:"volumes" validate:"dive"`
+	Tests         []IntegrationTest `yaml:"tests" validate:"dive"`
+	Notifications []Notification    `yaml:"notifications" validate:"dive"`
+	Cleanup       *Cleanup          `yaml:"cleanup"`
+	Metrics       *Metrics          `yaml:"metrics"`
+	TLS           *TLS              `yaml:"tls"`
+	Maintenance   *Maintenance      `yaml:"maintenance"`
+	Deploy        *Deploy           `yaml:"deploy"`
+	Scan          *Scan             `yaml:"scan" validate:"omitempty"`
+	DNS           *DNS              `yaml:"dns" validate:"omitempty"`
+	Builder       *Builder          `yaml:"builder" validate:"omitempty"`
+	Proxy         *Proxy            `yaml:"proxy"`
+	// Networks are private networks services and dependencies join by name.
+	// Dependencies that join one leave the project network, so the proxy
+	// cannot reach them.
+	Networks []string `yaml:"networks" validate:"unique,dive,network_name"`
+	// Environments are further deploy targets, selected with --env.
+	Environments []Environment `yaml:"environments" validate:"unique=Name,dive"`
+	// Include lists files, or glob patterns, relative to the configuration
+	// file whose services, dependencies and volumes are merged into it.
+	Include []string `yaml:"include" validate:"dive,required"`
+	// Revision is the commit being deployed, set by SetRevision.
+	Revision Revision `yaml:"-"`
+}
+
+// Maintenance configures the page `ftl maintenance on` serves, with status
+// 503, to every request instead of the services. Page is an HTML file, a
+// built-in page unless set. Clients from AllowIPs bypass maintenance mode, so
+// the site can be checked during the work; the list takes effect on deploy.
+type Maintenance struct {
+	Page     string   `yaml:"page" validate:"omitempty,filepath"`
+	AllowIPs []string `yaml:"allow_ips" validate:"dive,cidr|ip"`
+}
+
+// Metrics exposes Prometheus metrics on the server: request rates and
+// upstream latency of every service from the proxy, and certificate expiry
+// and container restart counts from the metrics agent. They are published on
+// Bind:Port, 127.0.0.1:9113 by default, so they stay private to the server
+// unless bound to a private network address.
+type Metrics struct {
+	Port int    `yaml:"port" validate:"omitempty,min=1,max=65535"`
+	Bind string `yaml:"bind" validate:"omitempty,ip"`
+}
+
+// Cleanup is the retention policy applied on the server after every deploy
+// and by `ftl cleanup`. KeepReleases tagged images of each repository the
+// project uses are kept (3 by default); untagged images older than
+// PruneOlderThan (720h by default) are removed. Images used by a container
+// are never removed.
+type Cleanup struct {
+	KeepReleases   int      `yaml:"keep_releases" validate:"omitempty,min=1"`
+	PruneOlderThan Duration `yaml:"prune_older_than"`
+}
+
+// IntegrationTest is a container that `ftl test` runs against a sandboxed copy
+// of the project. Services and dependencies are reachable by name, and the
+// test passes when the container exits with status 0.
+type IntegrationTest struct {
+	Name    string   `yaml:"name" validate:"required"`
+	Image   string   `yaml:"image" validate:"required"`
+	Command string   `yaml:"command"`
+	Env     []string `yaml:"env"`
+	Timeout Duration `yaml:"timeout"`
 }
 
 type Project struct {
@@ -32,46 +102,421 @@ type Project struct {
 	Email  string `yaml:"email" validate:"required,email"`
 }
 
+// Server is the deploy target. DockerHost points ftl at a Docker daemon other
+// than the default root socket, e.g. "unix:///run/user/1000/docker.sock" or
+// "tcp://127.0.0.1:2375"; when it is empty a rootless daemon of the deploy
+// user is detected automatically. Rootless makes `ftl setup` install rootless
+// Docker for the user instead of adding it to the docker group. ProxyJump
+// routes every SSH connection through a bastion host. Timeouts bounds
+// connects, pulls and health checks, and Transfer the image uploads.
 type Server struct {
 	Host       string `yaml:"host" validate:"omitempty,fqdn|ip"`
 	Port       int    `yaml:"port" validate:"omitempty,min=1,max=65535"`
 	User       string `yaml:"user"`
 	Passwd     string `yaml:"-"`
 	SSHKey     string `yaml:"ssh_key" validate:"omitempty,filepath"`
-	RootSSHKey string `yaml:"-"`
+	DockerHost string `yaml:"docker_host" validate:"omitempty,docker_host"`
+	// IdentityAgent is the socket of the ssh-agent to sign with instead of
+	// SSH_AUTH_SOCK, or "none" to use key files only.
+	IdentityAgent string     `yaml:"identity_agent"`
+	Rootless      bool       `yaml:"rootless"`
+	ProxyJump     *ProxyJump `yaml:"proxy_jump"`
+	Timeouts      *Timeouts  `yaml:"timeouts"`
+	Transfer      *Transfer  `yaml:"transfer"`
+	// Shared lets several projects deploy to the server: a proxy shared by
+	// all of them owns ports 80 and 443 and routes every domain to the proxy
+	// of its project.
+	Shared bool `yaml:"shared"`
+	// ImageTransfer is how images of services without an image reach the
+	// server: layers syncs only the layers it lacks, stream pipes the whole
+	// compressed image to docker load without staging it.
+	ImageTransfer string `yaml:"image_transfer" validate:"omitempty,oneof=layers stream"`
+	// IPv6 enables IPv6 on the project networks, so the proxy accepts
+	// connections over IPv6 itself and sees the addresses of IPv6 clients.
+	// Without it Docker forwards them over IPv4 from its own address.
+	IPv6 bool `yaml:"ipv6"`
+}
+
+// Image transfer modes of a server.
+const (
+	ImageTransferLayers = "layers"
+	ImageTransferStream = "stream"
+)
+
+// ProxyJump is a bastion host the server is reached through, like ssh -J. The
+// port defaults to 22, and the user and SSH key to those of the server.
+type ProxyJump struct {
+	Host   string `yaml:"host" validate:"required,fqdn|ip"`
+	Port   int    `yaml:"port" validate:"omitempty,min=1,max=65535"`
+	User   string `yaml:"user"`
+	SSHKey string `yaml:"ssh_key" validate:"omitempty,filepath"`
 }
 
+var dockerHostRegex = regexp.MustCompile(`^(unix://(/[^\s]+)|tcp://[^\s/]+)$`)
+
 type Service struct {
 	Name         string `yaml:"name" validate:"required"`
 	Image        string `yaml:"image"`
 	ImageUpdated bool
-	Port         int                 `yaml:"port" validate:"required,min=1,max=65535"`
+	Port         int                 `yaml:"port" validate:"omitempty,min=1,max=65535"`
 	Path         string              `yaml:"path"`
+	Build        *Build              `yaml:"build"`
+	Domain       string              `yaml:"domain" validate:"omitempty,fqdn"`
 	HealthCheck  *ServiceHealthCheck `yaml:"health_check"`
-	Routes       []Route             `yaml:"routes" validate:"required,dive"`
+	Routes       []Route             `yaml:"routes" validate:"dive"`
 	Volumes      []string            `yaml:"volumes" validate:"dive,volume_reference"`
 	Command      string              `yaml:"command"`
 	CommandSlice []string            `yaml:"_"`
 	Entrypoint   []string            `yaml:"entrypoint"`
 	Env          []string            `yaml:"env"`
+	EnvFiles     []string            `yaml:"env_files" validate:"dive,required"`
+	Profiles     []string            `yaml:"profiles" validate:"dive,required"`
 	Forwards     []string            `yaml:"forwards"`
 	Recreate     bool                `yaml:"recreate"`
 	Hooks        *Hooks              `yaml:"hooks"`
 	Container    *Container          `yaml:"container"`
-	LocalPorts   []int               `yaml:"-"`
+	Static       *Static             `yaml:"static"`
+	Migrations   *Migrations         `yaml:"migrations"`
+	Resources    *Resources          `yaml:"resources"`
+	Restart      string              `yaml:"restart" validate:"omitempty,restart_policy"`
+	CrashAlert   *CrashAlert         `yaml:"crash_alert"`
+	// DrainTimeout keeps the previous container on the network after traffic
+	// switches and gives it this long after SIGTERM to finish in-flight
+	// requests before it is killed.
+	DrainTimeout Duration `yaml:"drain_timeout"`
+	// StopSignal is sent to a container to stop it instead of SIGTERM, such
+	// as SIGQUIT for servers that drain on it.
+	StopSignal string `yaml:"stop_signal" validate:"omitempty,stop_signal"`
+	// StopGracePeriod is how long a container has after the stop signal
+	// before it is killed, for workers that finish their jobs first.
+	StopGracePeriod Duration `yaml:"stop_grace_period"`
+	// Replicas runs this many containers of the service. They share the
+	// service's network alias, so the proxy balances requests across all of
+	// them, and are replaced one at a time on deploy.
+	Replicas int `yaml:"replicas" validate:"omitempty,min=1"`
+	// Strategy is how containers are replaced on deploy: blue-green,
+	// replace or rolling.
+	Strategy string `yaml:"strategy" validate:"omitempty,oneof=blue-green replace rolling"`
+	// Type is web, the default, or worker for services without routes. Web
+	// services need a port unless they are static, and routes unless they
+	// expose streams.
+	Type string `yaml:"type" validate:"omitempty,oneof=web worker"`
+	// Verify checks the cosign signature of Image before it is deployed.
+	Verify     *Verify     `yaml:"verify"`
+	SmokeTests *SmokeTests `yaml:"smoke_tests"`
+	Warmup     *Warmup     `yaml:"warmup"`
+	Logging    *Logging    `yaml:"logging"`
+	// Networks are private networks the service joins in addition to the
+	// project network, under its name.
+	Networks []string `yaml:"networks" validate:"unique,dive,required"`
+	// Aliases are further names the service is reached by on its networks.
+	Aliases []string `yaml:"aliases" validate:"unique,dive,hostname_rfc1123"`
+	// Streams expose non-HTTP ports of the service through the proxy.
+	Streams []Stream `yaml:"streams" validate:"dive"`
+	// Uploads are copied to the server before the container starts.
+	Uploads    []Upload `yaml:"uploads" validate:"dive"`
+	ReplicaOf  string   `yaml:"-"`
+	LocalPorts []int    `yaml:"-"`
+	// Isolated services are not attached to the project network; they are
+	// created on their first network instead.
+	Isolated bool `yaml:"-"`
+	// Revision is the commit the container is deployed from. It is passed to
+	// the container but does not change its hash, so a new commit alone does
+	// not replace a container whose image and settings are unchanged.
+	Revision Revision `yaml:"-" json:"-"`
+	// Pinned is set by PinImage, and PinnedFrom is the image reference it
+	// replaced Image of, empty for images built from Path.
+	Pinned     bool   `yaml:"-"`
+	PinnedFrom string `yaml:"-"`
+}
+
+// PinImage replaces the image of the service with reference, the same image
+// by digest or ID. The hash of the service is still taken of the image it was
+// configured with: the image of a container is compared by ID, so pinning
+// alone does not replace it.
+func (s *Service) PinImage(reference string) {
+	if !s.Pinned {
+		s.Pinned = true
+		s.PinnedFrom = s.Image
+	}
+	s.Image = reference
+}
+
+// Verify configures cosign signature verification of a service image, with a
+// public key (a file or a KMS URI) or, for keyless signatures, the expected
+// certificate identity and OIDC issuer. The server pulls the verified digest.
+type Verify struct {
+	Key      string `yaml:"key" validate:"required_without=Identity"`
+	Identity string `yaml:"identity" validate:"required_without=Key"`
+	Issuer   string `yaml:"issuer" validate:"required_with=Identity"`
+}
+
+// SmokeTests check an updated service right after traffic switched to its new
+// container. Every check runs every Interval (5s by default) until Window has
+// passed, or once when Window is not set. When a check fails, traffic is
+// switched back to the previous container and the deploy fails.
+type SmokeTests struct {
+	Window   Duration    `yaml:"window"`
+	Interval Duration    `yaml:"interval"`
+	Checks   []SmokeTest `yaml:"checks" validate:"required,min=1,dive"`
+}
+
+// SmokeTest is a single smoke test check. An HTTP check requests Path from
+// the service port of the new container and expects Status, 200 by default; a
+// command check runs Command in the new container and expects it to exit with
+// status 0.
+type SmokeTest struct {
+	Name    string   `yaml:"name" validate:"required"`
+	Path    string   `yaml:"path" validate:"required_without=Command,excluded_with=Command,omitempty,startswith=/"`
+	Status  int      `yaml:"status" validate:"omitempty,min=100,max=599"`
+	Command string   `yaml:"command"`
+	Timeout Duration `yaml:"timeout"`
+}
+
+// Warmup runs against the new container of an updated service once it is
+// healthy and before traffic switches to it, so JIT compilation and caches
+// are warm when users reach it. Every path in URLs is requested Requests
+// times (1 by default) from the service port, whatever the status of the
+// responses; Command runs in the new container instead. When a path cannot
+// be reached or Command fails, or the warmup takes longer than Timeout (2m by
+// default), the new container is removed and the deploy fails.
+type Warmup struct {
+	URLs     []string `yaml:"urls" validate:"required_without=Command,excluded_with=Command,dive,startswith=/"`
+	Requests int      `yaml:"requests" validate:"omitempty,min=1"`
+	Command  string   `yaml:"command"`
+	Timeout  Duration `yaml:"timeout"`
+}
+
+// ReplicaCount returns the number of containers the service runs.
+func (s *Service) ReplicaCount() int {
+	if s.Replicas < 1 {
+		return 1
+	}
+	return s.Replicas
+}
+
+// ReplicaName returns the name of the container of the i-th replica, counted
+// from zero. The first replica keeps the service name, so scaling a service
+// does not replace its existing container.
+func (s *Service) ReplicaName(i int) string {
+	if i == 0 {
+		return s.Name
+	}
+	return fmt.Sprintf("%s-%d", s.Name, i+1)
+}
+
+// StopTimeout returns how long a container of the service is given to stop
+// before it is killed: the longer of its drain timeout and stop grace
+// period, or zero for Docker's default.
+func (s *Service) StopTimeout() time.Duration {
+	return max(s.DrainTimeout.Duration(), s.StopGracePeriod.Duration())
+}
+
+// Build configures how the service image is built from Path. Secrets are
+// mounted with RUN --mount=type=secret,id=<id> and never stored in the image;
+// SSH forwards agent sockets or keys for RUN --mount=type=ssh, e.g. "default"
+// for the local ssh-agent or "github=~/.ssh/id_ed25519". CacheFrom and CacheTo
+// take buildx cache notation, e.g. "type=registry,ref=ghcr.io/acme/web:cache",
+// "type=inline" or "type=local,dest=.cache/web".
+type Build struct {
+	// Platforms to build for, "linux/amd64" unless set. Several platforms are
+	// built concurrently and pushed as one multi-platform image, so they
+	// require an image in a registry.
+	Platforms []string      `yaml:"platforms" validate:"dive,platform"`
+	Secrets   []BuildSecret `yaml:"secrets" validate:"dive"`
+	SSH       []string      `yaml:"ssh" validate:"dive,required"`
+	CacheFrom []string      `yaml:"cache_from" validate:"dive,cache_spec"`
+	CacheTo   []string      `yaml:"cache_to" validate:"dive,cache_spec"`
+	// Pre are shell commands run in Path on this machine before the image
+	// is built, such as "npm ci" and "npm run build".
+	Pre []string `yaml:"pre" validate:"dive,required"`
+	// Output is the directory, relative to Path, the Pre commands write.
+	// When set, it is cached by the content of Inputs and the commands
+	// are skipped while that content does not change.
+	Output string `yaml:"output"`
+	// Inputs are the files and directories, relative to Path, the Pre
+	// commands read. All of Path except Output, .git and node_modules by
+	// default.
+	Inputs []string `yaml:"inputs" validate:"dive,required"`
 }
 
+var platformRegex = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+(/v[0-9]+)?$`)
+
+// cacheTypes are the buildx cache backends accepted in cache_from and cache_to.
+var cacheTypes = []string{"registry", "inline", "local", "gha", "s3", "azblob"}
+
+// validCacheSpec accepts an image reference or a buildx cache specification
+// with a known type.
+func validCacheSpec(spec string) bool {
+	if spec == "" || strings.ContainsAny(spec, " \t") {
+		return false
+	}
+	if !strings.Contains(spec, "=") {
+		return true
+	}
+
+	for _, option := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(option, "=")
+		if !ok {
+			return false
+		}
+		if key == "type" && !slices.Contains(cacheTypes, value) {
+			return false
+		}
+	}
+	return strings.HasPrefix(spec, "type=")
+}
+
+// resolveCacheSpec makes the directory of a local cache relative to baseDir.
+func resolveCacheSpec(spec, baseDir string) string {
+	if !strings.HasPrefix(spec, "type=local") {
+		return spec
+	}
+
+	options := strings.Split(spec, ",")
+	for i, option := range options {
+		key, value, _ := strings.Cut(option, "=")
+		if (key == "src" || key == "dest") && !filepath.IsAbs(value) {
+			options[i] = key + "=" + filepath.Join(baseDir, value)
+		}
+	}
+	return strings.Join(options, ",")
+}
+
+// BuildSecret exposes the environment variable Env or the file Src to the
+// build as the secret ID.
+type BuildSecret struct {
+	ID  string `yaml:"id" validate:"required"`
+	Env string `yaml:"env" validate:"required_without=Src,excluded_with=Src"`
+	Src string `yaml:"src" validate:"required_without=Env"`
+}
+
+// Spec returns the secret in docker build --secret notation.
+func (s BuildSecret) Spec() string {
+	if s.Env != "" {
+		return fmt.Sprintf("id=%s,env=%s", s.ID, s.Env)
+	}
+	return fmt.Sprintf("id=%s,src=%s", s.ID, s.Src)
+}
+
+// DefaultRestartPolicy is applied to containers that do not set restart.
+const DefaultRestartPolicy = "unless-stopped"
+
+// CrashAlert posts to Webhook every time the container has been restarted
+// Threshold more times (3 by default). The payload carries both "text" and
+// "content" so Slack and Discord webhooks accept it as-is.
+type CrashAlert struct {
+	Webhook   string `yaml:"webhook" validate:"required,url"`
+	Threshold int    `yaml:"threshold" validate:"omitempty,min=1"`
+}
+
+// Resources limits what a container may consume on the server. Memory sizes
+// use docker notation, e.g. "512m" or "2g".
+type Resources struct {
+	CPUs              float64 `yaml:"cpus" validate:"omitempty,gt=0"`
+	Memory            string  `yaml:"memory" validate:"omitempty,memory_size"`
+	MemoryReservation string  `yaml:"memory_reservation" validate:"omitempty,memory_size"`
+	PidsLimit         int     `yaml:"pids_limit" validate:"omitempty,min=1"`
+}
+
+// Logging configures the Docker log driver of a container. Driver is
+// json-file (the default), local, journald, syslog, loki or none. json-file
+// and local logs rotate at MaxSize, in docker notation ("10m" by default),
+// keeping MaxFile files (3 by default). syslog and loki ship the logs to
+// Address, e.g. "udp://logs.example.com:514" or
+// "https://loki.example.com/loki/api/v1/push"; loki needs the Loki Docker
+// plugin on the server. Options are passed to the driver as extra --log-opt
+// values.
+type Logging struct {
+	Driver  string            `yaml:"driver" validate:"omitempty,oneof=json-file local journald syslog loki none"`
+	MaxSize string            `yaml:"max_size" validate:"omitempty,memory_size"`
+	MaxFile int               `yaml:"max_file" validate:"omitempty,min=1"`
+	Address string            `yaml:"address" validate:"required_if=Driver syslog,required_if=Driver loki,omitempty,url"`
+	Options map[string]string `yaml:"options"`
+}
+
+// Log rotation applied to json-file and local logs unless configured.
+const (
+	DefaultLogDriver  = "json-file"
+	DefaultLogMaxSize = "10m"
+	DefaultLogMaxFile = 3
+)
+
+// Migration timings relative to the traffic cutover.
+const (
+	MigrationsPre  = "pre"
+	MigrationsPost = "post"
+)
+
+// Migration lock strategies.
+const (
+	MigrationsLockFlock = "flock"
+	MigrationsLockNone  = "none"
+)
+
+// Migrations describes a command run in an ephemeral container during deploy.
+// Image defaults to the service image, Timing to "pre" and Lock to "flock",
+// which serializes migrations of the project on the server.
+type Migrations struct {
+	Command string `yaml:"command" validate:"required"`
+	Image   string `yaml:"image"`
+	Timing  string `yaml:"timing" validate:"omitempty,oneof=pre post"`
+	Lock    string `yaml:"lock" validate:"omitempty,oneof=flock none"`
+}
+
+// Static marks a service whose built image only produces files. The directory
+// at Path is extracted from the image and served by the proxy directly, so no
+// container for the service runs on the server.
+//
+// HashedAssets declares that scripts and stylesheets carry content hashes in
+// their file names. Deploys verify that HTML only references hashed assets, and
+// the proxy caches hashed files forever while revalidating everything else.
+type Static struct {
+	Path         string `yaml:"path" validate:"required,unix_path"`
+	HashedAssets bool   `yaml:"hashed_assets"`
+}
+
+// ServiceHealthCheck gates traffic cutover on the new container being healthy.
+// The http type (the default) requests Path inside the container; the grpc
+// type calls the standard gRPC health checking protocol on the service port,
+// optionally for a single GRPCService.
 type ServiceHealthCheck struct {
-	Path     string        `yaml:"path"`
-	Interval time.Duration `yaml:"interval"`
-	Timeout  time.Duration `yaml:"timeout"`
-	Retries  int           `yaml:"retries"`
+	Type        string   `yaml:"type" validate:"omitempty,oneof=http grpc"`
+	Path        string   `yaml:"path"`
+	GRPCService string   `yaml:"grpc_service"`
+	Interval    Duration `yaml:"interval"`
+	Timeout     Duration `yaml:"timeout"`
+	Retries     int      `yaml:"retries"`
+	// Status lists the statuses of a healthy http response, any status below
+	// 400 by default.
+	Status []int `yaml:"status" validate:"unique,dive,min=100,max=599"`
+	// Body is a regular expression the body of a healthy response matches.
+	Body string `yaml:"body"`
+	// JSON maps dotted paths into a JSON response, such as "checks.db", to
+	// the values they must have.
+	JSON map[string]string `yaml:"json"`
 }
 
+const (
+	HealthCheckHTTP = "http"
+	HealthCheckGRPC = "grpc"
+)
+
 type Container struct {
 	HealthCheck *ContainerHealthCheck `yaml:"health_check"`
 	ULimits     []ULimit              `yaml:"ulimits"`
 	RunOnce     bool                  `yaml:"run_once"`
+	// User runs the container processes as a user of the image or as
+	// "uid[:gid]".
+	User    string   `yaml:"user"`
+	CapAdd  []string `yaml:"cap_add" validate:"dive,required"`
+	CapDrop []string `yaml:"cap_drop" validate:"dive,required"`
+	// ReadOnly mounts the root filesystem read-only. Paths the container
+	// writes to need a volume or a tmpfs mount.
+	ReadOnly    bool     `yaml:"read_only"`
+	SecurityOpt []string `yaml:"security_opt" validate:"dive,required"`
+	// Tmpfs mounts are "path" or "path:options", e.g. "/tmp:size=64m".
+	Tmpfs []string `yaml:"tmpfs" validate:"dive,startswith=/"`
 }
 
 type ULimit struct {
@@ -81,26 +526,47 @@ type ULimit struct {
 }
 
 type ContainerHealthCheck struct {
-	Cmd          string `yaml:"cmd"`
-	Interval     string `yaml:"interval"`
-	Retries      int    `yaml:"retries"`
-	Timeout      string `yaml:"timeout"`
-	StartPeriod  string `yaml:"start_period"`
-	StartTimeout string `yaml:"start_timeout"`
+	Cmd          string   `yaml:"cmd"`
+	Interval     Duration `yaml:"interval"`
+	Retries      int      `yaml:"retries"`
+	Timeout      Duration `yaml:"timeout"`
+	StartPeriod  Duration `yaml:"start_period"`
+	StartTimeout Duration `yaml:"start_timeout"`
 }
 
 type Route struct {
-	PathPrefix  string `yaml:"path" validate:"required"`
-	StripPrefix bool   `yaml:"strip_prefix"`
+	PathPrefix  string       `yaml:"path" validate:"required"`
+	StripPrefix bool         `yaml:"strip_prefix"`
+	Middleware  []Middleware `yaml:"middleware" validate:"dive"`
 }
 
 type Dependency struct {
-	Name      string     `yaml:"name" validate:"required"`
-	Image     string     `yaml:"image" validate:"required"`
-	Volumes   []string   `yaml:"volumes" validate:"dive,volume_reference"`
-	Env       []string   `yaml:"env" validate:"dive"`
-	Ports     []int      `yaml:"ports" validate:"dive,min=1,max=65535"`
-	Container *Container `yaml:"container"`
+	Name       string      `yaml:"name" validate:"required"`
+	Image      string      `yaml:"image" validate:"required"`
+	Volumes    []string    `yaml:"volumes" validate:"dive,volume_reference"`
+	Env        []string    `yaml:"env" validate:"dive"`
+	Ports      []int       `yaml:"ports" validate:"dive,min=1,max=65535"`
+	Container  *Container  `yaml:"container"`
+	Resources  *Resources  `yaml:"resources"`
+	Restart    string      `yaml:"restart" validate:"omitempty,restart_policy"`
+	CrashAlert *CrashAlert `yaml:"crash_alert"`
+	Logging    *Logging    `yaml:"logging"`
+	Profiles   []string    `yaml:"profiles" validate:"dive,required"`
+	// Networks isolates the dependency on these private networks, away from
+	// the proxy. Only services that join one of them can reach it.
+	Networks []string `yaml:"networks" validate:"unique,dive,required"`
+	// Aliases are further names the dependency is reached by on its networks.
+	Aliases []string `yaml:"aliases" validate:"unique,dive,hostname_rfc1123"`
+	// DependsOn names the dependencies that must be running, and healthy if
+	// they have a health check, before this one is started.
+	DependsOn []string `yaml:"depends_on" validate:"unique,dive,required"`
+	// Init prepares the data of the dependency when its volumes are new.
+	Init []InitStep `yaml:"init" validate:"dive"`
+	// Command and Entrypoint replace those of the image, in exec form.
+	Command    []string `yaml:"command"`
+	Entrypoint []string `yaml:"entrypoint"`
+	// Configs are mounted into the container from files written to the server.
+	Configs []ConfigFile `yaml:"configs" validate:"unique=Target,dive"`
 }
 
 // Hooks now supports either a simple remote command string
@@ -154,6 +620,8 @@ func getDefaultConfig(baseName, version string) (*Dependency, bool) {
 	if !found {
 		return nil, false
 	}
+	// Callers expand the environment in place.
+	dep.Env = slices.Clone(dep.Env)
 	parts := strings.Split(dep.Image, ":")
 	if len(parts) == 2 {
 		dep.Image = parts[0] + ":" + version
@@ -225,17 +693,8 @@ func (d *Dependency) UnmarshalYAML(node *yaml.Node) error {
 		if err := node.Decode(&tmp); err != nil {
 			return fmt.Errorf("failed to decode dependency map: %w", err)
 		}
-		// Expand placeholders in tmp.Env
-		for i, envLine := range tmp.Env {
-			expanded, err := expandWithEnvAndDefault(envLine)
-			if err != nil {
-				return fmt.Errorf(
-					"failed to expand env for dependency %q: %w",
-					tmp.Name, err,
-				)
-			}
-			tmp.Env[i] = expanded
-		}
+		// The env was expanded with the rest of the file; expanding it again
+		// would undo "$$" escapes.
 		*d = Dependency(tmp)
 		return nil
 
@@ -245,15 +704,124 @@ func (d *Dependency) UnmarshalYAML(node *yaml.Node) error {
 	}
 }
 
+var (
+	memorySizeRegex    = regexp.MustCompile(`^(?i)[0-9]+[bkmg]?$`)
+	restartPolicyRegex = regexp.MustCompile(`^(no|always|unless-stopped|on-failure(:[0-9]+)?)$`)
+	networkNameRegex   = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+	stopSignalRegex    = regexp.MustCompile(`^(SIG[A-Z0-9+-]+|[0-9]+)$`)
+)
+
+// validateNetworks checks that services and dependencies only join networks
+// declared at the top level.
+func (c *Config) validateNetworks() error {
+	for _, service := range c.Services {
+		for _, network := range service.Networks {
+			if !slices.Contains(c.Networks, network) {
+				return fmt.Errorf("service %s: network %q is not declared in networks", service.Name, network)
+			}
+		}
+	}
+	for _, dependency := range c.Dependencies {
+		for _, network := range dependency.Networks {
+			if !slices.Contains(c.Networks, network) {
+				return fmt.Errorf("dependency %s: network %q is not declared in networks", dependency.Name, network)
+			}
+		}
+	}
+	return nil
+}
+
+// validateDependencyOrder checks that dependencies only depend on other
+// dependencies, without cycles.
+func (c *Config) validateDependencyOrder() error {
+	dependsOn := make(map[string][]string, len(c.Dependencies))
+	for _, dependency := range c.Dependencies {
+		dependsOn[dependency.Name] = dependency.DependsOn
+	}
+	for _, dependency := range c.Dependencies {
+		for _, name := range dependency.DependsOn {
+			if _, ok := dependsOn[name]; !ok || name == dependency.Name {
+				return fmt.Errorf("dependency %s: depends_on %q is not another dependency", dependency.Name, name)
+			}
+		}
+	}
+
+	// Depth-first search, where visiting marks the dependencies on the
+	// current path.
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(c.Dependencies))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("dependencies depend on each other in a cycle: %s", strings.Join(append(path, name), " → "))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, next := range dependsOn[name] {
+			if err := visit(next, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for _, dependency := range c.Dependencies {
+		if err := visit(dependency.Name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MemorySizeBytes converts a memory size such as "512m" or "2g" into bytes.
+// A value without a unit is interpreted as bytes, matching docker.
+func MemorySizeBytes(size string) (int64, error) {
+	if !memorySizeRegex.MatchString(size) {
+		return 0, fmt.Errorf("invalid memory size %q", size)
+	}
+
+	multiplier := int64(1)
+	switch unit := strings.ToLower(size[len(size)-1:]); unit {
+	case "k":
+		multiplier = 1 << 10
+	case "m":
+		multiplier = 1 << 20
+	case "g":
+		multiplier = 1 << 30
+	}
+	digits := strings.TrimRightFunc(size, func(r rune) bool { return r < '0' || r > '9' })
+
+	value, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory size %q: %w", size, err)
+	}
+	return value * multiplier, nil
+}
+
 type Volume struct {
 	Name string `yaml:"name" validate:"required"`
 	Path string `yaml:"path" validate:"required,unix_path"`
 }
 
+// strictEnv makes a reference to an unset variable without a default an
+// error instead of an empty string.
+var strictEnv bool
+
+// SetStrictEnv sets whether parsing fails when the configuration references
+// an environment variable that is not set and has no default.
+func SetStrictEnv(strict bool) {
+	strictEnv = strict
+}
+
 // expandWithEnvAndDefault expands environment variables within a single string.
-// It handles `${VAR:-default}` and `${VAR:?error message}` syntax. If a required
-// variable is missing, it returns an error. Otherwise, it returns the expanded
-// string and a nil error.
+// It handles `${VAR:-default}` and `${VAR:?error message}` syntax, and `$$`
+// for a literal dollar sign. If a required variable is missing, it returns an
+// error. Otherwise, it returns the expanded string and a nil error.
 func expandWithEnvAndDefault(input string) (string, error) {
 	var expansionErr error
 
@@ -270,8 +838,35 @@ func expandWithEnvAndDefault(input string) (string, error) {
 	return expanded, expansionErr
 }
 
+// RequiredVariables returns the names of the environment variables the
+// configuration data references without a default, sorted. CI pipelines pass
+// them from their secrets.
+func RequiredVariables(data []byte) []string {
+	seen := map[string]bool{}
+	os.Expand(string(data), func(key string) string {
+		if key == "$" || strings.Contains(key, ":-") {
+			return ""
+		}
+		name, _, _ := strings.Cut(key, ":?")
+		seen[name] = true
+		return ""
+	})
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // expandOneVar handles a single ${...} expression inside os.Expand.
 func expandOneVar(key string) (string, error) {
+	// os.Expand reads "$$" as the variable "$"
+	if key == "$" {
+		return "$", nil
+	}
+
 	// Check for ":-" = default fallback
 	if strings.Contains(key, ":-") {
 		parts := strings.SplitN(key, ":-", 2)
@@ -299,12 +894,100 @@ func expandOneVar(key string) (string, error) {
 	if val, ok := os.LookupEnv(key); ok {
 		return val, nil
 	}
+	if strictEnv {
+		return "", fmt.Errorf("environment variable %s is not set, give it a default with ${%s:-default} or escape the dollar sign as $$", key, key)
+	}
 	// Not found in environment => return empty string
 	return "", nil
 }
 
+// resolvePaths makes relative local paths relative to baseDir.
+func (c *Config) resolvePaths(baseDir string) {
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(baseDir, path)
+	}
+
+	if c.Maintenance != nil {
+		c.Maintenance.Page = resolve(c.Maintenance.Page)
+	}
+	if c.Scan != nil {
+		c.Scan.Reports = resolve(c.Scan.ReportDir())
+	}
+	if c.TLS != nil {
+		c.TLS.Certificate = resolve(c.TLS.Certificate)
+		c.TLS.Key = resolve(c.TLS.Key)
+	}
+
+	for i := range c.Services {
+		service := &c.Services[i]
+		service.Path = resolve(service.Path)
+		if service.Verify != nil && !strings.Contains(service.Verify.Key, "://") {
+			service.Verify.Key = resolve(service.Verify.Key)
+		}
+		for j := range service.EnvFiles {
+			service.EnvFiles[j] = resolve(service.EnvFiles[j])
+		}
+		for j := range service.Uploads {
+			service.Uploads[j].Local = resolve(service.Uploads[j].Local)
+		}
+		if service.Build != nil {
+			for j := range service.Build.Secrets {
+				service.Build.Secrets[j].Src = resolve(service.Build.Secrets[j].Src)
+			}
+			for j, spec := range service.Build.CacheFrom {
+				service.Build.CacheFrom[j] = resolveCacheSpec(spec, baseDir)
+			}
+			for j, spec := range service.Build.CacheTo {
+				service.Build.CacheTo[j] = resolveCacheSpec(spec, baseDir)
+			}
+		}
+	}
+
+	for i := range c.Dependencies {
+		dependency := &c.Dependencies[i]
+		for j := range dependency.Init {
+			dependency.Init[j].File = resolve(dependency.Init[j].File)
+		}
+	}
+}
+
+// ParseConfigFile reads, parses and validates the configuration at path, or
+// from stdin when path is "-". Relative service and build secret paths are
+// resolved against the directory of the file, and a .env file next to it is
+// loaded; configuration read from stdin is resolved against the working
+// directory.
+func ParseConfigFile(path string) (*Config, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+		path = "."
+	} else {
+		data, err = os.ReadFile(path)
+		path = filepath.Dir(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if path != "." {
+		_ = godotenv.Load(filepath.Join(path, ".env"))
+	}
+
+	return parseConfig(data, path)
+}
+
 // ParseConfig parses and validates configuration from YAML data
 func ParseConfig(data []byte) (*Config, error) {
+	return parseConfig(data, ".")
+}
+
+func parseConfig(data []byte, baseDir string) (*Config, error) {
 	// Load any .env file from the current directory
 	_ = godotenv.Load()
 
@@ -319,37 +1002,55 @@ func ParseConfig(data []byte) (*Config, error) {
 		return nil, fmt.Errorf("error parsing YAML: %v", err)
 	}
 
-	// Set empty server if not specified
-	if config.Server == nil {
-		config.Server = &Server{}
+	if strictFields {
+		var document yaml.Node
+		_ = yaml.Unmarshal([]byte(expandedData), &document)
+		if err := checkKnownFields(&document, reflect.TypeOf(config), ""); err != nil {
+			return nil, err
+		}
 	}
 
-	// Set default host to project.domain if not specified
-	if config.Server.Host == "" {
-		config.Server.Host = config.Project.Domain
+	srcs, err := config.mergeIncludes(baseDir)
+	if err != nil {
+		return nil, err
 	}
 
-	// Set default port if not specified
-	if config.Server.Port == 0 {
-		config.Server.Port = 22
+	if baseDir != "." {
+		config.resolvePaths(baseDir)
 	}
 
-	// Set default user to current user if not specified
-	if config.Server.User == "" {
-		currentUser, err := user.Current()
+	// Merge the env files and the project-level env into every service, and
+	// the project-level env into every dependency
+	for i := range config.Services {
+		fileEnv, err := readEnvFiles(config.Services[i].EnvFiles)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get current user: %w", err)
+			return nil, fmt.Errorf("service %s: %w", config.Services[i].Name, err)
 		}
-		config.Server.User = currentUser.Username
+		config.Services[i].Env = mergeEnv(config.Env, mergeEnv(fileEnv, config.Services[i].Env))
+	}
+	for i := range config.Dependencies {
+		config.Dependencies[i].Env = mergeEnv(config.Env, config.Dependencies[i].Env)
 	}
 
-	// If no SSH key is specified, try to find a default one
-	if config.Server.SSHKey == "" {
-		defaultKey, err := findDefaultSSHKey()
-		if err != nil {
-			return nil, fmt.Errorf("no SSH key specified and failed to find default key: %w", err)
+	// Set empty server if not specified
+	if config.Server == nil {
+		config.Server = &Server{}
+	}
+	if err := config.Server.applyDefaults(config.Project.Domain); err != nil {
+		return nil, err
+	}
+	for i := range config.Environments {
+		environment := &config.Environments[i]
+		if environment.Server == nil {
+			continue
+		}
+		domain := environment.Domain
+		if domain == "" {
+			domain = config.Project.Domain
+		}
+		if err := environment.Server.applyDefaults(domain); err != nil {
+			return nil, fmt.Errorf("environment %s: %w", environment.Name, err)
 		}
-		config.Server.SSHKey = defaultKey
 	}
 
 	// Process .env files for services if they exist
@@ -366,12 +1067,13 @@ func ParseConfig(data []byte) (*Config, error) {
 	}
 
 	validate := validator.New()
+	validate.RegisterTagNameFunc(yamlFieldName)
+	validate.RegisterStructValidation(validateServiceType, Service{})
 
 	// Register custom validations
 	_ = validate.RegisterValidation("volume_reference", func(fl validator.FieldLevel) bool {
-		value := fl.Field().String()
-		parts := strings.Split(value, ":")
-		return len(parts) == 2 && parts[0] != "" && parts[1] != ""
+		_, err := ParseVolume(fl.Field().String())
+		return err == nil
 	})
 
 	_ = validate.RegisterValidation("unix_path", func(fl validator.FieldLevel) bool {
@@ -379,8 +1081,135 @@ func ParseConfig(data []byte) (*Config, error) {
 		return strings.HasPrefix(value, "/")
 	})
 
+	_ = validate.RegisterValidation("header_name", func(fl validator.FieldLevel) bool {
+		return headerNameRegex.MatchString(fl.Field().String())
+	})
+
+	_ = validate.RegisterValidation("cors_origin", func(fl validator.FieldLevel) bool {
+		return corsOriginRegex.MatchString(fl.Field().String())
+	})
+
+	_ = validate.RegisterValidation("bandwidth", func(fl validator.FieldLevel) bool {
+		_, err := BandwidthBytes(fl.Field().String())
+		return err == nil
+	})
+
+	_ = validate.RegisterValidation("rate", func(fl validator.FieldLevel) bool {
+		return rateRegex.MatchString(fl.Field().String())
+	})
+
+	_ = validate.RegisterValidation("htpasswd_user", func(fl validator.FieldLevel) bool {
+		return htpasswdUserRegex.MatchString(fl.Field().String())
+	})
+
+	_ = validate.RegisterValidation("docker_host", func(fl validator.FieldLevel) bool {
+		return dockerHostRegex.MatchString(fl.Field().String())
+	})
+
+	_ = validate.RegisterValidation("cache_spec", func(fl validator.FieldLevel) bool {
+		return validCacheSpec(fl.Field().String())
+	})
+
+	_ = validate.RegisterValidation("platform", func(fl validator.FieldLevel) bool {
+		return platformRegex.MatchString(fl.Field().String())
+	})
+
+	_ = validate.RegisterValidation("cipher_suite", func(fl validator.FieldLevel) bool {
+		return cipherSuiteRegex.MatchString(fl.Field().String())
+	})
+
+	_ = validate.RegisterValidation("memory_size", func(fl validator.FieldLevel) bool {
+		return memorySizeRegex.MatchString(fl.Field().String())
+	})
+
+	_ = validate.RegisterValidation("restart_policy", func(fl validator.FieldLevel) bool {
+		return restartPolicyRegex.MatchString(fl.Field().String())
+	})
+
+	_ = validate.RegisterValidation("stop_signal", func(fl validator.FieldLevel) bool {
+		return stopSignalRegex.MatchString(fl.Field().String())
+	})
+
+	_ = validate.RegisterValidation("network_name", func(fl validator.FieldLevel) bool {
+		return networkNameRegex.MatchString(fl.Field().String())
+	})
+
+	_ = validate.RegisterValidation("file_mode", func(fl validator.FieldLevel) bool {
+		return fileModeRegex.MatchString(fl.Field().String())
+	})
+
+	_ = validate.RegisterValidation("owner", func(fl validator.FieldLevel) bool {
+		return ownerRegex.MatchString(fl.Field().String())
+	})
+
 	if err := validate.Struct(config); err != nil {
-		return nil, fmt.Errorf("validation error: %v", err)
+		var document yaml.Node
+		_ = yaml.Unmarshal([]byte(expandedData), &document)
+		return nil, fmt.Errorf("validation error: %w", newValidationErrors(err, &document, srcs))
+	}
+
+	if config.TLS != nil && config.TLS.HSTS != nil {
+		if err := config.TLS.HSTS.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := config.validateNetworks(); err != nil {
+		return nil, err
+	}
+
+	if err := config.validateDependencyOrder(); err != nil {
+		return nil, err
+	}
+
+	if err := config.validateAliases(); err != nil {
+		return nil, err
+	}
+	config.injectAliasEnv()
+
+	if err := config.validateStreams(); err != nil {
+		return nil, err
+	}
+
+	if err := config.validateWorkers(); err != nil {
+		return nil, err
+	}
+
+	if err := config.validateInit(); err != nil {
+		return nil, err
+	}
+
+	if err := config.validatePreBuild(); err != nil {
+		return nil, err
+	}
+
+	if err := config.validateBuilder(); err != nil {
+		return nil, err
+	}
+
+	if err := config.validateStrategies(); err != nil {
+		return nil, err
+	}
+
+	if err := config.validateHealthChecks(); err != nil {
+		return nil, err
+	}
+
+	if err := config.validateImageTemplates(); err != nil {
+		return nil, err
+	}
+
+	for _, service := range config.Services {
+		for _, route := range service.Routes {
+			for _, m := range route.Middleware {
+				if m.CORS != nil && m.CORS.AllowCredentials && m.CORS.AllowsAnyOrigin() {
+					return nil, fmt.Errorf("service %s: cors allow_credentials cannot be combined with the \"*\" origin", service.Name)
+				}
+			}
+		}
+		if service.Build != nil && len(service.Build.Platforms) > 1 && service.Image == "" {
+			return nil, fmt.Errorf("service %s: building for several platforms requires an image to push the multi-platform image to", service.Name)
+		}
 	}
 
 	// Only collect volumes if there are explicitly defined volumes or if we're using default configs
@@ -431,6 +1260,169 @@ func ParseConfig(data []byte) (*Config, error) {
 	return &config, nil
 }
 
+// applyDefaults fills in the unset connection settings of s: the host is
+// domain, the port 22, the user the current user and the key the first
+// default SSH key found.
+func (s *Server) applyDefaults(domain string) error {
+	// Set default host to project.domain if not specified
+	if s.Host == "" {
+		s.Host = domain
+	}
+	s.Host = unbracketHost(s.Host)
+
+	// Set default port if not specified
+	if s.Port == 0 {
+		s.Port = 22
+	}
+
+	// Set default user to current user if not specified
+	if s.User == "" {
+		currentUser, err := user.Current()
+		if err != nil {
+			return fmt.Errorf("failed to get current user: %w", err)
+		}
+		s.User = currentUser.Username
+	}
+
+	// If no SSH key is specified, try to find a default one, unless the key
+	// itself is set in the environment. Without one, every key of a running
+	// ssh-agent is offered.
+	if s.SSHKey == "" && os.Getenv(ssh.KeyEnv) == "" {
+		defaultKey, err := findDefaultSSHKey()
+		if err != nil && !s.hasAgent() {
+			return fmt.Errorf("no SSH key specified, no ssh-agent is running and failed to find default key: %w", err)
+		}
+		s.SSHKey = defaultKey
+	}
+
+	if jump := s.ProxyJump; jump != nil {
+		jump.Host = unbracketHost(jump.Host)
+		if jump.Port == 0 {
+			jump.Port = 22
+		}
+		if jump.User == "" {
+			jump.User = s.User
+		}
+		if jump.SSHKey == "" {
+			jump.SSHKey = s.SSHKey
+		}
+	}
+
+	return nil
+}
+
+// unbracketHost returns host without the brackets of an IPv6 literal written
+// as in a URL, "[2001:db8::1]", so it validates and dials as an IP address.
+func unbracketHost(host string) string {
+	if inner, ok := strings.CutPrefix(host, "["); ok {
+		if inner, ok := strings.CutSuffix(inner, "]"); ok && net.ParseIP(inner) != nil {
+			return inner
+		}
+	}
+	return host
+}
+
+// hasAgent reports whether an ssh-agent is configured for the server.
+func (s *Server) hasAgent() bool {
+	if s.IdentityAgent != "" {
+		return s.IdentityAgent != ssh.NoAgent
+	}
+	return os.Getenv(ssh.AgentEnv) != ""
+}
+
+// readEnvFiles reads the variables of the env files in order; a variable set
+// by a later file overrides the earlier ones. Every file must exist.
+func readEnvFiles(paths []string) ([]string, error) {
+	var env []string
+	for _, path := range paths {
+		values, err := godotenv.Read(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read env file %s: %w", path, err)
+		}
+
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		fileEnv := make([]string, 0, len(keys))
+		for _, key := range keys {
+			fileEnv = append(fileEnv, key+"="+values[key])
+		}
+		env = mergeEnv(env, fileEnv)
+	}
+	return env, nil
+}
+
+// ApplyProfiles removes the services and dependencies that belong to profiles
+// none of which is active. Services and dependencies without profiles are
+// always kept. It fails for a profile that nothing in the configuration uses.
+func (c *Config) ApplyProfiles(active []string) error {
+	used := map[string]struct{}{}
+	enabled := func(profiles []string) bool {
+		for _, profile := range profiles {
+			used[profile] = struct{}{}
+		}
+		if len(profiles) == 0 {
+			return true
+		}
+		for _, profile := range profiles {
+			if slices.Contains(active, profile) {
+				return true
+			}
+		}
+		return false
+	}
+
+	services := c.Services[:0]
+	for _, service := range c.Services {
+		if enabled(service.Profiles) {
+			services = append(services, service)
+		}
+	}
+	c.Services = services
+
+	dependencies := c.Dependencies[:0]
+	for _, dependency := range c.Dependencies {
+		if enabled(dependency.Profiles) {
+			dependencies = append(dependencies, dependency)
+		}
+	}
+	c.Dependencies = dependencies
+
+	for _, profile := range active {
+		if _, ok := used[profile]; !ok {
+			return fmt.Errorf("profile %q is not used by any service or dependency", profile)
+		}
+	}
+
+	return nil
+}
+
+// mergeEnv returns the variables of shared that env does not set, followed by
+// env, so values set by a service or dependency take precedence.
+func mergeEnv(shared, env []string) []string {
+	if len(shared) == 0 {
+		return env
+	}
+
+	own := make(map[string]struct{}, len(env))
+	for _, variable := range env {
+		key, _, _ := strings.Cut(variable, "=")
+		own[key] = struct{}{}
+	}
+
+	merged := make([]string, 0, len(shared)+len(env))
+	for _, variable := range shared {
+		key, _, _ := strings.Cut(variable, "=")
+		if _, ok := own[key]; !ok {
+			merged = append(merged, variable)
+		}
+	}
+	return append(merged, env...)
+}
+
 // extractNamedVolume checks if volRef is in the form "NAME:/some/path"
 // and if NAME starts with a letter. If so, it returns NAME; otherwise "".
 func extractNamedVolume(volRef string) string {
@@ -453,9 +1445,45 @@ func extractNamedVolume(volRef string) string {
 	return ""
 }
 
+// Hash returns a digest of the project, services and dependencies, used to
+// tell which configuration a deploy was made from. Server and connection
+// settings are left out so every user deploying the same ftl.yaml gets the
+// same hash.
+func (c *Config) Hash() (string, error) {
+	bytes, err := json.Marshal(struct {
+		Project      Project
+		Services     []Service
+		Dependencies []Dependency
+	}{c.Project, c.Services, c.Dependencies})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	hash := sha256.Sum256(bytes)
+	return hex.EncodeToString(hash[:]), nil
+}
+
 func (s *Service) Hash() (string, error) {
 	service := *s
 	service.ImageUpdated = false
+	// Build settings only change the image, which is tracked separately, and
+	// the drain timeout and strategy only affect how the previous container
+	// is retired.
+	service.Build = nil
+	service.DrainTimeout = 0
+	service.Strategy = ""
+	// Scaling adds or removes replicas without replacing the others,
+	// verification only checks the image before it is pulled and smoke tests
+	// only check the container after cutover.
+	service.Replicas = 0
+	service.Verify = nil
+	service.SmokeTests = nil
+	service.Revision = Revision{}
+	if service.Pinned {
+		service.Image = service.PinnedFrom
+		service.Pinned = false
+		service.PinnedFrom = ""
+	}
 	sortedService := service.sortServiceFields()
 	bytes, err := json.Marshal(sortedService)
 	if err != nil {
@@ -509,6 +1537,33 @@ func sortMap(m reflect.Value) map[string]interface{} {
 	return sorted
 }
 
+// Domains returns the unique set of domains served by the project: the project
+// domain first, followed by any per-service domains in config order.
+func (c *Config) Domains() []string {
+	domains := []string{c.Project.Domain}
+	seen := map[string]struct{}{c.Project.Domain: {}}
+	for _, svc := range c.Services {
+		if svc.Domain == "" {
+			continue
+		}
+		if _, ok := seen[svc.Domain]; ok {
+			continue
+		}
+		seen[svc.Domain] = struct{}{}
+		domains = append(domains, svc.Domain)
+	}
+	return domains
+}
+
+// ServiceDomain returns the domain a service is routed under, falling back to
+// the project domain when the service does not declare its own.
+func (c *Config) ServiceDomain(svc *Service) string {
+	if svc.Domain != "" {
+		return svc.Domain
+	}
+	return c.Project.Domain
+}
+
 // findDefaultSSHKey searches for SSH keys in the default locations
 func findDefaultSSHKey() (string, error) {
 	home, err := os.UserHomeDir()
@@ -518,10 +1573,12 @@ func findDefaultSSHKey() (string, error) {
 
 	sshDir := filepath.Join(home, ".ssh")
 	keyNames := []string{
-		"id_ed25519", // Ed25519 - modern, preferred
-		"id_rsa",     // RSA
-		"id_ecdsa",   // ECDSA
-		"id_dsa",     // DSA - deprecated, less secure
+		"id_ed25519",    // Ed25519 - modern, preferred
+		"id_ed25519_sk", // Ed25519 on a FIDO2 security key, used through ssh-agent
+		"id_rsa",        // RSA
+		"id_ecdsa",      // ECDSA
+		"id_ecdsa_sk",   // ECDSA on a FIDO2 security key, used through ssh-agent
+		"id_dsa",        // DSA - deprecated, less secure
 	}
 
 	for _, name := range keyNames {
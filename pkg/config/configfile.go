@@ -0,0 +1,51 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+)
+
+// defaultConfigMode is the mode of config files that do not set one, readable
+// by whichever user the container runs as.
+const defaultConfigMode = "0644"
+
+// ConfigFile is a file of a dependency given inline in ftl.yaml, written to
+// the server and mounted read-only into the container, to tune an image
+// without building a custom one:
+//
+//	dependencies:
+//	  - name: postgres
+//	    image: postgres:16
+//	    command: ["postgres", "-c", "config_file=/etc/postgresql/postgresql.conf"]
+//	    configs:
+//	      - target: /etc/postgresql/postgresql.conf
+//	        content: |
+//	          listen_addresses = '*'
+//	          shared_buffers = 256MB
+//
+// Target is the absolute path of the file in the container and Mode its
+// permissions, 0644 by default. A changed file replaces the container.
+type ConfigFile struct {
+	Target  string `yaml:"target" validate:"required,startswith=/,excludes=:"`
+	Content string `yaml:"content"`
+	Mode    string `yaml:"mode" validate:"omitempty,file_mode"`
+}
+
+// FileMode returns the mode of the file, the default unless one is set.
+func (c *ConfigFile) FileMode() string {
+	if c.Mode == "" {
+		return defaultConfigMode
+	}
+	return c.Mode
+}
+
+// FileName returns the name the file is stored under on the server. It
+// changes with the content and mode, so a changed file is mounted from a new
+// path and the container of the dependency is replaced.
+func (c *ConfigFile) FileName() string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "mode=%s\n%s", c.FileMode(), c.Content)
+	return hex.EncodeToString(hash.Sum(nil))[:12] + "-" + path.Base(c.Target)
+}
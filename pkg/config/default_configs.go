@@ -1,5 +1,10 @@
 package config
 
+import (
+	"sort"
+	"strings"
+)
+
 // defaultConfigs holds "base name" → default configuration
 // (image, ports, volumes, environment variables, container settings, etc.).
 var defaultConfigs = map[string]Dependency{
@@ -85,3 +90,28 @@ var defaultConfigs = map[string]Dependency{
 		},
 	},
 }
+
+// DefaultDependency returns the default configuration of a dependency given
+// in the short form, as "postgres" or "postgres:16".
+func DefaultDependency(value string) (*Dependency, bool) {
+	base, version, found := strings.Cut(value, ":")
+	if !found {
+		version = "latest"
+	}
+	dep, ok := getDefaultConfig(base, version)
+	if !ok {
+		return nil, false
+	}
+	return dep, true
+}
+
+// DefaultDependencyNames returns the dependencies that have a default
+// configuration, sorted.
+func DefaultDependencyNames() []string {
+	names := make([]string, 0, len(defaultConfigs))
+	for name := range defaultConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,39 @@
+package config
+
+// DNS providers that create the records of the project domains.
+const (
+	DNSCloudflare = "cloudflare"
+	DNSRoute53    = "route53"
+)
+
+// defaultDNSTTL is the TTL of created records, short enough that a record
+// pointed at a new server takes effect soon.
+const defaultDNSTTL = 300
+
+// DNS makes deploys verify that the A and AAAA records of the project domains
+// point at the server, failing the deploy when they do not:
+//
+//	dns:
+//	  provider: cloudflare
+//	  zone: example.com
+//
+// With a provider the records that are missing or point elsewhere are
+// created or updated instead, in Zone, or the zone found for each domain
+// when it is not set. Resolver is the DNS server to query, as
+// "1.1.1.1:53", instead of the resolver of the system, whose cache may still
+// hold old records. Without a dns section the first deploy of a project only
+// warns about records that do not point at the server.
+type DNS struct {
+	Provider string `yaml:"provider" validate:"omitempty,oneof=cloudflare route53"`
+	Zone     string `yaml:"zone" validate:"omitempty,fqdn"`
+	TTL      int    `yaml:"ttl" validate:"omitempty,min=1"`
+	Resolver string `yaml:"resolver" validate:"omitempty,hostname_port"`
+}
+
+// RecordTTL returns the TTL of created records, 300 seconds by default.
+func (d *DNS) RecordTTL() int {
+	if d == nil || d.TTL == 0 {
+		return defaultDNSTTL
+	}
+	return d.TTL
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration that unmarshals from Go duration strings such
+// as "10s" or "1m30s", or from plain YAML integers, which are read as seconds.
+type Duration time.Duration
+
+// Duration returns d as a time.Duration.
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// String formats d the way time.Duration does, e.g. "1m30s".
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// UnmarshalYAML parses a duration and, when the value looks like a spelled-out
+// unit ("10 seconds"), suggests the equivalent Go duration in the error.
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: duration must be a string like \"10s\" or a number of seconds", node.Line)
+	}
+
+	if node.Tag == "!!int" {
+		seconds, err := strconv.ParseInt(node.Value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid duration %q: %w", node.Line, node.Value, err)
+		}
+		*d = Duration(time.Duration(seconds) * time.Second)
+		return nil
+	}
+
+	value := strings.TrimSpace(node.Value)
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		if suggestion, ok := suggestDuration(value); ok {
+			return fmt.Errorf("line %d: invalid duration %q, did you mean %q?", node.Line, node.Value, suggestion)
+		}
+		return fmt.Errorf("line %d: invalid duration %q, use a value like \"10s\", \"1m30s\" or a number of seconds", node.Line, node.Value)
+	}
+	if parsed < 0 {
+		return fmt.Errorf("line %d: duration %q must not be negative", node.Line, node.Value)
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+var spelledDurationRegex = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([a-zA-Z]+)$`)
+
+var durationUnits = map[string]string{
+	"ms": "ms", "msec": "ms", "msecs": "ms", "millisecond": "ms", "milliseconds": "ms",
+	"s": "s", "sec": "s", "secs": "s", "second": "s", "seconds": "s",
+	"m": "m", "min": "m", "mins": "m", "minute": "m", "minutes": "m",
+	"h": "h", "hr": "h", "hrs": "h", "hour": "h", "hours": "h",
+}
+
+// suggestDuration maps values like "10 seconds" or "5min" to "10s" and "5m".
+func suggestDuration(value string) (string, bool) {
+	match := spelledDurationRegex.FindStringSubmatch(value)
+	if match == nil {
+		return "", false
+	}
+
+	unit, ok := durationUnits[strings.ToLower(match[2])]
+	if !ok {
+		return "", false
+	}
+
+	return match[1] + unit, true
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SetServerHost sets server.host in the configuration file at path. The file
+// is edited in place through its YAML tree, so comments and the order of keys
+// are kept.
+func SetServerHost(path, host string) error {
+	return SetServerValues(path, map[string]string{"host": host})
+}
+
+// SetServerValues sets the keys of values under server in the configuration
+// file at path, in place like SetServerHost.
+func SetServerValues(path string, values map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if len(document.Content) == 0 || document.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("config file is not a mapping")
+	}
+
+	root := document.Content[0]
+	server := childNode(root, "server")
+	if server == nil || server.Kind != yaml.MappingNode {
+		server = &yaml.Node{Kind: yaml.MappingNode}
+		setMappingValue(root, "server", server)
+	}
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		value := &yaml.Node{}
+		value.SetString(values[key])
+		setMappingValue(server, key, value)
+	}
+
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return fmt.Errorf("failed to encode config file: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to encode config file: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat config file: %w", err)
+	}
+	if err := os.WriteFile(path, b.Bytes(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// setMappingValue replaces the value of key in the mapping node, or appends
+// the key when it is missing.
+func setMappingValue(mapping *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			value.LineComment = mapping.Content[i+1].LineComment
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Environment is a deploy target such as staging or production. Once
+// selected its settings replace those of the top-level configuration: Domain
+// the project domain, Project the project name, so that environments can share
+// a server, and Server the server, whose host defaults to Domain. Env is set on every service and dependency,
+// overriding their own values. `ftl deploy --all-envs` deploys the
+// environments in the order they are listed and asks before deploying those
+// with Confirm.
+//
+//	environments:
+//	  - name: staging
+//	    domain: staging.example.com
+//	    project: my-project-staging
+//	  - name: production
+//	    server:
+//	      host: prod.example.com
+//	    env:
+//	      - LOG_LEVEL=warn
+//	    confirm: true
+type Environment struct {
+	Name    string   `yaml:"name" validate:"required"`
+	Domain  string   `yaml:"domain" validate:"omitempty,fqdn"`
+	Project string   `yaml:"project"`
+	Server  *Server  `yaml:"server"`
+	Env     []string `yaml:"env"`
+	Confirm bool     `yaml:"confirm"`
+}
+
+// EnvironmentNames returns the names of the environments in order.
+func (c *Config) EnvironmentNames() []string {
+	names := make([]string, 0, len(c.Environments))
+	for _, environment := range c.Environments {
+		names = append(names, environment.Name)
+	}
+	return names
+}
+
+// Environment returns the environment called name.
+func (c *Config) Environment(name string) (*Environment, error) {
+	i := slices.IndexFunc(c.Environments, func(e Environment) bool { return e.Name == name })
+	if i == -1 {
+		return nil, fmt.Errorf("unknown environment %q", name)
+	}
+	return &c.Environments[i], nil
+}
+
+// ApplyEnvironment replaces the settings of c with those of the environment
+// called name.
+func (c *Config) ApplyEnvironment(name string) error {
+	environment, err := c.Environment(name)
+	if err != nil {
+		return err
+	}
+
+	if environment.Domain != "" {
+		c.Project.Domain = environment.Domain
+	}
+	if environment.Project != "" {
+		c.Project.Name = environment.Project
+	}
+	if environment.Server != nil {
+		c.Server = environment.Server
+	}
+	for i := range c.Services {
+		c.Services[i].Env = mergeEnv(c.Services[i].Env, environment.Env)
+	}
+	for i := range c.Dependencies {
+		c.Dependencies[i].Env = mergeEnv(c.Dependencies[i].Env, environment.Env)
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationError describes a configuration value that failed validation.
+// Programs embedding the package can get all of them from a ParseConfig error
+// with errors.As and a ValidationErrors target.
+type ValidationError struct {
+	// File is the included file that defines the value, relative to the
+	// configuration file, or empty for the configuration file itself.
+	File string
+	// Path locates the value by its YAML keys in File, e.g.
+	// "services[0].routes[0].path".
+	Path string
+	// Rule is the validation rule that failed, e.g. "required" or "fqdn", and
+	// Param its argument, e.g. "65535" for "max=65535".
+	Rule  string
+	Param string
+	// Value is the offending value, the zero value of the field when it is missing.
+	Value any
+	// Line is the line of the value in the YAML document of File, or of its closest
+	// enclosing key when the value is missing. It is 0 when unknown.
+	Line int
+
+	namespace string
+	field     string
+}
+
+func (e *ValidationError) Error() string {
+	message := fmt.Sprintf("Key: '%s' Error:Field validation for '%s' failed on the '%s' tag", e.namespace, e.field, e.Rule)
+	if e.File != "" {
+		return e.File + ": " + message
+	}
+	return message
+}
+
+// ValidationErrors lists every validation failure of a configuration.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// yamlFieldName names struct fields after their YAML key in validation paths.
+func yamlFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "-" || name == "_" {
+		return ""
+	}
+	return name
+}
+
+// newValidationErrors converts validator output into ValidationErrors, looking
+// up lines in the parsed document, or in the document of the included file
+// that defines the value according to srcs.
+func newValidationErrors(err error, document *yaml.Node, srcs sources) error {
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return err
+	}
+
+	errs := make(ValidationErrors, 0, len(fieldErrors))
+	for _, fe := range fieldErrors {
+		path := fe.Namespace()
+		if _, rest, ok := strings.Cut(path, "."); ok {
+			path = rest
+		}
+		namespace := fe.StructNamespace()
+		root, structPath, _ := strings.Cut(namespace, ".")
+
+		doc := document
+		src, path := srcs.locate(path)
+		if src.file != "" {
+			doc = src.document
+			_, structPath = srcs.locate(structPath)
+			namespace = root + "." + structPath
+		}
+		errs = append(errs, &ValidationError{
+			File:      src.file,
+			Path:      path,
+			Rule:      fe.Tag(),
+			Param:     fe.Param(),
+			Value:     fe.Value(),
+			Line:      lineOf(doc, path),
+			namespace: namespace,
+			field:     fe.StructField(),
+		})
+	}
+
+	return errs
+}
+
+// lineOf returns the line of the node at path, or of the deepest node on the
+// way to it that exists.
+func lineOf(document *yaml.Node, path string) int {
+	if document == nil || len(document.Content) == 0 {
+		return 0
+	}
+
+	node := document.Content[0]
+	line := node.Line
+	for _, segment := range pathSegments(path) {
+		next := childNode(node, segment)
+		if next == nil {
+			break
+		}
+		node = next
+		line = node.Line
+	}
+
+	return line
+}
+
+// pathSegments splits "a[0].b[key]" into "a", "0", "b" and "key".
+func pathSegments(path string) []string {
+	var segments []string
+	for _, part := range strings.Split(path, ".") {
+		name, rest, _ := strings.Cut(part, "[")
+		if name != "" {
+			segments = append(segments, name)
+		}
+		for rest != "" {
+			var index string
+			index, rest, _ = strings.Cut(rest, "]")
+			segments = append(segments, index)
+			rest = strings.TrimPrefix(rest, "[")
+		}
+	}
+	return segments
+}
+
+func childNode(node *yaml.Node, segment string) *yaml.Node {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == segment {
+				return node.Content[i+1]
+			}
+		}
+	case yaml.SequenceNode:
+		if index, err := strconv.Atoi(segment); err == nil && index >= 0 && index < len(node.Content) {
+			return node.Content[index]
+		}
+	}
+	return nil
+}
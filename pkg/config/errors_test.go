@@ -0,0 +1,52 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationErrors(t *testing.T) {
+	yamlData := []byte(`project:
+  name: test-project
+  domain: example.com
+  email: admin@example.com
+services:
+  - name: web
+    image: web:latest
+    port: 70000
+    routes:
+      - path: /
+  - name: api
+    image: api:latest
+`)
+
+	_, err := ParseConfig(yamlData)
+	require.Error(t, err)
+
+	var errs ValidationErrors
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 3)
+
+	assert.Equal(t, "services[0].port", errs[0].Path)
+	assert.Equal(t, "max", errs[0].Rule)
+	assert.Equal(t, "65535", errs[0].Param)
+	assert.Equal(t, 70000, errs[0].Value)
+	assert.Equal(t, 8, errs[0].Line)
+	assert.Equal(t, "Key: 'Config.Services[0].Port' Error:Field validation for 'Port' failed on the 'max' tag", errs[0].Error())
+
+	assert.Equal(t, "services[1].port", errs[1].Path)
+	assert.Equal(t, "required_without", errs[1].Rule)
+	assert.Equal(t, 11, errs[1].Line)
+
+	assert.Equal(t, "services[1].routes", errs[2].Path)
+	assert.Equal(t, "required_without", errs[2].Rule)
+}
+
+func TestPathSegments(t *testing.T) {
+	assert.Equal(t, []string{"services", "0", "routes", "1", "middleware", "0", "headers", "set", "X Frame"},
+		pathSegments("services[0].routes[1].middleware[0].headers.set[X Frame]"))
+	assert.Equal(t, []string{"project", "domain"}, pathSegments("project.domain"))
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// validateHealthChecks checks that the response assertions of the health
+// checks are valid and only set on http health checks.
+func (c *Config) validateHealthChecks() error {
+	for _, service := range c.Services {
+		hc := service.HealthCheck
+		if hc == nil {
+			continue
+		}
+		if hc.Type == HealthCheckGRPC && (len(hc.Status) > 0 || hc.Body != "" || len(hc.JSON) > 0) {
+			return fmt.Errorf("service %s: status, body and json only apply to http health checks", service.Name)
+		}
+		if _, err := regexp.Compile(hc.Body); err != nil {
+			return fmt.Errorf("service %s: invalid health check body: %w", service.Name, err)
+		}
+		for key := range hc.JSON {
+			if key == "" || slices.Contains(strings.Split(key, "."), "") {
+				return fmt.Errorf("service %s: invalid health check json path %q", service.Name, key)
+			}
+		}
+	}
+	return nil
+}
+
+// ChecksBody reports whether the health check asserts on the response body.
+func (h *ServiceHealthCheck) ChecksBody() bool {
+	return h.Body != "" || len(h.JSON) > 0
+}
+
+// CheckResponse returns why a response with status and body is not healthy,
+// or nil when it is.
+func (h *ServiceHealthCheck) CheckResponse(status int, body []byte) error {
+	if len(h.Status) > 0 && !slices.Contains(h.Status, status) {
+		return fmt.Errorf("GET %s returned status %d, expected %s", h.Path, status, joinStatuses(h.Status))
+	}
+	if len(h.Status) == 0 && (status < 100 || status >= 400) {
+		return fmt.Errorf("GET %s returned status %d", h.Path, status)
+	}
+
+	if h.Body != "" {
+		matched, err := regexp.Match(h.Body, body)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			return fmt.Errorf("GET %s returned a body that does not match %q", h.Path, h.Body)
+		}
+	}
+
+	if len(h.JSON) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return fmt.Errorf("GET %s returned a body that is not JSON: %w", h.Path, err)
+	}
+
+	keys := make([]string, 0, len(h.JSON))
+	for key := range h.JSON {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		value, ok := jsonValue(document, strings.Split(key, "."))
+		if !ok {
+			return fmt.Errorf("GET %s returned JSON without %s", h.Path, key)
+		}
+		if value != h.JSON[key] {
+			return fmt.Errorf("GET %s returned %s %q, expected %q", h.Path, key, value, h.JSON[key])
+		}
+	}
+	return nil
+}
+
+// StatusPattern returns an extended regular expression matching the
+// accepted statuses.
+func (h *ServiceHealthCheck) StatusPattern() string {
+	return "^(" + strings.ReplaceAll(joinStatuses(h.Status), ", ", "|") + ")$"
+}
+
+func joinStatuses(statuses []int) string {
+	parts := make([]string, len(statuses))
+	for i, status := range statuses {
+		parts[i] = strconv.Itoa(status)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// jsonValue returns the value at path in document as text: strings as they
+// are, other values as JSON. Numeric segments index arrays.
+func jsonValue(document any, path []string) (string, bool) {
+	for _, segment := range path {
+		switch node := document.(type) {
+		case map[string]any:
+			value, ok := node[segment]
+			if !ok {
+				return "", false
+			}
+			document = value
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return "", false
+			}
+			document = node[index]
+		default:
+			return "", false
+		}
+	}
+
+	if text, ok := document.(string); ok {
+		return text, true
+	}
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return "", false
+	}
+	return string(encoded), true
+}
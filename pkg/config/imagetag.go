@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// ImageVars are the variables the image of a service may use, as in
+//
+//	image: ghcr.io/acme/web:{{ .GitSHA }}
+//
+// so every build gets a tag of its own. The git variables and Timestamp
+// describe the checked out commit, so `ftl build` and a later `ftl deploy`
+// of the same commit render the same tag. A variable that is empty, such as
+// GitTag on a commit without a tag, fails the image that uses it.
+type ImageVars struct {
+	// GitSHA is the short SHA of the commit.
+	GitSHA string
+	// Revision is GitSHA with a "-dirty" suffix when the working tree has
+	// uncommitted changes.
+	Revision string
+	// GitBranch is the checked out branch, with characters a tag cannot hold
+	// replaced by "-".
+	GitBranch string
+	// GitTag is the git tag of the commit.
+	GitTag string
+	// Timestamp is the time of the commit in UTC, as 20060102150405.
+	Timestamp string
+	// Project is the name of the project, and Service that of the service.
+	Project string
+	Service string
+	// Env is the environment selected with --env.
+	Env string
+}
+
+// imageVarNames lists the variables of ImageVars, in the order they are
+// documented.
+var imageVarNames = []string{"GitSHA", "Revision", "GitBranch", "GitTag", "Timestamp", "Project", "Service", "Env"}
+
+// invalidTagChars matches the characters an image tag cannot hold.
+var invalidTagChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// TagValue replaces the characters an image tag cannot hold, such as the
+// slash of a branch like feature/login, with "-".
+func TagValue(value string) string {
+	return invalidTagChars.ReplaceAllString(value, "-")
+}
+
+// UsesImageVars reports whether the image of a service uses ImageVars.
+func (c *Config) UsesImageVars() bool {
+	for _, service := range c.Services {
+		if strings.Contains(service.Image, "{{") {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderImages renders the images of the services that use ImageVars. The
+// service name is set for each service.
+func (c *Config) RenderImages(vars ImageVars) error {
+	for i := range c.Services {
+		service := &c.Services[i]
+		if !strings.Contains(service.Image, "{{") {
+			continue
+		}
+
+		vars.Service = service.Name
+		image, err := renderImage(service.Image, vars.values())
+		if err != nil {
+			return fmt.Errorf("service %s: %w", service.Name, err)
+		}
+		service.Image = image
+	}
+	return nil
+}
+
+// validateImageTemplates checks that the images of the services render,
+// using only the variables of ImageVars.
+func (c *Config) validateImageTemplates() error {
+	placeholders := map[string]string{}
+	for _, name := range imageVarNames {
+		placeholders[name] = "x"
+	}
+
+	for _, service := range c.Services {
+		if !strings.Contains(service.Image, "{{") {
+			continue
+		}
+		if _, err := renderImage(service.Image, placeholders); err != nil {
+			return fmt.Errorf("service %s: %w", service.Name, err)
+		}
+	}
+	return nil
+}
+
+// values returns the variables to render with. Empty ones are left out, so
+// an image using one fails to render instead of getting an empty tag.
+func (v ImageVars) values() map[string]string {
+	values := map[string]string{}
+	for name, value := range map[string]string{
+		"GitSHA":    v.GitSHA,
+		"Revision":  v.Revision,
+		"GitBranch": v.GitBranch,
+		"GitTag":    v.GitTag,
+		"Timestamp": v.Timestamp,
+		"Project":   v.Project,
+		"Service":   v.Service,
+		"Env":       v.Env,
+	} {
+		if value != "" {
+			values[name] = value
+		}
+	}
+	return values
+}
+
+func renderImage(image string, values map[string]string) (string, error) {
+	tmpl, err := template.New("image").Option("missingkey=error").Parse(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image %q: %w", image, err)
+	}
+
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, values); err != nil {
+		var missing []string
+		for _, name := range imageVarNames {
+			if _, ok := values[name]; !ok && strings.Contains(image, "."+name) {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			return "", fmt.Errorf("image %q uses variables that are not set: %s", image, strings.Join(missing, ", "))
+		}
+		return "", fmt.Errorf("image %q uses an unknown variable (available: %s)", image, strings.Join(imageVarNames, ", "))
+	}
+	return rendered.String(), nil
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// includableKeys are the top-level keys an included file may set. Everything
+// else belongs to the main configuration file.
+var includableKeys = []string{"services", "dependencies", "volumes"}
+
+// includedConfig is the part of the configuration an included file defines.
+type includedConfig struct {
+	Services     []Service    `yaml:"services"`
+	Dependencies []Dependency `yaml:"dependencies"`
+	Volumes      []string     `yaml:"volumes"`
+}
+
+// source locates a service or dependency of the merged configuration in the
+// file that defines it: entry index of the list in file, with document its
+// parsed YAML. file is empty for the main configuration file.
+type source struct {
+	file     string
+	document *yaml.Node
+	index    int
+}
+
+// sources lists the source of every entry of the "services" and
+// "dependencies" lists of the merged configuration.
+type sources map[string][]source
+
+// locate returns the source of the value at path, a validation path such as
+// "services[3].port" or "Services[3].Port", and the path of the value in that
+// source.
+func (s sources) locate(path string) (source, string) {
+	key, rest, ok := strings.Cut(path, "[")
+	if !ok {
+		return source{}, path
+	}
+	index, rest, ok := strings.Cut(rest, "]")
+	list := s[strings.ToLower(key)]
+	i, err := strconv.Atoi(index)
+	if !ok || err != nil || i < 0 || i >= len(list) {
+		return source{}, path
+	}
+	src := list[i]
+	if src.file == "" {
+		return source{}, path
+	}
+	return src, fmt.Sprintf("%s[%d]%s", key, src.index, rest)
+}
+
+// describe names the file of src in error messages.
+func (src source) describe() string {
+	if src.file == "" {
+		return "the configuration file"
+	}
+	return src.file
+}
+
+// mergeIncludes appends the services, dependencies and volumes of the files
+// listed in Include, which may be glob patterns relative to baseDir, and
+// checks that no service or dependency name is defined twice. Relative paths
+// in included services and dependencies are resolved against the directory of
+// their file.
+func (c *Config) mergeIncludes(baseDir string) (sources, error) {
+	srcs := sources{}
+	for i := range c.Services {
+		srcs["services"] = append(srcs["services"], source{index: i})
+	}
+	for i := range c.Dependencies {
+		srcs["dependencies"] = append(srcs["dependencies"], source{index: i})
+	}
+
+	for _, pattern := range c.Include {
+		matches, err := filepath.Glob(filepath.Join(baseDir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("include %s: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("include %s: no such file", pattern)
+		}
+		for _, match := range matches {
+			name, err := filepath.Rel(baseDir, match)
+			if err != nil {
+				name = match
+			}
+			if err := c.mergeInclude(match, name, srcs); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := checkDuplicateNames("service", srcs["services"], func(i int) string { return c.Services[i].Name }); err != nil {
+		return nil, err
+	}
+	if err := checkDuplicateNames("dependency", srcs["dependencies"], func(i int) string { return c.Dependencies[i].Name }); err != nil {
+		return nil, err
+	}
+
+	return srcs, nil
+}
+
+func (c *Config) mergeInclude(path, name string, srcs sources) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read included file: %w", err)
+	}
+
+	expanded, err := expandWithEnvAndDefault(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: error expanding environment variables: %v", name, err)
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(expanded), &document); err != nil {
+		return fmt.Errorf("%s: error parsing YAML: %v", name, err)
+	}
+	if len(document.Content) == 0 {
+		return nil
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: an included file must be a mapping of %s", name, strings.Join(includableKeys, ", "))
+	}
+	for i := 0; i < len(root.Content); i += 2 {
+		if key := root.Content[i]; !slices.Contains(includableKeys, key.Value) {
+			return fmt.Errorf("%s:%d: %s cannot be included, only %s", name, key.Line, key.Value, strings.Join(includableKeys, ", "))
+		}
+	}
+
+	var included includedConfig
+	if err := root.Decode(&included); err != nil {
+		return fmt.Errorf("%s: error parsing YAML: %v", name, err)
+	}
+	if strictFields {
+		if err := checkKnownFields(root, reflect.TypeOf(included), ""); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	fragment := Config{Services: included.Services, Dependencies: included.Dependencies}
+	if dir := filepath.Dir(name); dir != "." {
+		fragment.resolvePaths(dir)
+	}
+
+	for i := range fragment.Services {
+		srcs["services"] = append(srcs["services"], source{file: name, document: &document, index: i})
+	}
+	for i := range fragment.Dependencies {
+		srcs["dependencies"] = append(srcs["dependencies"], source{file: name, document: &document, index: i})
+	}
+	c.Services = append(c.Services, fragment.Services...)
+	c.Dependencies = append(c.Dependencies, fragment.Dependencies...)
+	for _, volume := range included.Volumes {
+		if !slices.Contains(c.Volumes, volume) {
+			c.Volumes = append(c.Volumes, volume)
+		}
+	}
+
+	return nil
+}
+
+// checkDuplicateNames reports the first name, from name(i) for every entry
+// i of srcs, that is defined twice, and where.
+func checkDuplicateNames(kind string, srcs []source, name func(i int) string) error {
+	seen := make(map[string]source, len(srcs))
+	for i, src := range srcs {
+		n := name(i)
+		if n == "" {
+			continue
+		}
+		if first, ok := seen[n]; ok {
+			if first.file == src.file {
+				return fmt.Errorf("%s %s is defined twice in %s", kind, n, src.describe())
+			}
+			return fmt.Errorf("%s %s is defined in both %s and %s", kind, n, first.describe(), src.describe())
+		}
+		seen[n] = src
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// InitStep prepares the data of a dependency once, when its volumes are new,
+// to create extensions or roles or load seed data:
+//
+//	init:
+//	  - file: ./db/schema.sql
+//	  - command: psql -U postgres -c "CREATE EXTENSION IF NOT EXISTS pg_trgm"
+//
+// Command runs in the container with sh once the dependency is healthy. File
+// is a local file passed to Command on stdin; for postgres, mysql and
+// mariadb images, Command defaults to their client connected as the user the
+// image was initialised with. Each step runs once for the data of the
+// dependency: steps added later run on the next deploy, and all steps run
+// again after its volumes are recreated.
+type InitStep struct {
+	File    string `yaml:"file" validate:"required_without=Command"`
+	Command string `yaml:"command" validate:"required_without=File"`
+}
+
+// InitCommand returns the command step runs in the container of the
+// dependency, or an empty string when it has none.
+func (d *Dependency) InitCommand(step *InitStep) string {
+	if step.Command != "" || step.File == "" {
+		return step.Command
+	}
+
+	name := d.Image
+	if i := strings.LastIndex(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	switch base := path.Base(name); {
+	case strings.Contains(base, "postgres"), base == "postgis", base == "timescaledb":
+		return `psql -v ON_ERROR_STOP=1 -U "${POSTGRES_USER:-postgres}" -d "${POSTGRES_DB:-${POSTGRES_USER:-postgres}}"`
+	case base == "mariadb":
+		return `mariadb -uroot -p"${MARIADB_ROOT_PASSWORD:-$MYSQL_ROOT_PASSWORD}" ${MARIADB_DATABASE:-$MYSQL_DATABASE}`
+	case base == "mysql":
+		return `mysql -uroot -p"$MYSQL_ROOT_PASSWORD" $MYSQL_DATABASE`
+	}
+	return ""
+}
+
+// validateInit checks that every init step has a command to run.
+func (c *Config) validateInit() error {
+	for i := range c.Dependencies {
+		dependency := &c.Dependencies[i]
+		for j := range dependency.Init {
+			if dependency.InitCommand(&dependency.Init[j]) == "" {
+				return fmt.Errorf("dependency %s: init step %d needs a command to run %s with", dependency.Name, j+1, dependency.Init[j].File)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Marshal renders cfg as ftl.yaml. Empty values are left out so the result
+// reads like a hand-written file, and durations are written as "10s".
+func Marshal(cfg *Config) ([]byte, error) {
+	node := encodeNode(reflect.ValueOf(cfg))
+	if node == nil {
+		return nil, fmt.Errorf("empty configuration")
+	}
+
+	var b strings.Builder
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return []byte(b.String()), nil
+}
+
+// encodeNode converts v into a YAML node, returning nil for empty values.
+func encodeNode(v reflect.Value) *yaml.Node {
+	if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if d, ok := v.Interface().(Duration); ok {
+		if d == 0 {
+			return nil
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: d.String()}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+			if name == "" || name == "-" || name == "_" {
+				continue
+			}
+			value := encodeNode(v.Field(i))
+			if value == nil {
+				continue
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, value)
+		}
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return node
+
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return nil
+		}
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for i := 0; i < v.Len(); i++ {
+			if item := encodeNode(v.Index(i)); item != nil {
+				node.Content = append(node.Content, item)
+			}
+		}
+		return node
+
+	case reflect.Map:
+		if v.Len() == 0 {
+			return nil
+		}
+		keys := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, key := range keys {
+			if value := encodeNode(v.MapIndex(reflect.ValueOf(key))); value != nil {
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+			}
+		}
+		return node
+
+	case reflect.String:
+		if v.String() == "" {
+			return nil
+		}
+		node := &yaml.Node{}
+		node.SetString(v.String())
+		return node
+
+	case reflect.Bool:
+		if !v.Bool() {
+			return nil
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "true"}
+
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		if v.Int() == 0 {
+			return nil
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(v.Int(), 10)}
+
+	case reflect.Float64, reflect.Float32:
+		if v.Float() == 0 {
+			return nil
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: strconv.FormatFloat(v.Float(), 'f', -1, 64)}
+	}
+
+	return nil
+}
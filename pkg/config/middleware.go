@@ -0,0 +1,142 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MiddlewareKinds lists the supported route middleware in documentation order.
+// Each entry of a route's middleware list sets exactly one of them:
+//
+//	routes:
+//	  - path: /
+//	    middleware:
+//	      - headers:
+//	          set:
+//	            X-Frame-Options: DENY
+//	      - cache:
+//	          ttl: 10m
+//	      - allow_ips: [10.0.0.0/8, 203.0.113.7]
+//	      - auth:
+//	          users:
+//	            - ${ADMIN_HTPASSWD}
+//	      - cors:
+//	          allow_origins: [https://app.example.com]
+//	      - rate_limit:
+//	          rate: 10r/s
+var MiddlewareKinds = []string{"headers", "cache", "allow_ips", "auth", "cors", "rate_limit"}
+
+// Middleware is one step of a route's middleware chain. The proxy applies the
+// chain in the order it is declared.
+type Middleware struct {
+	Headers *HeadersMiddleware `yaml:"headers"`
+	Cache   *CacheMiddleware   `yaml:"cache"`
+	// AllowIPs admits only clients from these addresses and CIDR ranges.
+	AllowIPs  []string             `yaml:"allow_ips" validate:"omitempty,min=1,dive,cidr|ip"`
+	Auth      *AuthMiddleware      `yaml:"auth"`
+	CORS      *CORSMiddleware      `yaml:"cors"`
+	RateLimit *RateLimitMiddleware `yaml:"rate_limit"`
+}
+
+// HeadersMiddleware sets response headers on every response, including
+// errors, and removes headers sent by the upstream.
+type HeadersMiddleware struct {
+	Set    map[string]string `yaml:"set" validate:"dive,keys,header_name,endkeys"`
+	Remove []string          `yaml:"remove" validate:"dive,header_name"`
+}
+
+// CacheMiddleware caches successful upstream responses in the proxy for TTL.
+type CacheMiddleware struct {
+	TTL Duration `yaml:"ttl" validate:"required"`
+}
+
+// AuthMiddleware requires HTTP basic authentication. Users are htpasswd
+// lines ("name:hash", e.g. from `htpasswd -nB name`). Hashes contain "$", so
+// pass them through an environment variable rather than inline.
+type AuthMiddleware struct {
+	Type  string   `yaml:"type" validate:"omitempty,oneof=basic"`
+	Realm string   `yaml:"realm"`
+	Users []string `yaml:"users" validate:"required,min=1,dive,htpasswd_user"`
+}
+
+// CORSMiddleware answers cross-origin requests from AllowOrigins, which are
+// "scheme://host[:port]" origins or "*" for any origin, and responds to their
+// preflight requests in the proxy. Methods default to the common REST verbs
+// and headers to those the preflight request asks for.
+type CORSMiddleware struct {
+	AllowOrigins     []string `yaml:"allow_origins" validate:"required,min=1,dive,cors_origin"`
+	AllowMethods     []string `yaml:"allow_methods" validate:"dive,oneof=GET HEAD POST PUT PATCH DELETE OPTIONS"`
+	AllowHeaders     []string `yaml:"allow_headers" validate:"dive,header_name"`
+	ExposeHeaders    []string `yaml:"expose_headers" validate:"dive,header_name"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	MaxAge           Duration `yaml:"max_age"`
+}
+
+// RateLimitMiddleware limits each client to Rate requests, in nginx notation
+// such as "10r/s" or "30r/m", and rejects requests over the limit with 429.
+// Burst requests above the rate are let through before limiting starts.
+// Clients are told apart by IP address, or by the value of Header, e.g. an API
+// key; requests without the header are not limited.
+type RateLimitMiddleware struct {
+	Rate   string `yaml:"rate" validate:"required,rate"`
+	Burst  int    `yaml:"burst" validate:"min=0"`
+	Header string `yaml:"header" validate:"omitempty,header_name"`
+}
+
+var (
+	rateRegex         = regexp.MustCompile(`^[1-9][0-9]*r/[sm]$`)
+	headerNameRegex   = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+	htpasswdUserRegex = regexp.MustCompile(`^[^:\s]+:\S+$`)
+	corsOriginRegex   = regexp.MustCompile(`^(\*|https?://[A-Za-z0-9.-]+(:[0-9]+)?)$`)
+)
+
+// AllowsAnyOrigin reports whether the "*" origin is allowed.
+func (c *CORSMiddleware) AllowsAnyOrigin() bool {
+	return slices.Contains(c.AllowOrigins, "*")
+}
+
+// Kind returns the name of the middleware that is set.
+func (m Middleware) Kind() string {
+	switch {
+	case m.Headers != nil:
+		return "headers"
+	case m.Cache != nil:
+		return "cache"
+	case m.AllowIPs != nil:
+		return "allow_ips"
+	case m.Auth != nil:
+		return "auth"
+	case m.CORS != nil:
+		return "cors"
+	case m.RateLimit != nil:
+		return "rate_limit"
+	}
+	return ""
+}
+
+// String renders the middleware deterministically so service hashes do not
+// depend on pointer addresses.
+func (m Middleware) String() string {
+	settings, _ := json.Marshal(m)
+	return string(settings)
+}
+
+// UnmarshalYAML requires each list entry to name exactly one known middleware.
+func (m *Middleware) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode || len(node.Content) != 2 {
+		return fmt.Errorf("line %d: each middleware entry must set exactly one of %s", node.Line, strings.Join(MiddlewareKinds, ", "))
+	}
+
+	kind := node.Content[0].Value
+	if !slices.Contains(MiddlewareKinds, kind) {
+		return fmt.Errorf("line %d: unknown middleware %q, supported middleware: %s", node.Line, kind, strings.Join(MiddlewareKinds, ", "))
+	}
+
+	type plain Middleware
+	return node.Decode((*plain)(m))
+}
@@ -0,0 +1,51 @@
+package config
+
+import "slices"
+
+// Deploy lifecycle events a notification can subscribe to.
+const (
+	EventDeployStarted    = "started"
+	EventDeploySucceeded  = "succeeded"
+	EventDeployFailed     = "failed"
+	EventDeployRolledBack = "rolled_back"
+)
+
+// Notification channels.
+const (
+	NotificationSlack   = "slack"
+	NotificationDiscord = "discord"
+	NotificationWebhook = "webhook"
+	NotificationEmail   = "email"
+)
+
+// Notification sends deploy lifecycle events to a chat channel, a generic
+// webhook or by email. Slack, Discord and webhook channels post to URL, email
+// channels send through the SMTP server in Email. Events defaults to all events.
+//
+//	notifications:
+//	  - type: slack
+//	    url: ${SLACK_WEBHOOK_URL}
+//	    events: [failed, rolled_back]
+type Notification struct {
+	Type   string             `yaml:"type" validate:"required,oneof=slack discord webhook email"`
+	URL    string             `yaml:"url" validate:"required_unless=Type email,omitempty,url"`
+	Email  *EmailNotification `yaml:"email" validate:"required_if=Type email,omitempty"`
+	Events []string           `yaml:"events" validate:"dive,oneof=started succeeded failed rolled_back"`
+}
+
+// EmailNotification is the SMTP server and envelope for email notifications.
+// Port defaults to 587; the connection is upgraded with STARTTLS when the
+// server supports it.
+type EmailNotification struct {
+	Host     string   `yaml:"host" validate:"required"`
+	Port     int      `yaml:"port" validate:"omitempty,min=1,max=65535"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from" validate:"required,email"`
+	To       []string `yaml:"to" validate:"required,min=1,dive,email"`
+}
+
+// Wants reports whether the notification subscribes to event.
+func (n Notification) Wants(event string) bool {
+	return len(n.Events) == 0 || slices.Contains(n.Events, event)
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// validatePreBuild checks that pre-build commands run in a service path and
+// that their output and inputs stay inside it.
+func (c *Config) validatePreBuild() error {
+	for _, service := range c.Services {
+		build := service.Build
+		if build == nil || (len(build.Pre) == 0 && build.Output == "" && len(build.Inputs) == 0) {
+			continue
+		}
+		if len(build.Pre) == 0 {
+			return fmt.Errorf("service %s: build.output and build.inputs need build.pre commands", service.Name)
+		}
+		if service.Path == "" {
+			return fmt.Errorf("service %s: build.pre needs a path to run in", service.Name)
+		}
+		if build.Output != "" && (!filepath.IsLocal(build.Output) || filepath.Clean(build.Output) == ".") {
+			return fmt.Errorf("service %s: build.output %q must be a directory inside the service path", service.Name, build.Output)
+		}
+		if build.Output == "" && len(build.Inputs) > 0 {
+			return fmt.Errorf("service %s: build.inputs need a build.output to cache", service.Name)
+		}
+		for _, input := range build.Inputs {
+			if !filepath.IsLocal(input) {
+				return fmt.Errorf("service %s: build input %q must be inside the service path", service.Name, input)
+			}
+		}
+	}
+	return nil
+}
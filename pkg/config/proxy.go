@@ -0,0 +1,15 @@
+package config
+
+// Proxy configures the nginx proxy of the project. HealthCheck replaces the
+// health check of its container, which requests https://localhost/ every 10
+// seconds, for a proxy whose default server does not answer on /:
+//
+//	proxy:
+//	  health_check:
+//	    cmd: curl -kf https://localhost/healthz
+//	    interval: 30s
+//
+// Settings left out keep their default.
+type Proxy struct {
+	HealthCheck *ContainerHealthCheck `yaml:"health_check"`
+}
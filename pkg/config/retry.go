@@ -0,0 +1,71 @@
+package config
+
+import (
+	"time"
+
+	"github.com/yarlson/ftl/pkg/retry"
+)
+
+// defaultConnectTimeout bounds establishing an SSH connection unless
+// server.timeouts sets another.
+const defaultConnectTimeout = 10 * time.Second
+
+// Timeouts bounds remote operations, so one that hangs fails, and is retried,
+// instead of stalling the deploy.
+//
+//	server:
+//	  timeouts:
+//	    connect: 30s
+//	    pull: 10m
+//	    health_check: 5m
+type Timeouts struct {
+	// Connect bounds establishing an SSH connection, 10s by default.
+	Connect Duration `yaml:"connect"`
+	// Pull bounds each attempt to pull an image. Pulls are not bounded by
+	// default.
+	Pull Duration `yaml:"pull"`
+	// HealthCheck bounds how long a container may take to become healthy,
+	// replacing the time its health check settings allow.
+	HealthCheck Duration `yaml:"health_check"`
+}
+
+// Deploy configures how deploys handle remote operations that fail.
+// Retries is how many times a failed SSH connect, image pull or health check
+// command is retried, 2 by default and 0 to fail at once. Backoff is the wait
+// before the first retry, 1s by default, doubled before each next one.
+//
+//	deploy:
+//	  retries: 5
+//	  backoff: 2s
+type Deploy struct {
+	Retries *int     `yaml:"retries" validate:"omitempty,min=0"`
+	Backoff Duration `yaml:"backoff"`
+}
+
+// RetryPolicy returns the policy failed remote operations are retried with.
+func (c *Config) RetryPolicy() retry.Policy {
+	policy := retry.Default
+	if c.Deploy == nil {
+		return policy
+	}
+	if c.Deploy.Retries != nil {
+		policy.Retries = *c.Deploy.Retries
+	}
+	if c.Deploy.Backoff > 0 {
+		policy.Backoff = c.Deploy.Backoff.Duration()
+	}
+	return policy
+}
+
+// ServerTimeouts returns the timeouts of remote operations, with the connect
+// timeout defaulted.
+func (c *Config) ServerTimeouts() Timeouts {
+	var timeouts Timeouts
+	if c.Server != nil && c.Server.Timeouts != nil {
+		timeouts = *c.Server.Timeouts
+	}
+	if timeouts.Connect <= 0 {
+		timeouts.Connect = Duration(defaultConnectTimeout)
+	}
+	return timeouts
+}
@@ -0,0 +1,27 @@
+package config
+
+// Revision is the git commit a deployment is made from.
+type Revision struct {
+	// Commit is the short SHA of the commit, empty outside a git repository.
+	Commit string
+	// Dirty is set when the working tree had uncommitted changes.
+	Dirty bool
+}
+
+// String returns the commit, as "1a2b3c4", with a "-dirty" suffix when the
+// working tree had uncommitted changes.
+func (r Revision) String() string {
+	if r.Dirty && r.Commit != "" {
+		return r.Commit + "-dirty"
+	}
+	return r.Commit
+}
+
+// SetRevision records the commit the configuration is deployed from on the
+// configuration and every service.
+func (c *Config) SetRevision(revision Revision) {
+	c.Revision = revision
+	for i := range c.Services {
+		c.Services[i].Revision = revision
+	}
+}
@@ -0,0 +1,8 @@
+package config
+
+import _ "embed"
+
+// Sample is the annotated starter configuration written by `ftl init`.
+//
+//go:embed sample/ftl.yaml
+var Sample []byte
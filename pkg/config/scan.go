@@ -0,0 +1,60 @@
+package config
+
+import "slices"
+
+// Severities are the severities of vulnerabilities Trivy reports, from the
+// lowest.
+var Severities = []string{"UNKNOWN", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+// Scan checks the images ftl builds for known vulnerabilities with Trivy
+// before they are pushed:
+//
+//	scan:
+//	  severity: HIGH
+//	  action: fail
+//	  ignore_unfixed: true
+//
+// Vulnerabilities of Severity or above fail the build, or are only reported
+// when Action is "warn". The JSON report of every image is written to
+// Reports, .ftl/scan next to ftl.yaml unless set.
+type Scan struct {
+	Severity      string `yaml:"severity" validate:"omitempty,oneof=UNKNOWN LOW MEDIUM HIGH CRITICAL"`
+	Action        string `yaml:"action" validate:"omitempty,oneof=fail warn"`
+	IgnoreUnfixed bool   `yaml:"ignore_unfixed"`
+	Reports       string `yaml:"reports"`
+}
+
+const (
+	ScanFail = "fail"
+	ScanWarn = "warn"
+)
+
+// DefaultScanReports is the directory scan reports are written to, relative
+// to the configuration file.
+const DefaultScanReports = ".ftl/scan"
+
+// Threshold returns the lowest severity that counts, HIGH unless set.
+func (s *Scan) Threshold() string {
+	if s.Severity == "" {
+		return "HIGH"
+	}
+	return s.Severity
+}
+
+// CountedSeverities returns the threshold and every severity above it.
+func (s *Scan) CountedSeverities() []string {
+	return Severities[slices.Index(Severities, s.Threshold()):]
+}
+
+// Fails reports whether vulnerabilities fail the build.
+func (s *Scan) Fails() bool {
+	return s.Action != ScanWarn
+}
+
+// ReportDir returns the directory scan reports are written to.
+func (s *Scan) ReportDir() string {
+	if s.Reports == "" {
+		return DefaultScanReports
+	}
+	return s.Reports
+}
@@ -0,0 +1,42 @@
+package config
+
+import "fmt"
+
+// Deployment strategies of a service, set with strategy.
+const (
+	// StrategyBlueGreen starts each new container next to the old one and
+	// switches traffic once it is healthy. It is the default.
+	StrategyBlueGreen = "blue-green"
+	// StrategyReplace stops the old containers before the new ones start,
+	// for workloads of which two instances must never run at once, such as
+	// workers holding a leader lock. The service is down in between.
+	StrategyReplace = "replace"
+	// StrategyRolling replaces the replicas one at a time without starting
+	// extra containers, so the others keep serving and the service never
+	// runs more than its replica count.
+	StrategyRolling = "rolling"
+)
+
+// DeployStrategy returns how the containers of the service are replaced on
+// deploy. Recreate services are replaced.
+func (s *Service) DeployStrategy() string {
+	switch {
+	case s.Strategy != "":
+		return s.Strategy
+	case s.Recreate:
+		return StrategyReplace
+	default:
+		return StrategyBlueGreen
+	}
+}
+
+// validateStrategies checks that services rolled out one replica at a time
+// have replicas to keep serving meanwhile.
+func (c *Config) validateStrategies() error {
+	for _, service := range c.Services {
+		if service.Strategy == StrategyRolling && service.ReplicaCount() < 2 {
+			return fmt.Errorf("service %s: the rolling strategy needs at least 2 replicas", service.Name)
+		}
+	}
+	return nil
+}
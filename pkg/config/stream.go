@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Stream exposes a non-HTTP port of a service, such as MQTT, SMTP or a game
+// server, on a port of the proxy instead of publishing a host port on the
+// service container:
+//
+//	streams:
+//	  - port: 8883
+//	    target: 1883
+//	    tls: terminate
+//	  - port: 27015
+//	    protocol: udp
+//
+// With tls "terminate" the proxy decrypts TCP connections with the
+// certificate of the service's domain and forwards plain TCP. With
+// "passthrough" it forwards the encrypted connection untouched; several
+// services can then share a port, routed by the server name of the TLS
+// handshake.
+type Stream struct {
+	// Port is published on the server.
+	Port int `yaml:"port" validate:"required,min=1,max=65535"`
+	// Target is the port of the service container, Port by default.
+	Target   int    `yaml:"target" validate:"omitempty,min=1,max=65535"`
+	Protocol string `yaml:"protocol" validate:"omitempty,oneof=tcp udp"`
+	TLS      string `yaml:"tls" validate:"omitempty,oneof=terminate passthrough"`
+}
+
+// Network returns the transport protocol of the stream, tcp by default.
+func (s Stream) Network() string {
+	if s.Protocol == "" {
+		return "tcp"
+	}
+	return s.Protocol
+}
+
+// TargetPort returns the container port the stream forwards to.
+func (s Stream) TargetPort() int {
+	if s.Target == 0 {
+		return s.Port
+	}
+	return s.Target
+}
+
+// Listener identifies the proxy port of the stream, e.g. "8883/tcp".
+func (s Stream) Listener() string {
+	return strconv.Itoa(s.Port) + "/" + s.Network()
+}
+
+// validateStreams checks that stream ports do not collide with the HTTP
+// proxy or with each other. Only passthrough streams of services on distinct
+// domains can share a port.
+func (c *Config) validateStreams() error {
+	type owner struct {
+		service string
+		domain  string
+		stream  Stream
+	}
+	listeners := make(map[string][]owner)
+	for i := range c.Services {
+		service := &c.Services[i]
+		for _, stream := range service.Streams {
+			if stream.Network() == "udp" && stream.TLS != "" {
+				return fmt.Errorf("service %s: stream %s cannot use tls over udp", service.Name, stream.Listener())
+			}
+			if stream.Network() == "tcp" && (stream.Port == 80 || stream.Port == 443) {
+				return fmt.Errorf("service %s: stream port %d is used by the HTTP proxy", service.Name, stream.Port)
+			}
+			domain := c.ServiceDomain(service)
+			for _, other := range listeners[stream.Listener()] {
+				if stream.TLS != "passthrough" || other.stream.TLS != "passthrough" || other.domain == domain {
+					return fmt.Errorf("service %s: stream %s is already used by service %s, only passthrough streams on different domains can share a port", service.Name, stream.Listener(), other.service)
+				}
+			}
+			listeners[stream.Listener()] = append(listeners[stream.Listener()], owner{service.Name, domain, stream})
+		}
+	}
+	return nil
+}
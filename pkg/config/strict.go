@@ -0,0 +1,211 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// strictFields makes keys the configuration does not define, such as a
+// misspelled "enviroment", an error instead of being ignored.
+var strictFields = true
+
+// SetStrictFields sets whether parsing fails on keys the configuration does
+// not define.
+func SetStrictFields(strict bool) {
+	strictFields = strict
+}
+
+// unknownFieldError reports a key that no field of the configuration is
+// decoded from.
+type unknownFieldError struct {
+	line       int
+	field      string
+	path       string
+	suggestion string
+}
+
+func (e *unknownFieldError) Error() string {
+	message := fmt.Sprintf("line %d: unknown field %q", e.line, e.field)
+	if e.path != "" {
+		message += " in " + e.path
+	}
+	if e.suggestion != "" {
+		message += fmt.Sprintf(", did you mean %q?", e.suggestion)
+	}
+	return message
+}
+
+// yamlNodeType is skipped by checkKnownFields, since a yaml.Node holds any
+// YAML.
+var yamlNodeType = reflect.TypeOf(yaml.Node{})
+
+// checkKnownFields checks that every key of the mappings in node, decoded
+// into a value of type t, names a field of t. Aliases and merge keys are
+// followed, so anchors are checked where they are used, and keys starting
+// with "x-" are allowed anywhere to hold anchors. Values given in another
+// form than the type, such as dependencies written as "postgres:16", are
+// left to the decoder. path locates node in errors, such as
+// "services[0].container".
+func checkKnownFields(node *yaml.Node, t reflect.Type, path string) error {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return checkKnownFields(node.Content[0], t, path)
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i, item := range node.Content {
+			if err := checkKnownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := checkKnownFields(node.Content[i+1], t.Elem(), fieldPath(path, node.Content[i].Value)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode || t == yamlNodeType {
+			return nil
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Tag == "!!merge" {
+				if err := checkMerge(value, t, path); err != nil {
+					return err
+				}
+				continue
+			}
+			if strings.HasPrefix(key.Value, "x-") {
+				continue
+			}
+
+			field, ok := fields[key.Value]
+			if !ok {
+				return &unknownFieldError{line: key.Line, field: key.Value, path: path, suggestion: suggestField(key.Value, fields)}
+			}
+			if err := checkKnownFields(value, field, fieldPath(path, key.Value)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkMerge checks the mappings merged with "<<", one or a list of them.
+func checkMerge(node *yaml.Node, t reflect.Type, path string) error {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind != yaml.SequenceNode {
+		return checkKnownFields(node, t, path)
+	}
+	for _, item := range node.Content {
+		if err := checkKnownFields(item, t, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// yamlFields returns the type of every field of the struct type t by the key
+// it is decoded from, including the fields of inlined structs.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(options, "inline") {
+			inlined := field.Type
+			if inlined.Kind() == reflect.Pointer {
+				inlined = inlined.Elem()
+			}
+			if inlined.Kind() == reflect.Struct {
+				for key, fieldType := range yamlFields(inlined) {
+					fields[key] = fieldType
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// suggestField returns the known key closest to the misspelled key, when
+// it is at most two edits away.
+func suggestField(key string, fields map[string]reflect.Type) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	suggestion, best := "", 3
+	for _, name := range names {
+		if distance := editDistance(key, name); distance < best {
+			suggestion, best = name, distance
+		}
+	}
+	return suggestion
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func fieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// TLS is the TLS policy of the proxy. HTTP requests are redirected to HTTPS
+// unless Redirect is false, in which case routes are served over plain HTTP as
+// well. MinVersion is "1.2" (the default) or "1.3", and Ciphers replaces the
+// OpenSSL cipher list offered for TLS 1.2; TLS 1.3 suites are not
+// configurable.
+//
+// Mode sets where certificates come from: "letsencrypt" (the default) issues
+// them with Let's Encrypt, "letsencrypt-staging" with its staging
+// environment, whose untrusted certificates do not count against the rate
+// limits of test deployments, "selfsigned" generates them on deploy for
+// servers Let's Encrypt cannot reach, and "custom" serves Certificate and
+// Key, local PEM files whose certificate must cover every domain.
+//
+//	tls:
+//	  mode: letsencrypt
+//	  redirect: true
+//	  min_version: "1.2"
+//	  ciphers: [ECDHE-ECDSA-AES128-GCM-SHA256, ECDHE-RSA-AES128-GCM-SHA256]
+//	  hsts:
+//	    max_age: 8760h
+//	    include_subdomains: true
+//	  expiry_alert:
+//	    webhook: ${SLACK_WEBHOOK_URL}
+//	    days: 14
+type TLS struct {
+	Mode        string            `yaml:"mode" validate:"omitempty,oneof=letsencrypt letsencrypt-staging selfsigned custom"`
+	Certificate string            `yaml:"certificate" validate:"required_if=Mode custom,excluded_unless=Mode custom"`
+	Key         string            `yaml:"key" validate:"required_if=Mode custom,excluded_unless=Mode custom"`
+	Redirect    *bool             `yaml:"redirect"`
+	MinVersion  string            `yaml:"min_version" validate:"omitempty,oneof=1.2 1.3"`
+	Ciphers     []string          `yaml:"ciphers" validate:"dive,cipher_suite"`
+	HSTS        *HSTS             `yaml:"hsts"`
+	ExpiryAlert *CertificateAlert `yaml:"expiry_alert"`
+}
+
+// HSTS adds a Strict-Transport-Security header to every HTTPS response.
+type HSTS struct {
+	MaxAge            Duration `yaml:"max_age" validate:"required"`
+	IncludeSubdomains bool     `yaml:"include_subdomains"`
+	Preload           bool     `yaml:"preload"`
+}
+
+// CertificateAlert posts to Webhook once a day while the certificate of a
+// domain is missing or expires in less than Days days (14 by default), which
+// means its renewal keeps failing. The payload carries both "text" and
+// "content" so Slack and Discord webhooks accept it as-is.
+type CertificateAlert struct {
+	Webhook string `yaml:"webhook" validate:"required,url"`
+	Days    int    `yaml:"days" validate:"omitempty,min=1"`
+}
+
+// Certificate modes of the proxy.
+const (
+	TLSLetsEncrypt        = "letsencrypt"
+	TLSLetsEncryptStaging = "letsencrypt-staging"
+	TLSSelfSigned         = "selfsigned"
+	TLSCustom             = "custom"
+)
+
+// CertificateMode returns where certificates come from, Let's Encrypt unless
+// configured.
+func (t *TLS) CertificateMode() string {
+	if t == nil || t.Mode == "" {
+		return TLSLetsEncrypt
+	}
+	return t.Mode
+}
+
+// UsesACME reports whether certificates are issued with ACME, by the
+// certificate manager answering HTTP-01 challenges.
+func (t *TLS) UsesACME() bool {
+	mode := t.CertificateMode()
+	return mode == TLSLetsEncrypt || mode == TLSLetsEncryptStaging
+}
+
+// DefaultCertificateAlertDays is the expiry threshold unless configured.
+const DefaultCertificateAlertDays = 14
+
+// AlertDays returns the number of days before expiry under which a
+// certificate is reported, whether or not an alert is configured.
+func (t *TLS) AlertDays() int {
+	if t != nil && t.ExpiryAlert != nil && t.ExpiryAlert.Days > 0 {
+		return t.ExpiryAlert.Days
+	}
+	return DefaultCertificateAlertDays
+}
+
+// hstsPreloadMinAge is the shortest max-age accepted by the HSTS preload list.
+const hstsPreloadMinAge = 365 * 24 * time.Hour
+
+var cipherSuiteRegex = regexp.MustCompile(`^[A-Za-z0-9_+-]+$`)
+
+// RedirectsHTTP reports whether HTTP requests are redirected to HTTPS.
+func (t *TLS) RedirectsHTTP() bool {
+	return t == nil || t.Redirect == nil || *t.Redirect
+}
+
+// Protocols returns the TLS versions the proxy accepts, in nginx notation.
+func (t *TLS) Protocols() string {
+	if t != nil && t.MinVersion == "1.3" {
+		return "TLSv1.3"
+	}
+	return "TLSv1.2 TLSv1.3"
+}
+
+// Header returns the value of the Strict-Transport-Security header.
+func (h *HSTS) Header() string {
+	value := fmt.Sprintf("max-age=%d", int64(h.MaxAge.Duration().Seconds()))
+	if h.IncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	if h.Preload {
+		value += "; preload"
+	}
+	return value
+}
+
+func (h *HSTS) validate() error {
+	if h.Preload && (!h.IncludeSubdomains || h.MaxAge.Duration() < hstsPreloadMinAge) {
+		return fmt.Errorf("tls.hsts: preload requires include_subdomains and a max_age of at least one year")
+	}
+	return nil
+}
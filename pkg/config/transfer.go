@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Transfer limits what deploys send to the server, so pushing large images
+// does not saturate a slow uplink. Parallel is how many image layers are
+// uploaded at once, 1 by default. Bandwidth caps the uploads together, in
+// bytes per second with an optional k, m or g unit, or in bits per second
+// with kbit, mbit or gbit:
+//
+//	server:
+//	  transfer:
+//	    parallel: 2
+//	    bandwidth: 20mbit
+type Transfer struct {
+	Parallel  int    `yaml:"parallel" validate:"omitempty,min=1,max=32"`
+	Bandwidth string `yaml:"bandwidth" validate:"omitempty,bandwidth"`
+}
+
+var bandwidthRegex = regexp.MustCompile(`^(?i)([0-9]+(?:\.[0-9]+)?)\s*(b|k|kb|m|mb|g|gb|kbit|mbit|gbit)?(?:/s)?$`)
+
+// BandwidthBytes converts a bandwidth such as "2m", "500kb/s" or "20mbit"
+// into bytes per second. Byte units are binary, like memory sizes, and bit
+// units decimal, like link speeds.
+func BandwidthBytes(limit string) (int64, error) {
+	match := bandwidthRegex.FindStringSubmatch(strings.TrimSpace(limit))
+	if match == nil {
+		return 0, fmt.Errorf("invalid bandwidth %q", limit)
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bandwidth %q: %w", limit, err)
+	}
+
+	multiplier := 1.0
+	switch strings.ToLower(match[2]) {
+	case "k", "kb":
+		multiplier = 1 << 10
+	case "m", "mb":
+		multiplier = 1 << 20
+	case "g", "gb":
+		multiplier = 1 << 30
+	case "kbit":
+		multiplier = 1e3 / 8
+	case "mbit":
+		multiplier = 1e6 / 8
+	case "gbit":
+		multiplier = 1e9 / 8
+	}
+
+	bytes := int64(value * multiplier)
+	if bytes <= 0 {
+		return 0, fmt.Errorf("bandwidth %q must be positive", limit)
+	}
+	return bytes, nil
+}
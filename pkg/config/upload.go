@@ -0,0 +1,37 @@
+package config
+
+import "regexp"
+
+// Upload is a local file or directory copied to the server before the
+// container of a service starts, for configuration files, GeoIP databases or
+// licenses that should not be baked into the image:
+//
+//	uploads:
+//	  - local: ./config/app.toml
+//	    remote: config/app.toml
+//	    mode: "0640"
+//	    owner: "1000:1000"
+//	  - local: ./geoip
+//	    remote: /srv/geoip
+//
+// Remote is absolute, or relative to the project folder on the server
+// (~/projects/<project>). A directory replaces the remote one as a whole, so
+// files removed locally disappear from the server too. Mode sets the
+// permissions of the uploaded files and Owner, a user or user:group name or
+// ID, their owner. Changed content replaces the container, so it never runs
+// with half of an upload.
+type Upload struct {
+	Local  string `yaml:"local" validate:"required"`
+	Remote string `yaml:"remote" validate:"required"`
+	Mode   string `yaml:"mode" validate:"omitempty,file_mode"`
+	Owner  string `yaml:"owner" validate:"omitempty,owner"`
+	// Digest identifies the local content and settings, set when the service
+	// is deployed. It is part of the hash of the service, so a new upload
+	// replaces the container.
+	Digest string `yaml:"-"`
+}
+
+var (
+	fileModeRegex = regexp.MustCompile(`^0?[0-7]{3}$`)
+	ownerRegex    = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]*(:[a-zA-Z0-9_][a-zA-Z0-9_.-]*)?$`)
+)
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// VolumeMount is a volume reference of a service or dependency. A source
+// starting with "/" binds that path of the server, one starting with "." a
+// path relative to the home directory of the deploy user, and anything else
+// names a volume of the project:
+//
+//	volumes:
+//	  - data:/var/lib/app
+//	  - /srv/geoip:/usr/share/GeoIP:ro
+//
+// Options after a second colon are passed to Docker, e.g. "ro" or "ro,z".
+type VolumeMount struct {
+	Source  string
+	Target  string
+	Options []string
+}
+
+var volumeNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// volumeOptions are the mount options Docker accepts on -v.
+var volumeOptions = []string{"ro", "rw", "z", "Z", "nocopy", "shared", "slave", "private", "rshared", "rslave", "rprivate"}
+
+// ParseVolume parses a volume reference.
+func ParseVolume(ref string) (VolumeMount, error) {
+	parts := strings.Split(ref, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return VolumeMount{}, fmt.Errorf("volume %q is not source:target or source:target:options", ref)
+	}
+
+	mount := VolumeMount{Source: parts[0], Target: parts[1]}
+	switch {
+	case mount.Bind():
+		if path.Clean(mount.Source) == "/" || slices.Contains(strings.Split(mount.Source, "/"), "..") {
+			return VolumeMount{}, fmt.Errorf("volume %q: bind mount source must not be / or leave its directory with ..", ref)
+		}
+	case !volumeNameRegex.MatchString(mount.Source):
+		return VolumeMount{}, fmt.Errorf("volume %q: source must be a volume name or a path on the server", ref)
+	}
+	if !strings.HasPrefix(mount.Target, "/") {
+		return VolumeMount{}, fmt.Errorf("volume %q: target must be an absolute path", ref)
+	}
+
+	if len(parts) == 3 {
+		for _, option := range strings.Split(parts[2], ",") {
+			if !slices.Contains(volumeOptions, option) {
+				return VolumeMount{}, fmt.Errorf("volume %q: unknown option %q", ref, option)
+			}
+			mount.Options = append(mount.Options, option)
+		}
+	}
+	return mount, nil
+}
+
+// Bind reports whether the mount binds a path of the server rather than a
+// named volume.
+func (m VolumeMount) Bind() bool {
+	return strings.HasPrefix(m.Source, "/") || strings.HasPrefix(m.Source, ".")
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Service types. Web services, the default, serve HTTP through the proxy.
+// Workers, such as queue consumers and schedulers, have no HTTP surface:
+//
+//	services:
+//	  - name: jobs
+//	    type: worker
+//	    path: ./jobs
+//	    container:
+//	      health_check:
+//	        cmd: ./jobs healthcheck
+//
+// They need no port or routes and are not registered with the proxy. A new
+// container of a worker replaces the old one once its container health check
+// passes, so jobs keep being processed during a deploy.
+const (
+	ServiceWeb    = "web"
+	ServiceWorker = "worker"
+)
+
+// IsWorker reports whether the service is a worker without an HTTP surface.
+func (s *Service) IsWorker() bool {
+	return s.Type == ServiceWorker
+}
+
+// validateServiceType requires a port of web services that are not static,
+// and routes of those that expose no streams. Workers need neither.
+func validateServiceType(sl validator.StructLevel) {
+	service := sl.Current().Interface().(Service)
+	if service.IsWorker() {
+		return
+	}
+	if service.Port == 0 && service.Static == nil {
+		sl.ReportError(service.Port, "port", "Port", "required_without", "Static")
+	}
+	if len(service.Routes) == 0 && len(service.Streams) == 0 {
+		sl.ReportError(service.Routes, "routes", "Routes", "required_without", "Streams")
+	}
+}
+
+// validateWorkers checks that workers use none of the settings that only
+// apply to services the proxy sends requests to.
+func (c *Config) validateWorkers() error {
+	for _, service := range c.Services {
+		if !service.IsWorker() {
+			continue
+		}
+
+		var setting string
+		switch {
+		case len(service.Routes) > 0:
+			setting = "routes"
+		case len(service.Streams) > 0:
+			setting = "streams"
+		case service.Domain != "":
+			setting = "a domain"
+		case service.Static != nil:
+			setting = "static"
+		case service.HealthCheck != nil:
+			return fmt.Errorf("service %s: workers are not requested over HTTP or gRPC, check their health with container.health_check instead of health_check", service.Name)
+		case service.SmokeTests != nil:
+			setting = "smoke_tests"
+		case service.Warmup != nil:
+			setting = "warmup"
+		default:
+			continue
+		}
+		return fmt.Errorf("service %s: workers are not registered with the proxy and cannot have %s", service.Name, setting)
+	}
+	return nil
+}
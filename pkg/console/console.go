@@ -51,25 +51,25 @@ func init() {
 // Info prints an information message.
 func Info(a ...interface{}) {
 	message := fmt.Sprint(a...)
-	fmt.Printf("  %s\n", message)
+	printLine("  " + message)
 }
 
 // Success prints a success message.
 func Success(a ...interface{}) {
 	message := fmt.Sprint(a...)
-	fmt.Printf("%s✓%s %s\n", ColorGreen, ColorReset, message)
+	printLine(fmt.Sprintf("%s✓%s %s", ColorGreen, ColorReset, message))
 }
 
 // Warning prints a warning message.
 func Warning(a ...interface{}) {
 	message := fmt.Sprint(a...)
-	fmt.Printf("%s!%s %s\n", ColorYellow, ColorReset, message)
+	printLine(fmt.Sprintf("%s!%s %s", ColorYellow, ColorReset, message))
 }
 
 // Error prints an error message with a newline.
 func Error(a ...interface{}) {
 	message := fmt.Sprint(a...)
-	fmt.Printf("%s✘%s %s\n", ColorRed, ColorReset, message)
+	printLine(fmt.Sprintf("%s✘%s %s", ColorRed, ColorReset, message))
 }
 
 // Input prints an input prompt.
@@ -100,5 +100,5 @@ func ReadPassword() (string, error) {
 
 // Print prints a message to the console.
 func Print(a ...interface{}) {
-	fmt.Println(a...)
+	printLine(strings.TrimSuffix(fmt.Sprintln(a...), "\n"))
 }
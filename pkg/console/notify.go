@@ -0,0 +1,70 @@
+package console
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"golang.org/x/term"
+)
+
+// ProgressState is a state of the OSC 9;4 progress indicator shown by
+// terminals such as Windows Terminal, WezTerm, Ghostty and ConEmu.
+type ProgressState int
+
+// Available progress states.
+const (
+	ProgressClear ProgressState = iota
+	ProgressNormal
+	ProgressError
+	ProgressIndeterminate
+	ProgressWarning
+)
+
+var interactive = term.IsTerminal(int(os.Stdout.Fd()))
+
+// SetProgress updates the terminal progress indicator. Percent is ignored for
+// the clear and indeterminate states. It does nothing when stdout is not a terminal.
+func SetProgress(state ProgressState, percent int) {
+	if !interactive {
+		return
+	}
+	fmt.Printf("\033]9;4;%d;%d\007", state, percent)
+}
+
+// PushTitle saves the current terminal title and replaces it with title.
+// Call PopTitle to restore the saved one.
+func PushTitle(title string) {
+	if !interactive {
+		return
+	}
+	fmt.Printf("\033[22;0t\033]0;%s\007", title)
+}
+
+// PopTitle restores the terminal title saved by PushTitle.
+func PopTitle() {
+	if !interactive {
+		return
+	}
+	fmt.Print("\033[23;0t")
+}
+
+// Notify shows a desktop notification using osascript on macOS and
+// notify-send on Linux.
+func Notify(title, message string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("osascript", "-e", fmt.Sprintf("display notification %q with title %q", message, title))
+	case "linux":
+		cmd = exec.Command("notify-send", title, message)
+	default:
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to show notification: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+package console
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// spinnerFrames are drawn in turn in front of the running regions.
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// Renderer owns the terminal while concurrent tasks report progress. A single
+// goroutine does all the drawing: every running task has a live line, its
+// region, at the bottom of the terminal, and messages printed in the meantime,
+// including those of Info, Success, Warning, Error and Print, scroll above
+// the regions instead of breaking them up.
+//
+// Without a terminal nothing is redrawn: messages are printed as they come and
+// a region only prints its final message.
+type Renderer struct {
+	out   io.Writer
+	live  bool
+	width int
+
+	ops  chan func()
+	done chan struct{}
+
+	// The fields below are only used by the render goroutine.
+	regions []*Region
+	drawn   int
+	frame   int
+	stopped bool
+}
+
+// Region is the live line of one task of a Renderer.
+type Region struct {
+	renderer *Renderer
+	title    string
+	message  string
+}
+
+// active is the renderer the package-level print functions go through.
+var active atomic.Pointer[Renderer]
+
+// StartRenderer starts a renderer on stdout and routes the package-level print
+// functions through it until Stop is called.
+func StartRenderer() *Renderer {
+	width := 0
+	if interactive {
+		if w, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+			width = w
+		}
+	}
+	r := NewRenderer(os.Stdout, interactive, width)
+	active.Store(r)
+	return r
+}
+
+// NewRenderer starts a renderer writing to out. Regions are redrawn in place
+// when live is set, cut to width columns unless it is zero so they never wrap.
+func NewRenderer(out io.Writer, live bool, width int) *Renderer {
+	r := &Renderer{
+		out:   out,
+		live:  live,
+		width: width,
+		ops:   make(chan func()),
+		done:  make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Stop prints the final state of the regions still running and hands the
+// terminal back.
+func (r *Renderer) Stop() {
+	active.CompareAndSwap(r, nil)
+	r.do(func() {
+		r.clear()
+		for _, region := range r.regions {
+			r.write(region.line(" "))
+		}
+		r.regions = nil
+		r.stopped = true
+	})
+	<-r.done
+}
+
+// Log prints line above the regions.
+func (r *Renderer) Log(line string) {
+	r.do(func() {
+		r.clear()
+		r.write(line)
+		r.draw()
+	})
+}
+
+// Region adds a live line for a task, showing title and its latest message.
+func (r *Renderer) Region(title, message string) *Region {
+	region := &Region{renderer: r, title: title, message: message}
+	r.do(func() {
+		r.clear()
+		r.regions = append(r.regions, region)
+		r.draw()
+	})
+	return region
+}
+
+// Update replaces the message of the region.
+func (g *Region) Update(message string) {
+	g.renderer.do(func() {
+		g.message = message
+		g.renderer.clear()
+		g.renderer.draw()
+	})
+}
+
+// Done ends the region with a success message, which moves to the scrollback.
+func (g *Region) Done(message string) {
+	g.finish(fmt.Sprintf("%s✓%s", ColorGreen, ColorReset), message)
+}
+
+// Fail ends the region with an error message, which moves to the scrollback.
+func (g *Region) Fail(message string) {
+	g.finish(fmt.Sprintf("%s✘%s", ColorRed, ColorReset), message)
+}
+
+func (g *Region) finish(symbol, message string) {
+	r := g.renderer
+	r.do(func() {
+		r.clear()
+		for i, region := range r.regions {
+			if region == g {
+				r.regions = append(r.regions[:i], r.regions[i+1:]...)
+				break
+			}
+		}
+		g.message = message
+		r.write(g.line(symbol))
+		r.draw()
+	})
+}
+
+func (g *Region) line(symbol string) string {
+	if g.message == "" {
+		return symbol + " " + g.title
+	}
+	return fmt.Sprintf("%s %s: %s", symbol, g.title, g.message)
+}
+
+// Writer returns a writer whose lines are printed above the regions, each
+// after prefix, for the output of commands run while the renderer is active.
+// Close flushes a last line without a trailing newline.
+func (r *Renderer) Writer(prefix string) io.WriteCloser {
+	reader, writer := io.Pipe()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			r.Log(prefix + strings.TrimRight(scanner.Text(), "\r"))
+		}
+		_, _ = io.Copy(io.Discard, reader)
+	}()
+	return &lineWriter{PipeWriter: writer, wg: &wg}
+}
+
+type lineWriter struct {
+	*io.PipeWriter
+	wg *sync.WaitGroup
+}
+
+func (w *lineWriter) Close() error {
+	err := w.PipeWriter.Close()
+	w.wg.Wait()
+	return err
+}
+
+// Capture redirects the process's stdout and stderr to the scrollback of the
+// renderer, so commands that write to them directly, such as subprocesses
+// inheriting them, cannot corrupt the regions. The returned function puts them
+// back. The renderer itself keeps writing to the stdout it was created with.
+func (r *Renderer) Capture() (restore func(), err error) {
+	stdout, stderr := os.Stdout, os.Stderr
+
+	outReader, outWriter, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture stdout: %w", err)
+	}
+	errReader, errWriter, err := os.Pipe()
+	if err != nil {
+		outReader.Close()
+		outWriter.Close()
+		return nil, fmt.Errorf("failed to capture stderr: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, reader := range []*os.File{outReader, errReader} {
+		wg.Add(1)
+		go func(reader *os.File) {
+			defer wg.Done()
+			defer reader.Close()
+			writer := r.Writer("")
+			_, _ = io.Copy(writer, reader)
+			_ = writer.Close()
+		}(reader)
+	}
+
+	os.Stdout, os.Stderr = outWriter, errWriter
+	return func() {
+		os.Stdout, os.Stderr = stdout, stderr
+		outWriter.Close()
+		errWriter.Close()
+		wg.Wait()
+	}, nil
+}
+
+// do runs op on the render goroutine. Ops sent after Stop are dropped.
+func (r *Renderer) do(op func()) {
+	select {
+	case r.ops <- op:
+	case <-r.done:
+	}
+}
+
+func (r *Renderer) run() {
+	defer close(r.done)
+
+	var tick <-chan time.Time
+	if r.live {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case op := <-r.ops:
+			op()
+			if r.stopped {
+				return
+			}
+		case <-tick:
+			if len(r.regions) == 0 {
+				continue
+			}
+			r.frame = (r.frame + 1) % len(spinnerFrames)
+			r.clear()
+			r.draw()
+		}
+	}
+}
+
+// write prints a line to the scrollback.
+func (r *Renderer) write(line string) {
+	_, _ = fmt.Fprintln(r.out, line)
+}
+
+// clear erases the regions drawn on a terminal.
+func (r *Renderer) clear() {
+	if r.drawn == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(r.out, "\033[%dA\r\033[J", r.drawn)
+	r.drawn = 0
+}
+
+// draw prints the running regions on a terminal, one line each.
+func (r *Renderer) draw() {
+	if !r.live {
+		return
+	}
+	for _, region := range r.regions {
+		line := region.line(spinnerFrames[r.frame])
+		if r.width > 0 {
+			line = truncate(line, r.width-1)
+		}
+		r.write(line)
+	}
+	r.drawn = len(r.regions)
+}
+
+// truncate cuts line to width runes.
+func truncate(line string, width int) string {
+	runes := []rune(line)
+	if len(runes) <= width {
+		return line
+	}
+	return string(runes[:width])
+}
+
+// printLine prints a message line, above the regions while a renderer is
+// active.
+func printLine(line string) {
+	if r := active.Load(); r != nil {
+		r.Log(line)
+		return
+	}
+	fmt.Println(line)
+}
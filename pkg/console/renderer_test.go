@@ -0,0 +1,58 @@
+package console
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderer_NotLive(t *testing.T) {
+	var out bytes.Buffer
+	r := NewRenderer(&out, false, 0)
+
+	api := r.Region("api", "starting")
+	worker := r.Region("worker", "starting")
+	api.Update("step 1/3")
+	r.Log("pulling base image")
+	api.Done("built")
+	worker.Fail("exit code 1")
+	r.Stop()
+
+	assert.Equal(t, strings.Join([]string{
+		"pulling base image",
+		fmt.Sprintf("%s✓%s api: built", ColorGreen, ColorReset),
+		fmt.Sprintf("%s✘%s worker: exit code 1", ColorRed, ColorReset),
+	}, "\n")+"\n", out.String())
+}
+
+func TestRenderer_Live(t *testing.T) {
+	var out bytes.Buffer
+	r := NewRenderer(&out, true, 12)
+
+	r.Region("api", "a long status message")
+	r.Log("stray output")
+	r.Stop()
+
+	assert.Equal(t, strings.Join([]string{
+		"⠋ api: a lo",
+		"\033[1A\r\033[Jstray output",
+		"⠋ api: a lo",
+		"\033[1A\r\033[J  api: a long status message",
+	}, "\n")+"\n", out.String())
+}
+
+func TestRenderer_Writer(t *testing.T) {
+	var out bytes.Buffer
+	r := NewRenderer(&out, false, 0)
+
+	w := r.Writer("[api] ")
+	_, _ = w.Write([]byte("first\r\nsec"))
+	_, _ = w.Write([]byte("ond\nlast"))
+	assert.NoError(t, w.Close())
+	r.Stop()
+
+	assert.Equal(t, "[api] first\n[api] second\n[api] last\n", out.String())
+}
@@ -0,0 +1,67 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+// bindImage creates the missing directories of bind mounts, which may live
+// where the deploy user cannot write, such as /srv.
+const bindImage = "alpine:3"
+
+// numericUserRegex matches a container user given as "uid[:gid]".
+var numericUserRegex = regexp.MustCompile(`^[0-9]+(:[0-9]+)?$`)
+
+// createBindMounts creates the missing sources of the bind mounts of service
+// as directories the container can write to: owned by the container user
+// when it is given as "uid[:gid]", and by the deploy user otherwise. Paths
+// that exist are left as they are.
+func (d *Deployment) createBindMounts(ctx context.Context, service *config.Service) error {
+	var owner string
+	for _, volume := range service.Volumes {
+		mount, err := config.ParseVolume(volume)
+		if err != nil || !mount.Bind() {
+			continue
+		}
+
+		exists, err := d.runCommand(ctx, "sh", "-c", "test -e "+shell.Quote(mount.Source)+" && echo yes || true")
+		if err != nil {
+			return fmt.Errorf("failed to check bind mount %s: %w", mount.Source, err)
+		}
+		if exists == "yes" {
+			continue
+		}
+
+		if owner == "" {
+			if owner, err = d.bindOwner(ctx, service); err != nil {
+				return err
+			}
+		}
+
+		d.progress(fmt.Sprintf("Creating %s for %s...", mount.Source, service.Name))
+		// Docker creates the missing source of a bind mount itself, owned by
+		// root, so the helper only has to hand it over.
+		if output, err := d.runChecked(ctx, "docker", "run", "--rm", "-v", mount.Source+":/bind", bindImage, "chown", owner, "/bind"); err != nil {
+			return fmt.Errorf("failed to create bind mount %s: %w\n\x1b[93mOutput:\x1b[0m\n\x1b[90m%s\x1b[0m", mount.Source, err, output)
+		}
+	}
+	return nil
+}
+
+// bindOwner returns the owner of the bind mount directories created for
+// service.
+func (d *Deployment) bindOwner(ctx context.Context, service *config.Service) (string, error) {
+	if service.Container != nil && numericUserRegex.MatchString(service.Container.User) {
+		return service.Container.User, nil
+	}
+
+	ids, err := d.runCommand(ctx, "sh", "-c", `echo "$(id -u):$(id -g)"`)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the deploy user: %w", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,49 @@
+package deployment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestCreateBindMounts(t *testing.T) {
+	service := &config.Service{Name: "web", Volumes: []string{
+		"data:/var/lib/app",
+		"/srv/geoip:/usr/share/GeoIP:ro",
+		"/srv/uploads:/app/uploads",
+	}}
+
+	runner := fake.NewRunner()
+	runner.On("sh -c test -e '/srv/geoip'", fake.Response{Output: "yes"})
+	runner.On(`sh -c echo "$(id -u):$(id -g)"`, fake.Response{Output: "1001:1001"})
+	require.NoError(t, NewDeployment(runner, nil).createBindMounts(context.Background(), service))
+
+	var lines []string
+	for _, call := range runner.Calls() {
+		lines = append(lines, call.String())
+	}
+	assert.Equal(t, []string{
+		"sh -c test -e '/srv/geoip' && echo yes || true",
+		"sh -c test -e '/srv/uploads' && echo yes || true",
+		`sh -c echo "$(id -u):$(id -g)"`,
+		"docker run --rm -v /srv/uploads:/bind alpine:3 chown 1001:1001 /bind",
+	}, lines, "only missing sources are created, for the deploy user")
+
+	service.Container = &config.Container{User: "1000:1000"}
+	runner = fake.NewRunner()
+	require.NoError(t, NewDeployment(runner, nil).createBindMounts(context.Background(), service))
+	calls := runner.Calls()
+	assert.Equal(t, "docker run --rm -v /srv/uploads:/bind alpine:3 chown 1000:1000 /bind", calls[len(calls)-1].String())
+
+	runner = fake.NewRunner()
+	runner.On("sh -c test -e '/srv/geoip'", fake.Response{Output: "yes"})
+	runner.On("docker run", fake.Response{Output: "docker: Error response from daemon: mkdir /srv/uploads: permission denied.", ExitCode: 126})
+	err := NewDeployment(runner, nil).createBindMounts(context.Background(), service)
+	assert.ErrorContains(t, err, "failed to create bind mount /srv/uploads: command failed: exit status 126")
+	assert.ErrorContains(t, err, "permission denied")
+}
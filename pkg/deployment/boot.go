@@ -0,0 +1,122 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/proxy"
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+// BootOrderFile lists, in the project folder, the containers of the project
+// in the order they are brought back after the server reboots: dependencies
+// in depends_on order, then services, then the certificate manager and the
+// proxy, which resolves the services when it starts.
+const BootOrderFile = "boot.order"
+
+// BootScript runs when the server boots, once Docker is up. Docker restarts
+// the containers itself, all at once, so a service may crash-loop while its
+// database starts, and the proxy fails until the services resolve. The
+// script walks the boot order of every project of the user and, for each
+// container, starts it if it is stopped, restarts it if it is unhealthy or
+// crash-looping, and waits for it to be healthy before the next one.
+const BootScript = `#!/bin/sh
+i=0
+until docker info >/dev/null 2>&1; do
+  if [ -z "$DOCKER_HOST" ] && [ -S "/run/user/$(id -u)/docker.sock" ]; then
+    export DOCKER_HOST="unix:///run/user/$(id -u)/docker.sock"
+  fi
+  i=$((i + 1))
+  if [ "$i" -ge 300 ]; then
+    echo "Docker did not start" >&2
+    exit 1
+  fi
+  sleep 1
+done
+
+state() {
+  docker inspect --format '{{.State.Status}} {{if .State.Health}}{{.State.Health.Status}}{{end}}' "$1" 2>/dev/null
+}
+
+failed=0
+for order in "$HOME"/projects/*/` + BootOrderFile + `; do
+  [ -f "$order" ] || continue
+  for container in $(cat "$order"); do
+    case "$(state "$container")" in
+      "") echo "$container does not exist" >&2; continue ;;
+      "running "|"running healthy"|"running starting") ;;
+      running*|restarting*) docker restart "$container" >/dev/null ;;
+      *) docker start "$container" >/dev/null ;;
+    esac
+    i=0
+    until case "$(state "$container")" in "running "|"running healthy") true ;; *) false ;; esac; do
+      i=$((i + 1))
+      if [ "$i" -ge 180 ]; then
+        echo "$container is not healthy after 3 minutes" >&2
+        failed=1
+        break
+      fi
+      sleep 1
+    done
+  done
+done
+exit $failed
+`
+
+// bootOrder returns the containers of cfg in the order the server brings
+// them back after a reboot.
+func bootOrder(project string, cfg *config.Config) []string {
+	dependencies := make(map[string]*config.Dependency, len(cfg.Dependencies))
+	for i := range cfg.Dependencies {
+		dependencies[cfg.Dependencies[i].Name] = &cfg.Dependencies[i]
+	}
+
+	var containers []string
+	visited := make(map[string]bool)
+	var visit func(dependency *config.Dependency)
+	visit = func(dependency *config.Dependency) {
+		if visited[dependency.Name] {
+			return
+		}
+		visited[dependency.Name] = true
+		for _, name := range dependency.DependsOn {
+			if other, ok := dependencies[name]; ok {
+				visit(other)
+			}
+		}
+		containers = append(containers, containerName(project, dependency.Name, ""))
+	}
+	for i := range cfg.Dependencies {
+		visit(&cfg.Dependencies[i])
+	}
+
+	for i := range cfg.Services {
+		service := &cfg.Services[i]
+		if service.Static != nil || (service.Container != nil && service.Container.RunOnce) {
+			continue
+		}
+		for _, name := range replicaNames(service) {
+			containers = append(containers, containerName(project, name, ""))
+		}
+	}
+
+	return append(containers, containerName(project, proxy.ACMEUpstream, ""), containerName(project, "proxy", ""))
+}
+
+// writeBootOrder records the boot order of cfg in the project folder.
+func (d *Deployment) writeBootOrder(ctx context.Context, project string, cfg *config.Config) error {
+	projectPath, err := d.projectFolder(project)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(projectPath, BootOrderFile)
+	if output, err := d.runChecked(ctx, "sh", "-c", fmt.Sprintf("printf '%%s\\n' %s > %s", shell.Quote(strings.Join(bootOrder(project, cfg), "\n")), shell.Quote(path))); err != nil {
+		return outputError(fmt.Errorf("failed to write boot order: %w", err), output)
+	}
+
+	return nil
+}
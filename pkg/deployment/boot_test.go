@@ -0,0 +1,63 @@
+package deployment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestBootOrder(t *testing.T) {
+	cfg := &config.Config{
+		Dependencies: []config.Dependency{
+			{Name: "pgbouncer", DependsOn: []string{"postgres"}},
+			{Name: "redis"},
+			{Name: "postgres"},
+		},
+		Services: []config.Service{
+			{Name: "web", Replicas: 2},
+			{Name: "docs", Static: &config.Static{}},
+			{Name: "backup", Container: &config.Container{RunOnce: true}},
+			{Name: "api"},
+		},
+	}
+
+	assert.Equal(t, []string{
+		"app-postgres",
+		"app-pgbouncer",
+		"app-redis",
+		"app-web",
+		"app-web-2",
+		"app-api",
+		"app-zero",
+		"app-proxy",
+	}, bootOrder("app", cfg))
+}
+
+func TestWriteBootOrder(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+
+	cfg := &config.Config{Services: []config.Service{{Name: "web"}}}
+	require.NoError(t, NewDeployment(runner, nil).writeBootOrder(context.Background(), "app", cfg))
+
+	calls := runner.Calls()
+	assert.Equal(t, `sh -c printf '%s\n' 'app-web
+app-zero
+app-proxy' > '/home/deploy/projects/app/boot.order'`, calls[len(calls)-1].String())
+}
+
+func TestWriteBootOrder_Failure(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("sh -c printf", fake.Response{Output: "sh: can't create boot.order: Read-only file system", ExitCode: 1})
+
+	cfg := &config.Config{Services: []config.Service{{Name: "web"}}}
+	err := NewDeployment(runner, nil).writeBootOrder(context.Background(), "app", cfg)
+	assert.ErrorContains(t, err, "failed to write boot order")
+	assert.ErrorContains(t, err, "Read-only file system")
+}
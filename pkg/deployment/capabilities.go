@@ -0,0 +1,193 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yarlson/ftl/pkg/config"
+)
+
+const (
+	// minDockerVersion is the oldest Docker Engine release ftl deploys to.
+	minDockerVersion = "20.10"
+	// minPidsKernelVersion is the first kernel with the pids cgroup controller.
+	minPidsKernelVersion = "4.3"
+)
+
+// ServerCapabilities describes what the server can provide to containers.
+type ServerCapabilities struct {
+	DockerVersion string
+	KernelVersion string
+	MemoryBytes   int64
+	CPUs          int
+	MaxOpenFiles  int64
+}
+
+// ServerCapabilities probes the server for its Docker version, kernel,
+// memory, CPUs and open file limit. The probes run concurrently.
+func (d *Deployment) ServerCapabilities(ctx context.Context) (*ServerCapabilities, error) {
+	var (
+		caps ServerCapabilities
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+
+	probes := []struct {
+		name    string
+		command string
+		args    []string
+		parse   func(output string) error
+	}{
+		{"docker version", "docker", []string{"version", "--format", "{{.Server.Version}}"}, func(output string) error {
+			caps.DockerVersion = output
+			return nil
+		}},
+		{"kernel version", "uname", []string{"-r"}, func(output string) error {
+			caps.KernelVersion = output
+			return nil
+		}},
+		{"memory", "cat", []string{"/proc/meminfo"}, func(output string) error {
+			memory, err := parseMemTotal(output)
+			caps.MemoryBytes = memory
+			return err
+		}},
+		{"CPUs", "nproc", nil, func(output string) error {
+			cpus, err := strconv.Atoi(output)
+			caps.CPUs = cpus
+			return err
+		}},
+		{"open file limit", "cat", []string{"/proc/sys/fs/nr_open"}, func(output string) error {
+			limit, err := strconv.ParseInt(output, 10, 64)
+			caps.MaxOpenFiles = limit
+			return err
+		}},
+	}
+
+	for _, probe := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			output, err := d.runCommand(ctx, probe.command, probe.args...)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				err = probe.parse(output)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to detect %s: %w", probe.name, err))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	return &caps, nil
+}
+
+// CheckCapabilities reports every setting in cfg the server cannot satisfy.
+func CheckCapabilities(cfg *config.Config, caps *ServerCapabilities) []string {
+	var problems []string
+
+	if !versionAtLeast(caps.DockerVersion, minDockerVersion) {
+		problems = append(problems, fmt.Sprintf("server runs Docker %s, ftl requires %s or newer", caps.DockerVersion, minDockerVersion))
+	}
+
+	var reserved int64
+	check := func(kind, name string, resources *config.Resources, container *config.Container) {
+		if resources != nil {
+			if resources.CPUs > float64(caps.CPUs) {
+				problems = append(problems, fmt.Sprintf("%s %s: cpus %g exceeds the %d CPUs on the server", kind, name, resources.CPUs, caps.CPUs))
+			}
+			if resources.Memory != "" {
+				if memory, err := config.MemorySizeBytes(resources.Memory); err == nil && memory > caps.MemoryBytes {
+					problems = append(problems, fmt.Sprintf("%s %s: memory %s exceeds the %s available on the server", kind, name, resources.Memory, formatBytes(caps.MemoryBytes)))
+				}
+			}
+			if resources.MemoryReservation != "" {
+				if memory, err := config.MemorySizeBytes(resources.MemoryReservation); err == nil {
+					reserved += memory
+				}
+			}
+			if resources.PidsLimit > 0 && !versionAtLeast(caps.KernelVersion, minPidsKernelVersion) {
+				problems = append(problems, fmt.Sprintf("%s %s: pids_limit requires kernel %s or newer, server runs %s", kind, name, minPidsKernelVersion, caps.KernelVersion))
+			}
+		}
+
+		if container != nil {
+			for _, ulimit := range container.ULimits {
+				if ulimit.Name == "nofile" && caps.MaxOpenFiles > 0 && int64(ulimit.Hard) > caps.MaxOpenFiles {
+					problems = append(problems, fmt.Sprintf("%s %s: nofile hard limit %d exceeds the kernel maximum of %d", kind, name, ulimit.Hard, caps.MaxOpenFiles))
+				}
+			}
+		}
+	}
+
+	for _, service := range cfg.Services {
+		check("service", service.Name, service.Resources, service.Container)
+	}
+	for _, dependency := range cfg.Dependencies {
+		check("dependency", dependency.Name, dependency.Resources, dependency.Container)
+	}
+
+	if reserved > caps.MemoryBytes {
+		problems = append(problems, fmt.Sprintf("memory reservations total %s, more than the %s available on the server", formatBytes(reserved), formatBytes(caps.MemoryBytes)))
+	}
+
+	return problems
+}
+
+// parseMemTotal returns the MemTotal entry of /proc/meminfo in bytes.
+func parseMemTotal(meminfo string) (int64, error) {
+	for _, line := range strings.Split(meminfo, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return kb * 1024, nil
+		}
+	}
+	return 0, fmt.Errorf("MemTotal not found")
+}
+
+// versionAtLeast compares the leading major.minor numbers of a version such
+// as "27.5.1" or "6.8.0-45-generic" against minimum.
+func versionAtLeast(version, minimum string) bool {
+	parse := func(v string) [2]int {
+		var parts [2]int
+		for i, field := range strings.SplitN(v, ".", 3) {
+			if i >= 2 {
+				break
+			}
+			end := strings.IndexFunc(field, func(r rune) bool { return r < '0' || r > '9' })
+			if end >= 0 {
+				field = field[:end]
+			}
+			parts[i], _ = strconv.Atoi(field)
+		}
+		return parts
+	}
+
+	have, want := parse(version), parse(minimum)
+	if have[0] != want[0] {
+		return have[0] > want[0]
+	}
+	return have[1] >= want[1]
+}
+
+func formatBytes(bytes int64) string {
+	const gib = 1 << 30
+	if bytes >= gib {
+		return fmt.Sprintf("%.1fGiB", float64(bytes)/gib)
+	}
+	return fmt.Sprintf("%dMiB", bytes>>20)
+}
@@ -0,0 +1,73 @@
+package deployment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+)
+
+func TestCheckCapabilities(t *testing.T) {
+	caps := &ServerCapabilities{
+		DockerVersion: "27.5.1",
+		KernelVersion: "6.8.0-45-generic",
+		MemoryBytes:   2 << 30,
+		CPUs:          2,
+		MaxOpenFiles:  1048576,
+	}
+
+	t.Run("satisfiable config", func(t *testing.T) {
+		cfg := &config.Config{
+			Services: []config.Service{{
+				Name:      "web",
+				Resources: &config.Resources{CPUs: 1.5, Memory: "1g", MemoryReservation: "512m", PidsLimit: 100},
+				Container: &config.Container{ULimits: []config.ULimit{{Name: "nofile", Soft: 65536, Hard: 65536}}},
+			}},
+		}
+		assert.Empty(t, CheckCapabilities(cfg, caps))
+	})
+
+	t.Run("impossible config", func(t *testing.T) {
+		cfg := &config.Config{
+			Services: []config.Service{{
+				Name:      "web",
+				Resources: &config.Resources{CPUs: 4, Memory: "4g", MemoryReservation: "1536m"},
+				Container: &config.Container{ULimits: []config.ULimit{{Name: "nofile", Soft: 65536, Hard: 2097152}}},
+			}},
+			Dependencies: []config.Dependency{{
+				Name:      "postgres",
+				Resources: &config.Resources{MemoryReservation: "1g"},
+			}},
+		}
+
+		problems := CheckCapabilities(cfg, caps)
+		require.Len(t, problems, 4)
+		assert.Contains(t, problems[0], "cpus 4 exceeds the 2 CPUs")
+		assert.Contains(t, problems[1], "memory 4g exceeds the 2.0GiB")
+		assert.Contains(t, problems[2], "nofile hard limit 2097152")
+		assert.Contains(t, problems[3], "memory reservations total 2.5GiB")
+	})
+
+	t.Run("old docker and kernel", func(t *testing.T) {
+		old := &ServerCapabilities{DockerVersion: "19.03.8", KernelVersion: "4.1.0", MemoryBytes: 2 << 30, CPUs: 2}
+		cfg := &config.Config{
+			Services: []config.Service{{Name: "web", Resources: &config.Resources{PidsLimit: 100}}},
+		}
+
+		problems := CheckCapabilities(cfg, old)
+		require.Len(t, problems, 2)
+		assert.Contains(t, problems[0], "Docker 19.03.8")
+		assert.Contains(t, problems[1], "pids_limit requires kernel 4.3")
+	})
+}
+
+func TestParseMemTotal(t *testing.T) {
+	memory, err := parseMemTotal("MemTotal:        2014760 kB\nMemFree:          123456 kB\n")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2014760*1024), memory)
+
+	_, err = parseMemTotal("MemFree: 1 kB")
+	assert.Error(t, err)
+}
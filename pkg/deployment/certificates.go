@@ -0,0 +1,106 @@
+package deployment
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yarlson/ftl/pkg/config"
+)
+
+// certMonitorScript checks the certificate of every domain in $FTL_DOMAINS
+// once a day and posts to $FTL_WEBHOOK when it is missing or expires within
+// $FTL_ALERT_DAYS days. The first check waits an hour so certificates that
+// are still being issued on a first deploy are not reported.
+const certMonitorScript = `apk add --no-cache openssl >/dev/null 2>&1
+sleep 3600
+while true; do
+  for domain in $FTL_DOMAINS; do
+    cert="/certs/$domain.crt"
+    if [ ! -f "$cert" ]; then
+      message="No certificate for $domain: it could not be issued"
+    elif ! openssl x509 -noout -checkend $((FTL_ALERT_DAYS * 86400)) -in "$cert" >/dev/null 2>&1; then
+      end=$(openssl x509 -noout -enddate -in "$cert" | cut -d= -f2)
+      message="Certificate for $domain expires on $end and has not been renewed"
+    else
+      continue
+    fi
+    wget -q -O /dev/null --header 'Content-Type: application/json' \
+      --post-data "{\"text\":\"$message\",\"content\":\"$message\"}" "$FTL_WEBHOOK" || true
+  done
+  sleep 86400
+done`
+
+// Certificate is the state of the certificate served for a domain.
+type Certificate struct {
+	Domain   string
+	NotAfter time.Time
+	Err      error
+}
+
+// DaysLeft returns the number of whole days until the certificate expires.
+func (c Certificate) DaysLeft(now time.Time) int {
+	return int(c.NotAfter.Sub(now).Hours() / 24)
+}
+
+// Certificates reads the certificates the proxy serves for the domains.
+func (d *Deployment) Certificates(ctx context.Context, project string, domains []string) []Certificate {
+	certificates := make([]Certificate, 0, len(domains))
+	for _, domain := range domains {
+		certificate := Certificate{Domain: domain}
+		certificate.NotAfter, certificate.Err = d.certificateExpiry(ctx, project, domain)
+		certificates = append(certificates, certificate)
+	}
+	return certificates
+}
+
+func (d *Deployment) certificateExpiry(ctx context.Context, project, domain string) (time.Time, error) {
+	output, err := d.runner.RunCommand(ctx, "docker", "exec", containerName(project, "proxy", ""), "cat", "/etc/nginx/certs/"+domain+".crt")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	data, err := io.ReadAll(output)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read certificate: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return time.Time{}, fmt.Errorf("no certificate found")
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	return certificate.NotAfter, nil
+}
+
+// deployCertificateMonitor starts the container that sends certificate
+// expiry alerts.
+func (d *Deployment) deployCertificateMonitor(project string, cfg *config.Config) error {
+	service := &config.Service{
+		Name:         "cert-monitor",
+		Image:        "alpine:3",
+		Entrypoint:   []string{"sh"},
+		CommandSlice: []string{"-c", certMonitorScript},
+		Volumes:      []string{"certs:/certs:ro"},
+		Env: []string{
+			"FTL_DOMAINS=" + strings.Join(cfg.Domains(), " "),
+			"FTL_ALERT_DAYS=" + strconv.Itoa(cfg.TLS.AlertDays()),
+			"FTL_WEBHOOK=" + cfg.TLS.ExpiryAlert.Webhook,
+		},
+		Recreate: true,
+	}
+
+	if err := d.deployService(project, service); err != nil {
+		return fmt.Errorf("failed to deploy certificate monitor: %w", err)
+	}
+
+	return nil
+}
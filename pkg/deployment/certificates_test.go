@@ -0,0 +1,55 @@
+package deployment
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func testCertificate(t *testing.T, notAfter time.Time) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestCertificates(t *testing.T) {
+	notAfter := time.Now().Add(10*24*time.Hour + time.Hour).Truncate(time.Second)
+
+	runner := fake.NewRunner()
+	runner.On("docker exec project-proxy cat /etc/nginx/certs/example.com.crt", fake.Response{Output: testCertificate(t, notAfter)})
+	runner.On("docker exec project-proxy cat /etc/nginx/certs/api.example.com.crt", fake.Response{Output: "cat: can't open '/etc/nginx/certs/api.example.com.crt': No such file or directory"})
+
+	certificates := NewDeployment(runner, nil).Certificates(context.Background(), "project", []string{"example.com", "api.example.com"})
+	require.Len(t, certificates, 2)
+
+	assert.Equal(t, "example.com", certificates[0].Domain)
+	require.NoError(t, certificates[0].Err)
+	assert.True(t, notAfter.Equal(certificates[0].NotAfter))
+	assert.Equal(t, 10, certificates[0].DaysLeft(time.Now()))
+
+	assert.Equal(t, "api.example.com", certificates[1].Domain)
+	assert.EqualError(t, certificates[1].Err, "no certificate found")
+}
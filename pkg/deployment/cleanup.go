@@ -0,0 +1,250 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/docker"
+	"github.com/yarlson/ftl/pkg/shell"
+	"github.com/yarlson/pin"
+)
+
+// Retention defaults used when cleanup is not configured.
+const (
+	DefaultKeepReleases   = 3
+	DefaultPruneOlderThan = 720 * time.Hour
+)
+
+var anonymousVolumeRegex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// CleanupReport lists what Cleanup removed from the server.
+type CleanupReport struct {
+	Containers []string
+	Images     []string
+	Volumes    []string
+	Reclaimed  string
+}
+
+// Empty reports whether nothing was removed.
+func (r *CleanupReport) Empty() bool {
+	return len(r.Containers) == 0 && len(r.Images) == 0 && len(r.Volumes) == 0
+}
+
+// Cleanup applies the retention policy of cfg on the server. It removes
+// stopped containers on the project network that no longer belong to a
+// service or dependency, old tagged releases of the images the project uses,
+// untagged images past the age limit and anonymous volumes no container uses.
+func (d *Deployment) Cleanup(ctx context.Context, project string, cfg *config.Config, spinner *pin.Pin) (*CleanupReport, error) {
+	d.spinner = spinner
+
+	keepReleases := DefaultKeepReleases
+	pruneOlderThan := DefaultPruneOlderThan
+	if cfg.Cleanup != nil {
+		if cfg.Cleanup.KeepReleases > 0 {
+			keepReleases = cfg.Cleanup.KeepReleases
+		}
+		if cfg.Cleanup.PruneOlderThan > 0 {
+			pruneOlderThan = cfg.Cleanup.PruneOlderThan.Duration()
+		}
+	}
+
+	report := &CleanupReport{}
+	var err error
+
+	d.progress("Removing stopped containers...")
+	if report.Containers, err = d.pruneContainers(ctx, project, cfg); err != nil {
+		return nil, err
+	}
+
+	d.progress("Removing old releases...")
+	if report.Images, err = d.pruneReleases(ctx, project, cfg, keepReleases); err != nil {
+		return nil, err
+	}
+
+	d.progress("Removing unused images...")
+	output, err := d.runCommand(ctx, "docker", "image", "prune", "-f", "--filter", "until="+pruneOlderThan.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to prune images: %w", err)
+	}
+	report.Reclaimed = reclaimedSpace(output)
+
+	d.progress("Removing dangling volumes...")
+	if report.Volumes, err = d.pruneVolumes(ctx); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// pruneContainers removes stopped containers on the project network that are
+// not the container of a configured service or dependency, such as leftovers
+// of interrupted deploys and test runs.
+func (d *Deployment) pruneContainers(ctx context.Context, project string, cfg *config.Config) ([]string, error) {
+	keep := map[string]struct{}{}
+	for _, name := range systemServices {
+		keep[containerName(project, name, "")] = struct{}{}
+	}
+	for _, service := range cfg.Services {
+		for _, name := range replicaNames(&service) {
+			keep[containerName(project, name, "")] = struct{}{}
+		}
+	}
+	for _, dependency := range cfg.Dependencies {
+		keep[containerName(project, dependency.Name, "")] = struct{}{}
+	}
+
+	output, err := d.runCommand(ctx, "docker", "ps", "-a",
+		"--filter", fmt.Sprintf("network=%s", project),
+		"--filter", "status=exited",
+		"--filter", "status=created",
+		"--filter", "status=dead",
+		"--format", "{{.Names}}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stopped containers: %w", err)
+	}
+
+	var removed []string
+	for _, name := range strings.Fields(output) {
+		if _, ok := keep[name]; ok {
+			continue
+		}
+		ok, err := d.removeIfUnused(ctx, "docker rm", name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove container %s: %w", name, err)
+		}
+		if ok {
+			removed = append(removed, name)
+		}
+	}
+
+	return removed, nil
+}
+
+// pruneReleases removes all but the newest keep tags of every repository the
+// project's services and dependencies use. The tags in ftl.yaml are always
+// kept, and docker refuses to remove images that a container still uses.
+func (d *Deployment) pruneReleases(ctx context.Context, project string, cfg *config.Config, keep int) ([]string, error) {
+	current := map[string]struct{}{}
+	seen := map[string]struct{}{}
+	var repositories []string
+	addImage := func(image string) {
+		if image == "" {
+			return
+		}
+		repository := docker.Repository(image)
+		if repository == image {
+			image += ":latest"
+		}
+		current[image] = struct{}{}
+		if _, ok := seen[repository]; !ok {
+			seen[repository] = struct{}{}
+			repositories = append(repositories, repository)
+		}
+	}
+	for _, service := range cfg.Services {
+		if service.Static != nil {
+			continue
+		}
+		if service.Image == "" {
+			addImage(fmt.Sprintf("%s-%s", project, service.Name))
+			continue
+		}
+		addImage(service.Image)
+	}
+	for _, dependency := range cfg.Dependencies {
+		addImage(dependency.Image)
+	}
+
+	var removed []string
+	for _, repository := range repositories {
+		output, err := d.runCommand(ctx, "docker", "images", repository, "--format", "{{.Repository}}:{{.Tag}}")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list images of %s: %w", repository, err)
+		}
+
+		for _, image := range releasesToPrune(strings.Fields(output), current, keep) {
+			ok, err := d.removeIfUnused(ctx, "docker rmi", image)
+			if err != nil {
+				return nil, fmt.Errorf("failed to remove image %s: %w", image, err)
+			}
+			if ok {
+				removed = append(removed, image)
+			}
+		}
+	}
+
+	return removed, nil
+}
+
+// releasesToPrune returns the images past the newest keep, skipping untagged
+// images and the images in current. Images are listed newest first.
+func releasesToPrune(images []string, current map[string]struct{}, keep int) []string {
+	var prune []string
+	kept := 0
+	for _, image := range images {
+		if strings.HasSuffix(image, ":<none>") {
+			continue
+		}
+		if _, ok := current[image]; ok {
+			kept++
+			continue
+		}
+		if kept < keep {
+			kept++
+			continue
+		}
+		prune = append(prune, image)
+	}
+
+	return prune
+}
+
+// pruneVolumes removes anonymous volumes that no container uses. Named volumes
+// hold project data and are never removed.
+func (d *Deployment) pruneVolumes(ctx context.Context) ([]string, error) {
+	output, err := d.runCommand(ctx, "docker", "volume", "ls", "-q", "--filter", "dangling=true")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dangling volumes: %w", err)
+	}
+
+	var removed []string
+	for _, volume := range strings.Fields(output) {
+		if !anonymousVolumeRegex.MatchString(volume) {
+			continue
+		}
+		ok, err := d.removeIfUnused(ctx, "docker volume rm", volume)
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove volume %s: %w", volume, err)
+		}
+		if ok {
+			removed = append(removed, volume[:12])
+		}
+	}
+
+	return removed, nil
+}
+
+// removeIfUnused runs a docker removal command and reports whether it
+// succeeded. Docker rejects removing resources that are still in use, which
+// is not an error here.
+func (d *Deployment) removeIfUnused(ctx context.Context, command, name string) (bool, error) {
+	output, err := d.runCommand(ctx, "sh", "-c", fmt.Sprintf("%s %s >/dev/null 2>&1 && echo removed || true", command, shell.Quote(name)))
+	if err != nil {
+		return false, err
+	}
+	return output == "removed", nil
+}
+
+// reclaimedSpace extracts the total from docker prune output.
+func reclaimedSpace(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if value, ok := strings.CutPrefix(line, "Total reclaimed space:"); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
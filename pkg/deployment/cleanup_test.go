@@ -0,0 +1,29 @@
+package deployment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleasesToPrune(t *testing.T) {
+	images := []string{
+		"ghcr.io/acme/web:v5",
+		"ghcr.io/acme/web:<none>",
+		"ghcr.io/acme/web:v4",
+		"ghcr.io/acme/web:v3",
+		"ghcr.io/acme/web:v2",
+		"ghcr.io/acme/web:v1",
+	}
+	current := map[string]struct{}{"ghcr.io/acme/web:v2": {}}
+
+	assert.Equal(t, []string{"ghcr.io/acme/web:v1"}, releasesToPrune(images, current, 3))
+	assert.Equal(t, []string{"ghcr.io/acme/web:v4", "ghcr.io/acme/web:v3", "ghcr.io/acme/web:v1"}, releasesToPrune(images, current, 1))
+	assert.Empty(t, releasesToPrune(images, current, 5))
+}
+
+func TestReclaimedSpace(t *testing.T) {
+	output := "Deleted Images:\ndeleted: sha256:0123\n\nTotal reclaimed space: 1.2GB"
+	assert.Equal(t, "1.2GB", reclaimedSpace(output))
+	assert.Equal(t, "", reclaimedSpace(""))
+}
@@ -3,12 +3,28 @@ package deployment
 import (
 	"context"
 	"fmt"
+	"path"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/shell"
 )
 
+// deployDependencies starts the dependencies concurrently, except that a
+// dependency waits for those it depends on to run and, when another dependency
+// depends on it, waits to become healthy before its dependents start.
 func (d *Deployment) deployDependencies(ctx context.Context, project string, dependencies []config.Dependency) error {
+	starts := make(map[string]*dependencyStart, len(dependencies))
+	awaited := map[string]bool{}
+	for _, dep := range dependencies {
+		starts[dep.Name] = &dependencyStart{ready: make(chan struct{})}
+		for _, name := range dep.DependsOn {
+			awaited[name] = true
+		}
+	}
+
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(dependencies))
 
@@ -16,12 +32,16 @@ func (d *Deployment) deployDependencies(ctx context.Context, project string, dep
 		wg.Add(1)
 		go func(dep config.Dependency) {
 			defer wg.Done()
+			start := starts[dep.Name]
+			defer close(start.ready)
 
-			if err := d.startDependency(project, &dep); err != nil {
-				errChan <- fmt.Errorf("failed to deploy dependency %s: %w", dep.Name, err)
+			if start.err = d.startInOrder(ctx, project, &dep, starts, awaited[dep.Name]); start.err != nil {
+				err := fmt.Errorf("failed to deploy dependency %s: %w", dep.Name, start.err)
+				d.emit(Event{Type: EventDependencyFailed, Service: dep.Name, Message: err.Error(), Err: err})
+				errChan <- err
 				return
 			}
-
+			d.emit(Event{Type: EventDependencyDeployed, Service: dep.Name, Message: dep.Name + " deployed"})
 		}(dep)
 	}
 
@@ -40,17 +60,213 @@ func (d *Deployment) deployDependencies(ctx context.Context, project string, dep
 	return nil
 }
 
-func (d *Deployment) startDependency(project string, dependency *config.Dependency) error {
+// dependencyStart is the outcome of starting a dependency, set before ready is
+// closed.
+type dependencyStart struct {
+	ready chan struct{}
+	err   error
+}
+
+// startInOrder starts dependency once the dependencies it depends on are
+// ready, and waits for it to become healthy when healthy is set or it has
+// init steps to run. Dependencies
+// that are not being deployed, as those of inactive profiles, are not waited
+// for.
+func (d *Deployment) startInOrder(ctx context.Context, project string, dependency *config.Dependency, starts map[string]*dependencyStart, healthy bool) error {
+	for _, name := range dependency.DependsOn {
+		start, ok := starts[name]
+		if !ok {
+			continue
+		}
+		select {
+		case <-start.ready:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if start.err != nil {
+			return fmt.Errorf("%s, which it depends on, failed to start", name)
+		}
+	}
+
+	if err := d.startDependency(project, dependency); err != nil {
+		return err
+	}
+
+	if healthy || len(dependency.Init) > 0 {
+		d.progress(fmt.Sprintf("Waiting for %s to become healthy...", dependency.Name))
+		if err := d.dockerManager.WaitHealthy(containerName(project, dependency.Name, ""), healthTimeout(dependency)); err != nil {
+			return err
+		}
+	}
+
+	return d.initDependency(ctx, project, dependency)
+}
+
+// healthTimeout returns how long a dependency may take to become healthy:
+// the start period and every retry of its health check, with Docker's
+// defaults for the settings that are not configured.
+func healthTimeout(dependency *config.Dependency) time.Duration {
+	return containerHealthTimeout(dependency.Container)
+}
+
+// containerHealthTimeout is healthTimeout for the container health check
+// of a dependency or a worker.
+func containerHealthTimeout(container *config.Container) time.Duration {
+	interval, timeout, retries := 30*time.Second, 30*time.Second, 3
+	var startPeriod time.Duration
+	if container != nil && container.HealthCheck != nil {
+		hc := container.HealthCheck
+		if hc.Interval > 0 {
+			interval = hc.Interval.Duration()
+		}
+		if hc.Timeout > 0 {
+			timeout = hc.Timeout.Duration()
+		}
+		if hc.Retries > 0 {
+			retries = hc.Retries
+		}
+		startPeriod = hc.StartPeriod.Duration()
+	}
+	return startPeriod + time.Duration(retries)*(interval+timeout)
+}
+
+// DependencyService returns the service a dependency is deployed as, without
+// its configs, which are mounted from wherever they are written.
+func DependencyService(dependency *config.Dependency) *config.Service {
 	service := &config.Service{
 		Name:       dependency.Name,
 		Image:      dependency.Image,
 		Volumes:    dependency.Volumes,
 		Env:        dependency.Env,
 		LocalPorts: dependency.Ports,
+		Container:  dependency.Container,
+		Resources:  dependency.Resources,
+		Restart:    dependency.Restart,
+		CrashAlert: dependency.CrashAlert,
+		Logging:    dependency.Logging,
+		Networks:   dependency.Networks,
+		Aliases:    dependency.Aliases,
+		Isolated:   len(dependency.Networks) > 0,
+	}
+
+	// Docker takes the executable of the entrypoint on its own and the rest
+	// of it as arguments before the command.
+	if len(dependency.Entrypoint) > 0 {
+		service.Entrypoint = dependency.Entrypoint[:1]
+		service.CommandSlice = slices.Clone(dependency.Entrypoint[1:])
+	}
+	service.CommandSlice = append(service.CommandSlice, dependency.Command...)
+	if len(service.CommandSlice) == 0 {
+		service.CommandSlice = nil
+	}
+
+	return service
+}
+
+// ConfigMounts returns the volumes that mount the configs of dependency from
+// files written to dir with WriteConfigs.
+func ConfigMounts(dependency *config.Dependency, dir string) []string {
+	var volumes []string
+	for i := range dependency.Configs {
+		file := &dependency.Configs[i]
+		volumes = append(volumes, fmt.Sprintf("%s:%s:ro", path.Join(dir, file.FileName()), file.Target))
+	}
+	return volumes
+}
+
+// dependencyService returns the service dependency is deployed as, with its
+// configs mounted from the project folder.
+func (d *Deployment) dependencyService(project string, dependency *config.Dependency) (*config.Service, error) {
+	service := DependencyService(dependency)
+	if len(dependency.Configs) == 0 {
+		return service, nil
+	}
+
+	dir, err := d.configsFolder(project, dependency)
+	if err != nil {
+		return nil, err
+	}
+	service.Volumes = append(slices.Clone(service.Volumes), ConfigMounts(dependency, dir)...)
+	return service, nil
+}
+
+// configsFolder returns the folder on the server the configs of dependency are
+// written to.
+func (d *Deployment) configsFolder(project string, dependency *config.Dependency) (string, error) {
+	projectPath, err := d.projectFolder(project)
+	if err != nil {
+		return "", fmt.Errorf("failed to get project folder path: %w", err)
+	}
+	return path.Join(projectPath, "configs", dependency.Name), nil
+}
+
+func (d *Deployment) startDependency(project string, dependency *config.Dependency) error {
+	service, err := d.dependencyService(project, dependency)
+	if err != nil {
+		return err
+	}
+	if err := d.writeConfigs(context.Background(), project, dependency); err != nil {
+		return err
 	}
 	if err := d.deployService(project, service); err != nil {
 		return fmt.Errorf("failed to start container for %s: %v", dependency.Image, err)
 	}
 
+	return d.removeStaleConfigs(context.Background(), project, dependency)
+}
+
+// writeConfigs writes the configs of dependency to the server. Files are named
+// after their content, so those that exist are left alone.
+func (d *Deployment) writeConfigs(ctx context.Context, project string, dependency *config.Dependency) error {
+	if len(dependency.Configs) == 0 {
+		return nil
+	}
+
+	dir, err := d.configsFolder(project, dependency)
+	if err != nil {
+		return err
+	}
+	if output, err := d.runChecked(ctx, "mkdir", "-p", dir); err != nil {
+		return outputError(fmt.Errorf("failed to create %s: %w", dir, err), output)
+	}
+
+	for i := range dependency.Configs {
+		file := &dependency.Configs[i]
+		remote := path.Join(dir, file.FileName())
+		exists, err := d.runCommand(ctx, "sh", "-c", "test -f "+shell.Quote(remote)+" && echo yes || true")
+		if err != nil {
+			return fmt.Errorf("failed to check config %s of %s: %w", file.Target, dependency.Name, err)
+		}
+		if exists == "yes" {
+			continue
+		}
+
+		d.progress(fmt.Sprintf("Writing %s of %s...", file.Target, dependency.Name))
+		if err := d.writeRemoteFile(ctx, remote, file.Content); err != nil {
+			return fmt.Errorf("failed to write config %s of %s: %w", file.Target, dependency.Name, err)
+		}
+		if output, err := d.runChecked(ctx, "chmod", file.FileMode(), remote); err != nil {
+			return outputError(fmt.Errorf("failed to set the mode of config %s of %s: %w", file.Target, dependency.Name, err), output)
+		}
+	}
+	return nil
+}
+
+// removeStaleConfigs removes the files of the configs dependency no longer
+// has, once its container mounts the current ones. Until then the previous
+// container may still need them to restart.
+func (d *Deployment) removeStaleConfigs(ctx context.Context, project string, dependency *config.Dependency) error {
+	dir, err := d.configsFolder(project, dependency)
+	if err != nil {
+		return err
+	}
+
+	script := fmt.Sprintf("[ ! -d %[1]s ] || find %[1]s -maxdepth 1 -type f", shell.Quote(dir))
+	for i := range dependency.Configs {
+		script += " ! -name " + shell.Quote(dependency.Configs[i].FileName())
+	}
+	if output, err := d.runChecked(ctx, "sh", "-c", script+" -delete"); err != nil {
+		return outputError(fmt.Errorf("failed to remove old configs of %s: %w", dependency.Name, err), output)
+	}
 	return nil
 }
@@ -0,0 +1,139 @@
+package deployment
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestDeployDependencies_DependsOn(t *testing.T) {
+	dependencies := []config.Dependency{
+		{Name: "pgbouncer", Image: "edoburu/pgbouncer:latest", DependsOn: []string{"postgres"}},
+		{Name: "postgres", Image: "postgres:17"},
+		{Name: "redis", Image: "redis:7"},
+	}
+
+	runner := fake.NewRunner()
+	runner.On("docker inspect --format={{if .State.Health}}{{.State.Health.Status}}{{end}}", fake.Response{Output: "healthy"})
+	require.NoError(t, NewDeployment(runner, nil).deployDependencies(context.Background(), "project", dependencies))
+
+	var lines []string
+	for _, call := range runner.Calls() {
+		lines = append(lines, call.String())
+	}
+	index := func(prefix string) int {
+		return slices.IndexFunc(lines, func(line string) bool { return strings.HasPrefix(line, prefix) })
+	}
+	postgres := index("docker run --detach --name project-postgres ")
+	health := index("docker inspect --format={{if .State.Health}}{{.State.Health.Status}}{{end}} project-postgres")
+	pgbouncer := index("docker run --detach --name project-pgbouncer ")
+	require.NotEqual(t, -1, postgres)
+	assert.Less(t, postgres, health)
+	assert.Less(t, health, pgbouncer)
+	assert.Equal(t, -1, index("docker inspect --format={{if .State.Health}}{{.State.Health.Status}}{{end}} project-redis"), "nothing depends on redis")
+}
+
+func TestDeployDependencies_DependencyFails(t *testing.T) {
+	dependencies := []config.Dependency{
+		{Name: "postgres", Image: "postgres:17"},
+		{Name: "pgbouncer", Image: "edoburu/pgbouncer:latest", DependsOn: []string{"postgres"}},
+	}
+
+	runner := fake.NewRunner()
+	runner.On("docker inspect --format={{if .State.Health}}{{.State.Health.Status}}{{end}}", fake.Response{Output: "unhealthy"})
+	err := NewDeployment(runner, nil).deployDependencies(context.Background(), "project", dependencies)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "project-postgres is unhealthy")
+	assert.Contains(t, err.Error(), "postgres, which it depends on, failed to start")
+
+	for _, call := range runner.Calls() {
+		assert.False(t, strings.HasPrefix(call.String(), "docker run --detach --name project-pgbouncer "), "pgbouncer must not start")
+	}
+
+	runner = fake.NewRunner()
+	runner.On("docker run --detach --name project-postgres", fake.Response{Err: errors.New("port is already allocated")})
+	err = NewDeployment(runner, nil).deployDependencies(context.Background(), "project", dependencies)
+	assert.ErrorContains(t, err, "postgres, which it depends on, failed to start")
+}
+
+func TestHealthTimeout(t *testing.T) {
+	assert.Equal(t, 3*time.Minute, healthTimeout(&config.Dependency{}))
+	assert.Equal(t, 40*time.Second, healthTimeout(&config.Dependency{Container: &config.Container{HealthCheck: &config.ContainerHealthCheck{
+		Interval:    config.Duration(5 * time.Second),
+		Timeout:     config.Duration(5 * time.Second),
+		Retries:     3,
+		StartPeriod: config.Duration(10 * time.Second),
+	}}}))
+}
+
+func TestDependencyService_Command(t *testing.T) {
+	service := DependencyService(&config.Dependency{Name: "postgres", Image: "postgres:16", Command: []string{"postgres", "-c", "config_file=/etc/postgresql/postgresql.conf"}})
+	assert.Empty(t, service.Entrypoint)
+	assert.Equal(t, []string{"postgres", "-c", "config_file=/etc/postgresql/postgresql.conf"}, service.CommandSlice)
+
+	service = DependencyService(&config.Dependency{Name: "redis", Image: "redis:7", Entrypoint: []string{"docker-entrypoint.sh", "redis-server"}, Command: []string{"--save", ""}})
+	assert.Equal(t, []string{"docker-entrypoint.sh"}, service.Entrypoint)
+	assert.Equal(t, []string{"redis-server", "--save", ""}, service.CommandSlice)
+
+	assert.Nil(t, DependencyService(&config.Dependency{Name: "redis", Image: "redis:7"}).CommandSlice)
+}
+
+func TestStartDependency_Configs(t *testing.T) {
+	dependency := &config.Dependency{
+		Name:    "postgres",
+		Image:   "postgres:16",
+		Volumes: []string{"data:/var/lib/postgresql/data"},
+		Configs: []config.ConfigFile{{Target: "/etc/postgresql/postgresql.conf", Content: "shared_buffers = 256MB\n", Mode: "0640"}},
+	}
+	name := dependency.Configs[0].FileName()
+	remote := "/home/deploy/projects/project/configs/postgres/" + name
+
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	require.NoError(t, NewDeployment(runner, nil).startDependency("project", dependency))
+
+	content, ok := runner.File(remote)
+	require.True(t, ok)
+	assert.Equal(t, "shared_buffers = 256MB\n", string(content))
+
+	var lines []string
+	for _, call := range runner.Calls() {
+		lines = append(lines, call.String())
+	}
+	assert.Contains(t, lines, "chmod 0640 "+remote)
+	run := slices.IndexFunc(lines, func(line string) bool { return strings.HasPrefix(line, "docker run --detach --name project-postgres ") })
+	require.NotEqual(t, -1, run)
+	assert.Contains(t, lines[run], "-v "+remote+":/etc/postgresql/postgresql.conf:ro")
+	assert.Equal(t, []string{"data:/var/lib/postgresql/data"}, dependency.Volumes)
+	assert.Contains(t, lines[len(lines)-1], "! -name '"+name+"' -delete")
+}
+
+func TestWriteConfigs_Failure(t *testing.T) {
+	dependency := &config.Dependency{
+		Name:    "postgres",
+		Image:   "postgres:16",
+		Configs: []config.ConfigFile{{Target: "/etc/postgresql/postgresql.conf", Content: "shared_buffers = 256MB\n", Mode: "0640"}},
+	}
+
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("chmod 0640", fake.Response{Output: "chmod: changing permissions: Operation not permitted", ExitCode: 1})
+	err := NewDeployment(runner, nil).writeConfigs(context.Background(), "project", dependency)
+	assert.ErrorContains(t, err, "failed to set the mode of config /etc/postgresql/postgresql.conf of postgres")
+	assert.ErrorContains(t, err, "Operation not permitted")
+
+	runner.Reset()
+	runner.On("sh -c [ ! -d", fake.Response{Output: "find: cannot delete: Permission denied", ExitCode: 1})
+	err = NewDeployment(runner, nil).removeStaleConfigs(context.Background(), "project", dependency)
+	assert.ErrorContains(t, err, "failed to remove old configs of postgres")
+	assert.ErrorContains(t, err, "Permission denied")
+}
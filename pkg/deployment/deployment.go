@@ -1,16 +1,20 @@
 package deployment
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
 	"strings"
 
+	"github.com/yarlson/ftl/pkg/runner"
 	"github.com/yarlson/ftl/pkg/runner/local"
 
 	"github.com/yarlson/ftl/pkg/config"
 	"github.com/yarlson/ftl/pkg/docker"
+	"github.com/yarlson/ftl/pkg/shell"
 	"github.com/yarlson/pin"
 )
 
@@ -18,6 +22,10 @@ const (
 	newContainerSuffix = "_new"
 )
 
+// ErrRolledBack is wrapped by deploy errors when an updated service failed its
+// health check and the previous container was kept serving traffic.
+var ErrRolledBack = errors.New("rolled back to the previous container")
+
 func containerName(project, service, suffix string) string {
 	return fmt.Sprintf("%s-%s%s", project, service, suffix)
 }
@@ -33,37 +41,80 @@ type ImageSyncer interface {
 	CompareImages(ctx context.Context, image string) (bool, error)
 }
 
+// progressReporter is implemented by image syncers that report the progress
+// of transfers.
+type progressReporter interface {
+	SetProgress(progress func(message string))
+}
+
+// systemServices are the containers ftl runs next to the project's services.
+var systemServices = []string{"proxy", "zero", "watcher", "cert-monitor", "metrics", "metrics-agent"}
+
 type Deployment struct {
 	runner        Runner
-	localRunner   *local.Runner
+	localRunner   runner.CommandRunner
 	syncer        ImageSyncer
 	dockerManager *docker.DockerManager
+	spinner       *pin.Pin
+	events        chan<- Event
+	dialect       *shell.Dialect
+	timings       timings
+	spans         spans
+	pulled        pulledImages
 }
 
 func NewDeployment(runner Runner, syncer ImageSyncer) *Deployment {
-	return &Deployment{
-		runner:        runner,
-		syncer:        syncer,
-		localRunner:   local.NewRunner(),
-		dockerManager: docker.NewDockerManager(runner),
+	d := &Deployment{
+		syncer:      syncer,
+		localRunner: local.NewRunner(),
 	}
+	d.runner = &tracedRunner{Runner: runner, deployment: d}
+	d.dockerManager = docker.NewDockerManager(d.runner)
+	return d
 }
 
-func (d *Deployment) Deploy(ctx context.Context, project string, cfg *config.Config, spinner *pin.Pin) error {
-	spinner.UpdateMessage("Creating project network...")
+// Deploy deploys the project. When services is not nil only the named
+// services are deployed; dependencies are always brought up and the proxy
+// keeps routing to the services that are already running.
+func (d *Deployment) Deploy(ctx context.Context, project string, cfg *config.Config, spinner *pin.Pin, services []string) (err error) {
+	ctx, end := d.startDeploySpan(ctx, project)
+	defer func() { end(err) }()
+
+	d.spinner = spinner
+	d.dockerManager.SetPolicy(dockerPolicy(cfg))
+	d.timings.reset()
+	d.pulled.reset()
+
+	selected := cfg.Services
+	if services != nil {
+		selected = filterServices(cfg.Services, services)
+	}
+
+	d.stage("Creating project network...")
 	// Create project network
-	if err := d.dockerManager.EnsureNetwork(project); err != nil {
+	if err := d.ensureNetwork(project, cfg); err != nil {
 		return fmt.Errorf("failed to create network: %w", err)
 	}
+	if err := d.createNetworks(project, cfg); err != nil {
+		return err
+	}
 
-	spinner.UpdateMessage("Creating volumes...")
+	d.stage("Creating volumes...")
 	// Create volumes
 	cfg.Volumes = append(cfg.Volumes, "certs")
+	if cfg.Metrics != nil {
+		cfg.Volumes = append(cfg.Volumes, "metrics")
+	}
 	if err := d.createVolumes(ctx, project, cfg.Volumes); err != nil {
 		return fmt.Errorf("failed to create volumes: %w", err)
 	}
 
-	spinner.UpdateMessage("Deploying dependencies...")
+	d.stage("Pulling images...")
+	if err := d.prePullImages(ctx, cfg.Dependencies, selected); err != nil {
+		return fmt.Errorf("failed to pull images: %w", err)
+	}
+
+	d.stage("Deploying dependencies...")
 	// Deploy dependencies
 	if err := d.deployDependencies(ctx, project, cfg.Dependencies); err != nil {
 		return fmt.Errorf("failed to deploy dependencies: %w", err)
@@ -74,29 +125,71 @@ func (d *Deployment) Deploy(ctx context.Context, project string, cfg *config.Con
 	defer tunnelCancel()
 
 	if hasLocalHooks(cfg) {
-		spinner.UpdateMessage("Starting tunnels for local hooks...")
+		d.stage("Starting tunnels for local hooks...")
 		if err := d.startTunnels(tunnelCtx, cfg); err != nil {
 			return fmt.Errorf("failed to start tunnels: %w", err)
 		}
 	}
 
-	spinner.UpdateMessage("Deploying services...")
+	d.stage("Deploying services...")
 	// Deploy services
-	if err := d.deployServices(ctx, project, cfg.Services); err != nil {
+	if err := d.deployServices(ctx, project, selected); err != nil {
 		return fmt.Errorf("failed to deploy services: %w", err)
 	}
 
 	tunnelCancel()
 
-	spinner.UpdateMessage("Starting proxy configuration...")
+	if hasCrashAlerts(cfg) {
+		d.stage("Deploying crash watcher...")
+		if err := d.deployCrashWatcher(project, cfg); err != nil {
+			return err
+		}
+	}
+
+	if cfg.TLS != nil && cfg.TLS.ExpiryAlert != nil {
+		d.stage("Deploying certificate monitor...")
+		if err := d.deployCertificateMonitor(project, cfg); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Metrics != nil {
+		d.stage("Deploying metrics...")
+		if err := d.deployMetrics(project, cfg); err != nil {
+			return err
+		}
+	}
+
+	proxyCfg := cfg
+	if services != nil {
+		var err error
+		if proxyCfg, err = d.proxyConfig(ctx, project, cfg, services); err != nil {
+			return fmt.Errorf("failed to compute proxy configuration: %w", err)
+		}
+	}
+
+	d.stage("Starting proxy configuration...")
 	// Setup proxy
-	if err := d.startProxy(ctx, project, cfg); err != nil {
+	if err := d.timed(PhaseCutover, nil, func() error { return d.startProxy(ctx, project, proxyCfg) }); err != nil {
 		return fmt.Errorf("failed to start proxy: %w", err)
 	}
 
+	if err := d.writeBootOrder(ctx, project, cfg); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// progress reports a status message on the deployment spinner and events
+// channel, if any.
+func (d *Deployment) progress(message string) {
+	if d.spinner != nil {
+		d.spinner.UpdateMessage(message)
+	}
+	d.emit(Event{Type: EventProgress, Message: message})
+}
+
 func (d *Deployment) runCommand(ctx context.Context, command string, args ...string) (string, error) {
 	output, err := d.runner.RunCommand(ctx, command, args...)
 	if err != nil {
@@ -111,18 +204,64 @@ func (d *Deployment) runCommand(ctx context.Context, command string, args ...str
 	return strings.TrimSpace(string(outputBytes)), nil
 }
 
-func (d *Deployment) runLocalCommand(ctx context.Context, command string, args ...string) (string, error) {
-	output, err := d.runner.RunCommand(ctx, command, args...)
-	if err != nil {
-		return "", fmt.Errorf("failed to run command: %w", err)
+// runChecked runs a command like runCommand, but also fails when the command
+// exits with a non-zero status. The output is returned either way.
+func (d *Deployment) runChecked(ctx context.Context, command string, args ...string) (string, error) {
+	output, err := runner.RunChecked(ctx, d.runner, command, args...)
+	return strings.TrimSpace(string(output)), err
+}
+
+// outputError appends the output of a failed command to err, when it has
+// any.
+func outputError(err error, output string) error {
+	if output == "" {
+		return err
 	}
+	return fmt.Errorf("%w\n\x1b[93mOutput:\x1b[0m\n\x1b[90m%s\x1b[0m", err, output)
+}
 
-	outputBytes, readErr := io.ReadAll(output)
-	if readErr != nil {
-		return "", fmt.Errorf("failed to read command output: %v (original error: %w)", readErr, err)
+// streamChecked runs a command like runChecked, passing every line of output
+// to line as it is produced.
+func (d *Deployment) streamChecked(ctx context.Context, line func(string), command string, args ...string) error {
+	reader, writer := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line(scanner.Text())
+		}
+		_, _ = io.Copy(io.Discard, reader)
+	}()
+
+	err := runner.RunCheckedWithOutput(ctx, d.runner, writer, command, args...)
+	_ = writer.Close()
+	<-done
+	return err
+}
+
+// shellDialect returns the flavour of the utilities on the server, detected
+// on first use.
+func (d *Deployment) shellDialect(ctx context.Context) (shell.Dialect, error) {
+	if d.dialect == nil {
+		dialect, err := shell.Detect(ctx, d.runner)
+		if err != nil {
+			return shell.POSIX, err
+		}
+		d.dialect = &dialect
 	}
+	return *d.dialect, nil
+}
 
-	return strings.TrimSpace(string(outputBytes)), nil
+// runLocalCommand runs a command on this machine rather than on the server,
+// and fails when it exits with a non-zero status.
+func (d *Deployment) runLocalCommand(ctx context.Context, command string, args ...string) (string, error) {
+	output, err := runner.RunChecked(ctx, d.localRunner, command, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to run command: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
 }
 
 func (d *Deployment) makeProjectFolder(projectName string) error {
@@ -149,14 +288,81 @@ func (d *Deployment) projectFolder(projectName string) (string, error) {
 
 func (d *Deployment) updateImage(project string, service *config.Service) error {
 	if service.Image == "" {
-		updated, err := d.syncer.Sync(context.Background(), fmt.Sprintf("%s-%s", project, service.Name))
+		if d.syncer == nil {
+			return fmt.Errorf("service %s has no image and no image syncer is set", service.Name)
+		}
+		if reporter, ok := d.syncer.(progressReporter); ok {
+			reporter.SetProgress(d.progress)
+		}
+		var updated bool
+		err := d.timed(PhasePush, service, func() error {
+			var err error
+			updated, err = d.syncer.Sync(context.Background(), fmt.Sprintf("%s-%s", project, service.Name))
+			return err
+		})
 		if err != nil {
 			return err
 		}
 		service.ImageUpdated = updated
+		return nil
 	}
 
-	return d.dockerManager.PullImage(service.Image)
+	if image, ok := d.pulled.get(newPullKey(service)); ok {
+		service.Image = image
+		return nil
+	}
+
+	if service.Verify != nil {
+		d.progress(fmt.Sprintf("Verifying signature of %s...", service.Image))
+		image, err := d.verifyImage(context.Background(), service)
+		if err != nil {
+			return err
+		}
+		service.Image = image
+	}
+
+	return d.timed(PhasePull, service, func() error { return d.dockerManager.PullImage(service.Image) })
+}
+
+// dockerPolicy returns the timeouts and retries of the remote operations cfg
+// configures.
+func dockerPolicy(cfg *config.Config) docker.Policy {
+	timeouts := cfg.ServerTimeouts()
+	return docker.Policy{
+		Retry:         cfg.RetryPolicy(),
+		PullTimeout:   timeouts.Pull.Duration(),
+		HealthTimeout: timeouts.HealthCheck.Duration(),
+	}
+}
+
+// ensureNetwork creates network unless it exists, with IPv6 enabled when the
+// server serves IPv6.
+func (d *Deployment) ensureNetwork(network string, cfg *config.Config) error {
+	if cfg.Server != nil && cfg.Server.IPv6 {
+		return d.dockerManager.EnsureIPv6Network(network)
+	}
+	return d.dockerManager.EnsureNetwork(network)
+}
+
+// createNetworks creates the private networks of the project.
+func (d *Deployment) createNetworks(project string, cfg *config.Config) error {
+	for _, network := range privateNetworks(project, cfg.Networks) {
+		if err := d.ensureNetwork(network, cfg); err != nil {
+			return fmt.Errorf("failed to create network %s: %w", network, err)
+		}
+	}
+
+	return nil
+}
+
+// privateNetworks returns the Docker networks of the named private networks
+// of project.
+func privateNetworks(project string, networks []string) []string {
+	var names []string
+	for _, network := range networks {
+		names = append(names, docker.PrivateNetwork(project, network))
+	}
+	return names
 }
 
 func (d *Deployment) createVolumes(ctx context.Context, project string, volumes []string) error {
@@ -158,7 +158,7 @@ func (suite *DeploymentTestSuite) TestDeploy() {
 
 		// Initial deployment
 		spinner := pin.New("Deploying", pin.WithSpinnerColor(pin.ColorCyan))
-		err := suite.deployment.Deploy(ctx, project, cfg, spinner)
+		err := suite.deployment.Deploy(ctx, project, cfg, spinner, nil)
 		suite.Require().NoError(err, "Initial deployment should succeed")
 
 		time.Sleep(5 * time.Second)
@@ -206,7 +206,7 @@ func (suite *DeploymentTestSuite) TestDeploy() {
 		suite.T().Logf("Updating service image to nginx:1.20")
 
 		spinner = pin.New("Deploying", pin.WithSpinnerColor(pin.ColorCyan))
-		err = suite.deployment.Deploy(ctx, project, cfg, spinner)
+		err = suite.deployment.Deploy(ctx, project, cfg, spinner, nil)
 		suite.Require().NoError(err, "Service update should succeed")
 
 		time.Sleep(2 * time.Second)
@@ -0,0 +1,217 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/shell"
+	"github.com/yarlson/pin"
+)
+
+// certificatesVolume is the volume the proxy keeps the certificates of the
+// project in.
+const certificatesVolume = "certs"
+
+// TeardownOptions selects what Destroy leaves on the server.
+type TeardownOptions struct {
+	// KeepVolumes keeps the named volumes of the project, and the records of
+	// the init steps that ran on them.
+	KeepVolumes bool
+	// KeepCertificates keeps the certificates, so a new deploy does not have
+	// to request them again.
+	KeepCertificates bool
+}
+
+// Teardown lists what Destroy removes from the server.
+type Teardown struct {
+	Options    TeardownOptions
+	Containers []string
+	Networks   []string
+	Volumes    []string
+	// Certificates is the volume holding the certificates of the project, or
+	// empty when it is kept or does not exist.
+	Certificates string
+	// Folder is the project folder on the server, with the proxy
+	// configuration, uploads, configs and deploy history, or empty when it
+	// does not exist or holds nothing but the deploy lock.
+	Folder string
+	// Shared is set when the project is registered with the shared proxy.
+	Shared bool
+}
+
+// Empty reports whether there is nothing to remove.
+func (t *Teardown) Empty() bool {
+	return len(t.Containers) == 0 && len(t.Networks) == 0 && len(t.Volumes) == 0 &&
+		t.Certificates == "" && t.Folder == "" && !t.Shared
+}
+
+// PlanTeardown lists what Destroy removes of project: every container on its
+// networks, including those of services no longer in cfg, its networks, its
+// named volumes and certificates unless opts keeps them, its folder and its
+// domains on the shared proxy. Only what exists on the server is listed. It is
+// meant to run under the deploy lock, so no deploy changes the server between
+// the plan and Destroy.
+func (d *Deployment) PlanTeardown(ctx context.Context, project string, cfg *config.Config, opts TeardownOptions) (*Teardown, error) {
+	teardown := &Teardown{Options: opts}
+
+	existing, err := d.runCommand(ctx, "docker", "network", "ls", "--format", "{{.Name}}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %w", err)
+	}
+	for _, network := range append([]string{project}, privateNetworks(project, cfg.Networks)...) {
+		if slices.Contains(strings.Fields(existing), network) {
+			teardown.Networks = append(teardown.Networks, network)
+		}
+	}
+
+	for _, network := range teardown.Networks {
+		output, err := d.runCommand(ctx, "docker", "ps", "-a", "--filter", "network="+network, "--format", "{{.Names}}")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list containers on %s: %w", network, err)
+		}
+		for _, name := range strings.Fields(output) {
+			if !slices.Contains(teardown.Containers, name) {
+				teardown.Containers = append(teardown.Containers, name)
+			}
+		}
+	}
+	slices.Sort(teardown.Containers)
+
+	volumes, err := d.runCommand(ctx, "docker", "volume", "ls", "--format", "{{.Name}}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %w", err)
+	}
+	exists := func(volume string) bool {
+		return slices.Contains(strings.Fields(volumes), fmt.Sprintf("%s-%s", project, volume))
+	}
+	if !opts.KeepVolumes {
+		for _, volume := range append(slices.Clone(cfg.Volumes), "metrics") {
+			if volume != certificatesVolume && exists(volume) && !slices.Contains(teardown.Volumes, project+"-"+volume) {
+				teardown.Volumes = append(teardown.Volumes, project+"-"+volume)
+			}
+		}
+	}
+	if !opts.KeepCertificates && exists(certificatesVolume) {
+		teardown.Certificates = project + "-" + certificatesVolume
+	}
+
+	folder, err := d.projectFolder(project)
+	if err != nil {
+		return nil, err
+	}
+	found, err := d.runCommand(ctx, "sh", "-c", fmt.Sprintf("test -d %[1]s && ls -A %[1]s | grep -vqx deploy.lock && echo yes || true", shell.Quote(folder)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check project folder: %w", err)
+	}
+	if found == "yes" {
+		teardown.Folder = folder
+	}
+
+	dir, err := d.edgeDir(project)
+	if err != nil {
+		return nil, err
+	}
+	registered, err := d.edgeProjects(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	_, teardown.Shared = registered[project]
+
+	return teardown, nil
+}
+
+// Destroy removes what teardown lists of project from the server. The domains
+// of the project leave the shared proxy first, so it stops routing to the
+// containers before they are stopped and removed.
+func (d *Deployment) Destroy(ctx context.Context, project string, teardown *Teardown, spinner *pin.Pin) error {
+	d.spinner = spinner
+
+	if teardown.Shared {
+		if err := d.removeEdge(ctx, project); err != nil {
+			return err
+		}
+	}
+
+	if len(teardown.Containers) > 0 {
+		d.stage("Stopping containers...")
+		if output, err := d.runChecked(ctx, "docker", append([]string{"stop"}, teardown.Containers...)...); err != nil {
+			return teardownError(fmt.Errorf("failed to stop containers: %w", err), output)
+		}
+		d.stage("Removing containers...")
+		if output, err := d.runChecked(ctx, "docker", append([]string{"rm", "-f"}, teardown.Containers...)...); err != nil {
+			return teardownError(fmt.Errorf("failed to remove containers: %w", err), output)
+		}
+	}
+
+	if len(teardown.Networks) > 0 {
+		d.stage("Removing networks...")
+		if output, err := d.runChecked(ctx, "docker", append([]string{"network", "rm"}, teardown.Networks...)...); err != nil {
+			return teardownError(fmt.Errorf("failed to remove networks: %w", err), output)
+		}
+	}
+
+	volumes := slices.Clone(teardown.Volumes)
+	if teardown.Certificates != "" {
+		volumes = append(volumes, teardown.Certificates)
+	}
+	if len(volumes) > 0 {
+		d.stage("Removing volumes...")
+		if output, err := d.runChecked(ctx, "docker", append([]string{"volume", "rm"}, volumes...)...); err != nil {
+			return teardownError(fmt.Errorf("failed to remove volumes: %w", err), output)
+		}
+	}
+
+	if teardown.Folder != "" {
+		d.stage("Removing project folder...")
+		if err := d.removeProjectFolder(ctx, teardown.Folder, teardown.Options.KeepVolumes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReleaseTeardown releases the deploy lock taken to plan a teardown that is
+// not carried out, or that leaves the project folder. The folder is removed
+// again when the lock was all it held, as Lock creates it.
+func (d *Deployment) ReleaseTeardown(ctx context.Context, project string) error {
+	if err := d.Unlock(ctx, project); err != nil {
+		return err
+	}
+
+	folder, err := d.projectFolder(project)
+	if err != nil {
+		return err
+	}
+	if output, err := d.runChecked(ctx, "sh", "-c", fmt.Sprintf("if [ -z \"$(ls -A %[1]s)\" ]; then rmdir %[1]s; fi", shell.Quote(folder))); err != nil {
+		return outputError(fmt.Errorf("failed to remove project folder %s: %w", folder, err), output)
+	}
+	return nil
+}
+
+// removeProjectFolder removes folder, keeping the init records of the kept
+// volumes when keepInit is set. It runs as root in a container, as uploads
+// may be owned by another user than the deploy user.
+func (d *Deployment) removeProjectFolder(ctx context.Context, folder string, keepInit bool) error {
+	script := "rm -rf /projects/" + shell.Quote(path.Base(folder))
+	if keepInit {
+		script = fmt.Sprintf("find /projects/%s -mindepth 1 -maxdepth 1 ! -name init -exec rm -rf {} +", shell.Quote(path.Base(folder)))
+	}
+	if output, err := d.runChecked(ctx, "docker", "run", "--rm", "-v", path.Dir(folder)+":/projects", bindImage, "sh", "-c", script); err != nil {
+		return teardownError(fmt.Errorf("failed to remove project folder %s: %w", folder, err), output)
+	}
+	return nil
+}
+
+// teardownError adds the output of a failed teardown command, which names
+// what docker or rm could not remove, to err.
+func teardownError(err error, output string) error {
+	if output == "" {
+		return err
+	}
+	return fmt.Errorf("%w\n\x1b[93mLeft behind:\x1b[0m\n\x1b[90m%s\x1b[0m", err, output)
+}
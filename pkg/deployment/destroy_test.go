@@ -0,0 +1,110 @@
+package deployment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func teardownRunner() *fake.Runner {
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("docker network ls", fake.Response{Output: "bridge\nproject\nproject_backend\nother\n"})
+	runner.On("docker ps -a --filter network=project --format", fake.Response{Output: "project-web\nproject-postgres\nproject-proxy\n"})
+	runner.On("docker ps -a --filter network=project_backend --format", fake.Response{Output: "project-postgres\nproject-worker\n"})
+	runner.On("docker volume ls", fake.Response{Output: "project-pgdata\nproject-certs\nother-pgdata\n"})
+	runner.On("sh -c test -d", fake.Response{Output: "yes"})
+	runner.On("sh -c for f in", fake.Response{Output: "project example.com\nother other.com\n"})
+	return runner
+}
+
+func TestPlanTeardown(t *testing.T) {
+	cfg := &config.Config{Volumes: []string{"pgdata", "uploads"}, Networks: []string{"backend", "cache"}}
+
+	teardown, err := NewDeployment(teardownRunner(), nil).PlanTeardown(context.Background(), "project", cfg, TeardownOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"project-postgres", "project-proxy", "project-web", "project-worker"}, teardown.Containers)
+	assert.Equal(t, []string{"project", "project_backend"}, teardown.Networks)
+	assert.Equal(t, []string{"project-pgdata"}, teardown.Volumes)
+	assert.Equal(t, "project-certs", teardown.Certificates)
+	assert.Equal(t, "/home/deploy/projects/project", teardown.Folder)
+	assert.True(t, teardown.Shared)
+
+	teardown, err = NewDeployment(teardownRunner(), nil).PlanTeardown(context.Background(), "project", cfg, TeardownOptions{KeepVolumes: true, KeepCertificates: true})
+	require.NoError(t, err)
+	assert.Empty(t, teardown.Volumes)
+	assert.Empty(t, teardown.Certificates)
+	assert.False(t, teardown.Empty())
+
+	// A folder holding nothing but the deploy lock of the destroy is not listed.
+	runner := teardownRunner()
+	runner.On("sh -c test -d", fake.Response{})
+	teardown, err = NewDeployment(runner, nil).PlanTeardown(context.Background(), "project", cfg, TeardownOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, teardown.Folder)
+	assert.Contains(t, callLines(runner), "sh -c test -d '/home/deploy/projects/project' && ls -A '/home/deploy/projects/project' | grep -vqx deploy.lock && echo yes || true")
+}
+
+func TestReleaseTeardown(t *testing.T) {
+	runner := teardownRunner()
+	require.NoError(t, NewDeployment(runner, nil).ReleaseTeardown(context.Background(), "project"))
+
+	lines := callLines(runner)
+	assert.Contains(t, lines, "rm -rf /home/deploy/projects/project/deploy.lock")
+	assert.Equal(t, `sh -c if [ -z "$(ls -A '/home/deploy/projects/project')" ]; then rmdir '/home/deploy/projects/project'; fi`, lines[len(lines)-1])
+
+	runner = teardownRunner()
+	runner.On("sh -c if [ -z", fake.Response{Output: "rmdir: failed to remove '/home/deploy/projects/project': Permission denied", ExitCode: 1})
+	err := NewDeployment(runner, nil).ReleaseTeardown(context.Background(), "project")
+	assert.ErrorContains(t, err, "failed to remove project folder /home/deploy/projects/project")
+	assert.ErrorContains(t, err, "Permission denied")
+}
+
+func TestDestroy(t *testing.T) {
+	teardown := &Teardown{
+		Containers:   []string{"project-proxy", "project-web"},
+		Networks:     []string{"project"},
+		Volumes:      []string{"project-pgdata"},
+		Certificates: "project-certs",
+		Folder:       "/home/deploy/projects/project",
+		Shared:       true,
+	}
+
+	runner := teardownRunner()
+	runner.On("sh -c docker inspect --format='{{.State.Running}}'", fake.Response{Output: "true"})
+	require.NoError(t, NewDeployment(runner, nil).Destroy(context.Background(), "project", teardown, nil))
+
+	var lines []string
+	for _, call := range runner.Calls() {
+		lines = append(lines, call.String())
+	}
+	assert.Contains(t, lines, "rm -f /home/deploy/ftl-edge/projects/project.domains")
+	assert.Contains(t, lines, "docker exec ftl-edge nginx -s reload")
+	assert.Contains(t, lines, "docker stop project-proxy project-web")
+	assert.Contains(t, lines, "docker rm -f project-proxy project-web")
+	assert.Contains(t, lines, "docker network rm project")
+	assert.Contains(t, lines, "docker volume rm project-pgdata project-certs")
+	assert.Equal(t, "docker run --rm -v /home/deploy/projects:/projects alpine:3 sh -c rm -rf /projects/'project'", lines[len(lines)-1])
+
+	runner = teardownRunner()
+	teardown = &Teardown{Options: TeardownOptions{KeepVolumes: true}, Folder: "/home/deploy/projects/project"}
+	require.NoError(t, NewDeployment(runner, nil).Destroy(context.Background(), "project", teardown, nil))
+	require.Len(t, runner.Calls(), 1)
+	assert.Contains(t, runner.Calls()[0].String(), "! -name init -exec rm -rf {} +")
+
+	// A volume that is still in use fails the teardown and is reported.
+	runner = teardownRunner()
+	runner.On("docker volume rm", fake.Response{Output: "project-certs\nError response from daemon: remove project-pgdata: volume is in use - [0123abcd]", ExitCode: 1})
+	teardown = &Teardown{Volumes: []string{"project-pgdata"}, Certificates: "project-certs", Folder: "/home/deploy/projects/project"}
+	err := NewDeployment(runner, nil).Destroy(context.Background(), "project", teardown, nil)
+	assert.ErrorContains(t, err, "failed to remove volumes: command failed: exit status 1")
+	assert.ErrorContains(t, err, "remove project-pgdata: volume is in use")
+	for _, call := range runner.Calls() {
+		assert.NotContains(t, call.String(), "rm -rf /projects/", "the folder is kept when volumes are left behind")
+	}
+}
@@ -0,0 +1,202 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/docker"
+	"github.com/yarlson/ftl/pkg/proxy"
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+// Drift is a difference between the server state and the configuration.
+type Drift struct {
+	Resource string
+	Message  string
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%s: %s", d.Resource, d.Message)
+}
+
+// Diff compares the containers, proxy configuration and project network on the
+// server with cfg and reports every difference, including changes made on the
+// server by hand.
+func (d *Deployment) Diff(ctx context.Context, project string, cfg *config.Config) ([]Drift, error) {
+	var drifts []Drift
+
+	expected := map[string]struct{}{}
+	for _, name := range systemServices {
+		expected[containerName(project, name, "")] = struct{}{}
+	}
+
+	for i := range cfg.Services {
+		service := &cfg.Services[i]
+		if service.Static != nil {
+			continue
+		}
+		expected[containerName(project, service.Name, "")] = struct{}{}
+
+		serviceDrifts, err := d.diffService(project, "service "+service.Name, service)
+		if err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, serviceDrifts...)
+
+		for _, replica := range replicas(service) {
+			expected[containerName(project, replica.Name, "")] = struct{}{}
+
+			replicaDrifts, err := d.diffService(project, "service "+replica.Name, replica)
+			if err != nil {
+				return nil, err
+			}
+			drifts = append(drifts, replicaDrifts...)
+		}
+	}
+
+	for i := range cfg.Dependencies {
+		dependency := &cfg.Dependencies[i]
+		expected[containerName(project, dependency.Name, "")] = struct{}{}
+
+		service, err := d.dependencyService(project, dependency)
+		if err != nil {
+			return nil, err
+		}
+		dependencyDrifts, err := d.diffService(project, "dependency "+dependency.Name, service)
+		if err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, dependencyDrifts...)
+	}
+
+	proxyDrift, err := d.diffProxyConfig(ctx, project, cfg)
+	if err != nil {
+		return nil, err
+	}
+	drifts = append(drifts, proxyDrift...)
+
+	output, err := d.runCommand(ctx, "docker", "ps", "-a", "--filter", fmt.Sprintf("network=%s", project), "--format", "{{.Names}}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list project containers: %w", err)
+	}
+	for _, name := range strings.Fields(output) {
+		if _, ok := expected[name]; !ok {
+			drifts = append(drifts, Drift{Resource: "container " + name, Message: "running on the project network but not defined in the configuration"})
+		}
+	}
+
+	return drifts, nil
+}
+
+// diffService compares a single container with the service it was deployed from.
+func (d *Deployment) diffService(project, resource string, service *config.Service) ([]Drift, error) {
+	status, err := d.dockerManager.GetContainerStatus(docker.ServiceNetwork(project, service), service.Name)
+	if err != nil {
+		return nil, err
+	}
+	if status == docker.ContainerStatusNotFound {
+		return []Drift{{Resource: resource, Message: "not deployed"}}, nil
+	}
+
+	details, err := d.dockerManager.InspectContainer(docker.ServiceNetwork(project, service), service.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var drifts []Drift
+	if status == docker.ContainerStatusStopped {
+		drifts = append(drifts, Drift{Resource: resource, Message: fmt.Sprintf("container is %s", details.State.Status)})
+	}
+
+	if err := digestUploads(service); err != nil {
+		return nil, err
+	}
+	hash, err := service.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate config hash: %w", err)
+	}
+	if details.Config.Labels["ftl.config-hash"] != hash {
+		drifts = append(drifts, Drift{Resource: resource, Message: "configuration changed since the last deploy"})
+	}
+
+	if service.Image != "" {
+		imageID, err := d.dockerManager.ImageID(service.Image)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get image ID for %s: %w", service.Image, err)
+		}
+		if imageID != "" && details.Image != imageID {
+			drifts = append(drifts, Drift{Resource: resource, Message: fmt.Sprintf("container does not run the current %s image", service.Image)})
+		}
+	}
+
+	actualEnv := map[string]string{}
+	for _, env := range details.Config.Env {
+		key, value, _ := strings.Cut(env, "=")
+		actualEnv[key] = value
+	}
+	for _, env := range service.Env {
+		key, value, _ := strings.Cut(env, "=")
+		actual, ok := actualEnv[key]
+		if !ok {
+			drifts = append(drifts, Drift{Resource: resource, Message: fmt.Sprintf("env %s is missing", key)})
+		} else if actual != value {
+			drifts = append(drifts, Drift{Resource: resource, Message: fmt.Sprintf("env %s has a different value", key)})
+		}
+	}
+
+	expectedBinds := map[string]struct{}{}
+	for _, vol := range service.Volumes {
+		expectedBinds[docker.VolumeBind(project, vol)] = struct{}{}
+	}
+	actualBinds := map[string]struct{}{}
+	for _, bind := range details.HostConfig.Binds {
+		actualBinds[bind] = struct{}{}
+		if _, ok := expectedBinds[bind]; !ok {
+			drifts = append(drifts, Drift{Resource: resource, Message: fmt.Sprintf("unexpected volume %s", bind)})
+		}
+	}
+	var missing []string
+	for bind := range expectedBinds {
+		if _, ok := actualBinds[bind]; !ok {
+			missing = append(missing, bind)
+		}
+	}
+	sort.Strings(missing)
+	for _, bind := range missing {
+		drifts = append(drifts, Drift{Resource: resource, Message: fmt.Sprintf("volume %s is not mounted", bind)})
+	}
+
+	return drifts, nil
+}
+
+// diffProxyConfig compares the nginx configuration on the server with the one
+// that would be generated from cfg.
+func (d *Deployment) diffProxyConfig(ctx context.Context, project string, cfg *config.Config) ([]Drift, error) {
+	generated, err := proxy.GenerateNginxConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nginx config: %w", err)
+	}
+
+	projectPath, err := d.projectFolder(project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project folder path: %w", err)
+	}
+
+	live, err := d.runCommand(ctx, "sh", "-c", fmt.Sprintf("cat %s 2>/dev/null || true", shell.Quote(filepath.Join(projectPath, "nginx", "default.conf"))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read proxy configuration: %w", err)
+	}
+
+	if live == "" {
+		return []Drift{{Resource: "proxy", Message: "configuration not deployed"}}, nil
+	}
+	if live != strings.TrimSpace(generated) {
+		return []Drift{{Resource: "proxy", Message: "configuration differs from ftl.yaml"}}, nil
+	}
+
+	return nil, nil
+}
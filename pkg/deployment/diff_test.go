@@ -0,0 +1,105 @@
+package deployment
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/proxy"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func newDiffConfig() *config.Config {
+	return &config.Config{
+		Project: config.Project{Name: "shop", Domain: "shop.example.com", Email: "ops@example.com"},
+		Services: []config.Service{{
+			Name:   "web",
+			Image:  "web:latest",
+			Port:   80,
+			Routes: []config.Route{{PathPrefix: "/"}},
+		}},
+	}
+}
+
+// newDiffRunner scripts a server on which cfg is deployed as is: the web
+// container carries the current config hash and image, and the live proxy
+// configuration is the generated one.
+func newDiffRunner(t *testing.T, cfg *config.Config) *fake.Runner {
+	t.Helper()
+
+	hash, err := cfg.Services[0].Hash()
+	require.NoError(t, err)
+	generated, err := proxy.GenerateNginxConfig(cfg)
+	require.NoError(t, err)
+
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("docker ps -aq --filter network=shop", fake.Response{Output: "abc123"})
+	runner.On("docker inspect abc123", fake.Response{Output: `[{"Id":"abc123","Image":"sha256:web","State":{"Status":"running"},"Config":{"Labels":{"ftl.config-hash":"` + hash + `"}},"NetworkSettings":{"Networks":{"shop":{"Aliases":["web"]}}}}]`})
+	runner.On("docker inspect --format={{.Id}} web:latest", fake.Response{Output: "sha256:web"})
+	runner.On("sh -c cat '/home/deploy/projects/shop/nginx/default.conf'", fake.Response{Output: strings.TrimSpace(generated)})
+	runner.On("docker ps -a --filter network=shop --format {{.Names}}", fake.Response{Output: "shop-proxy\nshop-web"})
+	return runner
+}
+
+func TestDiff_Unchanged(t *testing.T) {
+	cfg := newDiffConfig()
+	runner := newDiffRunner(t, cfg)
+
+	drifts, err := NewDeployment(runner, nil).Diff(context.Background(), "shop", cfg)
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+}
+
+func TestDiff_ConfigChanged(t *testing.T) {
+	cfg := newDiffConfig()
+	runner := newDiffRunner(t, cfg)
+	cfg.Services[0].Env = []string{"MODE=prod"}
+
+	drifts, err := NewDeployment(runner, nil).Diff(context.Background(), "shop", cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []Drift{
+		{Resource: "service web", Message: "configuration changed since the last deploy"},
+		{Resource: "service web", Message: "env MODE is missing"},
+	}, drifts)
+}
+
+func TestDiff_MissingContainer(t *testing.T) {
+	cfg := newDiffConfig()
+	runner := newDiffRunner(t, cfg)
+	runner.On("docker ps -aq --filter network=shop", fake.Response{})
+	runner.On("docker ps -a --filter network=shop --format {{.Names}}", fake.Response{Output: "shop-proxy"})
+
+	drifts, err := NewDeployment(runner, nil).Diff(context.Background(), "shop", cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []Drift{{Resource: "service web", Message: "not deployed"}}, drifts)
+}
+
+func TestDiff_ServiceRemoved(t *testing.T) {
+	cfg := newDiffConfig()
+	runner := newDiffRunner(t, cfg)
+	runner.On("docker ps -a --filter network=shop --format {{.Names}}", fake.Response{Output: "shop-proxy\nshop-web\nshop-worker"})
+
+	drifts, err := NewDeployment(runner, nil).Diff(context.Background(), "shop", cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []Drift{{Resource: "container shop-worker", Message: "running on the project network but not defined in the configuration"}}, drifts)
+}
+
+func TestDiff_ProxyConfig(t *testing.T) {
+	cfg := newDiffConfig()
+	runner := newDiffRunner(t, cfg)
+	runner.On("sh -c cat '/home/deploy/projects/shop/nginx/default.conf'", fake.Response{Output: "server { listen 80; }"})
+
+	drifts, err := NewDeployment(runner, nil).Diff(context.Background(), "shop", cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []Drift{{Resource: "proxy", Message: "configuration differs from ftl.yaml"}}, drifts)
+
+	runner.On("sh -c cat '/home/deploy/projects/shop/nginx/default.conf'", fake.Response{})
+	drifts, err = NewDeployment(runner, nil).Diff(context.Background(), "shop", cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []Drift{{Resource: "proxy", Message: "configuration not deployed"}}, drifts)
+}
@@ -0,0 +1,226 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/proxy"
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+// edgeFolder holds the configuration of the shared proxy in the home
+// directory of the deploy user, with the domains of every project in
+// projects/<project>.domains.
+const edgeFolder = "ftl-edge"
+
+// edgeImage runs the shared proxy.
+const edgeImage = "nginx:alpine"
+
+// shared reports whether cfg deploys next to other projects.
+func shared(cfg *config.Config) bool {
+	return cfg.Server != nil && cfg.Server.Shared
+}
+
+// deployEdge registers the domains of the project with the shared proxy,
+// starting it when it does not run yet, and connects the project proxy to
+// it. A domain another project registered is refused.
+func (d *Deployment) deployEdge(ctx context.Context, project string, cfg *config.Config) error {
+	dir, err := d.edgeDir(project)
+	if err != nil {
+		return err
+	}
+
+	registered, err := d.edgeProjects(ctx, dir)
+	if err != nil {
+		return err
+	}
+	domains := cfg.Domains()
+	for other, otherDomains := range registered {
+		if other == project {
+			continue
+		}
+		for _, domain := range domains {
+			if slices.Contains(otherDomains, domain) {
+				return fmt.Errorf("domain %s is already served by project %s on this server", domain, other)
+			}
+		}
+	}
+	registered[project] = domains
+
+	d.progress("Updating shared proxy...")
+	if output, err := d.runChecked(ctx, "mkdir", "-p", filepath.Join(dir, "projects"), filepath.Join(dir, "conf")); err != nil {
+		return proxyError(fmt.Errorf("failed to create %s: %w", dir, err), "Output from mkdir", output)
+	}
+	if err := d.writeRemoteFile(ctx, filepath.Join(dir, "projects", project+".domains"), strings.Join(domains, "\n")+"\n"); err != nil {
+		return err
+	}
+
+	if err := d.ensureNetwork(proxy.EdgeNetwork, cfg); err != nil {
+		return fmt.Errorf("failed to create network %s: %w", proxy.EdgeNetwork, err)
+	}
+
+	if err := d.writeEdgeConfig(ctx, dir, registered); err != nil {
+		return err
+	}
+	projectProxy := containerName(project, "proxy", "")
+	connected, err := d.runCommand(ctx, "docker", "inspect", fmt.Sprintf(`--format={{if index .NetworkSettings.Networks %q}}yes{{end}}`, proxy.EdgeNetwork), projectProxy)
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", projectProxy, err)
+	}
+	if connected != "yes" {
+		if output, err := d.runChecked(ctx, "docker", "network", "connect", proxy.EdgeNetwork, projectProxy); err != nil {
+			return proxyError(fmt.Errorf("failed to connect %s to the shared proxy: %w", projectProxy, err), "Output from docker network connect", output)
+		}
+	}
+
+	running, err := d.runCommand(ctx, "sh", "-c", "docker inspect --format='{{.State.Running}}' "+proxy.EdgeContainer+" 2>/dev/null || true")
+	if err != nil {
+		return fmt.Errorf("failed to inspect shared proxy: %w", err)
+	}
+	if running == "true" {
+		return d.reloadEdge(ctx)
+	}
+
+	if _, err := d.runCommand(ctx, "sh", "-c", "docker rm -f "+proxy.EdgeContainer+" >/dev/null 2>&1 || true"); err != nil {
+		return fmt.Errorf("failed to remove shared proxy: %w", err)
+	}
+	if output, err := d.runChecked(ctx, "docker", "run", "--detach",
+		"--name", proxy.EdgeContainer,
+		"--network", proxy.EdgeNetwork,
+		"--restart", "unless-stopped",
+		"--publish", "80:80",
+		"--publish", "443:443",
+		"--volume", filepath.Join(dir, "conf")+":/etc/nginx/conf.d:ro",
+		edgeImage,
+		"nginx", "-g", "daemon off; include /etc/nginx/conf.d/"+proxy.EdgeStreamConfig+";",
+	); err != nil {
+		return proxyError(fmt.Errorf("failed to start shared proxy (another proxy may still publish ports 80 and 443; set server.shared on every project of the server): %w", err), "Output from docker run", output)
+	}
+	return nil
+}
+
+// removeEdge unregisters the domains of the project from the shared proxy.
+// The shared proxy is removed along with the last project it serves.
+func (d *Deployment) removeEdge(ctx context.Context, project string) error {
+	dir, err := d.edgeDir(project)
+	if err != nil {
+		return err
+	}
+
+	registered, err := d.edgeProjects(ctx, dir)
+	if err != nil {
+		return err
+	}
+	if _, ok := registered[project]; !ok {
+		return nil
+	}
+	delete(registered, project)
+
+	d.progress("Updating shared proxy...")
+	if output, err := d.runChecked(ctx, "rm", "-f", filepath.Join(dir, "projects", project+".domains")); err != nil {
+		return proxyError(fmt.Errorf("failed to unregister %s from the shared proxy: %w", project, err), "Output from rm", output)
+	}
+	if len(registered) == 0 {
+		if _, err := d.runCommand(ctx, "sh", "-c", "docker rm -f "+proxy.EdgeContainer+" >/dev/null 2>&1 || true"); err != nil {
+			return fmt.Errorf("failed to remove shared proxy: %w", err)
+		}
+		return nil
+	}
+
+	if err := d.writeEdgeConfig(ctx, dir, registered); err != nil {
+		return err
+	}
+	running, err := d.runCommand(ctx, "sh", "-c", "docker inspect --format='{{.State.Running}}' "+proxy.EdgeContainer+" 2>/dev/null || true")
+	if err != nil {
+		return fmt.Errorf("failed to inspect shared proxy: %w", err)
+	}
+	if running != "true" {
+		return nil
+	}
+	return d.reloadEdge(ctx)
+}
+
+// edgeDir returns the folder of the shared proxy, next to the projects
+// folder.
+func (d *Deployment) edgeDir(project string) (string, error) {
+	projectPath, err := d.projectFolder(project)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(filepath.Dir(projectPath)), edgeFolder), nil
+}
+
+// writeEdgeConfig writes the configuration of the shared proxy routing the
+// domains of every registered project to its proxy. It listens on IPv6 when
+// its network has IPv6 enabled.
+func (d *Deployment) writeEdgeConfig(ctx context.Context, dir string, registered map[string][]string) error {
+	upstreams := make(map[string][]string, len(registered))
+	for name, projectDomains := range registered {
+		upstreams[containerName(name, "proxy", "")] = projectDomains
+	}
+	ipv6, err := d.dockerManager.NetworkIPv6(proxy.EdgeNetwork)
+	if err != nil {
+		return err
+	}
+	httpConfig, streamConfig := proxy.GenerateEdgeConfig(upstreams, ipv6)
+	if err := d.writeRemoteFile(ctx, filepath.Join(dir, "conf", "default.conf"), httpConfig); err != nil {
+		return err
+	}
+	return d.writeRemoteFile(ctx, filepath.Join(dir, "conf", proxy.EdgeStreamConfig), streamConfig)
+}
+
+// reloadEdge reloads the shared proxy once it accepts its new configuration,
+// so a rejected configuration fails the deploy instead of being ignored.
+func (d *Deployment) reloadEdge(ctx context.Context) error {
+	if output, err := d.runChecked(ctx, "docker", "exec", proxy.EdgeContainer, "nginx", "-t"); err != nil {
+		return proxyError(fmt.Errorf("shared proxy rejected its configuration: %w", err), "Output from nginx -t", output)
+	}
+	if output, err := d.runChecked(ctx, "docker", "exec", proxy.EdgeContainer, "nginx", "-s", "reload"); err != nil {
+		return proxyError(fmt.Errorf("failed to reload shared proxy: %w", err), "Output from nginx -s reload", output)
+	}
+	return nil
+}
+
+// edgeProjects returns the domains registered with the shared proxy by
+// every project.
+func (d *Deployment) edgeProjects(ctx context.Context, dir string) (map[string][]string, error) {
+	script := fmt.Sprintf(`for f in %s/*.domains; do [ -e "$f" ] && printf '%%s %%s\n' "$(basename "$f" .domains)" "$(tr '\n' ' ' < "$f")"; done; true`,
+		shell.Quote(filepath.Join(dir, "projects")))
+	output, err := d.runCommand(ctx, "sh", "-c", script)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list projects of the shared proxy: %w", err)
+	}
+
+	projects := map[string][]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 {
+			projects[fields[0]] = fields[1:]
+		}
+	}
+	return projects, nil
+}
+
+// writeRemoteFile writes content to path on the server.
+func (d *Deployment) writeRemoteFile(ctx context.Context, path, content string) error {
+	tmpFile, err := os.CreateTemp("", "ftl-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.WriteString(content)
+	_ = tmpFile.Close()
+	if err == nil {
+		err = d.runner.CopyFile(ctx, tmpFile.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package deployment
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestDeployEdge(t *testing.T) {
+	cfg := &config.Config{
+		Project: config.Project{Name: "shop", Domain: "shop.example.com"},
+		Server:  &config.Server{Host: "example.com", Shared: true},
+	}
+
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("sh -c for f in", fake.Response{Output: "blog blog.example.com www.blog.example.com"})
+	runner.On("docker network inspect", fake.Response{Output: "[]"})
+	require.NoError(t, NewDeployment(runner, nil).deployEdge(context.Background(), "shop", cfg))
+
+	domains, ok := runner.File("/home/deploy/ftl-edge/projects/shop.domains")
+	require.True(t, ok)
+	assert.Equal(t, "shop.example.com\n", string(domains))
+
+	httpConfig, ok := runner.File("/home/deploy/ftl-edge/conf/default.conf")
+	require.True(t, ok)
+	assert.Contains(t, string(httpConfig), "blog.example.com blog-proxy;")
+	assert.Contains(t, string(httpConfig), "shop.example.com shop-proxy;")
+
+	var lines []string
+	for _, call := range runner.Calls() {
+		lines = append(lines, call.String())
+	}
+	assert.Contains(t, lines, "docker network connect ftl-edge shop-proxy")
+	assert.Contains(t, strings.Join(lines, "\n"), "docker run --detach --name ftl-edge --network ftl-edge --restart unless-stopped --publish 80:80 --publish 443:443")
+
+	// A running shared proxy is reloaded.
+	runner.Reset()
+	runner.On("sh -c docker inspect --format='{{.State.Running}}' ftl-edge", fake.Response{Output: "true"})
+	require.NoError(t, NewDeployment(runner, nil).deployEdge(context.Background(), "shop", cfg))
+	calls := runner.Calls()
+	require.GreaterOrEqual(t, len(calls), 2)
+	assert.Equal(t, "docker exec ftl-edge nginx -t", calls[len(calls)-2].String())
+	assert.Equal(t, "docker exec ftl-edge nginx -s reload", calls[len(calls)-1].String())
+
+	// A rejected configuration is not reloaded.
+	runner.Reset()
+	runner.On("sh -c docker inspect --format='{{.State.Running}}' ftl-edge", fake.Response{Output: "true"})
+	runner.On("docker exec ftl-edge nginx -t", fake.Response{Output: "nginx: [emerg] unknown directive", ExitCode: 1})
+	err := NewDeployment(runner, nil).deployEdge(context.Background(), "shop", cfg)
+	assert.ErrorContains(t, err, "shared proxy rejected its configuration")
+	assert.ErrorContains(t, err, "unknown directive")
+	for _, call := range runner.Calls() {
+		assert.NotEqual(t, "docker exec ftl-edge nginx -s reload", call.String())
+	}
+}
+
+func TestDeployEdge_DomainTaken(t *testing.T) {
+	cfg := &config.Config{
+		Project: config.Project{Name: "shop", Domain: "blog.example.com"},
+		Server:  &config.Server{Host: "example.com", Shared: true},
+	}
+
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("sh -c for f in", fake.Response{Output: "blog blog.example.com"})
+	err := NewDeployment(runner, nil).deployEdge(context.Background(), "shop", cfg)
+	assert.EqualError(t, err, "domain blog.example.com is already served by project blog on this server")
+}
+
+func TestDeployEdge_StartFailure(t *testing.T) {
+	cfg := &config.Config{
+		Project: config.Project{Name: "shop", Domain: "shop.example.com"},
+		Server:  &config.Server{Host: "example.com", Shared: true},
+	}
+
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("docker network inspect", fake.Response{Output: "[]"})
+	runner.On("docker run --detach --name ftl-edge", fake.Response{
+		Output:   "Bind for 0.0.0.0:80 failed: port is already allocated",
+		ExitCode: 125,
+	})
+	err := NewDeployment(runner, nil).deployEdge(context.Background(), "shop", cfg)
+	assert.ErrorContains(t, err, "another proxy may still publish ports 80 and 443")
+	assert.ErrorContains(t, err, "port is already allocated")
+
+	runner.Reset()
+	runner.On("docker network connect", fake.Response{Output: "Error response from daemon: network ftl-edge not found", ExitCode: 1})
+	err = NewDeployment(runner, nil).deployEdge(context.Background(), "shop", cfg)
+	assert.ErrorContains(t, err, "failed to connect shop-proxy to the shared proxy")
+	assert.ErrorContains(t, err, "network ftl-edge not found")
+}
+
+func TestRemoveEdge_UnregisterFailure(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("sh -c for f in", fake.Response{Output: "shop shop.example.com"})
+	runner.On("rm -f /home/deploy/ftl-edge/projects/shop.domains", fake.Response{Output: "rm: cannot remove: Permission denied", ExitCode: 1})
+
+	err := NewDeployment(runner, nil).removeEdge(context.Background(), "shop")
+	assert.ErrorContains(t, err, "failed to unregister shop from the shared proxy")
+	assert.ErrorContains(t, err, "Permission denied")
+}
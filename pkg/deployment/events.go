@@ -0,0 +1,112 @@
+package deployment
+
+import (
+	"context"
+	"time"
+
+	"github.com/yarlson/ftl/pkg/config"
+)
+
+// EventType identifies what an Event reports.
+type EventType string
+
+const (
+	// EventStage starts a step of the deployment, such as deploying the
+	// dependencies or starting the proxy.
+	EventStage EventType = "stage"
+	// EventProgress is a status update within the current step.
+	EventProgress EventType = "progress"
+	// EventServiceStarted and EventServiceDeployed bracket the deployment of
+	// a service; EventServiceFailed replaces the latter when it fails.
+	EventServiceStarted  EventType = "service_started"
+	EventServiceDeployed EventType = "service_deployed"
+	EventServiceFailed   EventType = "service_failed"
+	// EventDependencyDeployed and EventDependencyFailed report the outcome of
+	// a dependency.
+	EventDependencyDeployed EventType = "dependency_deployed"
+	EventDependencyFailed   EventType = "dependency_failed"
+	// EventWarning reports a problem that does not fail the deployment, such
+	// as a domain that does not point at the server yet.
+	EventWarning EventType = "warning"
+	// EventCompleted and EventFailed end the deployment.
+	EventCompleted EventType = "completed"
+	EventFailed    EventType = "failed"
+)
+
+// Event is a step in the progress of a deployment.
+type Event struct {
+	Type EventType
+	Time time.Time
+	// Service is the service or dependency the event is about, if any.
+	Service string
+	Message string
+	// Err is set on the failure events.
+	Err error
+}
+
+// Deployer deploys projects for programs that embed ftl and render progress
+// themselves. The ftl command line drives Deployment with a spinner instead.
+type Deployer struct {
+	deployment *Deployment
+}
+
+// NewDeployer returns a Deployer that runs commands on the server with runner
+// and transfers images with syncer.
+func NewDeployer(runner Runner, syncer ImageSyncer) *Deployer {
+	return &Deployer{deployment: NewDeployment(runner, syncer)}
+}
+
+// Deploy deploys every service and dependency of cfg. Progress is sent to
+// events, which Deploy closes when it returns; the caller must keep receiving
+// until then. events may be nil to discard progress.
+func (d *Deployer) Deploy(ctx context.Context, cfg *config.Config, events chan<- Event) error {
+	d.deployment.SetEvents(events)
+	defer func() {
+		d.deployment.SetEvents(nil)
+		if events != nil {
+			close(events)
+		}
+	}()
+
+	err := d.deployment.Deploy(ctx, cfg.Project.Name, cfg, nil, nil)
+	if err != nil {
+		d.deployment.emit(Event{Type: EventFailed, Message: err.Error(), Err: err})
+		return err
+	}
+	d.deployment.emit(Event{Type: EventCompleted, Message: "Deployment completed"})
+	return nil
+}
+
+// Lock takes the deploy lock of project for owner, see Deployment.Lock.
+func (d *Deployer) Lock(ctx context.Context, project, owner string, force bool) error {
+	return d.deployment.Lock(ctx, project, owner, force)
+}
+
+// Unlock releases the deploy lock of project.
+func (d *Deployer) Unlock(ctx context.Context, project string) error {
+	return d.deployment.Unlock(ctx, project)
+}
+
+// SetEvents makes the deployment send its progress to events, which the
+// caller must keep receiving from. nil discards progress.
+func (d *Deployment) SetEvents(events chan<- Event) {
+	d.events = events
+}
+
+// emit sends event to the events channel, if any.
+func (d *Deployment) emit(event Event) {
+	if d.events == nil {
+		return
+	}
+	event.Time = time.Now()
+	d.events <- event
+}
+
+// stage reports the start of a deployment step.
+func (d *Deployment) stage(message string) {
+	d.startStageSpan(message)
+	if d.spinner != nil {
+		d.spinner.UpdateMessage(message)
+	}
+	d.emit(Event{Type: EventStage, Message: message})
+}
@@ -0,0 +1,72 @@
+package deployment
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestDeployerEvents(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker network inspect", fake.Response{Output: "[]"})
+	runner.On("docker run", fake.Response{Err: errors.New("port is already allocated")})
+	cfg := &config.Config{
+		Project:      config.Project{Name: "project", Domain: "example.com", Email: "admin@example.com"},
+		Server:       &config.Server{Host: "example.com"},
+		Dependencies: []config.Dependency{{Name: "redis", Image: "redis:7"}},
+	}
+
+	events := make(chan Event)
+	var received []Event
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range events {
+			received = append(received, event)
+		}
+	}()
+
+	err := NewDeployer(runner, nil).Deploy(context.Background(), cfg, events)
+	<-done
+	require.Error(t, err)
+
+	var types []EventType
+	for _, event := range received {
+		assert.False(t, event.Time.IsZero())
+		if event.Type != EventProgress {
+			types = append(types, event.Type)
+		}
+	}
+	assert.Equal(t, []EventType{EventStage, EventStage, EventStage, EventStage, EventDependencyFailed, EventFailed}, types)
+
+	last := received[len(received)-1]
+	assert.Equal(t, err, last.Err)
+	failed := received[len(received)-2]
+	assert.Equal(t, "redis", failed.Service)
+	assert.ErrorContains(t, failed.Err, "port is already allocated")
+}
+
+func TestDeployerEvents_Completed(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker network inspect", fake.Response{Output: "[]"})
+	runner.On("docker inspect --format={{.State.Status}}", fake.Response{Output: "running"})
+	cfg := &config.Config{
+		Project: config.Project{Name: "project", Domain: "example.com", Email: "admin@example.com"},
+		Server:  &config.Server{Host: "example.com"},
+	}
+
+	events := make(chan Event, 100)
+	require.NoError(t, NewDeployer(runner, nil).Deploy(context.Background(), cfg, events))
+
+	var last Event
+	for event := range events {
+		last = event
+	}
+	assert.Equal(t, EventCompleted, last.Type)
+}
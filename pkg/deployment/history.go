@@ -0,0 +1,148 @@
+package deployment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+// historyFile is the append-only deploy audit log in the project folder on
+// the server, one JSON object per line.
+const historyFile = "history.jsonl"
+
+// HistoryEntry records one deploy in the audit log.
+type HistoryEntry struct {
+	Time       time.Time         `json:"time"`
+	User       string            `json:"user"`
+	Commit     string            `json:"commit,omitempty"`
+	Dirty      bool              `json:"dirty,omitempty"`
+	Services   []string          `json:"services"`
+	Images     map[string]string `json:"images,omitempty"`
+	ConfigHash string            `json:"config_hash"`
+	Result     string            `json:"result"`
+	Error      string            `json:"error,omitempty"`
+	Duration   float64           `json:"duration_seconds"`
+	// Phases holds the seconds spent in each phase of the deploy, keyed by
+	// phase.
+	Phases map[string]float64 `json:"phases,omitempty"`
+}
+
+// Result classifies the outcome of Deploy as one of the deploy events
+// succeeded, failed or rolled_back.
+func Result(err error) string {
+	switch {
+	case err == nil:
+		return config.EventDeploySucceeded
+	case errors.Is(err, ErrRolledBack):
+		return config.EventDeployRolledBack
+	}
+	return config.EventDeployFailed
+}
+
+// RecordHistory appends entry to the audit log of the project on the server.
+// The configuration hash and the images of the deployed services are filled in
+// from cfg.
+func (d *Deployment) RecordHistory(ctx context.Context, project string, cfg *config.Config, entry HistoryEntry) error {
+	hash, err := cfg.Hash()
+	if err != nil {
+		return fmt.Errorf("failed to generate config hash: %w", err)
+	}
+	entry.ConfigHash = hash
+	entry.Images = d.serviceImages(ctx, project, cfg, entry.Services)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode history entry: %w", err)
+	}
+
+	path, err := d.historyPath(project)
+	if err != nil {
+		return err
+	}
+
+	if output, err := d.runChecked(ctx, "sh", "-c", fmt.Sprintf("printf '%%s\\n' %s >> %s", shell.Quote(string(line)), shell.Quote(path))); err != nil {
+		return outputError(fmt.Errorf("failed to record deploy history: %w", err), output)
+	}
+
+	return nil
+}
+
+// History returns the last limit entries of the audit log, newest first. A
+// limit of zero returns the whole log.
+func (d *Deployment) History(ctx context.Context, project string, limit int) ([]HistoryEntry, error) {
+	path, err := d.historyPath(project)
+	if err != nil {
+		return nil, err
+	}
+
+	script := fmt.Sprintf("cat %s 2>/dev/null || true", shell.Quote(path))
+	if limit > 0 {
+		script = fmt.Sprintf("tail -n %d %s 2>/dev/null || true", limit, shell.Quote(path))
+	}
+
+	output, err := d.runCommand(ctx, "sh", "-c", script)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deploy history: %w", err)
+	}
+
+	return parseHistory(output)
+}
+
+func parseHistory(output string) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	for i, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse deploy history line %d: %w", i+1, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	return entries, nil
+}
+
+// serviceImages returns the digest, or the image ID for images that were not
+// pulled from a registry, of every named service that runs a container.
+func (d *Deployment) serviceImages(ctx context.Context, project string, cfg *config.Config, services []string) map[string]string {
+	images := map[string]string{}
+	for _, service := range filterServices(cfg.Services, services) {
+		if service.Static != nil {
+			continue
+		}
+		image := service.Image
+		if image == "" {
+			image = fmt.Sprintf("%s-%s", project, service.Name)
+		}
+
+		digest, err := d.runCommand(ctx, "docker", "image", "inspect", "-f", "{{if .RepoDigests}}{{index .RepoDigests 0}}{{else}}{{.Id}}{{end}}", image)
+		if err != nil || !strings.Contains(digest, "sha256:") {
+			continue
+		}
+		images[service.Name] = digest
+	}
+
+	return images
+}
+
+func (d *Deployment) historyPath(project string) (string, error) {
+	projectPath, err := d.prepareProjectFolder(project)
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare project folder: %w", err)
+	}
+
+	return filepath.Join(projectPath, historyFile), nil
+}
@@ -0,0 +1,51 @@
+package deployment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestParseHistory(t *testing.T) {
+	output := `{"time":"2026-10-06T09:12:00Z","user":"ana@laptop","commit":"a1b2c3d","services":["web"],"config_hash":"abc","result":"succeeded","duration_seconds":42}
+
+{"time":"2026-10-13T14:30:00Z","user":"ben@ci","services":["web","worker"],"config_hash":"def","result":"failed","error":"boom","duration_seconds":7.5,"phases":{"pull":2.5,"health_check":4}}`
+
+	entries, err := parseHistory(output)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "ben@ci", entries[0].User)
+	assert.Equal(t, "boom", entries[0].Error)
+	assert.Equal(t, map[string]float64{PhasePull: 2.5, PhaseHealthCheck: 4}, entries[0].Phases)
+	assert.Nil(t, entries[1].Phases)
+	assert.Equal(t, "a1b2c3d", entries[1].Commit)
+	assert.Equal(t, 42.0, entries[1].Duration)
+
+	_, err = parseHistory("not json")
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func TestDeployResult(t *testing.T) {
+	assert.Equal(t, config.EventDeploySucceeded, Result(nil))
+	assert.Equal(t, config.EventDeployFailed, Result(errors.New("boom")))
+	assert.Equal(t, config.EventDeployRolledBack, Result(fmt.Errorf("failed to deploy services: %w", ErrRolledBack)))
+}
+
+func TestRecordHistory_Failure(t *testing.T) {
+	cfg := &config.Config{Project: config.Project{Name: "project", Domain: "example.com", Email: "admin@example.com"}}
+
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("sh -c printf", fake.Response{Output: "sh: can't create history.jsonl: No space left on device", ExitCode: 1})
+
+	err := NewDeployment(runner, nil).RecordHistory(context.Background(), "project", cfg, HistoryEntry{Result: config.EventDeploySucceeded})
+	assert.ErrorContains(t, err, "failed to record deploy history")
+	assert.ErrorContains(t, err, "No space left on device")
+}
@@ -0,0 +1,145 @@
+package deployment
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/docker"
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+// initDependency runs the init steps of dependency that have not run against
+// its current data yet. The marker file ~/projects/<project>/init/<name>
+// records the volumes the steps ran against, on its first line, and the
+// digest of every step that ran; when the volumes were recreated since, every
+// step runs again.
+func (d *Deployment) initDependency(ctx context.Context, project string, dependency *config.Dependency) error {
+	if len(dependency.Init) == 0 {
+		return nil
+	}
+
+	generation, err := d.dataGeneration(ctx, project, dependency)
+	if err != nil {
+		return err
+	}
+	projectPath, err := d.projectFolder(project)
+	if err != nil {
+		return err
+	}
+	marker := path.Join(projectPath, "init", dependency.Name)
+
+	recorded, err := d.runCommand(ctx, "sh", "-c", "cat "+shell.Quote(marker)+" 2>/dev/null || true")
+	if err != nil {
+		return fmt.Errorf("failed to read init marker of %s: %w", dependency.Name, err)
+	}
+	lines := strings.Split(recorded, "\n")
+	applied := map[string]bool{}
+	if lines[0] == generation {
+		for _, line := range lines[1:] {
+			applied[line] = true
+		}
+	} else if _, err := d.runChecked(ctx, "sh", "-c", fmt.Sprintf("mkdir -p %s && printf '%%s\\n' %s > %s",
+		shell.Quote(path.Dir(marker)), shell.Quote(generation), shell.Quote(marker))); err != nil {
+		return fmt.Errorf("failed to write init marker of %s: %w", dependency.Name, err)
+	}
+
+	for i := range dependency.Init {
+		step := &dependency.Init[i]
+		digest, err := initDigest(dependency, step)
+		if err != nil {
+			return err
+		}
+		if applied[digest] {
+			continue
+		}
+
+		d.progress(fmt.Sprintf("Running init step %d of %s...", i+1, dependency.Name))
+		if err := d.runInitStep(ctx, project, path.Dir(marker), dependency, step, i); err != nil {
+			return fmt.Errorf("init step %d of %s failed: %w", i+1, dependency.Name, err)
+		}
+		if _, err := d.runChecked(ctx, "sh", "-c", fmt.Sprintf("echo %s >> %s", digest, shell.Quote(marker))); err != nil {
+			return fmt.Errorf("failed to write init marker of %s: %w", dependency.Name, err)
+		}
+	}
+	return nil
+}
+
+// runInitStep runs step in the container of dependency, with its file
+// uploaded to dir and passed on stdin.
+func (d *Deployment) runInitStep(ctx context.Context, project, dir string, dependency *config.Dependency, step *config.InitStep, index int) error {
+	container := containerName(project, dependency.Name, "")
+	command := dependency.InitCommand(step)
+	if step.File == "" {
+		return initStepError(d.runChecked(ctx, "docker", "exec", container, "sh", "-c", command))
+	}
+
+	remote := path.Join(dir, fmt.Sprintf("%s.%d%s", dependency.Name, index+1, path.Ext(step.File)))
+	if err := d.runner.CopyFile(ctx, step.File, remote); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", step.File, err)
+	}
+	defer func() { _, _ = d.runCommand(context.Background(), "rm", "-f", remote) }()
+
+	return initStepError(d.runChecked(ctx, "sh", "-c", fmt.Sprintf("docker exec -i %s sh -c %s < %s",
+		shell.Quote(container), shell.Quote(command), shell.Quote(remote))))
+}
+
+// initStepError adds the output of a failed init step to its error.
+func initStepError(output string, err error) error {
+	if err != nil && output != "" {
+		return fmt.Errorf("%w\n\x1b[93mOutput from the init step:\x1b[0m\n\x1b[90m%s\x1b[0m", err, output)
+	}
+	return err
+}
+
+// dataGeneration identifies the data of dependency: the creation time of its
+// volumes, which changes when they are recreated, and its bind mounted paths,
+// which are not. Without volumes, the data lives in the container.
+func (d *Deployment) dataGeneration(ctx context.Context, project string, dependency *config.Dependency) (string, error) {
+	var parts []string
+	for _, volume := range dependency.Volumes {
+		mount, err := config.ParseVolume(volume)
+		if err != nil {
+			return "", err
+		}
+		if mount.Bind() {
+			parts = append(parts, mount.Source)
+			continue
+		}
+		name, _, _ := strings.Cut(docker.VolumeBind(project, volume), ":")
+		created, err := d.runCommand(ctx, "docker", "volume", "inspect", "--format={{.CreatedAt}}", name)
+		if err != nil {
+			return "", fmt.Errorf("failed to inspect volume %s: %w", name, err)
+		}
+		parts = append(parts, name+"@"+created)
+	}
+
+	if len(parts) == 0 {
+		container := containerName(project, dependency.Name, "")
+		id, err := d.runCommand(ctx, "docker", "inspect", "--format={{.Id}}", container)
+		if err != nil {
+			return "", fmt.Errorf("failed to inspect %s: %w", container, err)
+		}
+		parts = append(parts, container+"@"+id)
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// initDigest identifies step by its command and the content of its file.
+func initDigest(dependency *config.Dependency, step *config.InitStep) (string, error) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "command=%s\n", dependency.InitCommand(step))
+	if step.File != "" {
+		content, err := os.ReadFile(step.File)
+		if err != nil {
+			return "", fmt.Errorf("failed to read init file %s: %w", step.File, err)
+		}
+		hash.Write(content)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,92 @@
+package deployment
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestInitDependency(t *testing.T) {
+	schema := filepath.Join(t.TempDir(), "schema.sql")
+	require.NoError(t, os.WriteFile(schema, []byte("CREATE TABLE users (id int);"), 0644))
+	dependency := &config.Dependency{
+		Name:    "postgres",
+		Image:   "postgres:16",
+		Volumes: []string{"data:/var/lib/postgresql/data"},
+		Init: []config.InitStep{
+			{Command: `psql -U postgres -c "CREATE EXTENSION pg_trgm"`},
+			{File: schema},
+		},
+	}
+
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("docker volume inspect", fake.Response{Output: "2026-01-01T00:00:00Z"})
+	require.NoError(t, NewDeployment(runner, nil).initDependency(context.Background(), "app", dependency))
+
+	var lines []string
+	for _, call := range runner.Calls() {
+		lines = append(lines, call.String())
+	}
+	assert.Contains(t, lines, `docker exec app-postgres sh -c psql -U postgres -c "CREATE EXTENSION pg_trgm"`)
+	assert.Contains(t, lines, `sh -c mkdir -p '/home/deploy/projects/app/init' && printf '%s\n' 'app-data@2026-01-01T00:00:00Z' > '/home/deploy/projects/app/init/postgres'`)
+	uploaded, ok := runner.File("/home/deploy/projects/app/init/postgres.2.sql")
+	require.True(t, ok)
+	assert.Equal(t, "CREATE TABLE users (id int);", string(uploaded))
+	assert.Contains(t, strings.Join(lines, "\n"), `sh -c docker exec -i 'app-postgres' sh -c 'psql -v ON_ERROR_STOP=1`)
+
+	// The marker lists both steps for the same volume, so nothing runs again.
+	first, err := initDigest(dependency, &dependency.Init[0])
+	require.NoError(t, err)
+	second, err := initDigest(dependency, &dependency.Init[1])
+	require.NoError(t, err)
+	runner = fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("docker volume inspect", fake.Response{Output: "2026-01-01T00:00:00Z"})
+	runner.On("sh -c cat", fake.Response{Output: "app-data@2026-01-01T00:00:00Z\n" + first + "\n" + second})
+	require.NoError(t, NewDeployment(runner, nil).initDependency(context.Background(), "app", dependency))
+	for _, call := range runner.Calls() {
+		assert.NotContains(t, call.String(), "docker exec", "applied steps are skipped")
+	}
+
+	// A recreated volume runs every step again.
+	runner.Reset()
+	runner.On("docker volume inspect", fake.Response{Output: "2026-02-01T00:00:00Z"})
+	require.NoError(t, NewDeployment(runner, nil).initDependency(context.Background(), "app", dependency))
+	var execs int
+	for _, call := range runner.Calls() {
+		if strings.Contains(call.String(), "docker exec") {
+			execs++
+		}
+	}
+	assert.Equal(t, 2, execs)
+}
+
+func TestInitDependency_Failure(t *testing.T) {
+	dependency := &config.Dependency{
+		Name:  "postgres",
+		Image: "postgres:16",
+		Init:  []config.InitStep{{Command: "psql -U postgres -f missing.sql"}},
+	}
+
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("docker inspect", fake.Response{Output: "abc123"})
+	runner.On("docker exec", fake.Response{Output: "psql: missing.sql: No such file or directory", ExitCode: 1})
+	err := NewDeployment(runner, nil).initDependency(context.Background(), "app", dependency)
+	assert.ErrorContains(t, err, "init step 1 of postgres failed: command failed: exit status 1")
+	assert.ErrorContains(t, err, "No such file or directory")
+
+	// A failed step is not recorded, so it runs again on the next deploy.
+	for _, call := range runner.Calls() {
+		assert.NotContains(t, call.String(), ">> '/home/deploy/projects/app/init/postgres'")
+	}
+}
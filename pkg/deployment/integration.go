@@ -0,0 +1,214 @@
+package deployment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yarlson/pin"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/docker"
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+// sandboxSuffix is appended to the project name for the network, containers
+// and volumes of an integration test run, isolating them from production.
+const sandboxSuffix = "-test"
+
+// TestResult is the outcome of a single integration test container.
+type TestResult struct {
+	Name     string
+	Passed   bool
+	Duration time.Duration
+	Output   string
+}
+
+// SandboxName returns the name of the isolated project used by integration tests.
+func SandboxName(project string) string {
+	return project + sandboxSuffix
+}
+
+// Test deploys the project's dependencies and services into a sandbox on the
+// server, runs every configured test container against it and reports the
+// results. The sandbox is removed afterwards unless keep is set; what could
+// not be removed is returned as an error along with the results.
+func (d *Deployment) Test(ctx context.Context, project string, cfg *config.Config, spinner *pin.Pin, keep bool) (results []TestResult, err error) {
+	d.spinner = spinner
+	sandbox := SandboxName(project)
+
+	if !keep {
+		defer func() {
+			d.progress("Removing test sandbox...")
+			if removeErr := d.RemoveSandbox(context.Background(), project, cfg); removeErr != nil {
+				err = errors.Join(err, removeErr)
+			}
+		}()
+	}
+
+	d.progress("Creating test sandbox...")
+	if err := d.ensureNetwork(sandbox, cfg); err != nil {
+		return nil, fmt.Errorf("failed to create sandbox network: %w", err)
+	}
+	if err := d.createNetworks(sandbox, cfg); err != nil {
+		return nil, err
+	}
+	if err := d.createVolumes(ctx, sandbox, cfg.Volumes); err != nil {
+		return nil, fmt.Errorf("failed to create sandbox volumes: %w", err)
+	}
+
+	d.progress("Deploying dependencies to sandbox...")
+	var dependencies []config.Dependency
+	for _, dependency := range cfg.Dependencies {
+		dependency.Ports = nil
+		dependency.CrashAlert = nil
+		dependencies = append(dependencies, dependency)
+	}
+	if err := d.deployDependencies(ctx, sandbox, dependencies); err != nil {
+		return nil, fmt.Errorf("failed to deploy dependencies: %w", err)
+	}
+
+	d.progress("Deploying services to sandbox...")
+	var services []config.Service
+	for _, service := range cfg.Services {
+		if service.Static != nil {
+			continue
+		}
+		if err := d.tagSandboxImage(ctx, project, &service); err != nil {
+			return nil, err
+		}
+		service.Forwards = nil
+		service.CrashAlert = nil
+		service.Hooks = remoteHooks(service.Hooks)
+		services = append(services, service)
+	}
+	if err := d.deployServices(ctx, sandbox, services); err != nil {
+		return nil, fmt.Errorf("failed to deploy services: %w", err)
+	}
+
+	for _, test := range cfg.Tests {
+		d.progress(fmt.Sprintf("Running test %s...", test.Name))
+		result, err := d.runTest(ctx, sandbox, test)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// runTest runs a test container on the sandbox network and waits for it to exit.
+func (d *Deployment) runTest(ctx context.Context, sandbox string, test config.IntegrationTest) (TestResult, error) {
+	runService := &config.Service{
+		Name:      test.Name,
+		Image:     test.Image,
+		Env:       test.Env,
+		Container: &config.Container{RunOnce: true},
+	}
+	if test.Command != "" {
+		runService.Entrypoint = []string{"sh"}
+		runService.CommandSlice = []string{"-c", test.Command}
+	}
+
+	args, err := docker.RunArgs(sandbox, runService, "_test")
+	if err != nil {
+		return TestResult{}, err
+	}
+
+	if test.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, test.Timeout.Duration())
+		defer cancel()
+	}
+
+	start := time.Now()
+	var lines []string
+	err = d.streamChecked(ctx, func(line string) {
+		lines = append(lines, line)
+		if len(lines) > 20 {
+			lines = lines[1:]
+		}
+	}, "docker", args...)
+
+	return TestResult{
+		Name:     test.Name,
+		Passed:   err == nil && ctx.Err() == nil,
+		Duration: time.Since(start),
+		Output:   strings.Join(lines, "\n"),
+	}, nil
+}
+
+// RemoveSandbox removes every container, the network, the volumes and the
+// retagged images of the project's test sandbox. It goes on when something
+// cannot be removed and returns every failure; networks and images the
+// sandbox did not get to create are skipped.
+func (d *Deployment) RemoveSandbox(ctx context.Context, project string, cfg *config.Config) error {
+	sandbox := SandboxName(project)
+	var errs []error
+
+	networks := append([]string{sandbox}, privateNetworks(sandbox, cfg.Networks)...)
+	for _, network := range networks {
+		if output, err := d.runChecked(ctx, "sh", "-c", fmt.Sprintf(`docker ps -aq --filter network=%s | while read -r id; do docker rm -f "$id" || exit 1; done`, shell.Quote(network))); err != nil {
+			errs = append(errs, teardownError(fmt.Errorf("failed to remove sandbox containers on %s: %w", network, err), output))
+		}
+	}
+	for _, network := range networks {
+		if output, err := d.runChecked(ctx, "sh", "-c", fmt.Sprintf("docker network inspect %[1]s >/dev/null 2>&1 || exit 0; docker network rm %[1]s", shell.Quote(network))); err != nil {
+			errs = append(errs, teardownError(fmt.Errorf("failed to remove sandbox network %s: %w", network, err), output))
+		}
+	}
+	for _, volume := range cfg.Volumes {
+		if output, err := d.runChecked(ctx, "docker", "volume", "rm", "-f", fmt.Sprintf("%s-%s", sandbox, volume)); err != nil {
+			errs = append(errs, teardownError(fmt.Errorf("failed to remove sandbox volume %s: %w", volume, err), output))
+		}
+	}
+	for _, service := range cfg.Services {
+		if service.Image != "" || service.Static != nil {
+			continue
+		}
+		image := fmt.Sprintf("%s-%s", sandbox, service.Name)
+		if output, err := d.runChecked(ctx, "sh", "-c", fmt.Sprintf("docker image inspect %[1]s >/dev/null 2>&1 || exit 0; docker rmi %[1]s", shell.Quote(image))); err != nil {
+			errs = append(errs, teardownError(fmt.Errorf("failed to remove sandbox image %s: %w", image, err), output))
+		}
+		_, _ = d.runLocalCommand(ctx, "docker", "rmi", image)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to remove test sandbox: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// tagSandboxImage tags the locally built image of service for the sandbox of
+// project, so the image syncer transfers it under the sandbox name. Services
+// with an image are pulled on the server instead.
+func (d *Deployment) tagSandboxImage(ctx context.Context, project string, service *config.Service) error {
+	if service.Image != "" {
+		return nil
+	}
+	image := fmt.Sprintf("%s-%s", project, service.Name)
+	if _, err := d.runLocalCommand(ctx, "docker", "tag", image, fmt.Sprintf("%s-%s", SandboxName(project), service.Name)); err != nil {
+		return fmt.Errorf("failed to tag image for service %s: %w", service.Name, err)
+	}
+	return nil
+}
+
+// remoteHooks drops local hook commands, which need tunnels to production
+// dependencies and must not run against a sandbox.
+func remoteHooks(hooks *config.Hooks) *config.Hooks {
+	if hooks == nil {
+		return nil
+	}
+
+	sandboxHooks := &config.Hooks{}
+	if hooks.Pre != nil && hooks.Pre.Remote != "" {
+		sandboxHooks.Pre = &config.HookItem{Remote: hooks.Pre.Remote}
+	}
+	if hooks.Post != nil && hooks.Post.Remote != "" {
+		sandboxHooks.Post = &config.HookItem{Remote: hooks.Post.Remote}
+	}
+	return sandboxHooks
+}
@@ -0,0 +1,77 @@
+package deployment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestRemoteHooks(t *testing.T) {
+	assert.Nil(t, remoteHooks(nil))
+
+	hooks := remoteHooks(&config.Hooks{
+		Pre:  &config.HookItem{Remote: "rake db:migrate", Local: "make seed"},
+		Post: &config.HookItem{Local: "make notify"},
+	})
+	assert.Equal(t, &config.HookItem{Remote: "rake db:migrate"}, hooks.Pre)
+	assert.Nil(t, hooks.Post)
+}
+
+func TestSandboxImages_Local(t *testing.T) {
+	remote, local := fake.NewRunner(), fake.NewRunner()
+	d := NewDeployment(remote, nil)
+	d.localRunner = local
+
+	require.NoError(t, d.tagSandboxImage(context.Background(), "shop", &config.Service{Name: "web"}))
+	require.NoError(t, d.tagSandboxImage(context.Background(), "shop", &config.Service{Name: "api", Image: "api:1.0"}))
+	assert.Empty(t, remote.Calls())
+	require.Len(t, local.Calls(), 1)
+	assert.Equal(t, "docker tag shop-web shop-test-web", local.Calls()[0].String())
+
+	local.On("docker tag", fake.Response{Output: "No such image: shop-web", ExitCode: 1})
+	assert.ErrorContains(t, d.tagSandboxImage(context.Background(), "shop", &config.Service{Name: "web"}), "failed to tag image for service web")
+
+	// The retagged image is removed from both machines.
+	local.Reset()
+	require.NoError(t, d.RemoveSandbox(context.Background(), "shop", &config.Config{Services: []config.Service{{Name: "web"}}}))
+	assert.Contains(t, callLines(remote), "sh -c docker image inspect 'shop-test-web' >/dev/null 2>&1 || exit 0; docker rmi 'shop-test-web'")
+	assert.Equal(t, []string{"docker rmi shop-test-web"}, callLines(local))
+}
+
+func TestRemoveSandbox_Failures(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("sh -c docker network inspect 'shop-test'", fake.Response{Output: "Error response from daemon: error while removing network: network shop-test has active endpoints", ExitCode: 1})
+	runner.On("docker volume rm -f shop-test-data", fake.Response{Output: "Error response from daemon: remove shop-test-data: volume is in use", ExitCode: 1})
+	cfg := &config.Config{Volumes: []string{"data", "uploads"}}
+
+	err := NewDeployment(runner, nil).RemoveSandbox(context.Background(), "shop", cfg)
+	assert.ErrorContains(t, err, "failed to remove sandbox network shop-test")
+	assert.ErrorContains(t, err, "has active endpoints")
+	assert.ErrorContains(t, err, "failed to remove sandbox volume data")
+	assert.ErrorContains(t, err, "volume is in use")
+	assert.Contains(t, callLines(runner), "docker volume rm -f shop-test-uploads", "teardown goes on after a failure")
+}
+
+func TestLocalHooks(t *testing.T) {
+	remote, local := fake.NewRunner(), fake.NewRunner()
+	d := NewDeployment(remote, nil)
+	d.localRunner = local
+
+	service := &config.Service{Name: "web", Hooks: &config.Hooks{Pre: &config.HookItem{Local: "make seed"}}}
+	require.NoError(t, d.processPreHooks("shop", service))
+	assert.Empty(t, remote.Calls())
+	assert.Equal(t, []string{"sh -c make seed"}, callLines(local))
+}
+
+func callLines(runner *fake.Runner) []string {
+	var lines []string
+	for _, call := range runner.Calls() {
+		lines = append(lines, call.String())
+	}
+	return lines
+}
@@ -0,0 +1,69 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+const lockAcquired = "ftl-lock-acquired"
+
+// Lock acquires the deploy lock of the project on the server, recording owner
+// as its holder. If another deploy holds the lock, Lock fails with the holder's
+// details unless force is set, in which case the stale lock is taken over.
+func (d *Deployment) Lock(ctx context.Context, project, owner string, force bool) error {
+	lockPath, err := d.lockPath(project)
+	if err != nil {
+		return err
+	}
+
+	if force {
+		if output, err := d.runChecked(ctx, "rm", "-rf", lockPath); err != nil {
+			return outputError(fmt.Errorf("failed to remove deploy lock: %w", err), output)
+		}
+	}
+
+	script := fmt.Sprintf(
+		"if mkdir %[1]s 2>/dev/null; then printf '%%s' %[2]s > %[1]s/owner; echo %[3]s; else cat %[1]s/owner 2>/dev/null; fi",
+		shell.Quote(lockPath), shell.Quote(owner), lockAcquired,
+	)
+
+	output, err := d.runCommand(ctx, "sh", "-c", script)
+	if err != nil {
+		return fmt.Errorf("failed to acquire deploy lock: %w", err)
+	}
+
+	if output != lockAcquired {
+		if output == "" {
+			output = "unknown owner"
+		}
+		return fmt.Errorf("another deployment is in progress (locked by %s); if it is stale, rerun with --force-unlock", output)
+	}
+
+	return nil
+}
+
+// Unlock releases the deploy lock of the project.
+func (d *Deployment) Unlock(ctx context.Context, project string) error {
+	lockPath, err := d.lockPath(project)
+	if err != nil {
+		return err
+	}
+
+	if output, err := d.runChecked(ctx, "rm", "-rf", lockPath); err != nil {
+		return outputError(fmt.Errorf("failed to release deploy lock: %w", err), output)
+	}
+
+	return nil
+}
+
+func (d *Deployment) lockPath(project string) (string, error) {
+	projectPath, err := d.prepareProjectFolder(project)
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare project folder: %w", err)
+	}
+
+	return filepath.Join(projectPath, "deploy.lock"), nil
+}
@@ -0,0 +1,84 @@
+package deployment
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+const testLockPath = "/home/deploy/projects/shop/deploy.lock"
+
+func newLockRunner(lockOutput string) *fake.Runner {
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("sh -c if mkdir", fake.Response{Output: lockOutput})
+	return runner
+}
+
+func TestLock(t *testing.T) {
+	runner := newLockRunner(lockAcquired)
+	require.NoError(t, NewDeployment(runner, nil).Lock(context.Background(), "shop", "alice@laptop since 2026-10-14T09:00:00Z", false))
+
+	lines := callLines(runner)
+	assert.Equal(t, "sh -c if mkdir '"+testLockPath+"' 2>/dev/null; then printf '%s' 'alice@laptop since 2026-10-14T09:00:00Z' > '"+testLockPath+"'/owner; echo ftl-lock-acquired; else cat '"+testLockPath+"'/owner 2>/dev/null; fi", lines[len(lines)-1])
+	assert.NotContains(t, lines, "rm -rf "+testLockPath, "a free lock is not removed first")
+}
+
+func TestLock_Held(t *testing.T) {
+	runner := newLockRunner("bob@ci since 2026-10-14T08:55:00Z")
+	err := NewDeployment(runner, nil).Lock(context.Background(), "shop", "alice@laptop", false)
+	assert.EqualError(t, err, "another deployment is in progress (locked by bob@ci since 2026-10-14T08:55:00Z); if it is stale, rerun with --force-unlock")
+
+	// A lock whose owner file was never written still names no one.
+	runner = newLockRunner("")
+	err = NewDeployment(runner, nil).Lock(context.Background(), "shop", "alice@laptop", false)
+	assert.ErrorContains(t, err, "locked by unknown owner")
+}
+
+func TestLock_Force(t *testing.T) {
+	runner := newLockRunner(lockAcquired)
+	require.NoError(t, NewDeployment(runner, nil).Lock(context.Background(), "shop", "alice@laptop", true))
+
+	lines := callLines(runner)
+	removed := slices.Index(lines, "rm -rf "+testLockPath)
+	require.NotEqual(t, -1, removed, "the stale lock is removed")
+	acquired := slices.IndexFunc(lines, func(line string) bool { return strings.HasPrefix(line, "sh -c if mkdir") })
+	assert.Less(t, removed, acquired, "the lock is taken over after the stale one is removed")
+
+	runner = newLockRunner(lockAcquired)
+	runner.On("rm -rf "+testLockPath, fake.Response{Output: "rm: cannot remove 'deploy.lock': Permission denied", ExitCode: 1})
+	err := NewDeployment(runner, nil).Lock(context.Background(), "shop", "alice@laptop", true)
+	assert.ErrorContains(t, err, "failed to remove deploy lock")
+	assert.ErrorContains(t, err, "Permission denied")
+}
+
+func TestUnlock_AfterFailedDeploy(t *testing.T) {
+	runner := newLockRunner(lockAcquired)
+	runner.On("docker network inspect", fake.Response{Err: errors.New("permission denied")})
+	runner.On("docker network create", fake.Response{Err: errors.New("permission denied")})
+	cfg := &config.Config{
+		Project: config.Project{Name: "shop", Domain: "shop.example.com", Email: "admin@example.com"},
+		Server:  &config.Server{Host: "example.com"},
+	}
+
+	deploy := NewDeployment(runner, nil)
+	require.NoError(t, deploy.Lock(context.Background(), "shop", "alice@laptop", false))
+	require.Error(t, deploy.Deploy(context.Background(), "shop", cfg, nil, nil))
+	require.NoError(t, deploy.Unlock(context.Background(), "shop"))
+
+	lines := callLines(runner)
+	assert.Equal(t, "rm -rf "+testLockPath, lines[len(lines)-1])
+
+	runner.On("rm -rf "+testLockPath, fake.Response{Output: "rm: cannot remove 'deploy.lock': Read-only file system", ExitCode: 1})
+	err := deploy.Unlock(context.Background(), "shop")
+	assert.ErrorContains(t, err, "failed to release deploy lock")
+	assert.ErrorContains(t, err, "Read-only file system")
+}
@@ -0,0 +1,97 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/proxy"
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+// MaintenanceOn uploads page, or the built-in maintenance page when page is
+// empty, and switches the proxy of the project to serve it instead of the
+// services. Containers keep running.
+func (d *Deployment) MaintenanceOn(ctx context.Context, project, page string) error {
+	dir, err := d.maintenanceFolder(project)
+	if err != nil {
+		return err
+	}
+	live, err := d.runCommand(ctx, "sh", "-c", fmt.Sprintf("cat %s 2>/dev/null || true", shell.Quote(filepath.Join(filepath.Dir(dir), "default.conf"))))
+	if err != nil {
+		return fmt.Errorf("failed to read proxy configuration: %w", err)
+	}
+	if !strings.Contains(live, "@ftl_maintenance") {
+		return fmt.Errorf("the proxy on the server does not support maintenance mode yet, deploy the project first")
+	}
+
+	if _, err := d.runCommand(ctx, "mkdir", "-p", dir); err != nil {
+		return fmt.Errorf("failed to create maintenance folder: %w", err)
+	}
+
+	if page == "" {
+		tmpFile, err := os.CreateTemp("", "maintenance-*.html")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary file: %w", err)
+		}
+		defer os.Remove(tmpFile.Name())
+
+		_, err = tmpFile.WriteString(proxy.DefaultMaintenancePage)
+		_ = tmpFile.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write maintenance page to temporary file: %w", err)
+		}
+		page = tmpFile.Name()
+	}
+
+	if err := d.runner.CopyFile(ctx, page, filepath.Join(dir, proxy.MaintenancePage)); err != nil {
+		return fmt.Errorf("failed to copy maintenance page: %w", err)
+	}
+
+	// The flag is written last so the proxy never serves a missing page.
+	if output, err := d.runChecked(ctx, "touch", filepath.Join(dir, proxy.MaintenanceFlag)); err != nil {
+		return outputError(fmt.Errorf("failed to enable maintenance mode: %w", err), output)
+	}
+
+	return nil
+}
+
+// MaintenanceOff switches the proxy of the project back to the services.
+func (d *Deployment) MaintenanceOff(ctx context.Context, project string) error {
+	dir, err := d.maintenanceFolder(project)
+	if err != nil {
+		return err
+	}
+	if output, err := d.runChecked(ctx, "rm", "-f", filepath.Join(dir, proxy.MaintenanceFlag)); err != nil {
+		return outputError(fmt.Errorf("failed to disable maintenance mode: %w", err), output)
+	}
+
+	return nil
+}
+
+// MaintenanceEnabled reports whether maintenance mode is on for the project.
+func (d *Deployment) MaintenanceEnabled(ctx context.Context, project string) (bool, error) {
+	dir, err := d.maintenanceFolder(project)
+	if err != nil {
+		return false, err
+	}
+	output, err := d.runCommand(ctx, "sh", "-c", fmt.Sprintf("test -f %s && echo on || true", shell.Quote(filepath.Join(dir, proxy.MaintenanceFlag))))
+	if err != nil {
+		return false, fmt.Errorf("failed to check maintenance mode: %w", err)
+	}
+
+	return strings.TrimSpace(output) == "on", nil
+}
+
+// maintenanceFolder returns the directory on the server holding the
+// maintenance page, inside the nginx configuration the proxy mounts.
+func (d *Deployment) maintenanceFolder(project string) (string, error) {
+	projectPath, err := d.projectFolder(project)
+	if err != nil {
+		return "", fmt.Errorf("failed to get project folder path: %w", err)
+	}
+
+	return filepath.Join(projectPath, "nginx", proxy.MaintenanceDir), nil
+}
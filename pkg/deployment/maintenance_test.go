@@ -0,0 +1,61 @@
+package deployment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/proxy"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestMaintenance(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("sh -c cat", fake.Response{Output: "location @ftl_maintenance {"})
+	runner.On("sh -c test -f", fake.Response{Output: "on\n"})
+	deploy := NewDeployment(runner, nil)
+
+	require.NoError(t, deploy.MaintenanceOn(context.Background(), "project", ""))
+	page, ok := runner.File("/home/deploy/projects/project/nginx/maintenance/index.html")
+	require.True(t, ok)
+	assert.Equal(t, proxy.DefaultMaintenancePage, string(page))
+
+	calls := runner.Calls()
+	assert.Equal(t, "touch /home/deploy/projects/project/nginx/maintenance/on", calls[len(calls)-1].String())
+
+	enabled, err := deploy.MaintenanceEnabled(context.Background(), "project")
+	require.NoError(t, err)
+	assert.True(t, enabled)
+
+	require.NoError(t, deploy.MaintenanceOff(context.Background(), "project"))
+	calls = runner.Calls()
+	assert.Equal(t, "rm -f /home/deploy/projects/project/nginx/maintenance/on", calls[len(calls)-1].String())
+}
+
+func TestMaintenance_OutdatedProxy(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+
+	err := NewDeployment(runner, nil).MaintenanceOn(context.Background(), "project", "")
+	assert.ErrorContains(t, err, "deploy the project first")
+}
+
+func TestMaintenance_FlagFailure(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("sh -c cat", fake.Response{Output: "location @ftl_maintenance {"})
+	runner.On("touch", fake.Response{Output: "touch: cannot touch 'on': No space left on device", ExitCode: 1})
+	runner.On("rm -f", fake.Response{Output: "rm: cannot remove 'on': Permission denied", ExitCode: 1})
+	deploy := NewDeployment(runner, nil)
+
+	err := deploy.MaintenanceOn(context.Background(), "project", "")
+	assert.ErrorContains(t, err, "failed to enable maintenance mode")
+	assert.ErrorContains(t, err, "No space left on device")
+
+	err = deploy.MaintenanceOff(context.Background(), "project")
+	assert.ErrorContains(t, err, "failed to disable maintenance mode")
+	assert.ErrorContains(t, err, "Permission denied")
+}
@@ -0,0 +1,124 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/proxy"
+)
+
+// Defaults of the metrics settings.
+const (
+	DefaultMetricsPort = 9113
+	DefaultMetricsBind = "127.0.0.1"
+)
+
+// metricsExporterImage parses the proxy access log into Prometheus metrics.
+const metricsExporterImage = "ghcr.io/martin-helmich/prometheus-nginxlog-exporter/exporter:v1"
+
+// metricsAgentScript writes the certificate expiry of every certificate in
+// /certs and the restart count of every container on the project networks to
+// the file served by the proxy as /metrics/agent, every 30 seconds.
+const metricsAgentScript = `apk add --no-cache openssl >/dev/null 2>&1
+while true; do
+  {
+    echo '# HELP ftl_certificate_expiry_timestamp_seconds Time the certificate of a domain expires.'
+    echo '# TYPE ftl_certificate_expiry_timestamp_seconds gauge'
+    for cert in /certs/*.crt; do
+      [ -f "$cert" ] || continue
+      end=$(openssl x509 -noout -enddate -dateopt iso_8601 -in "$cert" 2>/dev/null | cut -d= -f2)
+      ts=$(date -u -D '%Y-%m-%d %H:%M:%SZ' -d "$end" +%s 2>/dev/null) || continue
+      echo "ftl_certificate_expiry_timestamp_seconds{domain=\"$(basename "$cert" .crt)\"} $ts"
+    done
+    echo '# HELP ftl_container_restarts_total Number of times Docker restarted a container.'
+    echo '# TYPE ftl_container_restarts_total counter'
+    echo '# HELP ftl_container_running Whether a container is running.'
+    echo '# TYPE ftl_container_running gauge'
+    for network in $FTL_NETWORKS; do
+      docker ps -a --filter "network=$network" --format '{{.Names}}'
+    done | sort -u | while read -r name; do
+      docker inspect -f "ftl_container_restarts_total{container=\"$name\"} {{.RestartCount}}
+ftl_container_running{container=\"$name\"} {{if .State.Running}}1{{else}}0{{end}}" "$name" 2>/dev/null
+    done
+  } > /metrics/agent.prom.tmp && mv /metrics/agent.prom.tmp /metrics/agent.prom
+  sleep 30
+done`
+
+// metricsForward returns the port mapping publishing the proxy's metrics
+// server on the configured address.
+func metricsForward(metrics *config.Metrics) string {
+	port := metrics.Port
+	if port == 0 {
+		port = DefaultMetricsPort
+	}
+	bind := metrics.Bind
+	if bind == "" {
+		bind = DefaultMetricsBind
+	}
+
+	return fmt.Sprintf("%s:%d", net.JoinHostPort(bind, strconv.Itoa(port)), proxy.MetricsPort)
+}
+
+// deployMetrics starts the exporter receiving the proxy access log and the
+// agent collecting server metrics. The exporter has to run before the proxy
+// starts, as nginx resolves the address of its syslog server on startup.
+func (d *Deployment) deployMetrics(project string, cfg *config.Config) error {
+	projectPath, err := d.prepareProjectFolder(project)
+	if err != nil {
+		return fmt.Errorf("failed to prepare project folder: %w", err)
+	}
+
+	configPath := filepath.Join(projectPath, "metrics")
+	if _, err := d.runCommand(context.Background(), "mkdir", "-p", configPath); err != nil {
+		return fmt.Errorf("failed to create metrics config directory: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp("", "metrics-exporter-*.yml")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.WriteString(proxy.MetricsExporterConfig())
+	_ = tmpFile.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write metrics exporter config to temporary file: %w", err)
+	}
+	if err := d.runner.CopyFile(context.Background(), tmpFile.Name(), filepath.Join(configPath, "exporter.yml")); err != nil {
+		return fmt.Errorf("failed to copy metrics exporter config: %w", err)
+	}
+
+	exporter := &config.Service{
+		Name:         proxy.MetricsExporter,
+		Image:        metricsExporterImage,
+		Volumes:      []string{configPath + ":/etc/ftl-metrics:ro"},
+		CommandSlice: []string{"-config-file", "/etc/ftl-metrics/exporter.yml"},
+		Recreate:     true,
+	}
+	if err := d.deployService(project, exporter); err != nil {
+		return fmt.Errorf("failed to deploy metrics exporter: %w", err)
+	}
+
+	agent := &config.Service{
+		Name:         "metrics-agent",
+		Image:        "docker:cli",
+		Entrypoint:   []string{"sh"},
+		CommandSlice: []string{"-c", metricsAgentScript},
+		Volumes:      []string{"certs:/certs:ro", "metrics:/metrics"},
+		Env:          []string{"FTL_NETWORKS=" + strings.Join(append([]string{project}, privateNetworks(project, cfg.Networks)...), " ")},
+		Recreate:     true,
+	}
+	withDockerAccess(agent, cfg.Server)
+
+	if err := d.deployService(project, agent); err != nil {
+		return fmt.Errorf("failed to deploy metrics agent: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+package deployment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yarlson/ftl/pkg/config"
+)
+
+func TestMetricsForward(t *testing.T) {
+	assert.Equal(t, "127.0.0.1:9113:9113", metricsForward(&config.Metrics{}))
+	assert.Equal(t, "10.0.0.2:9200:9113", metricsForward(&config.Metrics{Port: 9200, Bind: "10.0.0.2"}))
+	assert.Equal(t, "[fd00::2]:9113:9113", metricsForward(&config.Metrics{Bind: "fd00::2"}))
+}
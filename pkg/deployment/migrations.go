@@ -0,0 +1,116 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/docker"
+)
+
+const migrationContainerSuffix = "_migrate"
+
+// withMigrations wraps a release of the service with its migrations, running
+// them before or after release depending on the configured timing. A failing
+// pre-cutover migration aborts the release.
+func (d *Deployment) withMigrations(project string, service *config.Service, release func() error) error {
+	migrations := service.Migrations
+	if migrations == nil {
+		return release()
+	}
+
+	timing := migrations.Timing
+	if timing == "" {
+		timing = config.MigrationsPre
+	}
+
+	if timing == config.MigrationsPre {
+		if err := d.runMigrations(context.Background(), project, service); err != nil {
+			return fmt.Errorf("pre-cutover migrations failed for %s: %w", service.Name, err)
+		}
+	}
+
+	if err := release(); err != nil {
+		return err
+	}
+
+	if timing == config.MigrationsPost {
+		if err := d.runMigrations(context.Background(), project, service); err != nil {
+			return fmt.Errorf("post-cutover migrations failed for %s: %w", service.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// runMigrations runs the migration command in an ephemeral container on the
+// project network, streaming its output to the spinner.
+func (d *Deployment) runMigrations(ctx context.Context, project string, service *config.Service) error {
+	migrations := service.Migrations
+
+	image := migrations.Image
+	if image == "" {
+		image = service.Image
+	}
+
+	runService := &config.Service{
+		Name:         service.Name,
+		Image:        image,
+		Volumes:      service.Volumes,
+		Env:          service.Env,
+		Entrypoint:   []string{"sh"},
+		CommandSlice: []string{"-c", migrations.Command},
+		Container:    runOnceContainer(service),
+		Networks:     service.Networks,
+	}
+
+	args, err := docker.RunArgs(project, runService, migrationContainerSuffix)
+	if err != nil {
+		return err
+	}
+
+	// The container joins the private networks of the service before it
+	// starts, so migrations can reach isolated dependencies.
+	if networks := docker.ServiceNetworks(project, runService)[1:]; len(networks) > 0 {
+		container := containerName(project, service.Name, migrationContainerSuffix)
+		args[0] = "create"
+		if _, err := d.runCommand(ctx, "docker", args...); err != nil {
+			return fmt.Errorf("failed to create migration container: %w", err)
+		}
+		for _, network := range networks {
+			if _, err := d.runCommand(ctx, "docker", "network", "connect", network, container); err != nil {
+				_, _ = d.runCommand(context.Background(), "docker", "rm", "-f", container)
+				return fmt.Errorf("failed to connect migration container to network %s: %w", network, err)
+			}
+		}
+		args = []string{"start", "--attach", container}
+	}
+
+	command := "docker"
+	if migrations.Lock != config.MigrationsLockNone {
+		projectPath, err := d.prepareProjectFolder(project)
+		if err != nil {
+			return fmt.Errorf("failed to prepare project folder: %w", err)
+		}
+		args = append([]string{filepath.Join(projectPath, "migrations.lock"), "docker"}, args...)
+		command = "flock"
+	}
+
+	d.progress(fmt.Sprintf("Running migrations for %s...", service.Name))
+
+	var lines []string
+	err = d.streamChecked(ctx, func(line string) {
+		lines = append(lines, line)
+		if len(lines) > 20 {
+			lines = lines[1:]
+		}
+		d.progress(fmt.Sprintf("Migrating %s: %s", service.Name, line))
+	}, command, args...)
+	if err != nil {
+		return fmt.Errorf("%w\n\x1b[93mOutput from the migration:\x1b[0m\n\x1b[90m%s\x1b[0m", err, strings.Join(lines, "\n"))
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package deployment
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+func TestScriptsArePOSIX(t *testing.T) {
+	for name, script := range map[string]string{
+		"crash watcher": crashWatcherScript,
+		"metrics agent": metricsAgentScript,
+	} {
+		assert.NoError(t, shell.Check(script), name)
+
+		output, err := exec.Command("sh", "-n", "-c", script).CombinedOutput()
+		assert.NoError(t, err, "%s: %s", name, output)
+	}
+}
@@ -0,0 +1,153 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/yarlson/ftl/pkg/config"
+)
+
+// pullKey identifies a pulled image by its reference in the configuration and
+// the signature it was verified against, so a service that requires a
+// signature never gets an image pulled for one that does not.
+type pullKey struct {
+	image  string
+	verify config.Verify
+}
+
+func newPullKey(service *config.Service) pullKey {
+	key := pullKey{image: service.Image}
+	if service.Verify != nil {
+		key.verify = *service.Verify
+	}
+	return key
+}
+
+// pulledImages records the images the pre-pull phase of a deploy pulled, with
+// the reference to deploy: the verified digest of signed images, the
+// configured reference otherwise.
+type pulledImages struct {
+	mu   sync.Mutex
+	refs map[pullKey]string
+}
+
+func (p *pulledImages) add(key pullKey, ref string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.refs == nil {
+		p.refs = map[pullKey]string{}
+	}
+	p.refs[key] = ref
+}
+
+func (p *pulledImages) get(key pullKey) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ref, ok := p.refs[key]
+	return ref, ok
+}
+
+func (p *pulledImages) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refs = nil
+}
+
+// prePullImages pulls the images of the dependencies and services on the
+// server concurrently, before any container is replaced, so a slow or
+// failing registry fails the deploy while the running containers are still
+// untouched. Services without an image are transferred as before.
+func (d *Deployment) prePullImages(ctx context.Context, dependencies []config.Dependency, services []config.Service) error {
+	var images []*config.Service
+	seen := map[pullKey]bool{}
+	add := func(service *config.Service) {
+		key := newPullKey(service)
+		if service.Image == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		images = append(images, service)
+	}
+	for i := range dependencies {
+		add(DependencyService(&dependencies[i]))
+	}
+	for i := range services {
+		add(&services[i])
+	}
+
+	var wg sync.WaitGroup
+	errChan := make(chan error, len(images))
+	for _, service := range images {
+		wg.Add(1)
+		go func(service config.Service) {
+			defer wg.Done()
+			if err := d.prePullImage(ctx, &service); err != nil {
+				errChan <- err
+			}
+		}(*service)
+	}
+
+	wg.Wait()
+	close(errChan)
+
+	var errs []error
+	for err := range errChan {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("errors occurred while pulling images: %v", errs)
+	}
+	return nil
+}
+
+// prePullImage verifies the signature of the image of service, when it has
+// to, pulls it and checks its digest.
+func (d *Deployment) prePullImage(ctx context.Context, service *config.Service) error {
+	key := newPullKey(service)
+	image := service.Image
+	if service.Verify != nil {
+		d.progress(fmt.Sprintf("Verifying signature of %s...", service.Image))
+		verified, err := d.verifyImage(ctx, service)
+		if err != nil {
+			return err
+		}
+		image = verified
+	}
+
+	d.progress(fmt.Sprintf("Pulling %s...", image))
+	if err := d.timed(PhasePull, service, func() error { return d.dockerManager.PullImage(image) }); err != nil {
+		return err
+	}
+	if err := d.checkDigest(ctx, image); err != nil {
+		return err
+	}
+
+	d.pulled.add(key, image)
+	return nil
+}
+
+// checkDigest checks that an image pulled by digest, as in
+// postgres@sha256:..., is stored on the server under that digest.
+func (d *Deployment) checkDigest(ctx context.Context, image string) error {
+	_, digest, pinned := strings.Cut(image, "@")
+	if !pinned {
+		return nil
+	}
+
+	output, err := d.runCommand(ctx, "docker", "image", "inspect", "--format={{join .RepoDigests \" \"}}", image)
+	if err != nil {
+		return fmt.Errorf("failed to read digests of %s: %w", image, err)
+	}
+	// Docker records no digests when the image was not pulled from a registry.
+	if output == "" {
+		return nil
+	}
+	for _, repoDigest := range strings.Fields(output) {
+		if strings.HasSuffix(repoDigest, "@"+digest) {
+			return nil
+		}
+	}
+	return fmt.Errorf("image %s was pulled with digests %s instead", image, output)
+}
@@ -0,0 +1,64 @@
+package deployment
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/docker"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestPrePullImages(t *testing.T) {
+	dependencies := []config.Dependency{{Name: "postgres", Image: "postgres:16"}}
+	services := []config.Service{
+		{Name: "web", Image: "ghcr.io/acme/web:1.2"},
+		{Name: "jobs", Image: "ghcr.io/acme/web:1.2"},
+		{Name: "api"},
+	}
+
+	runner := fake.NewRunner()
+	d := NewDeployment(runner, nil)
+	require.NoError(t, d.prePullImages(context.Background(), dependencies, services))
+
+	var pulls []string
+	for _, call := range runner.Calls() {
+		if len(call.Args) > 0 && call.Args[0] == "pull" {
+			pulls = append(pulls, call.String())
+		}
+	}
+	assert.ElementsMatch(t, []string{"docker pull postgres:16", "docker pull ghcr.io/acme/web:1.2"}, pulls)
+
+	// The images are not pulled again when the containers are replaced.
+	runner.Reset()
+	require.NoError(t, d.updateImage("shop", &services[1]))
+	assert.Empty(t, runner.Calls())
+}
+
+func TestPrePullImages_Failed(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker pull postgres:16", fake.Response{Err: errors.New("manifest unknown")})
+	d := NewDeployment(runner, nil)
+	d.dockerManager.SetPolicy(docker.Policy{})
+
+	err := d.prePullImages(context.Background(), []config.Dependency{{Name: "postgres", Image: "postgres:16"}}, nil)
+	assert.ErrorContains(t, err, "manifest unknown")
+}
+
+func TestCheckDigest(t *testing.T) {
+	const image = "postgres@sha256:4ac6d7f0f3be"
+
+	runner := fake.NewRunner()
+	runner.On("docker image inspect", fake.Response{Output: "postgres@sha256:4ac6d7f0f3be"})
+	require.NoError(t, NewDeployment(runner, nil).checkDigest(context.Background(), image))
+	require.NoError(t, NewDeployment(runner, nil).checkDigest(context.Background(), "postgres:16"))
+
+	runner = fake.NewRunner()
+	runner.On("docker image inspect", fake.Response{Output: "postgres@sha256:9e2b1a5c7d40"})
+	err := NewDeployment(runner, nil).checkDigest(context.Background(), image)
+	assert.EqualError(t, err, "image postgres@sha256:4ac6d7f0f3be was pulled with digests postgres@sha256:9e2b1a5c7d40 instead")
+}
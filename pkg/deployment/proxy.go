@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/yarlson/ftl/pkg/config"
 	"github.com/yarlson/ftl/pkg/proxy"
+	"github.com/yarlson/ftl/pkg/shell"
 )
 
 func (d *Deployment) startProxy(ctx context.Context, project string, cfg *config.Config) error {
@@ -18,14 +22,18 @@ func (d *Deployment) startProxy(ctx context.Context, project string, cfg *config
 		return fmt.Errorf("failed to prepare project folder: %w", err)
 	}
 
+	if err := d.backupProxyConfig(ctx, filepath.Join(projectPath, "nginx")); err != nil {
+		return err
+	}
+
 	// Prepare nginx config
 	configPath, err := d.prepareNginxConfig(cfg, projectPath)
 	if err != nil {
 		return fmt.Errorf("failed to prepare nginx config: %w", err)
 	}
 
-	if err := d.deployZero(project, cfg); err != nil {
-		return fmt.Errorf("failed to deploy Zero certificate manager: %w", err)
+	if err := d.deployCertificates(ctx, project, cfg); err != nil {
+		return err
 	}
 
 	service := &config.Service{
@@ -35,22 +43,57 @@ func (d *Deployment) startProxy(ctx context.Context, project string, cfg *config
 			"certs:/etc/nginx/certs:ro",
 			configPath + ":/etc/nginx/conf.d:ro",
 		},
-		Forwards: []string{
-			"443:443",
-		},
-		Container: &config.Container{
-			HealthCheck: &config.ContainerHealthCheck{
-				Cmd:      "curl -k https://localhost/",
-				Interval: "10s",
-				Retries:  3,
-				Timeout:  "5s",
-			},
-		},
 		Recreate: true,
 	}
 
+	healthCheck := config.ContainerHealthCheck{
+		Cmd:      "curl -k https://localhost/",
+		Interval: config.Duration(10 * time.Second),
+		Retries:  3,
+		Timeout:  config.Duration(5 * time.Second),
+	}
+
+	// On a shared server the shared proxy owns ports 80 and 443 and passes
+	// HTTPS connections on with the PROXY protocol.
+	if shared(cfg) {
+		healthCheck.Cmd = "curl -k --haproxy-protocol https://localhost/"
+	} else {
+		service.Forwards = append(service.Forwards, "443:443")
+		if !cfg.TLS.RedirectsHTTP() || !cfg.TLS.UsesACME() {
+			service.Forwards = append(service.Forwards, "80:80")
+		}
+	}
+	service.Container = &config.Container{HealthCheck: proxyHealthCheck(cfg, healthCheck)}
+
+	if proxy.HasStreams(cfg) {
+		service.Forwards = append(service.Forwards, streamForwards(cfg)...)
+		service.CommandSlice = []string{"nginx", "-g", "daemon off; include " + proxy.StreamConfigPath + ";"}
+	}
+
+	if cfg.Metrics != nil {
+		service.Volumes = append(service.Volumes, "metrics:"+proxy.MetricsDir+":ro")
+		service.Forwards = append(service.Forwards, metricsForward(cfg.Metrics))
+	}
+
+	if hasStaticServices(cfg) {
+		staticVolume, err := d.staticVolume(project)
+		if err != nil {
+			return fmt.Errorf("failed to prepare static volume: %w", err)
+		}
+		service.Volumes = append(service.Volumes, staticVolume)
+	}
+
 	if err := d.deployService(project, service); err != nil {
-		return fmt.Errorf("failed to deploy proxy service: %w", err)
+		return d.rollBackProxy(ctx, project, configPath, fmt.Errorf("failed to deploy proxy service: %w", err))
+	}
+
+	d.progress("Checking proxy...")
+	if err := d.verifyProxy(ctx, project); err != nil {
+		return d.rollBackProxy(ctx, project, configPath, err)
+	}
+
+	if shared(cfg) {
+		return d.deployEdge(ctx, project, cfg)
 	}
 
 	return nil
@@ -88,34 +131,165 @@ func (d *Deployment) prepareNginxConfig(cfg *config.Config, projectPath string)
 		return "", fmt.Errorf("failed to write nginx config to temporary file: %w", err)
 	}
 
+	if err := d.copyAuthFiles(cfg, configPath); err != nil {
+		return "", err
+	}
+
+	if proxy.HasStreams(cfg) {
+		if err := d.copyStreamConfig(cfg, configPath); err != nil {
+			return "", err
+		}
+	}
+
 	return configPath, d.runner.CopyFile(context.Background(), tmpFile.Name(), filepath.Join(configPath, "default.conf"))
 }
 
-func (d *Deployment) deployZero(project string, cfg *config.Config) error {
+// nginxGroup is the group of the nginx workers of the proxy image, which read
+// the user files of the auth middleware.
+const nginxGroup = "101"
+
+// copyAuthFiles replaces the user files of the auth middleware next to the
+// nginx configuration. They are staged in a new directory and renamed into
+// place one by one, so nginx never finds a user file missing, and only the
+// deploy user and the nginx workers can read them.
+func (d *Deployment) copyAuthFiles(cfg *config.Config, configPath string) error {
+	ctx := context.Background()
+	authPath := filepath.Join(configPath, proxy.AuthDir)
+	staging := authPath + ".ftl-new"
+	if _, err := d.runChecked(ctx, "sh", "-c", fmt.Sprintf("mkdir -p %[2]s && rm -rf %[1]s && mkdir -m 700 %[1]s", shell.Quote(staging), shell.Quote(authPath))); err != nil {
+		return fmt.Errorf("failed to create auth directory: %w", err)
+	}
+	defer func() { _, _ = d.runCommand(context.Background(), "rm", "-rf", staging) }()
+
+	files := proxy.AuthFiles(cfg)
+	names := make([]string, 0, len(files))
+	for name, content := range files {
+		tmpFile, err := os.CreateTemp("", "nginx-auth-*")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary file: %w", err)
+		}
+		_, err = tmpFile.WriteString(content)
+		_ = tmpFile.Close()
+		if err == nil {
+			err = d.runner.CopyFile(ctx, tmpFile.Name(), filepath.Join(staging, name))
+		}
+		_ = os.Remove(tmpFile.Name())
+		if err != nil {
+			return fmt.Errorf("failed to copy auth file: %w", err)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if err := d.secureAuthFiles(ctx, staging); err != nil {
+		return err
+	}
+
+	script := fmt.Sprintf("cd %s", shell.Quote(authPath))
+	for _, name := range names {
+		script += fmt.Sprintf(" && mv -f %s %s", shell.Quote(path.Join(staging, name)), shell.Quote(name))
+	}
+	script += " && find . -type f"
+	for _, name := range names {
+		script += " ! -name " + shell.Quote(name)
+	}
+	script += " -exec rm -f {} +"
+	if output, err := d.runChecked(ctx, "sh", "-c", script); err != nil {
+		return fmt.Errorf("failed to install auth files: %w\n\x1b[93mOutput:\x1b[0m\n\x1b[90m%s\x1b[0m", err, output)
+	}
+
+	return nil
+}
+
+// secureAuthFiles makes the user files in dir readable by their owner and the
+// nginx workers only. The group is changed from a container, as the deploy
+// user is not a member of it.
+func (d *Deployment) secureAuthFiles(ctx context.Context, dir string) error {
+	if output, err := d.runChecked(ctx, "docker", "run", "--rm", "-v", dir+":/auth", bindImage,
+		"sh", "-c", "chmod 750 /auth && find /auth -type f -exec chmod 640 {} + && chgrp -R "+nginxGroup+" /auth"); err != nil {
+		return fmt.Errorf("failed to set permissions of auth files: %w\n\x1b[93mOutput:\x1b[0m\n\x1b[90m%s\x1b[0m", err, output)
+	}
+	return nil
+}
+
+// copyStreamConfig writes the stream block next to the nginx configuration.
+func (d *Deployment) copyStreamConfig(cfg *config.Config, configPath string) error {
+	tmpFile, err := os.CreateTemp("", "nginx-stream-*.inc")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.WriteString(proxy.GenerateStreamConfig(cfg))
+	_ = tmpFile.Close()
+	if err == nil {
+		err = d.runner.CopyFile(context.Background(), tmpFile.Name(), filepath.Join(configPath, proxy.StreamConfig))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy stream config: %w", err)
+	}
+
+	return nil
+}
+
+// streamForwards returns the port mappings publishing the proxy ports of
+// service streams, once per port and protocol.
+func streamForwards(cfg *config.Config) []string {
+	var forwards []string
+	seen := make(map[string]bool)
+	for _, svc := range cfg.Services {
+		for _, stream := range svc.Streams {
+			if seen[stream.Listener()] {
+				continue
+			}
+			seen[stream.Listener()] = true
+			forward := fmt.Sprintf("%d:%d", stream.Port, stream.Port)
+			if stream.Network() == "udp" {
+				forward += "/udp"
+			}
+			forwards = append(forwards, forward)
+		}
+	}
+	return forwards
+}
+
+// deployZero deploys the certificate manager. It answers port 80, solving
+// ACME challenges and redirecting everything else to HTTPS, unless the TLS
+// policy serves plain HTTP or the server is shared, in which case the proxy
+// owns port 80 and forwards challenges to it.
+func (d *Deployment) deployZero(ctx context.Context, project string, cfg *config.Config) error {
 	service := &config.Service{
-		Name:  "zero",
+		Name:  proxy.ACMEUpstream,
 		Image: "yarlson/zero:1",
 		Volumes: []string{
 			"certs:/certs",
-			"/var/run/docker.sock:/var/run/docker.sock",
-		},
-		Forwards: []string{
-			"80:80",
-		},
-		CommandSlice: []string{
-			"-d",
-			cfg.Project.Domain,
-			"-e",
-			cfg.Project.Email,
-			"-c",
-			"/certs",
-			"--hook",
-			"nginx -s reload",
-			"--hook-container",
-			"proxy",
 		},
 		Recreate: true,
 	}
+	if cfg.TLS.RedirectsHTTP() && !shared(cfg) {
+		service.Forwards = []string{"80:80"}
+		if err := d.releaseHTTPPort(ctx, project); err != nil {
+			return err
+		}
+	}
+	withDockerAccess(service, cfg.Server)
+
+	for _, domain := range cfg.Domains() {
+		service.CommandSlice = append(service.CommandSlice, "-d", domain)
+	}
+	service.CommandSlice = append(service.CommandSlice,
+		"-e",
+		cfg.Project.Email,
+		"-c",
+		"/certs",
+		"--hook",
+		"nginx -s reload",
+		"--hook-container",
+		"proxy",
+	)
+	if cfg.TLS.CertificateMode() == config.TLSLetsEncryptStaging {
+		service.CommandSlice = append(service.CommandSlice, "--staging")
+	}
 
 	if err := d.deployService(project, service); err != nil {
 		return fmt.Errorf("failed to deploy certrenewer service: %w", err)
@@ -123,3 +297,18 @@ func (d *Deployment) deployZero(project string, cfg *config.Config) error {
 
 	return nil
 }
+
+// releaseHTTPPort removes the proxy container when it still publishes port 80
+// from a deploy that served plain HTTP, so the certificate manager can take
+// the port over. The proxy is recreated right after.
+func (d *Deployment) releaseHTTPPort(ctx context.Context, project string) error {
+	container := containerName(project, "proxy", "")
+	published, err := d.runCommand(ctx, "docker", "port", container, "80/tcp")
+	if err != nil || strings.TrimSpace(published) == "" {
+		return nil
+	}
+	if _, err := d.runCommand(ctx, "docker", "rm", "-f", container); err != nil {
+		return fmt.Errorf("failed to release port 80 from the proxy: %w", err)
+	}
+	return nil
+}
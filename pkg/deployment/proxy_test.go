@@ -0,0 +1,43 @@
+package deployment
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/proxy"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestCopyAuthFiles(t *testing.T) {
+	auth := &config.AuthMiddleware{Realm: "Admin", Users: []string{"admin:$apr1$O5ubtN1H$ZBnJ0sWh2Ft0IVy4MH3Ns1"}}
+	cfg := &config.Config{Services: []config.Service{{
+		Name:   "web",
+		Port:   80,
+		Routes: []config.Route{{PathPrefix: "/admin", Middleware: []config.Middleware{{Auth: auth}}}},
+	}}}
+	var name string
+	for file := range proxy.AuthFiles(cfg) {
+		name = file
+	}
+
+	runner := fake.NewRunner()
+	require.NoError(t, NewDeployment(runner, nil).copyAuthFiles(cfg, "/home/deploy/projects/shop/nginx"))
+
+	content, ok := runner.File("/home/deploy/projects/shop/nginx/htpasswd.ftl-new/" + name)
+	require.True(t, ok, "user files are staged next to the live ones")
+	assert.Equal(t, auth.Users[0]+"\n", string(content))
+
+	lines := callLines(runner)
+	require.Len(t, lines, 4)
+	assert.Equal(t, "sh -c mkdir -p '/home/deploy/projects/shop/nginx/htpasswd' && rm -rf '/home/deploy/projects/shop/nginx/htpasswd.ftl-new' && mkdir -m 700 '/home/deploy/projects/shop/nginx/htpasswd.ftl-new'", lines[0])
+	assert.Equal(t, "docker run --rm -v /home/deploy/projects/shop/nginx/htpasswd.ftl-new:/auth alpine:3 sh -c chmod 750 /auth && find /auth -type f -exec chmod 640 {} + && chgrp -R 101 /auth", lines[1])
+	assert.Equal(t, "sh -c cd '/home/deploy/projects/shop/nginx/htpasswd' && mv -f '/home/deploy/projects/shop/nginx/htpasswd.ftl-new/"+name+"' '"+name+"' && find . -type f ! -name '"+name+"' -exec rm -f {} +", lines[2])
+	assert.Equal(t, "rm -rf /home/deploy/projects/shop/nginx/htpasswd.ftl-new", lines[3])
+	for _, line := range lines {
+		assert.False(t, strings.Contains(line, "rm -rf '/home/deploy/projects/shop/nginx/htpasswd'"), "the live user files are never removed as a whole")
+	}
+}
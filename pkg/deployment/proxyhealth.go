@@ -0,0 +1,156 @@
+package deployment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/proxy"
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+// proxyLogLines is the number of lines of the proxy log shown when the proxy
+// is down.
+const proxyLogLines = 20
+
+// proxyConfigFiles are the files of the nginx configuration a deploy writes.
+// The maintenance folder next to them is left alone, since it is switched by
+// ftl maintenance.
+var proxyConfigFiles = []string{"default.conf", "stream.inc", "htpasswd"}
+
+// ErrProxyDown is wrapped by CheckProxy errors when the proxy container is not
+// running.
+var ErrProxyDown = errors.New("proxy is not running")
+
+// ErrProxyRolledBack is wrapped by deploy errors when the proxy failed with
+// the new configuration and was restored to the one it ran with before.
+var ErrProxyRolledBack = errors.New("restored the previous proxy configuration")
+
+// CheckProxy checks that the proxy of the project is running and accepts its
+// configuration. The error ends with the proxy log, or with the nginx error
+// for a configuration it rejects.
+func (d *Deployment) CheckProxy(ctx context.Context, project string) error {
+	container := containerName(project, "proxy", "")
+
+	status, _ := d.runCommand(ctx, "docker", "inspect", "--format={{.State.Status}}", container)
+	if status != "running" {
+		logs, _ := d.runCommand(ctx, "docker", "logs", "--tail", strconv.Itoa(proxyLogLines), container)
+		return proxyError(ErrProxyDown, "Proxy log", logs)
+	}
+
+	if output, err := d.runChecked(ctx, "docker", "exec", container, "nginx", "-t"); err != nil {
+		return proxyError(fmt.Errorf("proxy rejected its configuration: %w", err), "Output from nginx -t", output)
+	}
+
+	return nil
+}
+
+// HealProxy checks the proxy of the project like CheckProxy and starts it
+// again when it is not running. It reports whether the proxy was started; an
+// error means the proxy is still unhealthy.
+func (d *Deployment) HealProxy(ctx context.Context, project string) (bool, error) {
+	err := d.CheckProxy(ctx, project)
+	if !errors.Is(err, ErrProxyDown) {
+		return false, err
+	}
+
+	if _, err := d.runChecked(ctx, "docker", "start", containerName(project, "proxy", "")); err != nil {
+		return true, fmt.Errorf("failed to start proxy: %w", err)
+	}
+	return true, d.CheckProxy(ctx, project)
+}
+
+// verifyProxy checks the proxy after a deploy and reloads it, so a changed
+// configuration takes effect when the container itself was left as it was.
+func (d *Deployment) verifyProxy(ctx context.Context, project string) error {
+	if err := d.CheckProxy(ctx, project); err != nil {
+		return err
+	}
+
+	if output, err := d.runChecked(ctx, "docker", "exec", containerName(project, "proxy", ""), "nginx", "-s", "reload"); err != nil {
+		return proxyError(fmt.Errorf("proxy failed to reload its configuration: %w", err), "Output from nginx -s reload", output)
+	}
+	return nil
+}
+
+// backupProxyConfig copies the nginx configuration the proxy runs with aside,
+// before a deploy replaces it.
+func (d *Deployment) backupProxyConfig(ctx context.Context, configPath string) error {
+	script := fmt.Sprintf("rm -rf %[2]s && mkdir -p %[1]s %[2]s && cd %[1]s && for f in %[3]s; do [ ! -e \"$f\" ] || cp -a \"$f\" %[2]s/; done",
+		shell.Quote(configPath), shell.Quote(configPath+".previous"), strings.Join(proxyConfigFiles, " "))
+	if output, err := d.runChecked(ctx, "sh", "-c", script); err != nil {
+		return proxyError(fmt.Errorf("failed to back up proxy configuration: %w", err), "Output from the backup", output)
+	}
+	return nil
+}
+
+// rollBackProxy restores the configuration backed up by backupProxyConfig
+// after the proxy failed with the new one, and restarts the proxy with it.
+// The returned error wraps err, and ErrProxyRolledBack when the proxy is
+// healthy again; a restore that fails is reported along with err.
+func (d *Deployment) rollBackProxy(ctx context.Context, project, configPath string, err error) error {
+	d.progress("Proxy failed, restoring its previous configuration...")
+
+	// The files are restored in place: the proxy mounts the directory, so it
+	// would not see a directory moved over it.
+	script := fmt.Sprintf("[ -f %[2]s/default.conf ] || exit 0; cd %[1]s && rm -rf %[3]s && cp -a %[2]s/. . && echo restored",
+		shell.Quote(configPath), shell.Quote(configPath+".previous"), strings.Join(proxyConfigFiles, " "))
+	restored, restoreErr := d.runChecked(ctx, "sh", "-c", script)
+	if restoreErr != nil {
+		return proxyError(fmt.Errorf("%w (failed to restore the previous proxy configuration: %v)", err, restoreErr), "Output from the restore", restored)
+	}
+	if restored != "restored" {
+		return err
+	}
+	// The copies belong to the deploy user's group, which nginx cannot read.
+	authPath := filepath.Join(configPath, proxy.AuthDir)
+	if _, testErr := d.runChecked(ctx, "test", "-d", authPath); testErr == nil {
+		if secureErr := d.secureAuthFiles(ctx, authPath); secureErr != nil {
+			return err
+		}
+	}
+
+	if _, restartErr := d.runChecked(ctx, "docker", "restart", containerName(project, "proxy", "")); restartErr != nil {
+		return err
+	}
+	if d.CheckProxy(ctx, project) != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrProxyRolledBack, err)
+}
+
+// proxyHealthCheck returns the health check of the proxy container: the one
+// of the proxy section of cfg, with the settings it leaves out taken from
+// check.
+func proxyHealthCheck(cfg *config.Config, check config.ContainerHealthCheck) *config.ContainerHealthCheck {
+	if cfg.Proxy == nil || cfg.Proxy.HealthCheck == nil {
+		return &check
+	}
+
+	custom := *cfg.Proxy.HealthCheck
+	if custom.Cmd == "" {
+		custom.Cmd = check.Cmd
+	}
+	if custom.Interval == 0 {
+		custom.Interval = check.Interval
+	}
+	if custom.Retries == 0 {
+		custom.Retries = check.Retries
+	}
+	if custom.Timeout == 0 {
+		custom.Timeout = check.Timeout
+	}
+	return &custom
+}
+
+// proxyError appends output, under title, to err.
+func proxyError(err error, title, output string) error {
+	if output == "" {
+		return err
+	}
+	return fmt.Errorf("%w\n\x1b[93m%s:\x1b[0m\n\x1b[90m%s\x1b[0m", err, title, output)
+}
@@ -0,0 +1,128 @@
+package deployment
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestCheckProxy(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker inspect --format={{.State.Status}} shop-proxy", fake.Response{Output: "running"})
+	require.NoError(t, NewDeployment(runner, nil).CheckProxy(context.Background(), "shop"))
+	assert.Equal(t, "docker exec shop-proxy nginx -t", runner.Calls()[1].String())
+
+	runner.On("docker exec shop-proxy nginx -t", fake.Response{Err: errors.New("exit status 1")})
+	err := NewDeployment(runner, nil).CheckProxy(context.Background(), "shop")
+	assert.EqualError(t, err, "proxy rejected its configuration: exit status 1")
+
+	runner = fake.NewRunner()
+	runner.On("docker inspect --format={{.State.Status}} shop-proxy", fake.Response{Output: "restarting"})
+	runner.On("docker logs --tail 20 shop-proxy", fake.Response{Output: `nginx: [emerg] host not found in upstream "web"`})
+	err = NewDeployment(runner, nil).CheckProxy(context.Background(), "shop")
+	require.ErrorIs(t, err, ErrProxyDown)
+	assert.Contains(t, err.Error(), `host not found in upstream "web"`)
+}
+
+func TestHealProxy(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker inspect --format={{.State.Status}} shop-proxy", fake.Response{Output: "exited"})
+	started, err := NewDeployment(runner, nil).HealProxy(context.Background(), "shop")
+	assert.True(t, started)
+	assert.ErrorIs(t, err, ErrProxyDown)
+
+	var lines []string
+	for _, call := range runner.Calls() {
+		lines = append(lines, call.String())
+	}
+	assert.Contains(t, lines, "docker start shop-proxy")
+
+	runner = fake.NewRunner()
+	runner.On("docker inspect --format={{.State.Status}} shop-proxy", fake.Response{Output: "running"})
+	started, err = NewDeployment(runner, nil).HealProxy(context.Background(), "shop")
+	assert.False(t, started)
+	assert.NoError(t, err)
+}
+
+func TestStartProxy_RollBack(t *testing.T) {
+	cfg := &config.Config{
+		Project: config.Project{Name: "shop", Domain: "shop.example.com", Email: "admin@example.com"},
+		Server:  &config.Server{Host: "example.com"},
+	}
+
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("docker network inspect", fake.Response{Output: "[]"})
+	runner.On("docker inspect --format={{.State.Status}} shop-proxy", fake.Response{Output: "running"})
+	runner.On("docker exec shop-proxy nginx -t", fake.Response{Err: errors.New("exit status 1")})
+	err := NewDeployment(runner, nil).startProxy(context.Background(), "shop", cfg)
+	require.EqualError(t, err, "proxy rejected its configuration: exit status 1")
+	assert.NotErrorIs(t, err, ErrProxyRolledBack)
+
+	var lines []string
+	for _, call := range runner.Calls() {
+		lines = append(lines, call.String())
+	}
+	script := strings.Join(lines, "\n")
+	assert.Contains(t, script, "sh -c rm -rf '/home/deploy/projects/shop/nginx.previous' && mkdir -p '/home/deploy/projects/shop/nginx' '/home/deploy/projects/shop/nginx.previous'")
+	assert.Contains(t, script, "sh -c [ -f '/home/deploy/projects/shop/nginx.previous'/default.conf ] || exit 0; cd '/home/deploy/projects/shop/nginx' && rm -rf default.conf stream.inc htpasswd")
+	assert.NotContains(t, lines, "docker exec shop-proxy nginx -s reload")
+}
+
+func TestRollBackProxy(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("sh -c [ -f '/home/deploy/projects/shop/nginx.previous'/default.conf ]", fake.Response{Output: "restored"})
+	runner.On("docker inspect --format={{.State.Status}} shop-proxy", fake.Response{Output: "running"})
+	cause := errors.New("proxy failed to reload its configuration")
+	err := NewDeployment(runner, nil).rollBackProxy(context.Background(), "shop", "/home/deploy/projects/shop/nginx", cause)
+	assert.ErrorIs(t, err, ErrProxyRolledBack)
+	assert.ErrorIs(t, err, cause)
+	lines := callLines(runner)
+	assert.Contains(t, lines, "docker run --rm -v /home/deploy/projects/shop/nginx/htpasswd:/auth alpine:3 sh -c chmod 750 /auth && find /auth -type f -exec chmod 640 {} + && chgrp -R 101 /auth",
+		"restored user files are made readable by nginx again")
+	assert.Contains(t, lines, "docker restart shop-proxy")
+
+	// Without a backup, from the first deploy, there is nothing to restore.
+	runner = fake.NewRunner()
+	err = NewDeployment(runner, nil).rollBackProxy(context.Background(), "shop", "/home/deploy/projects/shop/nginx", cause)
+	assert.Equal(t, cause, err)
+	assert.Len(t, runner.Calls(), 1)
+
+	// A failed restore is reported instead of passing for a rollback.
+	runner = fake.NewRunner()
+	runner.On("sh -c [ -f '/home/deploy/projects/shop/nginx.previous'/default.conf ]", fake.Response{Output: "cp: can't create 'default.conf': No space left on device", ExitCode: 1})
+	err = NewDeployment(runner, nil).rollBackProxy(context.Background(), "shop", "/home/deploy/projects/shop/nginx", cause)
+	assert.ErrorIs(t, err, cause)
+	assert.NotErrorIs(t, err, ErrProxyRolledBack)
+	assert.ErrorContains(t, err, "failed to restore the previous proxy configuration: command failed: exit status 1")
+	assert.ErrorContains(t, err, "No space left on device")
+	assert.NotContains(t, callLines(runner), "docker restart shop-proxy")
+}
+
+func TestBackupProxyConfig_Failure(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("sh -c rm -rf", fake.Response{Output: "cp: can't stat 'default.conf': Permission denied", ExitCode: 1})
+	err := NewDeployment(runner, nil).backupProxyConfig(context.Background(), "/home/deploy/projects/shop/nginx")
+	assert.ErrorContains(t, err, "failed to back up proxy configuration")
+	assert.ErrorContains(t, err, "Permission denied")
+}
+
+func TestProxyHealthCheck(t *testing.T) {
+	check := config.ContainerHealthCheck{Cmd: "curl -k https://localhost/", Interval: config.Duration(10 * time.Second), Retries: 3}
+	assert.Equal(t, &check, proxyHealthCheck(&config.Config{}, check))
+
+	cfg := &config.Config{Proxy: &config.Proxy{HealthCheck: &config.ContainerHealthCheck{Cmd: "curl -kf https://localhost/healthz"}}}
+	assert.Equal(t, &config.ContainerHealthCheck{
+		Cmd:      "curl -kf https://localhost/healthz",
+		Interval: config.Duration(10 * time.Second),
+		Retries:  3,
+	}, proxyHealthCheck(cfg, check))
+}
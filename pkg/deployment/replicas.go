@@ -0,0 +1,113 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/docker"
+)
+
+// replicas returns the additional replicas of a service. The first replica is
+// the service itself; each other one is a copy named after its index that
+// joins the service alias. Hooks and migrations run once per service, so the
+// copies do not carry them.
+func replicas(service *config.Service) []*config.Service {
+	var copies []*config.Service
+	for i := 1; i < service.ReplicaCount(); i++ {
+		replica := *service
+		replica.Name = service.ReplicaName(i)
+		replica.ReplicaOf = service.Name
+		replica.Replicas = 0
+		replica.Hooks = nil
+		replica.Migrations = nil
+		copies = append(copies, &replica)
+	}
+	return copies
+}
+
+// replicaNames returns the names of the containers of every replica of service.
+func replicaNames(service *config.Service) []string {
+	names := make([]string, 0, service.ReplicaCount())
+	for i := 0; i < service.ReplicaCount(); i++ {
+		names = append(names, service.ReplicaName(i))
+	}
+	return names
+}
+
+// stopReplicas stops the additional replicas of a service deployed with the
+// replace strategy when its containers are about to be replaced, so old and
+// new containers never run at the same time. The replicas are replaced once
+// the first one is.
+func (d *Deployment) stopReplicas(project string, service *config.Service) error {
+	if service.ReplicaCount() < 2 {
+		return nil
+	}
+	status, err := d.dockerManager.GetContainerStatus(docker.ServiceNetwork(project, service), service.Name)
+	if err != nil || status == docker.ContainerStatusNotFound {
+		return err
+	}
+	update, err := d.dockerManager.ContainerNeedsUpdate(docker.ServiceNetwork(project, service), service)
+	if err != nil || !update {
+		return err
+	}
+
+	for _, replica := range replicas(service) {
+		name := containerName(project, replica.Name, "")
+		running, err := d.runCommand(context.Background(), "docker", "ps", "-q", "--filter", fmt.Sprintf("name=^%s$", name))
+		if err != nil {
+			return fmt.Errorf("failed to look up replica %s: %w", name, err)
+		}
+		if running == "" {
+			continue
+		}
+		d.progress(fmt.Sprintf("Stopping replica %s...", name))
+		if output, err := d.runChecked(context.Background(), "docker", stopArgs(service, name)...); err != nil {
+			return outputError(fmt.Errorf("failed to stop replica %s: %w", name, err), output)
+		}
+	}
+	return nil
+}
+
+// deployReplicas rolls the additional replicas of service one at a time, after
+// the first one was deployed, and removes the replicas left over from a larger
+// replica count.
+func (d *Deployment) deployReplicas(project string, service *config.Service) error {
+	for _, replica := range replicas(service) {
+		replica.ImageUpdated = service.ImageUpdated
+		d.progress(fmt.Sprintf("Deploying replica %s...", replica.Name))
+		if err := d.deployContainer(project, replica); err != nil {
+			return fmt.Errorf("failed to deploy replica %s: %w", replica.Name, err)
+		}
+	}
+
+	output, err := d.runCommand(context.Background(), "docker", "ps", "-a",
+		"--filter", fmt.Sprintf("network=%s", project),
+		"--filter", fmt.Sprintf("label=%s=%s", docker.ReplicaOfLabel, service.Name),
+		"--format", "{{.Names}}",
+	)
+	if err != nil {
+		return fmt.Errorf("failed to list replicas of %s: %w", service.Name, err)
+	}
+
+	var keep []string
+	for _, name := range replicaNames(service) {
+		keep = append(keep, containerName(project, name, ""))
+	}
+	for _, name := range strings.Fields(output) {
+		if slices.Contains(keep, name) {
+			continue
+		}
+		d.progress(fmt.Sprintf("Removing replica %s...", name))
+		if output, err := d.runChecked(context.Background(), "docker", stopArgs(service, name)...); err != nil {
+			return outputError(fmt.Errorf("failed to stop replica %s: %w", name, err), output)
+		}
+		if output, err := d.runChecked(context.Background(), "docker", "rm", name); err != nil {
+			return outputError(fmt.Errorf("failed to remove replica %s: %w", name, err), output)
+		}
+	}
+
+	return nil
+}
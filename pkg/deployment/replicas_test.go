@@ -0,0 +1,86 @@
+package deployment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestReplicas(t *testing.T) {
+	service := &config.Service{
+		Name:     "web",
+		Replicas: 3,
+		Hooks:    &config.Hooks{Pre: &config.HookItem{Remote: "migrate"}},
+	}
+
+	copies := replicas(service)
+	assert.Len(t, copies, 2)
+	assert.Equal(t, "web-2", copies[0].Name)
+	assert.Equal(t, "web-3", copies[1].Name)
+	for _, replica := range copies {
+		assert.Equal(t, "web", replica.ReplicaOf)
+		assert.Nil(t, replica.Hooks)
+	}
+	assert.Equal(t, []string{"web", "web-2", "web-3"}, replicaNames(service))
+
+	assert.Empty(t, replicas(&config.Service{Name: "web"}))
+	assert.Equal(t, []string{"web"}, replicaNames(&config.Service{Name: "web"}))
+}
+
+func TestStopReplicas(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker ps -aq --filter network=project", fake.Response{Output: "abc123"})
+	runner.On("docker inspect abc123", fake.Response{Output: `[{"Id":"abc123","Image":"sha256:old","State":{"Status":"running"},"NetworkSettings":{"Networks":{"project":{"Aliases":["worker"]}}}}]`})
+	runner.On("docker inspect --format={{.Id}} worker:latest", fake.Response{Output: "sha256:new"})
+	runner.On("docker ps -q --filter name=^project-worker-2$", fake.Response{Output: "def456"})
+	service := &config.Service{Name: "worker", Image: "worker:latest", Replicas: 3, Strategy: config.StrategyReplace}
+
+	require.NoError(t, NewDeployment(runner, nil).stopReplicas("project", service))
+
+	var calls []string
+	for _, call := range runner.Calls() {
+		calls = append(calls, call.String())
+	}
+	assert.Contains(t, calls, "docker stop project-worker-2")
+	assert.Contains(t, calls, "docker ps -q --filter name=^project-worker-3$")
+	assert.NotContains(t, calls, "docker stop project-worker-3", "a replica that is not running is left alone")
+
+	hash, err := service.Hash()
+	require.NoError(t, err)
+	runner.Reset()
+	runner.On("docker ps -aq --filter network=project", fake.Response{Output: "abc123"})
+	runner.On("docker inspect abc123", fake.Response{Output: `[{"Id":"abc123","Image":"sha256:new","State":{"Status":"running"},"Config":{"Labels":{"ftl.config-hash":"` + hash + `"}},"NetworkSettings":{"Networks":{"project":{"Aliases":["worker"]}}}}]`})
+	runner.On("docker inspect --format={{.Id}} worker:latest", fake.Response{Output: "sha256:new"})
+	require.NoError(t, NewDeployment(runner, nil).stopReplicas("project", service))
+	for _, call := range runner.Calls() {
+		assert.NotContains(t, call.String(), "docker stop", "replicas of an unchanged service keep running")
+	}
+}
+
+func TestDeployReplicas_RemoveSurplus(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker ps -a --filter network=project", fake.Response{Output: "project-web\nproject-web-2"})
+	service := &config.Service{Name: "web", Image: "web:latest"}
+
+	require.NoError(t, NewDeployment(runner, nil).deployReplicas("project", service))
+	var calls []string
+	for _, call := range runner.Calls() {
+		calls = append(calls, call.String())
+	}
+	assert.Contains(t, calls, "docker rm project-web-2")
+	assert.NotContains(t, calls, "docker rm project-web")
+
+	runner.Reset()
+	runner.On("docker ps -a --filter network=project", fake.Response{Output: "project-web\nproject-web-2"})
+	runner.On("docker stop project-web-2", fake.Response{Output: "Error response from daemon: cannot stop container", ExitCode: 1})
+	err := NewDeployment(runner, nil).deployReplicas("project", service)
+	assert.ErrorContains(t, err, "failed to stop replica project-web-2")
+	assert.ErrorContains(t, err, "cannot stop container")
+	for _, call := range runner.Calls() {
+		assert.NotEqual(t, "docker rm project-web-2", call.String(), "a replica that failed to stop is not removed")
+	}
+}
@@ -0,0 +1,66 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yarlson/pin"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/docker"
+)
+
+// Restart replaces the containers of the services with new containers started
+// from the images the running ones were created from, health-checked and one
+// replica at a time like a deploy, so the new containers pick up the current
+// environment. Nothing is built or pulled, and hooks and migrations do not
+// run. When services is nil every service is restarted.
+func (d *Deployment) Restart(ctx context.Context, project string, cfg *config.Config, spinner *pin.Pin, services []string) error {
+	d.spinner = spinner
+
+	selected := cfg.Services
+	if services != nil {
+		selected = filterServices(cfg.Services, services)
+	}
+
+	for _, service := range selected {
+		if service.Static != nil {
+			continue
+		}
+		service.Hooks = nil
+		service.Migrations = nil
+
+		for _, replica := range append([]*config.Service{&service}, replicas(&service)...) {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			d.progress(fmt.Sprintf("Restarting %s...", replica.Name))
+			if err := d.restartContainer(project, replica); err != nil {
+				return fmt.Errorf("failed to restart %s: %w", replica.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *Deployment) restartContainer(project string, service *config.Service) error {
+	status, err := d.dockerManager.GetContainerStatus(docker.ServiceNetwork(project, service), service.Name)
+	if err != nil {
+		return err
+	}
+	if status == docker.ContainerStatusNotFound {
+		return fmt.Errorf("%s is not deployed", service.Name)
+	}
+
+	// The tag may point to a newer image by now, so the new container starts
+	// from the image the running one was created from.
+	details, err := d.dockerManager.InspectContainer(docker.ServiceNetwork(project, service), service.Name)
+	if err != nil {
+		return err
+	}
+	service.PinImage(details.Image)
+
+	return d.updateService(project, service)
+}
@@ -0,0 +1,46 @@
+package deployment
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestRestart_NotDeployed(t *testing.T) {
+	runner := fake.NewRunner()
+	cfg := &config.Config{
+		Services: []config.Service{
+			{Name: "web", Port: 80},
+			{Name: "site", Static: &config.Static{}},
+		},
+	}
+
+	err := NewDeployment(runner, nil).Restart(context.Background(), "project", cfg, nil, nil)
+
+	assert.EqualError(t, err, "failed to restart web: web is not deployed")
+	for _, call := range runner.Calls() {
+		assert.NotContains(t, call.String(), "docker run", "nothing is started for a service that is not deployed")
+	}
+}
+
+func TestRestart_RunningImage(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker ps -aq", fake.Response{Output: "c1\n"})
+	runner.On("docker inspect c1", fake.Response{Output: `[{"Id": "c1", "Image": "sha256:0123", "Config": {"Image": "ghcr.io/acme/web:latest"}, "State": {"Status": "running"}, "NetworkSettings": {"Networks": {"project": {"Aliases": ["web"]}}}}]`})
+	cfg := &config.Config{Services: []config.Service{{Name: "web", Image: "ghcr.io/acme/web:latest", Port: 80, Strategy: config.StrategyReplace}}}
+
+	hash, err := cfg.Services[0].Hash()
+	require.NoError(t, err)
+
+	require.NoError(t, NewDeployment(runner, nil).Restart(context.Background(), "project", cfg, nil, nil))
+	calls := runner.Calls()
+	run := calls[len(calls)-1].String()
+	assert.True(t, strings.HasSuffix(run, " sha256:0123"), "the new container runs the image of the old one, not the tag: %s", run)
+	assert.Contains(t, run, "--label ftl.config-hash="+hash)
+}
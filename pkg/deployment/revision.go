@@ -0,0 +1,49 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/docker"
+)
+
+// tagRevision tags the image of service on the server with the commit it is
+// deployed from, as "ghcr.io/acme/web:1a2b3c4", so the images of earlier
+// deploys can be told apart.
+func (d *Deployment) tagRevision(project string, service *config.Service) error {
+	if service.Revision.Commit == "" {
+		return nil
+	}
+
+	image := service.Image
+	if image == "" {
+		image = fmt.Sprintf("%s-%s", project, service.Name)
+	}
+
+	tag := docker.Repository(image) + ":" + service.Revision.String()
+	if output, err := d.runChecked(context.Background(), "docker", "tag", image, tag); err != nil {
+		return outputError(fmt.Errorf("failed to tag image %s as %s: %w", image, tag, err), output)
+	}
+
+	return nil
+}
+
+// Revisions returns the commit the running container of every service of
+// cfg was deployed from, keyed by service. Services without a container, or
+// whose container was deployed without a commit, are left out.
+func (d *Deployment) Revisions(ctx context.Context, project string, cfg *config.Config) map[string]string {
+	revisions := map[string]string{}
+	for _, service := range cfg.Services {
+		if service.Static != nil {
+			continue
+		}
+		revision, err := d.runCommand(ctx, "docker", "inspect", "-f", fmt.Sprintf(`{{index .Config.Labels %q}}`, docker.RevisionLabel), containerName(project, service.Name, ""))
+		if err != nil || revision == "" || revision == "<no value>" {
+			continue
+		}
+		revisions[service.Name] = revision
+	}
+
+	return revisions
+}
@@ -0,0 +1,55 @@
+package deployment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestTagRevision(t *testing.T) {
+	runner := fake.NewRunner()
+	d := NewDeployment(runner, nil)
+
+	require.NoError(t, d.tagRevision("project", &config.Service{Name: "web"}))
+	assert.Empty(t, runner.Calls(), "nothing is tagged without a commit")
+
+	revision := config.Revision{Commit: "1a2b3c4", Dirty: true}
+	require.NoError(t, d.tagRevision("project", &config.Service{Name: "web", Revision: revision}))
+	require.NoError(t, d.tagRevision("project", &config.Service{Name: "api", Image: "ghcr.io/acme/api@sha256:abc", Revision: config.Revision{Commit: "1a2b3c4"}}))
+
+	var calls []string
+	for _, call := range runner.Calls() {
+		calls = append(calls, call.String())
+	}
+	assert.Equal(t, []string{
+		"docker tag project-web project-web:1a2b3c4-dirty",
+		"docker tag ghcr.io/acme/api@sha256:abc ghcr.io/acme/api:1a2b3c4",
+	}, calls)
+}
+
+func TestTagRevision_Failure(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker tag", fake.Response{Output: "Error response from daemon: No such image: project-web:latest", ExitCode: 1})
+
+	err := NewDeployment(runner, nil).tagRevision("project", &config.Service{Name: "web", Revision: config.Revision{Commit: "1a2b3c4"}})
+	assert.ErrorContains(t, err, "failed to tag image project-web as project-web:1a2b3c4")
+	assert.ErrorContains(t, err, "No such image")
+}
+
+func TestRevisions(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On(`docker inspect -f {{index .Config.Labels "ftl.git-sha"}} project-web`, fake.Response{Output: "1a2b3c4\n"})
+	runner.On(`docker inspect -f {{index .Config.Labels "ftl.git-sha"}} project-api`, fake.Response{Output: ""})
+
+	cfg := &config.Config{Services: []config.Service{
+		{Name: "web"},
+		{Name: "api"},
+		{Name: "docs", Static: &config.Static{}},
+	}}
+	assert.Equal(t, map[string]string{"web": "1a2b3c4"}, NewDeployment(runner, nil).Revisions(context.Background(), "project", cfg))
+}
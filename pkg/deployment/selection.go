@@ -0,0 +1,95 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/docker"
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+// SelectServices resolves the services named on the command line. Names in
+// only are deployed, names in skip are left out. It returns nil, meaning all
+// services, when neither is given.
+func SelectServices(cfg *config.Config, only, skip []string) ([]string, error) {
+	if len(only) == 0 && len(skip) == 0 {
+		return nil, nil
+	}
+
+	known := make([]string, 0, len(cfg.Services))
+	for _, service := range cfg.Services {
+		known = append(known, service.Name)
+	}
+	for _, name := range append(slices.Clone(only), skip...) {
+		if !slices.Contains(known, name) {
+			return nil, fmt.Errorf("unknown service %q, available services: %s", name, strings.Join(known, ", "))
+		}
+	}
+
+	var selected []string
+	for _, name := range known {
+		if len(only) > 0 && !slices.Contains(only, name) {
+			continue
+		}
+		if slices.Contains(skip, name) {
+			continue
+		}
+		selected = append(selected, name)
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no services left to deploy")
+	}
+
+	return selected, nil
+}
+
+// filterServices returns the services of cfg whose names are in names.
+func filterServices(services []config.Service, names []string) []config.Service {
+	var filtered []config.Service
+	for _, service := range services {
+		if slices.Contains(names, service.Name) {
+			filtered = append(filtered, service)
+		}
+	}
+	return filtered
+}
+
+// proxyConfig returns the configuration the proxy is generated from when only
+// some services are deployed: the selected services plus every other service
+// that is already running. Services that were never deployed are left out so
+// nginx does not fail to resolve their upstreams.
+func (d *Deployment) proxyConfig(ctx context.Context, project string, cfg *config.Config, selected []string) (*config.Config, error) {
+	staticPath, err := d.staticFolder(project)
+	if err != nil {
+		return nil, err
+	}
+
+	proxyCfg := *cfg
+	proxyCfg.Services = nil
+	for _, service := range cfg.Services {
+		deployed := slices.Contains(selected, service.Name)
+		if !deployed && service.Static != nil {
+			output, err := d.runCommand(ctx, "sh", "-c", fmt.Sprintf("test -e %s && echo deployed || true", shell.Quote(filepath.Join(staticPath, service.Name, "current"))))
+			if err != nil {
+				return nil, fmt.Errorf("failed to check static service %s: %w", service.Name, err)
+			}
+			deployed = output == "deployed"
+		} else if !deployed {
+			status, err := d.dockerManager.GetContainerStatus(project, service.Name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check service %s: %w", service.Name, err)
+			}
+			deployed = status != docker.ContainerStatusNotFound
+		}
+
+		if deployed {
+			proxyCfg.Services = append(proxyCfg.Services, service)
+		}
+	}
+
+	return &proxyCfg, nil
+}
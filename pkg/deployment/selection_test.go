@@ -0,0 +1,38 @@
+package deployment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+)
+
+func TestSelectServices(t *testing.T) {
+	cfg := &config.Config{
+		Services: []config.Service{{Name: "web"}, {Name: "api"}, {Name: "worker"}},
+	}
+
+	selected, err := SelectServices(cfg, nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, selected)
+
+	selected, err = SelectServices(cfg, []string{"worker", "web"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web", "worker"}, selected)
+
+	selected, err = SelectServices(cfg, nil, []string{"api"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web", "worker"}, selected)
+
+	selected, err = SelectServices(cfg, []string{"web", "api"}, []string{"api"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web"}, selected)
+
+	_, err = SelectServices(cfg, []string{"db"}, nil)
+	assert.ErrorContains(t, err, `unknown service "db"`)
+
+	_, err = SelectServices(cfg, []string{"web"}, []string{"web"})
+	assert.ErrorContains(t, err, "no services left")
+}
@@ -2,13 +2,18 @@ package deployment
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/yarlson/ftl/pkg/docker"
+	"math"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/telemetry"
 )
 
 func (d *Deployment) deployServices(ctx context.Context, project string, services []config.Service) error {
@@ -20,10 +25,25 @@ func (d *Deployment) deployServices(ctx context.Context, project string, service
 		go func(service config.Service) {
 			defer wg.Done()
 
-			if err := d.deployService(project, &service); err != nil {
-				errChan <- fmt.Errorf("failed to deploy service %s: %w", service.Name, err)
+			d.emit(Event{Type: EventServiceStarted, Service: service.Name, Message: "Deploying " + service.Name})
+			ctx, span := d.startSpan(ctx, "deploy "+service.Name, &service)
+
+			var err error
+			defer func() { telemetry.End(span, err) }()
+			if service.Static != nil {
+				if err = d.deployStatic(ctx, project, &service); err != nil {
+					err = fmt.Errorf("failed to deploy static service %s: %w", service.Name, err)
+				}
+			} else if err = d.deployService(project, &service); err != nil {
+				err = fmt.Errorf("failed to deploy service %s: %w", service.Name, err)
+			}
+
+			if err != nil {
+				d.emit(Event{Type: EventServiceFailed, Service: service.Name, Message: err.Error(), Err: err})
+				errChan <- err
 				return
 			}
+			d.emit(Event{Type: EventServiceDeployed, Service: service.Name, Message: service.Name + " deployed"})
 		}(service)
 	}
 
@@ -36,7 +56,7 @@ func (d *Deployment) deployServices(ctx context.Context, project string, service
 	}
 
 	if len(errs) > 0 {
-		return fmt.Errorf("errors occurred during service deployment: %v", errs)
+		return fmt.Errorf("errors occurred during service deployment: %w", errors.Join(errs...))
 	}
 
 	return nil
@@ -47,29 +67,61 @@ func (d *Deployment) deployService(project string, service *config.Service) erro
 	if err != nil {
 		return err
 	}
+	if err := d.tagRevision(project, service); err != nil {
+		return err
+	}
+	if err := d.uploadFiles(context.Background(), project, service); err != nil {
+		return err
+	}
+	if err := d.createBindMounts(context.Background(), service); err != nil {
+		return err
+	}
+
+	if service.DeployStrategy() == config.StrategyReplace {
+		if err := d.stopReplicas(project, service); err != nil {
+			return err
+		}
+	}
+
+	if err := d.deployContainer(project, service); err != nil {
+		return err
+	}
 
-	containerStatus, err := d.dockerManager.GetContainerStatus(project, service.Name)
+	return d.deployReplicas(project, service)
+}
+
+// deployContainer installs, updates or starts the container of a single
+// replica of service.
+func (d *Deployment) deployContainer(project string, service *config.Service) error {
+	containerStatus, err := d.dockerManager.GetContainerStatus(docker.ServiceNetwork(project, service), service.Name)
 	if err != nil {
 		return err
 	}
 
 	if containerStatus == docker.ContainerStatusNotFound {
-		if err := d.installService(project, service); err != nil {
-			return fmt.Errorf("failed to install service %s: %w", service.Name, err)
+		if err := d.removeMovedContainer(project, service); err != nil {
+			return err
 		}
-		return nil
+		return d.withMigrations(project, service, func() error {
+			if err := d.installService(project, service); err != nil {
+				return fmt.Errorf("failed to install service %s: %w", service.Name, err)
+			}
+			return nil
+		})
 	}
 
-	containerShouldBeUpdated, err := d.dockerManager.ContainerNeedsUpdate(project, service)
+	containerShouldBeUpdated, err := d.dockerManager.ContainerNeedsUpdate(docker.ServiceNetwork(project, service), service)
 	if err != nil {
 		return err
 	}
 
 	if containerShouldBeUpdated {
-		if err := d.updateService(project, service); err != nil {
-			return fmt.Errorf("failed to update service %s due to image change: %w", service.Name, err)
-		}
-		return nil
+		return d.withMigrations(project, service, func() error {
+			if err := d.updateService(project, service); err != nil {
+				return fmt.Errorf("failed to update service %s due to image change: %w", service.Name, err)
+			}
+			return nil
+		})
 	}
 
 	if containerStatus == docker.ContainerStatusStopped {
@@ -83,6 +135,30 @@ func (d *Deployment) deployService(project string, service *config.Service) erro
 	return nil
 }
 
+// removeMovedContainer removes the container of service when it exists on
+// another network, as after the service joined or left a private network, so
+// a new one can take its name. Volumes are kept.
+func (d *Deployment) removeMovedContainer(project string, service *config.Service) error {
+	container := containerName(project, service.Name, "")
+	output, err := d.runCommand(context.Background(), "docker", "ps", "-aq", "--filter", fmt.Sprintf("name=^%s$", container))
+	if err != nil {
+		return fmt.Errorf("failed to look up container %s: %w", container, err)
+	}
+	if output == "" {
+		return nil
+	}
+
+	d.progress(fmt.Sprintf("Moving %s to network %s...", service.Name, docker.ServiceNetwork(project, service)))
+	if _, err := d.runCommand(context.Background(), "docker", stopArgs(service, container)...); err != nil {
+		return fmt.Errorf("failed to stop container %s: %w", container, err)
+	}
+	if _, err := d.runCommand(context.Background(), "docker", "rm", container); err != nil {
+		return fmt.Errorf("failed to remove container %s: %w", container, err)
+	}
+
+	return nil
+}
+
 func (d *Deployment) installService(project string, service *config.Service) error {
 	if err := d.dockerManager.CreateAndRunContainer(project, service, ""); err != nil {
 		return fmt.Errorf("failed to start container for %s: %v", service.Image, err)
@@ -90,7 +166,7 @@ func (d *Deployment) installService(project string, service *config.Service) err
 
 	container := containerName(project, service.Name, "")
 
-	if err := d.dockerManager.CheckContainerHealth(container, service.HealthCheck); err != nil {
+	if err := d.checkHealth(container, service); err != nil {
 		return fmt.Errorf("install failed for %s: container is unhealthy: %w", container, err)
 	}
 
@@ -110,22 +186,32 @@ func (d *Deployment) installService(project string, service *config.Service) err
 func (d *Deployment) updateService(project string, service *config.Service) error {
 	container := containerName(project, service.Name, "")
 
-	if service.Recreate {
+	if service.DeployStrategy() != config.StrategyBlueGreen {
+		if err := d.processPreHooks(project, service); err != nil {
+			return err
+		}
 		if err := d.recreateService(project, service); err != nil {
 			return fmt.Errorf("failed to recreate service %s: %w", service.Name, err)
 		}
-		return nil
+		return d.processPostHooks(service, container)
 	}
 
 	if err := d.dockerManager.CreateAndRunContainer(project, service, newContainerSuffix); err != nil {
 		return fmt.Errorf("failed to start new container for %s: %v", container, err)
 	}
 
-	if err := d.dockerManager.CheckContainerHealth(container+newContainerSuffix, service.HealthCheck); err != nil {
+	if err := d.checkHealth(container+newContainerSuffix, service); err != nil {
 		if _, err := d.runCommand(context.Background(), "docker", "rm", "-f", container+newContainerSuffix); err != nil {
 			return fmt.Errorf("update failed for %s: new container is unhealthy and cleanup failed: %v", container, err)
 		}
-		return fmt.Errorf("update failed for %s: new container is unhealthy: %w", container, err)
+		return fmt.Errorf("update failed for %s: new container is unhealthy, %w: %w", container, ErrRolledBack, err)
+	}
+
+	if err := d.warmUp(context.Background(), service, container+newContainerSuffix); err != nil {
+		if _, rmErr := d.runCommand(context.Background(), "docker", "rm", "-f", container+newContainerSuffix); rmErr != nil {
+			return fmt.Errorf("update failed for %s: cleanup failed: %v (original error: %w)", container, rmErr, err)
+		}
+		return fmt.Errorf("update failed for %s: %w: %w", container, ErrRolledBack, err)
 	}
 
 	err := d.processPreHooks(project, service)
@@ -133,12 +219,24 @@ func (d *Deployment) updateService(project string, service *config.Service) erro
 		return err
 	}
 
-	oldContID, err := d.switchTraffic(project, service.Name)
+	var oldContID string
+	err = d.timed(PhaseCutover, service, func() error {
+		var err error
+		oldContID, err = d.switchTraffic(project, service)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to switch traffic for %s: %v", container, err)
 	}
 
-	if err := d.cleanup(project, oldContID, service.Name); err != nil {
+	if err := d.runSmokeTests(context.Background(), service, container+newContainerSuffix); err != nil {
+		if rollbackErr := d.rollBack(project, service, oldContID); rollbackErr != nil {
+			return fmt.Errorf("update failed for %s: %v, and rolling back failed: %v", container, err, rollbackErr)
+		}
+		return fmt.Errorf("update failed for %s: %w: %w", container, ErrRolledBack, err)
+	}
+
+	if err := d.timed(PhaseCutover, service, func() error { return d.cleanup(project, oldContID, service) }); err != nil {
 		return fmt.Errorf("failed to cleanup for %s: %v", container, err)
 	}
 
@@ -150,13 +248,24 @@ func (d *Deployment) updateService(project string, service *config.Service) erro
 	return nil
 }
 
+// checkHealth waits for container of service to become healthy. Workers are
+// not requested, so their container health check decides.
+func (d *Deployment) checkHealth(container string, service *config.Service) error {
+	return d.timed(PhaseHealthCheck, service, func() error {
+		if service.IsWorker() {
+			return d.dockerManager.WaitHealthy(container, containerHealthTimeout(service.Container))
+		}
+		return d.dockerManager.CheckContainerHealth(container, service)
+	})
+}
+
 func (d *Deployment) processPreHooks(project string, service *config.Service) error {
 	if service.Hooks == nil || service.Hooks.Pre == nil {
 		return nil
 	}
 
 	if service.Hooks.Pre.Local != "" {
-		if _, err := d.runLocalCommand(context.Background(), service.Hooks.Pre.Local); err != nil {
+		if _, err := d.runLocalCommand(context.Background(), "sh", "-c", service.Hooks.Pre.Local); err != nil {
 			return fmt.Errorf("local pre-hook failed: %w", err)
 		}
 	}
@@ -169,7 +278,8 @@ func (d *Deployment) processPreHooks(project string, service *config.Service) er
 			Env:        service.Env,
 			Entrypoint: service.Entrypoint,
 			Command:    service.Hooks.Pre.Remote,
-			Container:  &config.Container{RunOnce: true},
+			Container:  runOnceContainer(service),
+			Networks:   service.Networks,
 		}
 		err := d.dockerManager.CreateAndRunContainer(project, runService, "run")
 		if err != nil {
@@ -191,7 +301,7 @@ func (d *Deployment) processPostHooks(service *config.Service, container string)
 	}
 
 	if service.Hooks.Post.Local != "" {
-		if _, err := d.runLocalCommand(context.Background(), service.Hooks.Post.Local); err != nil {
+		if _, err := d.runLocalCommand(context.Background(), "sh", "-c", service.Hooks.Post.Local); err != nil {
 			return fmt.Errorf("local post-hook failed: %w", err)
 		}
 	}
@@ -205,12 +315,12 @@ func (d *Deployment) processPostHooks(service *config.Service, container string)
 }
 
 func (d *Deployment) recreateService(project string, service *config.Service) error {
-	oldContID, err := d.dockerManager.GetContainerID(project, service.Name)
+	oldContID, err := d.dockerManager.GetContainerID(docker.ServiceNetwork(project, service), service.Name)
 	if err != nil {
 		return fmt.Errorf("failed to get container ID for %s: %v", service.Name, err)
 	}
 
-	if _, err := d.runCommand(context.Background(), "docker", "stop", oldContID); err != nil {
+	if _, err := d.runCommand(context.Background(), "docker", stopArgs(service, oldContID)...); err != nil {
 		return fmt.Errorf("failed to stop old container for %s: %v", service.Name, err)
 	}
 
@@ -222,8 +332,9 @@ func (d *Deployment) recreateService(project string, service *config.Service) er
 		return fmt.Errorf("failed to start new container for %s: %v", service.Name, err)
 	}
 
-	if err := d.dockerManager.CheckContainerHealth(service.Name, service.HealthCheck); err != nil {
-		if _, rmErr := d.runCommand(context.Background(), "docker", "rm", "-f", service.Name); rmErr != nil {
+	container := containerName(project, service.Name, "")
+	if err := d.checkHealth(container, service); err != nil {
+		if _, rmErr := d.runCommand(context.Background(), "docker", "rm", "-f", container); rmErr != nil {
 			return fmt.Errorf("recreation failed for %s: new container is unhealthy and cleanup failed: %v (original error: %w)", service.Name, rmErr, err)
 		}
 		return fmt.Errorf("recreation failed for %s: new container is unhealthy: %w", service.Name, err)
@@ -232,16 +343,27 @@ func (d *Deployment) recreateService(project string, service *config.Service) er
 	return nil
 }
 
-func (d *Deployment) switchTraffic(project, service string) (string, error) {
-	newContainer := containerName(project, service, newContainerSuffix)
-	oldContainer, err := d.dockerManager.GetContainerID(project, service)
+// switchTraffic gives the new container the service alias. Without a drain
+// timeout the old container is disconnected right away; otherwise it stays
+// connected so in-flight requests can complete while it shuts down.
+func (d *Deployment) switchTraffic(project string, service *config.Service) (string, error) {
+	newContainer := containerName(project, service.Name, newContainerSuffix)
+	oldContainer, err := d.dockerManager.GetContainerID(docker.ServiceNetwork(project, service), service.Name)
 	if err != nil {
 		return "", fmt.Errorf("failed to get old container ID: %v", err)
 	}
 
-	cmds := [][]string{
-		{"docker", "network", "disconnect", project, newContainer},
-		{"docker", "network", "connect", "--alias", service, project, newContainer},
+	networks := docker.ServiceNetworks(project, service)
+	connect := []string{"docker", "network", "connect"}
+	for _, alias := range docker.NetworkAliases(service, "") {
+		connect = append(connect, "--alias", alias)
+	}
+	var cmds [][]string
+	for _, network := range networks {
+		cmds = append(cmds,
+			[]string{"docker", "network", "disconnect", network, newContainer},
+			append(slices.Clone(connect), network, newContainer),
+		)
 	}
 
 	for _, cmd := range cmds {
@@ -252,8 +374,13 @@ func (d *Deployment) switchTraffic(project, service string) (string, error) {
 
 	time.Sleep(1 * time.Second)
 
-	cmds = [][]string{
-		{"docker", "network", "disconnect", project, oldContainer},
+	if service.DrainTimeout > 0 {
+		return oldContainer, nil
+	}
+
+	cmds = nil
+	for _, network := range networks {
+		cmds = append(cmds, []string{"docker", "network", "disconnect", network, oldContainer})
 	}
 
 	for _, cmd := range cmds {
@@ -265,11 +392,11 @@ func (d *Deployment) switchTraffic(project, service string) (string, error) {
 	return oldContainer, nil
 }
 
-func (d *Deployment) cleanup(project, oldContID, service string) error {
-	oldContainer := containerName(project, service, newContainerSuffix)
-	newContainer := containerName(project, service, "")
+func (d *Deployment) cleanup(project, oldContID string, service *config.Service) error {
+	oldContainer := containerName(project, service.Name, newContainerSuffix)
+	newContainer := containerName(project, service.Name, "")
 	cmds := [][]string{
-		{"docker", "stop", oldContID},
+		append([]string{"docker"}, stopArgs(service, oldContID)...),
 		{"docker", "rm", oldContID},
 		{"docker", "rename", oldContainer, newContainer},
 	}
@@ -283,6 +410,18 @@ func (d *Deployment) cleanup(project, oldContID, service string) error {
 	return nil
 }
 
+// stopArgs returns the docker arguments that stop container, allowing the
+// service's drain timeout and stop grace period for a graceful shutdown.
+func stopArgs(service *config.Service, container string) []string {
+	timeout := service.StopTimeout()
+	if timeout <= 0 {
+		return []string{"stop", container}
+	}
+
+	seconds := int(math.Ceil(timeout.Seconds()))
+	return []string{"stop", "--time", strconv.Itoa(seconds), container}
+}
+
 // runRemoteHook executes the given command inside the specified container
 func (d *Deployment) runRemoteHook(ctx context.Context, containerName, command string) error {
 	if command == "" {
@@ -297,3 +436,20 @@ func (d *Deployment) runRemoteHook(ctx context.Context, containerName, command s
 
 	return nil
 }
+
+// runOnceContainer returns the settings of a one-off container of the
+// service, such as a hook or its migrations: it runs as the same user with
+// the same privileges and filesystem as the service, without its health check.
+func runOnceContainer(service *config.Service) *config.Container {
+	container := &config.Container{RunOnce: true}
+	if service.Container != nil {
+		container.ULimits = service.Container.ULimits
+		container.User = service.Container.User
+		container.CapAdd = service.Container.CapAdd
+		container.CapDrop = service.Container.CapDrop
+		container.ReadOnly = service.Container.ReadOnly
+		container.SecurityOpt = service.Container.SecurityOpt
+		container.Tmpfs = service.Container.Tmpfs
+	}
+	return container
+}
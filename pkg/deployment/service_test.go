@@ -0,0 +1,102 @@
+package deployment
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestStopArgs(t *testing.T) {
+	assert.Equal(t, []string{"stop", "abc"}, stopArgs(&config.Service{}, "abc"))
+	assert.Equal(t, []string{"stop", "--time", "30", "abc"}, stopArgs(&config.Service{DrainTimeout: config.Duration(30 * time.Second)}, "abc"))
+	assert.Equal(t, []string{"stop", "--time", "2", "abc"}, stopArgs(&config.Service{DrainTimeout: config.Duration(1500 * time.Millisecond)}, "abc"))
+	assert.Equal(t, []string{"stop", "--time", "300", "abc"}, stopArgs(&config.Service{
+		DrainTimeout:    config.Duration(30 * time.Second),
+		StopGracePeriod: config.Duration(5 * time.Minute),
+	}, "abc"), "the longer of the drain timeout and the grace period applies")
+}
+
+func TestSwitchTraffic_Networks(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker ps -aq --filter network=project_backend", fake.Response{Output: "abc123"})
+	runner.On("docker inspect abc123", fake.Response{Output: `[{"Id":"abc123","NetworkSettings":{"Networks":{"project_backend":{"Aliases":["postgres"]}}}}]`})
+	service := DependencyService(&config.Dependency{Name: "postgres", Image: "postgres:17", Networks: []string{"backend", "jobs"}})
+
+	oldContainer, err := NewDeployment(runner, nil).switchTraffic("project", service)
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", oldContainer)
+
+	var calls []string
+	for _, call := range runner.Calls()[2:] {
+		calls = append(calls, call.String())
+	}
+	assert.Equal(t, []string{
+		"docker network disconnect project_backend project-postgres_new",
+		"docker network connect --alias postgres project_backend project-postgres_new",
+		"docker network disconnect project_jobs project-postgres_new",
+		"docker network connect --alias postgres project_jobs project-postgres_new",
+		"docker network disconnect project_backend abc123",
+		"docker network disconnect project_jobs abc123",
+	}, calls)
+}
+
+func TestRemoveMovedContainer(t *testing.T) {
+	runner := fake.NewRunner()
+	service := DependencyService(&config.Dependency{Name: "postgres", Image: "postgres:17", Networks: []string{"backend"}})
+
+	require.NoError(t, NewDeployment(runner, nil).removeMovedContainer("project", service))
+	require.Len(t, runner.Calls(), 1, "nothing to remove without a container of that name")
+
+	runner.Reset()
+	runner.On("docker ps -aq --filter name=^project-postgres$", fake.Response{Output: "abc123"})
+	require.NoError(t, NewDeployment(runner, nil).removeMovedContainer("project", service))
+
+	var calls []string
+	for _, call := range runner.Calls() {
+		calls = append(calls, call.String())
+	}
+	assert.Equal(t, []string{
+		"docker ps -aq --filter name=^project-postgres$",
+		"docker stop project-postgres",
+		"docker rm project-postgres",
+	}, calls)
+}
+
+func TestRunOnceContainer(t *testing.T) {
+	assert.Equal(t, &config.Container{RunOnce: true}, runOnceContainer(&config.Service{Name: "web"}))
+
+	service := &config.Service{Name: "web", Container: &config.Container{
+		HealthCheck: &config.ContainerHealthCheck{Cmd: "true"},
+		User:        "1000",
+		CapDrop:     []string{"ALL"},
+		ReadOnly:    true,
+		Tmpfs:       []string{"/tmp"},
+	}}
+	assert.Equal(t, &config.Container{
+		RunOnce:  true,
+		User:     "1000",
+		CapDrop:  []string{"ALL"},
+		ReadOnly: true,
+		Tmpfs:    []string{"/tmp"},
+	}, runOnceContainer(service))
+}
+
+func TestCheckHealth_Worker(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker inspect --format={{if .State.Health}}{{.State.Health.Status}}{{end}}", fake.Response{Output: "unhealthy"})
+
+	worker := &config.Service{
+		Name: "jobs",
+		Type: config.ServiceWorker,
+		Container: &config.Container{HealthCheck: &config.ContainerHealthCheck{
+			Cmd: "./jobs healthcheck",
+		}},
+	}
+	err := NewDeployment(runner, nil).checkHealth("project-jobs_new", worker)
+	assert.EqualError(t, err, "project-jobs_new is unhealthy")
+}
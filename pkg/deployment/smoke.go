@@ -0,0 +1,112 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/docker"
+)
+
+// SmokeTestImage runs the HTTP smoke test checks, sharing the network
+// namespace of the container under test so images need no HTTP client.
+const SmokeTestImage = "curlimages/curl:8.10.1"
+
+const (
+	defaultSmokeTestInterval = 5 * time.Second
+	defaultSmokeTestTimeout  = 10 * time.Second
+)
+
+// runSmokeTests runs the smoke test checks of service against container
+// until the window of the tests has passed, and returns the first failure.
+func (d *Deployment) runSmokeTests(ctx context.Context, service *config.Service, container string) error {
+	tests := service.SmokeTests
+	if tests == nil {
+		return nil
+	}
+
+	interval := tests.Interval.Duration()
+	if interval <= 0 {
+		interval = defaultSmokeTestInterval
+	}
+	deadline := time.Now().Add(tests.Window.Duration())
+
+	for {
+		for _, check := range tests.Checks {
+			d.progress(fmt.Sprintf("Running smoke test %s for %s...", check.Name, service.Name))
+			if err := d.runSmokeTest(ctx, service, container, check); err != nil {
+				return fmt.Errorf("smoke test %s failed: %w", check.Name, err)
+			}
+		}
+
+		if !time.Now().Add(interval).Before(deadline) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (d *Deployment) runSmokeTest(ctx context.Context, service *config.Service, container string, check config.SmokeTest) error {
+	timeout := check.Timeout.Duration()
+	if timeout <= 0 {
+		timeout = defaultSmokeTestTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if check.Command != "" {
+		if output, err := d.runChecked(ctx, "docker", "exec", container, "sh", "-c", check.Command); err != nil {
+			return fmt.Errorf("%w\n\x1b[93mOutput from the smoke test:\x1b[0m\n\x1b[90m%s\x1b[0m", err, output)
+		}
+		return nil
+	}
+
+	want := check.Status
+	if want == 0 {
+		want = 200
+	}
+	output, err := d.runChecked(ctx, "docker", "run", "--rm", "--network", "container:"+container, SmokeTestImage,
+		"-s", "-o", "/dev/null", "-w", "%{http_code}",
+		"--max-time", strconv.Itoa(int(timeout.Seconds())),
+		fmt.Sprintf("http://localhost:%d%s", service.Port, check.Path))
+	if err != nil {
+		return err
+	}
+	if got := strings.TrimSpace(output); got != strconv.Itoa(want) {
+		return fmt.Errorf("GET %s returned status %s, expected %d", check.Path, got, want)
+	}
+	return nil
+}
+
+// rollBack switches traffic from the new container of service back to the
+// previous one and removes the new container.
+func (d *Deployment) rollBack(project string, service *config.Service, oldContID string) error {
+	var cmds [][]string
+	// With a drain timeout the previous container never left the network.
+	if service.DrainTimeout <= 0 {
+		connect := []string{"docker", "network", "connect"}
+		for _, alias := range docker.NetworkAliases(service, "") {
+			connect = append(connect, "--alias", alias)
+		}
+		for _, network := range docker.ServiceNetworks(project, service) {
+			cmds = append(cmds, append(slices.Clone(connect), network, oldContID))
+		}
+	}
+	cmds = append(cmds, []string{"docker", "rm", "-f", containerName(project, service.Name, newContainerSuffix)})
+
+	for _, cmd := range cmds {
+		if _, err := d.runCommand(context.Background(), cmd[0], cmd[1:]...); err != nil {
+			return fmt.Errorf("failed to execute command '%s': %v", strings.Join(cmd, " "), err)
+		}
+	}
+
+	return nil
+}
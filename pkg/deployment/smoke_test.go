@@ -0,0 +1,76 @@
+package deployment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestRunSmokeTests(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker run --rm --network container:project-web_new", fake.Response{Output: "200"})
+	service := &config.Service{
+		Name: "web",
+		Port: 3000,
+		SmokeTests: &config.SmokeTests{
+			Window:   config.Duration(30 * time.Millisecond),
+			Interval: config.Duration(10 * time.Millisecond),
+			Checks: []config.SmokeTest{
+				{Name: "home", Path: "/"},
+				{Name: "db", Command: "./bin/check-db"},
+			},
+		},
+	}
+
+	require.NoError(t, NewDeployment(runner, nil).runSmokeTests(context.Background(), service, "project-web_new"))
+
+	calls := runner.Calls()
+	require.GreaterOrEqual(t, len(calls), 4, "checks repeat until the window has passed")
+	assert.Equal(t, "docker run --rm --network container:project-web_new "+SmokeTestImage+" -s -o /dev/null -w %{http_code} --max-time 10 http://localhost:3000/", calls[0].String())
+	assert.Equal(t, "docker exec project-web_new sh -c ./bin/check-db", calls[1].String())
+}
+
+func TestRunSmokeTests_Failure(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker run", fake.Response{Output: "502"})
+	runner.On("docker exec", fake.Response{Output: "connection refused", ExitCode: 1})
+	service := &config.Service{Name: "web", Port: 3000, SmokeTests: &config.SmokeTests{
+		Checks: []config.SmokeTest{{Name: "home", Path: "/health", Status: 204}},
+	}}
+
+	err := NewDeployment(runner, nil).runSmokeTests(context.Background(), service, "project-web_new")
+	assert.EqualError(t, err, "smoke test home failed: GET /health returned status 502, expected 204")
+
+	service.SmokeTests.Checks = []config.SmokeTest{{Name: "db", Command: "./bin/check-db"}}
+	err = NewDeployment(runner, nil).runSmokeTests(context.Background(), service, "project-web_new")
+	assert.ErrorContains(t, err, "smoke test db failed: command failed: exit status 1")
+	assert.ErrorContains(t, err, "connection refused")
+}
+
+func TestRollBack(t *testing.T) {
+	runner := fake.NewRunner()
+	service := &config.Service{Name: "web-2", ReplicaOf: "web"}
+
+	require.NoError(t, NewDeployment(runner, nil).rollBack("project", service, "abc123"))
+
+	var calls []string
+	for _, call := range runner.Calls() {
+		calls = append(calls, call.String())
+	}
+	assert.Equal(t, []string{
+		"docker network connect --alias web-2 --alias web project abc123",
+		"docker rm -f project-web-2" + newContainerSuffix,
+	}, calls)
+
+	runner.Reset()
+	service = &config.Service{Name: "web", DrainTimeout: config.Duration(10 * time.Second)}
+	require.NoError(t, NewDeployment(runner, nil).rollBack("project", service, "abc123"))
+	require.Len(t, runner.Calls(), 1)
+	assert.Equal(t, "docker rm -f project-web"+newContainerSuffix, runner.Calls()[0].String())
+}
@@ -0,0 +1,285 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/proxy"
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+const (
+	// staticManifest lists the hashed assets shipped by a release. The next
+	// release copies them over so pages loaded before the switch keep working.
+	staticManifest = ".ftl-assets"
+
+	// staticReleasesToKeep is the number of releases kept per static service.
+	staticReleasesToKeep = 3
+)
+
+var (
+	assetReferenceRegex = regexp.MustCompile(`(?i)(?:src|href)\s*=\s*["']([^"']+)["']`)
+	assetHashRegex      = regexp.MustCompile(`^[A-Za-z0-9_]{8,}$`)
+	digitRegex          = regexp.MustCompile(`[0-9]`)
+)
+
+func hasStaticServices(cfg *config.Config) bool {
+	for _, service := range cfg.Services {
+		if service.Static != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// staticFolder returns the server directory mounted into the proxy as proxy.StaticRoot.
+func (d *Deployment) staticFolder(project string) (string, error) {
+	projectPath, err := d.projectFolder(project)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(projectPath, "static"), nil
+}
+
+// deployStatic extracts the static directory from the locally built image and
+// uploads it to the server as a new release. The proxy serves the release the
+// "current" symlink points to, which is switched atomically once the upload is
+// complete.
+func (d *Deployment) deployStatic(ctx context.Context, project string, service *config.Service) error {
+	image := service.Image
+	if image == "" {
+		image = fmt.Sprintf("%s-%s", project, service.Name)
+	}
+
+	workDir, err := os.MkdirTemp("", "ftl-static-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	contentDir := filepath.Join(workDir, "content")
+	if err := d.extractStatic(ctx, image, containerName(project, service.Name, "_static"), service.Static.Path, contentDir); err != nil {
+		return err
+	}
+
+	if service.Static.HashedAssets {
+		unhashed, err := findUnhashedAssetReferences(contentDir)
+		if err != nil {
+			return fmt.Errorf("failed to check asset references: %w", err)
+		}
+		if len(unhashed) > 0 {
+			return fmt.Errorf("hashed_assets is enabled but HTML references assets without a content hash:\n  %s", strings.Join(unhashed, "\n  "))
+		}
+	}
+
+	if err := writeStaticManifest(contentDir); err != nil {
+		return fmt.Errorf("failed to write asset manifest: %w", err)
+	}
+
+	archive := filepath.Join(workDir, "static.tar.gz")
+	if _, err := d.localRunner.RunCommand(ctx, "tar", "-czf", archive, "-C", contentDir, "."); err != nil {
+		return fmt.Errorf("failed to archive static files: %w", err)
+	}
+
+	staticPath, err := d.staticFolder(project)
+	if err != nil {
+		return fmt.Errorf("failed to get static folder path: %w", err)
+	}
+
+	serviceDir := filepath.Join(staticPath, service.Name)
+	release := staticReleaseName(time.Now())
+	releaseDir := filepath.Join(serviceDir, "releases", release)
+
+	// The release folder must be new, so two deploys can never extract into
+	// the same one.
+	if _, err := d.runChecked(ctx, "sh", "-c", fmt.Sprintf("mkdir -p %s && mkdir %s",
+		shell.Quote(path.Dir(releaseDir)), shell.Quote(releaseDir))); err != nil {
+		return fmt.Errorf("failed to create release folder: %w", err)
+	}
+
+	remoteArchive := filepath.Join(serviceDir, release+".tar.gz")
+	if err := d.runner.CopyFile(ctx, archive, remoteArchive); err != nil {
+		return fmt.Errorf("failed to upload static files: %w", err)
+	}
+
+	dialect, err := d.shellDialect(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, cmd := range staticReleaseCommands(serviceDir, release, dialect) {
+		if _, err := d.runChecked(ctx, cmd[0], cmd[1:]...); err != nil {
+			return fmt.Errorf("failed to execute command '%s': %v", strings.Join(cmd, " "), err)
+		}
+	}
+
+	return nil
+}
+
+// staticReleaseCommands returns the commands that install the uploaded
+// archive of release in serviceDir: extract it, carry over the hashed assets
+// of the current release, switch the "current" symlink to it and prune the
+// oldest releases.
+func staticReleaseCommands(serviceDir, release string, dialect shell.Dialect) [][]string {
+	remoteArchive := filepath.Join(serviceDir, release+".tar.gz")
+	releaseDir := filepath.Join(serviceDir, "releases", release)
+	return [][]string{
+		{"tar", "-xzf", remoteArchive, "-C", releaseDir},
+		{"rm", "-f", remoteArchive},
+		{"sh", "-c", fmt.Sprintf(
+			`cd %s && if [ -f current/%s ]; then (cd current && while IFS= read -r f; do [ -e %[3]s/"$f" ] || { mkdir -p %[3]s/"$(dirname "$f")" && cp "$f" %[3]s/"$f"; } || exit 1; done < %s); fi`,
+			shell.Quote(serviceDir), staticManifest, shell.Quote(releaseDir), staticManifest,
+		)},
+		{"sh", "-c", fmt.Sprintf("cd %s && %s", shell.Quote(serviceDir), dialect.ReplaceSymlink("releases/"+release, "current"))},
+		{"sh", "-c", fmt.Sprintf(
+			`cd %s && ls -1 | sort -r | tail -n +%d | while IFS= read -r release; do rm -rf "./$release" || exit 1; done`,
+			shell.Quote(serviceDir+"/releases"), staticReleasesToKeep+1,
+		)},
+	}
+}
+
+// staticReleaseName names the release folder of a deploy started at now. The
+// names sort by time, and the nanoseconds keep deploys started in the same
+// second apart.
+func staticReleaseName(now time.Time) string {
+	return now.UTC().Format("20060102150405.000000000")
+}
+
+// extractStatic copies srcPath out of image into destDir using a throwaway local container.
+func (d *Deployment) extractStatic(ctx context.Context, image, container, srcPath, destDir string) error {
+	_, _ = d.localRunner.RunCommand(ctx, "docker", "rm", "-f", container)
+
+	if _, err := d.localRunner.RunCommand(ctx, "docker", "create", "--name", container, image); err != nil {
+		return fmt.Errorf("failed to create container from %s: %w", image, err)
+	}
+	defer func() {
+		_, _ = d.localRunner.RunCommand(context.Background(), "docker", "rm", "-f", container)
+	}()
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", destDir, err)
+	}
+
+	if _, err := d.localRunner.RunCommand(ctx, "docker", "cp", container+":"+strings.TrimSuffix(srcPath, "/")+"/.", destDir); err != nil {
+		return fmt.Errorf("failed to copy %s from %s: %w", srcPath, image, err)
+	}
+
+	return nil
+}
+
+// staticVolume returns the proxy volume that exposes the project's static files.
+func (d *Deployment) staticVolume(project string) (string, error) {
+	staticPath, err := d.staticFolder(project)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := d.runCommand(context.Background(), "mkdir", "-p", staticPath); err != nil {
+		return "", fmt.Errorf("failed to create static folder: %w", err)
+	}
+
+	return staticPath + ":" + proxy.StaticRoot + ":ro", nil
+}
+
+// writeStaticManifest records the hashed assets found in dir in its manifest file.
+func writeStaticManifest(dir string) error {
+	var assets []string
+	err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !isHashedAsset(entry.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		assets = append(assets, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	content := strings.Join(assets, "\n")
+	if content != "" {
+		content += "\n"
+	}
+	return os.WriteFile(filepath.Join(dir, staticManifest), []byte(content), 0644)
+}
+
+// findUnhashedAssetReferences scans the HTML files in dir for local script and
+// stylesheet references whose file names carry no content hash.
+func findUnhashedAssetReferences(dir string) ([]string, error) {
+	var unhashed []string
+	err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(p), ".html") {
+			return nil
+		}
+
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		for _, match := range assetReferenceRegex.FindAllStringSubmatch(string(content), -1) {
+			ref := match[1]
+			if strings.Contains(ref, "://") || strings.HasPrefix(ref, "//") || strings.HasPrefix(ref, "data:") {
+				continue
+			}
+			ref, _, _ = strings.Cut(ref, "?")
+			ref, _, _ = strings.Cut(ref, "#")
+
+			switch strings.ToLower(path.Ext(ref)) {
+			case ".js", ".mjs", ".css":
+			default:
+				continue
+			}
+
+			if !isHashedAsset(path.Base(ref)) {
+				unhashed = append(unhashed, fmt.Sprintf("%s: %s", filepath.ToSlash(rel), match[1]))
+			}
+		}
+		return nil
+	})
+
+	return unhashed, err
+}
+
+// isHashedAsset reports whether a file name like "index-B4x9Qz1a.js" or
+// "main.3f2a9c1b.css" carries a content hash: a segment of at least eight
+// alphanumeric characters, including a digit, right before the extension.
+func isHashedAsset(name string) bool {
+	ext := path.Ext(name)
+	if ext == "" {
+		return false
+	}
+	stem := strings.TrimSuffix(name, ext)
+
+	idx := strings.LastIndexAny(stem, ".-")
+	if idx == -1 {
+		return false
+	}
+	segment := stem[idx+1:]
+
+	return assetHashRegex.MatchString(segment) && digitRegex.MatchString(segment)
+}
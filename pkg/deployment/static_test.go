@@ -0,0 +1,92 @@
+package deployment
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+func TestIsHashedAsset(t *testing.T) {
+	tests := map[string]bool{
+		"index-B4x9Qz1a.js":         true,
+		"main.3f2a9c1b.css":         true,
+		"chunk.3f2a9c1b4d5e6f70.js": true,
+		"app.js":                    false,
+		"react-polyfills.js":        false,
+		"index-abc1.js":             false,
+		"noext":                     false,
+	}
+
+	for name, want := range tests {
+		assert.Equal(t, want, isHashedAsset(name), name)
+	}
+}
+
+func TestFindUnhashedAssetReferences(t *testing.T) {
+	dir := t.TempDir()
+	html := `<html><head>
+<link rel="stylesheet" href="/assets/index-B4x9Qz1a.css">
+<link rel="icon" href="/favicon.ico">
+<script src="https://cdn.example.com/lib.js"></script>
+<script src="/assets/app.js?v=1"></script>
+</head></html>`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte(html), 0644))
+
+	unhashed, err := findUnhashedAssetReferences(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"index.html: /assets/app.js?v=1"}, unhashed)
+}
+
+func TestWriteStaticManifest(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "assets"), 0755))
+	for _, name := range []string{"index.html", "assets/index-B4x9Qz1a.js", "assets/logo.svg"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+
+	require.NoError(t, writeStaticManifest(dir))
+
+	manifest, err := os.ReadFile(filepath.Join(dir, staticManifest))
+	require.NoError(t, err)
+	assert.Equal(t, "assets/index-B4x9Qz1a.js\n", string(manifest))
+}
+
+func TestStaticReleaseName(t *testing.T) {
+	first := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	second := first.Add(time.Millisecond)
+	assert.Equal(t, "20260301120000.000000000", staticReleaseName(first))
+	assert.Less(t, staticReleaseName(first), staticReleaseName(second), "releases of the same second differ and sort by time")
+	assert.Less(t, staticReleaseName(second), staticReleaseName(first.Add(time.Second)))
+}
+
+func TestStaticReleaseCommands(t *testing.T) {
+	// A folder name the scripts must not split or expand.
+	serviceDir := filepath.Join(t.TempDir(), "my site $(touch pwned)", "web")
+	content := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(content, "index.html"), []byte("<html></html>"), 0644))
+
+	for i, release := range []string{"20260301120000.000000001", "20260301120000.000000002"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(serviceDir, "releases", release), 0755))
+		require.NoError(t, exec.Command("tar", "-czf", filepath.Join(serviceDir, release+".tar.gz"), "-C", content, ".").Run())
+
+		for _, cmd := range staticReleaseCommands(serviceDir, release, shell.POSIX) {
+			output, err := exec.Command(cmd[0], cmd[1:]...).CombinedOutput()
+			require.NoError(t, err, "release %d: %s: %s", i, cmd, output)
+		}
+	}
+
+	target, err := os.Readlink(filepath.Join(serviceDir, "current"))
+	require.NoError(t, err)
+	assert.Equal(t, "releases/20260301120000.000000002", target)
+	assert.FileExists(t, filepath.Join(serviceDir, "current", "index.html"))
+	assert.NoFileExists(t, filepath.Join(serviceDir, "20260301120000.000000002.tar.gz"))
+	assert.NoFileExists(t, "pwned")
+	assert.NoFileExists(t, filepath.Join(serviceDir, "pwned"))
+}
@@ -0,0 +1,89 @@
+package deployment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/config"
+)
+
+// ContainerStats is the resource usage of one container, as docker stats
+// reports it.
+type ContainerStats struct {
+	Name       string `json:"Name"`
+	CPU        string `json:"CPUPerc"`
+	Memory     string `json:"MemUsage"`
+	MemoryPerc string `json:"MemPerc"`
+	NetIO      string `json:"NetIO"`
+	BlockIO    string `json:"BlockIO"`
+	PIDs       string `json:"PIDs"`
+}
+
+// CPUPercent returns the CPU usage of the container, in percent of one core.
+func (s ContainerStats) CPUPercent() float64 {
+	return parsePercent(s.CPU)
+}
+
+// MemoryPercent returns the memory usage of the container, in percent of its
+// limit.
+func (s ContainerStats) MemoryPercent() float64 {
+	return parsePercent(s.MemoryPerc)
+}
+
+// Stats returns the resource usage of the containers on the networks of the
+// project, sorted by name.
+func (d *Deployment) Stats(ctx context.Context, project string, cfg *config.Config) ([]ContainerStats, error) {
+	seen := map[string]bool{}
+	var names []string
+	for _, network := range append([]string{project}, privateNetworks(project, cfg.Networks)...) {
+		output, err := d.runCommand(ctx, "docker", "ps", "--filter", "network="+network, "--format", "{{.Names}}")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list containers of network %s: %w", network, err)
+		}
+		for _, name := range strings.Fields(output) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	output, err := d.runCommand(ctx, "docker", append([]string{"stats", "--no-stream", "--format", "{{json .}}"}, names...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read container stats: %w", err)
+	}
+	return parseStats(output)
+}
+
+func parseStats(output string) ([]ContainerStats, error) {
+	var stats []ContainerStats
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var entry ContainerStats
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse container stats: %w", err)
+		}
+		stats = append(stats, entry)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats, nil
+}
+
+// parsePercent parses a percentage such as 12.5%, or returns zero for the
+// placeholder docker stats shows while a container starts.
+func parsePercent(value string) float64 {
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil {
+		return 0
+	}
+	return percent
+}
@@ -0,0 +1,42 @@
+package deployment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestStats(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker ps --filter network=project", fake.Response{Output: "project-web\nproject-postgres"})
+	runner.On("docker ps --filter network=project_backend", fake.Response{Output: "project-postgres\nproject-worker"})
+	runner.On("docker stats", fake.Response{Output: `{"Name":"project-web","CPUPerc":"12.50%","MemUsage":"120MiB / 1GiB","MemPerc":"11.72%","NetIO":"1.2MB / 3.4MB","BlockIO":"0B / 8kB","PIDs":"7"}
+{"Name":"project-postgres","CPUPerc":"--","MemUsage":"-- / --","MemPerc":"--","NetIO":"--","BlockIO":"--","PIDs":"--"}`})
+
+	stats, err := NewDeployment(runner, nil).Stats(context.Background(), "project", &config.Config{Networks: []string{"backend"}})
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	assert.Equal(t, "project-postgres", stats[0].Name)
+	assert.Equal(t, 0.0, stats[0].CPUPercent(), "a starting container has no usage yet")
+	assert.Equal(t, "project-web", stats[1].Name)
+	assert.Equal(t, 12.5, stats[1].CPUPercent())
+	assert.Equal(t, 11.72, stats[1].MemoryPercent())
+	assert.Equal(t, "120MiB / 1GiB", stats[1].Memory)
+
+	calls := runner.Calls()
+	assert.Equal(t, "docker stats --no-stream --format {{json .}} project-web project-postgres project-worker", calls[len(calls)-1].String())
+}
+
+func TestStats_NoContainers(t *testing.T) {
+	runner := fake.NewRunner()
+
+	stats, err := NewDeployment(runner, nil).Stats(context.Background(), "project", &config.Config{})
+	require.NoError(t, err)
+	assert.Empty(t, stats)
+	assert.Len(t, runner.Calls(), 1, "docker stats is not run without containers")
+}
@@ -0,0 +1,70 @@
+package deployment
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/telemetry"
+)
+
+// The timed phases of a deploy. Build is timed by ftl build; push covers both
+// the registry pushes of ftl build and the images Deploy streams to the
+// server.
+const (
+	PhaseBuild       = "build"
+	PhasePush        = "push"
+	PhasePull        = "pull"
+	PhaseHealthCheck = "health_check"
+	PhaseCutover     = "cutover"
+)
+
+// Phases lists the timed phases in the order they run.
+var Phases = []string{PhaseBuild, PhasePush, PhasePull, PhaseHealthCheck, PhaseCutover}
+
+// timings accumulates the seconds spent in each phase. Services are deployed
+// concurrently, so the time of a phase is summed over the services and can
+// exceed the duration of the deploy.
+type timings struct {
+	mu      sync.Mutex
+	seconds map[string]float64
+}
+
+func (t *timings) add(phase string, elapsed time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.seconds == nil {
+		t.seconds = map[string]float64{}
+	}
+	t.seconds[phase] += elapsed.Seconds()
+}
+
+func (t *timings) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seconds = nil
+}
+
+// Timings returns the seconds the last Deploy spent in each phase it went
+// through, keyed by phase.
+func (d *Deployment) Timings() map[string]float64 {
+	d.timings.mu.Lock()
+	defer d.timings.mu.Unlock()
+	seconds := make(map[string]float64, len(d.timings.seconds))
+	for phase, s := range d.timings.seconds {
+		seconds[phase] = s
+	}
+	return seconds
+}
+
+// timed runs fn and adds the time it took to phase, whether it failed or not.
+// It is traced as a span of the phase for service, which may be nil.
+func (d *Deployment) timed(phase string, service *config.Service, fn func() error) error {
+	_, span := d.startSpan(context.Background(), phase, service, telemetry.AttrPhase.String(phase))
+	started := time.Now()
+	err := fn()
+	d.timings.add(phase, time.Since(started))
+	telemetry.End(span, err)
+	return err
+}
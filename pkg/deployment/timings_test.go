@@ -0,0 +1,32 @@
+package deployment
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestTimings(t *testing.T) {
+	d := NewDeployment(fake.NewRunner(), nil)
+	assert.Empty(t, d.Timings())
+
+	assert.NoError(t, d.timed(PhasePull, nil, func() error { return nil }))
+	err := d.timed(PhasePull, nil, func() error { return errors.New("boom") })
+	assert.EqualError(t, err, "boom")
+	d.timings.add(PhaseHealthCheck, 1500*time.Millisecond)
+
+	timings := d.Timings()
+	assert.Len(t, timings, 2)
+	assert.Contains(t, timings, PhasePull)
+	assert.Equal(t, 1.5, timings[PhaseHealthCheck])
+
+	timings[PhaseCutover] = 1
+	assert.NotContains(t, d.Timings(), PhaseCutover)
+
+	d.timings.reset()
+	assert.Empty(t, d.Timings())
+}
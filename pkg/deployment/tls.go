@@ -0,0 +1,227 @@
+package deployment
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/docker"
+	"github.com/yarlson/ftl/pkg/proxy"
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+// certificatesImage writes certificates to the certs volume.
+const certificatesImage = "alpine:3"
+
+// certificateModeFile records, in the certs volume, the mode its
+// certificates were made with. Volumes without one hold Let's Encrypt
+// certificates.
+const certificateModeFile = ".ftl-mode"
+
+// selfSignedValidity is how long generated self-signed certificates are
+// valid. They are not trusted anyway, so they are not renewed.
+const selfSignedValidity = 10 * 365 * 24 * time.Hour
+
+// deployCertificates provides the certificates of the project domains as its
+// TLS mode configures: the certificate manager issues them with ACME, or
+// they are generated or taken from the configuration and written to the
+// certs volume the proxy reads.
+func (d *Deployment) deployCertificates(ctx context.Context, project string, cfg *config.Config) error {
+	mode := cfg.TLS.CertificateMode()
+	if err := d.switchCertificateMode(ctx, project, mode); err != nil {
+		return err
+	}
+
+	if cfg.TLS.UsesACME() {
+		if err := d.deployZero(ctx, project, cfg); err != nil {
+			return fmt.Errorf("failed to deploy Zero certificate manager: %w", err)
+		}
+		return nil
+	}
+
+	// The proxy takes port 80 over from a certificate manager left by an
+	// earlier deploy.
+	manager := containerName(project, proxy.ACMEUpstream, "")
+	existing, err := d.runChecked(ctx, "docker", "ps", "-aq", "--filter", fmt.Sprintf("name=^%s$", manager))
+	if err != nil {
+		return outputError(fmt.Errorf("failed to look up certificate manager: %w", err), existing)
+	}
+	if existing != "" {
+		if output, err := d.runChecked(ctx, "docker", "rm", "-f", manager); err != nil {
+			return outputError(fmt.Errorf("failed to remove certificate manager: %w", err), output)
+		}
+	}
+
+	var files map[string][]byte
+	switch mode {
+	case config.TLSSelfSigned:
+		files, err = d.selfSignedCertificates(ctx, project, cfg.Domains())
+	case config.TLSCustom:
+		files, err = customCertificates(cfg.TLS, cfg.Domains())
+	}
+	if err != nil {
+		return err
+	}
+	return d.installCertificates(ctx, project, files)
+}
+
+// switchCertificateMode removes the certificates of the certs volume when
+// they were made with another mode, so a project moving from the staging
+// environment to Let's Encrypt does not keep serving untrusted certificates,
+// and records mode.
+func (d *Deployment) switchCertificateMode(ctx context.Context, project, mode string) error {
+	script := fmt.Sprintf(
+		`if [ "$(cat /certs/%[1]s 2>/dev/null || echo %[2]s)" != %[3]s ]; then rm -f /certs/*.crt /certs/*.key; fi && echo %[3]s > /certs/%[1]s`,
+		certificateModeFile, config.TLSLetsEncrypt, shell.Quote(mode),
+	)
+	if _, err := d.runChecked(ctx, "docker", "run", "--rm", "-v", docker.VolumeBind(project, "certs:/certs"), certificatesImage, "sh", "-c", script); err != nil {
+		return fmt.Errorf("failed to check certificate mode: %w", err)
+	}
+	return nil
+}
+
+// selfSignedCertificates generates a certificate for each domain that has
+// none in the certs volume yet.
+func (d *Deployment) selfSignedCertificates(ctx context.Context, project string, domains []string) (map[string][]byte, error) {
+	existing, err := d.runChecked(ctx, "docker", "run", "--rm", "-v", docker.VolumeBind(project, "certs:/certs"), certificatesImage, "ls", "/certs")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list certificates: %w", err)
+	}
+	present := map[string]bool{}
+	for _, name := range strings.Fields(existing) {
+		present[name] = true
+	}
+
+	files := map[string][]byte{}
+	for _, domain := range domains {
+		if present[domain+".crt"] && present[domain+".key"] {
+			continue
+		}
+		certificate, key, err := selfSignedCertificate(domain, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate certificate for %s: %w", domain, err)
+		}
+		files[domain+".crt"] = certificate
+		files[domain+".key"] = key
+	}
+	return files, nil
+}
+
+// selfSignedCertificate returns a PEM certificate and key for domain.
+func selfSignedCertificate(domain string, now time.Time) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: domain},
+		DNSNames:              []string{domain},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+// customCertificates returns the configured certificate and key for every
+// domain, after checking that they belong together and cover the domains.
+func customCertificates(cfg *config.TLS, domains []string) (map[string][]byte, error) {
+	certificatePEM, err := os.ReadFile(cfg.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tls.certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tls.key: %w", err)
+	}
+
+	pair, err := tls.X509KeyPair(certificatePEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("tls.certificate and tls.key do not form a key pair: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tls.certificate: %w", err)
+	}
+
+	files := map[string][]byte{}
+	for _, domain := range domains {
+		if err := leaf.VerifyHostname(domain); err != nil {
+			return nil, fmt.Errorf("tls.certificate does not cover %s: %w", domain, err)
+		}
+		files[domain+".crt"] = certificatePEM
+		files[domain+".key"] = keyPEM
+	}
+	return files, nil
+}
+
+// installCertificates writes files to the certs volume through a directory
+// only the deploy user can read, removed afterwards.
+func (d *Deployment) installCertificates(ctx context.Context, project string, files map[string][]byte) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	localDir, err := os.MkdirTemp("", "ftl-certs-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(localDir)
+
+	projectPath, err := d.projectFolder(project)
+	if err != nil {
+		return err
+	}
+	remoteDir := filepath.Join(projectPath, "certs-upload")
+	if _, err := d.runChecked(ctx, "sh", "-c", fmt.Sprintf("rm -rf %[1]s && mkdir -m 700 -p %[1]s", shell.Quote(remoteDir))); err != nil {
+		return fmt.Errorf("failed to create %s: %w", remoteDir, err)
+	}
+	defer func() { _, _ = d.runCommand(context.Background(), "rm", "-rf", remoteDir) }()
+
+	for name, content := range files {
+		local := filepath.Join(localDir, name)
+		if err := os.WriteFile(local, content, 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if err := d.runner.CopyFile(ctx, local, filepath.Join(remoteDir, name)); err != nil {
+			return fmt.Errorf("failed to upload %s: %w", name, err)
+		}
+	}
+
+	if output, err := d.runChecked(ctx, "docker", "run", "--rm",
+		"-v", docker.VolumeBind(project, "certs:/certs"), "-v", remoteDir+":/upload:ro", certificatesImage,
+		"sh", "-c", "cp /upload/* /certs/ && chmod 600 /certs/*.key"); err != nil {
+		return fmt.Errorf("failed to install certificates: %w\n\x1b[93mOutput:\x1b[0m\n\x1b[90m%s\x1b[0m", err, output)
+	}
+	return nil
+}
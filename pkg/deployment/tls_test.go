@@ -0,0 +1,117 @@
+package deployment
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestSelfSignedCertificate(t *testing.T) {
+	now := time.Now()
+	certificatePEM, keyPEM, err := selfSignedCertificate("example.com", now)
+	require.NoError(t, err)
+
+	block, _ := pem.Decode(certificatePEM)
+	require.NotNil(t, block)
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	assert.NoError(t, certificate.VerifyHostname("example.com"))
+	assert.True(t, certificate.NotAfter.After(now.Add(9*365*24*time.Hour)))
+	assert.Contains(t, string(keyPEM), "EC PRIVATE KEY")
+}
+
+func TestCustomCertificates(t *testing.T) {
+	dir := t.TempDir()
+	certificatePEM, keyPEM, err := selfSignedCertificate("example.com", time.Now())
+	require.NoError(t, err)
+	tls := &config.TLS{Mode: config.TLSCustom, Certificate: filepath.Join(dir, "cert.pem"), Key: filepath.Join(dir, "key.pem")}
+	require.NoError(t, os.WriteFile(tls.Certificate, certificatePEM, 0600))
+	require.NoError(t, os.WriteFile(tls.Key, keyPEM, 0600))
+
+	files, err := customCertificates(tls, []string{"example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"example.com.crt": certificatePEM, "example.com.key": keyPEM}, files)
+
+	_, err = customCertificates(tls, []string{"example.com", "api.example.com"})
+	assert.ErrorContains(t, err, "tls.certificate does not cover api.example.com")
+
+	_, otherKey, err := selfSignedCertificate("example.com", time.Now())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(tls.Key, otherKey, 0600))
+	_, err = customCertificates(tls, []string{"example.com"})
+	assert.ErrorContains(t, err, "do not form a key pair")
+}
+
+func TestDeployCertificates_SelfSigned(t *testing.T) {
+	cfg := &config.Config{
+		Project: config.Project{Name: "project", Domain: "example.com"},
+		Services: []config.Service{
+			{Name: "web", Port: 80, Routes: []config.Route{{PathPrefix: "/"}}},
+			{Name: "api", Port: 80, Domain: "api.example.com", Routes: []config.Route{{PathPrefix: "/"}}},
+		},
+		TLS: &config.TLS{Mode: config.TLSSelfSigned},
+	}
+
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("docker run --rm -v project-certs:/certs alpine:3 ls /certs", fake.Response{Output: "example.com.crt\nexample.com.key"})
+	runner.On("docker ps -aq --filter name=^project-zero$", fake.Response{Output: "abc123"})
+	require.NoError(t, NewDeployment(runner, nil).deployCertificates(context.Background(), "project", cfg))
+
+	_, ok := runner.File("/home/deploy/projects/project/certs-upload/api.example.com.crt")
+	assert.True(t, ok, "a missing certificate is generated")
+	_, ok = runner.File("/home/deploy/projects/project/certs-upload/example.com.crt")
+	assert.False(t, ok, "an existing certificate is kept")
+
+	var lines []string
+	for _, call := range runner.Calls() {
+		lines = append(lines, call.String())
+	}
+	assert.True(t, strings.HasSuffix(lines[0], "echo 'selfsigned' > /certs/.ftl-mode"))
+	assert.Contains(t, lines, "docker rm -f project-zero")
+	for _, line := range lines {
+		assert.NotContains(t, line, "yarlson/zero", "no certificate manager runs")
+	}
+}
+
+func TestDeployCertificates_InstallFailure(t *testing.T) {
+	cfg := &config.Config{
+		Project:  config.Project{Name: "project", Domain: "example.com"},
+		Services: []config.Service{{Name: "web", Port: 80, Routes: []config.Route{{PathPrefix: "/"}}}},
+		TLS:      &config.TLS{Mode: config.TLSSelfSigned},
+	}
+
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("docker run --rm -v project-certs:/certs -v /home/deploy/projects/project/certs-upload:/upload:ro", fake.Response{Output: "cp: can't create '/certs/example.com.crt': No space left on device", ExitCode: 1})
+	err := NewDeployment(runner, nil).deployCertificates(context.Background(), "project", cfg)
+	assert.ErrorContains(t, err, "failed to install certificates: command failed: exit status 1")
+	assert.ErrorContains(t, err, "No space left on device")
+	assert.NotContains(t, callLines(runner), "docker rm -f project-zero", "without a certificate manager there is nothing to remove")
+}
+
+func TestDeployCertificates_ManagerLeft(t *testing.T) {
+	cfg := &config.Config{
+		Project:  config.Project{Name: "project", Domain: "example.com"},
+		Services: []config.Service{{Name: "web", Port: 80, Routes: []config.Route{{PathPrefix: "/"}}}},
+		TLS:      &config.TLS{Mode: config.TLSSelfSigned},
+	}
+
+	runner := fake.NewRunner()
+	runner.On("docker ps -aq --filter name=^project-zero$", fake.Response{Output: "abc123"})
+	runner.On("docker rm -f project-zero", fake.Response{Output: "Error response from daemon: removal of container project-zero is already in progress", ExitCode: 1})
+	err := NewDeployment(runner, nil).deployCertificates(context.Background(), "project", cfg)
+	assert.ErrorContains(t, err, "failed to remove certificate manager")
+	assert.ErrorContains(t, err, "already in progress")
+}
@@ -0,0 +1,136 @@
+package deployment
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner"
+	"github.com/yarlson/ftl/pkg/telemetry"
+)
+
+// spans holds the span of the running deploy and of its current stage. Most
+// steps pass context.Background() to the runner, so the spans of their
+// commands become children of the stage instead of a span in their context.
+type spans struct {
+	mu     sync.Mutex
+	deploy context.Context
+	stage  context.Context
+}
+
+// startDeploySpan starts the span of a deploy of project, which ends with
+// the returned function.
+func (d *Deployment) startDeploySpan(ctx context.Context, project string) (context.Context, func(err error)) {
+	ctx, span := telemetry.Tracer().Start(ctx, "deploy", trace.WithAttributes(
+		telemetry.AttrProject.String(project),
+		telemetry.AttrServer.String(d.runner.Host()),
+	))
+
+	d.spans.mu.Lock()
+	d.spans.deploy, d.spans.stage = ctx, nil
+	d.spans.mu.Unlock()
+
+	return ctx, func(err error) {
+		d.spans.mu.Lock()
+		if d.spans.stage != nil {
+			telemetry.End(trace.SpanFromContext(d.spans.stage), err)
+		}
+		d.spans.deploy, d.spans.stage = nil, nil
+		d.spans.mu.Unlock()
+		telemetry.End(span, err)
+	}
+}
+
+// startStageSpan ends the span of the previous stage of the deploy and starts
+// one for the stage reported with message, such as "Deploying services...".
+func (d *Deployment) startStageSpan(message string) {
+	d.spans.mu.Lock()
+	defer d.spans.mu.Unlock()
+	if d.spans.deploy == nil {
+		return
+	}
+	if d.spans.stage != nil {
+		trace.SpanFromContext(d.spans.stage).End()
+	}
+	d.spans.stage, _ = telemetry.Tracer().Start(d.spans.deploy, strings.TrimSuffix(message, "..."))
+}
+
+// spanParent returns ctx when it holds a span, or else the context of the
+// current stage or deploy.
+func (d *Deployment) spanParent(ctx context.Context) context.Context {
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	d.spans.mu.Lock()
+	defer d.spans.mu.Unlock()
+	switch {
+	case d.spans.stage != nil:
+		return d.spans.stage
+	case d.spans.deploy != nil:
+		return d.spans.deploy
+	}
+	return ctx
+}
+
+// startSpan starts a span of the deploy for service, which may be nil.
+func (d *Deployment) startSpan(ctx context.Context, name string, service *config.Service, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	if service != nil {
+		attributes = append(attributes, telemetry.AttrService.String(service.Name))
+		if service.Image != "" {
+			attributes = append(attributes, telemetry.AttrImage.String(service.Image))
+		}
+	}
+	return telemetry.Tracer().Start(d.spanParent(ctx), name, trace.WithAttributes(attributes...))
+}
+
+// tracedRunner records a span for every command it runs on the server. Only
+// the command and its subcommand, such as "docker run", are recorded, as the
+// arguments may hold secrets.
+type tracedRunner struct {
+	Runner
+	deployment *Deployment
+}
+
+func (r *tracedRunner) RunCommand(ctx context.Context, command string, args ...string) (io.ReadCloser, error) {
+	ctx, span := r.startCommandSpan(ctx, command, args)
+	output, err := r.Runner.RunCommand(ctx, command, args...)
+	telemetry.End(span, err)
+	return output, err
+}
+
+// RunCommandWithOutput runs a command to completion, recording a span like
+// RunCommand, and fails when it exits with a non-zero status.
+func (r *tracedRunner) RunCommandWithOutput(ctx context.Context, w io.Writer, command string, args ...string) error {
+	ctx, span := r.startCommandSpan(ctx, command, args)
+	err := runner.RunCheckedWithOutput(ctx, r.Runner, w, command, args...)
+	telemetry.End(span, err)
+	return err
+}
+
+// startCommandSpan starts the span of a command, named after the command and
+// its subcommand.
+func (r *tracedRunner) startCommandSpan(ctx context.Context, command string, args []string) (context.Context, trace.Span) {
+	name := command
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name += " " + args[0]
+	}
+	return r.deployment.startSpan(ctx, name, nil,
+		telemetry.AttrCommand.String(name),
+		telemetry.AttrServer.String(r.Host()),
+	)
+}
+
+func (r *tracedRunner) CopyFile(ctx context.Context, from, to string) error {
+	ctx, span := r.deployment.startSpan(ctx, "copy file", nil,
+		attribute.String("file.path", to),
+		telemetry.AttrServer.String(r.Host()),
+	)
+	err := r.Runner.CopyFile(ctx, from, to)
+	telemetry.End(span, err)
+	return err
+}
@@ -0,0 +1,55 @@
+package deployment
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	runner := fake.NewRunner()
+	runner.SetHost("203.0.113.10")
+	d := NewDeployment(runner, nil)
+
+	_, end := d.startDeploySpan(context.Background(), "project")
+	d.stage("Creating volumes...")
+	_, err := d.runCommand(context.Background(), "docker", "volume", "create", "project-data")
+	require.NoError(t, err)
+	require.NoError(t, d.timed(PhasePull, &config.Service{Name: "web", Image: "nginx:1.27"}, func() error { return nil }))
+	end(errors.New("boom"))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 4)
+	command, pull, stage, deploy := spans[0], spans[1], spans[2], spans[3]
+
+	assert.Equal(t, "docker volume", command.Name())
+	assert.Contains(t, command.Attributes(), attribute.String("server.address", "203.0.113.10"))
+	assert.Equal(t, stage.SpanContext().SpanID(), command.Parent().SpanID())
+
+	assert.Equal(t, "pull", pull.Name())
+	assert.Contains(t, pull.Attributes(), attribute.String("ftl.service", "web"))
+	assert.Contains(t, pull.Attributes(), attribute.String("container.image.name", "nginx:1.27"))
+
+	assert.Equal(t, "Creating volumes", stage.Name())
+	assert.Equal(t, deploy.SpanContext().SpanID(), stage.Parent().SpanID())
+
+	assert.Equal(t, "deploy", deploy.Name())
+	assert.Contains(t, deploy.Attributes(), attribute.String("ftl.project", "project"))
+	assert.Equal(t, codes.Error, deploy.Status().Code)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/ssh"
 	"github.com/yarlson/ftl/pkg/tunnel"
 )
 
@@ -22,10 +23,11 @@ func hasLocalHooks(cfg *config.Config) bool {
 }
 
 func (d *Deployment) startTunnels(ctx context.Context, cfg *config.Config) error {
-	err := tunnel.StartTunnels(
+	_, err := tunnel.StartTunnels(
 		ctx,
 		cfg.Server.Host, cfg.Server.Port,
 		cfg.Server.User, cfg.Server.SSHKey,
+		(*ssh.Jump)(cfg.Server.ProxyJump),
 		tunnel.CollectDependencyTunnels(cfg),
 	)
 	if err != nil {
@@ -0,0 +1,197 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/yarlson/pin"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/docker"
+)
+
+// snapshotImage archives dependency volumes before an upgrade.
+const snapshotImage = "alpine:3"
+
+// DependencyUpgrade is a deployed dependency whose configured image differs
+// from the image its container was created from.
+type DependencyUpgrade struct {
+	Dependency *config.Dependency
+	Running    string
+	// Major is set when the major version changes, or when the two images
+	// cannot be compared, as with a different repository or a "latest" tag.
+	Major bool
+}
+
+// Stateful reports whether the dependency keeps data in named volumes.
+func (u DependencyUpgrade) Stateful() bool {
+	return len(namedVolumes(u.Dependency.Volumes)) > 0
+}
+
+func (u DependencyUpgrade) String() string {
+	return fmt.Sprintf("%s: %s → %s", u.Dependency.Name, u.Running, u.Dependency.Image)
+}
+
+// DependencyUpgrades compares the images of the deployed dependencies with
+// cfg. Dependencies that are not deployed yet are skipped. When names is not
+// nil only the named dependencies are compared.
+func (d *Deployment) DependencyUpgrades(ctx context.Context, project string, cfg *config.Config, names []string) ([]DependencyUpgrade, error) {
+	var upgrades []DependencyUpgrade
+	for i := range cfg.Dependencies {
+		dependency := &cfg.Dependencies[i]
+		if names != nil && !slices.Contains(names, dependency.Name) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		network := docker.ServiceNetwork(project, DependencyService(dependency))
+		status, err := d.dockerManager.GetContainerStatus(network, dependency.Name)
+		if err != nil {
+			return nil, err
+		}
+		if status == docker.ContainerStatusNotFound {
+			continue
+		}
+
+		details, err := d.dockerManager.InspectContainer(network, dependency.Name)
+		if err != nil {
+			return nil, err
+		}
+		if details.Config.Image == dependency.Image {
+			continue
+		}
+
+		upgrades = append(upgrades, DependencyUpgrade{
+			Dependency: dependency,
+			Running:    details.Config.Image,
+			Major:      majorUpgrade(details.Config.Image, dependency.Image),
+		})
+	}
+
+	return upgrades, nil
+}
+
+// UpgradeDependency stops the dependency, archives each of its named volumes
+// into the snapshots folder of the project and redeploys it with the
+// configured image. It returns the paths of the snapshots on the server. The
+// old container is started again when a snapshot fails.
+func (d *Deployment) UpgradeDependency(ctx context.Context, project string, spinner *pin.Pin, upgrade DependencyUpgrade) ([]string, error) {
+	d.spinner = spinner
+	dependency := upgrade.Dependency
+
+	var snapshots []string
+	if upgrade.Stateful() {
+		container := containerName(project, dependency.Name, "")
+		d.progress(fmt.Sprintf("Stopping %s...", dependency.Name))
+		if _, err := d.runChecked(ctx, "docker", "stop", container); err != nil {
+			return nil, fmt.Errorf("failed to stop %s: %w", dependency.Name, err)
+		}
+
+		var err error
+		snapshots, err = d.snapshotVolumes(ctx, project, dependency)
+		if err != nil {
+			if _, startErr := d.runChecked(context.Background(), "docker", "start", container); startErr != nil {
+				return nil, fmt.Errorf("%w (failed to start %s again: %v)", err, dependency.Name, startErr)
+			}
+			return nil, err
+		}
+	}
+
+	d.progress(fmt.Sprintf("Upgrading %s to %s...", dependency.Name, dependency.Image))
+	if err := d.startDependency(project, dependency); err != nil {
+		return snapshots, fmt.Errorf("failed to upgrade dependency %s: %w", dependency.Name, err)
+	}
+
+	return snapshots, nil
+}
+
+// snapshotVolumes archives the named volumes of dependency as tar.gz files in
+// the snapshots folder of the project.
+func (d *Deployment) snapshotVolumes(ctx context.Context, project string, dependency *config.Dependency) ([]string, error) {
+	projectPath, err := d.projectFolder(project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project folder path: %w", err)
+	}
+	dir := filepath.Join(projectPath, "snapshots")
+	if _, err := d.runChecked(ctx, "mkdir", "-p", dir); err != nil {
+		return nil, fmt.Errorf("failed to create snapshots folder: %w", err)
+	}
+
+	stamp := time.Now().UTC().Format("20060102-150405")
+	var snapshots []string
+	for _, volume := range namedVolumes(dependency.Volumes) {
+		d.progress(fmt.Sprintf("Snapshotting volume %s...", volume))
+		file := fmt.Sprintf("%s-%s-%s.tar.gz", dependency.Name, volume, stamp)
+		if output, err := d.runChecked(ctx, "docker", "run", "--rm",
+			"-v", fmt.Sprintf("%s-%s:/data:ro", project, volume),
+			"-v", dir+":/snapshots",
+			snapshotImage, "tar", "-czf", "/snapshots/"+file, "-C", "/data", "."); err != nil {
+			return nil, fmt.Errorf("failed to snapshot volume %s: %w\n\x1b[93mOutput from tar:\x1b[0m\n\x1b[90m%s\x1b[0m", volume, err, output)
+		}
+		// The upgrade must not go ahead on an archive that was not written.
+		path := filepath.Join(dir, file)
+		if _, err := d.runChecked(ctx, "test", "-s", path); err != nil {
+			return nil, fmt.Errorf("snapshot of volume %s is empty: %w", volume, err)
+		}
+		snapshots = append(snapshots, path)
+	}
+
+	return snapshots, nil
+}
+
+// namedVolumes returns the names of the named volumes among volume
+// references, skipping bind mounts.
+func namedVolumes(volumes []string) []string {
+	var names []string
+	for _, volume := range volumes {
+		if volume != "" && unicode.IsLetter(rune(volume[0])) {
+			name, _, _ := strings.Cut(volume, ":")
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// majorUpgrade reports whether moving from the running to the configured
+// image changes the major version, treating images it cannot compare as a
+// major change.
+func majorUpgrade(running, configured string) bool {
+	if docker.Repository(running) != docker.Repository(configured) {
+		return true
+	}
+	from, ok := majorVersion(running)
+	if !ok {
+		return true
+	}
+	to, ok := majorVersion(configured)
+	if !ok {
+		return true
+	}
+	return from != to
+}
+
+// majorVersion returns the leading number of the tag of image, as 16 in
+// "postgres:16.2-alpine".
+func majorVersion(image string) (int, bool) {
+	image, _, _ = strings.Cut(image, "@")
+	tag := strings.TrimPrefix(strings.TrimPrefix(image, docker.Repository(image)), ":")
+	tag = strings.TrimPrefix(tag, "v")
+
+	end := strings.IndexFunc(tag, func(r rune) bool { return r < '0' || r > '9' })
+	if end == -1 {
+		end = len(tag)
+	}
+	major, err := strconv.Atoi(tag[:end])
+	if err != nil {
+		return 0, false
+	}
+	return major, true
+}
@@ -0,0 +1,97 @@
+package deployment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestMajorUpgrade(t *testing.T) {
+	tests := []struct {
+		running, configured string
+		major               bool
+	}{
+		{"postgres:16", "postgres:17", true},
+		{"postgres:16.2", "postgres:16.4-alpine", false},
+		{"redis:7", "redis:7.4", false},
+		{"ghcr.io/acme/cache:v1.2", "ghcr.io/acme/cache:v2.0", true},
+		{"localhost:5000/db:3", "localhost:5000/db:3.1", false},
+		{"postgres:latest", "postgres:17", true},
+		{"postgres:16", "mariadb:16", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.major, majorUpgrade(tt.running, tt.configured), "%s → %s", tt.running, tt.configured)
+	}
+}
+
+func TestDependencyUpgrades(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker ps -aq", fake.Response{Output: "c1\nc2\n"})
+	runner.On("docker inspect c1", fake.Response{Output: `[{"Id": "c1", "Config": {"Image": "postgres:16"}, "State": {"Status": "running"}, "NetworkSettings": {"Networks": {"project": {"Aliases": ["db"]}}}}]`})
+	runner.On("docker inspect c2", fake.Response{Output: `[{"Id": "c2", "Config": {"Image": "redis:7"}, "State": {"Status": "running"}, "NetworkSettings": {"Networks": {"project": {"Aliases": ["cache"]}}}}]`})
+	cfg := &config.Config{Dependencies: []config.Dependency{
+		{Name: "db", Image: "postgres:17", Volumes: []string{"db_data:/var/lib/postgresql/data"}},
+		{Name: "cache", Image: "redis:7"},
+		{Name: "queue", Image: "rabbitmq:4"},
+	}}
+
+	upgrades, err := NewDeployment(runner, nil).DependencyUpgrades(context.Background(), "project", cfg, nil)
+	require.NoError(t, err)
+	require.Len(t, upgrades, 1)
+	assert.Equal(t, "db", upgrades[0].Dependency.Name)
+	assert.Equal(t, "postgres:16", upgrades[0].Running)
+	assert.True(t, upgrades[0].Major)
+	assert.True(t, upgrades[0].Stateful())
+
+	upgrades, err = NewDeployment(runner, nil).DependencyUpgrades(context.Background(), "project", cfg, []string{"cache"})
+	require.NoError(t, err)
+	assert.Empty(t, upgrades)
+}
+
+func TestUpgradeDependency_SnapshotFailure(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("docker run", fake.Response{Output: "tar: write error: No space left on device", ExitCode: 1})
+	upgrade := DependencyUpgrade{
+		Dependency: &config.Dependency{Name: "db", Image: "postgres:17", Volumes: []string{"db_data:/var/lib/postgresql/data", "./init:/docker-entrypoint-initdb.d"}},
+		Running:    "postgres:16",
+		Major:      true,
+	}
+
+	_, err := NewDeployment(runner, nil).UpgradeDependency(context.Background(), "project", nil, upgrade)
+	assert.ErrorContains(t, err, "failed to snapshot volume db_data")
+	assert.ErrorContains(t, err, "No space left on device")
+
+	var calls []string
+	for _, call := range runner.Calls() {
+		calls = append(calls, call.String())
+	}
+	require.Len(t, calls, 5)
+	assert.Equal(t, "docker stop project-db", calls[0])
+	assert.Equal(t, "mkdir -p /home/deploy/projects/project/snapshots", calls[2])
+	assert.Contains(t, calls[3], "-v project-db_data:/data:ro -v /home/deploy/projects/project/snapshots:/snapshots alpine:3 tar -czf /snapshots/db-db_data-")
+	assert.Equal(t, "docker start project-db", calls[4])
+}
+
+func TestUpgradeDependency_EmptySnapshot(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("test -s", fake.Response{ExitCode: 1})
+	upgrade := DependencyUpgrade{
+		Dependency: &config.Dependency{Name: "db", Image: "postgres:17", Volumes: []string{"db_data:/var/lib/postgresql/data"}},
+		Running:    "postgres:16",
+		Major:      true,
+	}
+
+	_, err := NewDeployment(runner, nil).UpgradeDependency(context.Background(), "project", nil, upgrade)
+	assert.ErrorContains(t, err, "snapshot of volume db_data is empty")
+
+	calls := runner.Calls()
+	require.NotEmpty(t, calls)
+	assert.Equal(t, "docker start project-db", calls[len(calls)-1].String())
+}
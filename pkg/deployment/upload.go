@@ -0,0 +1,180 @@
+package deployment
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+// uploadImage changes the owner of uploads, which the deploy user may not be
+// allowed to do itself.
+const uploadImage = "alpine:3"
+
+// uploadFiles copies the uploads of service to the server. Uploads whose
+// content and settings have not changed since the last deploy are skipped.
+func (d *Deployment) uploadFiles(ctx context.Context, project string, service *config.Service) error {
+	if err := digestUploads(service); err != nil {
+		return err
+	}
+
+	for i := range service.Uploads {
+		upload := &service.Uploads[i]
+
+		remote := upload.Remote
+		if !path.IsAbs(remote) {
+			projectPath, err := d.projectFolder(project)
+			if err != nil {
+				return err
+			}
+			remote = path.Join(projectPath, remote)
+		}
+
+		dir, name := path.Dir(remote), path.Base(remote)
+		marker := path.Join(dir, "."+name+".ftl-upload")
+		current, err := d.runCommand(ctx, "sh", "-c", "cat "+shell.Quote(marker)+" 2>/dev/null || true")
+		if err != nil {
+			return fmt.Errorf("failed to check upload %s: %w", remote, err)
+		}
+		if current == upload.Digest {
+			continue
+		}
+
+		d.progress(fmt.Sprintf("Uploading %s to %s...", upload.Local, remote))
+		if err := d.upload(ctx, upload, dir, name); err != nil {
+			return fmt.Errorf("failed to upload %s to %s: %w", upload.Local, remote, err)
+		}
+	}
+	return nil
+}
+
+// upload copies the local side of upload next to dir/name and then swaps it
+// in, so the remote path is never left half written.
+func (d *Deployment) upload(ctx context.Context, upload *config.Upload, dir, name string) error {
+	info, err := os.Stat(upload.Local)
+	if err != nil {
+		return err
+	}
+
+	staging := "." + name + ".ftl-new"
+	if _, err := d.runChecked(ctx, "sh", "-c", fmt.Sprintf("mkdir -p %s && rm -rf %s",
+		shell.Quote(dir), shell.Quote(path.Join(dir, staging)))); err != nil {
+		return fmt.Errorf("failed to prepare %s: %w", dir, err)
+	}
+
+	if info.IsDir() {
+		archive, err := os.CreateTemp("", "ftl-upload-*.tar.gz")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary file: %w", err)
+		}
+		_ = archive.Close()
+		defer os.Remove(archive.Name())
+
+		if _, err := d.localRunner.RunCommand(ctx, "tar", "-czf", archive.Name(), "-C", upload.Local, "."); err != nil {
+			return fmt.Errorf("failed to archive %s: %w", upload.Local, err)
+		}
+
+		remoteArchive := path.Join(dir, staging+".tar.gz")
+		if err := d.runner.CopyFile(ctx, archive.Name(), remoteArchive); err != nil {
+			return err
+		}
+		if output, err := d.runChecked(ctx, "sh", "-c", fmt.Sprintf("mkdir %[1]s && tar -xzf %[2]s -C %[1]s && rm -f %[2]s",
+			shell.Quote(path.Join(dir, staging)), shell.Quote(remoteArchive))); err != nil {
+			return fmt.Errorf("failed to extract archive: %w\n\x1b[93mOutput from tar:\x1b[0m\n\x1b[90m%s\x1b[0m", err, output)
+		}
+	} else if err := d.runner.CopyFile(ctx, upload.Local, path.Join(dir, staging)); err != nil {
+		return err
+	}
+
+	script := uploadScript(upload, name, staging, info.IsDir())
+	var output string
+	if upload.Owner == "" {
+		output, err = d.runChecked(ctx, "sh", "-c", "cd "+shell.Quote(dir)+" && "+script)
+	} else {
+		output, err = d.runChecked(ctx, "docker", "run", "--rm", "-v", dir+":/upload", "-w", "/upload", uploadImage, "sh", "-c", script)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to install upload: %w\n\x1b[93mOutput:\x1b[0m\n\x1b[90m%s\x1b[0m", err, output)
+	}
+	return nil
+}
+
+// uploadScript returns the commands, run in the directory of the upload, that
+// apply its mode and owner to the staged copy, put it in place of name and
+// record its digest.
+func uploadScript(upload *config.Upload, name, staging string, dir bool) string {
+	old := "." + name + ".ftl-old"
+	var commands []string
+	if upload.Mode != "" {
+		if dir {
+			commands = append(commands, fmt.Sprintf("find %s -type f -exec chmod %s {} +", shell.Quote(staging), upload.Mode))
+		} else {
+			commands = append(commands, fmt.Sprintf("chmod %s %s", upload.Mode, shell.Quote(staging)))
+		}
+	}
+	if upload.Owner != "" {
+		commands = append(commands, fmt.Sprintf("chown -R %s %s", upload.Owner, shell.Quote(staging)))
+	}
+	commands = append(commands,
+		"rm -rf "+shell.Quote(old),
+		fmt.Sprintf("{ [ ! -e %[1]s ] || mv %[1]s %[2]s; }", shell.Quote(name), shell.Quote(old)),
+		fmt.Sprintf("mv %s %s", shell.Quote(staging), shell.Quote(name)),
+		"rm -rf "+shell.Quote(old),
+		fmt.Sprintf("printf %%s %s > %s", upload.Digest, shell.Quote("."+name+".ftl-upload")),
+	)
+	return strings.Join(commands, " && ")
+}
+
+// digestUploads sets the digest of every upload of service, which its hash
+// depends on.
+func digestUploads(service *config.Service) error {
+	for i := range service.Uploads {
+		upload := &service.Uploads[i]
+		digest, err := uploadDigest(upload)
+		if err != nil {
+			return fmt.Errorf("failed to read upload %s: %w", upload.Local, err)
+		}
+		upload.Digest = digest
+	}
+	return nil
+}
+
+// uploadDigest returns a hash of the content of the local side of upload,
+// with the names of the files of a directory, and of its mode and owner.
+func uploadDigest(upload *config.Upload) (string, error) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "mode=%s owner=%s\n", upload.Mode, upload.Owner)
+
+	err := filepath.WalkDir(upload.Local, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(upload.Local, name)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		fmt.Fprintf(hash, "%s\n", filepath.ToSlash(rel))
+		_, err = io.Copy(hash, file)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
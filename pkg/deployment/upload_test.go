@@ -0,0 +1,84 @@
+package deployment
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestUploadFiles(t *testing.T) {
+	local := filepath.Join(t.TempDir(), "app.toml")
+	require.NoError(t, os.WriteFile(local, []byte("debug = false\n"), 0600))
+
+	service := &config.Service{Name: "web", Uploads: []config.Upload{
+		{Local: local, Remote: "config/app.toml", Mode: "0640"},
+		{Local: local, Remote: "/srv/license.key", Owner: "1000:1000"},
+	}}
+
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	require.NoError(t, NewDeployment(runner, nil).uploadFiles(context.Background(), "project", service))
+
+	content, ok := runner.File("/home/deploy/projects/project/config/.app.toml.ftl-new")
+	require.True(t, ok)
+	assert.Equal(t, "debug = false\n", string(content))
+	_, ok = runner.File("/srv/.license.key.ftl-new")
+	assert.True(t, ok)
+
+	digest := service.Uploads[0].Digest
+	require.NotEmpty(t, digest)
+	assert.NotEqual(t, digest, service.Uploads[1].Digest, "the mode and owner are part of the digest")
+
+	var lines []string
+	for _, call := range runner.Calls() {
+		lines = append(lines, call.String())
+	}
+	assert.Contains(t, lines, "sh -c cd '/home/deploy/projects/project/config' && chmod 0640 '.app.toml.ftl-new' && rm -rf '.app.toml.ftl-old' && "+
+		"{ [ ! -e 'app.toml' ] || mv 'app.toml' '.app.toml.ftl-old'; } && mv '.app.toml.ftl-new' 'app.toml' && rm -rf '.app.toml.ftl-old' && "+
+		"printf %s "+digest+" > '.app.toml.ftl-upload'")
+	assert.True(t, strings.HasPrefix(lines[len(lines)-1], "docker run --rm -v /srv:/upload -w /upload alpine:3 sh -c chown -R 1000:1000 '.license.key.ftl-new' && "),
+		"an owner is set from a container")
+
+	runner = fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("sh -c cat '/home/deploy/projects/project/config/.app.toml.ftl-upload'", fake.Response{Output: digest})
+	service.Uploads = service.Uploads[:1]
+	require.NoError(t, NewDeployment(runner, nil).uploadFiles(context.Background(), "project", service))
+	_, ok = runner.File("/home/deploy/projects/project/config/.app.toml.ftl-new")
+	assert.False(t, ok, "an unchanged upload is skipped")
+
+	runner = fake.NewRunner()
+	runner.On("docker run", fake.Response{Output: "chown: invalid user: '1000:1000'", ExitCode: 1})
+	service.Uploads = []config.Upload{{Local: local, Remote: "/srv/license.key", Owner: "1000:1000"}}
+	err := NewDeployment(runner, nil).uploadFiles(context.Background(), "project", service)
+	assert.ErrorContains(t, err, "failed to install upload: command failed: exit status 1")
+	assert.ErrorContains(t, err, "invalid user")
+}
+
+func TestUploadDigest(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "geoip"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "geoip", "City.mmdb"), []byte("v1"), 0644))
+
+	upload := &config.Upload{Local: filepath.Join(dir, "geoip")}
+	first, err := uploadDigest(upload)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "geoip", "City.mmdb"), []byte("v2"), 0644))
+	second, err := uploadDigest(upload)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+
+	require.NoError(t, os.Rename(filepath.Join(dir, "geoip", "City.mmdb"), filepath.Join(dir, "geoip", "Country.mmdb")))
+	third, err := uploadDigest(upload)
+	require.NoError(t, err)
+	assert.NotEqual(t, second, third, "renaming a file changes the digest")
+}
@@ -0,0 +1,76 @@
+package deployment
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/docker"
+)
+
+// verifyArgs returns the cosign arguments that verify the signature of image.
+func verifyArgs(verify *config.Verify, image string) []string {
+	args := []string{"verify", "--output", "json"}
+	if verify.Key != "" {
+		args = append(args, "--key", verify.Key)
+	} else {
+		args = append(args,
+			"--certificate-identity", verify.Identity,
+			"--certificate-oidc-issuer", verify.Issuer,
+		)
+	}
+	return append(args, image)
+}
+
+// verifyImage checks the cosign signature of the service image on this
+// machine and returns the reference by digest of the verified image, so the
+// server cannot pull anything else even if the tag moves.
+func (d *Deployment) verifyImage(ctx context.Context, service *config.Service) (string, error) {
+	output, err := d.localRunner.RunCommand(ctx, "cosign", verifyArgs(service.Verify, service.Image)...)
+	if err != nil {
+		return "", fmt.Errorf("signature verification of %s failed: %w", service.Image, err)
+	}
+	defer output.Close()
+
+	digest, err := verifiedDigest(output)
+	if err != nil {
+		return "", fmt.Errorf("signature verification of %s failed: %w", service.Image, err)
+	}
+
+	return docker.Repository(service.Image) + "@" + digest, nil
+}
+
+// verifiedDigest reads the manifest digest from the JSON printed by cosign
+// verify. Its status messages are skipped.
+func verifiedDigest(output io.Reader) (string, error) {
+	scanner := bufio.NewScanner(output)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "[") {
+			continue
+		}
+
+		var signatures []struct {
+			Critical struct {
+				Image struct {
+					Digest string `json:"docker-manifest-digest"`
+				} `json:"image"`
+			} `json:"critical"`
+		}
+		if err := json.Unmarshal([]byte(line), &signatures); err != nil {
+			return "", fmt.Errorf("failed to parse cosign output: %w", err)
+		}
+		for _, signature := range signatures {
+			if signature.Critical.Image.Digest != "" {
+				return signature.Critical.Image.Digest, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("cosign did not report a verified signature")
+}
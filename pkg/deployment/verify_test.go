@@ -0,0 +1,37 @@
+package deployment
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+)
+
+func TestVerifyArgs(t *testing.T) {
+	assert.Equal(t,
+		[]string{"verify", "--output", "json", "--key", "cosign.pub", "ghcr.io/acme/web:1.0"},
+		verifyArgs(&config.Verify{Key: "cosign.pub"}, "ghcr.io/acme/web:1.0"),
+	)
+	assert.Equal(t,
+		[]string{"verify", "--output", "json", "--certificate-identity", "ci@acme.com", "--certificate-oidc-issuer", "https://accounts.google.com", "ghcr.io/acme/web:1.0"},
+		verifyArgs(&config.Verify{Identity: "ci@acme.com", Issuer: "https://accounts.google.com"}, "ghcr.io/acme/web:1.0"),
+	)
+}
+
+func TestVerifiedDigest(t *testing.T) {
+	output := `
+Verification for ghcr.io/acme/web:1.0 --
+The following checks were performed on each of these signatures:
+  - The cosign claims were validated
+[{"critical":{"identity":{"docker-reference":"ghcr.io/acme/web"},"image":{"docker-manifest-digest":"sha256:4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945"},"type":"cosign container image signature"},"optional":null}]
+`
+	digest, err := verifiedDigest(strings.NewReader(output))
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945", digest)
+
+	_, err = verifiedDigest(strings.NewReader("Error: no matching signatures"))
+	assert.Error(t, err)
+}
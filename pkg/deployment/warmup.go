@@ -0,0 +1,51 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yarlson/ftl/pkg/config"
+)
+
+const defaultWarmupTimeout = 2 * time.Minute
+
+// warmUp runs the warmup of service against its new container, before
+// traffic switches to it.
+func (d *Deployment) warmUp(ctx context.Context, service *config.Service, container string) error {
+	warmup := service.Warmup
+	if warmup == nil {
+		return nil
+	}
+
+	timeout := warmup.Timeout.Duration()
+	if timeout <= 0 {
+		timeout = defaultWarmupTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	d.progress(fmt.Sprintf("Warming up %s...", service.Name))
+	if warmup.Command != "" {
+		if output, err := d.runChecked(ctx, "docker", "exec", container, "sh", "-c", warmup.Command); err != nil {
+			return fmt.Errorf("warmup command failed: %w\n\x1b[93mOutput from the warmup:\x1b[0m\n\x1b[90m%s\x1b[0m", err, output)
+		}
+		return nil
+	}
+
+	requests := warmup.Requests
+	if requests < 1 {
+		requests = 1
+	}
+	args := []string{"run", "--rm", "--network", "container:" + container, SmokeTestImage, "-s"}
+	for i := 0; i < requests; i++ {
+		for _, path := range warmup.URLs {
+			args = append(args, "-o", "/dev/null", fmt.Sprintf("http://localhost:%d%s", service.Port, path))
+		}
+	}
+	if _, err := d.runChecked(ctx, "docker", args...); err != nil {
+		return fmt.Errorf("warmup requests failed: %w", err)
+	}
+
+	return nil
+}
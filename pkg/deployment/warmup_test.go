@@ -0,0 +1,42 @@
+package deployment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestWarmUp(t *testing.T) {
+	runner := fake.NewRunner()
+	service := &config.Service{Name: "web", Port: 8080, Warmup: &config.Warmup{URLs: []string{"/", "/api/products"}, Requests: 2}}
+
+	require.NoError(t, NewDeployment(runner, nil).warmUp(context.Background(), service, "project-web_new"))
+	require.Len(t, runner.Calls(), 1)
+	assert.Equal(t, "docker run --rm --network container:project-web_new "+SmokeTestImage+" -s"+
+		" -o /dev/null http://localhost:8080/ -o /dev/null http://localhost:8080/api/products"+
+		" -o /dev/null http://localhost:8080/ -o /dev/null http://localhost:8080/api/products", runner.Calls()[0].String())
+
+	runner = fake.NewRunner()
+	runner.On("docker exec", fake.Response{Output: "redis: connection refused", ExitCode: 1})
+	service.Warmup = &config.Warmup{Command: "./bin/warm-cache"}
+	err := NewDeployment(runner, nil).warmUp(context.Background(), service, "project-web_new")
+	assert.ErrorContains(t, err, "warmup command failed: command failed: exit status 1")
+	assert.ErrorContains(t, err, "redis: connection refused")
+	assert.Equal(t, "docker exec project-web_new sh -c ./bin/warm-cache", runner.Calls()[0].String())
+
+	// curl exits with 7 when the container does not accept connections.
+	runner = fake.NewRunner()
+	runner.On("docker run", fake.Response{ExitCode: 7})
+	service.Warmup = &config.Warmup{URLs: []string{"/"}}
+	err = NewDeployment(runner, nil).warmUp(context.Background(), service, "project-web_new")
+	assert.ErrorContains(t, err, "warmup requests failed: command failed: exit status 7")
+
+	runner = fake.NewRunner()
+	require.NoError(t, NewDeployment(runner, nil).warmUp(context.Background(), &config.Service{Name: "web"}, "project-web_new"))
+	assert.Empty(t, runner.Calls())
+}
@@ -0,0 +1,71 @@
+package deployment
+
+import (
+	"fmt"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/docker"
+)
+
+// crashWatcherScript follows docker events and posts to the container's
+// webhook each time its restart count reaches a multiple of its threshold.
+const crashWatcherScript = `docker events --filter type=container --filter event=die --filter label=` + docker.CrashAlertWebhookLabel + ` --format '{{.Actor.Attributes.name}}' |
+while read -r name; do
+  sleep 1
+  count=$(docker inspect -f '{{.RestartCount}}' "$name" 2>/dev/null) || continue
+  threshold=$(docker inspect -f '{{index .Config.Labels "` + docker.CrashAlertThresholdLabel + `"}}' "$name")
+  webhook=$(docker inspect -f '{{index .Config.Labels "` + docker.CrashAlertWebhookLabel + `"}}' "$name")
+  if [ "$count" -gt 0 ] && [ $((count % threshold)) -eq 0 ]; then
+    message="Container $name on $(hostname) is crash-looping: restarted $count times"
+    wget -q -O /dev/null --header 'Content-Type: application/json' \
+      --post-data "{\"text\":\"$message\",\"content\":\"$message\"}" "$webhook" || true
+  fi
+done`
+
+// withDockerAccess gives a service container access to the Docker daemon ftl
+// deploys to, by mounting its socket or, for TCP daemons, setting DOCKER_HOST.
+func withDockerAccess(service *config.Service, server *config.Server) {
+	dockerHost := ""
+	if server != nil {
+		dockerHost = server.DockerHost
+	}
+
+	if socket := docker.SocketPath(dockerHost); socket != "" {
+		service.Volumes = append(service.Volumes, socket+":"+docker.DefaultSocket)
+		return
+	}
+	service.Env = append(service.Env, "DOCKER_HOST="+dockerHost)
+}
+
+func hasCrashAlerts(cfg *config.Config) bool {
+	for _, service := range cfg.Services {
+		if service.CrashAlert != nil {
+			return true
+		}
+	}
+	for _, dependency := range cfg.Dependencies {
+		if dependency.CrashAlert != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deployCrashWatcher starts the container that sends crash-loop alerts.
+func (d *Deployment) deployCrashWatcher(project string, cfg *config.Config) error {
+	service := &config.Service{
+		Name:         "watcher",
+		Image:        "docker:cli",
+		Entrypoint:   []string{"sh"},
+		CommandSlice: []string{"-c", crashWatcherScript},
+		Recreate:     true,
+	}
+	withDockerAccess(service, cfg.Server)
+
+	if err := d.deployService(project, service); err != nil {
+		return fmt.Errorf("failed to deploy crash watcher: %w", err)
+	}
+
+	return nil
+}
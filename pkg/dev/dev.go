@@ -0,0 +1,287 @@
+// Package dev runs a project on the local Docker daemon with the same
+// services, dependencies, volumes and proxy routes it has in production.
+package dev
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/build"
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/deployment"
+	"github.com/yarlson/ftl/pkg/docker"
+	"github.com/yarlson/ftl/pkg/proxy"
+	"github.com/yarlson/ftl/pkg/runner/local"
+)
+
+// networkSuffix keeps the local stack apart from anything else running on
+// the Docker daemon, including a local copy of the production network.
+const networkSuffix = "-dev"
+
+// Environment is a project running locally.
+type Environment struct {
+	cfg     *config.Config
+	network string
+	port    int
+	workDir string
+	shell   *local.Shell
+	docker  *docker.DockerManager
+	builder *build.Build
+
+	// Output receives every line of build output with the service name.
+	Output func(service, line string)
+	// Progress receives a short status message for each step.
+	Progress func(message string)
+}
+
+// New prepares a local environment for cfg whose proxy listens on port.
+func New(cfg *config.Config, port int) (*Environment, error) {
+	network := cfg.Project.Name + networkSuffix
+
+	workDir := filepath.Join(os.TempDir(), "ftl-"+network)
+	if err := os.MkdirAll(filepath.Join(workDir, "nginx"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create work directory: %w", err)
+	}
+
+	shell := local.NewShell()
+	return &Environment{
+		cfg:      cfg,
+		network:  network,
+		port:     port,
+		workDir:  workDir,
+		shell:    shell,
+		docker:   docker.NewDockerManager(shell),
+		builder:  build.NewBuild(local.NewRunner()),
+		Output:   func(string, string) {},
+		Progress: func(string) {},
+	}, nil
+}
+
+// Up builds every service and starts dependencies, services and the proxy.
+func (e *Environment) Up(ctx context.Context) error {
+	e.Progress("Creating network...")
+	if err := e.docker.EnsureNetwork(e.network); err != nil {
+		return fmt.Errorf("failed to create network: %w", err)
+	}
+	for _, volume := range e.cfg.Volumes {
+		if err := e.docker.CreateVolume(ctx, e.network, volume); err != nil {
+			return fmt.Errorf("failed to create volume %s: %w", volume, err)
+		}
+	}
+
+	for i := range e.cfg.Dependencies {
+		dependency := deployment.DependencyService(&e.cfg.Dependencies[i])
+		// The development environment runs everything on a single network.
+		dependency.Networks = nil
+		dependency.Isolated = false
+		if err := e.mountConfigs(&e.cfg.Dependencies[i], dependency); err != nil {
+			return err
+		}
+		e.Progress(fmt.Sprintf("Starting dependency %s...", dependency.Name))
+		if err := e.startDependency(ctx, dependency); err != nil {
+			return fmt.Errorf("failed to start dependency %s: %w", dependency.Name, err)
+		}
+	}
+
+	for i := range e.cfg.Services {
+		if err := e.RestartService(ctx, &e.cfg.Services[i]); err != nil {
+			return err
+		}
+	}
+
+	e.Progress("Starting proxy...")
+	if err := e.startProxy(ctx); err != nil {
+		return fmt.Errorf("failed to start proxy: %w", err)
+	}
+
+	return nil
+}
+
+// mountConfigs writes the configs of dependency to the work directory and
+// mounts them into service.
+func (e *Environment) mountConfigs(dependency *config.Dependency, service *config.Service) error {
+	if len(dependency.Configs) == 0 {
+		return nil
+	}
+
+	dir := filepath.Join(e.workDir, "configs", dependency.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create configs directory of %s: %w", dependency.Name, err)
+	}
+	for _, file := range dependency.Configs {
+		mode, err := strconv.ParseUint(file.FileMode(), 8, 32)
+		if err != nil {
+			return fmt.Errorf("invalid mode of config %s of %s: %w", file.Target, dependency.Name, err)
+		}
+		name := filepath.Join(dir, file.FileName())
+		if err := os.WriteFile(name, []byte(file.Content), os.FileMode(mode)); err != nil {
+			return fmt.Errorf("failed to write config %s of %s: %w", file.Target, dependency.Name, err)
+		}
+	}
+	service.Volumes = append(slices.Clone(service.Volumes), deployment.ConfigMounts(dependency, dir)...)
+	return nil
+}
+
+// RestartService rebuilds a service and replaces its container. Static
+// services are extracted again and served by the running proxy.
+func (e *Environment) RestartService(ctx context.Context, service *config.Service) error {
+	image := service.Image
+	if service.Path != "" {
+		image = fmt.Sprintf("%s-%s", e.network, service.Name)
+		e.Progress(fmt.Sprintf("Building %s...", service.Name))
+		cacheDir, _ := build.DefaultPreCacheDir()
+		if _, err := e.builder.Pre(ctx, e.cfg.Project.Name, service, cacheDir, func(line string) { e.Output(service.Name, line) }); err != nil {
+			return fmt.Errorf("failed to run pre-build commands of service %s: %w", service.Name, err)
+		}
+		if err := e.builder.Build(ctx, image, service.Path, build.ServiceOptions(service), func(line string) { e.Output(service.Name, line) }); err != nil {
+			return fmt.Errorf("failed to build service %s: %w", service.Name, err)
+		}
+	}
+
+	if service.Static != nil {
+		return e.extractStatic(ctx, service, image)
+	}
+
+	svc := *service
+	if service.Path != "" {
+		svc.Image = ""
+	}
+	svc.Hooks = nil
+	svc.CrashAlert = nil
+	svc.Networks = nil
+
+	e.Progress(fmt.Sprintf("Starting %s...", service.Name))
+	container := fmt.Sprintf("%s-%s", e.network, service.Name)
+	_ = e.run(ctx, "docker", "rm", "-f", container)
+	if err := e.docker.CreateAndRunContainer(e.network, &svc, ""); err != nil {
+		return fmt.Errorf("failed to start service %s: %w", service.Name, err)
+	}
+	if err := e.docker.CheckContainerHealth(container, &svc); err != nil {
+		return fmt.Errorf("service %s is unhealthy: %w", service.Name, err)
+	}
+
+	return nil
+}
+
+// Down removes the containers and network of the environment. Volumes are
+// kept so data survives between sessions.
+func (e *Environment) Down(ctx context.Context) error {
+	if err := e.run(ctx, "sh", "-c", fmt.Sprintf("docker ps -aq --filter network=%s | xargs -r docker rm -f", e.network)); err != nil {
+		return fmt.Errorf("failed to remove containers: %w", err)
+	}
+	if err := e.run(ctx, "docker", "network", "rm", e.network); err != nil {
+		return fmt.Errorf("failed to remove network: %w", err)
+	}
+	return nil
+}
+
+// URL returns the address the proxy is reachable on.
+func (e *Environment) URL() string {
+	return fmt.Sprintf("http://localhost:%d", e.port)
+}
+
+func (e *Environment) startDependency(ctx context.Context, dependency *config.Service) error {
+	status, err := e.docker.GetContainerStatus(e.network, dependency.Name)
+	if err != nil {
+		return err
+	}
+	if status == docker.ContainerStatusRunning {
+		needsUpdate, err := e.docker.ContainerNeedsUpdate(e.network, dependency)
+		if err != nil {
+			return err
+		}
+		if !needsUpdate {
+			return nil
+		}
+	}
+
+	_ = e.run(ctx, "docker", "rm", "-f", fmt.Sprintf("%s-%s", e.network, dependency.Name))
+	return e.docker.CreateAndRunContainer(e.network, dependency, "")
+}
+
+func (e *Environment) startProxy(ctx context.Context) error {
+	nginxConfig, err := proxy.GenerateDevNginxConfig(e.cfg)
+	if err != nil {
+		return fmt.Errorf("failed to generate nginx config: %w", err)
+	}
+
+	confDir := filepath.Join(e.workDir, "nginx")
+	if err := os.WriteFile(filepath.Join(confDir, "default.conf"), []byte(nginxConfig), 0644); err != nil {
+		return fmt.Errorf("failed to write nginx config: %w", err)
+	}
+
+	authDir := filepath.Join(confDir, proxy.AuthDir)
+	if err := os.MkdirAll(authDir, 0755); err != nil {
+		return fmt.Errorf("failed to create auth directory: %w", err)
+	}
+	for name, content := range proxy.AuthFiles(e.cfg) {
+		if err := os.WriteFile(filepath.Join(authDir, name), []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write auth file: %w", err)
+		}
+	}
+
+	staticDir := filepath.Join(e.workDir, "static")
+	if err := os.MkdirAll(staticDir, 0755); err != nil {
+		return fmt.Errorf("failed to create static directory: %w", err)
+	}
+
+	service := &config.Service{
+		Name:  "proxy",
+		Image: "nginx:alpine",
+		Volumes: []string{
+			confDir + ":/etc/nginx/conf.d:ro",
+			staticDir + ":" + proxy.StaticRoot + ":ro",
+		},
+		Forwards: []string{fmt.Sprintf("%d:80", e.port)},
+	}
+
+	_ = e.run(ctx, "docker", "rm", "-f", fmt.Sprintf("%s-%s", e.network, service.Name))
+	return e.docker.CreateAndRunContainer(e.network, service, "")
+}
+
+// extractStatic copies a static service's files out of its image into the
+// directory the proxy serves it from.
+func (e *Environment) extractStatic(ctx context.Context, service *config.Service, image string) error {
+	container := fmt.Sprintf("%s-%s-extract", e.network, service.Name)
+	dest := filepath.Join(e.workDir, "static", service.Name, "current")
+
+	_ = e.run(ctx, "docker", "rm", "-f", container)
+	if err := e.run(ctx, "docker", "create", "--name", container, image); err != nil {
+		return fmt.Errorf("failed to create container from %s: %w", image, err)
+	}
+	defer func() {
+		_ = e.run(context.Background(), "docker", "rm", "-f", container)
+	}()
+
+	if err := os.RemoveAll(dest); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", dest, err)
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+
+	if err := e.run(ctx, "docker", "cp", container+":"+strings.TrimSuffix(service.Static.Path, "/")+"/.", dest); err != nil {
+		return fmt.Errorf("failed to copy %s from %s: %w", service.Static.Path, image, err)
+	}
+	return nil
+}
+
+// run runs a command and returns its output in the error if it fails.
+func (e *Environment) run(ctx context.Context, command string, args ...string) error {
+	output, err := e.shell.RunCommand(ctx, command, args...)
+	if err != nil {
+		return err
+	}
+
+	data, _ := io.ReadAll(output)
+	if err := output.Close(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
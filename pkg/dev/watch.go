@@ -0,0 +1,98 @@
+package dev
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// waitInterval is how long Watch waits between scans of the service paths.
+const waitInterval = time.Second
+
+// skippedDirs are never watched for changes.
+var skippedDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"vendor":       true,
+}
+
+// Watch polls the build context of every service and rebuilds and restarts a
+// service when a file in it changes. It returns when ctx is cancelled.
+// onRestart is called after every restart attempt.
+func (e *Environment) Watch(ctx context.Context, onRestart func(service string, err error)) error {
+	fingerprints := map[string]fingerprint{}
+	for _, service := range e.cfg.Services {
+		if service.Path != "" {
+			fingerprints[service.Name] = scan(service.Path)
+		}
+	}
+
+	ticker := time.NewTicker(waitInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		for i := range e.cfg.Services {
+			service := &e.cfg.Services[i]
+			if service.Path == "" {
+				continue
+			}
+
+			current := scan(service.Path)
+			if current == fingerprints[service.Name] {
+				continue
+			}
+			fingerprints[service.Name] = current
+
+			err := e.RestartService(ctx, service)
+			if ctx.Err() != nil {
+				return nil
+			}
+			// Pre-build commands write to the path themselves, which must
+			// not count as a change.
+			fingerprints[service.Name] = scan(service.Path)
+			onRestart(service.Name, err)
+		}
+	}
+}
+
+// fingerprint summarises a directory tree well enough to notice edits,
+// additions and deletions.
+type fingerprint struct {
+	files   int
+	size    int64
+	modTime time.Time
+}
+
+func scan(root string) fingerprint {
+	var fp fingerprint
+	_ = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if path != root && skippedDirs[entry.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		fp.files++
+		fp.size += info.Size()
+		if info.ModTime().After(fp.modTime) {
+			fp.modTime = info.ModTime()
+		}
+		return nil
+	})
+	return fp
+}
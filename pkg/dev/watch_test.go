@@ -0,0 +1,30 @@
+package dev
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "main.go"), []byte("package main"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "node_modules", "left-pad"), 0755))
+
+	initial := scan(root)
+	assert.Equal(t, 1, initial.files)
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "node_modules", "left-pad", "index.js"), []byte("x"), 0644))
+	assert.Equal(t, initial, scan(root), "skipped directories must not trigger rebuilds")
+
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(filepath.Join(root, "main.go"), later, later))
+	assert.NotEqual(t, initial, scan(root))
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "util.go"), []byte("package main"), 0644))
+	assert.Equal(t, 2, scan(root).files)
+}
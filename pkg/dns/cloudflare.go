@@ -0,0 +1,152 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Cloudflare creates records with the Cloudflare API. Records are not
+// proxied, so they point at the server itself.
+type Cloudflare struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewCloudflare returns a Cloudflare provider that authenticates with an API
+// token allowed to edit the DNS of the zones.
+func NewCloudflare(token string) *Cloudflare {
+	return &Cloudflare{
+		baseURL: "https://api.cloudflare.com/client/v4",
+		token:   token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func newCloudflareFromEnv() (Provider, error) {
+	token := os.Getenv("CLOUDFLARE_API_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("CLOUDFLARE_API_TOKEN is not set")
+	}
+	return NewCloudflare(token), nil
+}
+
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+	Proxied bool   `json:"proxied"`
+}
+
+// Upsert updates the first record of the name and type to the record and
+// deletes the others, or creates the record when there is none.
+func (c *Cloudflare) Upsert(ctx context.Context, zone string, record Record, ttl int) error {
+	zoneID, err := c.zoneID(ctx, zone, record.Name)
+	if err != nil {
+		return err
+	}
+
+	var existing []cloudflareRecord
+	query := url.Values{"type": {record.Type}, "name": {record.Name}}
+	if err := c.do(ctx, http.MethodGet, "/zones/"+zoneID+"/dns_records?"+query.Encode(), nil, &existing); err != nil {
+		return fmt.Errorf("failed to list records of %s: %w", record.Name, err)
+	}
+
+	body := cloudflareRecord{Type: record.Type, Name: record.Name, Content: record.Value, TTL: ttl}
+	if len(existing) == 0 {
+		if err := c.do(ctx, http.MethodPost, "/zones/"+zoneID+"/dns_records", body, nil); err != nil {
+			return fmt.Errorf("failed to create %s record of %s: %w", record.Type, record.Name, err)
+		}
+		return nil
+	}
+
+	if err := c.do(ctx, http.MethodPut, "/zones/"+zoneID+"/dns_records/"+existing[0].ID, body, nil); err != nil {
+		return fmt.Errorf("failed to update %s record of %s: %w", record.Type, record.Name, err)
+	}
+	for _, other := range existing[1:] {
+		if err := c.do(ctx, http.MethodDelete, "/zones/"+zoneID+"/dns_records/"+other.ID, nil, nil); err != nil {
+			return fmt.Errorf("failed to delete %s record of %s: %w", record.Type, record.Name, err)
+		}
+	}
+	return nil
+}
+
+// zoneID returns the ID of zone, or of the nearest zone holding name when
+// zone is empty.
+func (c *Cloudflare) zoneID(ctx context.Context, zone, name string) (string, error) {
+	candidates := zoneCandidates(name)
+	if zone != "" {
+		candidates = []string{zone}
+	}
+
+	for _, candidate := range candidates {
+		var zones []struct {
+			ID string `json:"id"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/zones?"+url.Values{"name": {candidate}}.Encode(), nil, &zones); err != nil {
+			return "", fmt.Errorf("failed to look up zone %s: %w", candidate, err)
+		}
+		if len(zones) > 0 {
+			return zones[0].ID, nil
+		}
+	}
+	return "", fmt.Errorf("no Cloudflare zone holds %s", name)
+}
+
+// do sends body as JSON and decodes the result of the response into out,
+// when it is not nil.
+func (c *Cloudflare) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response of %s %s: %w", method, req.URL.Path, err)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s failed with status %d: %s", method, req.URL.Path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("failed to decode response of %s %s: %w", method, req.URL.Path, err)
+	}
+	if err := json.Unmarshal(envelope.Result, out); err != nil {
+		return fmt.Errorf("failed to decode response of %s %s: %w", method, req.URL.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// Package dns verifies that the domains of a project point at the server,
+// and creates the records that do not with DNS providers.
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/runner/local"
+)
+
+// Record is an A or AAAA record a domain needs to point at the server.
+type Record struct {
+	Name  string
+	Type  string
+	Value string
+	// Current lists the addresses the domain resolves to instead, none when
+	// it does not resolve.
+	Current []string
+}
+
+// Provider creates DNS records.
+type Provider interface {
+	// Upsert creates the record in zone, or replaces the records of its name
+	// and type with it. With an empty zone the zone of the record name is
+	// looked up.
+	Upsert(ctx context.Context, zone string, record Record, ttl int) error
+}
+
+// providers creates the supported providers, configured from the environment.
+var providers = map[string]func() (Provider, error){
+	"cloudflare": newCloudflareFromEnv,
+	"route53":    newRoute53FromEnv,
+}
+
+// Providers returns the names of the supported providers.
+func Providers() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns the provider with the given name. Credentials are read from the
+// environment: CLOUDFLARE_API_TOKEN for Cloudflare and the AWS CLI
+// configuration for Route 53.
+func New(name string) (Provider, error) {
+	newProvider, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown DNS provider %q, available providers: %s", name, strings.Join(Providers(), ", "))
+	}
+	return newProvider()
+}
+
+func newRoute53FromEnv() (Provider, error) {
+	return NewRoute53(local.NewRunner()), nil
+}
+
+// LookupFunc returns the addresses of host.
+type LookupFunc func(ctx context.Context, host string) ([]string, error)
+
+// NewLookup returns a LookupFunc querying the DNS server at address, such as
+// "1.1.1.1:53", or the resolver of the system when address is empty.
+func NewLookup(address string) LookupFunc {
+	if address == "" {
+		return net.DefaultResolver.LookupHost
+	}
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+	return resolver.LookupHost
+}
+
+// Plan returns the records the domains need to point at the server with the
+// addresses serverIPs: one for each address of the server, for every domain
+// that resolves to none of them. A domain that does not resolve needs them
+// as well.
+func Plan(ctx context.Context, lookup LookupFunc, domains, serverIPs []string) []Record {
+	var records []Record
+	for _, domain := range domains {
+		addresses, _ := lookup(ctx, domain)
+		if slices.ContainsFunc(addresses, func(address string) bool {
+			return slices.ContainsFunc(serverIPs, func(serverIP string) bool { return SameIP(serverIP, address) })
+		}) {
+			continue
+		}
+		for _, serverIP := range serverIPs {
+			if net.ParseIP(serverIP) == nil {
+				continue
+			}
+			records = append(records, Record{Name: domain, Type: RecordType(serverIP), Value: serverIP, Current: addresses})
+		}
+	}
+	return records
+}
+
+// SameIP reports whether a and b are the same address, however they are
+// written, such as 2001:db8::1 and 2001:DB8:0::1.
+func SameIP(a, b string) bool {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA == nil || ipB == nil {
+		return a == b
+	}
+	return ipA.Equal(ipB)
+}
+
+// RecordType returns the type of the record pointing to address: AAAA for
+// IPv6 addresses, A otherwise.
+func RecordType(address string) string {
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		return "AAAA"
+	}
+	return "A"
+}
+
+// zoneCandidates returns the names the zone of name may have, from name
+// itself down to its registrable domain.
+func zoneCandidates(name string) []string {
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	var candidates []string
+	for i := 0; i < len(labels)-1; i++ {
+		candidates = append(candidates, strings.Join(labels[i:], "."))
+	}
+	return candidates
+}
@@ -0,0 +1,120 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestNew(t *testing.T) {
+	t.Setenv("CLOUDFLARE_API_TOKEN", "")
+	_, err := New("cloudflare")
+	assert.EqualError(t, err, "CLOUDFLARE_API_TOKEN is not set")
+
+	_, err = New("gandi")
+	assert.EqualError(t, err, `unknown DNS provider "gandi", available providers: cloudflare, route53`)
+}
+
+func TestPlan(t *testing.T) {
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		switch host {
+		case "example.com":
+			return []string{"2001:DB8:0::10"}, nil
+		case "www.example.com":
+			return []string{"198.51.100.7"}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	records := Plan(context.Background(), lookup, []string{"example.com", "www.example.com", "api.example.com"}, []string{"203.0.113.10", "2001:db8::10"})
+	assert.Equal(t, []Record{
+		{Name: "www.example.com", Type: "A", Value: "203.0.113.10", Current: []string{"198.51.100.7"}},
+		{Name: "www.example.com", Type: "AAAA", Value: "2001:db8::10", Current: []string{"198.51.100.7"}},
+		{Name: "api.example.com", Type: "A", Value: "203.0.113.10"},
+		{Name: "api.example.com", Type: "AAAA", Value: "2001:db8::10"},
+	}, records)
+}
+
+func TestZoneCandidates(t *testing.T) {
+	assert.Equal(t, []string{"app.eu.example.com", "eu.example.com", "example.com"}, zoneCandidates("app.eu.example.com"))
+	assert.Equal(t, []string{"example.com"}, zoneCandidates("example.com."))
+}
+
+func TestCloudflareUpsert(t *testing.T) {
+	var requests []string
+	var body cloudflareRecord
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		if r.Method == http.MethodPost || r.Method == http.MethodPut {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		}
+
+		switch r.Method + " " + r.URL.RequestURI() {
+		case "GET /zones?name=app.example.com", "GET /zones?name=example.org":
+			w.Write([]byte(`{"success": true, "result": []}`))
+		case "GET /zones?name=example.com":
+			w.Write([]byte(`{"success": true, "result": [{"id": "zone-1"}]}`))
+		case "GET /zones/zone-1/dns_records?name=app.example.com&type=A":
+			w.Write([]byte(`{"success": true, "result": [{"id": "rec-1"}, {"id": "rec-2"}]}`))
+		case "GET /zones/zone-1/dns_records?name=new.example.com&type=AAAA":
+			w.Write([]byte(`{"success": true, "result": []}`))
+		default:
+			w.Write([]byte(`{"success": true, "result": {}}`))
+		}
+	}))
+	defer server.Close()
+
+	provider := NewCloudflare("token")
+	provider.baseURL = server.URL
+
+	require.NoError(t, provider.Upsert(context.Background(), "", Record{Name: "app.example.com", Type: "A", Value: "203.0.113.10"}, 300))
+	assert.Equal(t, []string{
+		"GET /zones?name=app.example.com",
+		"GET /zones?name=example.com",
+		"GET /zones/zone-1/dns_records?name=app.example.com&type=A",
+		"PUT /zones/zone-1/dns_records/rec-1",
+		"DELETE /zones/zone-1/dns_records/rec-2",
+	}, requests)
+	assert.Equal(t, cloudflareRecord{Type: "A", Name: "app.example.com", Content: "203.0.113.10", TTL: 300}, body)
+
+	requests = nil
+	require.NoError(t, provider.Upsert(context.Background(), "example.com", Record{Name: "new.example.com", Type: "AAAA", Value: "2001:db8::10"}, 60))
+	assert.Equal(t, []string{
+		"GET /zones?name=example.com",
+		"GET /zones/zone-1/dns_records?name=new.example.com&type=AAAA",
+		"POST /zones/zone-1/dns_records",
+	}, requests)
+
+	err := provider.Upsert(context.Background(), "example.org", Record{Name: "app.example.org", Type: "A", Value: "203.0.113.10"}, 300)
+	assert.EqualError(t, err, "no Cloudflare zone holds app.example.org")
+}
+
+func TestRoute53Upsert(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("aws route53 list-hosted-zones-by-name --dns-name app.example.com", fake.Response{Output: `{"Id": "/hostedzone/Z2", "Name": "example.org."}`})
+	runner.On("aws route53 list-hosted-zones-by-name --dns-name example.com", fake.Response{Output: `{"Id": "/hostedzone/Z1", "Name": "example.com."}`})
+
+	err := NewRoute53(runner).Upsert(context.Background(), "", Record{Name: "app.example.com", Type: "A", Value: "203.0.113.10"}, 300)
+	require.NoError(t, err)
+
+	calls := runner.Calls()
+	require.Len(t, calls, 3)
+	assert.Equal(t, []string{
+		"route53", "change-resource-record-sets", "--hosted-zone-id", "Z1",
+		"--change-batch", `{"Changes":[{"Action":"UPSERT","ResourceRecordSet":{"Name":"app.example.com","ResourceRecords":[{"Value":"203.0.113.10"}],"TTL":300,"Type":"A"}}]}`,
+	}, calls[2].Args)
+
+	runner = fake.NewRunner()
+	runner.On("aws route53 list-hosted-zones-by-name", fake.Response{Output: "null"})
+	err = NewRoute53(runner).Upsert(context.Background(), "example.com", Record{Name: "app.example.com", Type: "A", Value: "203.0.113.10"}, 300)
+	assert.EqualError(t, err, "no Route 53 hosted zone holds app.example.com")
+}
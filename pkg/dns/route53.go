@@ -0,0 +1,101 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Runner runs commands on the local machine.
+type Runner interface {
+	RunCommand(ctx context.Context, command string, args ...string) (io.ReadCloser, error)
+}
+
+// Route53 creates records in AWS Route 53 hosted zones with the AWS CLI, so
+// credentials, profiles and SSO work the way they do for the aws command.
+type Route53 struct {
+	runner Runner
+}
+
+// NewRoute53 returns a Route 53 provider that runs the aws command with
+// runner.
+func NewRoute53(runner Runner) *Route53 {
+	return &Route53{runner: runner}
+}
+
+// Upsert replaces the record set of the name and type with the record.
+func (r *Route53) Upsert(ctx context.Context, zone string, record Record, ttl int) error {
+	zoneID, err := r.zoneID(ctx, zone, record.Name)
+	if err != nil {
+		return err
+	}
+
+	batch, err := json.Marshal(map[string]any{
+		"Changes": []any{map[string]any{
+			"Action": "UPSERT",
+			"ResourceRecordSet": map[string]any{
+				"Name":            record.Name,
+				"Type":            record.Type,
+				"TTL":             ttl,
+				"ResourceRecords": []any{map[string]string{"Value": record.Value}},
+			},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode change batch: %w", err)
+	}
+
+	if _, err := r.aws(ctx, "route53", "change-resource-record-sets", "--hosted-zone-id", zoneID, "--change-batch", string(batch)); err != nil {
+		return fmt.Errorf("failed to upsert %s record of %s: %w", record.Type, record.Name, err)
+	}
+	return nil
+}
+
+// zoneID returns the ID of the hosted zone named zone, or of the nearest
+// hosted zone holding name when zone is empty.
+func (r *Route53) zoneID(ctx context.Context, zone, name string) (string, error) {
+	candidates := zoneCandidates(name)
+	if zone != "" {
+		candidates = []string{zone}
+	}
+
+	for _, candidate := range candidates {
+		output, err := r.aws(ctx, "route53", "list-hosted-zones-by-name",
+			"--dns-name", candidate, "--max-items", "1",
+			"--query", "HostedZones[0]", "--output", "json",
+		)
+		if err != nil {
+			return "", fmt.Errorf("failed to look up hosted zone %s: %w", candidate, err)
+		}
+
+		var hostedZone *struct {
+			ID   string `json:"Id"`
+			Name string `json:"Name"`
+		}
+		if err := json.Unmarshal([]byte(output), &hostedZone); err != nil {
+			return "", fmt.Errorf("failed to decode hosted zone %s: %w", candidate, err)
+		}
+		// Zones are listed from the name on, so the first one may be another.
+		if hostedZone != nil && strings.TrimSuffix(hostedZone.Name, ".") == candidate {
+			return strings.TrimPrefix(hostedZone.ID, "/hostedzone/"), nil
+		}
+	}
+	return "", fmt.Errorf("no Route 53 hosted zone holds %s", name)
+}
+
+// aws runs the aws command and returns its trimmed output.
+func (r *Route53) aws(ctx context.Context, args ...string) (string, error) {
+	output, err := r.runner.RunCommand(ctx, "aws", args...)
+	if err != nil {
+		return "", err
+	}
+	defer output.Close()
+
+	data, err := io.ReadAll(output)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
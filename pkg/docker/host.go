@@ -0,0 +1,46 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DefaultSocket is where a Docker daemon running as root listens.
+const DefaultSocket = "/var/run/docker.sock"
+
+// detectHostScript prints the DOCKER_HOST of the user's rootless daemon when
+// the root daemon socket is missing or not writable by the user.
+const detectHostScript = `rootless="/run/user/$(id -u)/docker.sock"; if [ ! -w ` + DefaultSocket + ` ] && [ -S "$rootless" ]; then echo "unix://$rootless"; fi`
+
+// DetectHost returns the DOCKER_HOST to use on the server: the socket of a
+// rootless daemon run by the connected user if the root daemon is not usable,
+// or an empty string for the default daemon.
+func DetectHost(ctx context.Context, runner CommandRunner) (string, error) {
+	output, err := runner.RunCommand(ctx, "sh", "-c", detectHostScript)
+	if err != nil {
+		return "", fmt.Errorf("failed to detect docker daemon: %w", err)
+	}
+	defer output.Close()
+
+	data, err := io.ReadAll(output)
+	if err != nil {
+		return "", fmt.Errorf("failed to read docker daemon detection output: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// SocketPath returns the path of the daemon socket for a DOCKER_HOST value:
+// DefaultSocket when it is empty, or an empty string when the daemon is not
+// reached through a unix socket.
+func SocketPath(dockerHost string) string {
+	if dockerHost == "" {
+		return DefaultSocket
+	}
+	if path, ok := strings.CutPrefix(dockerHost, "unix://"); ok {
+		return path
+	}
+	return ""
+}
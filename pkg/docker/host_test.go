@@ -0,0 +1,13 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSocketPath(t *testing.T) {
+	assert.Equal(t, DefaultSocket, SocketPath(""))
+	assert.Equal(t, "/run/user/1000/docker.sock", SocketPath("unix:///run/user/1000/docker.sock"))
+	assert.Empty(t, SocketPath("tcp://10.0.0.5:2375"))
+}
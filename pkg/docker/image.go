@@ -0,0 +1,21 @@
+package docker
+
+import "strings"
+
+// Repository returns the repository of an image reference, without its tag
+// or digest. A port in the registry host is not mistaken for a tag.
+func Repository(image string) string {
+	if before, _, ok := strings.Cut(image, "@"); ok {
+		image = before
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i]
+	}
+	return image
+}
+
+// IsDigestReference reports whether image is pinned to a digest, as in
+// "ghcr.io/acme/web@sha256:...".
+func IsDigestReference(image string) bool {
+	return strings.Contains(image, "@")
+}
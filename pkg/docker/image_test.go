@@ -0,0 +1,25 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepository(t *testing.T) {
+	tests := map[string]string{
+		"nginx":                                 "nginx",
+		"nginx:alpine":                          "nginx",
+		"ghcr.io/acme/web:1.2":                  "ghcr.io/acme/web",
+		"registry.local:5000/web":               "registry.local:5000/web",
+		"registry.local:5000/web:latest":        "registry.local:5000/web",
+		"ghcr.io/acme/web@sha256:0123456789abc": "ghcr.io/acme/web",
+		"ghcr.io/acme/web:1.2@sha256:0123abc":   "ghcr.io/acme/web",
+	}
+
+	for image, repository := range tests {
+		assert.Equal(t, repository, Repository(image), image)
+	}
+	assert.True(t, IsDigestReference("ghcr.io/acme/web@sha256:0123abc"))
+	assert.False(t, IsDigestReference("ghcr.io/acme/web:1.2"))
+}
@@ -3,14 +3,20 @@ package docker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
 
 	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/retry"
+	"github.com/yarlson/ftl/pkg/runner"
 )
 
 // ContainerDetails holds information from a Docker inspect.
@@ -29,6 +35,26 @@ type ContainerDetails struct {
 	HostConfig struct{ Binds []string }
 }
 
+// Labels read by the crash watcher deployed next to the project containers.
+const (
+	CrashAlertWebhookLabel   = "ftl.crash-alert.webhook"
+	CrashAlertThresholdLabel = "ftl.crash-alert.threshold"
+)
+
+// ReplicaOfLabel names the service an additional replica container belongs to.
+const ReplicaOfLabel = "ftl.replica-of"
+
+// RevisionLabel records the commit a container was deployed from, as
+// "1a2b3c4" or "1a2b3c4-dirty".
+const RevisionLabel = "ftl.git-sha"
+
+// Environment variables that pass the commit a container was deployed from to
+// the application.
+const (
+	GitSHAEnv   = "FTL_GIT_SHA"
+	GitDirtyEnv = "FTL_GIT_DIRTY"
+)
+
 // ContainerStatus represents the status of a container.
 type ContainerStatus int
 
@@ -47,11 +73,27 @@ type CommandRunner interface {
 // DockerManager manages Docker containers.
 type DockerManager struct {
 	runner CommandRunner
+	policy Policy
+}
+
+// Policy bounds and retries the remote operations of a DockerManager. Retry
+// applies to image pulls and to the commands health checks run. PullTimeout
+// bounds each pull attempt and HealthTimeout how long a container may take to
+// become healthy; zero leaves them to the pull and the health check settings.
+type Policy struct {
+	Retry         retry.Policy
+	PullTimeout   time.Duration
+	HealthTimeout time.Duration
 }
 
 // NewDockerManager creates a new DockerManager.
 func NewDockerManager(runner CommandRunner) *DockerManager {
-	return &DockerManager{runner: runner}
+	return &DockerManager{runner: runner, policy: Policy{Retry: retry.Default}}
+}
+
+// SetPolicy replaces the timeouts and retries of the remote operations.
+func (dm *DockerManager) SetPolicy(policy Policy) {
+	dm.policy = policy
 }
 
 // GetContainerStatus returns the status of a container identified by networkName and serviceName.
@@ -100,6 +142,11 @@ func (dm *DockerManager) findContainerDetails(networkName, serviceName string) (
 			continue
 		}
 
+		// Additional replicas share the service alias but are not the service's container.
+		if containers[0].Config.Labels[ReplicaOfLabel] == serviceName {
+			continue
+		}
+
 		if networkConfig, ok := containers[0].NetworkSettings.Networks[networkName]; ok {
 			for _, alias := range networkConfig.Aliases {
 				if alias == serviceName {
@@ -112,18 +159,49 @@ func (dm *DockerManager) findContainerDetails(networkName, serviceName string) (
 	return nil, fmt.Errorf("no container found with alias %s in network %s", serviceName, networkName)
 }
 
+// GRPCHealthProbeImage runs the gRPC health checking protocol against services
+// whose health check type is grpc, so their images need no probe binary.
+const GRPCHealthProbeImage = "ghcr.io/grpc-ecosystem/grpc-health-probe:v0.4.37"
+
+// HTTPProbeImage requests the health check path of services whose health
+// check asserts on the response body, so their images need no HTTP client
+// able to show it.
+const HTTPProbeImage = "curlimages/curl:8.10.1"
+
 // CheckContainerHealth performs health checks for the container with the given ID.
-func (dm *DockerManager) CheckContainerHealth(containerID string, hc *config.ServiceHealthCheck) error {
+func (dm *DockerManager) CheckContainerHealth(containerID string, svc *config.Service) error {
+	hc := svc.HealthCheck
 	if hc == nil {
 		return nil
 	}
 
-	for i := 0; i < hc.Retries; i++ {
-		output, err := dm.runCommand(context.Background(), "docker", "inspect", "--format={{.State.Health.Status}}", containerID)
-		if err == nil && strings.TrimSpace(output) == "healthy" {
-			return nil
+	// With a health timeout the container is checked until it expires,
+	// however many retries the health check allows.
+	deadline := time.Now().Add(dm.policy.HealthTimeout)
+	var mismatch error
+	for i := 0; i < hc.Retries || (dm.policy.HealthTimeout > 0 && time.Now().Before(deadline)); i++ {
+		if hc.Type == config.HealthCheckGRPC {
+			if dm.probeGRPCHealth(containerID, svc.Port, hc) == nil {
+				return nil
+			}
+		} else {
+			var output string
+			err := dm.policy.Retry.Do(context.Background(), func() error {
+				var err error
+				output, err = dm.runCommand(context.Background(), "docker", "inspect", "--format={{.State.Health.Status}}", containerID)
+				return err
+			})
+			if err == nil && strings.TrimSpace(output) == "healthy" {
+				// Docker only checks the status; the body is checked here.
+				if !hc.ChecksBody() {
+					return nil
+				}
+				if mismatch = dm.probeHTTPHealth(containerID, svc.Port, hc); mismatch == nil {
+					return nil
+				}
+			}
 		}
-		time.Sleep(hc.Interval)
+		time.Sleep(hc.Interval.Duration())
 	}
 
 	output, err := dm.runCommand(context.Background(), "docker", "logs", containerID)
@@ -141,9 +219,98 @@ func (dm *DockerManager) CheckContainerHealth(containerID string, hc *config.Ser
 	cleanedOutput := colorCodeRegex.ReplaceAllString(trimmedOutput, "")
 	grayOutput := "\x1b[90m" + cleanedOutput + "\x1b[0m"
 
+	if mismatch != nil {
+		return fmt.Errorf("container failed to become healthy: %v\n\x1b[93mOutput from the container:\x1b[0m\n%s", mismatch, grayOutput)
+	}
 	return fmt.Errorf("container failed to become healthy\n\x1b[93mOutput from the container:\x1b[0m\n%s", grayOutput)
 }
 
+// healthPollInterval is how often WaitHealthy checks the health of a container.
+var healthPollInterval = time.Second
+
+// WaitHealthy waits until the Docker health check of the container reports
+// healthy. A container whose image and configuration define no health check
+// counts as healthy once it runs. It fails when the container is reported
+// unhealthy or the timeout, replaced by the health timeout of the policy when
+// set, expires.
+func (dm *DockerManager) WaitHealthy(containerID string, timeout time.Duration) error {
+	if dm.policy.HealthTimeout > 0 {
+		timeout = dm.policy.HealthTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		var output string
+		err := dm.policy.Retry.Do(context.Background(), func() error {
+			var err error
+			output, err = dm.runCommand(context.Background(), "docker", "inspect", "--format={{if .State.Health}}{{.State.Health.Status}}{{end}}", containerID)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to inspect %s: %w", containerID, err)
+		}
+
+		switch strings.TrimSpace(output) {
+		case "", "healthy":
+			return nil
+		case "unhealthy":
+			return fmt.Errorf("%s is unhealthy", containerID)
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s did not become healthy within %s", containerID, timeout)
+		}
+		time.Sleep(healthPollInterval)
+	}
+}
+
+// probeGRPCHealth calls grpc.health.v1.Health/Check on the container's port
+// from a probe container that shares its network namespace.
+func (dm *DockerManager) probeGRPCHealth(containerID string, port int, hc *config.ServiceHealthCheck) error {
+	args := []string{
+		"run", "--rm", "--network", "container:" + containerID, GRPCHealthProbeImage,
+		fmt.Sprintf("-addr=localhost:%d", port),
+	}
+	if hc.GRPCService != "" {
+		args = append(args, "-service="+hc.GRPCService)
+	}
+	if hc.Timeout > 0 {
+		args = append(args, "-connect-timeout="+hc.Timeout.String(), "-rpc-timeout="+hc.Timeout.String())
+	}
+
+	_, err := runner.RunChecked(context.Background(), dm.runner, "docker", args...)
+	return err
+}
+
+// probeHTTPHealth requests the health check path of the container from a
+// probe container that shares its network namespace and checks the response
+// against the expected statuses, body and JSON values.
+func (dm *DockerManager) probeHTTPHealth(containerID string, port int, hc *config.ServiceHealthCheck) error {
+	args := []string{
+		"run", "--rm", "--network", "container:" + containerID, HTTPProbeImage,
+		"-s", "-w", "\n%{http_code}",
+	}
+	if hc.Timeout > 0 {
+		args = append(args, "--max-time", fmt.Sprintf("%d", int(hc.Timeout.Duration().Seconds())))
+	}
+	args = append(args, fmt.Sprintf("http://localhost:%d%s", port, hc.Path))
+
+	output, err := dm.runCommand(context.Background(), "docker", args...)
+	if err != nil {
+		return fmt.Errorf("GET %s failed: %w", hc.Path, err)
+	}
+
+	// The status follows the body on the last line.
+	body, code := "", output
+	if i := strings.LastIndex(output, "\n"); i >= 0 {
+		body, code = output[:i], output[i+1:]
+	}
+	status, err := strconv.Atoi(strings.TrimSpace(code))
+	if err != nil {
+		return fmt.Errorf("GET %s returned no status", hc.Path)
+	}
+	return hc.CheckResponse(status, []byte(body))
+}
+
 // StartContainer starts the container with the given ID.
 func (dm *DockerManager) StartContainer(containerID string) error {
 	_, err := dm.runCommand(context.Background(), "docker", "start", containerID)
@@ -155,60 +322,207 @@ func (dm *DockerManager) StartContainer(containerID string) error {
 
 // CreateAndRunContainer creates and starts a container for the given service on the specified network.
 func (dm *DockerManager) CreateAndRunContainer(networkName string, svc *config.Service, suffix string) error {
+	args, err := RunArgs(networkName, svc, suffix)
+	if err != nil {
+		return err
+	}
+
+	networks := ServiceNetworks(networkName, svc)[1:]
+	if len(networks) == 0 {
+		_, err = dm.runCommand(context.Background(), "docker", args...)
+		return err
+	}
+
+	// A one-off container would exit before it joins the other networks, so
+	// it is created, connected and only then started.
+	runOnce := svc.Container != nil && svc.Container.RunOnce
+	if runOnce {
+		args[0] = "create"
+	}
+	if _, err := dm.runCommand(context.Background(), "docker", args...); err != nil {
+		return err
+	}
+
+	containerName := generateContainerName(networkName, svc.Name, suffix)
+	connect := []string{"network", "connect"}
+	for _, alias := range NetworkAliases(svc, suffix) {
+		connect = append(connect, "--alias", alias)
+	}
+	for _, network := range networks {
+		if _, err := dm.runCommand(context.Background(), "docker", append(connect, network, containerName)...); err != nil {
+			return fmt.Errorf("failed to connect %s to network %s: %w", containerName, network, err)
+		}
+	}
+
+	if runOnce {
+		_, err = dm.runCommand(context.Background(), "docker", "start", "--attach", containerName)
+	}
+	return err
+}
+
+// PrivateNetwork returns the Docker network of the private network name of
+// the project on networkName.
+func PrivateNetwork(networkName, name string) string {
+	return fmt.Sprintf("%s_%s", networkName, name)
+}
+
+// ServiceNetworks returns the networks the containers of svc join. The first
+// one is the network they are created on and found by: the project network,
+// or the first private network of an isolated service.
+func ServiceNetworks(networkName string, svc *config.Service) []string {
+	var networks []string
+	if !svc.Isolated || len(svc.Networks) == 0 {
+		networks = append(networks, networkName)
+	}
+	for _, name := range svc.Networks {
+		networks = append(networks, PrivateNetwork(networkName, name))
+	}
+	return networks
+}
+
+// NetworkAliases returns the names a container of svc is reached by on its
+// networks: its own, then the service name for a replica and the aliases of
+// the service. A replacement container, created with a suffix, only gets the
+// names of the service once traffic switches to it.
+func NetworkAliases(svc *config.Service, suffix string) []string {
+	aliases := []string{svc.Name + suffix}
+	if suffix != "" {
+		return aliases
+	}
+	if svc.ReplicaOf != "" {
+		aliases = append(aliases, svc.ReplicaOf)
+	}
+	return append(aliases, svc.Aliases...)
+}
+
+// ServiceNetwork returns the network the containers of svc are found by.
+func ServiceNetwork(networkName string, svc *config.Service) string {
+	return ServiceNetworks(networkName, svc)[0]
+}
+
+// RunArgs returns the "docker run" arguments (without the leading "docker")
+// used to start the given service on the specified network.
+func RunArgs(networkName string, svc *config.Service, suffix string) ([]string, error) {
 	containerName := generateContainerName(networkName, svc.Name, suffix)
 
 	args := []string{"run"}
-	if svc.Container != nil && svc.Container.RunOnce {
+	runOnce := svc.Container != nil && svc.Container.RunOnce
+	if runOnce {
 		args = append(args, "--rm")
 	} else {
 		args = append(args, "--detach")
 	}
 
-	args = append(args, []string{
-		"--name", containerName,
-		"--network", networkName,
-		"--network-alias", svc.Name + suffix,
-		"--restart", "unless-stopped",
-	}...)
+	args = append(args, "--name", containerName, "--network", ServiceNetwork(networkName, svc))
+	for _, alias := range NetworkAliases(svc, suffix) {
+		args = append(args, "--network-alias", alias)
+	}
+	if svc.ReplicaOf != "" {
+		args = append(args, "--label", fmt.Sprintf("%s=%s", ReplicaOfLabel, svc.ReplicaOf))
+	}
+
+	// Docker rejects a restart policy on containers started with --rm.
+	if !runOnce {
+		restart := svc.Restart
+		if restart == "" {
+			restart = config.DefaultRestartPolicy
+		}
+		args = append(args, "--restart", restart)
+	}
+
+	args = append(args, LogArgs(svc.Logging)...)
+
+	// Docker also stops the container this way when the daemon or server
+	// shuts down, not only on deploy.
+	if svc.StopSignal != "" {
+		args = append(args, "--stop-signal", svc.StopSignal)
+	}
+	if svc.StopGracePeriod > 0 {
+		args = append(args, "--stop-timeout", strconv.Itoa(int(math.Ceil(svc.StopGracePeriod.Duration().Seconds()))))
+	}
 
+	if svc.Revision.Commit != "" {
+		args = append(args,
+			"-e", fmt.Sprintf("%s=%s", GitSHAEnv, svc.Revision.Commit),
+			"-e", fmt.Sprintf("%s=%t", GitDirtyEnv, svc.Revision.Dirty),
+			"--label", fmt.Sprintf("%s=%s", RevisionLabel, svc.Revision),
+		)
+	}
 	for _, envVal := range svc.Env {
 		args = append(args, "-e", envVal)
 	}
 
 	for _, vol := range svc.Volumes {
-		if unicode.IsLetter(rune(vol[0])) {
-			vol = fmt.Sprintf("%s-%s", networkName, vol)
-		}
-		args = append(args, "-v", vol)
+		args = append(args, "-v", VolumeBind(networkName, vol))
 	}
 
 	var healthArgs []string
-	if svc.HealthCheck != nil {
+	if svc.HealthCheck != nil && svc.HealthCheck.Type != config.HealthCheckGRPC {
 		healthArgs = []string{
-			"--health-cmd", fmt.Sprintf("curl -sf http://localhost:%d%s || exit 1", svc.Port, svc.HealthCheck.Path),
-			"--health-interval", fmt.Sprintf("%ds", int(svc.HealthCheck.Interval.Seconds())),
+			"--health-cmd", httpHealthCmd(svc.Port, svc.HealthCheck),
+			"--health-interval", fmt.Sprintf("%ds", int(svc.HealthCheck.Interval.Duration().Seconds())),
 			"--health-retries", fmt.Sprintf("%d", svc.HealthCheck.Retries),
-			"--health-timeout", fmt.Sprintf("%ds", int(svc.HealthCheck.Timeout.Seconds())),
+			"--health-timeout", fmt.Sprintf("%ds", int(svc.HealthCheck.Timeout.Duration().Seconds())),
 		}
 	}
 	if svc.Container != nil && svc.Container.HealthCheck != nil {
 		healthArgs = []string{
 			"--health-cmd", svc.Container.HealthCheck.Cmd,
-			"--health-interval", svc.Container.HealthCheck.Interval,
 			"--health-retries", fmt.Sprintf("%d", svc.Container.HealthCheck.Retries),
 		}
-		if svc.Container.HealthCheck.Timeout != "" {
-			healthArgs = append(healthArgs, "--health-timeout", svc.Container.HealthCheck.Timeout)
+		if svc.Container.HealthCheck.Interval > 0 {
+			healthArgs = append(healthArgs, "--health-interval", svc.Container.HealthCheck.Interval.String())
+		}
+		if svc.Container.HealthCheck.Timeout > 0 {
+			healthArgs = append(healthArgs, "--health-timeout", svc.Container.HealthCheck.Timeout.String())
 		}
-		if svc.Container.HealthCheck.StartPeriod != "" {
-			healthArgs = append(healthArgs, "--health-start-period", svc.Container.HealthCheck.StartPeriod)
+		if svc.Container.HealthCheck.StartPeriod > 0 {
+			healthArgs = append(healthArgs, "--health-start-period", svc.Container.HealthCheck.StartPeriod.String())
 		}
-		if svc.Container.HealthCheck.StartTimeout != "" {
-			healthArgs = append(healthArgs, "--health-start-timeout", svc.Container.HealthCheck.StartTimeout)
+		if svc.Container.HealthCheck.StartTimeout > 0 {
+			healthArgs = append(healthArgs, "--health-start-timeout", svc.Container.HealthCheck.StartTimeout.String())
 		}
 	}
 	args = append(args, healthArgs...)
 
+	if res := svc.Resources; res != nil {
+		if res.CPUs > 0 {
+			args = append(args, "--cpus", strconv.FormatFloat(res.CPUs, 'f', -1, 64))
+		}
+		if res.Memory != "" {
+			args = append(args, "--memory", res.Memory)
+		}
+		if res.MemoryReservation != "" {
+			args = append(args, "--memory-reservation", res.MemoryReservation)
+		}
+		if res.PidsLimit > 0 {
+			args = append(args, "--pids-limit", strconv.Itoa(res.PidsLimit))
+		}
+	}
+	if svc.Container != nil {
+		for _, ulimit := range svc.Container.ULimits {
+			args = append(args, "--ulimit", fmt.Sprintf("%s=%d:%d", ulimit.Name, ulimit.Soft, ulimit.Hard))
+		}
+		if svc.Container.User != "" {
+			args = append(args, "--user", svc.Container.User)
+		}
+		for _, capability := range svc.Container.CapAdd {
+			args = append(args, "--cap-add", capability)
+		}
+		for _, capability := range svc.Container.CapDrop {
+			args = append(args, "--cap-drop", capability)
+		}
+		if svc.Container.ReadOnly {
+			args = append(args, "--read-only")
+		}
+		for _, opt := range svc.Container.SecurityOpt {
+			args = append(args, "--security-opt", opt)
+		}
+		for _, mount := range svc.Container.Tmpfs {
+			args = append(args, "--tmpfs", mount)
+		}
+	}
+
 	for _, port := range svc.LocalPorts {
 		args = append(args, "-p", fmt.Sprintf("127.0.0.1:%d:%d", port, port))
 	}
@@ -218,17 +532,32 @@ func (dm *DockerManager) CreateAndRunContainer(networkName string, svc *config.S
 
 	hash, err := svc.Hash()
 	if err != nil {
-		return fmt.Errorf("failed to generate config hash: %w", err)
+		return nil, fmt.Errorf("failed to generate config hash: %w", err)
 	}
 	args = append(args, "--label", fmt.Sprintf("ftl.config-hash=%s", hash))
 
+	if svc.CrashAlert != nil && !runOnce {
+		threshold := svc.CrashAlert.Threshold
+		if threshold == 0 {
+			threshold = 3
+		}
+		args = append(args,
+			"--label", fmt.Sprintf("%s=%s", CrashAlertWebhookLabel, svc.CrashAlert.Webhook),
+			"--label", fmt.Sprintf("%s=%d", CrashAlertThresholdLabel, threshold),
+		)
+	}
+
 	if len(svc.Entrypoint) > 0 {
 		args = append(args, "--entrypoint", strings.Join(svc.Entrypoint, " "))
 	}
 
 	image := svc.Image
 	if image == "" {
-		image = fmt.Sprintf("%s-%s", networkName, svc.Name)
+		name := svc.Name
+		if svc.ReplicaOf != "" {
+			name = svc.ReplicaOf
+		}
+		image = fmt.Sprintf("%s-%s", networkName, name)
 	}
 	args = append(args, image)
 
@@ -239,8 +568,71 @@ func (dm *DockerManager) CreateAndRunContainer(networkName string, svc *config.S
 		args = append(args, svc.CommandSlice...)
 	}
 
-	_, err = dm.runCommand(context.Background(), "docker", args...)
-	return err
+	return args, nil
+}
+
+// LogArgs returns the docker run arguments that configure the log driver,
+// rotating json-file and local logs by default so they cannot fill the disk.
+func LogArgs(logging *config.Logging) []string {
+	if logging == nil {
+		logging = &config.Logging{}
+	}
+	driver := logging.Driver
+	if driver == "" {
+		driver = config.DefaultLogDriver
+	}
+
+	args := []string{"--log-driver", driver}
+	opt := func(name, value string) {
+		args = append(args, "--log-opt", name+"="+value)
+	}
+	switch driver {
+	case "json-file", "local":
+		maxSize, maxFile := logging.MaxSize, logging.MaxFile
+		if maxSize == "" {
+			maxSize = config.DefaultLogMaxSize
+		}
+		if maxFile == 0 {
+			maxFile = config.DefaultLogMaxFile
+		}
+		opt("max-size", maxSize)
+		opt("max-file", strconv.Itoa(maxFile))
+	case "syslog":
+		opt("syslog-address", logging.Address)
+	case "loki":
+		opt("loki-url", logging.Address)
+	}
+
+	names := make([]string, 0, len(logging.Options))
+	for name := range logging.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		opt(name, logging.Options[name])
+	}
+
+	return args
+}
+
+// VolumeBind returns the bind passed to "docker run -v" for a volume reference,
+// prefixing named volumes with the network name.
+func VolumeBind(networkName, vol string) string {
+	if unicode.IsLetter(rune(vol[0])) {
+		return fmt.Sprintf("%s-%s", networkName, vol)
+	}
+	return vol
+}
+
+// InspectContainer returns the Docker inspect details of the container with
+// the given serviceName alias in the network.
+func (dm *DockerManager) InspectContainer(networkName, serviceName string) (*ContainerDetails, error) {
+	return dm.findContainerDetails(networkName, serviceName)
+}
+
+// ImageID returns the ID of the image on the host, or "" if it does not exist.
+func (dm *DockerManager) ImageID(imageName string) (string, error) {
+	return dm.fetchImageID(imageName)
 }
 
 // ContainerNeedsUpdate determines if a container should be updated based on its configuration and image.
@@ -300,18 +692,75 @@ func (dm *DockerManager) fetchImageID(imageName string) (string, error) {
 }
 
 // PullImage pulls the specified image from the Docker registry and verifies it.
+// Failed pulls are retried with the policy; layers that finished downloading
+// stay in the local store, so each retry resumes where the previous attempt
+// stopped.
 func (dm *DockerManager) PullImage(imageName string) error {
-	_, err := dm.runCommand(context.Background(), "docker", "pull", imageName)
-	if err != nil {
-		return err
+	if err := dm.policy.Retry.Do(context.Background(), func() error {
+		return dm.pullImage(imageName)
+	}); err != nil {
+		return fmt.Errorf("failed to pull %s after %d attempts: %w", imageName, dm.policy.Retry.Attempts(), err)
 	}
-	_, err = dm.runCommand(context.Background(), "docker", "images", "--no-trunc", "--format={{.ID}}", imageName)
+
+	_, err := dm.runCommand(context.Background(), "docker", "images", "--no-trunc", "--format={{.ID}}", imageName)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// pullImage runs a single docker pull, bounded by the pull timeout, and
+// reports the layers that did not finish when it fails.
+func (dm *DockerManager) pullImage(imageName string) error {
+	ctx := context.Background()
+	if dm.policy.PullTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dm.policy.PullTimeout)
+		defer cancel()
+	}
+
+	output, err := runner.RunChecked(ctx, dm.runner, "docker", "pull", imageName)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("pull did not complete within %s", dm.policy.PullTimeout)
+	}
+	if err == nil {
+		return nil
+	}
+
+	if layers := incompleteLayers(string(output)); len(layers) > 0 {
+		return fmt.Errorf("layers %s did not complete: %w", strings.Join(layers, ", "), err)
+	}
+	return err
+}
+
+// incompleteLayers returns the layers in docker pull output that started but
+// never reached "Pull complete" or "Already exists".
+func incompleteLayers(output string) []string {
+	var order []string
+	done := map[string]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		layer, status, ok := strings.Cut(strings.TrimSpace(line), ": ")
+		if !ok || len(layer) != 12 || strings.ContainsAny(layer, " /:") {
+			continue
+		}
+		if _, seen := done[layer]; !seen {
+			order = append(order, layer)
+			done[layer] = false
+		}
+		if status == "Pull complete" || status == "Already exists" {
+			done[layer] = true
+		}
+	}
+
+	var layers []string
+	for _, layer := range order {
+		if !done[layer] {
+			layers = append(layers, layer)
+		}
+	}
+	return layers
+}
+
 // networkExists returns true if a Docker network with the specified name exists.
 func (dm *DockerManager) networkExists(networkName string) (bool, error) {
 	output, err := dm.runCommand(context.Background(), "docker", "network", "ls", "--format", "{{.Name}}")
@@ -347,6 +796,48 @@ func (dm *DockerManager) EnsureNetwork(networkName string) error {
 	return nil
 }
 
+// EnsureIPv6Network is EnsureNetwork for a network with IPv6 enabled, so
+// containers on it are reachable over IPv6 and see the addresses of IPv6
+// clients. Docker cannot enable IPv6 on an existing network, so one created
+// without it fails.
+func (dm *DockerManager) EnsureIPv6Network(networkName string) error {
+	exists, err := dm.networkExists(networkName)
+	if err != nil {
+		return fmt.Errorf("failed to check if network exists: %w", err)
+	}
+
+	if !exists {
+		if _, err := dm.runCommand(context.Background(), "docker", "network", "create", "--ipv6", networkName); err != nil {
+			return fmt.Errorf("failed to create network: %w", err)
+		}
+		return nil
+	}
+
+	enabled, err := dm.NetworkIPv6(networkName)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return fmt.Errorf("network %s was created without IPv6, remove it to recreate it with IPv6", networkName)
+	}
+	return nil
+}
+
+// NetworkIPv6 reports whether the Docker network has IPv6 enabled. A network
+// that does not exist has not.
+func (dm *DockerManager) NetworkIPv6(networkName string) (bool, error) {
+	exists, err := dm.networkExists(networkName)
+	if err != nil || !exists {
+		return false, err
+	}
+
+	output, err := dm.runCommand(context.Background(), "docker", "network", "inspect", "--format", "{{.EnableIPv6}}", networkName)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect network %s: %w", networkName, err)
+	}
+	return strings.TrimSpace(output) == "true", nil
+}
+
 // CreateVolume creates a Docker volume for the specified project and volume name if it does not already exist.
 func (dm *DockerManager) CreateVolume(ctx context.Context, project, volume string) error {
 	volumeName := fmt.Sprintf("%s-%s", project, volume)
@@ -361,3 +852,14 @@ func (dm *DockerManager) CreateVolume(ctx context.Context, project, volume strin
 
 	return nil
 }
+
+// httpHealthCmd returns the Docker health command of an http health check.
+// It fails on statuses from 400 unless the health check lists the statuses
+// it expects.
+func httpHealthCmd(port int, hc *config.ServiceHealthCheck) string {
+	url := fmt.Sprintf("http://localhost:%d%s", port, hc.Path)
+	if len(hc.Status) == 0 {
+		return fmt.Sprintf("curl -sf %s || exit 1", url)
+	}
+	return fmt.Sprintf("curl -s -o /dev/null -w '%%{http_code}' %s | grep -qE '%s' || exit 1", url, hc.StatusPattern())
+}
@@ -0,0 +1,325 @@
+package docker
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/retry"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestRunArgs_Resources(t *testing.T) {
+	svc := &config.Service{
+		Name:  "api",
+		Image: "api:latest",
+		Resources: &config.Resources{
+			CPUs:              0.5,
+			Memory:            "512m",
+			MemoryReservation: "256m",
+			PidsLimit:         100,
+		},
+		Container: &config.Container{
+			ULimits: []config.ULimit{{Name: "nofile", Soft: 1024, Hard: 2048}},
+		},
+	}
+
+	args, err := RunArgs("project", svc, "")
+	require.NoError(t, err)
+
+	assert.Subset(t, args, []string{
+		"--cpus", "0.5",
+		"--memory", "512m",
+		"--memory-reservation", "256m",
+		"--pids-limit", "100",
+		"--ulimit", "nofile=1024:2048",
+	})
+	assert.Equal(t, "api:latest", args[len(args)-1])
+}
+
+func TestRunArgs_Aliases(t *testing.T) {
+	svc := &config.Service{Name: "api-2", Image: "api:latest", ReplicaOf: "api", Aliases: []string{"api.internal"}}
+
+	args, err := RunArgs("project", svc, "")
+	require.NoError(t, err)
+	assert.Contains(t, strings.Join(args, " "), "--network-alias api-2 --network-alias api --network-alias api.internal")
+
+	// A replacement container gets the aliases once traffic switches to it.
+	args, err = RunArgs("project", svc, "_new")
+	require.NoError(t, err)
+	assert.Contains(t, strings.Join(args, " "), "--network-alias api-2_new --label")
+}
+
+func TestRunArgs_Security(t *testing.T) {
+	svc := &config.Service{
+		Name:  "api",
+		Image: "api:latest",
+		Container: &config.Container{
+			User:        "1000:1000",
+			CapAdd:      []string{"NET_BIND_SERVICE"},
+			CapDrop:     []string{"ALL"},
+			ReadOnly:    true,
+			SecurityOpt: []string{"no-new-privileges"},
+			Tmpfs:       []string{"/tmp:size=64m", "/run"},
+		},
+	}
+
+	args, err := RunArgs("project", svc, "")
+	require.NoError(t, err)
+
+	joined := strings.Join(args, " ")
+	assert.Contains(t, joined, "--user 1000:1000")
+	assert.Contains(t, joined, "--cap-add NET_BIND_SERVICE")
+	assert.Contains(t, joined, "--cap-drop ALL")
+	assert.Contains(t, joined, "--read-only")
+	assert.Contains(t, joined, "--security-opt no-new-privileges")
+	assert.Contains(t, joined, "--tmpfs /tmp:size=64m --tmpfs /run")
+	assert.Equal(t, "api:latest", args[len(args)-1])
+}
+
+func TestRunArgs_Revision(t *testing.T) {
+	svc := &config.Service{Name: "api", Image: "api:latest", Env: []string{"FTL_GIT_SHA=override"}}
+
+	args, err := RunArgs("project", svc, "")
+	require.NoError(t, err)
+	assert.NotContains(t, strings.Join(args, " "), RevisionLabel)
+
+	hash, err := svc.Hash()
+	require.NoError(t, err)
+
+	svc.Revision = config.Revision{Commit: "1a2b3c4", Dirty: true}
+	args, err = RunArgs("project", svc, "")
+	require.NoError(t, err)
+
+	joined := strings.Join(args, " ")
+	assert.Contains(t, joined, "-e FTL_GIT_SHA=1a2b3c4 -e FTL_GIT_DIRTY=true --label ftl.git-sha=1a2b3c4-dirty -e FTL_GIT_SHA=override", "the env of the service comes last and wins")
+	assert.Contains(t, joined, "--label ftl.config-hash="+hash, "the revision does not change the hash")
+}
+
+func TestRunArgs_RunOnce(t *testing.T) {
+	svc := &config.Service{
+		Name:      "api",
+		Image:     "api:latest",
+		Container: &config.Container{RunOnce: true},
+	}
+
+	args, err := RunArgs("project", svc, "_migrate")
+	require.NoError(t, err)
+
+	assert.Contains(t, args, "--rm")
+	assert.NotContains(t, args, "--restart")
+	assert.Contains(t, args, "project-api_migrate")
+}
+
+func TestRunArgs_RestartPolicy(t *testing.T) {
+	svc := &config.Service{Name: "api", Image: "api:latest"}
+
+	args, err := RunArgs("project", svc, "")
+	require.NoError(t, err)
+	assert.Subset(t, args, []string{"--restart", config.DefaultRestartPolicy})
+
+	svc.Restart = "on-failure:5"
+	svc.CrashAlert = &config.CrashAlert{Webhook: "https://hooks.example.com/T000"}
+
+	args, err = RunArgs("project", svc, "")
+	require.NoError(t, err)
+	assert.Subset(t, args, []string{
+		"--restart", "on-failure:5",
+		"--label", CrashAlertWebhookLabel + "=https://hooks.example.com/T000",
+		"--label", CrashAlertThresholdLabel + "=3",
+	})
+}
+
+func TestRunArgs_StopSignal(t *testing.T) {
+	svc := &config.Service{Name: "worker", Image: "worker:latest"}
+
+	args, err := RunArgs("project", svc, "")
+	require.NoError(t, err)
+	assert.NotContains(t, args, "--stop-signal")
+	assert.NotContains(t, args, "--stop-timeout")
+
+	svc.StopSignal = "SIGQUIT"
+	svc.StopGracePeriod = config.Duration(90500 * time.Millisecond)
+	args, err = RunArgs("project", svc, "")
+	require.NoError(t, err)
+	assert.Subset(t, args, []string{"--stop-signal", "SIGQUIT", "--stop-timeout", "91"})
+}
+
+func TestRunArgs_GRPCHealthCheck(t *testing.T) {
+	svc := &config.Service{
+		Name:        "api",
+		Image:       "api:latest",
+		Port:        50051,
+		HealthCheck: &config.ServiceHealthCheck{Type: config.HealthCheckGRPC, Retries: 3},
+	}
+
+	args, err := RunArgs("project", svc, "")
+	require.NoError(t, err)
+	assert.NotContains(t, args, "--health-cmd")
+
+	svc.HealthCheck.Type = config.HealthCheckHTTP
+	svc.HealthCheck.Path = "/healthz"
+
+	args, err = RunArgs("project", svc, "")
+	require.NoError(t, err)
+	assert.Contains(t, args, "curl -sf http://localhost:50051/healthz || exit 1")
+}
+
+func TestRunArgs_HealthCheckStatus(t *testing.T) {
+	svc := &config.Service{
+		Name:        "api",
+		Image:       "api:latest",
+		Port:        80,
+		HealthCheck: &config.ServiceHealthCheck{Path: "/healthz", Status: []int{200, 204}, Retries: 3},
+	}
+
+	args, err := RunArgs("project", svc, "")
+	require.NoError(t, err)
+	assert.Contains(t, args, "curl -s -o /dev/null -w '%{http_code}' http://localhost:80/healthz | grep -qE '^(200|204)$' || exit 1")
+}
+
+func TestCheckContainerHealth_Body(t *testing.T) {
+	svc := &config.Service{
+		Name: "api",
+		Port: 80,
+		HealthCheck: &config.ServiceHealthCheck{
+			Path:    "/healthz",
+			Body:    `"status":"(ok|up)"`,
+			JSON:    map[string]string{"checks.db": "ok"},
+			Timeout: config.Duration(2 * time.Second),
+			Retries: 2,
+		},
+	}
+
+	runner := fake.NewRunner()
+	runner.On("docker inspect", fake.Response{Output: "healthy"})
+	runner.On("docker run", fake.Response{Output: `{"status":"ok","checks":{"db":"ok"}}` + "\n200"})
+	dm := NewDockerManager(runner)
+
+	require.NoError(t, dm.CheckContainerHealth("abc", svc))
+	assert.Contains(t, runner.Calls()[1].String(), "docker run --rm --network container:abc "+HTTPProbeImage+" -s -w")
+	assert.Contains(t, runner.Calls()[1].String(), "--max-time 2 http://localhost:80/healthz")
+
+	runner = fake.NewRunner()
+	runner.On("docker inspect", fake.Response{Output: "healthy"})
+	runner.On("docker run", fake.Response{Output: `{"status":"ok","checks":{"db":"degraded"}}` + "\n200"})
+	runner.On("docker logs", fake.Response{Output: "listening"})
+	dm = NewDockerManager(runner)
+
+	err := dm.CheckContainerHealth("abc", svc)
+	assert.ErrorContains(t, err, `GET /healthz returned checks.db "degraded", expected "ok"`)
+}
+
+func TestIncompleteLayers(t *testing.T) {
+	output := `latest: Pulling from library/postgres
+a2318d6c47ec: Already exists
+2bc8c1e5c1a6: Pulling fs layer
+8f3c6e2b1d0a: Pulling fs layer
+2bc8c1e5c1a6: Download complete
+2bc8c1e5c1a6: Pull complete
+8f3c6e2b1d0a: Downloading
+error pulling image: unexpected EOF`
+
+	assert.Equal(t, []string{"8f3c6e2b1d0a"}, incompleteLayers(output))
+	assert.Empty(t, incompleteLayers("latest: Pulling from library/postgres\na2318d6c47ec: Pull complete"))
+}
+
+func TestPullImage_Retries(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker pull", fake.Response{Err: errors.New("connection reset by peer")})
+	dm := NewDockerManager(runner)
+	dm.SetPolicy(Policy{Retry: retry.Policy{Retries: 3, Backoff: time.Millisecond}})
+
+	err := dm.PullImage("postgres:17")
+	assert.ErrorContains(t, err, "failed to pull postgres:17 after 4 attempts")
+	assert.ErrorContains(t, err, "connection reset by peer")
+
+	pulls := 0
+	for _, call := range runner.Calls() {
+		if call.String() == "docker pull postgres:17" {
+			pulls++
+		}
+	}
+	assert.Equal(t, 4, pulls)
+}
+
+func TestRunArgs_Replica(t *testing.T) {
+	svc := &config.Service{Name: "web-2", ReplicaOf: "web", Port: 80}
+
+	args, err := RunArgs("project", svc, "")
+	require.NoError(t, err)
+	assert.Subset(t, args, []string{"--name", "project-web-2", "--network-alias", "web-2", "--network-alias", "web", "--label", "ftl.replica-of=web"})
+	assert.Equal(t, "project-web", args[len(args)-1])
+
+	args, err = RunArgs("project", svc, "_new")
+	require.NoError(t, err)
+	assert.NotContains(t, args, "web")
+}
+
+func TestServiceNetworks(t *testing.T) {
+	assert.Equal(t, []string{"project"}, ServiceNetworks("project", &config.Service{Name: "web"}))
+	assert.Equal(t, []string{"project", "project_backend"}, ServiceNetworks("project", &config.Service{Name: "web", Networks: []string{"backend"}}))
+	assert.Equal(t, []string{"project_backend", "project_jobs"}, ServiceNetworks("project", &config.Service{Name: "postgres", Networks: []string{"backend", "jobs"}, Isolated: true}))
+
+	args, err := RunArgs("project", &config.Service{Name: "postgres", Image: "postgres:17", Networks: []string{"backend"}, Isolated: true}, "")
+	require.NoError(t, err)
+	assert.Subset(t, args, []string{"--network", "project_backend", "--network-alias", "postgres"})
+	assert.NotContains(t, args, "project")
+}
+
+func TestCreateAndRunContainer_Networks(t *testing.T) {
+	runner := fake.NewRunner()
+	svc := &config.Service{Name: "web-2", ReplicaOf: "web", Image: "web:1", Port: 80, Networks: []string{"backend"}}
+	require.NoError(t, NewDockerManager(runner).CreateAndRunContainer("project", svc, ""))
+
+	calls := runner.Calls()
+	require.Len(t, calls, 2)
+	assert.True(t, strings.HasPrefix(calls[0].String(), "docker run --detach --name project-web-2 --network project "))
+	assert.Equal(t, "docker network connect --alias web-2 --alias web project_backend project-web-2", calls[1].String())
+
+	runner.Reset()
+	job := &config.Service{Name: "migrate", Image: "web:1", Networks: []string{"backend"}, Container: &config.Container{RunOnce: true}}
+	require.NoError(t, NewDockerManager(runner).CreateAndRunContainer("project", job, "run"))
+
+	calls = runner.Calls()
+	require.Len(t, calls, 3)
+	assert.True(t, strings.HasPrefix(calls[0].String(), "docker create --rm --name project-migraterun "))
+	assert.Equal(t, "docker network connect --alias migraterun project_backend project-migraterun", calls[1].String())
+	assert.Equal(t, "docker start --attach project-migraterun", calls[2].String())
+}
+
+func TestLogArgs(t *testing.T) {
+	assert.Equal(t, []string{"--log-driver", "json-file", "--log-opt", "max-size=10m", "--log-opt", "max-file=3"}, LogArgs(nil))
+	assert.Equal(t, []string{"--log-driver", "local", "--log-opt", "max-size=50m", "--log-opt", "max-file=5"},
+		LogArgs(&config.Logging{Driver: "local", MaxSize: "50m", MaxFile: 5}))
+	assert.Equal(t, []string{"--log-driver", "syslog", "--log-opt", "syslog-address=udp://logs.example.com:514", "--log-opt", "tag=web"},
+		LogArgs(&config.Logging{Driver: "syslog", Address: "udp://logs.example.com:514", Options: map[string]string{"tag": "web"}}))
+	assert.Equal(t, []string{"--log-driver", "loki", "--log-opt", "loki-url=https://loki.example.com/loki/api/v1/push", "--log-opt", "loki-batch-size=400", "--log-opt", "loki-retries=2"},
+		LogArgs(&config.Logging{Driver: "loki", Address: "https://loki.example.com/loki/api/v1/push", Options: map[string]string{"loki-retries": "2", "loki-batch-size": "400"}}))
+
+	args, err := RunArgs("project", &config.Service{Name: "api", Image: "api:latest", Logging: &config.Logging{Driver: "none"}}, "")
+	require.NoError(t, err)
+	assert.Subset(t, args, []string{"--log-driver", "none"})
+	assert.NotContains(t, args, "--log-opt")
+}
+
+func TestEnsureIPv6Network(t *testing.T) {
+	runner := fake.NewRunner()
+	runner.On("docker network ls", fake.Response{Output: "bridge\nhost"})
+	dm := NewDockerManager(runner)
+
+	require.NoError(t, dm.EnsureIPv6Network("shop"))
+	assert.Equal(t, "docker network create --ipv6 shop", runner.Calls()[1].String())
+
+	runner = fake.NewRunner()
+	runner.On("docker network ls", fake.Response{Output: "bridge\nshop"})
+	runner.On("docker network inspect", fake.Response{Output: "false"})
+	dm = NewDockerManager(runner)
+
+	assert.ErrorContains(t, dm.EnsureIPv6Network("shop"), "network shop was created without IPv6")
+}
@@ -0,0 +1,351 @@
+// Package doctor diagnoses why a project cannot be deployed or served: the
+// local Docker daemon, the connection to the server, the server's Docker and
+// disk, and whether the project's domains reach the proxy with a valid
+// certificate.
+package doctor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/deployment"
+	"github.com/yarlson/ftl/pkg/dns"
+	"github.com/yarlson/ftl/pkg/runner"
+)
+
+// Status is the outcome of a check.
+type Status int
+
+const (
+	Pass Status = iota
+	Warn
+	Fail
+	// Skip is reported for checks that need a step that failed before.
+	Skip
+)
+
+// Result is the outcome of one check, with what to do about it when it did
+// not pass.
+type Result struct {
+	Check   string
+	Status  Status
+	Message string
+	Remedy  string
+}
+
+// Thresholds of the disk and certificate checks.
+const (
+	minFreeDisk     = 5 << 30
+	criticalDisk    = 1 << 30
+	certExpiryWarn  = 14 * 24 * time.Hour
+	dialTimeout     = 5 * time.Second
+	remoteProbeTime = 30 * time.Second
+)
+
+// Runner runs commands, locally or on the server.
+type Runner interface {
+	RunCommand(ctx context.Context, command string, args ...string) (io.ReadCloser, error)
+}
+
+// ServerRunner runs commands on the server.
+type ServerRunner interface {
+	deployment.Runner
+	Close() error
+}
+
+// Doctor runs the checks. The zero values of the network functions use the
+// system resolver, dialer and certificate roots.
+type Doctor struct {
+	Config  *config.Config
+	Local   Runner
+	Connect func() (ServerRunner, error)
+
+	LookupHost func(ctx context.Context, host string) ([]string, error)
+	Dial       func(ctx context.Context, network, address string) (net.Conn, error)
+	Roots      *x509.CertPool
+	Now        func() time.Time
+	// HTTPSPort is the port the certificate check connects to, 443 unless
+	// set.
+	HTTPSPort int
+}
+
+// Run runs every check in order and returns their results. Checks on the
+// server are skipped when it cannot be reached.
+func (d *Doctor) Run(ctx context.Context) []Result {
+	d.defaults()
+
+	results := []Result{d.checkLocalDocker(ctx)}
+
+	runner, connected := d.checkSSH()
+	results = append(results, connected)
+	if runner != nil {
+		defer runner.Close()
+		results = append(results, d.checkServerDocker(ctx, runner), d.checkDisk(ctx, runner))
+	} else {
+		results = append(results,
+			Result{Check: "Server Docker", Status: Skip, Message: "server is not reachable"},
+			Result{Check: "Disk space", Status: Skip, Message: "server is not reachable"},
+		)
+	}
+
+	results = append(results, d.checkPorts(ctx)...)
+	results = append(results, d.checkDNS(ctx)...)
+	results = append(results, d.checkCertificates(ctx)...)
+	return results
+}
+
+func (d *Doctor) defaults() {
+	if d.LookupHost == nil {
+		d.LookupHost = net.DefaultResolver.LookupHost
+	}
+	if d.Dial == nil {
+		dialer := &net.Dialer{Timeout: dialTimeout}
+		d.Dial = dialer.DialContext
+	}
+	if d.Now == nil {
+		d.Now = time.Now
+	}
+	if d.HTTPSPort == 0 {
+		d.HTTPSPort = 443
+	}
+}
+
+func (d *Doctor) checkLocalDocker(ctx context.Context) Result {
+	result := Result{Check: "Local Docker"}
+	version, err := run(ctx, d.Local, "docker", "version", "--format", "{{.Server.Version}}")
+	if err != nil || version == "" {
+		result.Status = Fail
+		result.Message = "the local Docker daemon is not available"
+		result.Remedy = "Install Docker and start it (Docker Desktop on macOS and Windows, `systemctl start docker` on Linux). ftl builds images locally."
+		return result
+	}
+	result.Message = "Docker " + version
+	return result
+}
+
+func (d *Doctor) checkSSH() (ServerRunner, Result) {
+	server := d.Config.Server
+	result := Result{Check: "SSH"}
+	runner, err := d.Connect()
+	if err != nil {
+		result.Status = Fail
+		result.Message = fmt.Sprintf("cannot connect to %s@%s:%d: %v", server.User, server.Host, server.Port, err)
+		result.Remedy = fmt.Sprintf("Check server.host, server.user and server.ssh_key in ftl.yaml and try `ssh -i %s -p %d %s@%s`. Run `ftl setup` to create the user on a new server.", server.SSHKey, server.Port, server.User, server.Host)
+		return nil, result
+	}
+	result.Message = fmt.Sprintf("connected to %s@%s", server.User, server.Host)
+	return runner, result
+}
+
+func (d *Doctor) checkServerDocker(ctx context.Context, runner ServerRunner) Result {
+	result := Result{Check: "Server Docker"}
+	ctx, cancel := context.WithTimeout(ctx, remoteProbeTime)
+	defer cancel()
+
+	caps, err := deployment.NewDeployment(runner, nil).ServerCapabilities(ctx)
+	if err != nil {
+		result.Status = Fail
+		result.Message = fmt.Sprintf("Docker is not usable by %s: %v", d.Config.Server.User, err)
+		result.Remedy = "Run `ftl setup` to install Docker and give the user access to it, or check `systemctl status docker` on the server."
+		return result
+	}
+
+	if problems := deployment.CheckCapabilities(d.Config, caps); len(problems) > 0 {
+		result.Status = Fail
+		result.Message = strings.Join(problems, "; ")
+		result.Remedy = "Upgrade Docker on the server, or lower the resource limits in ftl.yaml."
+		return result
+	}
+	result.Message = "Docker " + caps.DockerVersion
+	return result
+}
+
+func (d *Doctor) checkDisk(ctx context.Context, runner ServerRunner) Result {
+	result := Result{Check: "Disk space"}
+	dir, err := run(ctx, runner, "docker", "info", "--format", "{{.DockerRootDir}}")
+	if err != nil || dir == "" {
+		dir = "/"
+	}
+
+	output, err := run(ctx, runner, "df", "-Pk", dir)
+	if err != nil {
+		result.Status = Warn
+		result.Message = fmt.Sprintf("cannot read free disk space: %v", err)
+		return result
+	}
+	free, used, err := parseDF(output)
+	if err != nil {
+		result.Status = Warn
+		result.Message = fmt.Sprintf("cannot read free disk space: %v", err)
+		return result
+	}
+
+	result.Message = fmt.Sprintf("%s free on %s (%d%% used)", formatBytes(free), dir, used)
+	switch {
+	case free < criticalDisk:
+		result.Status = Fail
+	case free < minFreeDisk:
+		result.Status = Warn
+	default:
+		return result
+	}
+	result.Remedy = "Free space with `ftl cleanup` or `docker system prune` on the server, or grow the disk. Deploys pull new images before the old ones are removed."
+	return result
+}
+
+// parseDF returns the available bytes and the used percentage from the output
+// of df -Pk.
+func parseDF(output string) (int64, int, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 2 {
+		return 0, 0, fmt.Errorf("unexpected df output %q", output)
+	}
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 5 {
+		return 0, 0, fmt.Errorf("unexpected df output %q", output)
+	}
+	available, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unexpected df output %q", output)
+	}
+	used, err := strconv.Atoi(strings.TrimSuffix(fields[4], "%"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("unexpected df output %q", output)
+	}
+	return available * 1024, used, nil
+}
+
+func (d *Doctor) checkPorts(ctx context.Context) []Result {
+	var results []Result
+	for _, port := range []int{80, 443} {
+		result := Result{Check: fmt.Sprintf("Port %d", port)}
+		address := net.JoinHostPort(d.Config.Server.Host, strconv.Itoa(port))
+		conn, err := d.Dial(ctx, "tcp", address)
+		switch {
+		case err == nil:
+			conn.Close()
+			result.Message = address + " is reachable"
+		case errors.Is(err, syscall.ECONNREFUSED):
+			result.Status = Warn
+			result.Message = address + " is open but nothing is listening"
+			result.Remedy = "The proxy is not running. Run `ftl deploy`, or check `ftl logs proxy`."
+		default:
+			result.Status = Fail
+			result.Message = fmt.Sprintf("%s is not reachable: %v", address, err)
+			result.Remedy = fmt.Sprintf("Allow TCP port %d in the server firewall (`ufw allow %d/tcp`) and in the security group or firewall of your cloud provider.", port, port)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func (d *Doctor) checkDNS(ctx context.Context) []Result {
+	serverIPs, err := d.LookupHost(ctx, d.Config.Server.Host)
+	if err != nil {
+		return []Result{{
+			Check:   "DNS",
+			Status:  Fail,
+			Message: fmt.Sprintf("cannot resolve server host %s: %v", d.Config.Server.Host, err),
+			Remedy:  "Set server.host to the IP address of the server, or create a DNS record for it.",
+		}}
+	}
+
+	var results []Result
+	for _, domain := range d.Config.Domains() {
+		result := Result{Check: "DNS " + domain}
+		addresses, err := d.LookupHost(ctx, domain)
+		if err != nil {
+			result.Status = Fail
+			result.Message = fmt.Sprintf("%s does not resolve: %v", domain, err)
+			result.Remedy = fmt.Sprintf("Create an %s record for %s pointing to %s.", dns.RecordType(serverIPs[0]), domain, serverIPs[0])
+			results = append(results, result)
+			continue
+		}
+
+		var matched bool
+		for _, address := range addresses {
+			if slices.ContainsFunc(serverIPs, func(serverIP string) bool { return dns.SameIP(serverIP, address) }) {
+				matched = true
+			}
+		}
+		if !matched {
+			result.Status = Fail
+			result.Message = fmt.Sprintf("%s resolves to %s, not to the server (%s)", domain, strings.Join(addresses, ", "), strings.Join(serverIPs, ", "))
+			result.Remedy = fmt.Sprintf("Point the %s record of %s to %s. If it is behind a CDN, make sure the CDN forwards to %s.", dns.RecordType(serverIPs[0]), domain, serverIPs[0], serverIPs[0])
+		} else {
+			result.Message = fmt.Sprintf("%s resolves to the server", domain)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func (d *Doctor) checkCertificates(ctx context.Context) []Result {
+	var results []Result
+	for _, domain := range d.Config.Domains() {
+		result := Result{Check: "Certificate " + domain}
+
+		conn, err := d.Dial(ctx, "tcp", net.JoinHostPort(domain, strconv.Itoa(d.HTTPSPort)))
+		if err != nil {
+			result.Status = Skip
+			result.Message = fmt.Sprintf("cannot connect to %s: %v", domain, err)
+			results = append(results, result)
+			continue
+		}
+
+		client := tls.Client(conn, &tls.Config{ServerName: domain, RootCAs: d.Roots, Time: d.Now})
+		err = client.HandshakeContext(ctx)
+		var certs []*x509.Certificate
+		if err == nil {
+			certs = client.ConnectionState().PeerCertificates
+		}
+		client.Close()
+
+		if err != nil {
+			result.Status = Fail
+			result.Message = fmt.Sprintf("%s does not present a valid certificate: %v", domain, err)
+			result.Remedy = "Certificates are issued over HTTP on port 80 once DNS points to the server. Fix the DNS and port checks above, then check `ftl logs proxy` for ACME errors."
+			results = append(results, result)
+			continue
+		}
+
+		expiry := certs[0].NotAfter
+		remaining := expiry.Sub(d.Now())
+		result.Message = fmt.Sprintf("valid until %s (%d days)", expiry.Format(time.DateOnly), int(remaining.Hours()/24))
+		if remaining < certExpiryWarn {
+			result.Status = Warn
+			result.Remedy = "The certificate should have been renewed by now. Check `ftl logs proxy` for renewal errors."
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// run runs a command and returns its trimmed output. It fails when the
+// command exits with a non-zero status.
+func run(ctx context.Context, r Runner, command string, args ...string) (string, error) {
+	output, err := runner.RunChecked(ctx, r, command, args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func formatBytes(bytes int64) string {
+	const gib = 1 << 30
+	if bytes >= gib {
+		return fmt.Sprintf("%.1fGiB", float64(bytes)/gib)
+	}
+	return fmt.Sprintf("%dMiB", bytes>>20)
+}
@@ -0,0 +1,159 @@
+package doctor
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+type serverRunner struct {
+	*fake.Runner
+}
+
+func (serverRunner) Close() error { return nil }
+
+func TestParseDF(t *testing.T) {
+	free, used, err := parseDF(`Filesystem     1024-blocks     Used Available Capacity Mounted on
+/dev/sda1         40470732 35123456   3276800      92% /`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3276800*1024), free)
+	assert.Equal(t, 92, used)
+
+	_, _, err = parseDF("df: /nope: No such file or directory")
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	// httptest serves the certificate for example.com, so the project domain
+	// and the server host both point at it.
+	https := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	https.Config.ErrorLog = log.New(io.Discard, "", 0)
+	https.StartTLS()
+	defer https.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(https.Certificate())
+	_, port, _ := net.SplitHostPort(https.Listener.Addr().String())
+	httpsPort, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	refused := closed.Addr().String()
+	closed.Close()
+
+	cfg := &config.Config{
+		Project: config.Project{Name: "app", Domain: "example.com"},
+		Server:  &config.Server{Host: "203.0.113.10", Port: 22, User: "deploy", SSHKey: "~/.ssh/id_ed25519"},
+		Services: []config.Service{
+			{Name: "web"},
+			{Name: "api", Domain: "api.example.org"},
+		},
+	}
+
+	local := fake.NewRunner()
+	local.On("docker version", fake.Response{Err: errors.New("Cannot connect to the Docker daemon")})
+
+	server := fake.NewRunner()
+	server.On("docker version", fake.Response{Output: "27.5.1"})
+	server.On("uname -r", fake.Response{Output: "6.8.0-45-generic"})
+	server.On("cat /proc/meminfo", fake.Response{Output: "MemTotal:        4015852 kB"})
+	server.On("nproc", fake.Response{Output: "2"})
+	server.On("cat /proc/sys/fs/nr_open", fake.Response{Output: "1048576"})
+	server.On("docker info", fake.Response{Output: "/var/lib/docker"})
+	server.On("df", fake.Response{Output: "Filesystem 1024-blocks Used Available Capacity Mounted on\n/dev/sda1 40470732 38000000 2097152 95% /"})
+
+	doctor := &Doctor{
+		Config:  cfg,
+		Local:   local,
+		Connect: func() (ServerRunner, error) { return serverRunner{server}, nil },
+		LookupHost: func(ctx context.Context, host string) ([]string, error) {
+			switch host {
+			case "203.0.113.10", "example.com":
+				return []string{"203.0.113.10"}, nil
+			case "api.example.org":
+				return []string{"198.51.100.7"}, nil
+			}
+			return nil, errors.New("no such host")
+		},
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			switch {
+			case strings.HasSuffix(address, ":80"):
+				return net.Dial("tcp", refused)
+			case strings.HasSuffix(address, ":443"):
+				return nil, errors.New("i/o timeout")
+			}
+			return net.Dial("tcp", https.Listener.Addr().String())
+		},
+		Roots:     roots,
+		Now:       func() time.Time { return https.Certificate().NotAfter.Add(-7 * 24 * time.Hour) },
+		HTTPSPort: httpsPort,
+	}
+
+	results := doctor.Run(context.Background())
+	statuses := map[string]Status{}
+	for _, result := range results {
+		statuses[result.Check] = result.Status
+		if result.Status == Fail || result.Status == Warn {
+			assert.NotEmpty(t, result.Remedy, result.Check)
+		}
+	}
+	assert.Equal(t, map[string]Status{
+		"Local Docker":                Fail,
+		"SSH":                         Pass,
+		"Server Docker":               Pass,
+		"Disk space":                  Warn,
+		"Port 80":                     Warn,
+		"Port 443":                    Fail,
+		"DNS example.com":             Pass,
+		"DNS api.example.org":         Fail,
+		"Certificate example.com":     Warn,
+		"Certificate api.example.org": Fail,
+	}, statuses)
+
+	doctor.Connect = func() (ServerRunner, error) { return nil, errors.New("connection refused") }
+	results = doctor.Run(context.Background())
+	assert.Equal(t, Fail, results[1].Status)
+	assert.Contains(t, results[1].Remedy, "ssh -i ~/.ssh/id_ed25519 -p 22 deploy@203.0.113.10")
+	assert.Equal(t, Skip, results[2].Status)
+	assert.Equal(t, Skip, results[3].Status)
+}
+
+func TestCheckDNS_IPv6(t *testing.T) {
+	doctor := &Doctor{
+		Config: &config.Config{
+			Project:  config.Project{Name: "app", Domain: "example.com"},
+			Server:   &config.Server{Host: "2001:db8::10"},
+			Services: []config.Service{{Name: "web"}, {Name: "api", Domain: "api.example.org"}},
+		},
+		LookupHost: func(ctx context.Context, host string) ([]string, error) {
+			switch host {
+			case "2001:db8::10":
+				return []string{"2001:db8::10"}, nil
+			case "example.com":
+				return []string{"2001:DB8:0::10"}, nil
+			}
+			return nil, errors.New("no such host")
+		},
+	}
+
+	results := doctor.checkDNS(context.Background())
+	require.Len(t, results, 2)
+	assert.Equal(t, Pass, results[0].Status)
+	assert.Equal(t, Fail, results[1].Status)
+	assert.Equal(t, "Create an AAAA record for api.example.org pointing to 2001:db8::10.", results[1].Remedy)
+}
@@ -0,0 +1,86 @@
+package ftl
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/docker"
+	"github.com/yarlson/ftl/pkg/imagesync"
+	"github.com/yarlson/ftl/pkg/runner/remote"
+	"github.com/yarlson/ftl/pkg/ssh"
+)
+
+// Connect opens an SSH connection to server and returns a runner whose docker
+// commands reach the daemon configured for the server, or the rootless daemon
+// of the deploy user when one is detected, and whose uploads are capped to the
+// transfer bandwidth of the server.
+func Connect(server *config.Server) (*remote.Runner, error) {
+	var sshKeyPath string
+	if server.SSHKey != "" {
+		sshKeyPath = filepath.Join(os.Getenv("HOME"), ".ssh", filepath.Base(server.SSHKey))
+	}
+	sshClient, err := ssh.FindKeyAndConnectWithUser(server.Host, server.Port, server.User, sshKeyPath, (*ssh.Jump)(server.ProxyJump))
+	if err != nil {
+		return nil, err
+	}
+
+	runner := remote.NewRunner(sshClient)
+	if err := configureDockerHost(runner, server); err != nil {
+		runner.Close()
+		return nil, err
+	}
+	if server.Transfer != nil && server.Transfer.Bandwidth != "" {
+		bandwidth, err := config.BandwidthBytes(server.Transfer.Bandwidth)
+		if err != nil {
+			runner.Close()
+			return nil, err
+		}
+		runner.SetUploadLimit(bandwidth)
+	}
+
+	return runner, nil
+}
+
+// NewImageSyncer creates an image syncer that transfers images through runner
+// as the image transfer mode of server sets, staging them in a temporary
+// local directory unless they are streamed. Layers are uploaded one at a time
+// unless the transfer settings of server allow more.
+func NewImageSyncer(runner *remote.Runner, server *config.Server) (*imagesync.ImageSync, error) {
+	localStore, err := os.MkdirTemp("", "dockersync-local")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create local store: %w", err)
+	}
+
+	parallel := 1
+	if server.Transfer != nil && server.Transfer.Parallel > 0 {
+		parallel = server.Transfer.Parallel
+	}
+
+	return imagesync.NewImageSync(imagesync.Config{
+		LocalStore:  localStore,
+		MaxParallel: parallel,
+		Stream:      server.ImageTransfer == config.ImageTransferStream,
+	}, runner), nil
+}
+
+// configureDockerHost points every docker command run through runner at the
+// daemon configured for the server, or at the rootless daemon of the deploy
+// user when one is detected.
+func configureDockerHost(runner *remote.Runner, server *config.Server) error {
+	if server.DockerHost == "" {
+		dockerHost, err := docker.DetectHost(context.Background(), runner)
+		if err != nil {
+			return err
+		}
+		server.DockerHost = dockerHost
+	}
+
+	if server.DockerHost != "" {
+		runner.SetEnv("DOCKER_HOST", server.DockerHost)
+	}
+
+	return nil
+}
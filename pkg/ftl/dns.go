@@ -0,0 +1,78 @@
+package ftl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/deployment"
+	"github.com/yarlson/ftl/pkg/dns"
+)
+
+// verifyDNS checks that the domains of the project point at the server. With
+// a dns provider configured the records that do not are created; when only
+// the check is configured they fail the deploy. Without a dns section only
+// the first deploy of the project checks them, and only warns.
+func (c *Client) verifyDNS(ctx context.Context, deploy *deployment.Deployment) error {
+	cfg := c.cfg
+	if cfg.Server == nil {
+		return nil
+	}
+	if cfg.DNS == nil {
+		history, err := deploy.History(ctx, cfg.Project.Name, 1)
+		if err != nil || len(history) > 0 {
+			return nil
+		}
+	}
+
+	c.stage("Checking DNS records...")
+	var resolver string
+	if cfg.DNS != nil {
+		resolver = cfg.DNS.Resolver
+	}
+	lookup := c.lookup(resolver)
+	serverIPs, err := lookup(ctx, cfg.Server.Host)
+	if err != nil {
+		if cfg.DNS == nil {
+			c.warn(fmt.Sprintf("Skipping the DNS check, cannot resolve server host %s: %v", cfg.Server.Host, err))
+			return nil
+		}
+		return fmt.Errorf("cannot resolve server host %s: %w", cfg.Server.Host, err)
+	}
+
+	records := dns.Plan(ctx, lookup, cfg.Domains(), serverIPs)
+	if len(records) == 0 {
+		return nil
+	}
+
+	if cfg.DNS != nil && cfg.DNS.Provider != "" {
+		provider, err := dns.New(cfg.DNS.Provider)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			c.stage(fmt.Sprintf("Pointing %s to %s...", record.Name, record.Value))
+			if err := provider.Upsert(ctx, cfg.DNS.Zone, record, cfg.DNS.RecordTTL()); err != nil {
+				return err
+			}
+		}
+		c.stage(fmt.Sprintf("Created %d DNS records with %s; certificates are issued once they propagate", len(records), cfg.DNS.Provider))
+		return nil
+	}
+
+	var problems []string
+	for _, record := range records {
+		current := "does not resolve"
+		if len(record.Current) > 0 {
+			current = "resolves to " + strings.Join(record.Current, ", ")
+		}
+		problems = append(problems, fmt.Sprintf("%s %s, create an %s record pointing to %s", record.Name, current, record.Type, record.Value))
+	}
+	if cfg.DNS == nil {
+		for _, problem := range problems {
+			c.warn(problem)
+		}
+		return nil
+	}
+	return fmt.Errorf("domains do not point at the server: %s", strings.Join(problems, "; "))
+}
@@ -0,0 +1,414 @@
+// Package ftl is the Go API for programs that embed ftl, such as the internal
+// tooling of a platform team. It deploys a parsed configuration the way the
+// ftl command line does, without its terminal output:
+//
+//	cfg, err := config.ParseConfigFile("ftl.yaml")
+//	if err != nil {
+//		return err
+//	}
+//	err = ftl.New(cfg, ftl.WithEventSink(func(event deployment.Event) {
+//		fmt.Println(event.Type, event.Service, event.Message)
+//	})).Deploy(ctx)
+//
+// The SSH settings of package ssh, such as the identity agent and the connect
+// timeouts of the server, are process-wide. Deploy applies those of its
+// configuration while it connects and holds them until the connection is
+// made, so Clients may deploy concurrently, but settings a program applies
+// itself while a Client connects are overwritten. The parsing settings of
+// package config, config.SetStrictFields and config.SetStrictEnv, are
+// process-wide as well: parse the configurations of Clients that need
+// different ones one at a time.
+package ftl
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+
+	"github.com/yarlson/ftl/pkg/build"
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/deployment"
+	"github.com/yarlson/ftl/pkg/dns"
+	"github.com/yarlson/ftl/pkg/runner/remote"
+	"github.com/yarlson/ftl/pkg/ssh"
+)
+
+// Client deploys one configuration. Create it with New.
+type Client struct {
+	cfg         *config.Config
+	runner      deployment.Runner
+	syncer      deployment.ImageSyncer
+	sink        func(deployment.Event)
+	logger      *slog.Logger
+	lockOwner   string
+	forceUnlock bool
+	services    []string
+	revision    *config.Revision
+	digests     string
+	timings     string
+	lookup      func(resolver string) dns.LookupFunc
+
+	events chan<- deployment.Event
+	phases map[string]float64
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithEventSink makes the deployment call sink with every event, in order,
+// from a single goroutine. Deploy returns once sink has handled the last one.
+func WithEventSink(sink func(deployment.Event)) Option {
+	return func(c *Client) {
+		c.sink = sink
+	}
+}
+
+// WithRunner makes the deployment run its commands with runner instead of
+// connecting to the server of the configuration over SSH, for instance to go
+// through another transport or to record the commands in tests. Services
+// without an image are only deployed with an image syncer set as well.
+func WithRunner(runner deployment.Runner) Option {
+	return func(c *Client) {
+		c.runner = runner
+	}
+}
+
+// WithImageSyncer makes the deployment transfer the images of services built
+// locally with syncer.
+func WithImageSyncer(syncer deployment.ImageSyncer) Option {
+	return func(c *Client) {
+		c.syncer = syncer
+	}
+}
+
+// WithLogger makes the deployment log every event to logger: failures at
+// error level, warnings at warning level and the rest at info level.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithLockOwner sets the description of the caller recorded in the deploy
+// lock, which ftl shows to anyone deploying the project in the meantime.
+func WithLockOwner(owner string) Option {
+	return func(c *Client) {
+		c.lockOwner = owner
+	}
+}
+
+// WithForceUnlock makes the deployment take over the deploy lock left behind
+// by an interrupted deployment.
+func WithForceUnlock() Option {
+	return func(c *Client) {
+		c.forceUnlock = true
+	}
+}
+
+// WithServices makes the deployment deploy only the named services, as
+// returned by deployment.SelectServices. Dependencies are always deployed and
+// the proxy keeps routing to services that are already running.
+func WithServices(services []string) Option {
+	return func(c *Client) {
+		c.services = services
+	}
+}
+
+// WithRevision records revision on the configuration as the commit it is
+// deployed from, for the labels of the containers and the history.
+func WithRevision(revision config.Revision) Option {
+	return func(c *Client) {
+		c.revision = &revision
+	}
+}
+
+// WithDigests makes the deployment run the images that ftl build pushed by
+// the digests it recorded in the file at path, see PinDigests.
+func WithDigests(path string) Option {
+	return func(c *Client) {
+		c.digests = path
+	}
+}
+
+// WithBuildTimings adds the build timings ftl build recorded in the file at
+// path to the phases of the deploy in the history. The file is cleared once
+// the deploy is recorded.
+func WithBuildTimings(path string) Option {
+	return func(c *Client) {
+		c.timings = path
+	}
+}
+
+// New returns a Client that deploys cfg, as parsed by config.ParseConfigFile,
+// with opts.
+func New(cfg *config.Config, opts ...Option) *Client {
+	c := &Client{cfg: cfg, lookup: dns.NewLookup}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Deploy deploys the services of the configuration while holding the deploy
+// lock of the project, as ftl deploy does: it checks the DNS records of the
+// domains, records the deploy in the history of the project, sends the
+// notifications configured for the deploy events and, when the configuration
+// asks for it, cleans up after a successful deploy.
+func (c *Client) Deploy(ctx context.Context) error {
+	events := make(chan deployment.Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range events {
+			c.handle(event)
+		}
+	}()
+	c.events = events
+
+	err := c.deploy(ctx)
+	if err != nil {
+		c.emit(deployment.Event{Type: deployment.EventFailed, Message: err.Error(), Err: err})
+	} else {
+		c.emit(deployment.Event{Type: deployment.EventCompleted, Message: "Deployment completed"})
+	}
+
+	c.events = nil
+	close(events)
+	<-done
+	return err
+}
+
+// Phases returns the seconds the last Deploy spent in each phase it went
+// through, including the timings of the builds since the deploy before.
+func (c *Client) Phases() map[string]float64 {
+	return c.phases
+}
+
+func (c *Client) deploy(ctx context.Context) error {
+	cfg := c.cfg
+	if c.runner == nil && cfg.Server == nil {
+		return fmt.Errorf("no server configured")
+	}
+	if c.revision != nil {
+		cfg.SetRevision(*c.revision)
+	}
+	if c.digests != "" {
+		if err := PinDigests(cfg, c.digests); err != nil {
+			return err
+		}
+	}
+
+	notifications := c.newNotifications()
+	notifications.send(config.EventDeployStarted, nil)
+	err := c.deployToServer(ctx)
+	notifications.send(deployment.Result(err), err)
+	return err
+}
+
+// deployToServer deploys the services and records the deploy in the history.
+func (c *Client) deployToServer(ctx context.Context) error {
+	cfg := c.cfg
+	project := cfg.Project.Name
+
+	runner, syncer := c.runner, c.syncer
+	if runner == nil {
+		c.stage("Connecting to server " + cfg.Server.Host + "...")
+		remoteRunner, err := connect(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to connect to server %s: %w", cfg.Server.Host, err)
+		}
+		defer remoteRunner.Close()
+		runner = remoteRunner
+
+		if syncer == nil {
+			if syncer, err = NewImageSyncer(remoteRunner, cfg.Server); err != nil {
+				return err
+			}
+		}
+	}
+
+	deploy := deployment.NewDeployment(runner, syncer)
+	deploy.SetEvents(c.events)
+
+	c.stage("Acquiring deploy lock...")
+	if err := deploy.Lock(ctx, project, c.owner(), c.forceUnlock); err != nil {
+		return err
+	}
+	defer func() {
+		_ = deploy.Unlock(context.Background(), project)
+	}()
+
+	if err := c.verifyDNS(ctx, deploy); err != nil {
+		return err
+	}
+
+	c.stage("Starting deployment process...")
+	started := time.Now()
+	deployErr := deploy.Deploy(ctx, project, cfg, nil, c.services)
+
+	c.phases = deploy.Timings()
+	if c.timings != "" {
+		buildTimings, err := build.LoadTimings(c.timings)
+		if err != nil {
+			c.warn(err.Error())
+		}
+		for phase, seconds := range buildTimings {
+			c.phases[phase] += seconds
+		}
+	}
+
+	entry := deployment.HistoryEntry{
+		Time:     started.UTC(),
+		User:     CurrentUser(),
+		Commit:   cfg.Revision.Commit,
+		Dirty:    cfg.Revision.Dirty,
+		Services: c.serviceNames(),
+		Result:   deployment.Result(deployErr),
+		Duration: time.Since(started).Seconds(),
+		Phases:   c.phases,
+	}
+	if deployErr != nil {
+		entry.Error = deployErr.Error()
+	}
+	if err := deploy.RecordHistory(context.Background(), project, cfg, entry); err != nil {
+		c.warn(err.Error())
+	} else if c.timings != "" {
+		if err := build.ClearTimings(c.timings); err != nil {
+			c.warn(err.Error())
+		}
+	}
+
+	if deployErr != nil {
+		return deployErr
+	}
+
+	if cfg.Cleanup != nil {
+		c.stage("Cleaning up old releases...")
+		if _, err := deploy.Cleanup(ctx, project, cfg, nil); err != nil {
+			c.warn(fmt.Sprintf("Cleanup failed: %v", err))
+		}
+	}
+
+	return nil
+}
+
+// connectMu serializes connecting, since the SSH settings connections are
+// made with are process-wide.
+var connectMu sync.Mutex
+
+// connect connects to the server of cfg with its identity agent and connect
+// policy.
+func connect(cfg *config.Config) (*remote.Runner, error) {
+	connectMu.Lock()
+	defer connectMu.Unlock()
+
+	ssh.SetIdentityAgent(cfg.Server.IdentityAgent)
+	ssh.SetConnectPolicy(cfg.ServerTimeouts().Connect.Duration(), cfg.RetryPolicy())
+	return Connect(cfg.Server)
+}
+
+// emit sends event to the sink and logger, in order with the events of the
+// deployment.
+func (c *Client) emit(event deployment.Event) {
+	event.Time = time.Now()
+	c.events <- event
+}
+
+// stage reports the start of a step of the deploy.
+func (c *Client) stage(message string) {
+	c.emit(deployment.Event{Type: deployment.EventStage, Message: message})
+}
+
+// warn reports a problem that does not fail the deploy.
+func (c *Client) warn(message string) {
+	c.emit(deployment.Event{Type: deployment.EventWarning, Message: message})
+}
+
+// serviceNames returns the names of the services the deploy covers: the
+// selected services, or every service when none were selected.
+func (c *Client) serviceNames() []string {
+	if c.services != nil {
+		return c.services
+	}
+
+	names := make([]string, 0, len(c.cfg.Services))
+	for _, service := range c.cfg.Services {
+		names = append(names, service.Name)
+	}
+	return names
+}
+
+// handle passes event to the sink and logger.
+func (c *Client) handle(event deployment.Event) {
+	if c.sink != nil {
+		c.sink(event)
+	}
+	if c.logger == nil {
+		return
+	}
+
+	attrs := []any{slog.String("event", string(event.Type))}
+	if event.Service != "" {
+		attrs = append(attrs, slog.String("service", event.Service))
+	}
+	switch {
+	case event.Err != nil:
+		c.logger.Error(event.Message, append(attrs, slog.Any("error", event.Err))...)
+	case event.Type == deployment.EventWarning:
+		c.logger.Warn(event.Message, attrs...)
+	default:
+		c.logger.Info(event.Message, attrs...)
+	}
+}
+
+// owner returns the lock owner, the host name of the caller unless one was
+// set.
+func (c *Client) owner() string {
+	if c.lockOwner != "" {
+		return c.lockOwner
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("ftl library on %s since %s", hostname, time.Now().Format(time.RFC3339))
+}
+
+// CurrentUser identifies the current user and machine as user@host, as
+// recorded in the history of the deploys.
+func CurrentUser() string {
+	username := "unknown"
+	if currentUser, err := user.Current(); err == nil {
+		username = currentUser.Username
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return fmt.Sprintf("%s@%s", username, hostname)
+}
+
+// PinDigests replaces the tag of every service image that ftl build pushed
+// with the digest it recorded in the file at path, so the server runs exactly
+// that build.
+func PinDigests(cfg *config.Config, path string) error {
+	digests, err := build.LoadDigests(path)
+	if err != nil {
+		return err
+	}
+
+	for i := range cfg.Services {
+		service := &cfg.Services[i]
+		if digest, ok := digests[service.Image]; ok {
+			service.PinImage(digest)
+		}
+	}
+
+	return nil
+}
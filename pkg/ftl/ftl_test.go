@@ -0,0 +1,207 @@
+package ftl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/build"
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/deployment"
+	"github.com/yarlson/ftl/pkg/dns"
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Project: config.Project{Name: "project", Domain: "example.com", Email: "admin@example.com"},
+		Server:  &config.Server{Host: "example.com"},
+	}
+}
+
+// withLookup makes the DNS check resolve names with lookup instead of the
+// network.
+func withLookup(lookup dns.LookupFunc) Option {
+	return func(c *Client) {
+		c.lookup = func(string) dns.LookupFunc { return lookup }
+	}
+}
+
+// resolveTo resolves every name to ip.
+func resolveTo(ip string) Option {
+	return withLookup(func(context.Context, string) ([]string, error) { return []string{ip}, nil })
+}
+
+// newRunner returns a fake runner on which the deploy lock can be taken.
+func newRunner() *fake.Runner {
+	runner := fake.NewRunner()
+	runner.On("sh -c echo $HOME", fake.Response{Output: "/home/deploy"})
+	runner.On("sh -c if mkdir", fake.Response{Output: "ftl-lock-acquired"})
+	runner.On("docker network inspect", fake.Response{Output: "[]"})
+	runner.On("docker inspect --format={{.State.Status}}", fake.Response{Output: "running"})
+	return runner
+}
+
+func TestDeploy(t *testing.T) {
+	runner := newRunner()
+
+	var events []deployment.Event
+	var logs bytes.Buffer
+	client := New(testConfig(),
+		WithRunner(runner),
+		WithEventSink(func(event deployment.Event) { events = append(events, event) }),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		WithLockOwner("platform-bot"),
+		resolveTo("203.0.113.10"),
+	)
+	require.NoError(t, client.Deploy(context.Background()))
+
+	require.NotEmpty(t, events)
+	assert.Equal(t, deployment.EventCompleted, events[len(events)-1].Type)
+	assert.Contains(t, logs.String(), "event=completed")
+
+	calls := runner.Calls()
+	var locked bool
+	for _, call := range calls {
+		locked = locked || strings.HasPrefix(call.String(), "sh -c if mkdir") && strings.Contains(call.String(), "platform-bot")
+	}
+	assert.True(t, locked, "the deploy lock names the owner")
+	assert.True(t, strings.HasPrefix(calls[len(calls)-1].String(), "rm -rf /home/deploy/projects/project/"), "the deploy lock is released last")
+}
+
+func TestDeploy_Locked(t *testing.T) {
+	runner := newRunner()
+	runner.On("sh -c if mkdir", fake.Response{Output: "alice@laptop"})
+
+	err := New(testConfig(), WithRunner(runner), resolveTo("203.0.113.10")).Deploy(context.Background())
+	assert.ErrorContains(t, err, "locked by alice@laptop")
+}
+
+func TestDeploy_Failed(t *testing.T) {
+	runner := newRunner()
+	runner.On("docker network inspect", fake.Response{Err: errors.New("permission denied")})
+	runner.On("docker network create", fake.Response{Err: errors.New("permission denied")})
+
+	var logs bytes.Buffer
+	err := New(testConfig(), WithRunner(runner), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))), resolveTo("203.0.113.10")).Deploy(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, logs.String(), "level=ERROR")
+	assert.Contains(t, logs.String(), "event=failed")
+}
+
+func TestDeploy_NoServer(t *testing.T) {
+	cfg := testConfig()
+	cfg.Server = nil
+	assert.EqualError(t, New(cfg).Deploy(context.Background()), "no server configured")
+}
+
+// historyEntry returns the entry the deploy appended to the history.
+func historyEntry(t *testing.T, runner *fake.Runner) deployment.HistoryEntry {
+	t.Helper()
+	for _, call := range runner.Calls() {
+		script := call.String()
+		if !strings.HasPrefix(script, "sh -c printf") || !strings.Contains(script, "history") {
+			continue
+		}
+		start, end := strings.Index(script, "{"), strings.LastIndex(script, "}")
+		var entry deployment.HistoryEntry
+		require.NoError(t, json.Unmarshal([]byte(script[start:end+1]), &entry))
+		return entry
+	}
+	t.Fatal("the deploy was not recorded in the history")
+	return deployment.HistoryEntry{}
+}
+
+func TestDeploy_History(t *testing.T) {
+	dir := t.TempDir()
+	timings := filepath.Join(dir, ".ftl", "build-timings.json")
+	require.NoError(t, build.SaveTimings(timings, map[string]float64{deployment.PhaseBuild: 42}))
+
+	runner := newRunner()
+	client := New(testConfig(),
+		WithRunner(runner),
+		WithRevision(config.Revision{Commit: "abc1234", Dirty: true}),
+		WithBuildTimings(timings),
+		resolveTo("203.0.113.10"),
+	)
+	require.NoError(t, client.Deploy(context.Background()))
+
+	entry := historyEntry(t, runner)
+	assert.Equal(t, "abc1234", entry.Commit)
+	assert.True(t, entry.Dirty)
+	assert.Equal(t, config.EventDeploySucceeded, entry.Result)
+	assert.Equal(t, CurrentUser(), entry.User)
+	assert.Equal(t, 42.0, entry.Phases[deployment.PhaseBuild])
+	assert.Equal(t, 42.0, client.Phases()[deployment.PhaseBuild])
+	assert.NoFileExists(t, timings, "build timings are cleared once recorded")
+}
+
+func TestDeploy_FailureRecorded(t *testing.T) {
+	runner := newRunner()
+	runner.On("docker network inspect", fake.Response{Err: errors.New("permission denied")})
+	runner.On("docker network create", fake.Response{Err: errors.New("permission denied")})
+
+	require.Error(t, New(testConfig(), WithRunner(runner), resolveTo("203.0.113.10")).Deploy(context.Background()))
+	entry := historyEntry(t, runner)
+	assert.Equal(t, config.EventDeployFailed, entry.Result)
+	assert.Contains(t, entry.Error, "permission denied")
+}
+
+func TestDeploy_DNSWarning(t *testing.T) {
+	runner := newRunner()
+	lookup := withLookup(func(_ context.Context, host string) ([]string, error) {
+		if host == "example.com" {
+			return []string{"203.0.113.10"}, nil
+		}
+		return []string{"198.51.100.1"}, nil
+	})
+	cfg := testConfig()
+	cfg.Services = []config.Service{{Name: "web", Image: "nginx:1.27", Port: 80, Routes: []config.Route{{PathPrefix: "/"}}}}
+	cfg.Project.Domain = "shop.example.com"
+	runner.On("docker inspect --format={{.State.Status}}", fake.Response{Err: errors.New("no such container")})
+
+	var warnings []string
+	var logs bytes.Buffer
+	_ = New(cfg,
+		WithRunner(runner),
+		WithEventSink(func(event deployment.Event) {
+			if event.Type == deployment.EventWarning {
+				warnings = append(warnings, event.Message)
+			}
+		}),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		lookup,
+	).Deploy(context.Background())
+
+	require.NotEmpty(t, warnings)
+	assert.Contains(t, warnings[0], "shop.example.com resolves to 198.51.100.1")
+	assert.Contains(t, logs.String(), "level=WARN")
+}
+
+func TestDeploy_Services(t *testing.T) {
+	runner := newRunner()
+	cfg := testConfig()
+	cfg.Services = []config.Service{{Name: "web", Image: "nginx:1.27", Port: 80}, {Name: "worker", Image: "busybox:1.36"}}
+
+	_ = New(cfg, WithRunner(runner), WithServices([]string{"worker"}), resolveTo("203.0.113.10")).Deploy(context.Background())
+	assert.Equal(t, []string{"worker"}, historyEntry(t, runner).Services)
+}
+
+func TestPinDigests(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "digests.json")
+	require.NoError(t, build.SaveDigests(path, map[string]string{"registry.example.com/web:latest": "registry.example.com/web@sha256:abc"}))
+
+	cfg := testConfig()
+	cfg.Services = []config.Service{{Name: "web", Image: "registry.example.com/web:latest"}, {Name: "worker", Image: "busybox:1.36"}}
+	require.NoError(t, PinDigests(cfg, path))
+	assert.Equal(t, "registry.example.com/web@sha256:abc", cfg.Services[0].Image)
+	assert.Equal(t, "busybox:1.36", cfg.Services[1].Image)
+}
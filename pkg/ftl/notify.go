@@ -0,0 +1,47 @@
+package ftl
+
+import (
+	"context"
+	"time"
+
+	"github.com/yarlson/ftl/pkg/notify"
+)
+
+// notifications reports the lifecycle of one deploy to the notification
+// channels of the configuration.
+type notifications struct {
+	client   *Client
+	notifier *notify.Notifier
+	event    notify.Event
+	started  time.Time
+}
+
+func (c *Client) newNotifications() *notifications {
+	event := notify.Event{
+		Project:  c.cfg.Project.Name,
+		Commit:   c.cfg.Revision.String(),
+		Services: c.serviceNames(),
+	}
+	if c.cfg.Server != nil {
+		event.Server = c.cfg.Server.Host
+	}
+	return &notifications{
+		client:   c,
+		notifier: notify.NewNotifier(c.cfg.Notifications),
+		event:    event,
+		started:  time.Now(),
+	}
+}
+
+// send delivers an event. Delivery failures are reported as warnings but
+// never fail the deploy.
+func (n *notifications) send(eventType string, err error) {
+	event := n.event
+	event.Type = eventType
+	event.Duration = time.Since(n.started)
+	event.Err = err
+
+	if err := n.notifier.Notify(context.Background(), event); err != nil {
+		n.client.warn(err.Error())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -14,8 +15,10 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/yarlson/ftl/pkg/runner/remote"
+	"github.com/yarlson/ftl/pkg/shell"
 )
 
 // Config holds the configuration for the Docker image sync operation.
@@ -23,12 +26,24 @@ type Config struct {
 	LocalStore  string
 	RemoteStore string
 	MaxParallel int
+	// Retries is how many times a failed blob transfer is attempted before
+	// the sync gives up. Blobs that were already transferred are kept, so a
+	// later sync only sends what is still missing.
+	Retries int
+	// Stream sends the whole image, compressed, straight to docker load on
+	// the server instead of syncing its layers through the stores. Nothing
+	// is staged on either side, but unchanged layers are sent again.
+	Stream bool
 }
 
+// partialSuffix marks blobs that are still being uploaded to the remote store.
+const partialSuffix = ".partial"
+
 // ImageSync handles Docker image synchronization operations.
 type ImageSync struct {
-	cfg    Config
-	runner *remote.Runner
+	cfg      Config
+	runner   *remote.Runner
+	progress func(message string)
 }
 
 // NewImageSync creates a new ImageSync instance with the provided configuration and SSH runner.
@@ -36,6 +51,9 @@ func NewImageSync(cfg Config, runner *remote.Runner) *ImageSync {
 	if cfg.MaxParallel <= 0 {
 		cfg.MaxParallel = 4
 	}
+	if cfg.Retries <= 0 {
+		cfg.Retries = 3
+	}
 	if cfg.LocalStore == "" {
 		cfg.LocalStore = filepath.Join(os.Getenv("HOME"), "docker-images")
 	}
@@ -57,6 +75,13 @@ func (s *ImageSync) Sync(ctx context.Context, image string) (bool, error) {
 		return false, nil // Images are identical
 	}
 
+	if s.cfg.Stream {
+		if err := s.streamImage(ctx, image); err != nil {
+			return false, fmt.Errorf("failed to stream image: %w", err)
+		}
+		return true, nil
+	}
+
 	if err := s.prepareDirectories(ctx); err != nil {
 		return false, fmt.Errorf("failed to prepare directories: %w", err)
 	}
@@ -370,26 +395,48 @@ func (s *ImageSync) transferBlobs(ctx context.Context, image string, blobs []str
 			semaphore <- struct{}{}
 			defer func() { <-semaphore }()
 
-			if err := s.transferBlob(ctx, image, blob); err != nil {
-				errChan <- fmt.Errorf("failed to transfer blob %s: %w", blob, err)
+			if err := s.transferBlobWithRetry(ctx, image, blob); err != nil {
+				errChan <- err
 			}
 		}(blob)
 	}
 
-	go func() {
-		wg.Wait()
-		close(errChan)
-	}()
+	wg.Wait()
+	close(errChan)
 
+	var errs []error
 	for err := range errChan {
-		if err != nil {
-			return err
-		}
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d layers failed, completed layers will be reused on the next sync: %w", len(errs), len(blobs), errors.Join(errs...))
 	}
 
 	return nil
 }
 
+// transferBlobWithRetry transfers a blob, retrying with a growing delay when
+// the transfer fails.
+func (s *ImageSync) transferBlobWithRetry(ctx context.Context, image, blob string) error {
+	var err error
+	for attempt := 1; attempt <= s.cfg.Retries; attempt++ {
+		if err = s.transferBlob(ctx, image, blob); err == nil {
+			return nil
+		}
+		if attempt == s.cfg.Retries {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("layer %s: %w", shortDigest(blob), ctx.Err())
+		case <-time.After(time.Duration(attempt) * time.Second):
+		}
+	}
+
+	return fmt.Errorf("layer %s failed after %d attempts: %w", shortDigest(blob), s.cfg.Retries, err)
+}
+
 func (s *ImageSync) loadRemoteImage(ctx context.Context, image string) error {
 	cmd := fmt.Sprintf("cd %s && tar -cf - . | docker load",
 		filepath.Join(s.cfg.RemoteStore, normalizeImageName(image)))
@@ -491,22 +538,86 @@ func (s *ImageSync) listRemoteBlobs(ctx context.Context, image string) ([]string
 		return nil, err
 	}
 
-	blobs := strings.Fields(string(data))
-	return blobs, nil
+	return completedBlobs(strings.Fields(string(data))), nil
+}
+
+// completedBlobs drops blobs whose upload was interrupted.
+func completedBlobs(names []string) []string {
+	var blobs []string
+	for _, name := range names {
+		if !strings.HasSuffix(name, partialSuffix) {
+			blobs = append(blobs, name)
+		}
+	}
+	return blobs
 }
 
-// transferBlob copies a single blob to the remote host.
+// transferBlob copies a single blob to the remote host. The blob is uploaded
+// under a temporary name and only moved into place once its digest has been
+// verified, so an interrupted upload never looks like a completed layer.
 func (s *ImageSync) transferBlob(ctx context.Context, image string, blob string) error {
 	imageDir := normalizeImageName(image)
 	localPath := filepath.Join(s.cfg.LocalStore, imageDir, "blobs", "sha256", blob)
 	remotePath := filepath.Join(s.cfg.RemoteStore, imageDir, "blobs", "sha256", blob)
+	partialPath := remotePath + partialSuffix
 
 	_, err := s.runner.RunCommand(ctx, "mkdir", "-p", filepath.Dir(remotePath))
 	if err != nil {
 		return err
 	}
 
-	return s.runner.CopyFile(ctx, localPath, remotePath)
+	if err := s.runner.CopyFile(ctx, localPath, partialPath); err != nil {
+		return err
+	}
+
+	// The digest is compared here rather than with sha256sum -c, whose flags
+	// differ between GNU and BusyBox.
+	partial := shell.Quote(partialPath)
+	output, err := s.runner.RunCommand(ctx, fmt.Sprintf("sha256sum %s 2>/dev/null || shasum -a 256 %s", partial, partial))
+	if err != nil {
+		return fmt.Errorf("failed to verify blob: %w", err)
+	}
+	data, err := io.ReadAll(output)
+	_ = output.Close()
+	if err != nil {
+		return fmt.Errorf("failed to verify blob: %w", err)
+	}
+	if err := checkBlobDigest(blob, string(data)); err != nil {
+		return err
+	}
+
+	output, err = s.runner.RunCommand(ctx, shell.Join("mv", "-f", partialPath, remotePath)+" && echo moved")
+	if err != nil {
+		return fmt.Errorf("failed to move blob into place: %w", err)
+	}
+	data, _ = io.ReadAll(output)
+	_ = output.Close()
+	if strings.TrimSpace(string(data)) != "moved" {
+		return fmt.Errorf("failed to move blob into place: %s", strings.TrimSpace(string(data)))
+	}
+
+	return nil
+}
+
+// checkBlobDigest checks the output of sha256sum for an uploaded blob against
+// the digest the blob is named after.
+func checkBlobDigest(blob, output string) error {
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return fmt.Errorf("failed to verify blob %s: no digest computed", shortDigest(blob))
+	}
+	if fields[0] != blob {
+		return fmt.Errorf("blob digest mismatch after upload: expected %s, got %s", shortDigest(blob), shortDigest(fields[0]))
+	}
+	return nil
+}
+
+// shortDigest returns the abbreviated form docker uses when it reports layers.
+func shortDigest(blob string) string {
+	if len(blob) > 12 {
+		return blob[:12]
+	}
+	return blob
 }
 
 // transferMetadata copies the image metadata files to the remote host.
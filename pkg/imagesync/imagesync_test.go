@@ -107,3 +107,15 @@ func TestImageSync(t *testing.T) {
 	_, err = sync.Sync(ctx, testImage)
 	require.NoError(t, err)
 }
+
+func TestCompletedBlobs(t *testing.T) {
+	blobs := completedBlobs([]string{"aaa", "bbb.partial", "ccc"})
+	require.Equal(t, []string{"aaa", "ccc"}, blobs)
+}
+
+func TestCheckBlobDigest(t *testing.T) {
+	const blob = "4ac6d7f0f3be9e2b1a5c7d40"
+	require.NoError(t, checkBlobDigest(blob, blob+"  /home/deploy/docker-images/app/blobs/sha256/"+blob+".partial\n"))
+	require.EqualError(t, checkBlobDigest(blob, "9e2b1a5c7d404ac6d7f0f3be  file\n"), "blob digest mismatch after upload: expected 4ac6d7f0f3be, got 9e2b1a5c7d40")
+	require.Error(t, checkBlobDigest(blob, ""))
+}
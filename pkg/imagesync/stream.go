@@ -0,0 +1,117 @@
+package imagesync
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is how often the progress of a streamed image is reported.
+const progressInterval = time.Second
+
+// SetProgress makes the sync report the progress of transfers to progress.
+func (s *ImageSync) SetProgress(progress func(message string)) {
+	s.progress = progress
+}
+
+// streamImage pipes docker save of image, compressed, to docker load on the
+// server, reporting the amount sent while it runs.
+func (s *ImageSync) streamImage(ctx context.Context, image string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	save := exec.CommandContext(ctx, "docker", "save", image)
+	stdout, err := save.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr strings.Builder
+	save.Stderr = &stderr
+	if err := save.Start(); err != nil {
+		return fmt.Errorf("failed to save image: %w", err)
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		gz, _ := gzip.NewWriterLevel(writer, gzip.BestSpeed)
+		_, err := io.Copy(gz, stdout)
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+		if waitErr := save.Wait(); err == nil && waitErr != nil {
+			err = fmt.Errorf("failed to save image: %w: %s", waitErr, strings.TrimSpace(stderr.String()))
+		}
+		_ = writer.CloseWithError(err)
+	}()
+
+	counter := &countingReader{reader: reader}
+	stop := s.reportProgress(image, counter)
+	output, err := s.runner.RunCommandWithInput(ctx, counter, "docker", "load")
+	stop()
+	// The loader stops reading when the save fails, so the save error says
+	// more than the load error.
+	_ = reader.CloseWithError(err)
+	if err != nil {
+		return fmt.Errorf("failed to load image on the server: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// reportProgress reports the bytes counter has read every progressInterval
+// until the returned function is called.
+func (s *ImageSync) reportProgress(image string, counter *countingReader) func() {
+	if s.progress == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				s.progress(fmt.Sprintf("Streaming image %s: %s sent...", image, formatBytes(counter.count.Load())))
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	reader io.Reader
+	count  atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count.Add(int64(n))
+	return n, err
+}
+
+// formatBytes returns size in the largest binary unit it reaches.
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
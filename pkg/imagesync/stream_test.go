@@ -0,0 +1,32 @@
+package imagesync
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountingReader(t *testing.T) {
+	counter := &countingReader{reader: strings.NewReader("docker image")}
+	data, err := io.ReadAll(counter)
+	require.NoError(t, err)
+	assert.Equal(t, "docker image", string(data))
+	assert.Equal(t, int64(12), counter.count.Load())
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1024:            "1.0 KiB",
+		1536:            "1.5 KiB",
+		5 * 1024 * 1024: "5.0 MiB",
+		3 << 30:         "3.0 GiB",
+	}
+	for size, want := range tests {
+		assert.Equal(t, want, formatBytes(size), size)
+	}
+}
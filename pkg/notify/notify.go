@@ -0,0 +1,186 @@
+// Package notify delivers deploy lifecycle events to the channels configured
+// under notifications in ftl.yaml.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yarlson/ftl/pkg/config"
+)
+
+const (
+	requestTimeout   = 10 * time.Second
+	defaultEmailPort = 587
+)
+
+// Event describes one step of a deployment.
+type Event struct {
+	Type     string
+	Project  string
+	Server   string
+	Commit   string
+	Services []string
+	Duration time.Duration
+	Err      error
+}
+
+// Message renders the event as a single line of text.
+func (e Event) Message() string {
+	var b strings.Builder
+	b.WriteString("Deployment of " + e.Project)
+	if e.Server != "" {
+		b.WriteString(" to " + e.Server)
+	}
+
+	switch e.Type {
+	case config.EventDeployStarted:
+		b.WriteString(" started")
+	case config.EventDeploySucceeded:
+		b.WriteString(" succeeded in " + e.Duration.Round(time.Second).String())
+	case config.EventDeployFailed:
+		b.WriteString(" failed after " + e.Duration.Round(time.Second).String())
+	case config.EventDeployRolledBack:
+		b.WriteString(" was rolled back after " + e.Duration.Round(time.Second).String())
+	}
+
+	if e.Commit != "" {
+		b.WriteString(" (" + e.Commit + ")")
+	}
+	if len(e.Services) > 0 {
+		b.WriteString(", services: " + strings.Join(e.Services, ", "))
+	}
+	if e.Err != nil {
+		b.WriteString(": " + e.Err.Error())
+	}
+
+	return b.String()
+}
+
+// webhookPayload is the JSON body posted to generic webhooks.
+type webhookPayload struct {
+	Event    string   `json:"event"`
+	Project  string   `json:"project"`
+	Server   string   `json:"server,omitempty"`
+	Commit   string   `json:"commit,omitempty"`
+	Services []string `json:"services"`
+	Duration float64  `json:"duration_seconds"`
+	Error    string   `json:"error,omitempty"`
+	Message  string   `json:"message"`
+}
+
+// Notifier sends events to the configured channels.
+type Notifier struct {
+	channels []config.Notification
+	client   *http.Client
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewNotifier creates a notifier for the given channels.
+func NewNotifier(channels []config.Notification) *Notifier {
+	return &Notifier{
+		channels: channels,
+		client:   &http.Client{Timeout: requestTimeout},
+		sendMail: smtp.SendMail,
+	}
+}
+
+// Notify sends the event to every channel subscribed to it. A failing channel
+// does not stop delivery to the others; all failures are returned together.
+func (n *Notifier) Notify(ctx context.Context, event Event) error {
+	var errs []error
+	for _, channel := range n.channels {
+		if !channel.Wants(event.Type) {
+			continue
+		}
+		if err := n.send(ctx, channel, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s notification failed: %w", channel.Type, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) send(ctx context.Context, channel config.Notification, event Event) error {
+	switch channel.Type {
+	case config.NotificationSlack:
+		return n.post(ctx, channel.URL, map[string]string{"text": event.Message()})
+	case config.NotificationDiscord:
+		return n.post(ctx, channel.URL, map[string]string{"content": event.Message()})
+	case config.NotificationWebhook:
+		payload := webhookPayload{
+			Event:    event.Type,
+			Project:  event.Project,
+			Server:   event.Server,
+			Commit:   event.Commit,
+			Services: event.Services,
+			Duration: event.Duration.Seconds(),
+			Message:  event.Message(),
+		}
+		if event.Err != nil {
+			payload.Error = event.Err.Error()
+		}
+		return n.post(ctx, channel.URL, payload)
+	case config.NotificationEmail:
+		return n.email(channel.Email, event)
+	}
+
+	return fmt.Errorf("unknown notification type %q", channel.Type)
+}
+
+func (n *Notifier) post(ctx context.Context, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (n *Notifier) email(settings *config.EmailNotification, event Event) error {
+	port := settings.Port
+	if port == 0 {
+		port = defaultEmailPort
+	}
+
+	var auth smtp.Auth
+	if settings.Username != "" {
+		auth = smtp.PlainAuth("", settings.Username, settings.Password, settings.Host)
+	}
+
+	subject := fmt.Sprintf("[ftl] %s: deployment %s", event.Project, strings.ReplaceAll(event.Type, "_", " "))
+	msg := "From: " + settings.From + "\r\n" +
+		"To: " + strings.Join(settings.To, ", ") + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		event.Message() + "\r\n"
+
+	addr := net.JoinHostPort(settings.Host, strconv.Itoa(port))
+	return n.sendMail(addr, auth, settings.From, settings.To, []byte(msg))
+}
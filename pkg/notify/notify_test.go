@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/config"
+)
+
+func TestEventMessage(t *testing.T) {
+	event := Event{
+		Type:     config.EventDeployFailed,
+		Project:  "shop",
+		Server:   "shop.example.com",
+		Commit:   "a1b2c3d",
+		Services: []string{"web", "worker"},
+		Duration: 83400 * time.Millisecond,
+		Err:      errors.New("web is unhealthy"),
+	}
+
+	assert.Equal(t, "Deployment of shop to shop.example.com failed after 1m23s (a1b2c3d), services: web, worker: web is unhealthy", event.Message())
+
+	event.Type = config.EventDeployStarted
+	event.Err = nil
+	assert.Equal(t, "Deployment of shop to shop.example.com started (a1b2c3d), services: web, worker", event.Message())
+}
+
+func TestNotify(t *testing.T) {
+	var received []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		received = append(received, body)
+	}))
+	defer server.Close()
+
+	var mailTo []string
+	notifier := NewNotifier([]config.Notification{
+		{Type: config.NotificationSlack, URL: server.URL},
+		{Type: config.NotificationDiscord, URL: server.URL, Events: []string{config.EventDeployFailed}},
+		{Type: config.NotificationWebhook, URL: server.URL},
+		{Type: config.NotificationEmail, Email: &config.EmailNotification{Host: "smtp.example.com", From: "ftl@example.com", To: []string{"ops@example.com"}}},
+	})
+	notifier.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		assert.Equal(t, "smtp.example.com:587", addr)
+		assert.Contains(t, string(msg), "Subject: [ftl] shop: deployment succeeded\r\n")
+		mailTo = to
+		return nil
+	}
+
+	err := notifier.Notify(context.Background(), Event{
+		Type:     config.EventDeploySucceeded,
+		Project:  "shop",
+		Commit:   "a1b2c3d",
+		Services: []string{"web"},
+		Duration: 2 * time.Second,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, received, 2)
+	assert.Equal(t, "Deployment of shop succeeded in 2s (a1b2c3d), services: web", received[0]["text"])
+	assert.Equal(t, "succeeded", received[1]["event"])
+	assert.Equal(t, "a1b2c3d", received[1]["commit"])
+	assert.Equal(t, 2.0, received[1]["duration_seconds"])
+	assert.Equal(t, []string{"ops@example.com"}, mailTo)
+}
+
+func TestNotify_ReportsFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	notifier := NewNotifier([]config.Notification{{Type: config.NotificationSlack, URL: server.URL}})
+	err := notifier.Notify(context.Background(), Event{Type: config.EventDeployStarted, Project: "shop"})
+	assert.ErrorContains(t, err, "slack notification failed: unexpected status 403 Forbidden")
+}
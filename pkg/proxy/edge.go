@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// EdgeContainer is the proxy shared by the projects of a server with
+// server.shared set. It owns ports 80 and 443 and routes each domain to the
+// proxy of the project serving it, which terminates TLS with the
+// certificates of that project.
+const EdgeContainer = "ftl-edge"
+
+// EdgeNetwork is the network the shared proxy reaches the project proxies on.
+const EdgeNetwork = "ftl-edge"
+
+// EdgeStreamConfig is the name of the file holding the stream block of the
+// shared proxy, next to its HTTP configuration. It is included in the main
+// context, so it must not end in .conf.
+const EdgeStreamConfig = "stream.inc"
+
+// GenerateEdgeConfig returns the HTTP configuration and the stream block of
+// the shared proxy, routing the domains of upstreams, keyed by the project
+// proxy serving them. HTTPS connections are passed through by server name
+// with the PROXY protocol, so project proxies see the address of clients;
+// plain HTTP is proxied by host and carries it in X-Forwarded-For. With ipv6
+// set it listens on IPv6 as well.
+func GenerateEdgeConfig(upstreams map[string][]string, ipv6 bool) (string, string) {
+	var routes []string
+	for upstream, domains := range upstreams {
+		for _, domain := range domains {
+			routes = append(routes, domain+" "+upstream)
+		}
+	}
+	slices.Sort(routes)
+
+	var http, stream strings.Builder
+	http.WriteString("map $host $ftl_edge_upstream {\n    hostnames;\n    default \"\";\n")
+	stream.WriteString("stream {\n    map $ssl_preread_server_name $ftl_edge_upstream {\n        default 127.0.0.1:1;\n")
+	for _, route := range routes {
+		fmt.Fprintf(&http, "    %s;\n", route)
+		fmt.Fprintf(&stream, "        %s:443;\n", route)
+	}
+	http.WriteString(`}
+
+server {
+    listen 80 default_server;
+` + listenIPv6(ipv6, "    ", "80 default_server") + `    resolver 127.0.0.11 valid=10s;
+
+    if ($ftl_edge_upstream = "") {
+        return 404;
+    }
+
+    location / {
+        proxy_pass http://$ftl_edge_upstream;
+        proxy_http_version 1.1;
+        proxy_set_header Host $host;
+        proxy_set_header X-Real-IP $remote_addr;
+        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+        proxy_set_header X-Forwarded-Proto http;
+    }
+}
+`)
+	stream.WriteString(`    }
+
+    server {
+        listen 443;
+` + listenIPv6(ipv6, "        ", "443") + `        resolver 127.0.0.11 valid=10s;
+        ssl_preread on;
+        proxy_protocol on;
+        proxy_pass $ftl_edge_upstream;
+    }
+}
+`)
+	return http.String(), stream.String()
+}
+
+// listenIPv6 returns the listen directive for the IPv6 wildcard address with
+// params at indent, or nothing without ipv6.
+func listenIPv6(ipv6 bool, indent, params string) string {
+	if !ipv6 {
+		return ""
+	}
+	return indent + "listen [::]:" + params + ";\n"
+}
@@ -0,0 +1,37 @@
+package proxy
+
+// MaintenanceDir is the directory, relative to the nginx configuration
+// directory, that holds the maintenance page. Maintenance mode is on while
+// MaintenanceFlag exists in it; the proxy checks for the file on every
+// request, so switching needs no reload.
+const (
+	MaintenanceDir  = "maintenance"
+	MaintenanceFlag = "on"
+	MaintenancePage = "index.html"
+)
+
+// maintenancePath is where the maintenance directory is mounted in the proxy
+// container.
+const maintenancePath = "/etc/nginx/conf.d/" + MaintenanceDir
+
+// DefaultMaintenancePage is served in maintenance mode unless the
+// configuration names a page.
+const DefaultMaintenancePage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Down for maintenance</title>
+<style>
+body { font-family: system-ui, sans-serif; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; color: #333; }
+main { text-align: center; padding: 2rem; }
+</style>
+</head>
+<body>
+<main>
+<h1>Down for maintenance</h1>
+<p>We are performing scheduled maintenance and will be back shortly.</p>
+</main>
+</body>
+</html>
+`
@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"fmt"
+	"html/template"
+)
+
+const (
+	// MetricsPort is the port of the proxy's metrics server inside the
+	// proxy container.
+	MetricsPort = 9113
+	// MetricsDir is where the proxy container mounts the metrics written by
+	// the metrics agent.
+	MetricsDir = "/var/lib/ftl/metrics"
+	// MetricsExporter is the service the proxy sends its access log to.
+	MetricsExporter = "metrics"
+
+	metricsExporterPort = 4040
+	metricsSyslogPort   = 5531
+)
+
+// metricsLogFormat is the access log format shared by the proxy and the
+// exporter parsing it. $proxy_host is the upstream, i.e. the service name.
+const metricsLogFormat = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent" "$proxy_host" $request_time $upstream_response_time`
+
+func renderMetricsLogFormat() template.HTML {
+	return template.HTML(metricsLogFormat)
+}
+
+// MetricsExporterConfig returns the configuration of the exporter that turns
+// the proxy access log into request and upstream latency metrics.
+func MetricsExporterConfig() string {
+	return fmt.Sprintf(`listen:
+  port: %d
+  address: 0.0.0.0
+  metrics_endpoint: /metrics
+namespaces:
+  - name: ftl
+    format: '%s'
+    source:
+      syslog:
+        listen_address: udp://0.0.0.0:%d
+        format: rfc3164
+        tags:
+          - nginx
+    relabel_configs:
+      - target_label: service
+        from: proxy_host
+    histogram_buckets: [0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10]
+`, metricsExporterPort, metricsLogFormat, metricsSyslogPort)
+}
@@ -0,0 +1,245 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/config"
+)
+
+// cacheZone is the shared nginx cache used by the cache middleware.
+const cacheZone = "ftl_cache"
+
+// AuthDir is the directory, relative to the nginx configuration directory,
+// that holds the user files of the auth middleware.
+const AuthDir = "htpasswd"
+
+// authPath is where the nginx configuration directory is mounted in the
+// proxy container.
+const authPath = "/etc/nginx/conf.d/" + AuthDir
+
+// middlewareRenderers turn each middleware kind into nginx location directives.
+// New proxy features register here instead of adding their own route fields.
+var middlewareRenderers = map[string]func(config.Middleware) []string{
+	"headers":    renderHeaders,
+	"cache":      renderCache,
+	"allow_ips":  renderAllowIPs,
+	"auth":       renderAuth,
+	"cors":       renderCORS,
+	"rate_limit": renderRateLimit,
+}
+
+// renderMiddleware renders a route's middleware chain in declaration order,
+// one indented directive per line, ready to be placed inside a location block.
+func renderMiddleware(route config.Route) template.HTML {
+	var b strings.Builder
+	for _, m := range route.Middleware {
+		render, ok := middlewareRenderers[m.Kind()]
+		if !ok {
+			continue
+		}
+		for _, directive := range render(m) {
+			b.WriteString("\n\t\t\t")
+			b.WriteString(directive)
+		}
+	}
+	return template.HTML(b.String())
+}
+
+// usesMiddleware reports whether any route of cfg uses the given middleware kind.
+func usesMiddleware(cfg *config.Config, kind string) bool {
+	for _, svc := range cfg.Services {
+		for _, route := range svc.Routes {
+			for _, m := range route.Middleware {
+				if m.Kind() == kind {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func renderHeaders(m config.Middleware) []string {
+	names := make([]string, 0, len(m.Headers.Set))
+	for name := range m.Headers.Set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var directives []string
+	for _, name := range names {
+		directives = append(directives, fmt.Sprintf("add_header %s %s always;", name, nginxQuote(m.Headers.Set[name])))
+	}
+	for _, name := range m.Headers.Remove {
+		directives = append(directives, fmt.Sprintf("proxy_hide_header %s;", name))
+	}
+	return directives
+}
+
+func renderCache(m config.Middleware) []string {
+	return []string{
+		"proxy_cache " + cacheZone + ";",
+		fmt.Sprintf("proxy_cache_valid 200 301 302 %ds;", int(m.Cache.TTL.Duration().Seconds())),
+		"proxy_cache_use_stale error timeout updating;",
+		"add_header X-Cache-Status $upstream_cache_status always;",
+	}
+}
+
+func renderAllowIPs(m config.Middleware) []string {
+	directives := make([]string, 0, len(m.AllowIPs)+1)
+	for _, ip := range m.AllowIPs {
+		directives = append(directives, fmt.Sprintf("allow %s;", ip))
+	}
+	return append(directives, "deny all;")
+}
+
+func renderAuth(m config.Middleware) []string {
+	realm := m.Auth.Realm
+	if realm == "" {
+		realm = "Restricted"
+	}
+	return []string{
+		fmt.Sprintf("auth_basic %s;", nginxQuote(realm)),
+		fmt.Sprintf("auth_basic_user_file %s/%s;", authPath, authFileName(m.Auth)),
+	}
+}
+
+// corsMethods are the methods allowed when a cors middleware sets none.
+var corsMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// renderCORS sets $cors_origin to the request origin when it is allowed, adds
+// the CORS headers to responses and answers preflight requests from allowed
+// origins with 204 before they reach the upstream. nginx omits headers with
+// an empty value, so requests from other origins get none.
+func renderCORS(m config.Middleware) []string {
+	cors := m.CORS
+
+	directives := []string{`set $cors_origin "";`}
+	if cors.AllowsAnyOrigin() {
+		directives = append(directives, `if ($http_origin != "") { set $cors_origin "*"; }`)
+	} else {
+		origins := make([]string, len(cors.AllowOrigins))
+		for i, origin := range cors.AllowOrigins {
+			origins[i] = regexp.QuoteMeta(origin)
+		}
+		directives = append(directives, fmt.Sprintf("if ($http_origin ~ %s) { set $cors_origin $http_origin; }", nginxQuote("^("+strings.Join(origins, "|")+")$")))
+	}
+
+	methods := cors.AllowMethods
+	if len(methods) == 0 {
+		methods = corsMethods
+	}
+	allowHeaders := "$http_access_control_request_headers"
+	if len(cors.AllowHeaders) > 0 {
+		allowHeaders = nginxQuote(strings.Join(cors.AllowHeaders, ", "))
+	}
+
+	headers := []string{"add_header Access-Control-Allow-Origin $cors_origin always;"}
+	if !cors.AllowsAnyOrigin() {
+		headers = append(headers, "add_header Vary Origin always;")
+	}
+	if cors.AllowCredentials {
+		headers = append(headers, "add_header Access-Control-Allow-Credentials $cors_credentials always;")
+		directives = append(directives, `set $cors_credentials "";`, `if ($cors_origin != "") { set $cors_credentials true; }`)
+	}
+
+	preflight := append([]string{}, headers...)
+	preflight = append(preflight,
+		fmt.Sprintf("add_header Access-Control-Allow-Methods %s always;", nginxQuote(strings.Join(methods, ", "))),
+		fmt.Sprintf("add_header Access-Control-Allow-Headers %s always;", allowHeaders),
+	)
+	if cors.MaxAge > 0 {
+		preflight = append(preflight, fmt.Sprintf("add_header Access-Control-Max-Age %d always;", int(cors.MaxAge.Duration().Seconds())))
+	}
+	preflight = append(preflight, "return 204;")
+
+	directives = append(directives,
+		`set $cors_preflight "$request_method:$cors_origin";`,
+		fmt.Sprintf(`if ($cors_preflight ~ "^OPTIONS:.") { %s }`, strings.Join(preflight, " ")),
+	)
+	directives = append(directives, headers...)
+	if len(cors.ExposeHeaders) > 0 {
+		directives = append(directives, fmt.Sprintf("add_header Access-Control-Expose-Headers %s always;", nginxQuote(strings.Join(cors.ExposeHeaders, ", "))))
+	}
+	return directives
+}
+
+// rateLimitZone returns the nginx zone a rate_limit middleware counts requests
+// in and the variable clients are keyed by. Routes with the same rate and key
+// share a zone, and so a budget.
+func rateLimitZone(rateLimit *config.RateLimitMiddleware) (zone, key string) {
+	key = "$binary_remote_addr"
+	if rateLimit.Header != "" {
+		key = "$http_" + strings.ReplaceAll(strings.ToLower(rateLimit.Header), "-", "_")
+	}
+	sum := sha256.Sum256([]byte(key + " " + rateLimit.Rate))
+	return "ftl_rate_" + hex.EncodeToString(sum[:])[:8], key
+}
+
+// rateLimitZones returns the limit_req_zone directives of every rate_limit
+// middleware of cfg, sorted and without duplicates.
+func rateLimitZones(cfg *config.Config) []string {
+	seen := map[string]struct{}{}
+	var zones []string
+	for _, svc := range cfg.Services {
+		for _, route := range svc.Routes {
+			for _, m := range route.Middleware {
+				if m.RateLimit == nil {
+					continue
+				}
+				zone, key := rateLimitZone(m.RateLimit)
+				directive := fmt.Sprintf("limit_req_zone %s zone=%s:10m rate=%s;", key, zone, m.RateLimit.Rate)
+				if _, ok := seen[directive]; !ok {
+					seen[directive] = struct{}{}
+					zones = append(zones, directive)
+				}
+			}
+		}
+	}
+	sort.Strings(zones)
+	return zones
+}
+
+func renderRateLimit(m config.Middleware) []string {
+	zone, _ := rateLimitZone(m.RateLimit)
+	return []string{
+		fmt.Sprintf("limit_req zone=%s burst=%d nodelay;", zone, m.RateLimit.Burst),
+		"limit_req_status 429;",
+	}
+}
+
+// authFileName names the user file of an auth middleware after its contents,
+// so routes with the same users share a file and changing users changes the
+// configuration.
+func authFileName(auth *config.AuthMiddleware) string {
+	sum := sha256.Sum256([]byte(strings.Join(auth.Users, "\n")))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// AuthFiles returns the user files the auth middleware of cfg refers to, keyed
+// by file name. They belong in AuthDir next to the generated configuration.
+func AuthFiles(cfg *config.Config) map[string]string {
+	files := map[string]string{}
+	for _, svc := range cfg.Services {
+		for _, route := range svc.Routes {
+			for _, m := range route.Middleware {
+				if m.Auth != nil {
+					files[authFileName(m.Auth)] = strings.Join(m.Auth.Users, "\n") + "\n"
+				}
+			}
+		}
+	}
+	return files
+}
+
+// nginxQuote wraps value in double quotes, escaping characters nginx treats
+// specially inside a quoted string.
+func nginxQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
@@ -8,29 +8,277 @@ import (
 	"github.com/yarlson/ftl/pkg/config"
 )
 
+// StaticRoot is the directory inside the proxy container where static service
+// files are mounted, one subdirectory per service.
+const StaticRoot = "/usr/share/nginx/static"
+
+// ACMEUpstream is the certificate manager container. Without an HTTPS
+// redirect the proxy answers port 80 itself and forwards ACME HTTP-01
+// challenges to it. It only runs when certificates are issued with ACME;
+// otherwise the proxy answers port 80 and redirects to HTTPS itself.
+const ACMEUpstream = "zero"
+
+// serverBlock groups the services routed under a single domain.
+type serverBlock struct {
+	Domain   string
+	Default  bool
+	Services []config.Service
+}
+
+// templateData is the data passed to the nginx template.
+type templateData struct {
+	StaticRoot      string
+	PlainHTTP       bool
+	Shared          bool
+	IPv6            bool
+	ServeHTTP       bool
+	RedirectHTTP    bool
+	Protocols       string
+	Ciphers         template.HTML
+	HSTS            string
+	ACME            string
+	HashedAssets    bool
+	Cache           bool
+	CacheZone       string
+	RateZones       []string
+	Maintenance     string
+	MaintenanceOn   string
+	MaintenancePage string
+	BypassIPs       []string
+	Metrics         bool
+	MetricsPort     int
+	MetricsDir      string
+	Exporter        string
+	ExporterPort    int
+	SyslogPort      int
+	Upstreams       []config.Service
+	Servers         []serverBlock
+}
+
 // GenerateNginxConfig generates an Nginx configuration based on the provided config.
 func GenerateNginxConfig(cfg *config.Config) (string, error) {
+	return generateNginxConfig(cfg, false)
+}
+
+// GenerateDevNginxConfig generates the configuration used by `ftl dev`. It
+// serves the same routes over plain HTTP on port 80, and the project domain
+// is the default server, so the stack can be reached on localhost.
+func GenerateDevNginxConfig(cfg *config.Config) (string, error) {
+	return generateNginxConfig(cfg, true)
+}
+
+func generateNginxConfig(cfg *config.Config, plainHTTP bool) (string, error) {
 	if cfg.Project.Domain == "" {
 		cfg.Project.Domain = "localhost"
 	}
 
-	tmpl := template.Must(template.New("nginx").Parse(`
-{{- range .Services}}
+	// On a shared server the certificate manager does not own port 80, so
+	// the proxy redirects and forwards the ACME challenges itself.
+	shared := !plainHTTP && cfg.Server != nil && cfg.Server.Shared
+	data := templateData{
+		StaticRoot: StaticRoot,
+		PlainHTTP:  plainHTTP,
+		Shared:     shared,
+		IPv6:       !plainHTTP && cfg.Server != nil && cfg.Server.IPv6,
+		Cache:      usesMiddleware(cfg, "cache"),
+		CacheZone:  cacheZone,
+		RateZones:  rateLimitZones(cfg),
+		// ftl dev has no metrics exporter to send the access log to.
+		Metrics:         cfg.Metrics != nil && !plainHTTP,
+		MetricsPort:     MetricsPort,
+		MetricsDir:      MetricsDir,
+		Exporter:        MetricsExporter,
+		ExporterPort:    metricsExporterPort,
+		SyslogPort:      metricsSyslogPort,
+		ServeHTTP:       !cfg.TLS.RedirectsHTTP(),
+		RedirectHTTP:    !plainHTTP && cfg.TLS.RedirectsHTTP() && (!cfg.TLS.UsesACME() || shared),
+		Protocols:       cfg.TLS.Protocols(),
+		Maintenance:     maintenancePath,
+		MaintenanceOn:   maintenancePath + "/" + MaintenanceFlag,
+		MaintenancePage: "/" + MaintenancePage,
+	}
+	if cfg.TLS.UsesACME() {
+		data.ACME = ACMEUpstream
+	}
+	if cfg.Maintenance != nil {
+		data.BypassIPs = cfg.Maintenance.AllowIPs
+	}
+	if cfg.TLS != nil && !plainHTTP {
+		// OpenSSL cipher strings use "+", which html/template would escape.
+		data.Ciphers = template.HTML(strings.Join(cfg.TLS.Ciphers, ":"))
+		if cfg.TLS.HSTS != nil {
+			data.HSTS = cfg.TLS.HSTS.Header()
+		}
+	}
+	for _, svc := range cfg.Services {
+		// Workers are not requested, and nginx fails on upstreams that do
+		// not resolve.
+		if svc.IsWorker() {
+			continue
+		}
+		if svc.Static == nil {
+			data.Upstreams = append(data.Upstreams, svc)
+			continue
+		}
+		if svc.Static.HashedAssets {
+			data.HashedAssets = true
+		}
+	}
+	for _, domain := range cfg.Domains() {
+		block := serverBlock{Domain: domain, Default: plainHTTP && len(data.Servers) == 0}
+		for i := range cfg.Services {
+			if cfg.ServiceDomain(&cfg.Services[i]) == domain {
+				block.Services = append(block.Services, cfg.Services[i])
+			}
+		}
+		data.Servers = append(data.Servers, block)
+	}
+
+	tmpl := template.Must(template.New("nginx").Funcs(template.FuncMap{
+		"middleware":       renderMiddleware,
+		"metricsLogFormat": renderMetricsLogFormat,
+		"staticPrefix":     staticPrefix,
+	}).Parse(`
+{{- $staticRoot := .StaticRoot }}
+{{- if .Shared}}
+	set_real_ip_from 0.0.0.0/0;
+	set_real_ip_from ::/0;
+	real_ip_header proxy_protocol;
+{{- end}}
+{{- if .Cache}}
+	proxy_cache_path /var/cache/nginx/{{.CacheZone}} levels=1:2 keys_zone={{.CacheZone}}:10m max_size=1g inactive=60m use_temp_path=off;
+{{- end}}
+{{- if not .PlainHTTP}}
+	geo $ftl_maintenance_bypass {
+		default 0;
+	{{- range .BypassIPs}}
+		{{.}} 1;
+	{{- end}}
+	}
+{{- end}}
+{{- range .RateZones}}
+	{{.}}
+{{- end}}
+{{- if .HashedAssets}}
+	map $uri $static_cache_control {
+		"~[.-](?=[A-Za-z0-9_]*[0-9])[A-Za-z0-9_]{8,}\.[A-Za-z0-9]+$" "public, max-age=31536000, immutable";
+		default "no-cache";
+	}
+{{- end}}
+{{- if .Metrics}}
+	log_format ftl_metrics '{{metricsLogFormat}}';
+	access_log syslog:server={{.Exporter}}:{{.SyslogPort}},tag=nginx,nohostname ftl_metrics;
+
+	server {
+		listen {{.MetricsPort}};
+		{{- if .IPv6}}
+		listen [::]:{{.MetricsPort}};
+		{{- end}}
+		access_log off;
+
+		location = /metrics {
+			resolver 127.0.0.11 valid=1s;
+			set $metrics_exporter {{.Exporter}};
+			proxy_pass http://$metrics_exporter:{{.ExporterPort}}/metrics;
+		}
+
+		location = /metrics/agent {
+			alias {{.MetricsDir}}/agent.prom;
+			default_type "text/plain; version=0.0.4";
+		}
+	}
+{{- end}}
+{{- range .Upstreams}}
 	upstream {{.Name}} {
 		server {{.Name}}:{{.Port}};
 	}
 {{- end}}
+{{- $plainHTTP := .PlainHTTP }}
+{{- $serveHTTP := .ServeHTTP }}
+{{- $protocols := .Protocols }}
+{{- $ciphers := .Ciphers }}
+{{- $hsts := .HSTS }}
+{{- $acme := .ACME }}
+{{- $shared := .Shared }}
+{{- $ipv6 := .IPv6 }}
+{{- $maintenance := .Maintenance }}
+{{- $maintenanceOn := .MaintenanceOn }}
+{{- $maintenancePage := .MaintenancePage }}
+{{- if .RedirectHTTP}}
+
+	server {
+		listen 80 default_server;
+		{{- if $ipv6}}
+		listen [::]:80 default_server;
+		{{- end}}
+	{{- if $acme}}
+
+		location ^~ /.well-known/acme-challenge/ {
+			resolver 127.0.0.11 valid=1s;
+			set $acme {{$acme}};
+			proxy_pass http://$acme;
+		}
+
+		location / {
+			return 301 https://$host$request_uri;
+		}
+	{{- else}}
+		return 301 https://$host$request_uri;
+	{{- end}}
+	}
+{{- end}}
+{{- range .Servers}}
 
 	server {
-		listen 443 ssl;
+	{{- if $plainHTTP}}
+		listen 80{{if .Default}} default_server{{end}};
+		server_name {{.Domain}};
+	{{- else}}
+		listen 443 ssl{{if $shared}} proxy_protocol{{end}};
+		{{- if $ipv6}}
+		listen [::]:443 ssl{{if $shared}} proxy_protocol{{end}};
+		{{- end}}
+		{{- if $serveHTTP}}
+		listen 80;
+		{{- if $ipv6}}
+		listen [::]:80;
+		{{- end}}
+		{{- end}}
 		http2 on;
-		server_name {{.Project.Domain}};
+		server_name {{.Domain}};
 
-		ssl_certificate /etc/nginx/certs/{{.Project.Domain}}.crt;
-		ssl_certificate_key /etc/nginx/certs/{{.Project.Domain}}.key;
-		ssl_protocols TLSv1.2 TLSv1.3;
+		ssl_certificate /etc/nginx/certs/{{.Domain}}.crt;
+		ssl_certificate_key /etc/nginx/certs/{{.Domain}}.key;
+		ssl_protocols {{$protocols}};
+		{{- if $ciphers}}
+		ssl_ciphers {{$ciphers}};
+		{{- end}}
 		ssl_prefer_server_ciphers on;
 
+		set $ftl_maintenance "off";
+		if (-f {{$maintenanceOn}}) {
+			set $ftl_maintenance "on:$ftl_maintenance_bypass";
+		}
+		if ($ftl_maintenance = "on:0") {
+			return 503;
+		}
+		error_page 503 @ftl_maintenance;
+
+		location @ftl_maintenance {
+			root {{$maintenance}};
+			add_header Cache-Control "no-store" always;
+			try_files {{$maintenancePage}} =503;
+		}
+		{{- if and $serveHTTP $acme}}
+
+		location ^~ /.well-known/acme-challenge/ {
+			resolver 127.0.0.11 valid=1s;
+			set $acme {{$acme}};
+			proxy_pass http://$acme;
+		}
+		{{- end}}
+	{{- end}}
+
         client_body_buffer_size 10M;
         client_max_body_size 10M;
 
@@ -41,6 +289,30 @@ func GenerateNginxConfig(cfg *config.Config) (string, error) {
         proxy_read_timeout 300s;
 {{- range .Services}}
 	{{- $serviceName := .Name }}
+	{{- $drain := gt .DrainTimeout 0 }}
+	{{- if .Static}}
+	{{- $static := .Static }}
+	{{- range .Routes}}
+	{{- $prefix := staticPrefix .PathPrefix }}
+	{{- if ne $prefix .PathPrefix}}
+		location = {{.PathPrefix}} {
+			absolute_redirect off;
+			return 301 {{$prefix}}$is_args$args;
+		}
+	{{- end}}
+		location {{$prefix}} {
+			alias {{$staticRoot}}/{{$serviceName}}/current/;
+			index index.html;
+		{{- if $hsts}}
+			add_header Strict-Transport-Security "{{$hsts}}" always;
+		{{- end}}
+		{{- if $static.HashedAssets}}
+			add_header Cache-Control $static_cache_control;
+		{{- end}}
+		{{- middleware .}}
+		}
+	{{- end}}
+	{{- else}}
 	{{- range .Routes}}
 		location {{.PathPrefix}} {
 		{{- if .StripPrefix}}
@@ -55,17 +327,36 @@ func GenerateNginxConfig(cfg *config.Config) (string, error) {
             proxy_set_header X-Real-IP $remote_addr;
             proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
             proxy_set_header X-Forwarded-Proto $scheme;
+		{{- if $hsts}}
+			add_header Strict-Transport-Security "{{$hsts}}" always;
+		{{- end}}
+		{{- if $drain}}
+			proxy_next_upstream error timeout http_502 http_503;
+		{{- end}}
+		{{- middleware .}}
 		}
 	{{- end}}
+	{{- end}}
 {{- end}}
 	}
+{{- end}}
 `))
 
 	var buffer bytes.Buffer
-	err := tmpl.Execute(&buffer, cfg)
+	err := tmpl.Execute(&buffer, data)
 	if err != nil {
 		return "", err
 	}
 
 	return strings.ReplaceAll(buffer.String(), "\t", "    "), nil
 }
+
+// staticPrefix returns the location prefix of a static route, which ends in a
+// slash: with alias, a prefix such as /docs would also match /docs../secret
+// and serve files outside the release.
+func staticPrefix(prefix string) string {
+	if strings.HasSuffix(prefix, "/") {
+		return prefix
+	}
+	return prefix + "/"
+}
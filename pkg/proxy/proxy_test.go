@@ -1,7 +1,9 @@
 package proxy
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -88,3 +90,511 @@ func (suite *ProxyTestSuite) TestGenerateNginxConfig_EmptyConfig() {
 
 	assert.NoError(suite.T(), err)
 }
+
+func (suite *ProxyTestSuite) TestGenerateNginxConfig_ServiceDomains() {
+	cfg := &config.Config{
+		Project: config.Project{
+			Name:   "test-project",
+			Domain: "example.com",
+			Email:  "test@example.com",
+		},
+		Services: []config.Service{
+			{
+				Name:   "web",
+				Port:   80,
+				Routes: []config.Route{{PathPrefix: "/"}},
+			},
+			{
+				Name:   "api",
+				Port:   8080,
+				Domain: "api.example.com",
+				Routes: []config.Route{{PathPrefix: "/"}},
+			},
+		},
+	}
+
+	nginxConfig, err := GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+
+	assert.Contains(suite.T(), nginxConfig, "server_name example.com;")
+	assert.Contains(suite.T(), nginxConfig, "server_name api.example.com;")
+	assert.Contains(suite.T(), nginxConfig, "ssl_certificate /etc/nginx/certs/api.example.com.crt;")
+
+	apiBlock := nginxConfig[strings.Index(nginxConfig, "server_name api.example.com;"):]
+	assert.Contains(suite.T(), apiBlock, "set $service api;")
+	assert.NotContains(suite.T(), apiBlock, "set $service web;")
+}
+
+func (suite *ProxyTestSuite) TestGenerateNginxConfig_StaticService() {
+	cfg := &config.Config{
+		Project: config.Project{
+			Name:   "test-project",
+			Domain: "example.com",
+			Email:  "test@example.com",
+		},
+		Services: []config.Service{
+			{
+				Name:   "api",
+				Port:   8080,
+				Routes: []config.Route{{PathPrefix: "/api"}},
+			},
+			{
+				Name:   "frontend",
+				Static: &config.Static{Path: "/app/dist"},
+				Routes: []config.Route{{PathPrefix: "/"}},
+			},
+		},
+	}
+
+	nginxConfig, err := GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+
+	assert.Contains(suite.T(), nginxConfig, "upstream api {")
+	assert.NotContains(suite.T(), nginxConfig, "upstream frontend {")
+	assert.Contains(suite.T(), nginxConfig, "alias "+StaticRoot+"/frontend/current/;")
+	assert.NotContains(suite.T(), nginxConfig, "set $service frontend;")
+
+	// A prefix without a trailing slash would let /docs../ escape the alias.
+	cfg.Services[1].Routes = []config.Route{{PathPrefix: "/docs"}}
+	nginxConfig, err = GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+	assert.Contains(suite.T(), nginxConfig, "location = /docs {\n            absolute_redirect off;\n            return 301 /docs/$is_args$args;")
+	assert.Contains(suite.T(), nginxConfig, "location /docs/ {\n            alias "+StaticRoot+"/frontend/current/;")
+	assert.NotContains(suite.T(), nginxConfig, "location /docs {")
+}
+
+func (suite *ProxyTestSuite) TestGenerateNginxConfig_Worker() {
+	cfg := &config.Config{
+		Project: config.Project{
+			Name:   "test-project",
+			Domain: "example.com",
+			Email:  "test@example.com",
+		},
+		Services: []config.Service{
+			{
+				Name:   "api",
+				Port:   8080,
+				Routes: []config.Route{{PathPrefix: "/"}},
+			},
+			{
+				Name: "jobs",
+				Type: config.ServiceWorker,
+			},
+		},
+	}
+
+	nginxConfig, err := GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+
+	assert.Contains(suite.T(), nginxConfig, "upstream api {")
+	assert.NotContains(suite.T(), nginxConfig, "jobs")
+}
+
+func (suite *ProxyTestSuite) TestGenerateNginxConfig_StaticHashedAssets() {
+	cfg := &config.Config{
+		Project: config.Project{
+			Name:   "test-project",
+			Domain: "example.com",
+			Email:  "test@example.com",
+		},
+		Services: []config.Service{
+			{
+				Name:   "frontend",
+				Static: &config.Static{Path: "/app/dist", HashedAssets: true},
+				Routes: []config.Route{{PathPrefix: "/"}},
+			},
+		},
+	}
+
+	nginxConfig, err := GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+
+	assert.Contains(suite.T(), nginxConfig, "map $uri $static_cache_control {")
+	assert.Contains(suite.T(), nginxConfig, `"public, max-age=31536000, immutable";`)
+	assert.Contains(suite.T(), nginxConfig, "add_header Cache-Control $static_cache_control;")
+}
+
+func (suite *ProxyTestSuite) TestGenerateNginxConfig_Middleware() {
+	cfg := &config.Config{
+		Project: config.Project{
+			Name:   "test-project",
+			Domain: "example.com",
+			Email:  "test@example.com",
+		},
+		Services: []config.Service{
+			{
+				Name: "web",
+				Port: 80,
+				Routes: []config.Route{{
+					PathPrefix: "/",
+					Middleware: []config.Middleware{
+						{Headers: &config.HeadersMiddleware{
+							Set:    map[string]string{"X-Frame-Options": "DENY", "Content-Security-Policy": `default-src 'self'; script-src "nonce"`},
+							Remove: []string{"X-Powered-By"},
+						}},
+						{Cache: &config.CacheMiddleware{TTL: config.Duration(10 * time.Minute)}},
+					},
+				}},
+			},
+		},
+	}
+
+	nginxConfig, err := GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+
+	assert.Contains(suite.T(), nginxConfig, "proxy_cache_path /var/cache/nginx/ftl_cache levels=1:2 keys_zone=ftl_cache:10m")
+	assert.Contains(suite.T(), nginxConfig, `add_header Content-Security-Policy "default-src 'self'; script-src \"nonce\"" always;`)
+	assert.Contains(suite.T(), nginxConfig, "proxy_hide_header X-Powered-By;")
+	assert.Contains(suite.T(), nginxConfig, "proxy_cache_valid 200 301 302 600s;")
+
+	headers := strings.Index(nginxConfig, "add_header X-Frame-Options")
+	cache := strings.Index(nginxConfig, "proxy_cache ftl_cache;")
+	assert.True(suite.T(), headers >= 0 && headers < cache, "middleware must render in declaration order")
+}
+
+func (suite *ProxyTestSuite) TestGenerateNginxConfig_DrainTimeout() {
+	cfg := &config.Config{
+		Project: config.Project{
+			Name:   "test-project",
+			Domain: "example.com",
+			Email:  "test@example.com",
+		},
+		Services: []config.Service{
+			{Name: "web", Port: 80, DrainTimeout: config.Duration(30 * time.Second), Routes: []config.Route{{PathPrefix: "/"}}},
+			{Name: "api", Port: 8080, Routes: []config.Route{{PathPrefix: "/api"}}},
+		},
+	}
+
+	nginxConfig, err := GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+
+	assert.Equal(suite.T(), 1, strings.Count(nginxConfig, "proxy_next_upstream error timeout http_502 http_503;"))
+	web := strings.Index(nginxConfig, "set $service web;")
+	api := strings.Index(nginxConfig, "set $service api;")
+	retry := strings.Index(nginxConfig, "proxy_next_upstream")
+	assert.True(suite.T(), web < retry && retry < api)
+}
+
+func (suite *ProxyTestSuite) TestGenerateNginxConfig_AccessControl() {
+	auth := &config.AuthMiddleware{Realm: "Admin", Users: []string{"admin:$apr1$O5ubtN1H$ZBnJ0sWh2Ft0IVy4MH3Ns1"}}
+	cfg := &config.Config{
+		Project: config.Project{
+			Name:   "test-project",
+			Domain: "example.com",
+			Email:  "test@example.com",
+		},
+		Services: []config.Service{
+			{
+				Name: "web",
+				Port: 80,
+				Routes: []config.Route{{
+					PathPrefix: "/admin",
+					Middleware: []config.Middleware{
+						{AllowIPs: []string{"10.0.0.0/8", "203.0.113.7"}},
+						{Auth: auth},
+					},
+				}},
+			},
+		},
+	}
+
+	nginxConfig, err := GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+
+	files := AuthFiles(cfg)
+	suite.Require().Len(files, 1)
+	for name, content := range files {
+		assert.Contains(suite.T(), nginxConfig, "auth_basic_user_file /etc/nginx/conf.d/htpasswd/"+name+";")
+		assert.Equal(suite.T(), "admin:$apr1$O5ubtN1H$ZBnJ0sWh2Ft0IVy4MH3Ns1\n", content)
+	}
+
+	assert.Contains(suite.T(), nginxConfig, "allow 10.0.0.0/8;\n            allow 203.0.113.7;\n            deny all;")
+	assert.Contains(suite.T(), nginxConfig, `auth_basic "Admin";`)
+}
+
+func (suite *ProxyTestSuite) TestGenerateNginxConfig_Metrics() {
+	cfg := &config.Config{
+		Project: config.Project{
+			Name:   "test-project",
+			Domain: "example.com",
+			Email:  "test@example.com",
+		},
+		Services: []config.Service{
+			{Name: "web", Port: 80, Routes: []config.Route{{PathPrefix: "/"}}},
+		},
+		Metrics: &config.Metrics{},
+	}
+
+	nginxConfig, err := GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+
+	assert.Contains(suite.T(), nginxConfig, `log_format ftl_metrics '$remote_addr - $remote_user [$time_local] "$request" $status`)
+	assert.Contains(suite.T(), nginxConfig, "access_log syslog:server=metrics:5531,tag=nginx,nohostname ftl_metrics;")
+	assert.Contains(suite.T(), nginxConfig, "listen 9113;")
+	assert.Contains(suite.T(), nginxConfig, "proxy_pass http://$metrics_exporter:4040/metrics;")
+	assert.Contains(suite.T(), nginxConfig, "alias /var/lib/ftl/metrics/agent.prom;")
+	assert.Contains(suite.T(), MetricsExporterConfig(), "format: '"+metricsLogFormat+"'")
+
+	devConfig, err := GenerateDevNginxConfig(cfg)
+	suite.Require().NoError(err)
+	assert.NotContains(suite.T(), devConfig, "ftl_metrics")
+}
+
+func (suite *ProxyTestSuite) TestGenerateNginxConfig_TLSPolicy() {
+	redirect := false
+	cfg := &config.Config{
+		Project: config.Project{Name: "test-project", Domain: "example.com", Email: "test@example.com"},
+		Services: []config.Service{
+			{Name: "web", Port: 80, Routes: []config.Route{{PathPrefix: "/"}}},
+		},
+		TLS: &config.TLS{
+			Redirect:   &redirect,
+			MinVersion: "1.3",
+			Ciphers:    []string{"ECDHE-ECDSA-AES128-GCM-SHA256", "ECDHE-RSA-AES128-GCM-SHA256"},
+			HSTS:       &config.HSTS{MaxAge: config.Duration(365 * 24 * time.Hour), IncludeSubdomains: true},
+		},
+	}
+
+	result, err := GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+	suite.Contains(result, "listen 443 ssl;\n        listen 80;")
+	suite.Contains(result, "ssl_protocols TLSv1.3;")
+	suite.Contains(result, "ssl_ciphers ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256;")
+
+	cfg.TLS.Ciphers = []string{"ECDHE+AESGCM", "CHACHA20"}
+	result, err = GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+	suite.Contains(result, "ssl_ciphers ECDHE+AESGCM:CHACHA20;")
+	suite.Contains(result, "location ^~ /.well-known/acme-challenge/")
+	suite.Contains(result, "set $acme zero;")
+	suite.Contains(result, `add_header Strict-Transport-Security "max-age=31536000; includeSubDomains" always;`)
+
+	result, err = GenerateNginxConfig(&config.Config{
+		Project:  cfg.Project,
+		Services: cfg.Services,
+	})
+	suite.Require().NoError(err)
+	suite.Contains(result, "ssl_protocols TLSv1.2 TLSv1.3;")
+	suite.NotContains(result, "listen 80;")
+	suite.NotContains(result, "ssl_ciphers")
+	suite.NotContains(result, "Strict-Transport-Security")
+
+	result, err = GenerateDevNginxConfig(cfg)
+	suite.Require().NoError(err)
+	suite.NotContains(result, "Strict-Transport-Security")
+}
+
+func (suite *ProxyTestSuite) TestGenerateNginxConfig_SelfSigned() {
+	cfg := &config.Config{
+		Project: config.Project{Name: "test-project", Domain: "example.com", Email: "test@example.com"},
+		Services: []config.Service{
+			{Name: "web", Port: 80, Routes: []config.Route{{PathPrefix: "/"}}},
+		},
+		TLS: &config.TLS{Mode: config.TLSSelfSigned},
+	}
+
+	result, err := GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+	suite.Contains(result, "listen 80 default_server;\n        return 301 https://$host$request_uri;", "without a certificate manager the proxy redirects")
+	suite.NotContains(result, "acme-challenge")
+
+	redirect := false
+	cfg.TLS.Redirect = &redirect
+	result, err = GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+	suite.NotContains(result, "return 301")
+	suite.Contains(result, "listen 443 ssl;\n        listen 80;")
+	suite.NotContains(result, "acme-challenge")
+}
+
+func (suite *ProxyTestSuite) TestGenerateNginxConfig_Shared() {
+	cfg := &config.Config{
+		Project: config.Project{Name: "test-project", Domain: "example.com", Email: "test@example.com"},
+		Server:  &config.Server{Host: "example.com", Shared: true},
+		Services: []config.Service{
+			{Name: "web", Port: 80, Routes: []config.Route{{PathPrefix: "/"}}},
+		},
+	}
+
+	result, err := GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+	suite.Contains(result, "real_ip_header proxy_protocol;")
+	suite.Contains(result, "listen 443 ssl proxy_protocol;")
+	suite.Contains(result, "listen 80 default_server;\n\n        location ^~ /.well-known/acme-challenge/ {", "the proxy answers the challenges of the certificate manager")
+	suite.Contains(result, "location / {\n            return 301 https://$host$request_uri;")
+
+	cfg.TLS = &config.TLS{Mode: config.TLSSelfSigned}
+	result, err = GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+	suite.Contains(result, "listen 80 default_server;\n        return 301 https://$host$request_uri;")
+}
+
+func (suite *ProxyTestSuite) TestGenerateEdgeConfig() {
+	http, stream := GenerateEdgeConfig(map[string][]string{
+		"shop-proxy": {"shop.example.com"},
+		"blog-proxy": {"blog.example.com", "www.blog.example.com"},
+	}, false)
+
+	suite.Contains(http, "    blog.example.com blog-proxy;\n    shop.example.com shop-proxy;\n    www.blog.example.com blog-proxy;\n}")
+	suite.Contains(http, "proxy_pass http://$ftl_edge_upstream;")
+	suite.Contains(stream, "        blog.example.com blog-proxy:443;\n")
+	suite.Contains(stream, "ssl_preread on;")
+	suite.Contains(stream, "proxy_protocol on;")
+	suite.NotContains(http+stream, "[::]")
+
+	http, stream = GenerateEdgeConfig(map[string][]string{"shop-proxy": {"shop.example.com"}}, true)
+	suite.Contains(http, "    listen 80 default_server;\n    listen [::]:80 default_server;\n")
+	suite.Contains(stream, "        listen 443;\n        listen [::]:443;\n")
+}
+
+func (suite *ProxyTestSuite) TestGenerateNginxConfig_IPv6() {
+	cfg := &config.Config{
+		Project:  config.Project{Name: "test-project", Domain: "example.com", Email: "test@example.com"},
+		Server:   &config.Server{IPv6: true},
+		TLS:      &config.TLS{Mode: config.TLSSelfSigned},
+		Services: []config.Service{{Name: "web", Port: 80, Routes: []config.Route{{PathPrefix: "/"}}}},
+	}
+
+	result, err := GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+	suite.Contains(result, "listen 80 default_server;\n        listen [::]:80 default_server;")
+	suite.Contains(result, "listen 443 ssl;\n        listen [::]:443 ssl;")
+
+	result, err = GenerateDevNginxConfig(cfg)
+	suite.Require().NoError(err)
+	suite.NotContains(result, "[::]")
+
+	cfg.Server.IPv6 = false
+	result, err = GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+	suite.NotContains(result, "[::]")
+}
+
+func (suite *ProxyTestSuite) TestGenerateNginxConfig_CORS() {
+	cfg := &config.Config{
+		Project: config.Project{Name: "test-project", Domain: "example.com", Email: "test@example.com"},
+		Services: []config.Service{
+			{Name: "api", Port: 8080, Routes: []config.Route{{
+				PathPrefix: "/api",
+				Middleware: []config.Middleware{{CORS: &config.CORSMiddleware{
+					AllowOrigins:     []string{"https://app.example.com", "http://localhost:3000"},
+					AllowHeaders:     []string{"Authorization", "Content-Type"},
+					ExposeHeaders:    []string{"X-Request-Id"},
+					AllowCredentials: true,
+					MaxAge:           config.Duration(10 * time.Minute),
+				}}},
+			}}},
+			{Name: "public", Port: 8081, Routes: []config.Route{{
+				PathPrefix: "/public",
+				Middleware: []config.Middleware{{CORS: &config.CORSMiddleware{AllowOrigins: []string{"*"}}}},
+			}}},
+		},
+	}
+
+	nginxConfig, err := GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+
+	suite.Contains(nginxConfig, `if ($http_origin ~ "^(https://app\\.example\\.com|http://localhost:3000)$") { set $cors_origin $http_origin; }`)
+	suite.Contains(nginxConfig, `if ($cors_preflight ~ "^OPTIONS:.") { add_header Access-Control-Allow-Origin $cors_origin always; add_header Vary Origin always; add_header Access-Control-Allow-Credentials $cors_credentials always; add_header Access-Control-Allow-Methods "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS" always; add_header Access-Control-Allow-Headers "Authorization, Content-Type" always; add_header Access-Control-Max-Age 600 always; return 204; }`)
+	suite.Contains(nginxConfig, `add_header Access-Control-Expose-Headers "X-Request-Id" always;`)
+
+	suite.Contains(nginxConfig, `if ($http_origin != "") { set $cors_origin "*"; }`)
+	suite.Contains(nginxConfig, "add_header Access-Control-Allow-Headers $http_access_control_request_headers always;")
+}
+
+func (suite *ProxyTestSuite) TestGenerateNginxConfig_RateLimit() {
+	login := config.Middleware{RateLimit: &config.RateLimitMiddleware{Rate: "5r/m", Burst: 3}}
+	cfg := &config.Config{
+		Project: config.Project{Name: "test-project", Domain: "example.com", Email: "test@example.com"},
+		Services: []config.Service{
+			{Name: "web", Port: 80, Routes: []config.Route{
+				{PathPrefix: "/login", Middleware: []config.Middleware{login}},
+				{PathPrefix: "/signup", Middleware: []config.Middleware{login}},
+			}},
+			{Name: "api", Port: 8080, Routes: []config.Route{{
+				PathPrefix: "/api",
+				Middleware: []config.Middleware{{RateLimit: &config.RateLimitMiddleware{Rate: "10r/s", Header: "X-Api-Key"}}},
+			}}},
+		},
+	}
+
+	nginxConfig, err := GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+
+	loginZone, _ := rateLimitZone(login.RateLimit)
+	apiZone, _ := rateLimitZone(cfg.Services[1].Routes[0].Middleware[0].RateLimit)
+	suite.NotEqual(loginZone, apiZone)
+
+	suite.Equal(1, strings.Count(nginxConfig, "limit_req_zone $binary_remote_addr zone="+loginZone+":10m rate=5r/m;"))
+	suite.Contains(nginxConfig, "limit_req_zone $http_x_api_key zone="+apiZone+":10m rate=10r/s;")
+	suite.Equal(2, strings.Count(nginxConfig, "limit_req zone="+loginZone+" burst=3 nodelay;"))
+	suite.Contains(nginxConfig, "limit_req zone="+apiZone+" burst=0 nodelay;")
+	suite.Contains(nginxConfig, "limit_req_status 429;")
+}
+
+func (suite *ProxyTestSuite) TestGenerateNginxConfig_Maintenance() {
+	cfg := &config.Config{
+		Project:     config.Project{Name: "test-project", Domain: "example.com", Email: "test@example.com"},
+		Services:    []config.Service{{Name: "web", Port: 80, Routes: []config.Route{{PathPrefix: "/"}}}},
+		Maintenance: &config.Maintenance{AllowIPs: []string{"203.0.113.7", "10.0.0.0/8"}},
+	}
+
+	nginxConfig, err := GenerateNginxConfig(cfg)
+	suite.Require().NoError(err)
+
+	suite.Contains(nginxConfig, "geo $ftl_maintenance_bypass {\n        default 0;\n        203.0.113.7 1;\n        10.0.0.0/8 1;\n    }")
+	suite.Contains(nginxConfig, "if (-f /etc/nginx/conf.d/maintenance/on) {")
+	suite.Contains(nginxConfig, `if ($ftl_maintenance = "on:0") {`)
+	suite.Contains(nginxConfig, "error_page 503 @ftl_maintenance;")
+	suite.Contains(nginxConfig, "try_files /index.html =503;")
+
+	nginxConfig, err = GenerateDevNginxConfig(cfg)
+	suite.Require().NoError(err)
+	suite.NotContains(nginxConfig, "ftl_maintenance")
+}
+
+func (suite *ProxyTestSuite) TestGenerateStreamConfig() {
+	cfg := &config.Config{
+		Project: config.Project{Name: "test-project", Domain: "example.com", Email: "test@example.com"},
+		TLS:     &config.TLS{MinVersion: "1.3"},
+		Services: []config.Service{
+			{Name: "mqtt", Port: 1883, Domain: "mqtt.example.com", Streams: []config.Stream{{Port: 8883, Target: 1883, TLS: "terminate"}}},
+			{Name: "game", Port: 27015, Streams: []config.Stream{{Port: 27015, Protocol: "udp"}}},
+			{Name: "broker", Port: 5671, Domain: "broker.example.com", Streams: []config.Stream{{Port: 5671, TLS: "passthrough"}}},
+			{Name: "queue", Port: 5671, Domain: "queue.example.com", Streams: []config.Stream{{Port: 5671, TLS: "passthrough"}}},
+		},
+	}
+	suite.True(HasStreams(cfg))
+	suite.False(HasStreams(&config.Config{Services: []config.Service{{Name: "web"}}}))
+
+	suite.Equal(`stream {
+    resolver 127.0.0.11 valid=1s;
+
+    server {
+        listen 8883 ssl;
+        ssl_certificate /etc/nginx/certs/mqtt.example.com.crt;
+        ssl_certificate_key /etc/nginx/certs/mqtt.example.com.key;
+        ssl_protocols TLSv1.3;
+        set $ftl_stream_8883_tcp mqtt:1883;
+        proxy_pass $ftl_stream_8883_tcp;
+    }
+
+    server {
+        listen 27015 udp;
+        set $ftl_stream_27015_udp game:27015;
+        proxy_pass $ftl_stream_27015_udp;
+    }
+
+    map $ssl_preread_server_name $ftl_stream_5671_tcp {
+        broker.example.com broker:5671;
+        queue.example.com queue:5671;
+    }
+
+    server {
+        listen 5671;
+        ssl_preread on;
+        proxy_pass $ftl_stream_5671_tcp;
+    }
+}
+`, GenerateStreamConfig(cfg))
+}
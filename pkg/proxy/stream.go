@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/yarlson/ftl/pkg/config"
+)
+
+// StreamConfig is the file, relative to the nginx configuration directory,
+// that holds the stream block. Files in that directory are included in the
+// http context, so the proxy includes this one in the main context itself.
+const StreamConfig = "stream.inc"
+
+// StreamConfigPath is where the stream configuration is mounted in the proxy
+// container.
+const StreamConfigPath = "/etc/nginx/conf.d/" + StreamConfig
+
+// streamRoute is a service stream together with the domain it serves.
+type streamRoute struct {
+	service string
+	domain  string
+	stream  config.Stream
+}
+
+// HasStreams reports whether any service of cfg declares a stream.
+func HasStreams(cfg *config.Config) bool {
+	for _, svc := range cfg.Services {
+		if len(svc.Streams) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateStreamConfig generates the nginx stream block that forwards the
+// proxy ports of service streams to their containers, one server per port.
+func GenerateStreamConfig(cfg *config.Config) string {
+	var listeners []string
+	routes := make(map[string][]streamRoute)
+	for i := range cfg.Services {
+		svc := &cfg.Services[i]
+		for _, stream := range svc.Streams {
+			listener := stream.Listener()
+			if _, ok := routes[listener]; !ok {
+				listeners = append(listeners, listener)
+			}
+			routes[listener] = append(routes[listener], streamRoute{service: svc.Name, domain: cfg.ServiceDomain(svc), stream: stream})
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("stream {\n")
+	b.WriteString("    resolver 127.0.0.11 valid=1s;\n")
+	for _, listener := range listeners {
+		b.WriteString("\n")
+		renderStreamServer(&b, cfg.TLS, cfg.Server != nil && cfg.Server.IPv6, routes[listener])
+	}
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+func renderStreamServer(b *strings.Builder, tls *config.TLS, ipv6 bool, routes []streamRoute) {
+	first := routes[0].stream
+	variable := "$ftl_stream_" + strconv.Itoa(first.Port) + "_" + first.Network()
+
+	// Passthrough streams sharing a port are routed by the server name the
+	// client sends in its TLS handshake.
+	if len(routes) > 1 {
+		fmt.Fprintf(b, "    map $ssl_preread_server_name %s {\n", variable)
+		for _, route := range routes {
+			fmt.Fprintf(b, "        %s %s:%d;\n", route.domain, route.service, route.stream.TargetPort())
+		}
+		b.WriteString("    }\n\n")
+	}
+
+	b.WriteString("    server {\n")
+	switch {
+	case first.Network() == "udp":
+		fmt.Fprintf(b, "        listen %d udp;\n", first.Port)
+		b.WriteString(listenIPv6(ipv6, "        ", fmt.Sprintf("%d udp", first.Port)))
+	case first.TLS == "terminate":
+		fmt.Fprintf(b, "        listen %d ssl;\n", first.Port)
+		b.WriteString(listenIPv6(ipv6, "        ", fmt.Sprintf("%d ssl", first.Port)))
+		fmt.Fprintf(b, "        ssl_certificate /etc/nginx/certs/%s.crt;\n", routes[0].domain)
+		fmt.Fprintf(b, "        ssl_certificate_key /etc/nginx/certs/%s.key;\n", routes[0].domain)
+		fmt.Fprintf(b, "        ssl_protocols %s;\n", tls.Protocols())
+		if tls != nil && len(tls.Ciphers) > 0 {
+			fmt.Fprintf(b, "        ssl_ciphers %s;\n", strings.Join(tls.Ciphers, ":"))
+		}
+	default:
+		fmt.Fprintf(b, "        listen %d;\n", first.Port)
+		b.WriteString(listenIPv6(ipv6, "        ", strconv.Itoa(first.Port)))
+	}
+	if len(routes) > 1 {
+		b.WriteString("        ssl_preread on;\n")
+	} else {
+		fmt.Fprintf(b, "        set %s %s:%d;\n", variable, routes[0].service, first.TargetPort())
+	}
+	fmt.Fprintf(b, "        proxy_pass %s;\n", variable)
+	b.WriteString("    }\n")
+}
@@ -0,0 +1,84 @@
+// Package retry retries operations that fail for transient reasons, such as
+// a dropped connection to the server or to a registry, with exponential
+// backoff.
+package retry
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// maxBackoff caps the wait between two attempts.
+const maxBackoff = 30 * time.Second
+
+// Policy is how a failed operation is retried: up to Retries more times,
+// waiting Backoff before the first retry and twice as long before each next
+// one.
+type Policy struct {
+	Retries int
+	Backoff time.Duration
+}
+
+// Default is the policy remote operations are retried with unless the
+// configuration sets another.
+var Default = Policy{Retries: 2, Backoff: time.Second}
+
+// Attempts returns how many times an operation runs at most.
+func (p Policy) Attempts() int {
+	return p.Retries + 1
+}
+
+// Delay returns the wait before the given retry, counting from 1.
+func (p Policy) Delay(retry int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < retry && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+// Do runs op until it succeeds, returns an error marked with Permanent, the
+// retries are used up or ctx is done, and returns the last error of op.
+func (p Policy) Do(ctx context.Context, op func() error) error {
+	for retry := 1; ; retry++ {
+		err := op()
+		if err == nil {
+			return nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if retry > p.Retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(p.Delay(retry)):
+		}
+	}
+}
+
+// Permanent marks err as not worth retrying, so Do returns it at once.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,63 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	policy := Policy{Retries: 2, Backoff: time.Millisecond}
+
+	calls := 0
+	err := policy.Do(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = policy.Do(context.Background(), func() error {
+		calls++
+		return errors.New("connection reset")
+	})
+	assert.EqualError(t, err, "connection reset")
+	assert.Equal(t, policy.Attempts(), calls, "the retries are used up")
+
+	calls = 0
+	unauthorized := errors.New("unauthorized")
+	err = policy.Do(context.Background(), func() error {
+		calls++
+		return Permanent(unauthorized)
+	})
+	assert.Same(t, unauthorized, err)
+	assert.Equal(t, 1, calls, "a permanent error is not retried")
+}
+
+func TestDo_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := Policy{Retries: 5, Backoff: time.Hour}.Do(ctx, func() error {
+		calls++
+		return errors.New("timeout")
+	})
+	assert.EqualError(t, err, "timeout")
+	assert.Equal(t, 1, calls)
+}
+
+func TestDelay(t *testing.T) {
+	policy := Policy{Backoff: time.Second}
+	assert.Equal(t, time.Second, policy.Delay(1))
+	assert.Equal(t, 2*time.Second, policy.Delay(2))
+	assert.Equal(t, 8*time.Second, policy.Delay(4))
+	assert.Equal(t, maxBackoff, policy.Delay(10))
+}
@@ -0,0 +1,215 @@
+// Package dryrun provides a Runner that records what a deployment would do on
+// the server instead of doing it, so the plan can be reviewed before it runs.
+//
+// Nothing is sent to the server. Commands succeed with empty output unless a
+// response is scripted with On, so code that inspects the server sees one on
+// which nothing is deployed yet:
+//
+//	runner := dryrun.NewRunner("example.com")
+//	runner.On("docker inspect --format={{.State.Health.Status}}", "healthy")
+//
+//	// ... deploy with runner ...
+//
+//	for _, step := range runner.Steps() {
+//		fmt.Println(step)
+//	}
+package dryrun
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+// StepKind is what a Step does on the server.
+type StepKind string
+
+const (
+	// StepCommand runs a command.
+	StepCommand StepKind = "command"
+	// StepFile copies a file.
+	StepFile StepKind = "file"
+	// StepImage transfers a locally built image.
+	StepImage StepKind = "image"
+)
+
+// Step is an action the deployment would take on the server.
+type Step struct {
+	Kind StepKind `json:"kind"`
+	// Command is the command line, with the arguments that need it quoted
+	// for the remote shell.
+	Command string `json:"command,omitempty"`
+	// Path and Content are the destination and content of a copied file.
+	Path    string `json:"path,omitempty"`
+	Content string `json:"content,omitempty"`
+	// Image is the image transferred to the server.
+	Image string `json:"image,omitempty"`
+}
+
+// String returns the step in the form ftl deploy --dry-run prints it.
+func (s Step) String() string {
+	switch s.Kind {
+	case StepFile:
+		content := s.Content
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		return fmt.Sprintf("# write %s\n%s# end of %s", s.Path, content, s.Path)
+	case StepImage:
+		return "# transfer image " + s.Image
+	default:
+		return "$ " + s.Command
+	}
+}
+
+type rule struct {
+	prefix string
+	output string
+}
+
+// Runner records every command, file copy and image transfer. It is safe for
+// concurrent use.
+type Runner struct {
+	mu    sync.Mutex
+	host  string
+	env   []string
+	rules []rule
+	steps []Step
+}
+
+// NewRunner creates a Runner standing in for host.
+func NewRunner(host string) *Runner {
+	return &Runner{host: host}
+}
+
+// On scripts the output of commands whose command line, with arguments
+// separated by spaces and not quoted, starts with prefix on a word boundary.
+// When several prefixes match, the one scripted last wins.
+func (r *Runner) On(prefix, output string) *Runner {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rules = append(r.rules, rule{prefix: prefix, output: output})
+	return r
+}
+
+// SetEnv exports an environment variable to every command recorded
+// afterwards, as the remote runner does.
+func (r *Runner) SetEnv(name, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.env = append(r.env, shell.Export(name, value))
+}
+
+// Steps returns the steps recorded so far, in order.
+func (r *Runner) Steps() []Step {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Step(nil), r.steps...)
+}
+
+func (r *Runner) RunCommand(ctx context.Context, command string, args ...string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(r.run(command, args))), nil
+}
+
+// RunCommandWithOutput writes the scripted output of the command to w.
+func (r *Runner) RunCommandWithOutput(ctx context.Context, w io.Writer, command string, args ...string) error {
+	_, err := io.WriteString(w, r.run(command, args))
+	return err
+}
+
+// RunCommands records commands in order.
+func (r *Runner) RunCommands(ctx context.Context, commands []string) error {
+	for _, line := range commands {
+		r.run(line, nil)
+	}
+	return nil
+}
+
+// CopyFile reads src from the local file system and records its content as
+// written to dst.
+func (r *Runner) CopyFile(ctx context.Context, src, dst string) error {
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read source file: %w", err)
+	}
+
+	r.record(Step{Kind: StepFile, Path: dst, Content: string(content)})
+	return nil
+}
+
+// Sync records the transfer of a locally built image, so the Runner can also
+// stand in for the image syncer. It reports the image as updated.
+func (r *Runner) Sync(ctx context.Context, image string) (bool, error) {
+	r.record(Step{Kind: StepImage, Image: image})
+	return true, nil
+}
+
+// CompareImages reports the image as different from the one on the server.
+func (r *Runner) CompareImages(ctx context.Context, image string) (bool, error) {
+	return true, nil
+}
+
+func (r *Runner) Host() string {
+	return r.host
+}
+
+// Close does nothing; it lets the Runner replace a remote runner.
+func (r *Runner) Close() error {
+	return nil
+}
+
+// run records the command and returns its scripted output.
+func (r *Runner) run(command string, args []string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.steps = append(r.steps, Step{
+		Kind:    StepCommand,
+		Command: strings.Join(r.env, "") + commandLine(command, args),
+	})
+
+	line := strings.Join(append([]string{command}, args...), " ")
+	for i := len(r.rules) - 1; i >= 0; i-- {
+		prefix := r.rules[i].prefix
+		if line == prefix || strings.HasPrefix(line, prefix+" ") {
+			return r.rules[i].output
+		}
+	}
+	return ""
+}
+
+// commandLine joins command and args like shell.Join, leaving the arguments
+// that a shell reads as a single word unquoted so the line stays readable.
+func commandLine(command string, args []string) string {
+	words := []string{command}
+	for _, arg := range args {
+		if arg == "" || strings.ContainsFunc(arg, needsQuote) {
+			arg = shell.Quote(arg)
+		}
+		words = append(words, arg)
+	}
+	return strings.Join(words, " ")
+}
+
+func needsQuote(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	}
+	return !strings.ContainsRune("-_./:=,@%+^", r)
+}
+
+func (r *Runner) record(step Step) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.steps = append(r.steps, step)
+}
@@ -0,0 +1,57 @@
+package dryrun
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/deployment"
+	"github.com/yarlson/ftl/pkg/docker"
+)
+
+var (
+	_ deployment.Runner      = (*Runner)(nil)
+	_ deployment.ImageSyncer = (*Runner)(nil)
+	_ docker.CommandRunner   = (*Runner)(nil)
+)
+
+func TestRunner(t *testing.T) {
+	runner := NewRunner("example.com")
+	runner.SetEnv("DOCKER_HOST", "unix:///run/user/1000/docker.sock")
+	runner.On("sh -c echo $HOME", "/home/deploy")
+
+	output, err := runner.RunCommand(context.Background(), "sh", "-c", "echo $HOME")
+	require.NoError(t, err)
+	data, err := io.ReadAll(output)
+	require.NoError(t, err)
+	assert.Equal(t, "/home/deploy", string(data))
+
+	output, err = runner.RunCommand(context.Background(), "docker", "run", "--name", "app-web", "--health-cmd", "curl -sf http://localhost/", "")
+	require.NoError(t, err)
+	data, _ = io.ReadAll(output)
+	assert.Empty(t, data)
+
+	src := filepath.Join(t.TempDir(), "default.conf")
+	require.NoError(t, os.WriteFile(src, []byte("server {}"), 0o644))
+	require.NoError(t, runner.CopyFile(context.Background(), src, "/home/deploy/projects/app/nginx/default.conf"))
+
+	updated, err := runner.Sync(context.Background(), "app-api")
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	export := "export DOCKER_HOST='unix:///run/user/1000/docker.sock'; "
+	assert.Equal(t, []Step{
+		{Kind: StepCommand, Command: export + "sh -c 'echo $HOME'"},
+		{Kind: StepCommand, Command: export + "docker run --name app-web --health-cmd 'curl -sf http://localhost/' ''"},
+		{Kind: StepFile, Path: "/home/deploy/projects/app/nginx/default.conf", Content: "server {}"},
+		{Kind: StepImage, Image: "app-api"},
+	}, runner.Steps())
+
+	assert.Equal(t, "$ docker network create app", Step{Kind: StepCommand, Command: "docker network create app"}.String())
+	assert.Equal(t, "# write /etc/app.conf\nserver {}\n# end of /etc/app.conf", Step{Kind: StepFile, Path: "/etc/app.conf", Content: "server {}"}.String())
+}
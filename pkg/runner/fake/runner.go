@@ -0,0 +1,214 @@
+// Package fake provides an in-memory Runner for testing code built on
+// pkg/build, pkg/deployment and pkg/docker without Docker or a server.
+//
+// Responses are scripted per command line and every command is recorded:
+//
+//	runner := fake.NewRunner()
+//	runner.On("docker inspect", fake.Response{Output: "[]"})
+//	runner.On("docker pull", fake.Response{Err: errors.New("no such image")})
+//	runner.On("docker exec", fake.Response{Output: "permission denied", ExitCode: 1})
+//
+//	// ... exercise the code under test ...
+//
+//	for _, call := range runner.Calls() {
+//		fmt.Println(call)
+//	}
+package fake
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Response is the scripted result of a command.
+type Response struct {
+	// Output is returned as the command's combined output.
+	Output string
+	// Err, when set, is returned instead of the output, as if the command
+	// failed to run.
+	Err error
+	// ExitCode, when not zero, is the exit status the command ends with. As
+	// on a server, the output of RunCommand reads and closes without error;
+	// RunCommandWithOutput returns an *ExitError.
+	ExitCode int
+}
+
+// ExitError is returned by RunCommandWithOutput for commands scripted with a
+// non-zero ExitCode.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("command failed: exit status %d", e.Code)
+}
+
+// Call is a command run through the Runner.
+type Call struct {
+	Command string
+	Args    []string
+}
+
+// String returns the command line of the call, with arguments separated by
+// spaces and not quoted.
+func (c Call) String() string {
+	return strings.Join(append([]string{c.Command}, c.Args...), " ")
+}
+
+type rule struct {
+	prefix   string
+	response Response
+}
+
+// Runner records every command and file copy and answers commands with the
+// scripted responses. It is safe for concurrent use.
+type Runner struct {
+	mu       sync.Mutex
+	host     string
+	rules    []rule
+	fallback Response
+	calls    []Call
+	files    map[string][]byte
+}
+
+// NewRunner creates a Runner that answers every command with empty output
+// until responses are scripted with On.
+func NewRunner() *Runner {
+	return &Runner{
+		host:  "localhost",
+		files: map[string][]byte{},
+	}
+}
+
+// On scripts the response to commands whose command line starts with prefix,
+// matched on whole words. When several prefixes match, the one scripted last
+// wins, so a test can override a response set up earlier.
+func (r *Runner) On(prefix string, response Response) *Runner {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rules = append(r.rules, rule{prefix: prefix, response: response})
+	return r
+}
+
+// Default sets the response to commands no scripted prefix matches.
+func (r *Runner) Default(response Response) *Runner {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.fallback = response
+	return r
+}
+
+// SetHost sets the host returned by Host.
+func (r *Runner) SetHost(host string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.host = host
+}
+
+// Calls returns the commands run so far, in order.
+func (r *Runner) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Call(nil), r.calls...)
+}
+
+// File returns the content of the file copied to dst with CopyFile.
+func (r *Runner) File(dst string) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	content, ok := r.files[dst]
+	return content, ok
+}
+
+// Reset forgets the recorded calls and copied files, keeping the scripted
+// responses.
+func (r *Runner) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = nil
+	r.files = map[string][]byte{}
+}
+
+func (r *Runner) RunCommand(ctx context.Context, command string, args ...string) (io.ReadCloser, error) {
+	response := r.run(Call{Command: command, Args: args})
+	if response.Err != nil {
+		return nil, response.Err
+	}
+	return io.NopCloser(strings.NewReader(response.Output)), nil
+}
+
+// RunCommandWithOutput writes the scripted output of the command to w.
+func (r *Runner) RunCommandWithOutput(ctx context.Context, w io.Writer, command string, args ...string) error {
+	response := r.run(Call{Command: command, Args: args})
+	if _, err := io.WriteString(w, response.Output); err != nil {
+		return err
+	}
+	if response.Err == nil && response.ExitCode != 0 {
+		return &ExitError{Code: response.ExitCode}
+	}
+	return response.Err
+}
+
+// RunCommands runs commands in order, split into words like a shell without
+// quoting would. It stops at the first command that fails.
+func (r *Runner) RunCommands(ctx context.Context, commands []string) error {
+	for _, line := range commands {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if response := r.run(Call{Command: fields[0], Args: fields[1:]}); response.Err != nil {
+			return fmt.Errorf("command %q failed: %w", line, response.Err)
+		}
+	}
+	return nil
+}
+
+// CopyFile reads src from the local file system and keeps its content in
+// memory under dst.
+func (r *Runner) CopyFile(ctx context.Context, src, dst string) error {
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read source file: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.files[dst] = content
+	return nil
+}
+
+func (r *Runner) Host() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.host
+}
+
+// run records call and returns its scripted response.
+func (r *Runner) run(call Call) Response {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, call)
+
+	line := call.String()
+	for i := len(r.rules) - 1; i >= 0; i-- {
+		prefix := r.rules[i].prefix
+		if line == prefix || strings.HasPrefix(line, prefix+" ") {
+			return r.rules[i].response
+		}
+	}
+	return r.fallback
+}
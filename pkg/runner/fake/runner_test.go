@@ -0,0 +1,92 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/build"
+	"github.com/yarlson/ftl/pkg/deployment"
+	"github.com/yarlson/ftl/pkg/docker"
+	runnerpkg "github.com/yarlson/ftl/pkg/runner"
+)
+
+var (
+	_ build.Runner         = (*Runner)(nil)
+	_ deployment.Runner    = (*Runner)(nil)
+	_ docker.CommandRunner = (*Runner)(nil)
+)
+
+func TestRunner(t *testing.T) {
+	runner := NewRunner()
+	runner.On("docker", Response{Output: "any docker command"})
+	runner.On("docker images", Response{Output: "abc123"})
+	runner.On("docker pull", Response{Err: errors.New("pull failed")})
+
+	output, err := runner.RunCommand(context.Background(), "docker", "images", "-q")
+	require.NoError(t, err)
+	data, err := io.ReadAll(output)
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", string(data))
+
+	output, err = runner.RunCommand(context.Background(), "docker", "imagesx")
+	require.NoError(t, err)
+	data, _ = io.ReadAll(output)
+	assert.Equal(t, "any docker command", string(data))
+
+	_, err = runner.RunCommand(context.Background(), "docker", "pull", "nginx")
+	assert.EqualError(t, err, "pull failed")
+
+	assert.ErrorContains(t, runner.RunCommands(context.Background(), []string{"echo hi", "docker pull nginx", "echo never"}), "pull failed")
+
+	var calls []string
+	for _, call := range runner.Calls() {
+		calls = append(calls, call.String())
+	}
+	assert.Equal(t, []string{"docker images -q", "docker imagesx", "docker pull nginx", "echo hi", "docker pull nginx"}, calls)
+
+	src := filepath.Join(t.TempDir(), "default.conf")
+	require.NoError(t, os.WriteFile(src, []byte("server {}"), 0644))
+	require.NoError(t, runner.CopyFile(context.Background(), src, "/etc/nginx/conf.d/default.conf"))
+	content, ok := runner.File("/etc/nginx/conf.d/default.conf")
+	assert.True(t, ok)
+	assert.Equal(t, "server {}", string(content))
+}
+
+func TestRunner_Build(t *testing.T) {
+	runner := NewRunner()
+	runner.On("docker build", Response{Output: "#1 DONE\n", Err: errors.New("exit status 1")})
+
+	var lines []string
+	err := build.NewBuild(runner).Build(context.Background(), "app:latest", ".", build.Options{}, func(line string) {
+		lines = append(lines, line)
+	})
+
+	assert.ErrorContains(t, err, "failed to build image")
+	assert.Equal(t, []string{"#1 DONE"}, lines)
+	assert.Equal(t, "docker", runner.Calls()[0].Command)
+	assert.Contains(t, runner.Calls()[0].Args, "app:latest")
+}
+
+func TestRunner_ExitCode(t *testing.T) {
+	runner := NewRunner()
+	runner.On("nginx -t", Response{Output: "emerg: unknown directive", ExitCode: 1})
+
+	// Like a remote command, the output does not report the exit status.
+	output, err := runner.RunCommand(context.Background(), "nginx", "-t")
+	require.NoError(t, err)
+	_, _ = io.ReadAll(output)
+	assert.NoError(t, output.Close())
+
+	data, err := runnerpkg.RunChecked(context.Background(), runner, "nginx", "-t")
+	var exitErr *ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 1, exitErr.Code)
+	assert.Equal(t, "emerg: unknown directive", string(data))
+}
@@ -24,6 +24,18 @@ func (e *Runner) RunCommand(ctx context.Context, command string, args ...string)
 	return io.NopCloser(bytes.NewReader(output)), nil
 }
 
+// RunCommandWithOutput runs a command and writes its combined output to w
+// while it runs, instead of buffering it until the command exits.
+func (e *Runner) RunCommandWithOutput(ctx context.Context, w io.Writer, command string, args ...string) error {
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("command execution failed: %w", err)
+	}
+	return nil
+}
+
 func (e *Runner) RunCommands(ctx context.Context, commands []string) error {
 	operations := make([]func() error, len(commands))
 	for i, cmdString := range commands {
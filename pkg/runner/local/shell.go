@@ -0,0 +1,105 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/yarlson/ftl/pkg/shell"
+)
+
+// Shell runs commands on the local machine with the same semantics as the
+// remote runner: arguments are quoted for the shell, output is streamed, and
+// a non-zero exit status is reported when the output is read to the end or
+// closed. It lets code written for a server drive the local Docker daemon.
+type Shell struct{}
+
+func NewShell() *Shell {
+	return &Shell{}
+}
+
+// RunCommand starts command through sh and returns its combined output.
+func (s *Shell) RunCommand(ctx context.Context, command string, args ...string) (io.ReadCloser, error) {
+	fullCmd := shell.Join(command, args...)
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("creating output pipe: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", fullCmd)
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if err := cmd.Start(); err != nil {
+		_ = reader.Close()
+		_ = writer.Close()
+		return nil, fmt.Errorf("starting command: %w", err)
+	}
+	_ = writer.Close()
+
+	return &shellOutput{reader: reader, cmd: cmd}, nil
+}
+
+// CopyFile copies src to dst on the local machine.
+func (s *Shell) CopyFile(ctx context.Context, src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("opening source file: %w", err)
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("creating destination directory: %w", err)
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("creating destination file: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("copying file: %w", err)
+	}
+	return out.Close()
+}
+
+// Host returns localhost.
+func (s *Shell) Host() string {
+	return "localhost"
+}
+
+// shellOutput waits for the command once its output has been consumed, so
+// callers that only read the output do not leave zombie processes behind.
+type shellOutput struct {
+	reader  *os.File
+	cmd     *exec.Cmd
+	once    sync.Once
+	waitErr error
+}
+
+func (o *shellOutput) Read(p []byte) (int, error) {
+	n, err := o.reader.Read(p)
+	if err == io.EOF {
+		o.wait()
+	}
+	return n, err
+}
+
+// Close releases the output and returns an error if the command failed.
+func (o *shellOutput) Close() error {
+	_ = o.reader.Close()
+	o.wait()
+	return o.waitErr
+}
+
+func (o *shellOutput) wait() {
+	o.once.Do(func() {
+		if err := o.cmd.Wait(); err != nil {
+			o.waitErr = fmt.Errorf("command failed: %w", err)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package local
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShellRunCommand(t *testing.T) {
+	shell := NewShell()
+
+	output, err := shell.RunCommand(context.Background(), "echo", "it's", "quoted")
+	require.NoError(t, err)
+	data, err := io.ReadAll(output)
+	require.NoError(t, err)
+	assert.Equal(t, "it's quoted\n", string(data))
+	assert.NoError(t, output.Close())
+
+	output, err = shell.RunCommand(context.Background(), "sh", "-c", "echo failing >&2; exit 3")
+	require.NoError(t, err)
+	data, err = io.ReadAll(output)
+	require.NoError(t, err)
+	assert.Equal(t, "failing\n", string(data))
+	assert.ErrorContains(t, output.Close(), "exit status 3")
+}
+
+func TestShellCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.txt")
+	require.NoError(t, os.WriteFile(src, []byte("content"), 0644))
+
+	dst := filepath.Join(dir, "nested", "dst.txt")
+	require.NoError(t, NewShell().CopyFile(context.Background(), src, dst))
+
+	data, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+}
@@ -1,15 +1,19 @@
 package remote
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"strings"
 
 	"github.com/bramvdbogaerde/go-scp"
 	"golang.org/x/crypto/ssh"
+
+	"github.com/yarlson/ftl/pkg/shell"
 )
 
 // ErrNoClient is returned when attempting operations on a closed Runner.
@@ -18,7 +22,9 @@ var ErrNoClient = errors.New("ssh client is nil")
 // Runner executes commands and transfers files on a remote host via SSH.
 // Once closed, a Runner cannot be reused.
 type Runner struct {
-	client *ssh.Client // client is unexported as it's an implementation detail
+	client  *ssh.Client // client is unexported as it's an implementation detail
+	env     []string
+	limiter *limiter
 }
 
 // NewRunner creates a new Runner instance using the provided SSH client.
@@ -41,6 +47,31 @@ func (r *Runner) Close() error {
 	return err
 }
 
+// SetEnv exports an environment variable to every command run afterwards.
+// It is set by the remote shell, so it does not depend on the SSH server
+// accepting client environment variables.
+func (r *Runner) SetEnv(name, value string) {
+	r.env = append(r.env, shell.Export(name, value))
+}
+
+// SetUploadLimit caps the bandwidth of every file and input sent to the
+// remote host afterwards, together, to bytesPerSecond. Zero removes the cap.
+func (r *Runner) SetUploadLimit(bytesPerSecond int64) {
+	if bytesPerSecond <= 0 {
+		r.limiter = nil
+		return
+	}
+	r.limiter = newLimiter(bytesPerSecond)
+}
+
+// throttle returns reader paced to the upload limit, if one is set.
+func (r *Runner) throttle(ctx context.Context, reader io.Reader) io.Reader {
+	if r.limiter == nil {
+		return reader
+	}
+	return &throttledReader{ctx: ctx, reader: reader, limiter: r.limiter}
+}
+
 // RunCommands executes multiple commands sequentially on the remote host.
 // It stops at the first command that fails.
 func (r *Runner) RunCommands(ctx context.Context, commands []string) error {
@@ -80,15 +111,9 @@ func (r *Runner) RunCommand(ctx context.Context, command string, args ...string)
 		return nil, fmt.Errorf("creating session: %w", err)
 	}
 
-	// Build the full command with properly escaped arguments
-	fullCmd := command
-	if len(args) > 0 {
-		escapedArgs := make([]string, len(args))
-		for i, arg := range args {
-			escapedArgs[i] = escapeArg(arg)
-		}
-		fullCmd += " " + strings.Join(escapedArgs, " ")
-	}
+	// Run the command with /bin/sh rather than the login shell of the user,
+	// so it means the same on every server.
+	fullCmd := shell.Wrap(strings.Join(r.env, "") + shell.Join(command, args...))
 
 	// Set up command I/O
 	stdout, err := session.StdoutPipe()
@@ -120,8 +145,15 @@ func (r *Runner) Host() string {
 	if r.client == nil {
 		return ""
 	}
-	addr := r.client.RemoteAddr().String()
-	host, _, _ := strings.Cut(addr, ":")
+	return addressHost(r.client.RemoteAddr().String())
+}
+
+// addressHost returns the host of addr, host:port or [host]:port for IPv6.
+func addressHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
 	return host
 }
 
@@ -143,7 +175,8 @@ func (r *Runner) CopyFile(ctx context.Context, src, dst string) error {
 	}
 	defer f.Close()
 
-	if err := client.CopyFile(ctx, f, dst, "0644"); err != nil {
+	passThru := func(reader io.Reader, _ int64) io.Reader { return r.throttle(ctx, reader) }
+	if err := client.CopyFromFilePassThru(ctx, *f, dst, "0644", passThru); err != nil {
 		return fmt.Errorf("copying file: %w", err)
 	}
 	return nil
@@ -181,7 +214,79 @@ func (c *commandOutput) Close() error {
 	return c.session.Close()
 }
 
-// escapeArg escapes a command-line argument for safe use in SSH commands.
-func escapeArg(arg string) string {
-	return "'" + strings.ReplaceAll(arg, "'", "'\\''") + "'"
+// RunCommandWithOutput executes a command on the remote host, writing its
+// combined output to w as it is produced, and waits for it to finish. Unlike
+// the output of RunCommand, it returns an error wrapping *ssh.ExitError when
+// the command exits with a non-zero status.
+func (r *Runner) RunCommandWithOutput(ctx context.Context, w io.Writer, command string, args ...string) error {
+	if r.client == nil {
+		return ErrNoClient
+	}
+
+	session, err := r.client.NewSession()
+	if err != nil {
+		return fmt.Errorf("creating session: %w", err)
+	}
+	defer session.Close()
+
+	session.Stdout = w
+	session.Stderr = w
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = session.Signal(ssh.SIGTERM)
+			_ = session.Close()
+		case <-done:
+		}
+	}()
+
+	if err := session.Run(shell.Wrap(strings.Join(r.env, "") + shell.Join(command, args...))); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("command failed: %w", err)
+	}
+	return nil
+}
+
+// RunCommandWithInput executes a command on the remote host with input as its
+// standard input, such as an archive streamed to docker load, and waits for it
+// to finish. It returns the combined output of the command.
+func (r *Runner) RunCommandWithInput(ctx context.Context, input io.Reader, command string, args ...string) ([]byte, error) {
+	if r.client == nil {
+		return nil, ErrNoClient
+	}
+
+	session, err := r.client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("creating session: %w", err)
+	}
+	defer session.Close()
+
+	var output bytes.Buffer
+	session.Stdin = r.throttle(ctx, input)
+	session.Stdout = &output
+	session.Stderr = &output
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = session.Signal(ssh.SIGTERM)
+			_ = session.Close()
+		case <-done:
+		}
+	}()
+
+	if err := session.Run(shell.Wrap(strings.Join(r.env, "") + shell.Join(command, args...))); err != nil {
+		if ctx.Err() != nil {
+			return output.Bytes(), ctx.Err()
+		}
+		return output.Bytes(), fmt.Errorf("running command: %w", err)
+	}
+	return output.Bytes(), nil
 }
@@ -0,0 +1,13 @@
+package remote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressHost(t *testing.T) {
+	assert.Equal(t, "203.0.113.10", addressHost("203.0.113.10:22"))
+	assert.Equal(t, "2001:db8::1", addressHost("[2001:db8::1]:2222"))
+	assert.Equal(t, "203.0.113.10", addressHost("203.0.113.10"))
+}
@@ -0,0 +1,79 @@
+package remote
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// throttleSlices is how many reads a second of bandwidth is split into, so a
+// throttled upload sends small steady chunks instead of bursts.
+const throttleSlices = 10
+
+// limiter paces reads to a number of bytes per second shared by every reader
+// it throttles.
+type limiter struct {
+	mu    sync.Mutex
+	rate  int64
+	next  time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newLimiter(rate int64) *limiter {
+	return &limiter{rate: rate, sleep: sleepContext}
+}
+
+// chunk returns the most bytes a single throttled read may return.
+func (l *limiter) chunk() int {
+	return int(max(l.rate/throttleSlices, 1))
+}
+
+// wait blocks until n more bytes may be sent, booking the time they take at
+// the limit so the readers sharing l stay under it together.
+func (l *limiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(n) / float64(l.rate) * float64(time.Second)))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	return l.sleep(ctx, delay)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttledReader reads from reader no faster than limiter allows.
+type throttledReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *limiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if chunk := t.limiter.chunk(); len(p) > chunk {
+		p = p[:chunk]
+	}
+	n, err := t.reader.Read(p)
+	if n > 0 {
+		if waitErr := t.limiter.wait(t.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
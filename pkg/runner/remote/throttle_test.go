@@ -0,0 +1,44 @@
+package remote
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottledReader(t *testing.T) {
+	l := newLimiter(100)
+	var slept time.Duration
+	l.sleep = func(_ context.Context, d time.Duration) error {
+		slept = d
+		return nil
+	}
+
+	reader := &throttledReader{ctx: context.Background(), reader: strings.NewReader(strings.Repeat("x", 50)), limiter: l}
+	buf := make([]byte, 64)
+	n, err := reader.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, 10, n, "reads are split into tenths of the rate")
+
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Len(t, data, 40)
+	// The first chunk is sent at once, the fifth once the four before it
+	// took their share of the second.
+	assert.InDelta(t, float64(400*time.Millisecond), float64(slept), float64(50*time.Millisecond))
+}
+
+func TestThrottledReader_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	l := newLimiter(1)
+	reader := &throttledReader{ctx: ctx, reader: strings.NewReader("ab"), limiter: l}
+	_, err := io.ReadAll(reader)
+	assert.ErrorIs(t, err, context.Canceled)
+}
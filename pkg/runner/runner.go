@@ -0,0 +1,52 @@
+// Package runner holds what the command runners in its subpackages have in
+// common.
+package runner
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// CommandRunner starts commands. The output of RunCommand does not report
+// the exit status of the command: remote commands that exit with a non-zero
+// status read and close like successful ones.
+type CommandRunner interface {
+	RunCommand(ctx context.Context, command string, args ...string) (io.ReadCloser, error)
+}
+
+// OutputRunner is implemented by runners that run a command to completion,
+// writing its combined output to w as it is produced, and return an error
+// when it fails or exits with a non-zero status.
+type OutputRunner interface {
+	RunCommandWithOutput(ctx context.Context, w io.Writer, command string, args ...string) error
+}
+
+// RunChecked runs command with r and returns its combined output. It fails
+// when the command exits with a non-zero status; the output is returned
+// either way.
+func RunChecked(ctx context.Context, r CommandRunner, command string, args ...string) ([]byte, error) {
+	var output bytes.Buffer
+	err := RunCheckedWithOutput(ctx, r, &output, command, args...)
+	return output.Bytes(), err
+}
+
+// RunCheckedWithOutput runs command with r like RunChecked, writing its
+// output to w as it is produced. Runners that do not implement OutputRunner
+// report failures when the command is started or its output is closed.
+func RunCheckedWithOutput(ctx context.Context, r CommandRunner, w io.Writer, command string, args ...string) error {
+	if runner, ok := r.(OutputRunner); ok {
+		return runner.RunCommandWithOutput(ctx, w, command, args...)
+	}
+
+	output, err := r.RunCommand(ctx, command, args...)
+	if err != nil {
+		return err
+	}
+	_, copyErr := io.Copy(w, output)
+	closeErr := output.Close()
+	if copyErr != nil {
+		return copyErr
+	}
+	return closeErr
+}
@@ -0,0 +1,38 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// closingRunner reports the exit status of its commands when their output
+// is closed, like the local shell runner.
+type closingRunner struct {
+	err error
+}
+
+type failingOutput struct {
+	io.Reader
+	err error
+}
+
+func (o failingOutput) Close() error { return o.err }
+
+func (r closingRunner) RunCommand(ctx context.Context, command string, args ...string) (io.ReadCloser, error) {
+	return failingOutput{Reader: strings.NewReader("permission denied"), err: r.err}, nil
+}
+
+func TestRunChecked(t *testing.T) {
+	output, err := RunChecked(context.Background(), closingRunner{}, "true")
+	assert.NoError(t, err)
+	assert.Equal(t, "permission denied", string(output))
+
+	output, err = RunChecked(context.Background(), closingRunner{err: errors.New("exit status 1")}, "false")
+	assert.EqualError(t, err, "exit status 1")
+	assert.Equal(t, "permission denied", string(output))
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/runner/remote"
+	"github.com/yarlson/ftl/pkg/shell"
+	"github.com/yarlson/ftl/pkg/ssh"
+)
+
+// sshdConfigPath is the sshd drop-in that turns password logins off. sshd
+// keeps the first value it reads, so the name sorts before the drop-ins of
+// cloud images that turn them on, such as 50-cloud-init.conf.
+const sshdConfigPath = "/etc/ssh/sshd_config.d/00-ftl.conf"
+
+const sshdConfig = `# Written by ftl setup --password
+PasswordAuthentication no
+KbdInteractiveAuthentication no
+PermitRootLogin prohibit-password
+`
+
+// Bootstrap connects to a server that only accepts the root password and
+// authorizes the SSH key ftl connects with for root, so Setup can connect with
+// the key.
+func Bootstrap(ctx context.Context, cfg *config.Server, rootPassword string) error {
+	if cfg.ProxyJump != nil {
+		return errors.New("password login connects to the server directly and cannot go through proxy_jump")
+	}
+
+	publicKey, err := ssh.AuthorizedKey(cfg.SSHKey)
+	if err != nil {
+		return err
+	}
+
+	client, err := ssh.NewSSHClientWithPassword(cfg.Host, strconv.Itoa(cfg.Port), "root", rootPassword)
+	if err != nil {
+		return fmt.Errorf("failed to log in as root with the password: %w", err)
+	}
+	defer client.Close()
+
+	if err := remote.NewRunner(client).RunCommands(ctx, authorizeKeyCommands("root", "/root/.ssh", publicKey)); err != nil {
+		return fmt.Errorf("failed to authorize SSH key for root: %w", err)
+	}
+	return nil
+}
+
+// DisablePasswordLogin turns password logins off on the server once the
+// deploy user can log in with the SSH key, leaving them on otherwise so the
+// server cannot be locked out.
+func DisablePasswordLogin(ctx context.Context, cfg *config.Server) error {
+	userClient, err := ssh.FindKeyAndConnectWithUser(cfg.Host, cfg.Port, cfg.User, cfg.SSHKey, (*ssh.Jump)(cfg.ProxyJump))
+	if err != nil {
+		return fmt.Errorf("%s cannot log in with the SSH key, so password login is left on: %w", cfg.User, err)
+	}
+	userClient.Close()
+
+	client, err := ssh.FindKeyAndConnectWithUser(cfg.Host, cfg.Port, "root", cfg.SSHKey, (*ssh.Jump)(cfg.ProxyJump))
+	if err != nil {
+		return fmt.Errorf("failed to connect via SSH: %w", err)
+	}
+	defer client.Close()
+
+	commands := []string{
+		fmt.Sprintf("printf '%%s' %s > %s", shell.Quote(sshdConfig), sshdConfigPath),
+		"sshd -t",
+		"systemctl reload ssh || systemctl reload sshd",
+	}
+	return remote.NewRunner(client).RunCommands(ctx, commands)
+}
+
+// authorizeKeyCommands returns the commands that add publicKey to the
+// authorized keys of user in sshDir, unless it is there already.
+func authorizeKeyCommands(user, sshDir, publicKey string) []string {
+	authKeysFile := filepath.Join(sshDir, "authorized_keys")
+	return []string{
+		fmt.Sprintf("mkdir -p %s", sshDir),
+		fmt.Sprintf("touch %s", authKeysFile),
+		fmt.Sprintf("grep -qxF %[1]s %[2]s || echo %[1]s >> %[2]s", shell.Quote(publicKey), authKeysFile),
+		fmt.Sprintf("chown -R %s:%s %s", user, user, sshDir),
+		fmt.Sprintf("chmod 700 %s", sshDir),
+		fmt.Sprintf("chmod 600 %s", authKeysFile),
+	}
+}
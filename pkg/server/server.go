@@ -3,14 +3,11 @@ package server
 import (
 	"context"
 	"fmt"
-	"os"
-	"path/filepath"
-	"strings"
-
-	gossh "golang.org/x/crypto/ssh"
 
 	"github.com/yarlson/ftl/pkg/config"
+	"github.com/yarlson/ftl/pkg/deployment"
 	"github.com/yarlson/ftl/pkg/runner/remote"
+	"github.com/yarlson/ftl/pkg/shell"
 	"github.com/yarlson/ftl/pkg/ssh"
 	"github.com/yarlson/pin"
 )
@@ -33,7 +30,7 @@ func Setup(ctx context.Context, cfg *config.Config, dockerCreds DockerCredential
 
 func setupServer(ctx context.Context, cfg *config.Server, dockerCreds DockerCredentials, newUserPassword string, spinner *pin.Pin) error {
 	spinner.UpdateMessage("Establishing SSH connection to server " + cfg.Host + " as root...")
-	sshClient, rootKey, err := ssh.FindKeyAndConnectWithUser(cfg.Host, cfg.Port, "root", cfg.SSHKey)
+	sshClient, err := ssh.FindKeyAndConnectWithUser(cfg.Host, cfg.Port, "root", cfg.SSHKey, (*ssh.Jump)(cfg.ProxyJump))
 	if err != nil {
 		return fmt.Errorf("failed to connect via SSH: %w", err)
 	}
@@ -41,10 +38,9 @@ func setupServer(ctx context.Context, cfg *config.Server, dockerCreds DockerCred
 	defer sshClient.Close()
 
 	runner := remote.NewRunner(sshClient)
-	cfg.RootSSHKey = string(rootKey)
 
 	spinner.UpdateMessage("Installing required software...")
-	if err := installSoftware(ctx, runner); err != nil {
+	if err := installSoftware(ctx, runner, cfg.Rootless); err != nil {
 		return fmt.Errorf("installing software: %w", err)
 	}
 	spinner.UpdateMessage("Software installation complete.")
@@ -56,17 +52,31 @@ func setupServer(ctx context.Context, cfg *config.Server, dockerCreds DockerCred
 	spinner.UpdateMessage("Firewall configuration complete.")
 
 	spinner.UpdateMessage("Creating user account " + cfg.User + "...")
-	if err := createUser(ctx, runner, cfg.User, newUserPassword); err != nil {
+	if err := createUser(ctx, runner, cfg.User, newUserPassword, cfg.Rootless); err != nil {
 		return fmt.Errorf("creating user: %w", err)
 	}
 	spinner.UpdateMessage("User account created.")
 
+	if cfg.Rootless {
+		spinner.UpdateMessage("Setting up rootless Docker for " + cfg.User + "...")
+		if err := setupRootlessDocker(ctx, runner, cfg.User); err != nil {
+			return fmt.Errorf("setting up rootless docker: %w", err)
+		}
+		spinner.UpdateMessage("Rootless Docker setup complete.")
+	}
+
 	spinner.UpdateMessage("Setting up SSH key for user " + cfg.User + "...")
 	if err := setupSSHKey(ctx, runner, cfg); err != nil {
 		return fmt.Errorf("setting up SSH key: %w", err)
 	}
 	spinner.UpdateMessage("SSH key setup complete.")
 
+	spinner.UpdateMessage("Installing boot service...")
+	if err := installBootService(ctx, runner, cfg); err != nil {
+		return fmt.Errorf("installing boot service: %w", err)
+	}
+	spinner.UpdateMessage("Boot service installed.")
+
 	if dockerCreds.Username != "" && dockerCreds.Password != "" {
 		spinner.UpdateMessage("Logging into Docker registry...")
 		if err := dockerLogin(ctx, runner, dockerCreds); err != nil {
@@ -78,7 +88,7 @@ func setupServer(ctx context.Context, cfg *config.Server, dockerCreds DockerCred
 	return nil
 }
 
-func installSoftware(ctx context.Context, runner *remote.Runner) error {
+func installSoftware(ctx context.Context, runner *remote.Runner, rootless bool) error {
 	commands := []string{
 		"apt-get update",
 		"apt-get install -y apt-transport-https ca-certificates curl wget git software-properties-common",
@@ -87,6 +97,64 @@ func installSoftware(ctx context.Context, runner *remote.Runner) error {
 		"apt-get update",
 		"apt-get install -y docker-ce docker-ce-cli containerd.io docker-compose-plugin",
 	}
+	if rootless {
+		commands = append(commands, "apt-get install -y docker-ce-rootless-extras uidmap dbus-user-session")
+	}
+	return runner.RunCommands(ctx, commands)
+}
+
+// setupRootlessDocker runs a Docker daemon as user instead of root. The root
+// daemon is disabled, the user's daemon is kept running without a login
+// session, and unprivileged processes may bind the proxy's ports 80 and 443.
+func setupRootlessDocker(ctx context.Context, runner *remote.Runner, user string) error {
+	commands := []string{
+		"systemctl disable --now docker.service docker.socket",
+		"echo 'net.ipv4.ip_unprivileged_port_start=80' > /etc/sysctl.d/99-ftl-rootless-docker.conf",
+		"sysctl --system",
+		fmt.Sprintf("loginctl enable-linger %s", user),
+		fmt.Sprintf(`su - %[1]s -c 'export XDG_RUNTIME_DIR=/run/user/$(id -u); for i in $(seq 30); do [ -d "$XDG_RUNTIME_DIR" ] && break; sleep 1; done; dockerd-rootless-setuptool.sh install'`, user),
+	}
+	return runner.RunCommands(ctx, commands)
+}
+
+// bootScriptPath is where the script bringing projects back after a reboot is
+// installed.
+const bootScriptPath = "/usr/local/bin/ftl-boot"
+
+// bootUnit returns the systemd unit that runs the boot script as the deploy
+// user once the server has booted.
+func bootUnit(server *config.Server) string {
+	environment := ""
+	if server.DockerHost != "" {
+		environment = "Environment=DOCKER_HOST=" + server.DockerHost + "\n"
+	}
+	return fmt.Sprintf(`[Unit]
+Description=Start the ftl projects of %[1]s in dependency order
+After=docker.service network-online.target
+Wants=network-online.target
+
+[Service]
+Type=oneshot
+User=%[1]s
+%[2]sExecStart=%[3]s
+TimeoutStartSec=30min
+
+[Install]
+WantedBy=multi-user.target
+`, server.User, environment, bootScriptPath)
+}
+
+// installBootService installs the boot script and enables the unit running it
+// for the deploy user, so every project comes back in order after a reboot.
+func installBootService(ctx context.Context, runner *remote.Runner, server *config.Server) error {
+	unit := "ftl-boot-" + server.User + ".service"
+	commands := []string{
+		fmt.Sprintf("printf '%%s' %s > %s", shell.Quote(deployment.BootScript), bootScriptPath),
+		"chmod 755 " + bootScriptPath,
+		fmt.Sprintf("printf '%%s' %s > /etc/systemd/system/%s", shell.Quote(bootUnit(server)), unit),
+		"systemctl daemon-reload",
+		"systemctl enable " + unit,
+	}
 	return runner.RunCommands(ctx, commands)
 }
 
@@ -103,7 +171,7 @@ func configureFirewall(ctx context.Context, runner *remote.Runner) error {
 	return runner.RunCommands(ctx, commands)
 }
 
-func createUser(ctx context.Context, runner *remote.Runner, user, password string) error {
+func createUser(ctx context.Context, runner *remote.Runner, user, password string, rootless bool) error {
 	checkUserCmd := fmt.Sprintf("id -u %s", user)
 	if _, err := runner.RunCommand(ctx, checkUserCmd); err == nil {
 		// User already exists
@@ -113,34 +181,22 @@ func createUser(ctx context.Context, runner *remote.Runner, user, password strin
 	commands := []string{
 		fmt.Sprintf("adduser --gecos '' --disabled-password %s", user),
 		fmt.Sprintf("echo '%s:%s' | chpasswd", user, password),
-		fmt.Sprintf("usermod -aG docker %s", user),
+	}
+	// Members of the docker group control the root daemon, which a rootless
+	// setup is meant to avoid.
+	if !rootless {
+		commands = append(commands, fmt.Sprintf("usermod -aG docker %s", user))
 	}
 	return runner.RunCommands(ctx, commands)
 }
 
 func setupSSHKey(ctx context.Context, runner *remote.Runner, server *config.Server) error {
-	keyData, err := readSSHKey(server.SSHKey)
+	publicKey, err := ssh.AuthorizedKey(server.SSHKey)
 	if err != nil {
 		return err
 	}
 
-	publicKey, err := parsePublicKey(keyData)
-	if err != nil {
-		return err
-	}
-
-	user := server.User
-	sshDir := fmt.Sprintf("/home/%s/.ssh", user)
-	authKeysFile := filepath.Join(sshDir, "authorized_keys")
-
-	commands := []string{
-		fmt.Sprintf("mkdir -p %s", sshDir),
-		fmt.Sprintf("echo '%s' | tee -a %s", publicKey, authKeysFile),
-		fmt.Sprintf("chown -R %s:%s %s", user, user, sshDir),
-		fmt.Sprintf("chmod 700 %s", sshDir),
-		fmt.Sprintf("chmod 600 %s", authKeysFile),
-	}
-	return runner.RunCommands(ctx, commands)
+	return runner.RunCommands(ctx, authorizeKeyCommands(server.User, fmt.Sprintf("/home/%s/.ssh", server.User), publicKey))
 }
 
 func dockerLogin(ctx context.Context, runner *remote.Runner, creds DockerCredentials) error {
@@ -148,22 +204,3 @@ func dockerLogin(ctx context.Context, runner *remote.Runner, creds DockerCredent
 	_, err := runner.RunCommand(ctx, command)
 	return err
 }
-
-func readSSHKey(keyPath string) ([]byte, error) {
-	if strings.HasPrefix(keyPath, "~") {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get home directory: %w", err)
-		}
-		keyPath = filepath.Join(homeDir, keyPath[1:])
-	}
-	return os.ReadFile(keyPath)
-}
-
-func parsePublicKey(keyData []byte) (string, error) {
-	privateKey, err := gossh.ParsePrivateKey(keyData)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse private key: %w", err)
-	}
-	return string(gossh.MarshalAuthorizedKey(privateKey.PublicKey())), nil
-}
@@ -0,0 +1,151 @@
+// Package shell builds the command lines ftl runs on servers. Everything is
+// written for the POSIX sh found on every server, whether /bin/sh is bash,
+// dash or BusyBox ash, and run through it instead of the user's login shell,
+// which may be anything from bash to fish.
+package shell
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Quote quotes s as a single word for a POSIX shell.
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Join returns the command line that runs command with args, each argument
+// quoted. command itself is not quoted, so it may be a script fragment.
+func Join(command string, args ...string) string {
+	if len(args) == 0 {
+		return command
+	}
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = Quote(arg)
+	}
+	return command + " " + strings.Join(quoted, " ")
+}
+
+// Export returns the statement that exports name with value to the commands
+// after it.
+func Export(name, value string) string {
+	return fmt.Sprintf("export %s=%s; ", name, Quote(value))
+}
+
+// Wrap returns the command line that runs script with /bin/sh, so it is
+// interpreted the same way whatever the login shell of the user is.
+func Wrap(script string) string {
+	return "/bin/sh -c " + Quote(script)
+}
+
+// Dialect is the flavour of the core utilities on a server. File operations
+// that GNU coreutils can do atomically need a different command elsewhere.
+type Dialect int
+
+const (
+	// POSIX utilities, with none of the GNU extensions.
+	POSIX Dialect = iota
+	// GNU coreutils, as on Debian, Ubuntu and RHEL.
+	GNU
+	// BusyBox, as on Alpine and many minimal images.
+	BusyBox
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case GNU:
+		return "gnu"
+	case BusyBox:
+		return "busybox"
+	default:
+		return "posix"
+	}
+}
+
+// detectScript prints which utilities are installed.
+const detectScript = `if mv --version 2>/dev/null | grep -q GNU; then echo gnu; elif readlink "$(command -v mv)" 2>/dev/null | grep -q busybox; then echo busybox; else echo posix; fi`
+
+// Runner runs commands on a server.
+type Runner interface {
+	RunCommand(ctx context.Context, command string, args ...string) (io.ReadCloser, error)
+}
+
+// Detect returns the dialect of the utilities on the server of runner.
+func Detect(ctx context.Context, runner Runner) (Dialect, error) {
+	output, err := runner.RunCommand(ctx, "sh", "-c", detectScript)
+	if err != nil {
+		return POSIX, fmt.Errorf("failed to detect shell utilities: %w", err)
+	}
+	defer output.Close()
+
+	data, err := io.ReadAll(output)
+	if err != nil {
+		return POSIX, fmt.Errorf("failed to read shell utilities: %w", err)
+	}
+
+	switch strings.TrimSpace(string(data)) {
+	case "gnu":
+		return GNU, nil
+	case "busybox":
+		return BusyBox, nil
+	default:
+		return POSIX, nil
+	}
+}
+
+// ReplaceSymlink returns the script that points link at target by renaming a
+// new link over it, which is atomic. A plain mv would move the new link into
+// the directory link points to, so mv is told not to follow link: with -T on
+// GNU and BusyBox, -h on BSD and macOS, and where neither is known the old
+// link is removed first.
+func (d Dialect) ReplaceSymlink(target, link string) string {
+	tmp := link + ".tmp"
+	if d == GNU {
+		return fmt.Sprintf("ln -sfn %s %s && mv -Tf %s %s", Quote(target), Quote(tmp), Quote(tmp), Quote(link))
+	}
+	return fmt.Sprintf("rm -f %[2]s && ln -s %[1]s %[2]s && { mv -Tf %[2]s %[3]s 2>/dev/null || mv -hf %[2]s %[3]s 2>/dev/null || { rm -f %[3]s && mv -f %[2]s %[3]s; }; }",
+		Quote(target), Quote(tmp), Quote(link))
+}
+
+// commandStart matches where a command word may start.
+const commandStart = `(^|[;&|({]|\bthen|\bdo|\belse)\s*`
+
+// bashisms are constructs of bash and other shells that POSIX sh does not
+// have, with what to use instead.
+var bashisms = []struct {
+	pattern *regexp.Regexp
+	hint    string
+}{
+	{regexp.MustCompile(`\[\[`), "[[ is not POSIX, use ["},
+	{regexp.MustCompile(`\[ [^]]* == `), "== in [ is not POSIX, use ="},
+	{regexp.MustCompile(`&>`), "&> is not POSIX, use >file 2>&1"},
+	{regexp.MustCompile(`<<<`), "here-strings are not POSIX, use printf | or a here-document"},
+	{regexp.MustCompile(commandStart + `function\s`), "function is not POSIX, use name() { ... }"},
+	{regexp.MustCompile(commandStart + `source\s`), "source is not POSIX, use ."},
+	{regexp.MustCompile(`\$'`), "$'...' strings are not POSIX, use printf"},
+	{regexp.MustCompile(`pipefail`), "pipefail is not POSIX"},
+	{regexp.MustCompile(`\{[0-9]+\.\.[0-9]+\}`), "brace ranges are not POSIX, use seq or a while loop"},
+	{regexp.MustCompile(`(^|\s)echo -[en]\s`), "echo options are not portable, use printf"},
+	{regexp.MustCompile(`\blocal\s`), "local is not POSIX"},
+}
+
+// Check reports the constructs in script that only some shells understand.
+// It is a heuristic for the scripts ftl generates, not a parser.
+func Check(script string) error {
+	var problems []string
+	for _, line := range strings.Split(script, "\n") {
+		for _, b := range bashisms {
+			if b.pattern.MatchString(line) {
+				problems = append(problems, fmt.Sprintf("%s: %s", strings.TrimSpace(line), b.hint))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("script is not POSIX sh:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
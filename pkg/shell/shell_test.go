@@ -0,0 +1,103 @@
+package shell
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yarlson/ftl/pkg/runner/fake"
+)
+
+func TestJoin(t *testing.T) {
+	assert.Equal(t, "docker", Join("docker"))
+	assert.Equal(t, `docker 'ps' 'it'\''s here'`, Join("docker", "ps", "it's here"))
+	assert.Equal(t, `export DOCKER_HOST='unix:///run/user/1000/docker.sock'; `, Export("DOCKER_HOST", "unix:///run/user/1000/docker.sock"))
+	assert.Equal(t, `/bin/sh -c 'echo '\''hi'\'''`, Wrap("echo 'hi'"))
+
+	output, err := exec.Command("sh", "-c", Wrap(Export("GREETING", "it's $HOME")+Join("printf '%s'", "$GREETING"))).Output()
+	require.NoError(t, err)
+	assert.Equal(t, "$GREETING", string(output))
+}
+
+func TestDetect(t *testing.T) {
+	runner := fake.NewRunner()
+	for output, want := range map[string]Dialect{"gnu\n": GNU, "busybox\n": BusyBox, "posix\n": POSIX, "": POSIX} {
+		runner.On("sh -c", fake.Response{Output: output})
+		dialect, err := Detect(context.Background(), runner)
+		require.NoError(t, err)
+		assert.Equal(t, want, dialect, output)
+	}
+}
+
+func TestReplaceSymlink(t *testing.T) {
+	for _, dialect := range []Dialect{GNU, POSIX, BusyBox} {
+		t.Run(dialect.String(), func(t *testing.T) {
+			if dialect == GNU && exec.Command("sh", "-c", "mv --version | grep -q GNU").Run() != nil {
+				t.Skip("GNU coreutils are not installed")
+			}
+
+			dir := t.TempDir()
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, "releases", "1"), 0755))
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, "releases", "2"), 0755))
+
+			for _, release := range []string{"releases/1", "releases/2"} {
+				cmd := exec.Command("sh", "-c", dialect.ReplaceSymlink(release, "current"))
+				cmd.Dir = dir
+				output, err := cmd.CombinedOutput()
+				require.NoError(t, err, string(output))
+
+				target, err := os.Readlink(filepath.Join(dir, "current"))
+				require.NoError(t, err)
+				assert.Equal(t, release, target)
+			}
+			for _, name := range []string{"2", "current.tmp"} {
+				_, err := os.Lstat(filepath.Join(dir, "releases", "1", name))
+				assert.True(t, os.IsNotExist(err), "the new link was moved into the old release")
+			}
+		})
+	}
+
+	t.Run("mv without -T or -h", func(t *testing.T) {
+		mv, err := exec.LookPath("mv")
+		require.NoError(t, err)
+		bin := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(bin, "mv"), []byte("#!/bin/sh\ncase \"$1\" in -T*|-h*) exit 1;; esac\nexec "+mv+" \"$@\"\n"), 0755))
+
+		dir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "releases", "1"), 0755))
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "releases", "2"), 0755))
+		for _, release := range []string{"releases/1", "releases/2"} {
+			cmd := exec.Command("sh", "-c", POSIX.ReplaceSymlink(release, "current"))
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(), "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+			output, err := cmd.CombinedOutput()
+			require.NoError(t, err, string(output))
+
+			target, err := os.Readlink(filepath.Join(dir, "current"))
+			require.NoError(t, err)
+			assert.Equal(t, release, target)
+		}
+		_, err = os.Lstat(filepath.Join(dir, "releases", "1", "current.tmp"))
+		assert.True(t, os.IsNotExist(err), "the new link was moved into the old release")
+	})
+}
+
+func TestCheck(t *testing.T) {
+	assert.NoError(t, Check(`if [ "$count" -gt 0 ] && [ "$a" = "b" ]; then . ./env; fi
+for i in $(seq 30); do printf '%s\n' "$i" >/dev/null 2>&1; done`))
+
+	err := Check(`if [[ -f x ]]; then source ./env; fi
+docker logs app &> /dev/null
+[ "$a" == "b" ] && echo -e "done"`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "[[ is not POSIX")
+	assert.Contains(t, err.Error(), "source is not POSIX")
+	assert.Contains(t, err.Error(), "&> is not POSIX")
+	assert.Contains(t, err.Error(), "== in [ is not POSIX")
+	assert.Contains(t, err.Error(), "echo options are not portable")
+}
@@ -0,0 +1,242 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// AgentEnv is the environment variable that holds the socket of the running
+// ssh-agent.
+const AgentEnv = "SSH_AUTH_SOCK"
+
+// NoAgent as the identity agent keeps connections from using an ssh-agent.
+const NoAgent = "none"
+
+// PassphraseFunc asks for the passphrase of the encrypted private key at path.
+type PassphraseFunc func(path string) ([]byte, error)
+
+var (
+	// identityAgent is the socket of the ssh-agent set with SetIdentityAgent.
+	identityAgent string
+	// passphrasePrompt asks for the passphrases of encrypted keys.
+	passphrasePrompt PassphraseFunc
+
+	// passphrases caches the passphrase of every key it was asked for, so it
+	// is asked once per run whatever the number of connections.
+	passphrases   = map[string][]byte{}
+	passphrasesMu sync.Mutex
+
+	agents   = map[string]agent.ExtendedAgent{}
+	agentsMu sync.Mutex
+)
+
+// SetIdentityAgent makes connections authenticate with the keys of the
+// ssh-agent listening on socket instead of the one in SSH_AUTH_SOCK, such as
+// the agent of a password manager. NoAgent turns the ssh-agent off.
+func SetIdentityAgent(socket string) {
+	identityAgent = socket
+}
+
+// SetPassphrasePrompt makes connections ask prompt for the passphrase of
+// encrypted keys the ssh-agent does not hold. Without a prompt such keys
+// cannot be used.
+func SetPassphrasePrompt(prompt PassphraseFunc) {
+	passphrasePrompt = prompt
+}
+
+// Signers returns the keys connections authenticate with: the key set in
+// KeyEnv, or else the key at keyPath. Keys the ssh-agent holds are signed
+// with by the agent, so encrypted keys need no passphrase and security keys
+// (ed25519-sk, ecdsa-sk) work at all. Without keyPath, every key of the agent
+// is offered.
+func Signers(keyPath string) ([]ssh.Signer, error) {
+	if key, ok, err := keyFromEnv(); ok {
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key in %s: %v", KeyEnv, err)
+		}
+		return []ssh.Signer{signer}, nil
+	}
+
+	agentKeys, err := agentSigners()
+	if err != nil {
+		return nil, err
+	}
+
+	if keyPath == "" {
+		if len(agentKeys) == 0 {
+			return nil, errors.New("no SSH key specified and no ssh-agent with keys is running")
+		}
+		return agentKeys, nil
+	}
+
+	path, err := expandHome(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := loadKey(path, agentKeys)
+	if err != nil {
+		return nil, err
+	}
+	return []ssh.Signer{signer}, nil
+}
+
+// AuthorizedKey returns the public key, in authorized_keys format, that
+// connections made with keyPath authenticate with.
+func AuthorizedKey(keyPath string) (string, error) {
+	signers, err := Signers(keyPath)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signers[0].PublicKey()))), nil
+}
+
+// loadKey returns the signer of the private key at path, through the agent
+// when one of agentKeys is that key.
+func loadKey(path string, agentKeys []ssh.Signer) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH key: %w", err)
+	}
+
+	signer, parseErr := ssh.ParsePrivateKey(data)
+	if parseErr == nil {
+		return signer, nil
+	}
+
+	if public := publicKeyOf(path, parseErr); public != nil {
+		for _, agentKey := range agentKeys {
+			if bytes.Equal(agentKey.PublicKey().Marshal(), public.Marshal()) {
+				return agentKey, nil
+			}
+		}
+		if isSecurityKey(public) {
+			return nil, fmt.Errorf("%s is a security key, which ftl can only use through ssh-agent: add it with ssh-add %s", path, path)
+		}
+	}
+
+	var missing *ssh.PassphraseMissingError
+	if errors.As(parseErr, &missing) {
+		return decryptKey(path, data)
+	}
+	return nil, fmt.Errorf("failed to parse private key %s: %v", path, parseErr)
+}
+
+// decryptKey decrypts the private key data read from path with a passphrase
+// asked once per run.
+func decryptKey(path string, data []byte) (ssh.Signer, error) {
+	passphrasesMu.Lock()
+	defer passphrasesMu.Unlock()
+
+	if passphrase, ok := passphrases[path]; ok {
+		return ssh.ParsePrivateKeyWithPassphrase(data, passphrase)
+	}
+	if passphrasePrompt == nil {
+		return nil, fmt.Errorf("SSH key %s is encrypted: add it to ssh-agent with ssh-add %s", path, path)
+	}
+
+	for attempt := 0; ; attempt++ {
+		passphrase, err := passphrasePrompt(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read passphrase of %s: %w", path, err)
+		}
+
+		signer, err := ssh.ParsePrivateKeyWithPassphrase(data, passphrase)
+		if errors.Is(err, x509.IncorrectPasswordError) && attempt < 2 {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt SSH key %s: %v", path, err)
+		}
+
+		passphrases[path] = passphrase
+		return signer, nil
+	}
+}
+
+// agentSigners returns the keys the ssh-agent holds, or none when no agent is
+// running.
+func agentSigners() ([]ssh.Signer, error) {
+	socket := identityAgent
+	if socket == "" {
+		socket = os.Getenv(AgentEnv)
+	}
+	if socket == "" || socket == NoAgent {
+		return nil, nil
+	}
+	socket, err := expandHome(socket)
+	if err != nil {
+		return nil, err
+	}
+
+	agentsMu.Lock()
+	defer agentsMu.Unlock()
+
+	client, ok := agents[socket]
+	if !ok {
+		// The connection stays open for the run: the agent signs on it
+		// whenever a connection authenticates.
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to ssh-agent at %s: %w", socket, err)
+		}
+		client = agent.NewClient(conn)
+		agents[socket] = client
+	}
+
+	signers, err := client.Signers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the keys of ssh-agent at %s: %w", socket, err)
+	}
+	return signers, nil
+}
+
+// publicKeyOf returns the public key of the private key at path that failed to
+// parse with err: the one in the header of an encrypted key, else the one in
+// the .pub file next to it.
+func publicKeyOf(path string, err error) ssh.PublicKey {
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) && missing.PublicKey != nil {
+		return missing.PublicKey
+	}
+
+	data, err := os.ReadFile(path + ".pub")
+	if err != nil {
+		return nil
+	}
+	public, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil
+	}
+	return public
+}
+
+// isSecurityKey reports whether key lives on a FIDO2 security key.
+func isSecurityKey(key ssh.PublicKey) bool {
+	return key.Type() == ssh.KeyAlgoSKED25519 || key.Type() == ssh.KeyAlgoSKECDSA256
+}
+
+// expandHome resolves a leading ~ in path to the home directory.
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, path[1:]), nil
+}
@@ -0,0 +1,255 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// KnownHostsFile is where the host keys of a project's servers are pinned,
+// relative to the directory of the configuration file. It is meant to be
+// committed so every machine deploying the project checks the same keys.
+const KnownHostsFile = ".ftl/known_hosts"
+
+// ConfirmFunc asks whether to trust the unknown key of host, identified by
+// its SHA256 fingerprint.
+type ConfirmFunc func(host, fingerprint string) (bool, error)
+
+// HostKeys checks server host keys against a known_hosts file, trusting a
+// server's key the first time ftl connects to it after confirmation.
+type HostKeys struct {
+	path    string
+	confirm ConfirmFunc
+	mu      sync.Mutex
+}
+
+// NewHostKeys returns a store backed by the known_hosts file at path, which is
+// created on the first trusted key. confirm is asked about unknown keys; when
+// it is nil, unknown keys are rejected.
+func NewHostKeys(path string, confirm ConfirmFunc) *HostKeys {
+	return &HostKeys{path: path, confirm: confirm}
+}
+
+// HostKeyChangedError is returned when a server presents a key other than the
+// one pinned for it.
+type HostKeyChangedError struct {
+	Host        string
+	Fingerprint string
+	Path        string
+}
+
+func (e *HostKeyChangedError) Error() string {
+	return fmt.Sprintf(`host key of %s has changed (now %s) and does not match the key pinned in %s.
+This happens when the server was rebuilt or reinstalled, but it can also mean
+someone is intercepting the connection. If the server was rebuilt on purpose,
+run "ftl server trust" to pin the new key`, e.Host, e.Fingerprint, e.Path)
+}
+
+// Callback returns the host key callback to connect with.
+func (h *HostKeys) Callback() ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		err := h.check(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if err == nil || !errors.As(err, &keyErr) {
+			return err
+		}
+
+		fingerprint := ssh.FingerprintSHA256(key)
+		if len(keyErr.Want) > 0 {
+			return &HostKeyChangedError{Host: hostname, Fingerprint: fingerprint, Path: h.path}
+		}
+
+		if h.confirm == nil {
+			return fmt.Errorf("host key of %s (%s) is not trusted yet, run \"ftl server trust\" to pin it", hostname, fingerprint)
+		}
+		trusted, err := h.confirm(hostname, fingerprint)
+		if err != nil {
+			return fmt.Errorf("failed to confirm host key of %s: %w", hostname, err)
+		}
+		if !trusted {
+			return fmt.Errorf("host key of %s (%s) was not trusted", hostname, fingerprint)
+		}
+		return h.add(hostname, key)
+	}
+}
+
+// Trust pins key for hostname, replacing any key pinned for it before.
+func (h *HostKeys) Trust(hostname string, key ssh.PublicKey) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	data, err := os.ReadFile(h.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", h.path, err)
+	}
+
+	address := knownhosts.Normalize(hostname)
+	var kept []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		if hosts, _, _ := strings.Cut(line, " "); hosts == address {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	kept = append(kept, knownhosts.Line([]string{address}, key))
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(h.path), err)
+	}
+	if err := os.WriteFile(h.path, []byte(strings.Join(kept, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", h.path, err)
+	}
+	return nil
+}
+
+// check verifies key against the pinned keys. A missing file pins nothing.
+func (h *HostKeys) check(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	if _, err := os.Stat(h.path); os.IsNotExist(err) {
+		return &knownhosts.KeyError{}
+	}
+	callback, err := knownhosts.New(h.path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", h.path, err)
+	}
+	return callback(hostname, remote, key)
+}
+
+// Algorithms returns the host key algorithms of the keys pinned for
+// hostname, so the server is asked for the key that can be checked rather
+// than the one it prefers. It returns nil when no key is pinned, leaving the
+// choice to the server.
+func (h *HostKeys) Algorithms(hostname string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		return nil
+	}
+
+	address := knownhosts.Normalize(hostname)
+	var algorithms []string
+	for len(data) > 0 {
+		marker, hosts, key, _, rest, err := ssh.ParseKnownHosts(data)
+		if err != nil {
+			break
+		}
+		data = rest
+		if marker != "" || !slices.Contains(hosts, address) {
+			continue
+		}
+		for _, algorithm := range keyAlgorithms(key.Type()) {
+			if !slices.Contains(algorithms, algorithm) {
+				algorithms = append(algorithms, algorithm)
+			}
+		}
+	}
+	return algorithms
+}
+
+// keyAlgorithms returns the algorithms a host can sign with using a key of
+// keyType. RSA keys sign with SHA-2 on current servers, and SHA-1 only on old
+// ones.
+func keyAlgorithms(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{keyType}
+}
+
+// add appends the key of hostname to the file.
+func (h *HostKeys) add(hostname string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(h.path), err)
+	}
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", h.path, err)
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintln(file, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)); err != nil {
+		return fmt.Errorf("failed to write %s: %w", h.path, err)
+	}
+	return nil
+}
+
+// hostKeys is the store connections are checked against. Without one, host
+// keys are not checked.
+var hostKeys *HostKeys
+
+// SetHostKeys makes every new connection check host keys against store.
+func SetHostKeys(store *HostKeys) {
+	hostKeys = store
+}
+
+func hostKeyCallback() ssh.HostKeyCallback {
+	if hostKeys == nil {
+		return ssh.InsecureIgnoreHostKey()
+	}
+	return hostKeys.Callback()
+}
+
+func hostKeyAlgorithms(addr string) []string {
+	if hostKeys == nil {
+		return nil
+	}
+	return hostKeys.Algorithms(addr)
+}
+
+// FetchHostKey connects to host, through jump when it is not nil, and
+// returns the host key it presents, without authenticating.
+func FetchHostKey(host string, port int, jump *Jump) (ssh.PublicKey, error) {
+	var hostKey ssh.PublicKey
+	config := &ssh.ClientConfig{
+		User: "ftl",
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKey = key
+			return errHostKeyFetched
+		},
+		Timeout: connectTimeout,
+	}
+
+	addr := net.JoinHostPort(host, fmt.Sprint(port))
+	var (
+		conn       net.Conn
+		jumpClient *ssh.Client
+		err        error
+	)
+	if jump != nil {
+		jumpClient, err = connectJump(jump)
+		if err != nil {
+			return nil, err
+		}
+		defer jumpClient.Close()
+		conn, err = jumpClient.Dial("tcp", addr)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, config.Timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s: %w", addr, err)
+	}
+	defer conn.Close()
+
+	_, _, _, err = ssh.NewClientConn(conn, addr, config)
+	if hostKey == nil {
+		return nil, fmt.Errorf("failed to get host key of %s: %w", host, err)
+	}
+	return hostKey, nil
+}
+
+var errHostKeyFetched = errors.New("host key fetched")
@@ -0,0 +1,91 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, err := ssh.NewPublicKey(public)
+	require.NoError(t, err)
+	return key
+}
+
+func TestHostKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".ftl", "known_hosts")
+	remote := &net.TCPAddr{IP: net.ParseIP("203.0.113.10"), Port: 22}
+	key, otherKey := newHostKey(t), newHostKey(t)
+
+	var asked []string
+	answer := false
+	store := NewHostKeys(path, func(host, fingerprint string) (bool, error) {
+		asked = append(asked, host+" "+fingerprint)
+		return answer, nil
+	})
+	callback := store.Callback()
+
+	err := callback("203.0.113.10:22", remote, key)
+	assert.EqualError(t, err, "host key of 203.0.113.10:22 ("+ssh.FingerprintSHA256(key)+") was not trusted")
+	assert.NoFileExists(t, path)
+
+	answer = true
+	require.NoError(t, callback("203.0.113.10:22", remote, key))
+	require.NoError(t, callback("203.0.113.10:22", remote, key))
+	assert.Equal(t, []string{
+		"203.0.113.10:22 " + ssh.FingerprintSHA256(key),
+		"203.0.113.10:22 " + ssh.FingerprintSHA256(key),
+	}, asked)
+
+	err = callback("203.0.113.10:22", remote, otherKey)
+	var changed *HostKeyChangedError
+	require.True(t, errors.As(err, &changed))
+	assert.Equal(t, ssh.FingerprintSHA256(otherKey), changed.Fingerprint)
+	assert.Contains(t, err.Error(), `run "ftl server trust" to pin the new key`)
+	assert.Len(t, asked, 2)
+
+	require.NoError(t, store.Trust("203.0.113.10:22", otherKey))
+	require.NoError(t, callback("203.0.113.10:22", remote, otherKey))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, ssh.FingerprintSHA256(otherKey), fingerprintOfLine(t, data))
+
+	err = NewHostKeys(path, nil).Callback()("198.51.100.1:2222", remote, key)
+	assert.ErrorContains(t, err, `host key of 198.51.100.1:2222 (`+ssh.FingerprintSHA256(key)+`) is not trusted yet`)
+}
+
+func TestHostKeysAlgorithms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	store := NewHostKeys(path, nil)
+	assert.Nil(t, store.Algorithms("203.0.113.10:22"), "without a file the server picks")
+
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaKey, err := ssh.NewPublicKey(&private.PublicKey)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Trust("203.0.113.10:22", newHostKey(t)))
+	require.NoError(t, store.Trust("198.51.100.1:2222", rsaKey))
+
+	assert.Equal(t, []string{ssh.KeyAlgoED25519}, store.Algorithms("203.0.113.10:22"))
+	assert.Equal(t, []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}, store.Algorithms("198.51.100.1:2222"))
+	assert.Nil(t, store.Algorithms("198.51.100.1:22"), "a host is pinned together with its port")
+}
+
+func fingerprintOfLine(t *testing.T, data []byte) string {
+	_, _, key, _, rest, err := ssh.ParseKnownHosts(data)
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+	return ssh.FingerprintSHA256(key)
+}
@@ -0,0 +1,111 @@
+package ssh
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// SOCKS5 protocol values of RFC 1928 that ServeSOCKS uses.
+const (
+	socksVersion      = 5
+	socksNoAuth       = 0
+	socksNoAcceptable = 0xff
+	socksConnectCmd   = 1
+	socksIPv4         = 1
+	socksDomain       = 3
+	socksIPv6         = 4
+
+	socksSucceeded       = 0
+	socksHostUnreachable = 4
+	socksCmdUnsupported  = 7
+	socksAddrUnsupported = 8
+)
+
+// socksConnect answers the SOCKS5 handshake of a client on conn, without
+// authentication, and opens the connection it asks for with dial. Only the
+// CONNECT command is supported.
+func socksConnect(conn net.Conn, dial func(network, addr string) (net.Conn, error)) (net.Conn, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, fmt.Errorf("failed to read greeting: %w", err)
+	}
+	if header[0] != socksVersion {
+		return nil, fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return nil, fmt.Errorf("failed to read authentication methods: %w", err)
+	}
+	noAuth := false
+	for _, method := range methods {
+		if method == socksNoAuth {
+			noAuth = true
+		}
+	}
+	if !noAuth {
+		_, _ = conn.Write([]byte{socksVersion, socksNoAcceptable})
+		return nil, errors.New("client requires authentication")
+	}
+	if _, err := conn.Write([]byte{socksVersion, socksNoAuth}); err != nil {
+		return nil, err
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return nil, fmt.Errorf("failed to read request: %w", err)
+	}
+	if request[1] != socksConnectCmd {
+		socksReply(conn, socksCmdUnsupported)
+		return nil, fmt.Errorf("unsupported SOCKS command %d", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case socksIPv4, socksIPv6:
+		size := net.IPv4len
+		if request[3] == socksIPv6 {
+			size = net.IPv6len
+		}
+		ip := make(net.IP, size)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return nil, fmt.Errorf("failed to read address: %w", err)
+		}
+		host = ip.String()
+	case socksDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return nil, fmt.Errorf("failed to read address: %w", err)
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return nil, fmt.Errorf("failed to read address: %w", err)
+		}
+		host = string(domain)
+	default:
+		socksReply(conn, socksAddrUnsupported)
+		return nil, fmt.Errorf("unsupported SOCKS address type %d", request[3])
+	}
+	portBytes := make([]byte, 2)
+	if _, err := io.ReadFull(conn, portBytes); err != nil {
+		return nil, fmt.Errorf("failed to read port: %w", err)
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(portBytes))))
+
+	remote, err := dial("tcp", addr)
+	if err != nil {
+		socksReply(conn, socksHostUnreachable)
+		return nil, fmt.Errorf("failed to dial %s: %w", addr, err)
+	}
+	socksReply(conn, socksSucceeded)
+	return remote, nil
+}
+
+// socksReply answers a SOCKS5 request with status. The bound address is left
+// empty: the connection is opened by the SSH server, not by this machine.
+func socksReply(conn net.Conn, status byte) {
+	_, _ = conn.Write([]byte{socksVersion, status, 0, socksIPv4, 0, 0, 0, 0, 0, 0})
+}
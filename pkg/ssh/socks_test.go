@@ -0,0 +1,81 @@
+package ssh
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocksConnect(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	var dialed string
+	remote, _ := net.Pipe()
+	result := make(chan error, 1)
+	go func() {
+		_, err := socksConnect(server, func(network, addr string) (net.Conn, error) {
+			dialed = network + " " + addr
+			return remote, nil
+		})
+		result <- err
+	}()
+
+	_, err := client.Write([]byte{5, 1, 0})
+	require.NoError(t, err)
+	reply := make([]byte, 2)
+	_, err = io.ReadFull(client, reply)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{5, 0}, reply, "no authentication is selected")
+
+	_, err = client.Write(append([]byte{5, 1, 0, 3, 8}, "db.local"...))
+	require.NoError(t, err)
+	_, err = client.Write([]byte{0x15, 0x38})
+	require.NoError(t, err)
+
+	reply = make([]byte, 10)
+	_, err = io.ReadFull(client, reply)
+	require.NoError(t, err)
+	assert.Equal(t, byte(0), reply[1], "the request succeeds")
+	require.NoError(t, <-result)
+	assert.Equal(t, "tcp db.local:5432", dialed)
+}
+
+func TestSocksConnect_Failures(t *testing.T) {
+	request := func(t *testing.T, greeting, req []byte, dial func(string, string) (net.Conn, error)) ([]byte, error) {
+		t.Helper()
+		client, server := net.Pipe()
+		defer client.Close()
+
+		result := make(chan error, 1)
+		go func() {
+			_, err := socksConnect(server, dial)
+			server.Close()
+			result <- err
+		}()
+
+		// net.Pipe is synchronous: the client writes while the replies are read.
+		go func() {
+			_, _ = client.Write(append(greeting, req...))
+		}()
+		reply, _ := io.ReadAll(client)
+		return reply, <-result
+	}
+	unreachable := func(string, string) (net.Conn, error) { return nil, errors.New("connection refused") }
+
+	reply, err := request(t, []byte{5, 1, 2}, nil, unreachable)
+	assert.ErrorContains(t, err, "requires authentication")
+	assert.Equal(t, []byte{5, 0xff}, reply)
+
+	reply, err = request(t, []byte{5, 1, 0}, []byte{5, 2, 0, 1}, unreachable)
+	assert.ErrorContains(t, err, "unsupported SOCKS command 2")
+	assert.Equal(t, byte(7), reply[3])
+
+	reply, err = request(t, []byte{5, 1, 0}, []byte{5, 1, 0, 1, 10, 0, 0, 5, 0, 80}, unreachable)
+	assert.ErrorContains(t, err, "failed to dial 10.0.0.5:80")
+	assert.Equal(t, byte(4), reply[3])
+}
@@ -2,18 +2,49 @@ package ssh
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"golang.org/x/crypto/ssh"
+
+	"github.com/yarlson/ftl/pkg/retry"
+)
+
+var (
+	// connectTimeout bounds each attempt to establish a connection.
+	connectTimeout = 10 * time.Second
+	// connectRetry is the policy connections that fail to be established
+	// for network reasons are retried with.
+	connectRetry = retry.Default
 )
 
+// SetConnectPolicy bounds every attempt to establish a connection by timeout
+// and retries the attempts that fail for network reasons, such as a refused
+// or timed out dial, with policy. Authentication and host key failures are
+// not retried.
+func SetConnectPolicy(timeout time.Duration, policy retry.Policy) {
+	connectTimeout = timeout
+	connectRetry = policy
+}
+
+// Jump is a bastion host that connections to a server are made through, like
+// ssh -J.
+type Jump struct {
+	Host   string
+	Port   int
+	User   string
+	SSHKey string
+}
+
 // NewSSHClientWithKey creates a new ssh.Client using a private key
 func NewSSHClientWithKey(host string, port int, user string, key []byte) (*ssh.Client, error) {
 	signer, err := ssh.ParsePrivateKey(key)
@@ -21,18 +52,16 @@ func NewSSHClientWithKey(host string, port int, user string, key []byte) (*ssh.C
 		return nil, fmt.Errorf("failed to parse private key: %v", err)
 	}
 
-	config := &ssh.ClientConfig{
-		User:            user,
-		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         10 * time.Second,
-	}
+	return newClientWithSigners(host, port, user, []ssh.Signer{signer})
+}
 
-	addr := fmt.Sprintf("%s:%d", host, port)
+func newClientWithSigners(host string, port int, user string, signers []ssh.Signer) (*ssh.Client, error) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	config := clientConfig(addr, user, signers)
 
 	conn, err := net.DialTimeout("tcp", addr, config.Timeout)
 	if err != nil {
-		return nil, fmt.Errorf("failed to dial TCP connection: %v", err)
+		return nil, fmt.Errorf("failed to dial TCP connection: %w", err)
 	}
 
 	if tcpConn, ok := conn.(*net.TCPConn); ok {
@@ -40,26 +69,94 @@ func NewSSHClientWithKey(host string, port int, user string, key []byte) (*ssh.C
 		_ = tcpConn.SetKeepAlivePeriod(30 * time.Second)
 	}
 
-	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	return newClient(conn, addr, config)
+}
+
+// NewSSHClientThroughJump creates a new ssh.Client using a private key,
+// connected through the jump host. Closing the client also closes the
+// connection to the jump host.
+func NewSSHClientThroughJump(host string, port int, user string, key []byte, jump *Jump) (*ssh.Client, error) {
+	signer, err := ssh.ParsePrivateKey(key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to establish SSH connection: %v", err)
+		return nil, fmt.Errorf("failed to parse private key: %v", err)
+	}
+
+	return newClientThroughJump(host, port, user, []ssh.Signer{signer}, jump)
+}
+
+func newClientThroughJump(host string, port int, user string, signers []ssh.Signer, jump *Jump) (*ssh.Client, error) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	config := clientConfig(addr, user, signers)
+
+	jumpClient, err := connectJump(jump)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := jumpClient.Dial("tcp", addr)
+	if err != nil {
+		jumpClient.Close()
+		return nil, fmt.Errorf("failed to dial %s through jump host %s: %v", addr, jump.Host, err)
+	}
+
+	client, err := newClient(conn, addr, config)
+	if err != nil {
+		jumpClient.Close()
+		return nil, err
 	}
 
-	client := ssh.NewClient(sshConn, chans, reqs)
+	go func() {
+		_ = client.Wait()
+		jumpClient.Close()
+	}()
+
 	return client, nil
 }
 
+// connectJump connects to the jump host with its own key.
+func connectJump(jump *Jump) (*ssh.Client, error) {
+	signers, err := Signers(jump.SSHKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find SSH key for jump host: %w", err)
+	}
+	jumpClient, err := newClientWithSigners(jump.Host, jump.Port, jump.User, signers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to jump host %s: %w", jump.Host, err)
+	}
+	return jumpClient, nil
+}
+
+func clientConfig(addr, user string, signers []ssh.Signer) *ssh.ClientConfig {
+	return &ssh.ClientConfig{
+		User:              user,
+		Auth:              []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		HostKeyCallback:   hostKeyCallback(),
+		HostKeyAlgorithms: hostKeyAlgorithms(addr),
+		Timeout:           connectTimeout,
+	}
+}
+
+func newClient(conn net.Conn, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to establish SSH connection: %w", err)
+	}
+
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
 // NewSSHClientWithPassword creates a new ssh.Client using a password
 func NewSSHClientWithPassword(host string, port string, user string, password string) (*ssh.Client, error) {
+	addr := net.JoinHostPort(host, port)
 	config := &ssh.ClientConfig{
-		User:            user,
-		Auth:            []ssh.AuthMethod{ssh.Password(password)},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         10 * time.Second,
+		User:              user,
+		Auth:              []ssh.AuthMethod{ssh.Password(password)},
+		HostKeyCallback:   hostKeyCallback(),
+		HostKeyAlgorithms: hostKeyAlgorithms(addr),
+		Timeout:           connectTimeout,
 	}
 
-	addr := fmt.Sprintf("%s:%s", host, port)
-
 	client, err := ssh.Dial("tcp", addr, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect: %v", err)
@@ -71,15 +168,39 @@ func NewSSHClientWithPassword(host string, port string, user string, password st
 // sshKeyPath is used only for testing purposes
 var sshKeyPath string
 
-// FindSSHKey looks for an SSH key in the given path or in default locations
+// KeyEnv is the environment variable that holds the private key to connect
+// with, as in CI where no key file is at hand. It takes precedence over key
+// files and may be base64 encoded, for CI systems that cannot store newlines.
+const KeyEnv = "FTL_SSH_KEY"
+
+// keyFromEnv returns the private key set in KeyEnv, if any.
+func keyFromEnv() ([]byte, bool, error) {
+	value := strings.TrimSpace(os.Getenv(KeyEnv))
+	if value == "" {
+		return nil, false, nil
+	}
+	if strings.HasPrefix(value, "-----BEGIN") {
+		return []byte(value + "\n"), true, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, true, fmt.Errorf("%s is neither a PEM private key nor base64 encoded: %w", KeyEnv, err)
+	}
+	return key, true, nil
+}
+
+// FindSSHKey returns the key set in KeyEnv, or looks for an SSH key in the
+// given path or in default locations
 func FindSSHKey(keyPath string) ([]byte, error) {
+	if key, ok, err := keyFromEnv(); ok {
+		return key, err
+	}
+
 	if keyPath != "" {
-		if strings.HasPrefix(keyPath, "~") {
-			home, err := os.UserHomeDir()
-			if err != nil {
-				return nil, fmt.Errorf("failed to get home directory: %w", err)
-			}
-			keyPath = filepath.Join(home, keyPath[1:])
+		keyPath, err := expandHome(keyPath)
+		if err != nil {
+			return nil, err
 		}
 
 		return os.ReadFile(keyPath)
@@ -102,19 +223,40 @@ func FindSSHKey(keyPath string) ([]byte, error) {
 	return nil, fmt.Errorf("no suitable SSH key found in %s", sshDir)
 }
 
-// FindKeyAndConnectWithUser finds an SSH key and establishes a connection
-func FindKeyAndConnectWithUser(host string, port int, user, keyPath string) (*ssh.Client, []byte, error) {
-	key, err := FindSSHKey(keyPath)
+// FindKeyAndConnectWithUser authenticates with the keys Signers returns for
+// keyPath and establishes a connection, through jump when it is not nil.
+func FindKeyAndConnectWithUser(host string, port int, user, keyPath string, jump *Jump) (*ssh.Client, error) {
+	signers, err := Signers(keyPath)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to find SSH key: %w", err)
+		return nil, fmt.Errorf("failed to find SSH key: %w", err)
 	}
 
-	client, err := NewSSHClientWithKey(host, port, user, key)
+	var client *ssh.Client
+	err = connectRetry.Do(context.Background(), func() error {
+		var err error
+		if jump != nil {
+			client, err = newClientThroughJump(host, port, user, signers, jump)
+		} else {
+			client, err = newClientWithSigners(host, port, user, signers)
+		}
+		if err != nil && !isNetworkError(err) {
+			return retry.Permanent(err)
+		}
+		return err
+	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to establish SSH connection: %w", err)
+		return nil, fmt.Errorf("failed to establish SSH connection: %w", err)
 	}
 
-	return client, key, nil
+	return client, nil
+}
+
+// isNetworkError reports whether a connection failed to be established for a
+// network reason that may go away on its own, not because of the credentials
+// or the host key.
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF)
 }
 
 // getSSHDir returns the SSH directory path
@@ -133,58 +275,139 @@ func getSSHDir() (string, error) {
 
 // CreateSSHTunnel establishes an SSH tunnel from a local port to a remote address through an SSH server.
 // It listens on localPort and forwards connections to remoteAddr via the SSH server at host:port.
-// Authentication is done using the provided user and keyPath (path to the private key file).
-func CreateSSHTunnel(ctx context.Context, host string, port int, user, keyPath, localPort string, remoteAddr string) error {
-	client, _, err := FindKeyAndConnectWithUser(host, port, user, keyPath)
+// Authentication is done using the provided user and keyPath (path to the private key file), and
+// the connection goes through jump when it is not nil.
+func CreateSSHTunnel(ctx context.Context, host string, port int, user, keyPath string, jump *Jump, localPort string, remoteAddr string) error {
+	localListener, err := net.Listen("tcp", "localhost:"+localPort)
+	if err != nil {
+		return fmt.Errorf("failed to listen on local port %s: %v", localPort, err)
+	}
+	return ServeTunnel(ctx, host, port, user, keyPath, jump, localListener, remoteAddr)
+}
+
+// ServeTunnel forwards every connection accepted on listener to remoteAddr
+// through the SSH server at host:port, until ctx is canceled. Listening before
+// the tunnel is served lets callers pick a free port with port 0 and learn it
+// from the listener. The listener is closed on return.
+func ServeTunnel(ctx context.Context, host string, port int, user, keyPath string, jump *Jump, listener net.Listener, remoteAddr string) error {
+	return serveListener(ctx, host, port, user, keyPath, jump, listener, func(client *ssh.Client, localConn net.Conn) {
+		remoteConn, err := client.Dial("tcp", remoteAddr)
+		if err != nil {
+			fmt.Printf("Failed to dial remote address %s: %v\n", remoteAddr, err)
+			localConn.Close()
+			return
+		}
+		handleConnection(localConn, remoteConn)
+	})
+}
+
+// ServeSOCKS runs a SOCKS5 proxy on listener that opens the connections its
+// clients ask for from the SSH server at host:port, like ssh -D, until ctx is
+// canceled. The listener is closed on return.
+func ServeSOCKS(ctx context.Context, host string, port int, user, keyPath string, jump *Jump, listener net.Listener) error {
+	return serveListener(ctx, host, port, user, keyPath, jump, listener, func(client *ssh.Client, localConn net.Conn) {
+		remoteConn, err := socksConnect(localConn, client.Dial)
+		if err != nil {
+			fmt.Printf("SOCKS request failed: %v\n", err)
+			localConn.Close()
+			return
+		}
+		handleConnection(localConn, remoteConn)
+	})
+}
+
+// serveListener connects to the SSH server at host:port and hands every
+// connection accepted on listener to handle, in its own goroutine, until ctx
+// is canceled.
+func serveListener(ctx context.Context, host string, port int, user, keyPath string, jump *Jump, listener net.Listener, handle func(*ssh.Client, net.Conn)) error {
+	defer listener.Close()
+
+	client, err := FindKeyAndConnectWithUser(host, port, user, keyPath, jump)
 	if err != nil {
 		return fmt.Errorf("failed to establish SSH connection: %v", err)
 	}
 	defer client.Close()
 
-	// Start keep-alive routine
+	go keepAlive(ctx, client)
+
 	go func() {
-		ticker := time.NewTicker(30 * time.Second)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
-				if err != nil {
-					fmt.Printf("Failed to send keep-alive packet: %v\n", err)
-					return
-				}
-			case <-ctx.Done():
-				return
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		localConn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if isClosedNetworkError(err) {
+				return fmt.Errorf("failed to accept local connection: %v", err)
 			}
+			fmt.Printf("Failed to accept local connection: %v\n", err)
+			continue
 		}
-	}()
 
-	localListener, err := net.Listen("tcp", "localhost:"+localPort)
+		go handle(client, localConn)
+	}
+}
+
+// CreateReverseSSHTunnel establishes an SSH tunnel from a remote address to a local address.
+// The SSH server at host:port listens on remoteAddr and every connection it accepts is forwarded
+// to localAddr on this machine, until ctx is canceled.
+func CreateReverseSSHTunnel(ctx context.Context, host string, port int, user, keyPath string, jump *Jump, remoteAddr, localAddr string) error {
+	client, err := FindKeyAndConnectWithUser(host, port, user, keyPath, jump)
 	if err != nil {
-		return fmt.Errorf("failed to listen on local port %s: %v", localPort, err)
+		return fmt.Errorf("failed to establish SSH connection: %v", err)
+	}
+	defer client.Close()
+
+	go keepAlive(ctx, client)
+
+	remoteListener, err := client.Listen("tcp", remoteAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on remote address %s: %v", remoteAddr, err)
 	}
-	defer localListener.Close()
+
+	go func() {
+		<-ctx.Done()
+		remoteListener.Close()
+	}()
 
 	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		default:
-			localConn, err := localListener.Accept()
-			if err != nil {
-				fmt.Printf("Failed to accept local connection: %v\n", err)
-				continue
+		remoteConn, err := remoteListener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
 			}
+			return fmt.Errorf("failed to accept remote connection: %v", err)
+		}
+
+		localConn, err := net.Dial("tcp", localAddr)
+		if err != nil {
+			fmt.Printf("Failed to dial local address %s: %v\n", localAddr, err)
+			remoteConn.Close()
+			continue
+		}
+
+		go handleConnection(localConn, remoteConn)
+	}
+}
 
-			remoteConn, err := client.Dial("tcp", remoteAddr)
+// keepAlive sends keep-alive packets over client until ctx is canceled.
+func keepAlive(ctx context.Context, client *ssh.Client) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
 			if err != nil {
-				fmt.Printf("Failed to dial remote address %s: %v\n", remoteAddr, err)
-				localConn.Close()
-				continue
+				fmt.Printf("Failed to send keep-alive packet: %v\n", err)
+				return
 			}
-
-			// Handle the connection in a separate goroutine
-			go handleConnection(localConn, remoteConn)
+		case <-ctx.Done():
+			return
 		}
 	}
 }
@@ -3,6 +3,9 @@ package tunnel
 import (
 	"context"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,37 +15,144 @@ import (
 )
 
 // Config describes which local port should forward to which remote address.
+// A local port of 0 is replaced with a free one when the tunnel starts.
 type Config struct {
 	LocalPort  string
 	RemoteAddr string
 }
 
-// StartTunnels spawns one goroutine per tunnel, each calling ssh.CreateSSHTunnel.
+// ReverseConfig describes which remote address on the server should forward
+// to which address reachable from this machine.
+type ReverseConfig struct {
+	RemoteAddr string
+	LocalAddr  string
+}
+
+// StartTunnels listens on the local port of every tunnel and spawns one
+// goroutine per tunnel, each calling ssh.ServeTunnel. It returns the tunnels
+// with the local ports they listen on.
 func StartTunnels(
 	ctx context.Context,
 	host string,
 	port int,
 	user, sshKey string,
+	jump *ssh.Jump,
 	tunnels []Config,
+) ([]Config, error) {
+	if len(tunnels) == 0 {
+		return nil, fmt.Errorf("no tunnels to establish")
+	}
+
+	started := make([]Config, 0, len(tunnels))
+	listeners := make([]net.Listener, 0, len(tunnels))
+	for _, t := range tunnels {
+		listener, err := listen(t.LocalPort)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+		started = append(started, Config{LocalPort: listenerPort(listener), RemoteAddr: t.RemoteAddr})
+	}
+
+	starters := make([]func() error, 0, len(tunnels))
+	for i, t := range started {
+		listener := listeners[i]
+		starters = append(starters, func() error {
+			err := ssh.ServeTunnel(ctx, host, port, user, sshKey, jump, listener, t.RemoteAddr)
+			if err != nil {
+				return fmt.Errorf("tunnel %s -> %s failed: %v", t.LocalPort, t.RemoteAddr, err)
+			}
+			return nil
+		})
+	}
+
+	return started, start(starters)
+}
+
+// StartSOCKS runs a SOCKS5 proxy on localPort, or a free port when it is 0,
+// whose connections are opened from the server, and returns the port it
+// listens on.
+func StartSOCKS(
+	ctx context.Context,
+	host string,
+	port int,
+	user, sshKey string,
+	jump *ssh.Jump,
+	localPort string,
+) (string, error) {
+	listener, err := listen(localPort)
+	if err != nil {
+		return "", err
+	}
+	started := listenerPort(listener)
+
+	return started, start([]func() error{func() error {
+		if err := ssh.ServeSOCKS(ctx, host, port, user, sshKey, jump, listener); err != nil {
+			return fmt.Errorf("SOCKS proxy on port %s failed: %v", started, err)
+		}
+		return nil
+	}})
+}
+
+// listen listens on localPort of localhost.
+func listen(localPort string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort("localhost", localPort))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on local port %s: %v", localPort, err)
+	}
+	return listener, nil
+}
+
+// listenerPort returns the port listener listens on.
+func listenerPort(listener net.Listener) string {
+	return strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+}
+
+// StartReverseTunnels spawns one goroutine per tunnel, each calling ssh.CreateReverseSSHTunnel.
+func StartReverseTunnels(
+	ctx context.Context,
+	host string,
+	port int,
+	user, sshKey string,
+	jump *ssh.Jump,
+	tunnels []ReverseConfig,
 ) error {
 	if len(tunnels) == 0 {
 		return fmt.Errorf("no tunnels to establish")
 	}
 
+	starters := make([]func() error, 0, len(tunnels))
+	for _, t := range tunnels {
+		starters = append(starters, func() error {
+			err := ssh.CreateReverseSSHTunnel(ctx, host, port, user, sshKey, jump, t.RemoteAddr, t.LocalAddr)
+			if err != nil {
+				return fmt.Errorf("reverse tunnel %s -> %s failed: %v", t.RemoteAddr, t.LocalAddr, err)
+			}
+			return nil
+		})
+	}
+
+	return start(starters)
+}
+
+// start runs every starter in its own goroutine and returns the first error
+// reported while the tunnels are being established.
+func start(starters []func() error) error {
 	var wg sync.WaitGroup
-	errorChan := make(chan error, len(tunnels))
+	errorChan := make(chan error, len(starters))
 
-	for _, t := range tunnels {
+	for _, starter := range starters {
 		wg.Add(1)
-		go func(tun Config) {
+		go func() {
 			defer wg.Done()
 
-			err := ssh.CreateSSHTunnel(ctx, host, port, user, sshKey, tun.LocalPort, tun.RemoteAddr)
-			if err != nil {
-				errorChan <- fmt.Errorf("tunnel %s -> %s failed: %v",
-					tun.LocalPort, tun.RemoteAddr, err)
+			if err := starter(); err != nil {
+				errorChan <- err
 			}
-		}(t)
+		}()
 	}
 
 	go func() {
@@ -74,3 +184,60 @@ func CollectDependencyTunnels(cfg *config.Config) []Config {
 	}
 	return tunnels
 }
+
+// ParseReverse parses a reverse tunnel in the format of ssh -R:
+// [bind_address:]remote_port:[local_host:]local_port. The server listens on
+// localhost unless a bind address is given, and local_host defaults to
+// localhost. IPv6 addresses are written in brackets, as in
+// [::1]:9000:[::1]:3000.
+func ParseReverse(spec string) (ReverseConfig, error) {
+	parts := splitSpec(spec)
+
+	var bind, remotePort, localHost, localPort string
+	switch len(parts) {
+	case 2:
+		bind, remotePort, localHost, localPort = "localhost", parts[0], "localhost", parts[1]
+	case 3:
+		bind, remotePort, localHost, localPort = "localhost", parts[0], parts[1], parts[2]
+	case 4:
+		bind, remotePort, localHost, localPort = parts[0], parts[1], parts[2], parts[3]
+	default:
+		return ReverseConfig{}, fmt.Errorf("invalid reverse tunnel %q: expected [bind_address:]remote_port:[local_host:]local_port", spec)
+	}
+
+	for _, p := range []string{remotePort, localPort} {
+		if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+			return ReverseConfig{}, fmt.Errorf("invalid reverse tunnel %q: invalid port %q", spec, p)
+		}
+	}
+	if bind == "" || localHost == "" {
+		return ReverseConfig{}, fmt.Errorf("invalid reverse tunnel %q: empty host", spec)
+	}
+
+	return ReverseConfig{
+		RemoteAddr: net.JoinHostPort(bind, remotePort),
+		LocalAddr:  net.JoinHostPort(localHost, localPort),
+	}, nil
+}
+
+// splitSpec splits a tunnel spec at the colons outside brackets, and strips
+// the brackets around IPv6 addresses.
+func splitSpec(spec string) []string {
+	var parts []string
+	var part strings.Builder
+	bracketed := false
+	for _, r := range spec {
+		switch {
+		case r == '[' && part.Len() == 0:
+			bracketed = true
+		case r == ']' && bracketed:
+			bracketed = false
+		case r == ':' && !bracketed:
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteRune(r)
+		}
+	}
+	return append(parts, part.String())
+}
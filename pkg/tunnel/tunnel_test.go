@@ -0,0 +1,45 @@
+package tunnel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReverse(t *testing.T) {
+	tests := []struct {
+		spec string
+		want ReverseConfig
+	}{
+		{"9000:3000", ReverseConfig{RemoteAddr: "localhost:9000", LocalAddr: "localhost:3000"}},
+		{"9000:192.168.1.10:5432", ReverseConfig{RemoteAddr: "localhost:9000", LocalAddr: "192.168.1.10:5432"}},
+		{"0.0.0.0:9000:localhost:3000", ReverseConfig{RemoteAddr: "0.0.0.0:9000", LocalAddr: "localhost:3000"}},
+		{"[::]:9000:[::1]:3000", ReverseConfig{RemoteAddr: "[::]:9000", LocalAddr: "[::1]:3000"}},
+		{"9000:[2001:db8::5]:5432", ReverseConfig{RemoteAddr: "localhost:9000", LocalAddr: "[2001:db8::5]:5432"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseReverse(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	for _, spec := range []string{"9000", "abc:3000", "9000:70000", "a:b:c:d:e", "9000::3000"} {
+		_, err := ParseReverse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestListen_FreePort(t *testing.T) {
+	listener, err := listen("0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	assert.NotEqual(t, "0", listenerPort(listener))
+
+	_, err = listen(listenerPort(listener))
+	assert.ErrorContains(t, err, "failed to listen on local port", "a port in use is refused")
+}